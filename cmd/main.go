@@ -10,7 +10,10 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/opiproject/gospdk/spdk"
@@ -19,6 +22,7 @@ import (
 	"github.com/opiproject/opi-spdk-bridge/pkg/frontend"
 	"github.com/opiproject/opi-spdk-bridge/pkg/kvm"
 	"github.com/opiproject/opi-spdk-bridge/pkg/middleend"
+	"github.com/opiproject/opi-spdk-bridge/pkg/transaction"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 
 	pc "github.com/opiproject/opi-api/inventory/v1/gen/go"
@@ -26,10 +30,11 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 
 	"github.com/philippgille/gokv"
-	"github.com/philippgille/gokv/redis"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
@@ -44,6 +49,20 @@ func splitBusesBySeparator(str string) []string {
 	return []string{}
 }
 
+func splitInterceptors(str string) []string {
+	if str != "" {
+		return strings.Split(str, ",")
+	}
+	return []string{}
+}
+
+func splitAnnotationKeys(str string) []string {
+	if str != "" {
+		return strings.Split(str, ",")
+	}
+	return []string{}
+}
+
 func main() {
 	var grpcPort int
 	flag.IntVar(&grpcPort, "grpc_port", 50051, "The gRPC server port")
@@ -54,34 +73,231 @@ func main() {
 	var spdkAddress string
 	flag.StringVar(&spdkAddress, "spdk_addr", "/var/tmp/spdk.sock", "Points to SPDK unix socket/tcp socket to interact with")
 
+	var spdkWaitTimeout time.Duration
+	flag.DurationVar(&spdkWaitTimeout, "spdk_wait_timeout", 0, "How long to wait at startup for SPDK unix socket to become available, e.g. \"30s\". 0 means fail immediately")
+
+	var spdkTimeout time.Duration
+	flag.DurationVar(&spdkTimeout, "spdk_timeout", 0, "Timeout of each SPDK JSON-RPC call, e.g. \"30s\", shortened by sooner gRPC request deadline. 0 means calls are bounded by gRPC request deadline only. The connection of a timed out call is closed, SPDK may still complete it, e.g. leave a bdev of a timed out create the bridge does not track. Calls undoing a failed or timed out request, e.g. subsystem resume or rollback, are bounded by this timeout instead of the request deadline, 30s if 0")
+
+	var spdkRetries int
+	flag.IntVar(&spdkRetries, "spdk_retries", 0, "Number of retries of SPDK JSON-RPC calls failed on connection level. Failed connects are retried for all calls, failures after the request was sent, e.g. EOF, only for read-only calls. Errors reported by SPDK are never retried. 0 disables retries")
+
+	var spdkRetryBackoff time.Duration
+	flag.DurationVar(&spdkRetryBackoff, "spdk_retry_backoff", utils.DefaultSpdkRetryBackoff, "Delay before the first retry of SPDK JSON-RPC call, doubled with every next retry")
+
+	var spdkIDMismatch string
+	flag.StringVar(&spdkIDMismatch, "spdk_id_mismatch", utils.SpdkIDMismatchReconnect, "Handling of SPDK responses with mismatched ID: \"reconnect\" recreates SPDK client and fails the call as unavailable, \"fail\" only fails the call")
+
 	var useKvm bool
 	flag.BoolVar(&useKvm, "kvm", false, "Automates interaction with QEMU to plug/unplug SPDK devices")
 
 	var qmpAddress string
 	flag.StringVar(&qmpAddress, "qmp_addr", "127.0.0.1:5555", "Points to QMP unix socket/tcp socket to interact with. Valid only with -kvm option")
 
+	var qmpDialTimeout time.Duration
+	flag.DurationVar(&qmpDialTimeout, "qmp_dial_timeout", kvm.DefaultQmpDialTimeout, "Timeout of connecting to QMP including capabilities negotiation. Valid only with -kvm option")
+
+	var qmpConnectRetries int
+	flag.IntVar(&qmpConnectRetries, "qmp_connect_retries", kvm.DefaultQmpConnectRetries, "Number of reconnects to QMP when connecting fails, e.g. while QEMU restarts. 0 disables reconnects. Valid only with -kvm option")
+
+	var qmpConnectBackoff time.Duration
+	flag.DurationVar(&qmpConnectBackoff, "qmp_connect_backoff", kvm.DefaultQmpConnectBackoff, "Delay before the first reconnect to QMP, doubled with every next reconnect. Valid only with -kvm option")
+
 	var ctrlrDir string
-	flag.StringVar(&ctrlrDir, "ctrlr_dir", "", "Directory with created SPDK device unix sockets (-S option in SPDK). Valid only with -kvm option")
+	flag.StringVar(&ctrlrDir, "ctrlr_dir", "", "Directory with created SPDK device unix sockets (-S option in SPDK). Valid only with -kvm option or -virtio_blk_transport=vfio-user")
+
+	var virtioBlkTransport string
+	flag.StringVar(&virtioBlkTransport, "virtio_blk_transport", "vhost-user", "Transport of virtio-blk controllers: \"vhost-user\" or \"vfio-user\" creating PCIe endpoints in -ctrlr_dir without QEMU. Valid only without -kvm option")
+
+	var fileRoot string
+	flag.StringVar(&fileRoot, "file_root", "", "Directory all file paths (Aio filenames, key files, controller sockets) must be located in. Empty means no restriction")
+
+	var cleanupKeyFiles bool
+	flag.BoolVar(&cleanupKeyFiles, "cleanup_key_files", false, "Remove PSK key files left in key files directory (-file_root or /var/tmp) by previous runs at startup. Removes key files of every bridge using that directory, so enable it only when the directory is not shared with other bridges")
 
 	var busesStr string
 	flag.StringVar(&busesStr, "buses", "", "QEMU PCI buses IDs separated by `:` to attach Nvme/virtio-blk devices on. e.g. \"pci.opi.0:pci.opi.1\". Valid only with -kvm option")
 
 	var tlsFiles string
-	flag.StringVar(&tlsFiles, "tls", "", "TLS files in server_cert:server_key:ca_cert format.")
+	flag.StringVar(&tlsFiles, "tls", "", "TLS files in server_cert:server_key[:ca_cert] format.")
+
+	var mtls bool
+	flag.BoolVar(&mtls, "mtls", true, "Require clients to present a certificate signed by ca_cert of -tls. Otherwise client certificates are optional, verified against ca_cert when presented, and ca_cert may be omitted")
+
+	var enableChannelz bool
+	flag.BoolVar(&enableChannelz, "enable_channelz", false, "Registers gRPC channelz service to inspect connections state. With -tls it is restricted to -admin_identities")
+
+	var importState string
+	flag.StringVar(&importState, "import_state", "", "Path to bridge state document returned by ExportState to import at startup without calling SPDK, for disaster recovery when SPDK already holds the resources. The redis store has to be preserved as well")
+
+	var adminIdentities string
+	flag.StringVar(&adminIdentities, "admin_identities", "", "Client certificate common names or DNS names separated by `,` allowed to call admin services, e.g. channelz and state export. Valid only with -tls option, state service is registered only when set")
 
 	var redisAddress string
 	flag.StringVar(&redisAddress, "redis_addr", "127.0.0.1:6379", "Redis address in ip_address:port format")
 
+	var kvBackend string
+	flag.StringVar(&kvBackend, "kv_backend", utils.KvBackendRedis, "Store of annotations, expiry and other data SPDK cannot hold: \"redis\" keeps it across restarts, \"memory\" needs no Redis but loses it on restart")
+
+	var redisPrefix string
+	flag.StringVar(&redisPrefix, "redis_prefix", "", "Prefix of all Redis keys, separated from them by \":\", letting multiple bridges share one Redis. Empty means keys are not prefixed")
+
+	blockSizes := backend.DefaultBlockSizes
+	flag.Int64Var(&blockSizes.Null, "null_block_size", blockSizes.Null, "Default block size for Null volumes created without block_size")
+	flag.Int64Var(&blockSizes.Malloc, "malloc_block_size", blockSizes.Malloc, "Default block size for Malloc volumes created without block_size")
+	flag.Int64Var(&blockSizes.Aio, "aio_block_size", blockSizes.Aio, "Default block size for Aio volumes created without block_size")
+
+	var defaultQos backend.QosProfile
+	flag.IntVar(&defaultQos.RwIosPerSec, "default_qos_rw_iops", 0, "Read/write IOPS limit applied to new Null/Aio volumes unless overridden in request. 0 means no limit")
+	flag.IntVar(&defaultQos.RwMbytesPerSec, "default_qos_rw_mbs", 0, "Read/write bandwidth limit in MB/s applied to new Null/Aio volumes unless overridden in request. 0 means no limit")
+	flag.IntVar(&defaultQos.RMbytesPerSec, "default_qos_rd_mbs", 0, "Read bandwidth limit in MB/s applied to new Null/Aio volumes unless overridden in request. 0 means no limit")
+	flag.IntVar(&defaultQos.WMbytesPerSec, "default_qos_wr_mbs", 0, "Write bandwidth limit in MB/s applied to new Null/Aio volumes unless overridden in request. 0 means no limit")
+
+	var ttlReapInterval time.Duration
+	flag.DurationVar(&ttlReapInterval, "ttl_reap_interval", 10*time.Second, "How often volumes created with ttl are checked for expiry and deleted")
+
+	var annotationKeys string
+	flag.StringVar(&annotationKeys, "annotation_keys", "", "Annotation keys separated by `,` accepted on volume create as opi-annotation-<key> request metadata, e.g. \"owner,ticket\". Annotations are stored keyed by bdev name")
+
+	var healthCheckInterval time.Duration
+	flag.DurationVar(&healthCheckInterval, "health_check_interval", utils.DefaultHealthCheckInterval, "How often SPDK is probed to report gRPC health status")
+	var healthFailureThreshold int
+	flag.IntVar(&healthFailureThreshold, "health_failure_threshold", utils.DefaultHealthFailureThreshold, "Consecutive failed SPDK probes before health status becomes NOT_SERVING")
+	var healthSuccessThreshold int
+	flag.IntVar(&healthSuccessThreshold, "health_success_threshold", utils.DefaultHealthSuccessThreshold, "Consecutive successful SPDK probes before health status becomes SERVING again")
+
+	var autoPause bool
+	flag.BoolVar(&autoPause, "auto_pause", true, "Pause Nvme subsystems while adding/removing namespaces and listeners. They are always resumed, even on failure")
+
+	var statsInterval time.Duration
+	flag.DurationVar(&statsInterval, "stats_interval", 0, "Shortest period between bdev_get_iostat calls serving Nvme controller, namespace and path stats, e.g. \"1s\". Requests within it share stats of the last call. 0 queries SPDK on every request")
+
+	var emptyStats string
+	flag.StringVar(&emptyStats, "empty_stats", frontend.EmptyStatsNoData, "Handling of Nvme controller and namespace stats SPDK has no entry for: \"no-data\" returns zeros flagged by opi-stats-no-data header, \"not-found\" fails the call as not found")
+
+	var nqnBase string
+	flag.StringVar(&nqnBase, "nqn_base", "", "Date and reversed domain part, e.g. nqn.2024-01.com.example, of NQNs generated as <base>:opi:<id> for Nvme subsystems created without NQN. Empty requires NQN")
+
+	var hostID string
+	flag.StringVar(&hostID, "host_id", "", "UUID identifying this host. Hostnqn nqn.2014-08.org.nvmexpress:uuid:<host_id> is used for TCP Nvme paths created without one. Empty derives the UUID from host name")
+
+	var listByteBudget int
+	flag.IntVar(&listByteBudget, "list_byte_budget", utils.DefaultListByteBudget, "Max encoded size in bytes of List responses. Larger ones are truncated and return next page token")
+
+	var paginationTokenTTL time.Duration
+	flag.DurationVar(&paginationTokenTTL, "pagination_token_ttl", utils.DefaultPaginationTokenTTL, "How long List page tokens stay valid after they were returned, e.g. \"10m\". Expired tokens are rejected with NotFound")
+
+	var grpcMaxRecvMsgSize int
+	flag.IntVar(&grpcMaxRecvMsgSize, "grpc_max_recv_msg_size", utils.DefaultGrpcMaxMsgSize, "Max size in bytes of gRPC requests the server receives")
+
+	var grpcMaxSendMsgSize int
+	flag.IntVar(&grpcMaxSendMsgSize, "grpc_max_send_msg_size", utils.DefaultGrpcMaxMsgSize, "Max size in bytes of gRPC responses the server sends. Raise -list_byte_budget accordingly to return larger List pages")
+
+	var resourceNamePrefix string
+	flag.StringVar(&resourceNamePrefix, "resource_name_prefix", "", "Prefix, e.g. clusters/cluster-a, prepended to names of all resources. Empty keeps default naming")
+
+	var interceptors string
+	flag.StringVar(&interceptors, "interceptors", strings.Join(utils.DefaultInterceptors, ","), "Enabled gRPC unary interceptors separated by `,` in invocation order, the first being the outermost")
+
+	var tenantQuotas string
+	flag.StringVar(&tenantQuotas, "tenant_quotas", "", "Number of resources of a kind each tenant, given in opi-tenant request metadata, can create in kind=limit format separated by `,`, e.g. \"NvmeSubsystem=10,NvmeController=20\". Kind is resource name of Create/Delete methods")
+
+	var metricsPort int
+	flag.IntVar(&metricsPort, "metrics_port", 0, "The port Prometheus metrics are served on. 0 means the HTTP server port")
+
+	var metricsPath string
+	flag.StringVar(&metricsPath, "metrics_path", utils.DefaultMetricsPath, "HTTP path Prometheus metrics are served on. Empty disables metrics")
+
+	var logLevel string
+	flag.StringVar(&logLevel, "log_level", "info", "Minimal level of logged gRPC calls: debug, info, warn or error. Request and response payloads are logged at debug only")
+
+	var logFormat string
+	flag.StringVar(&logFormat, "log_format", utils.LogFormatText, "Format of logged gRPC calls: \"text\" or \"json\", one object per line with method, duration and status code")
+
+	var configPath string
+	flag.StringVar(&configPath, "config", "", "JSON or YAML (.yaml/.yml) config file with settings keyed by flag names, e.g. grpc_port, spdk_addr, kvm or buses as a list. Flags given on command line override file values. Re-read on SIGHUP to apply log_level, log_format, tls and feature_flags without restart")
+
 	flag.Parse()
 
+	if err := utils.SetFileRoot(fileRoot); err != nil {
+		log.Panic(err)
+	}
+	if err := utils.SetListByteBudget(listByteBudget); err != nil {
+		log.Panic(err)
+	}
+	if err := utils.SetPaginationTokenTTL(paginationTokenTTL); err != nil {
+		log.Panic(err)
+	}
+	if spdkTimeout > 0 {
+		if err := utils.SetSpdkCleanupTimeout(spdkTimeout); err != nil {
+			log.Panic(err)
+		}
+	}
+	utils.SetMutualTLS(mtls)
+	if err := utils.SetLogLevel(logLevel); err != nil {
+		log.Panicf("invalid log_level: %v", err)
+	}
+	if err := utils.SetLogFormat(logFormat); err != nil {
+		log.Panicf("invalid log_format: %v", err)
+	}
+	if resourceNamePrefix != "" {
+		if err := utils.SetNameStrategy(utils.NewPrefixNameStrategy(resourceNamePrefix)); err != nil {
+			log.Panicf("invalid resource_name_prefix: %v", err)
+		}
+	}
+	if cleanupKeyFiles {
+		removed, err := utils.RemoveStaleKeyFiles(utils.KeyFileDir())
+		if err != nil {
+			log.Panicf("failed to remove stale key files: %v", err)
+		}
+		log.Printf("Removed %d stale key file(s) from %v", removed, utils.KeyFileDir())
+	}
+
+	msgSizeServerOptions, err := utils.GrpcMaxMsgSizeServerOptions(grpcMaxRecvMsgSize, grpcMaxSendMsgSize)
+	if err != nil {
+		log.Panicf("invalid gRPC message size: %v", err)
+	}
+	msgSizeDialOptions, err := utils.GrpcMaxMsgSizeDialOptions(grpcMaxRecvMsgSize, grpcMaxSendMsgSize)
+	if err != nil {
+		log.Panicf("invalid gRPC message size: %v", err)
+	}
+
+	quotas, err := utils.ParseTenantQuotas(tenantQuotas)
+	if err != nil {
+		log.Panicf("invalid tenant_quotas: %v", err)
+	}
+
+	config := utils.Config{
+		GrpcPort:     grpcPort,
+		HTTPPort:     httpPort,
+		SpdkAddress:  spdkAddress,
+		RedisAddress: redisAddress,
+		TLSFiles:     tlsFiles,
+		LogLevel:     logLevel,
+		LogFormat:    logFormat,
+		Interceptors: splitInterceptors(interceptors),
+		TenantQuotas: quotas,
+		Kvm:          useKvm,
+		QmpAddress:   qmpAddress,
+		CtrlrDir:     ctrlrDir,
+		Buses:        splitBusesBySeparator(busesStr),
+	}
+	if configPath != "" {
+		flagsConfig, setFlags := config, commandLineFlags()
+		config = applyConfigFile(configPath, flagsConfig, setFlags)
+		grpcPort, httpPort = config.GrpcPort, config.HTTPPort
+		spdkAddress, redisAddress, tlsFiles = config.SpdkAddress, config.RedisAddress, config.TLSFiles
+		useKvm, qmpAddress, ctrlrDir = config.Kvm, config.QmpAddress, config.CtrlrDir
+		busesStr = strings.Join(config.Buses, ":")
+		go reloadConfigOnSighup(configPath, config, flagsConfig, setFlags)
+	}
+
 	// Create KV store for persistence
-	options := redis.DefaultOptions
-	options.Address = redisAddress
-	options.Codec = utils.ProtoCodec{}
-	store, err := redis.NewClient(options)
+	kvStore, err := utils.NewStore(kvBackend, redisAddress)
 	if err != nil {
 		log.Panic(err)
 	}
+	store := utils.NewPrefixedStore(kvStore, redisPrefix)
 	defer func(store gokv.Store) {
 		err := store.Close()
 		if err != nil {
@@ -89,11 +305,146 @@ func main() {
 		}
 	}(store)
 
-	go runGatewayServer(grpcPort, httpPort)
-	runGrpcServer(grpcPort, useKvm, store, spdkAddress, qmpAddress, ctrlrDir, busesStr, tlsFiles)
+	var metrics *utils.Metrics
+	if metricsPath != "" {
+		metrics = utils.NewMetrics()
+		if metricsPort != 0 && metricsPort != httpPort {
+			go runMetricsServer(metricsPort, metricsPath, metrics)
+		}
+	}
+
+	go runGatewayServer(grpcPort, httpPort, metricsPort, metricsPath, metrics, msgSizeDialOptions)
+	runGrpcServer(store, metrics, grpcServerOptions{
+		grpcPort:        grpcPort,
+		msgSizeOptions:  msgSizeServerOptions,
+		tlsFiles:        tlsFiles,
+		interceptors:    config.Interceptors,
+		tenantQuotas:    config.TenantQuotas,
+		enableChannelz:  enableChannelz,
+		adminIdentities: adminIdentities,
+		importState:     importState,
+
+		spdkAddress:      spdkAddress,
+		spdkWaitTimeout:  spdkWaitTimeout,
+		spdkTimeout:      spdkTimeout,
+		spdkRetries:      spdkRetries,
+		spdkRetryBackoff: spdkRetryBackoff,
+		spdkIDMismatch:   spdkIDMismatch,
+
+		useKvm:             useKvm,
+		qmpAddress:         qmpAddress,
+		qmpDialTimeout:     qmpDialTimeout,
+		qmpConnectRetries:  qmpConnectRetries,
+		qmpConnectBackoff:  qmpConnectBackoff,
+		ctrlrDir:           ctrlrDir,
+		virtioBlkTransport: virtioBlkTransport,
+		buses:              splitBusesBySeparator(busesStr),
+
+		blockSizes:      blockSizes,
+		defaultQos:      defaultQos,
+		ttlReapInterval: ttlReapInterval,
+		annotationKeys:  splitAnnotationKeys(annotationKeys),
+		hostID:          hostID,
+
+		healthCheckInterval:    healthCheckInterval,
+		healthFailureThreshold: healthFailureThreshold,
+		healthSuccessThreshold: healthSuccessThreshold,
+
+		autoPause:     autoPause,
+		statsInterval: statsInterval,
+		emptyStats:    emptyStats,
+		nqnBase:       nqnBase,
+	})
+}
+
+// applyConfigFile returns settings of config file overridden by flags given
+// on command line and applies log and feature flags settings of the result
+func applyConfigFile(configPath string, config utils.Config, setFlags map[string]bool) utils.Config {
+	fileConfig, err := utils.LoadConfig(configPath)
+	if err != nil {
+		log.Panic(err)
+	}
+	config = utils.MergeConfig(fileConfig, config, setFlags)
+	if err := utils.SetLogLevel(config.LogLevel); err != nil {
+		log.Panicf("invalid log_level: %v", err)
+	}
+	if err := utils.SetLogFormat(config.LogFormat); err != nil {
+		log.Panicf("invalid log_format: %v", err)
+	}
+	utils.SetFeatureFlags(config.FeatureFlags)
+	return config
 }
 
-func runGrpcServer(grpcPort int, useKvm bool, store gokv.Store, spdkAddress, qmpAddress, ctrlrDir, busesStr, tlsFiles string) {
+// commandLineFlags returns names of flags explicitly given on command line
+func commandLineFlags() map[string]bool {
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+	return setFlags
+}
+
+func reloadConfigOnSighup(configPath string, config, flagsConfig utils.Config, setFlags map[string]bool) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	for range sighup {
+		log.Printf("Got SIGHUP, reloading config file %v", configPath)
+		next, err := utils.LoadConfig(configPath)
+		if err != nil {
+			log.Printf("error: failed to reload config: %v", err)
+			continue
+		}
+		next = utils.MergeConfig(next, flagsConfig, setFlags)
+		if _, err := utils.ReloadConfig(&config, next); err != nil {
+			log.Printf("error: failed to apply reloaded config: %v", err)
+		}
+	}
+}
+
+// grpcServerOptions are settings of gRPC server and servers registered on it
+type grpcServerOptions struct {
+	grpcPort        int
+	msgSizeOptions  []grpc.ServerOption
+	tlsFiles        string
+	interceptors    []string
+	tenantQuotas    map[string]int
+	enableChannelz  bool
+	adminIdentities string
+	importState     string
+
+	spdkAddress      string
+	spdkWaitTimeout  time.Duration
+	spdkTimeout      time.Duration
+	spdkRetries      int
+	spdkRetryBackoff time.Duration
+	spdkIDMismatch   string
+
+	useKvm             bool
+	qmpAddress         string
+	qmpDialTimeout     time.Duration
+	qmpConnectRetries  int
+	qmpConnectBackoff  time.Duration
+	ctrlrDir           string
+	virtioBlkTransport string
+	buses              []string
+
+	blockSizes      backend.BlockSizes
+	defaultQos      backend.QosProfile
+	ttlReapInterval time.Duration
+	annotationKeys  []string
+	hostID          string
+
+	healthCheckInterval    time.Duration
+	healthFailureThreshold int
+	healthSuccessThreshold int
+
+	autoPause     bool
+	statsInterval time.Duration
+	emptyStats    string
+	nqnBase       string
+}
+
+func runGrpcServer(store gokv.Store, metrics *utils.Metrics, opts grpcServerOptions) {
 	tp := utils.InitTracerProvider("opi-spdk-bridge")
 	defer func() {
 		if err := tp.Shutdown(context.Background()); err != nil {
@@ -101,19 +452,17 @@ func runGrpcServer(grpcPort int, useKvm bool, store gokv.Store, spdkAddress, qmp
 		}
 	}()
 
-	buses := splitBusesBySeparator(busesStr)
-
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", opts.grpcPort))
 	if err != nil {
 		log.Panicf("failed to listen: %v", err)
 	}
 
-	var serverOptions []grpc.ServerOption
-	if tlsFiles == "" {
+	serverOptions := append([]grpc.ServerOption{}, opts.msgSizeOptions...)
+	if opts.tlsFiles == "" {
 		log.Println("TLS files are not specified. Use insecure connection.")
 	} else {
-		log.Println("Use TLS certificate files:", tlsFiles)
-		config, err := utils.ParseTLSFiles(tlsFiles)
+		log.Println("Use TLS certificate files:", opts.tlsFiles)
+		config, err := utils.ParseTLSFiles(opts.tlsFiles)
 		if err != nil {
 			log.Panic("Failed to parse string with tls paths:", err)
 		}
@@ -124,47 +473,151 @@ func runGrpcServer(grpcPort int, useKvm bool, store gokv.Store, spdkAddress, qmp
 		}
 		serverOptions = append(serverOptions, option)
 	}
+	availableInterceptors := map[string]grpc.UnaryServerInterceptor{
+		utils.MetricsInterceptor: nil,
+		utils.LoggingInterceptor: logging.UnaryServerInterceptor(utils.InterceptorLogger(log.Default()),
+			logging.WithLogOnEvents(
+				logging.StartCall,
+				logging.FinishCall,
+				logging.PayloadReceived,
+				logging.PayloadSent,
+			),
+		),
+		utils.SpdkCallsInterceptor:   utils.SpdkCallsUnaryServerInterceptor,
+		utils.AdminInterceptor:       nil,
+		utils.TenantQuotaInterceptor: nil,
+		utils.ReadMaskInterceptor:    utils.ReadMaskUnaryServerInterceptor,
+		utils.VerbosityInterceptor:   utils.ResponseVerbosityUnaryServerInterceptor,
+	}
+	if metrics != nil {
+		availableInterceptors[utils.MetricsInterceptor] = metrics.UnaryServerInterceptor
+	}
+	var quotas *utils.TenantQuotas
+	if len(opts.tenantQuotas) > 0 {
+		if quotas, err = utils.NewTenantQuotas(opts.tenantQuotas); err != nil {
+			log.Panicf("invalid tenant_quotas: %v", err)
+		}
+		availableInterceptors[utils.TenantQuotaInterceptor] = quotas.UnaryServerInterceptor
+	}
+	if opts.tlsFiles != "" {
+		admins := strings.Split(opts.adminIdentities, ",")
+		prefixes := []string{utils.StateServicePrefix}
+		if opts.enableChannelz {
+			prefixes = append(prefixes, utils.ChannelzServicePrefix)
+		}
+		availableInterceptors[utils.AdminInterceptor] = utils.NewAdminUnaryServerInterceptor(admins, prefixes...)
+	}
+	chain, err := utils.BuildUnaryInterceptorChain(opts.interceptors, availableInterceptors)
+	if err != nil {
+		log.Panic(err)
+	}
+	log.Println("Enabled gRPC interceptors:", opts.interceptors)
 	serverOptions = append(serverOptions,
 		grpc.StatsHandler(otelgrpc.NewServerHandler()),
-		grpc.UnaryInterceptor(
-			logging.UnaryServerInterceptor(utils.InterceptorLogger(log.Default()),
-				logging.WithLogOnEvents(
-					logging.StartCall,
-					logging.FinishCall,
-					logging.PayloadReceived,
-					logging.PayloadSent,
-				),
-			)),
+		grpc.ChainUnaryInterceptor(chain...),
 	)
 	s := grpc.NewServer(serverOptions...)
 
-	jsonRPC := spdk.NewClient(spdkAddress)
-	backendServer := backend.NewServer(jsonRPC, store)
+	spdkClient, err := utils.NewIDMismatchHandlingJSONRPC(func() spdk.JSONRPC {
+		return utils.NewSpdkClient(opts.spdkAddress)
+	}, opts.spdkIDMismatch)
+	if err != nil {
+		log.Panic(err)
+	}
+	spdkClient, err = utils.NewRetryingJSONRPC(spdkClient, opts.spdkRetries, opts.spdkRetryBackoff)
+	if err != nil {
+		log.Panic(err)
+	}
+	jsonRPC := utils.NewSpdkCallRecordingJSONRPC(utils.NewTimeoutJSONRPC(spdkClient, opts.spdkTimeout))
+	if metrics != nil {
+		jsonRPC = utils.NewMetricsJSONRPC(jsonRPC, metrics)
+	}
+	if err := utils.WaitForSpdk(context.Background(), jsonRPC, opts.spdkAddress, opts.spdkWaitTimeout); err != nil {
+		log.Panic(err)
+	}
+	backendServer := backend.NewCustomizedServer(jsonRPC, store, opts.blockSizes, opts.defaultQos)
+	if err := backendServer.SetAnnotationKeys(opts.annotationKeys); err != nil {
+		log.Panic(err)
+	}
+	if err := backendServer.SetHostID(opts.hostID); err != nil {
+		log.Panicf("invalid host_id: %v", err)
+	}
+	if opts.statsInterval < 0 {
+		log.Panicf("stats_interval cannot be negative, got %v", opts.statsInterval)
+	}
+	var statsCollector *utils.StatsCollector
+	if opts.statsInterval > 0 {
+		statsCollector = utils.NewStatsCollector(jsonRPC, opts.statsInterval)
+	}
+	backendServer.SetStatsCollector(statsCollector)
+	if opts.ttlReapInterval <= 0 {
+		log.Panicf("ttl_reap_interval must be positive, got %v", opts.ttlReapInterval)
+	}
 	middleendServer := middleend.NewServer(jsonRPC, store)
 
-	if useKvm {
+	healthServer := health.NewServer()
+	// probes go to SPDK directly, so that retries do not delay reporting it down
+	healthRPC := utils.NewTimeoutJSONRPC(utils.NewSpdkClient(opts.spdkAddress), 0)
+	healthChecker, err := utils.NewSpdkHealthChecker(healthRPC, healthServer, opts.healthFailureThreshold, opts.healthSuccessThreshold)
+	if err != nil {
+		log.Panic(err)
+	}
+	healthChecker.SetSpdkAddress(opts.spdkAddress)
+	healthChecker.SetStore(store)
+	if opts.healthCheckInterval <= 0 {
+		log.Panicf("health_check_interval must be positive, got %v", opts.healthCheckInterval)
+	}
+	go healthChecker.Run(context.Background(), opts.healthCheckInterval)
+
+	var frontendServer *frontend.Server
+	var nvmeServer pb.FrontendNvmeServiceServer
+	if opts.useKvm {
 		log.Println("Creating KVM server.")
-		frontendServer := frontend.NewCustomizedServer(jsonRPC,
+		if _, err := utils.ResolveFilePath(opts.ctrlrDir); err != nil {
+			log.Panicf("invalid ctrlr_dir: %v", err)
+		}
+		frontendServer = frontend.NewCustomizedServer(jsonRPC,
 			store,
 			map[pb.NvmeTransportType]frontend.NvmeTransport{
 				pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP:  frontend.NewNvmeTCPTransport(jsonRPC),
-				pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE: kvm.NewNvmeVfiouserTransport(ctrlrDir, jsonRPC),
+				pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE: kvm.NewNvmeVfiouserTransport(opts.ctrlrDir, jsonRPC),
 			},
 			frontend.NewVhostUserBlkTransport(),
 		)
-		kvmServer := kvm.NewServer(frontendServer, qmpAddress, ctrlrDir, buses)
+		frontendServer.AutoPause = opts.autoPause
+		if err := frontendServer.SetEmptyStats(opts.emptyStats); err != nil {
+			log.Panicf("invalid empty_stats: %v", err)
+		}
+		frontendServer.SetStatsCollector(statsCollector)
+		if err := frontendServer.SetNqnBase(opts.nqnBase); err != nil {
+			log.Panicf("invalid nqn_base: %v", err)
+		}
+		kvmServer := kvm.NewServer(frontendServer, store, opts.qmpAddress, opts.ctrlrDir, opts.buses)
+		if err := kvmServer.SetQmpConnectPolicy(opts.qmpDialTimeout, opts.qmpConnectRetries, opts.qmpConnectBackoff); err != nil {
+			log.Panic(err)
+		}
 
+		nvmeServer = kvmServer
 		pb.RegisterFrontendNvmeServiceServer(s, kvmServer)
 		pb.RegisterFrontendVirtioBlkServiceServer(s, kvmServer)
 		pb.RegisterFrontendVirtioScsiServiceServer(s, kvmServer)
 	} else {
-		frontendServer := frontend.NewCustomizedServer(jsonRPC,
+		frontendServer = frontend.NewCustomizedServer(jsonRPC,
 			store,
 			map[pb.NvmeTransportType]frontend.NvmeTransport{
 				pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP: frontend.NewNvmeTCPTransport(jsonRPC),
 			},
-			frontend.NewVhostUserBlkTransport(),
+			newVirtioBlkTransport(opts.virtioBlkTransport, opts.ctrlrDir, jsonRPC),
 		)
+		frontendServer.AutoPause = opts.autoPause
+		if err := frontendServer.SetEmptyStats(opts.emptyStats); err != nil {
+			log.Panicf("invalid empty_stats: %v", err)
+		}
+		frontendServer.SetStatsCollector(statsCollector)
+		if err := frontendServer.SetNqnBase(opts.nqnBase); err != nil {
+			log.Panicf("invalid nqn_base: %v", err)
+		}
+		nvmeServer = frontendServer
 		pb.RegisterFrontendNvmeServiceServer(s, frontendServer)
 		pb.RegisterFrontendVirtioBlkServiceServer(s, frontendServer)
 		pb.RegisterFrontendVirtioScsiServiceServer(s, frontendServer)
@@ -174,10 +627,54 @@ func runGrpcServer(grpcPort int, useKvm bool, store gokv.Store, spdkAddress, qmp
 	pb.RegisterNullVolumeServiceServer(s, backendServer)
 	pb.RegisterMallocVolumeServiceServer(s, backendServer)
 	pb.RegisterAioVolumeServiceServer(s, backendServer)
+	pc.RegisterInventoryServiceServer(s, backendServer)
 	pb.RegisterMiddleendEncryptionServiceServer(s, middleendServer)
 	pb.RegisterMiddleendQosVolumeServiceServer(s, middleendServer)
+	middleend.RegisterCompositeVolumeServer(s, middleendServer)
+	transactionServer := transaction.NewServer(backendServer, nvmeServer)
+	if quotas != nil {
+		backendServer.SetResourceReleaser(quotas)
+		frontendServer.SetResourceReleaser(quotas)
+		transactionServer.SetResourceReleaser(quotas)
+		transactionServer.SetTenantQuotas(quotas)
+	}
+	transaction.RegisterServer(s, transactionServer)
+	utils.RegisterMemoryStatsServer(s, utils.NewMemoryStatsServer(jsonRPC, utils.DefaultMemoryStatsCacheTTL))
+	utils.RegisterSubsystemsServer(s, utils.NewSubsystemsServer(jsonRPC))
+	utils.RegisterReactorStatsServer(s, utils.NewReactorStatsServer(jsonRPC))
+	frontend.RegisterNvmeNamespacePlacementServer(s, frontendServer)
+	frontend.RegisterNvmeNamespaceReservationServer(s, frontendServer)
+	frontend.RegisterNvmeSubsystemListenersServer(s, frontendServer)
+	frontend.RegisterNvmeNamespaceBatchServer(s, frontendServer)
+	frontend.RegisterNvmeNamespaceAttachmentServer(s, frontendServer)
+	backend.RegisterStateDriftServer(s, backendServer)
+	backend.RegisterVolumeLatencyHistogramServer(s, backendServer)
+	backend.RegisterPassthruVolumeServer(s, backendServer)
+	utils.RegisterIdentityServer(s, utils.NewIdentityServer(strings.Split(opts.adminIdentities, ",")))
+	utils.RegisterResourceCountsServer(s, utils.NewResourceCountsServer(backendServer, frontendServer, middleendServer))
+	stateServer := utils.NewStateServer(backendServer, frontendServer, middleendServer)
+	if err := utils.CheckAdminRestricted(opts.tlsFiles, strings.Split(opts.adminIdentities, ","), opts.interceptors); err != nil {
+		log.Printf("State service is not registered, exported state carries keys: %v", err)
+	} else {
+		utils.RegisterStateServer(s, stateServer)
+	}
+
+	healthpb.RegisterHealthServer(s, healthServer)
 
 	reflection.Register(s)
+	utils.RegisterChannelz(s, opts.enableChannelz)
+
+	if opts.importState != "" {
+		if err := stateServer.ImportStateFile(opts.importState); err != nil {
+			log.Panicf("failed to import state: %v", err)
+		}
+		log.Println("Imported state from", opts.importState)
+	}
+	backendServer.RestoreExpiries()
+	if metrics != nil {
+		metrics.RegisterReapedVolumes(backendServer.ReapedVolumes)
+	}
+	go backendServer.RunVolumeReaper(context.Background(), opts.ttlReapInterval)
 
 	log.Printf("gRPC server listening at %v", lis.Addr())
 	if err := s.Serve(lis); err != nil {
@@ -185,7 +682,22 @@ func runGrpcServer(grpcPort int, useKvm bool, store gokv.Store, spdkAddress, qmp
 	}
 }
 
-func runGatewayServer(grpcPort int, httpPort int) {
+func newVirtioBlkTransport(transport, ctrlrDir string, jsonRPC spdk.JSONRPC) frontend.VirtioBlkTransport {
+	switch transport {
+	case "vhost-user":
+		return frontend.NewVhostUserBlkTransport()
+	case "vfio-user":
+		if _, err := utils.ResolveFilePath(ctrlrDir); err != nil {
+			log.Panicf("invalid ctrlr_dir: %v", err)
+		}
+		return frontend.NewVirtioBlkVfioUserTransport(ctrlrDir, jsonRPC)
+	default:
+		log.Panicf("invalid virtio_blk_transport %q, supported are %v", transport, []string{"vfio-user", "vhost-user"})
+		return nil
+	}
+}
+
+func runGatewayServer(grpcPort int, httpPort int, metricsPort int, metricsPath string, metrics *utils.Metrics, msgSizeOptions []grpc.DialOption) {
 	ctx := context.Background()
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -194,7 +706,7 @@ func runGatewayServer(grpcPort int, httpPort int) {
 	// Note: Make sure the gRPC server is running properly and accessible
 	mux := runtime.NewServeMux()
 
-	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	opts := append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, msgSizeOptions...)
 	endpoint := fmt.Sprintf("localhost:%d", grpcPort)
 	registerGatewayHandler(ctx, mux, endpoint, opts, pc.RegisterInventoryServiceHandlerFromEndpoint, "inventory")
 
@@ -210,11 +722,20 @@ func runGatewayServer(grpcPort int, httpPort int) {
 	registerGatewayHandler(ctx, mux, endpoint, opts, pb.RegisterFrontendVirtioScsiServiceHandlerFromEndpoint, "frontend virtio-scsi")
 	registerGatewayHandler(ctx, mux, endpoint, opts, pb.RegisterFrontendNvmeServiceHandlerFromEndpoint, "frontend nvme")
 
+	var handler http.Handler = mux
+	if metrics != nil && (metricsPort == 0 || metricsPort == httpPort) {
+		httpMux := http.NewServeMux()
+		httpMux.Handle(metricsPath, metrics.Handler())
+		httpMux.Handle("/", mux)
+		handler = httpMux
+		log.Printf("Serving metrics at %v", metricsPath)
+	}
+
 	// Start HTTP server (and proxy calls to gRPC server endpoint)
 	log.Printf("HTTP Server listening at %v", httpPort)
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", httpPort),
-		Handler:      mux,
+		Handler:      handler,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
@@ -225,6 +746,24 @@ func runGatewayServer(grpcPort int, httpPort int) {
 	}
 }
 
+func runMetricsServer(metricsPort int, metricsPath string, metrics *utils.Metrics) {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, metrics.Handler())
+
+	log.Printf("Metrics server listening at %v, serving %v", metricsPort, metricsPath)
+	server := &http.Server{
+		Addr:         fmt.Sprintf(":%d", metricsPort),
+		Handler:      mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	err := server.ListenAndServe()
+	if err != nil {
+		log.Panic("cannot start metrics server")
+	}
+}
+
 type registerHandlerFunc func(context.Context, *runtime.ServeMux, string, []grpc.DialOption) error
 
 func registerGatewayHandler(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption, registerFunc registerHandlerFunc, serviceName string) {
@@ -9,7 +9,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"path"
 	"sort"
 
 	"github.com/opiproject/gospdk/spdk"
@@ -19,7 +18,6 @@ import (
 	"github.com/google/uuid"
 	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/fieldmask"
-	"go.einride.tech/aip/resourceid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -31,33 +29,96 @@ func sortAioVolumes(volumes []*pb.AioVolume) {
 	})
 }
 
+// bdevAioCreateParams extends SPDK create parameters with UUID and NUMA
+// placement hint
+type bdevAioCreateParams struct {
+	spdk.BdevAioCreateParams
+	UUID     string `json:"uuid,omitempty"`
+	NumaID   *int32 `json:"numa_id,omitempty"`
+	Readonly bool   `json:"readonly,omitempty"`
+}
+
 // CreateAioVolume creates an Aio volume
 func (s *Server) CreateAioVolume(ctx context.Context, in *pb.CreateAioVolumeRequest) (*pb.AioVolume, error) {
+	// 0 or omitted block size means use configured default
+	if in.AioVolume != nil {
+		applyDefaultBlockSize(&in.AioVolume.BlockSize, s.blockSizes.Aio)
+	}
 	// check input correctness
 	if err := s.validateCreateAioVolumeRequest(in); err != nil {
 		return nil, err
 	}
+	numaNode, err := s.numaNodeFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	qosProfile, err := s.qosProfileFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := ttlFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	annotations, err := s.annotationsFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	readonly, err := aioReadonlyFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	// see https://google.aip.dev/133#user-specified-ids
-	resourceID := resourceid.NewSystemGenerated()
+	resourceID, err := s.idempotencyKeys.ResourceID(ctx, "AioVolume", in.AioVolumeId)
+	if err != nil {
+		return nil, err
+	}
 	if in.AioVolumeId != "" {
 		log.Printf("client provided the ID of a resource %v, ignoring the name field %v", in.AioVolumeId, in.AioVolume.Name)
-		resourceID = in.AioVolumeId
 	}
 	in.AioVolume.Name = utils.ResourceIDToVolumeName(resourceID)
+	unlock := s.resourceLocks.Lock(in.AioVolume.Name)
+	defer unlock()
 	// idempotent API when called with same key, should return same object
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.AioVolumes[in.AioVolume.Name]
+	existingQosProfile, existingReadonly := s.qosProfiles[in.AioVolume.Name], s.aioReadonly[in.AioVolume.Name]
+	s.mapsMu.RUnlock()
 	if ok {
 		log.Printf("Already existing AioVolume with id %v", in.AioVolume.Name)
+		sendQosProfile(ctx, existingQosProfile)
+		sendAnnotations(ctx, s.Annotations(resourceID))
+		sendAioReadonly(ctx, existingReadonly)
 		return volume, nil
 	}
+	// generate UUID if omitted, so that the stored volume records it
+	in.AioVolume.Uuid, err = utils.EnsureUUID(in.AioVolume.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeUUIDFree(ctx, in.AioVolume.Uuid); err != nil {
+		return nil, err
+	}
 	// not found, so create a new one
-	params := spdk.BdevAioCreateParams{
-		Name:      resourceID,
-		BlockSize: 512,
-		Filename:  in.AioVolume.Filename,
+	filename, err := utils.ResolveFilePath(in.AioVolume.Filename)
+	if err != nil {
+		return nil, err
+	}
+	params := bdevAioCreateParams{
+		BdevAioCreateParams: spdk.BdevAioCreateParams{
+			Name:      resourceID,
+			BlockSize: int(in.GetAioVolume().GetBlockSize()),
+			Filename:  filename,
+		},
+		UUID:     in.AioVolume.Uuid,
+		NumaID:   numaNode,
+		Readonly: readonly,
+	}
+	if utils.SkipSpdkCallInDryRun(ctx, "bdev_aio_create", &params) {
+		return utils.ProtoClone(in.AioVolume), nil
 	}
 	var result spdk.BdevAioCreateResult
-	err := s.rpc.Call(ctx, "bdev_aio_create", &params, &result)
+	err = s.rpc.Call(ctx, "bdev_aio_create", &params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -66,8 +127,25 @@ func (s *Server) CreateAioVolume(ctx context.Context, in *pb.CreateAioVolumeRequ
 		msg := fmt.Sprintf("Could not create Aio Dev: %s", params.Name)
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
+	if err := s.applyQosProfile(ctx, resourceID, qosProfile); err != nil {
+		s.rollbackBdevCreate(ctx, "bdev_aio_delete", resourceID)
+		return nil, err
+	}
 	response := utils.ProtoClone(in.AioVolume)
+	s.mapsMu.Lock()
 	s.Volumes.AioVolumes[in.AioVolume.Name] = response
+	if qosProfile != nil {
+		s.qosProfiles[in.AioVolume.Name] = qosProfile
+	}
+	if readonly {
+		s.aioReadonly[in.AioVolume.Name] = true
+	}
+	s.mapsMu.Unlock()
+	s.setExpiry(in.AioVolume.Name, ttl)
+	sendQosProfile(ctx, qosProfile)
+	s.setAnnotations(resourceID, annotations)
+	sendAnnotations(ctx, annotations)
+	sendAioReadonly(ctx, readonly)
 	return response, nil
 }
 
@@ -77,8 +155,12 @@ func (s *Server) DeleteAioVolume(ctx context.Context, in *pb.DeleteAioVolumeRequ
 	if err := s.validateDeleteAioVolumeRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.AioVolumes[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			return &emptypb.Empty{}, nil
@@ -86,10 +168,13 @@ func (s *Server) DeleteAioVolume(ctx context.Context, in *pb.DeleteAioVolumeRequ
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	params := spdk.BdevAioDeleteParams{
 		Name: resourceID,
 	}
+	if utils.SkipSpdkCallInDryRun(ctx, "bdev_aio_delete", &params) {
+		return &emptypb.Empty{}, nil
+	}
 	var result spdk.BdevAioDeleteResult
 	err := s.rpc.Call(ctx, "bdev_aio_delete", &params, &result)
 	if err != nil {
@@ -100,7 +185,14 @@ func (s *Server) DeleteAioVolume(ctx context.Context, in *pb.DeleteAioVolumeRequ
 		msg := fmt.Sprintf("Could not delete Aio Dev: %s", params.Name)
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
+	s.mapsMu.Lock()
 	delete(s.Volumes.AioVolumes, volume.Name)
+	delete(s.aioReadonly, volume.Name)
+	delete(s.qosProfiles, volume.Name)
+	s.mapsMu.Unlock()
+	s.clearExpiry(volume.Name)
+	s.clearAnnotations(resourceID)
+	s.clearHistogram(resourceID)
 	return &emptypb.Empty{}, nil
 }
 
@@ -110,18 +202,26 @@ func (s *Server) UpdateAioVolume(ctx context.Context, in *pb.UpdateAioVolumeRequ
 	if err := s.validateUpdateAioVolumeRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.AioVolume.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.AioVolumes[in.AioVolume.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			log.Printf("Got AllowMissing, create a new resource, don't return error when resource not found")
+			filename, err := utils.ResolveFilePath(in.AioVolume.Filename)
+			if err != nil {
+				return nil, err
+			}
 			params := spdk.BdevAioCreateParams{
-				Name:      path.Base(in.AioVolume.Name),
+				Name:      utils.ResourceNameToID(in.AioVolume.Name),
 				BlockSize: 512,
-				Filename:  in.AioVolume.Filename,
+				Filename:  filename,
 			}
 			var result spdk.BdevAioCreateResult
-			err := s.rpc.Call(ctx, "bdev_aio_create", &params, &result)
+			err = s.rpc.Call(ctx, "bdev_aio_create", &params, &result)
 			if err != nil {
 				return nil, err
 			}
@@ -131,17 +231,26 @@ func (s *Server) UpdateAioVolume(ctx context.Context, in *pb.UpdateAioVolumeRequ
 				return nil, status.Errorf(codes.InvalidArgument, msg)
 			}
 			response := utils.ProtoClone(in.AioVolume)
+			s.mapsMu.Lock()
 			s.Volumes.AioVolumes[in.AioVolume.Name] = response
+			s.mapsMu.Unlock()
 			return response, nil
 		}
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.AioVolume.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	// update_mask = 2
 	if err := fieldmask.Validate(in.UpdateMask, in.AioVolume); err != nil {
 		return nil, err
 	}
+	if isAioResize(in.UpdateMask) {
+		return s.resizeAioVolume(ctx, volume, in.AioVolume.BlocksCount)
+	}
+	filename, err := utils.ResolveFilePath(in.AioVolume.Filename)
+	if err != nil {
+		return nil, err
+	}
 	params1 := spdk.BdevAioDeleteParams{
 		Name: resourceID,
 	}
@@ -158,7 +267,7 @@ func (s *Server) UpdateAioVolume(ctx context.Context, in *pb.UpdateAioVolumeRequ
 	params2 := spdk.BdevAioCreateParams{
 		Name:      resourceID,
 		BlockSize: 512,
-		Filename:  in.AioVolume.Filename,
+		Filename:  filename,
 	}
 	var result2 spdk.BdevAioCreateResult
 	err2 := s.rpc.Call(ctx, "bdev_aio_create", &params2, &result2)
@@ -170,8 +279,14 @@ func (s *Server) UpdateAioVolume(ctx context.Context, in *pb.UpdateAioVolumeRequ
 		msg := fmt.Sprintf("Could not create Aio Dev: %s", params2.Name)
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
+	// recreated bdev has no limits, so restore the ones applied on create
+	if err := s.applyQosProfile(ctx, resourceID, s.appliedQosProfile(volume.Name)); err != nil {
+		return nil, err
+	}
 	response := utils.ProtoClone(in.AioVolume)
+	s.mapsMu.Lock()
 	s.Volumes.AioVolumes[in.AioVolume.Name] = response
+	s.mapsMu.Unlock()
 	return response, nil
 }
 
@@ -197,13 +312,14 @@ func (s *Server) ListAioVolumes(ctx context.Context, in *pb.ListAioVolumesReques
 	result, hasMoreElements := utils.LimitPagination(result, offset, size)
 	if hasMoreElements {
 		token = uuid.New().String()
-		s.Pagination[token] = offset + size
+		s.Pagination.Set(token, offset+size)
 	}
 	Blobarray := make([]*pb.AioVolume, len(result))
 	for i := range result {
 		r := &result[i]
 		Blobarray[i] = &pb.AioVolume{Name: r.Name, BlockSize: r.BlockSize, BlocksCount: r.NumBlocks}
 	}
+	Blobarray, token = utils.LimitPaginationBySize(Blobarray, offset, token, s.Pagination)
 	sortAioVolumes(Blobarray)
 	return &pb.ListAioVolumesResponse{AioVolumes: Blobarray, NextPageToken: token}, nil
 }
@@ -215,16 +331,18 @@ func (s *Server) GetAioVolume(ctx context.Context, in *pb.GetAioVolumeRequest) (
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.AioVolumes[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	params := spdk.BdevGetBdevsParams{
 		Name: resourceID,
 	}
-	var result []spdk.BdevGetBdevsResult
+	var result []bdevGetBdevsResult
 	err := s.rpc.Call(ctx, "bdev_get_bdevs", &params, &result)
 	if err != nil {
 		return nil, err
@@ -234,6 +352,9 @@ func (s *Server) GetAioVolume(ctx context.Context, in *pb.GetAioVolumeRequest) (
 		msg := fmt.Sprintf("expecting exactly 1 result, got %d", len(result))
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
+	sendSupportedIoTypes(ctx, result[0].SupportedIoTypes)
+	sendQosProfile(ctx, s.appliedQosProfile(volume.Name))
+	sendAnnotations(ctx, s.Annotations(resourceID))
 	return &pb.AioVolume{Name: result[0].Name, BlockSize: result[0].BlockSize, BlocksCount: result[0].NumBlocks}, nil
 }
 
@@ -244,12 +365,14 @@ func (s *Server) StatsAioVolume(ctx context.Context, in *pb.StatsAioVolumeReques
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.AioVolumes[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	params := spdk.BdevGetIostatParams{
 		Name: resourceID,
 	}
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AioReadonlyMetadataKey is metadata key which, when set to "true", creates
+// Aio volume opened read only, since AioVolume has no such field. Set by
+// client on create and returned by server in header
+const AioReadonlyMetadataKey = "opi-aio-readonly"
+
+// aioReadonlyFromContext returns whether client requested read only Aio
+// volume
+func aioReadonlyFromContext(ctx context.Context) (bool, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(AioReadonlyMetadataKey)
+	if len(values) == 0 {
+		return false, nil
+	}
+	readonly, err := strconv.ParseBool(values[0])
+	if err != nil {
+		msg := fmt.Sprintf("invalid readonly flag %q", values[0])
+		return false, status.Errorf(codes.InvalidArgument, msg)
+	}
+	return readonly, nil
+}
+
+func sendAioReadonly(ctx context.Context, readonly bool) {
+	if !readonly {
+		return
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(AioReadonlyMetadataKey, strconv.FormatBool(readonly))); err != nil {
+		log.Printf("error: failed to send readonly flag: %v", err)
+	}
+}
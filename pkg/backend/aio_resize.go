@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/opiproject/gospdk/spdk"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// aioBlocksCountPath is update mask path requesting resize of Aio volume
+const aioBlocksCountPath = "blocks_count"
+
+// bdevAioRescanParams is parameters of bdev_aio_rescan, not provided by
+// gospdk
+type bdevAioRescanParams struct {
+	Name string `json:"name"`
+}
+
+// isAioResize reports whether update changes only size of Aio volume, which
+// is done by rescan of the grown backing file instead of recreating bdev
+func isAioResize(mask *fieldmaskpb.FieldMask) bool {
+	return len(mask.GetPaths()) == 1 && mask.GetPaths()[0] == aioBlocksCountPath
+}
+
+// resizeAioVolume makes SPDK pick up size of grown backing file of volume
+// and records the new blocks count. Shrinking is rejected, since data
+// beyond the new size would be lost
+func (s *Server) resizeAioVolume(ctx context.Context, volume *pb.AioVolume, blocksCount int64) (*pb.AioVolume, error) {
+	if blocksCount < volume.BlocksCount {
+		msg := fmt.Sprintf("cannot shrink Aio volume %s from %d to %d blocks", volume.Name, volume.BlocksCount, blocksCount)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if blocksCount == volume.BlocksCount {
+		return utils.ProtoClone(volume), nil
+	}
+	resourceID := utils.ResourceNameToID(volume.Name)
+	params := bdevAioRescanParams{
+		Name: resourceID,
+	}
+	if utils.SkipSpdkCallInDryRun(ctx, "bdev_aio_rescan", &params) {
+		response := utils.ProtoClone(volume)
+		response.BlocksCount = blocksCount
+		return response, nil
+	}
+	var result bool
+	err := s.rpc.Call(ctx, "bdev_aio_rescan", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	if !result {
+		msg := fmt.Sprintf("Could not rescan Aio Dev: %s", params.Name)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	bdevParams := spdk.BdevGetBdevsParams{
+		Name: resourceID,
+	}
+	var bdevs []spdk.BdevGetBdevsResult
+	err = s.rpc.Call(ctx, "bdev_get_bdevs", &bdevParams, &bdevs)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v", bdevs)
+	if len(bdevs) != 1 {
+		msg := fmt.Sprintf("expecting exactly 1 result, got %d", len(bdevs))
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	// record size SPDK sees after rescan, even if the file did not grow enough
+	response := utils.ProtoClone(volume)
+	response.BlocksCount = bdevs[0].NumBlocks
+	s.mapsMu.Lock()
+	s.Volumes.AioVolumes[volume.Name] = response
+	s.mapsMu.Unlock()
+	if response.BlocksCount < blocksCount {
+		msg := fmt.Sprintf("backing file of Aio volume %s holds %d blocks, %d requested", volume.Name, response.BlocksCount, blocksCount)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	return utils.ProtoClone(response), nil
+}
@@ -6,17 +6,29 @@
 package backend
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/fieldmaskpb"
 
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 
+	"github.com/philippgille/gokv/gomap"
+
+	"github.com/opiproject/gospdk/spdk"
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
@@ -28,12 +40,14 @@ var (
 		BlockSize:   512,
 		BlocksCount: 12,
 		Filename:    "/tmp/aio_bdev_file",
+		Uuid:        "7e8d9c0b-1a2f-4b3c-8d4e-5f6a7b8c9d0e",
 	}
 	testAioVolumeWithName = pb.AioVolume{
 		Name:        testAioVolumeName,
 		BlockSize:   testAioVolume.BlockSize,
 		BlocksCount: testAioVolume.BlocksCount,
 		Filename:    testAioVolume.Filename,
+		Uuid:        testAioVolume.Uuid,
 	}
 )
 
@@ -111,6 +125,33 @@ func TestBackEnd_CreateAioVolume(t *testing.T) {
 			errMsg:  "",
 			exist:   true,
 		},
+		"omitted block size uses default": {
+			id:      testAioVolumeID,
+			in:      &pb.AioVolume{BlocksCount: 12, Filename: "/tmp/aio_bdev_file", Uuid: testAioVolume.Uuid},
+			out:     &testAioVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"supplied block size overrides default": {
+			id:      testAioVolumeID,
+			in:      &pb.AioVolume{BlockSize: 4096, BlocksCount: 12, Filename: "/tmp/aio_bdev_file", Uuid: testAioVolume.Uuid},
+			out:     &pb.AioVolume{BlockSize: 4096, BlocksCount: 12, Filename: "/tmp/aio_bdev_file", Uuid: testAioVolume.Uuid},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"unsupported block size": {
+			id:      testAioVolumeID,
+			in:      &pb.AioVolume{BlockSize: 1000, BlocksCount: 12, Filename: "/tmp/aio_bdev_file"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("unsupported block size %d, supported sizes are %v", 1000, supportedBlockSizes),
+			exist:   false,
+		},
 		"no required field": {
 			id:      testAioVolumeID,
 			in:      nil,
@@ -157,6 +198,262 @@ func TestBackEnd_CreateAioVolume(t *testing.T) {
 	}
 }
 
+func TestBackEnd_CreateAioVolumeNumaNode(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		numaNode string
+		spdk     []string
+		params   []string
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"no numa node hint": {
+			numaNode: "",
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			params:   []string{`{"name":"mytest","filename":"/tmp/aio_bdev_file","block_size":512,"uuid":"7e8d9c0b-1a2f-4b3c-8d4e-5f6a7b8c9d0e"}`},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"valid numa node hint": {
+			numaNode: "1",
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			params:   []string{`{"name":"mytest","filename":"/tmp/aio_bdev_file","block_size":512,"uuid":"7e8d9c0b-1a2f-4b3c-8d4e-5f6a7b8c9d0e","numa_id":1}`},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"numa node out of range": {
+			numaNode: "2",
+			spdk:     []string{},
+			params:   nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   "numa node 2 is out of range, host has 2 node(s)",
+		},
+		"negative numa node": {
+			numaNode: "-1",
+			spdk:     []string{},
+			params:   nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   "numa node -1 is out of range, host has 2 node(s)",
+		},
+		"not a number numa node": {
+			numaNode: "first",
+			spdk:     []string{},
+			params:   nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("invalid numa node %q", "first"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			testEnv.opiSpdkServer.numaNodeCount = func() int { return 2 }
+			recorder := testEnv.recordSpdkParams()
+
+			ctx := testEnv.ctx
+			if tt.numaNode != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, NumaNodeMetadataKey, tt.numaNode)
+			}
+			request := &pb.CreateAioVolumeRequest{AioVolume: &testAioVolume, AioVolumeId: testAioVolumeID}
+			_, err := testEnv.client.CreateAioVolume(ctx, request)
+
+			if !reflect.DeepEqual(recorder.params, tt.params) {
+				t.Error("spdk params: expected", tt.params, "received", recorder.params)
+			}
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+		})
+	}
+}
+
+func TestBackEnd_CreateAioVolumeReadonly(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		readonly  string
+		blockSize int64
+		spdk      []string
+		params    []string
+		header    []string
+		errCode   codes.Code
+		errMsg    string
+	}{
+		"readonly omitted": {
+			readonly:  "",
+			blockSize: 512,
+			spdk:      []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			params:    []string{`{"name":"mytest","filename":"/tmp/aio_bdev_file","block_size":512,"uuid":"7e8d9c0b-1a2f-4b3c-8d4e-5f6a7b8c9d0e"}`},
+			header:    nil,
+			errCode:   codes.OK,
+			errMsg:    "",
+		},
+		"readonly with block size override": {
+			readonly:  "true",
+			blockSize: 4096,
+			spdk:      []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			params:    []string{`{"name":"mytest","filename":"/tmp/aio_bdev_file","block_size":4096,"uuid":"7e8d9c0b-1a2f-4b3c-8d4e-5f6a7b8c9d0e","readonly":true}`},
+			header:    []string{"true"},
+			errCode:   codes.OK,
+			errMsg:    "",
+		},
+		"readonly disabled": {
+			readonly:  "false",
+			blockSize: 512,
+			spdk:      []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			params:    []string{`{"name":"mytest","filename":"/tmp/aio_bdev_file","block_size":512,"uuid":"7e8d9c0b-1a2f-4b3c-8d4e-5f6a7b8c9d0e"}`},
+			header:    nil,
+			errCode:   codes.OK,
+			errMsg:    "",
+		},
+		"invalid readonly flag": {
+			readonly:  "maybe",
+			blockSize: 512,
+			spdk:      []string{},
+			params:    nil,
+			header:    nil,
+			errCode:   codes.InvalidArgument,
+			errMsg:    fmt.Sprintf("invalid readonly flag %q", "maybe"),
+		},
+		"not power of two block size": {
+			readonly:  "true",
+			blockSize: 520,
+			spdk:      []string{},
+			params:    nil,
+			header:    nil,
+			errCode:   codes.InvalidArgument,
+			errMsg:    "Aio block size 520 must be a power of two multiple of 512",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+
+			ctx := testEnv.ctx
+			if tt.readonly != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, AioReadonlyMetadataKey, tt.readonly)
+			}
+			volume := utils.ProtoClone(&testAioVolume)
+			volume.BlockSize = tt.blockSize
+			var header metadata.MD
+			request := &pb.CreateAioVolumeRequest{AioVolume: volume, AioVolumeId: testAioVolumeID}
+			_, err := testEnv.client.CreateAioVolume(ctx, request, grpc.Header(&header))
+
+			if !reflect.DeepEqual(recorder.params, tt.params) {
+				t.Error("spdk params: expected", tt.params, "received", recorder.params)
+			}
+			if readonly := header.Get(AioReadonlyMetadataKey); !reflect.DeepEqual(readonly, tt.header) {
+				t.Error("readonly header: expected", tt.header, "received", readonly)
+			}
+			if _, ok := testEnv.opiSpdkServer.aioReadonly[testAioVolumeName]; ok != (tt.header != nil) {
+				t.Error("readonly stored: expected", tt.header != nil, "received", ok)
+			}
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+		})
+	}
+}
+
+func TestBackEnd_CreateAioVolumeFileRoot(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.SetFileRoot(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = utils.SetFileRoot("") })
+
+	tests := map[string]struct {
+		filename string
+		spdk     []string
+		params   []string
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"absolute path in root": {
+			filename: filepath.Join(root, "aio_bdev_file"),
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			params:   []string{fmt.Sprintf(`{"name":"mytest","filename":"%v/aio_bdev_file","block_size":512,"uuid":"7e8d9c0b-1a2f-4b3c-8d4e-5f6a7b8c9d0e"}`, root)},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"relative path in root": {
+			filename: "aio_bdev_file",
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			params:   []string{fmt.Sprintf(`{"name":"mytest","filename":"%v/aio_bdev_file","block_size":512,"uuid":"7e8d9c0b-1a2f-4b3c-8d4e-5f6a7b8c9d0e"}`, root)},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"traversal out of root": {
+			filename: root + "/../aio_bdev_file",
+			spdk:     []string{},
+			params:   nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("path %v/../aio_bdev_file is outside of file root %v", root, root),
+		},
+		"relative traversal out of root": {
+			filename: "../aio_bdev_file",
+			spdk:     []string{},
+			params:   nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("path ../aio_bdev_file is outside of file root %v", root),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+
+			volume := utils.ProtoClone(&testAioVolume)
+			volume.Filename = tt.filename
+			request := &pb.CreateAioVolumeRequest{AioVolume: volume, AioVolumeId: testAioVolumeID}
+			_, err := testEnv.client.CreateAioVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(recorder.params, tt.params) {
+				t.Error("spdk params: expected", tt.params, "received", recorder.params)
+			}
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+		})
+	}
+}
+
 func TestBackEnd_UpdateAioVolume(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
 
@@ -332,6 +629,115 @@ func TestBackEnd_UpdateAioVolume(t *testing.T) {
 	}
 }
 
+func TestBackEnd_ResizeAioVolume(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	mask := &fieldmaskpb.FieldMask{Paths: []string{"blocks_count"}}
+	tests := map[string]struct {
+		blocksCount   int64
+		spdk          []string
+		methods       []string
+		storedBlocks  int64
+		expectedBlock int64
+		errCode       codes.Code
+		errMsg        string
+	}{
+		"grown file rescanned": {
+			blocksCount: 24,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"mytest","block_size":512,"num_blocks":24}]}`,
+			},
+			methods:       []string{"bdev_aio_rescan", "bdev_get_bdevs"},
+			storedBlocks:  24,
+			expectedBlock: 24,
+			errCode:       codes.OK,
+			errMsg:        "",
+		},
+		"same size": {
+			blocksCount:   testAioVolume.BlocksCount,
+			spdk:          []string{},
+			methods:       nil,
+			storedBlocks:  testAioVolume.BlocksCount,
+			expectedBlock: testAioVolume.BlocksCount,
+			errCode:       codes.OK,
+			errMsg:        "",
+		},
+		"shrinking rejected": {
+			blocksCount:  6,
+			spdk:         []string{},
+			methods:      nil,
+			storedBlocks: testAioVolume.BlocksCount,
+			errCode:      codes.InvalidArgument,
+			errMsg:       fmt.Sprintf("cannot shrink Aio volume %s from 12 to 6 blocks", testAioVolumeName),
+		},
+		"file not grown enough": {
+			blocksCount: 24,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"mytest","block_size":512,"num_blocks":16}]}`,
+			},
+			methods:      []string{"bdev_aio_rescan", "bdev_get_bdevs"},
+			storedBlocks: 16,
+			errCode:      codes.FailedPrecondition,
+			errMsg:       fmt.Sprintf("backing file of Aio volume %s holds 16 blocks, 24 requested", testAioVolumeName),
+		},
+		"rescan failed": {
+			blocksCount:  24,
+			spdk:         []string{`{"id":%d,"error":{"code":0,"message":""},"result":false}`},
+			methods:      []string{"bdev_aio_rescan"},
+			storedBlocks: testAioVolume.BlocksCount,
+			errCode:      codes.InvalidArgument,
+			errMsg:       fmt.Sprintf("Could not rescan Aio Dev: %s", testAioVolumeID),
+		},
+		"rescan spdk error": {
+			blocksCount:  24,
+			spdk:         []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":false}`},
+			methods:      []string{"bdev_aio_rescan"},
+			storedBlocks: testAioVolume.BlocksCount,
+			errCode:      codes.Unknown,
+			errMsg:       fmt.Sprintf("bdev_aio_rescan: %v", "json response error: myopierr"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+
+			testEnv.opiSpdkServer.Volumes.AioVolumes[testAioVolumeName] = utils.ProtoClone(&testAioVolumeWithName)
+
+			volume := utils.ProtoClone(&testAioVolumeWithName)
+			volume.BlocksCount = tt.blocksCount
+			request := &pb.UpdateAioVolumeRequest{AioVolume: volume, UpdateMask: mask}
+			response, err := testEnv.client.UpdateAioVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(recorder.methods, tt.methods) {
+				t.Error("spdk methods: expected", tt.methods, "received", recorder.methods)
+			}
+			if tt.errCode == codes.OK && response.GetBlocksCount() != tt.expectedBlock {
+				t.Error("blocks count: expected", tt.expectedBlock, "received", response.GetBlocksCount())
+			}
+			stored := testEnv.opiSpdkServer.Volumes.AioVolumes[testAioVolumeName]
+			if stored.BlocksCount != tt.storedBlocks {
+				t.Error("stored blocks count: expected", tt.storedBlocks, "received", stored.BlocksCount)
+			}
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+		})
+	}
+}
+
 func TestBackEnd_ListAioVolumes(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
 	tests := map[string]struct {
@@ -487,7 +893,7 @@ func TestBackEnd_ListAioVolumes(t *testing.T) {
 			testEnv := createTestEnvironment(tt.spdk)
 			defer testEnv.Close()
 
-			testEnv.opiSpdkServer.Pagination["existing-pagination-token"] = 1
+			testEnv.opiSpdkServer.Pagination.Set("existing-pagination-token", 1)
 
 			request := &pb.ListAioVolumesRequest{PageSize: tt.size, PageToken: tt.token}
 			response, err := testEnv.client.ListAioVolumes(testEnv.ctx, request)
@@ -536,7 +942,7 @@ func TestBackEnd_GetAioVolume(t *testing.T) {
 			out:     nil,
 			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":false}`},
 			errCode: codes.Unknown,
-			errMsg:  fmt.Sprintf("bdev_get_bdevs: %v", "json: cannot unmarshal bool into Go value of type []spdk.BdevGetBdevsResult"),
+			errMsg:  fmt.Sprintf("bdev_get_bdevs: %v", "json: cannot unmarshal bool into Go value of type []backend.bdevGetBdevsResult"),
 		},
 		"valid request with empty SPDK response": {
 			in:      testAioVolumeName,
@@ -618,6 +1024,65 @@ func TestBackEnd_GetAioVolume(t *testing.T) {
 	}
 }
 
+func TestBackEnd_GetAioVolumeSupportedIoTypes(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		spdk    []string
+		ioTypes *SupportedIoTypes
+	}{
+		"supported io types reported by SPDK": {
+			spdk: []string{`{"jsonrpc":"2.0","id":%d,"result":[{"name":"Aio0","block_size":512,"num_blocks":131072,"uuid":"88112c76-8c49-4395-955a-0d695b1d2099","supported_io_types":{"read":true,"write":true,"unmap":true,"write_zeroes":true,"flush":true,"reset":true,"compare":false,"compare_and_write":false,"abort":true,"nvme_admin":false,"nvme_io":false}}]}`},
+			ioTypes: &SupportedIoTypes{
+				Read:        true,
+				Write:       true,
+				Unmap:       true,
+				WriteZeroes: true,
+				Flush:       true,
+				Reset:       true,
+				Abort:       true,
+			},
+		},
+		"supported io types omitted by SPDK": {
+			spdk:    []string{`{"jsonrpc":"2.0","id":%d,"result":[{"name":"Aio0","block_size":512,"num_blocks":131072,"uuid":"88112c76-8c49-4395-955a-0d695b1d2099"}]}`},
+			ioTypes: nil,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.Volumes.AioVolumes[testAioVolumeName] = utils.ProtoClone(&testAioVolumeWithName)
+
+			var header metadata.MD
+			request := &pb.GetAioVolumeRequest{Name: testAioVolumeName}
+			_, err := testEnv.client.GetAioVolume(testEnv.ctx, request, grpc.Header(&header))
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+
+			values := header.Get(SupportedIoTypesHeaderKey)
+			if tt.ioTypes == nil {
+				if len(values) != 0 {
+					t.Error("expected no supported io types, received", values)
+				}
+				return
+			}
+			if len(values) != 1 {
+				t.Fatal("expected supported io types in header, received", values)
+			}
+			ioTypes := &SupportedIoTypes{}
+			if err := json.Unmarshal([]byte(values[0]), ioTypes); err != nil {
+				t.Fatal(err)
+			}
+			if *ioTypes != *tt.ioTypes {
+				t.Error("supported io types: expected", tt.ioTypes, "received", ioTypes)
+			}
+		})
+	}
+}
+
 func TestBackEnd_StatsAioVolume(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
 	tests := map[string]struct {
@@ -833,3 +1298,77 @@ func TestBackEnd_DeleteAioVolume(t *testing.T) {
 		})
 	}
 }
+
+func TestBackEnd_ListAioVolumesMaxMsgSize(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	if err := utils.SetListByteBudget(8 << 20); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = utils.SetListByteBudget(utils.DefaultListByteBudget) })
+	// full page of volumes with 20KB names exceeds default 4MB limit
+	bdevs := make([]spdk.BdevGetBdevsResult, 250)
+	for i := range bdevs {
+		bdevs[i] = spdk.BdevGetBdevsResult{Name: fmt.Sprintf("%v-%d", strings.Repeat("a", 20<<10), i), BlockSize: 512, NumBlocks: 64}
+	}
+
+	tests := map[string]struct {
+		maxMsgSize int
+		errCode    codes.Code
+	}{
+		"default limit": {
+			maxMsgSize: utils.DefaultGrpcMaxMsgSize,
+			errCode:    codes.ResourceExhausted,
+		},
+		"raised limit": {
+			maxMsgSize: 8 << 20,
+			errCode:    codes.OK,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			serverOptions, err := utils.GrpcMaxMsgSizeServerOptions(tt.maxMsgSize, tt.maxMsgSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dialOptions, err := utils.GrpcMaxMsgSizeDialOptions(tt.maxMsgSize, tt.maxMsgSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			options := gomap.DefaultOptions
+			options.Codec = utils.ProtoCodec{}
+			opiSpdkServer := NewServer(&stubJSONRRPC{bdevs: bdevs}, gomap.NewStore(options))
+
+			listener := bufconn.Listen(1024 * 1024)
+			server := grpc.NewServer(serverOptions...)
+			pb.RegisterAioVolumeServiceServer(server, opiSpdkServer)
+			go func() { _ = server.Serve(listener) }()
+			defer server.Stop()
+
+			dialOptions = append(dialOptions,
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+					return listener.Dial()
+				}))
+			conn, err := grpc.DialContext(context.Background(), "", dialOptions...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer utils.CloseGrpcConnection(conn)
+
+			request := &pb.ListAioVolumesRequest{PageSize: int32(len(bdevs))}
+			response, err := pb.NewAioVolumeServiceClient(conn).ListAioVolumes(context.Background(), request)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+			if tt.errCode == codes.OK && len(response.GetAioVolumes()) != len(bdevs) {
+				t.Error("volumes: expected", len(bdevs), "received", len(response.GetAioVolumes()))
+			}
+		})
+	}
+}
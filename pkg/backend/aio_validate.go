@@ -5,26 +5,46 @@
 package backend
 
 import (
+	"fmt"
+
 	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/resourceid"
 	"go.einride.tech/aip/resourcename"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-func (s *Server) validateCreateAioVolumeRequest(in *pb.CreateAioVolumeRequest) error {
-	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+// validateAioBlockSize checks block size overriding the one of the backing
+// file is supported, which for Aio means a power of two multiple of 512
+func validateAioBlockSize(blockSize int64) error {
+	if err := validateBlockSize(blockSize); err != nil {
 		return err
 	}
+	if blockSize%512 != 0 || blockSize&(blockSize-1) != 0 {
+		msg := fmt.Sprintf("Aio block size %d must be a power of two multiple of 512", blockSize)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateCreateAioVolumeRequest(in *pb.CreateAioVolumeRequest) error {
+	v := &utils.Validator{}
+	// check required fields
+	v.CheckRequiredFields(in)
 	// see https://google.aip.dev/133#user-specified-ids
 	if in.AioVolumeId != "" {
-		if err := resourceid.ValidateUserSettable(in.AioVolumeId); err != nil {
-			return err
-		}
+		v.Check("aio_volume_id", resourceid.ValidateUserSettable(in.AioVolumeId))
 	}
-	// TODO: validate also: block_size, blocks_count, uuid, filename
-	return nil
+	if in.AioVolume != nil {
+		v.Check("aio_volume.block_size", validateAioBlockSize(in.AioVolume.BlockSize))
+		v.Check("aio_volume.uuid", utils.ValidateUUID(in.AioVolume.Uuid))
+	}
+	// TODO: validate also: blocks_count, filename
+	return v.Err()
 }
 
 func (s *Server) validateDeleteAioVolumeRequest(in *pb.DeleteAioVolumeRequest) error {
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// AnnotationMetadataKeyPrefix prefixes request metadata keys carrying
+// annotations of a new volume, e.g. "opi-annotation-owner". SPDK bdevs have
+// no key/value metadata, so annotations are kept in the store keyed by bdev
+// name where raw SPDK tooling can look them up
+const AnnotationMetadataKeyPrefix = "opi-annotation-"
+
+// AnnotationsHeaderKey is response header key carrying JSON encoded
+// annotations of the volume returned by Create and Get calls
+const AnnotationsHeaderKey = "opi-annotations"
+
+// maxAnnotationValueLength limits size of a single annotation value
+const maxAnnotationValueLength = 256
+
+var annotationKeyRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9_.-]*[a-z0-9])?$`)
+
+// SetAnnotationKeys sets annotation keys accepted on volume create. Empty
+// keys disable annotations
+func (s *Server) SetAnnotationKeys(keys []string) error {
+	allowed := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if !annotationKeyRegexp.MatchString(key) {
+			return fmt.Errorf("invalid annotation key %q, only lowercase alphanumeric characters, '-', '_' and '.' are allowed", key)
+		}
+		if allowed[key] {
+			return fmt.Errorf("duplicate annotation key %q", key)
+		}
+		allowed[key] = true
+	}
+	s.annotationKeys = allowed
+	return nil
+}
+
+// annotationsFromContext returns annotations provided in request metadata
+// or nil if there are none. Keys not set by SetAnnotationKeys are rejected
+func (s *Server) annotationsFromContext(ctx context.Context) (map[string]string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var annotations map[string]string
+	for mdKey, values := range md {
+		if !strings.HasPrefix(mdKey, AnnotationMetadataKeyPrefix) || len(values) == 0 {
+			continue
+		}
+		key := strings.TrimPrefix(mdKey, AnnotationMetadataKeyPrefix)
+		if !s.annotationKeys[key] {
+			msg := fmt.Sprintf("annotation key %q is not allowed", key)
+			return nil, status.Errorf(codes.InvalidArgument, msg)
+		}
+		if len(values[0]) > maxAnnotationValueLength {
+			msg := fmt.Sprintf("annotation %q value exceeds %d characters", key, maxAnnotationValueLength)
+			return nil, status.Errorf(codes.InvalidArgument, msg)
+		}
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[key] = values[0]
+	}
+	return annotations, nil
+}
+
+func annotationsStoreKey(bdevName string) string {
+	return "annotations/" + bdevName
+}
+
+// setAnnotations records annotations of bdev in memory and in the store
+func (s *Server) setAnnotations(bdevName string, annotations map[string]string) {
+	if len(annotations) == 0 {
+		return
+	}
+	s.mapsMu.Lock()
+	s.annotations[bdevName] = annotations
+	s.mapsMu.Unlock()
+	fields := make(map[string]interface{}, len(annotations))
+	for key, value := range annotations {
+		fields[key] = value
+	}
+	value, err := structpb.NewStruct(fields)
+	if err != nil {
+		log.Printf("error: failed to convert annotations of %v: %v", bdevName, err)
+		return
+	}
+	if err := s.store.Set(annotationsStoreKey(bdevName), value); err != nil {
+		log.Printf("error: failed to store annotations of %v: %v", bdevName, err)
+	}
+}
+
+// clearAnnotations forgets annotations of a deleted bdev
+func (s *Server) clearAnnotations(bdevName string) {
+	s.mapsMu.Lock()
+	_, ok := s.annotations[bdevName]
+	delete(s.annotations, bdevName)
+	s.mapsMu.Unlock()
+	if !ok {
+		return
+	}
+	if err := s.store.Delete(annotationsStoreKey(bdevName)); err != nil {
+		log.Printf("error: failed to delete annotations of %v: %v", bdevName, err)
+	}
+}
+
+// Annotations returns annotations of bdev provided on volume create
+func (s *Server) Annotations(bdevName string) map[string]string {
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	return s.annotations[bdevName]
+}
+
+func sendAnnotations(ctx context.Context, annotations map[string]string) {
+	if len(annotations) == 0 {
+		return
+	}
+	data, err := json.Marshal(annotations)
+	if err != nil {
+		log.Printf("error: failed to marshal annotations: %v", err)
+		return
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(AnnotationsHeaderKey, string(data))); err != nil {
+		log.Printf("error: failed to send annotations: %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestBackEnd_SetAnnotationKeys(t *testing.T) {
+	tests := map[string]struct {
+		keys   []string
+		errMsg string
+	}{
+		"no keys": {
+			keys:   []string{},
+			errMsg: "",
+		},
+		"valid keys": {
+			keys:   []string{"owner", "ticket", "cost-center.id"},
+			errMsg: "",
+		},
+		"uppercase key": {
+			keys:   []string{"Owner"},
+			errMsg: fmt.Sprintf("invalid annotation key %q, only lowercase alphanumeric characters, '-', '_' and '.' are allowed", "Owner"),
+		},
+		"empty key": {
+			keys:   []string{""},
+			errMsg: fmt.Sprintf("invalid annotation key %q, only lowercase alphanumeric characters, '-', '_' and '.' are allowed", ""),
+		},
+		"key ending with separator": {
+			keys:   []string{"owner-"},
+			errMsg: fmt.Sprintf("invalid annotation key %q, only lowercase alphanumeric characters, '-', '_' and '.' are allowed", "owner-"),
+		},
+		"duplicate key": {
+			keys:   []string{"owner", "owner"},
+			errMsg: fmt.Sprintf("duplicate annotation key %q", "owner"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			err := testEnv.opiSpdkServer.SetAnnotationKeys(tt.keys)
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tt.errMsg {
+				t.Error("expected error", tt.errMsg, "received", errMsg)
+			}
+		})
+	}
+}
+
+func TestBackEnd_CreateNullVolumeAnnotations(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		annotations map[string]string
+		spdk        []string
+		out         map[string]string
+		errCode     codes.Code
+		errMsg      string
+	}{
+		"no annotations": {
+			annotations: map[string]string{},
+			spdk:        []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			out:         nil,
+			errCode:     codes.OK,
+			errMsg:      "",
+		},
+		"allowed annotations": {
+			annotations: map[string]string{"owner": "team-a", "ticket": "STOR-42"},
+			spdk:        []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			out:         map[string]string{"owner": "team-a", "ticket": "STOR-42"},
+			errCode:     codes.OK,
+			errMsg:      "",
+		},
+		"not allowed annotation key": {
+			annotations: map[string]string{"owner": "team-a", "project": "x"},
+			spdk:        []string{},
+			out:         nil,
+			errCode:     codes.InvalidArgument,
+			errMsg:      fmt.Sprintf("annotation key %q is not allowed", "project"),
+		},
+		"too long annotation value": {
+			annotations: map[string]string{"ticket": strings.Repeat("x", maxAnnotationValueLength+1)},
+			spdk:        []string{},
+			out:         nil,
+			errCode:     codes.InvalidArgument,
+			errMsg:      fmt.Sprintf("annotation %q value exceeds %d characters", "ticket", maxAnnotationValueLength),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			if err := testEnv.opiSpdkServer.SetAnnotationKeys([]string{"owner", "ticket"}); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := testEnv.ctx
+			for key, value := range tt.annotations {
+				ctx = metadata.AppendToOutgoingContext(ctx, AnnotationMetadataKeyPrefix+key, value)
+			}
+			var header metadata.MD
+			request := &pb.CreateNullVolumeRequest{NullVolume: &testNullVolume, NullVolumeId: testNullVolumeID}
+			_, err := testEnv.client.CreateNullVolume(ctx, request, grpc.Header(&header))
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if annotations := testEnv.opiSpdkServer.Annotations(testNullVolumeID); !reflect.DeepEqual(annotations, tt.out) {
+				t.Error("annotations: expected", tt.out, "received", annotations)
+			}
+			stored := &structpb.Struct{}
+			found, err := testEnv.opiSpdkServer.store.Get(annotationsStoreKey(testNullVolumeID), stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found != (tt.out != nil) {
+				t.Error("annotations stored: expected", tt.out != nil, "received", found)
+			}
+			for key, value := range tt.out {
+				if stored.Fields[key].GetStringValue() != value {
+					t.Error("stored annotation", key, "expected", value, "received", stored.Fields[key].GetStringValue())
+				}
+			}
+			values := header.Get(AnnotationsHeaderKey)
+			if (len(values) != 0) != (tt.out != nil) {
+				t.Error("annotations header: expected", tt.out != nil, "received", values)
+			}
+		})
+	}
+}
+
+func TestBackEnd_NullVolumeAnnotationsLifecycle(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`,
+		`{"jsonrpc":"2.0","id":%d,"result":[{"name":"mytest","block_size":512,"num_blocks":64,"uuid":"11d3902e-d9bb-49a7-bb27-cd7261ef3217"}]}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+	})
+	defer testEnv.Close()
+	if err := testEnv.opiSpdkServer.SetAnnotationKeys([]string{"owner"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(testEnv.ctx, AnnotationMetadataKeyPrefix+"owner", "team-a")
+	request := &pb.CreateNullVolumeRequest{NullVolume: &testNullVolume, NullVolumeId: testNullVolumeID}
+	if _, err := testEnv.client.CreateNullVolume(ctx, request); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	var header metadata.MD
+	if _, err := testEnv.client.GetNullVolume(testEnv.ctx, &pb.GetNullVolumeRequest{Name: testNullVolumeName}, grpc.Header(&header)); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if values := header.Get(AnnotationsHeaderKey); len(values) != 1 || values[0] != `{"owner":"team-a"}` {
+		t.Error("annotations header: expected", `{"owner":"team-a"}`, "received", values)
+	}
+
+	if _, err := testEnv.client.DeleteNullVolume(testEnv.ctx, &pb.DeleteNullVolumeRequest{Name: testNullVolumeName}); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if annotations := testEnv.opiSpdkServer.Annotations(testNullVolumeID); annotations != nil {
+		t.Error("expected annotations of deleted volume to be removed, received", annotations)
+	}
+	found, err := testEnv.opiSpdkServer.store.Get(annotationsStoreKey(testNullVolumeID), &structpb.Struct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Error("expected stored annotations of deleted volume to be removed")
+	}
+}
+
+func TestBackEnd_NullVolumeMemoryKvBackend(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`,
+		`{"jsonrpc":"2.0","id":%d,"result":[{"name":"mytest","block_size":512,"num_blocks":64,"uuid":"11d3902e-d9bb-49a7-bb27-cd7261ef3217"}]}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+	})
+	defer testEnv.Close()
+	store, err := utils.NewStore(utils.KvBackendMemory, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEnv.opiSpdkServer.store = store
+	if err := testEnv.opiSpdkServer.SetAnnotationKeys([]string{"owner"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(testEnv.ctx, AnnotationMetadataKeyPrefix+"owner", "team-a")
+	request := &pb.CreateNullVolumeRequest{NullVolume: &testNullVolume, NullVolumeId: testNullVolumeID}
+	if _, err := testEnv.client.CreateNullVolume(ctx, request); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	found, err := store.Get(annotationsStoreKey(testNullVolumeID), &structpb.Struct{})
+	if !found || err != nil {
+		t.Error("expected annotations kept in memory store, received", found, err)
+	}
+
+	response, err := testEnv.client.GetNullVolume(testEnv.ctx, &pb.GetNullVolumeRequest{Name: testNullVolumeName})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if response.GetBlocksCount() != testNullVolume.BlocksCount {
+		t.Error("blocks count: expected", testNullVolume.BlocksCount, "received", response.GetBlocksCount())
+	}
+
+	if _, err := testEnv.client.DeleteNullVolume(testEnv.ctx, &pb.DeleteNullVolumeRequest{Name: testNullVolumeName}); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	found, err = store.Get(annotationsStoreKey(testNullVolumeID), &structpb.Struct{})
+	if found || err != nil {
+		t.Error("expected annotations removed from memory store, received", found, err)
+	}
+}
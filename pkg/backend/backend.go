@@ -6,15 +6,41 @@
 package backend
 
 import (
+	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/philippgille/gokv"
 
 	"github.com/opiproject/gospdk/spdk"
+	pc "github.com/opiproject/opi-api/inventory/v1/gen/go"
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// supportedBlockSizes lists block sizes accepted for BackEnd volumes
+var supportedBlockSizes = []int64{512, 520, 528, 4096, 4104, 4160, 4224}
+
+// BlockSizes contains default block sizes per volume type, applied when
+// a create request omits block_size
+type BlockSizes struct {
+	Null   int64
+	Malloc int64
+	Aio    int64
+}
+
+// DefaultBlockSizes are block sizes used by NewServer
+var DefaultBlockSizes = BlockSizes{
+	Null:   512,
+	Malloc: 512,
+	Aio:    512,
+}
+
 // TODO: can we combine all of volume types into a single list?
 //		 maybe create a volume abstraction like bdev in SPDK?
 
@@ -23,6 +49,8 @@ type VolumeParameters struct {
 	AioVolumes    map[string]*pb.AioVolume
 	NullVolumes   map[string]*pb.NullVolume
 	MallocVolumes map[string]*pb.MallocVolume
+	// PassthruVolumes map passthru volume names to their base bdevs
+	PassthruVolumes map[string]*PassthruVolume
 
 	NvmeControllers map[string]*pb.NvmeRemoteController
 	NvmePaths       map[string]*pb.NvmePath
@@ -34,23 +62,80 @@ type Server struct {
 	pb.UnimplementedNullVolumeServiceServer
 	pb.UnimplementedMallocVolumeServiceServer
 	pb.UnimplementedAioVolumeServiceServer
+	pc.UnimplementedInventoryServiceServer
 
 	rpc                spdk.JSONRPC
 	store              gokv.Store
 	Volumes            VolumeParameters
-	Pagination         map[string]int
+	Pagination         *utils.Pagination
 	keyToTemporaryFile func(pskKey []byte) (string, error)
+	blockSizes         BlockSizes
+	numaNodeCount      func() int
+	pciDevices         func() ([]*pc.PCIeDeviceInfo, error)
+	// nvmeHostIDs maps remote controller names to fabrics host IDs
+	nvmeHostIDs map[string]string
+	// hostID identifies this host, hostnqn of TCP paths created without
+	// one is derived from it
+	hostID string
+	// nvmeKeepAliveTimeouts maps remote controller names to keep-alive
+	// timeouts in milliseconds set on create
+	nvmeKeepAliveTimeouts map[string]int
+	defaultQos            QosProfile
+	// qosProfiles maps volume names to QoS profiles applied on create
+	qosProfiles map[string]*AppliedQosProfile
+	// expiries maps volume names to time they are deleted by the reaper
+	expiries   map[string]time.Time
+	expiriesMu sync.Mutex
+	reaped     atomic.Uint64
+	// annotations maps bdev names to annotations provided on volume create
+	annotations map[string]map[string]string
+	// annotationKeys contains annotation keys accepted on volume create
+	annotationKeys map[string]bool
+	// histograms contains bdev names with latency histogram enabled
+	histograms map[string]bool
+	// aioReadonly contains names of Aio volumes created read only
+	aioReadonly map[string]bool
+	// resourceLocks serializes mutating operations on the same resource
+	resourceLocks *utils.KeyedMutex
+	// mapsMu guards Volumes and the maps keyed by volume name, since
+	// resourceLocks serialize operations on one resource only
+	mapsMu sync.RWMutex
+	// idempotencyKeys resolves IDs of resources created with idempotency key
+	idempotencyKeys *utils.IdempotencyKeys
+	// releaser is notified of volumes deleted by the reaper
+	releaser utils.ResourceReleaser
+	// stats shares stats of all bdevs between stats requests, nil queries
+	// SPDK on every request
+	stats *utils.StatsCollector
 }
 
 // NewServer creates initialized instance of BackEnd server communicating
 // with provided jsonRPC
 func NewServer(jsonRPC spdk.JSONRPC, store gokv.Store) *Server {
+	return NewCustomizedServer(jsonRPC, store, DefaultBlockSizes, QosProfile{})
+}
+
+// NewCustomizedServer creates initialized instance of BackEnd server communicating
+// with provided jsonRPC, store, non standard default block sizes and QoS
+// profile applied to new Null and Aio volumes. Zero profile applies no limits
+func NewCustomizedServer(jsonRPC spdk.JSONRPC, store gokv.Store, blockSizes BlockSizes, defaultQos QosProfile) *Server {
 	if jsonRPC == nil {
 		log.Panic("nil for JSONRPC is not allowed")
 	}
 	if store == nil {
 		log.Panic("nil for Store is not allowed")
 	}
+	for _, size := range []int64{blockSizes.Null, blockSizes.Malloc, blockSizes.Aio} {
+		if err := validateBlockSize(size); err != nil {
+			log.Panicf("invalid default block size: %v", err)
+		}
+	}
+	if err := validateAioBlockSize(blockSizes.Aio); err != nil {
+		log.Panicf("invalid default block size: %v", err)
+	}
+	if err := validateQosProfile(defaultQos); err != nil {
+		log.Panicf("invalid default qos profile: %v", err)
+	}
 	return &Server{
 		rpc:   jsonRPC,
 		store: store,
@@ -58,10 +143,70 @@ func NewServer(jsonRPC spdk.JSONRPC, store gokv.Store) *Server {
 			AioVolumes:      make(map[string]*pb.AioVolume),
 			NullVolumes:     make(map[string]*pb.NullVolume),
 			MallocVolumes:   make(map[string]*pb.MallocVolume),
+			PassthruVolumes: make(map[string]*PassthruVolume),
 			NvmeControllers: make(map[string]*pb.NvmeRemoteController),
 			NvmePaths:       make(map[string]*pb.NvmePath),
 		},
-		Pagination:         make(map[string]int),
-		keyToTemporaryFile: utils.KeyToTemporaryFile,
+		Pagination:            utils.NewPagination(),
+		keyToTemporaryFile:    utils.KeyToTemporaryFile,
+		blockSizes:            blockSizes,
+		numaNodeCount:         utils.NumaNodeCount,
+		pciDevices:            storagePCIeDevices,
+		nvmeHostIDs:           make(map[string]string),
+		hostID:                defaultHostID(),
+		nvmeKeepAliveTimeouts: make(map[string]int),
+		defaultQos:            defaultQos,
+		qosProfiles:           make(map[string]*AppliedQosProfile),
+		expiries:              make(map[string]time.Time),
+		annotations:           make(map[string]map[string]string),
+		annotationKeys:        make(map[string]bool),
+		histograms:            make(map[string]bool),
+		aioReadonly:           make(map[string]bool),
+		resourceLocks:         utils.NewKeyedMutex(),
+		idempotencyKeys:       utils.NewIdempotencyKeys(store),
+	}
+}
+
+// applyDefaultBlockSize replaces omitted (zero) block size by the configured default
+func applyDefaultBlockSize(blockSize *int64, defaultSize int64) {
+	if *blockSize == 0 {
+		*blockSize = defaultSize
+	}
+}
+
+func validateBlockSize(blockSize int64) error {
+	for _, size := range supportedBlockSizes {
+		if blockSize == size {
+			return nil
+		}
+	}
+	msg := fmt.Sprintf("unsupported block size %d, supported sizes are %v", blockSize, supportedBlockSizes)
+	return status.Errorf(codes.InvalidArgument, msg)
+}
+
+// ResourceCounts returns number of BackEnd resources per resource type
+func (s *Server) ResourceCounts() map[string]int {
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	return map[string]int{
+		"aio_volumes":             len(s.Volumes.AioVolumes),
+		"null_volumes":            len(s.Volumes.NullVolumes),
+		"malloc_volumes":          len(s.Volumes.MallocVolumes),
+		"passthru_volumes":        len(s.Volumes.PassthruVolumes),
+		"nvme_remote_controllers": len(s.Volumes.NvmeControllers),
+		"nvme_paths":              len(s.Volumes.NvmePaths),
+	}
+}
+
+// StateResources returns BackEnd resources per resource type for export
+// and import of bridge state
+func (s *Server) StateResources() map[string]utils.StateResource {
+	return map[string]utils.StateResource{
+		"aio_volumes":             utils.ProtoStateResource(&s.mapsMu, s.Volumes.AioVolumes),
+		"null_volumes":            utils.ProtoStateResource(&s.mapsMu, s.Volumes.NullVolumes),
+		"malloc_volumes":          utils.ProtoStateResource(&s.mapsMu, s.Volumes.MallocVolumes),
+		"passthru_volumes":        utils.JSONStateResource(&s.mapsMu, s.Volumes.PassthruVolumes),
+		"nvme_remote_controllers": utils.ProtoStateResource(&s.mapsMu, s.Volumes.NvmeControllers),
+		"nvme_paths":              utils.ProtoStateResource(&s.mapsMu, s.Volumes.NvmePaths),
 	}
 }
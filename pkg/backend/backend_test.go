@@ -7,9 +7,12 @@ package backend
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"net"
 	"os"
+	"reflect"
+	"testing"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -18,6 +21,7 @@ import (
 	"github.com/philippgille/gokv/gomap"
 
 	"github.com/opiproject/gospdk/spdk"
+	pc "github.com/opiproject/opi-api/inventory/v1/gen/go"
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
@@ -42,6 +46,7 @@ type backendClient struct {
 	pb.NullVolumeServiceClient
 	pb.MallocVolumeServiceClient
 	pb.AioVolumeServiceClient
+	pc.InventoryServiceClient
 }
 
 type testEnv struct {
@@ -62,6 +67,30 @@ func (e *testEnv) Close() {
 	utils.CloseGrpcConnection(e.conn)
 }
 
+// spdkParamsRecorder keeps called methods and JSON of params sent to SPDK to
+// verify them in tests
+type spdkParamsRecorder struct {
+	spdk.JSONRPC
+	methods []string
+	params  []string
+}
+
+func (r *spdkParamsRecorder) Call(ctx context.Context, method string, args, result interface{}) error {
+	data, err := json.Marshal(args)
+	if err != nil {
+		log.Panic(err)
+	}
+	r.methods = append(r.methods, method)
+	r.params = append(r.params, string(data))
+	return r.JSONRPC.Call(ctx, method, args, result)
+}
+
+func (e *testEnv) recordSpdkParams() *spdkParamsRecorder {
+	recorder := &spdkParamsRecorder{JSONRPC: e.opiSpdkServer.rpc}
+	e.opiSpdkServer.rpc = recorder
+	return recorder
+}
+
 func createTestEnvironment(spdkResponses []string) *testEnv {
 	env := &testEnv{}
 	env.testSocket = utils.GenerateSocketName("backend")
@@ -88,6 +117,7 @@ func createTestEnvironment(spdkResponses []string) *testEnv {
 		pb.NewNullVolumeServiceClient(env.conn),
 		pb.NewMallocVolumeServiceClient(env.conn),
 		pb.NewAioVolumeServiceClient(env.conn),
+		pc.NewInventoryServiceClient(env.conn),
 	}
 
 	return env
@@ -100,6 +130,7 @@ func dialer(opiSpdkServer *Server) func(context.Context, string) (net.Conn, erro
 	pb.RegisterNullVolumeServiceServer(server, opiSpdkServer)
 	pb.RegisterMallocVolumeServiceServer(server, opiSpdkServer)
 	pb.RegisterAioVolumeServiceServer(server, opiSpdkServer)
+	pc.RegisterInventoryServiceServer(server, opiSpdkServer)
 
 	go func() {
 		if err := server.Serve(listener); err != nil {
@@ -111,3 +142,93 @@ func dialer(opiSpdkServer *Server) func(context.Context, string) (net.Conn, erro
 		return listener.Dial()
 	}
 }
+
+func TestBackEnd_NewCustomizedServer(t *testing.T) {
+	validJSONRPC := spdk.NewClient("/some/path")
+	validStore := gomap.NewStore(gomap.DefaultOptions)
+
+	tests := map[string]struct {
+		jsonRPC    spdk.JSONRPC
+		store      gomap.Store
+		blockSizes BlockSizes
+		defaultQos QosProfile
+		wantPanic  bool
+	}{
+		"nil json rpc": {
+			jsonRPC:    nil,
+			store:      validStore,
+			blockSizes: DefaultBlockSizes,
+			wantPanic:  true,
+		},
+		"unsupported null block size": {
+			jsonRPC:    validJSONRPC,
+			store:      validStore,
+			blockSizes: BlockSizes{Null: 1000, Malloc: 512, Aio: 512},
+			wantPanic:  true,
+		},
+		"zero aio block size": {
+			jsonRPC:    validJSONRPC,
+			store:      validStore,
+			blockSizes: BlockSizes{Null: 512, Malloc: 512, Aio: 0},
+			wantPanic:  true,
+		},
+		"negative default qos limit": {
+			jsonRPC:    validJSONRPC,
+			store:      validStore,
+			blockSizes: DefaultBlockSizes,
+			defaultQos: QosProfile{RwIosPerSec: -1},
+			wantPanic:  true,
+		},
+		"all valid arguments": {
+			jsonRPC:    validJSONRPC,
+			store:      validStore,
+			blockSizes: BlockSizes{Null: 4096, Malloc: 520, Aio: 512},
+			defaultQos: QosProfile{RwIosPerSec: 10000, RwMbytesPerSec: 100},
+			wantPanic:  false,
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if (r != nil) != tt.wantPanic {
+					t.Errorf("NewCustomizedServer() recover = %v, wantPanic = %v", r, tt.wantPanic)
+				}
+			}()
+
+			server := NewCustomizedServer(tt.jsonRPC, tt.store, tt.blockSizes, tt.defaultQos)
+			if server == nil && !tt.wantPanic {
+				t.Error("expected non nil server or panic")
+			}
+			if server != nil && server.blockSizes != tt.blockSizes {
+				t.Error("block sizes: expected", tt.blockSizes, "received", server.blockSizes)
+			}
+			if server != nil && server.defaultQos != tt.defaultQos {
+				t.Error("default qos: expected", tt.defaultQos, "received", server.defaultQos)
+			}
+		})
+	}
+}
+
+func TestBackEnd_ResourceCounts(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	testEnv.opiSpdkServer.Volumes.NullVolumes["volumes/null0"] = &pb.NullVolume{}
+	testEnv.opiSpdkServer.Volumes.NullVolumes["volumes/null1"] = &pb.NullVolume{}
+	testEnv.opiSpdkServer.Volumes.AioVolumes["volumes/aio0"] = &pb.AioVolume{}
+	testEnv.opiSpdkServer.Volumes.NvmeControllers["nvmeRemoteControllers/ctrl0"] = &pb.NvmeRemoteController{}
+	testEnv.opiSpdkServer.Volumes.PassthruVolumes["volumes/passthru0"] = &PassthruVolume{}
+
+	expected := map[string]int{
+		"aio_volumes":             1,
+		"null_volumes":            2,
+		"malloc_volumes":          0,
+		"passthru_volumes":        1,
+		"nvme_remote_controllers": 1,
+		"nvme_paths":              0,
+	}
+	if counts := testEnv.opiSpdkServer.ResourceCounts(); !reflect.DeepEqual(counts, expected) {
+		t.Error("counts: expected", expected, "received", counts)
+	}
+}
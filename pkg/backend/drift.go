@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+// StateDriftServiceName is full name of the service reporting drift between
+// stored BackEnd volumes and SPDK. It is not part of OPI API, so it is
+// registered with a hand written service descriptor
+const StateDriftServiceName = "opi_spdk_bridge.v1.StateDriftService"
+
+// driftProductNames maps SPDK product names of bdevs managed by BackEnd
+// volumes to volume types. Bdevs of other products are never reported
+var driftProductNames = map[string]string{
+	"Null disk":   "null",
+	"Malloc disk": "malloc",
+	"AIO disk":    "aio",
+}
+
+// SpecDrift is a volume property which differs between stored spec and SPDK
+type SpecDrift struct {
+	Name   string `json:"name"`
+	Field  string `json:"field"`
+	Stored string `json:"stored"`
+	Actual string `json:"actual"`
+}
+
+// StateDrift reports BackEnd volumes stored but missing in SPDK, bdevs in
+// SPDK not known to the bridge and volumes whose spec differs from SPDK.
+// Volumes and bdevs are referenced by resource name and bdev name respectively
+type StateDrift struct {
+	MissingInSpdk []string    `json:"missing_in_spdk"`
+	ExtraInSpdk   []string    `json:"extra_in_spdk"`
+	SpecDiffers   []SpecDrift `json:"spec_differs"`
+}
+
+// storedBdev is bdev expected in SPDK for a stored volume
+type storedBdev struct {
+	name        string
+	volumeType  string
+	blockSize   int64
+	blocksCount int64
+}
+
+// storedBdevs returns bdevs expected in SPDK keyed by bdev name
+func (s *Server) storedBdevs() map[string]storedBdev {
+	bdevs := make(map[string]storedBdev)
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	for name, volume := range s.Volumes.NullVolumes {
+		bdevs[utils.ResourceNameToID(name)] = storedBdev{name, "null", volume.BlockSize, volume.BlocksCount}
+	}
+	for name, volume := range s.Volumes.MallocVolumes {
+		bdevs[utils.ResourceNameToID(name)] = storedBdev{name, "malloc", volume.BlockSize, volume.BlocksCount}
+	}
+	for name, volume := range s.Volumes.AioVolumes {
+		bdevs[utils.ResourceNameToID(name)] = storedBdev{name, "aio", volume.BlockSize, volume.BlocksCount}
+	}
+	return bdevs
+}
+
+// diffState compares stored bdevs against bdevs reported by SPDK. It has no
+// side effects, so it can back both reporting and repairing of drift
+func diffState(stored map[string]storedBdev, actual []bdevGetBdevsResult) *StateDrift {
+	drift := &StateDrift{MissingInSpdk: []string{}, ExtraInSpdk: []string{}, SpecDiffers: []SpecDrift{}}
+	seen := make(map[string]bool)
+	for _, bdev := range actual {
+		volumeType, managed := driftProductNames[bdev.ProductName]
+		if !managed {
+			continue
+		}
+		seen[bdev.Name] = true
+		expected, ok := stored[bdev.Name]
+		if !ok {
+			drift.ExtraInSpdk = append(drift.ExtraInSpdk, bdev.Name)
+			continue
+		}
+		if expected.volumeType != volumeType {
+			drift.SpecDiffers = append(drift.SpecDiffers, SpecDrift{expected.name, "type", expected.volumeType, volumeType})
+		}
+		if expected.blockSize != 0 && expected.blockSize != bdev.BlockSize {
+			drift.SpecDiffers = append(drift.SpecDiffers, SpecDrift{expected.name, "block_size", fmt.Sprint(expected.blockSize), fmt.Sprint(bdev.BlockSize)})
+		}
+		if expected.blocksCount != 0 && expected.blocksCount != bdev.NumBlocks {
+			drift.SpecDiffers = append(drift.SpecDiffers, SpecDrift{expected.name, "blocks_count", fmt.Sprint(expected.blocksCount), fmt.Sprint(bdev.NumBlocks)})
+		}
+	}
+	for bdevName, expected := range stored {
+		if !seen[bdevName] {
+			drift.MissingInSpdk = append(drift.MissingInSpdk, expected.name)
+		}
+	}
+	sort.Strings(drift.MissingInSpdk)
+	sort.Strings(drift.ExtraInSpdk)
+	sort.SliceStable(drift.SpecDiffers, func(i int, j int) bool {
+		return drift.SpecDiffers[i].Name < drift.SpecDiffers[j].Name
+	})
+	return drift
+}
+
+// DiffStateDrift reports drift between stored BackEnd volumes and SPDK
+// without changing either of them
+func (s *Server) DiffStateDrift(ctx context.Context) (*StateDrift, error) {
+	var result []bdevGetBdevsResult
+	err := s.rpc.Call(ctx, "bdev_get_bdevs", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	return diffState(s.storedBdevs(), result), nil
+}
+
+// DiffState returns StateDrift as a struct
+func (s *Server) DiffState(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	drift, err := s.DiffStateDrift(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(drift)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	response := &structpb.Struct{}
+	if err := response.UnmarshalJSON(data); err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	return response, nil
+}
+
+// stateDriftServiceServer is implemented by Server
+type stateDriftServiceServer interface {
+	DiffState(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+}
+
+var stateDriftServiceDesc = grpc.ServiceDesc{
+	ServiceName: StateDriftServiceName,
+	HandlerType: (*stateDriftServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "DiffState",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(emptypb.Empty)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(stateDriftServiceServer).DiffState(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: "/" + StateDriftServiceName + "/DiffState",
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(stateDriftServiceServer).DiffState(ctx, req.(*emptypb.Empty))
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterStateDriftServer registers state drift service on s
+func RegisterStateDriftServer(s *grpc.Server, srv *Server) {
+	s.RegisterService(&stateDriftServiceDesc, srv)
+}
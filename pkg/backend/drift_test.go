@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestBackEnd_DiffState(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	nullName := utils.ResourceIDToVolumeName("null-drift")
+	mallocName := utils.ResourceIDToVolumeName("malloc-drift")
+	aioName := utils.ResourceIDToVolumeName("aio-drift")
+
+	tests := map[string]struct {
+		spdk    []string
+		out     *StateDrift
+		errCode codes.Code
+		errMsg  string
+	}{
+		"no drift": {
+			spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":[` +
+				`{"name":"null-drift","product_name":"Null disk","block_size":512,"num_blocks":64},` +
+				`{"name":"malloc-drift","product_name":"Malloc disk","block_size":512,"num_blocks":64},` +
+				`{"name":"aio-drift","product_name":"AIO disk","block_size":4096,"num_blocks":16},` +
+				`{"name":"opi-nvme8n1","product_name":"NVMe disk","block_size":512,"num_blocks":64}]}`},
+			out:     &StateDrift{MissingInSpdk: []string{}, ExtraInSpdk: []string{}, SpecDiffers: []SpecDrift{}},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"missing in spdk": {
+			spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":[` +
+				`{"name":"null-drift","product_name":"Null disk","block_size":512,"num_blocks":64}]}`},
+			out:     &StateDrift{MissingInSpdk: []string{aioName, mallocName}, ExtraInSpdk: []string{}, SpecDiffers: []SpecDrift{}},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"extra in spdk": {
+			spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":[` +
+				`{"name":"null-drift","product_name":"Null disk","block_size":512,"num_blocks":64},` +
+				`{"name":"malloc-drift","product_name":"Malloc disk","block_size":512,"num_blocks":64},` +
+				`{"name":"aio-drift","product_name":"AIO disk","block_size":4096,"num_blocks":16},` +
+				`{"name":"null-unknown","product_name":"Null disk","block_size":512,"num_blocks":64}]}`},
+			out:     &StateDrift{MissingInSpdk: []string{}, ExtraInSpdk: []string{"null-unknown"}, SpecDiffers: []SpecDrift{}},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"spec differs": {
+			spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":[` +
+				`{"name":"null-drift","product_name":"Null disk","block_size":4096,"num_blocks":8},` +
+				`{"name":"malloc-drift","product_name":"Null disk","block_size":512,"num_blocks":64},` +
+				`{"name":"aio-drift","product_name":"AIO disk","block_size":4096,"num_blocks":16}]}`},
+			out: &StateDrift{MissingInSpdk: []string{}, ExtraInSpdk: []string{}, SpecDiffers: []SpecDrift{
+				{Name: mallocName, Field: "type", Stored: "malloc", Actual: "null"},
+				{Name: nullName, Field: "block_size", Stored: "512", Actual: "4096"},
+				{Name: nullName, Field: "blocks_count", Stored: "64", Actual: "8"},
+			}},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with invalid marshal SPDK response": {
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("bdev_get_bdevs: %v", "json: cannot unmarshal bool into Go value of type []backend.bdevGetBdevsResult"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			testEnv.opiSpdkServer.Volumes.NullVolumes[nullName] = &pb.NullVolume{Name: nullName, BlockSize: 512, BlocksCount: 64}
+			testEnv.opiSpdkServer.Volumes.MallocVolumes[mallocName] = &pb.MallocVolume{Name: mallocName, BlockSize: 512, BlocksCount: 64}
+			testEnv.opiSpdkServer.Volumes.AioVolumes[aioName] = &pb.AioVolume{Name: aioName, BlockSize: 4096, BlocksCount: 16}
+
+			drift, err := testEnv.opiSpdkServer.DiffStateDrift(testEnv.ctx)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if !reflect.DeepEqual(drift, tt.out) {
+				t.Error("drift: expected", tt.out, "received", drift)
+			}
+			if len(testEnv.opiSpdkServer.Volumes.NullVolumes) != 1 ||
+				len(testEnv.opiSpdkServer.Volumes.MallocVolumes) != 1 ||
+				len(testEnv.opiSpdkServer.Volumes.AioVolumes) != 1 {
+				t.Error("expected stored volumes to stay unchanged")
+			}
+		})
+	}
+}
+
+func TestBackEnd_DiffStateStruct(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{`{"id":%d,"error":{"code":0,"message":""},"result":[` +
+		`{"name":"null-unknown","product_name":"Null disk","block_size":512,"num_blocks":64}]}`})
+	defer testEnv.Close()
+
+	response, err := testEnv.opiSpdkServer.DiffState(testEnv.ctx, &emptypb.Empty{})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	extra := response.Fields["extra_in_spdk"].GetListValue().AsSlice()
+	if !reflect.DeepEqual(extra, []interface{}{"null-unknown"}) {
+		t.Error("extra in spdk: expected", []string{"null-unknown"}, "received", extra)
+	}
+}
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Request metadata keys carrying multipath failover timing of a new Nvme
+// path in seconds, since NvmePath has no such fields. Omitted ones keep
+// SPDK defaults. Set by client on create and returned by server in header
+const (
+	// NvmePathCtrlrLossTimeoutMetadataKey is time to keep reconnecting a
+	// lost controller before deleting it, -1 reconnects forever
+	NvmePathCtrlrLossTimeoutMetadataKey = "opi-nvme-ctrlr-loss-timeout-sec"
+	// NvmePathReconnectDelayMetadataKey is delay between reconnect attempts
+	NvmePathReconnectDelayMetadataKey = "opi-nvme-reconnect-delay-sec"
+	// NvmePathFastIOFailTimeoutMetadataKey is time after which I/O to a lost
+	// controller fails over instead of waiting for reconnect
+	NvmePathFastIOFailTimeoutMetadataKey = "opi-nvme-fast-io-fail-timeout-sec"
+)
+
+// nvmePathFailover contains failover timing passed to
+// bdev_nvme_attach_controller, zero values keep SPDK defaults
+type nvmePathFailover struct {
+	CtrlrLossTimeoutSec  int `json:"ctrlr_loss_timeout_sec,omitempty"`
+	ReconnectDelaySec    int `json:"reconnect_delay_sec,omitempty"`
+	FastIoFailTimeoutSec int `json:"fast_io_fail_timeout_sec,omitempty"`
+}
+
+// nvmePathFailoverField is a failover value with its metadata key
+type nvmePathFailoverField struct {
+	key   string
+	value *int
+}
+
+func (f *nvmePathFailover) fields() []nvmePathFailoverField {
+	return []nvmePathFailoverField{
+		{NvmePathCtrlrLossTimeoutMetadataKey, &f.CtrlrLossTimeoutSec},
+		{NvmePathReconnectDelayMetadataKey, &f.ReconnectDelaySec},
+		{NvmePathFastIOFailTimeoutMetadataKey, &f.FastIoFailTimeoutSec},
+	}
+}
+
+// nvmePathFailoverFromContext returns failover timing requested by client
+func nvmePathFailoverFromContext(ctx context.Context) (nvmePathFailover, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	failover := nvmePathFailover{}
+	for _, field := range failover.fields() {
+		values := md.Get(field.key)
+		if len(values) == 0 {
+			continue
+		}
+		value, err := strconv.Atoi(values[0])
+		if err != nil {
+			msg := fmt.Sprintf("invalid %s %q", field.key, values[0])
+			return nvmePathFailover{}, status.Errorf(codes.InvalidArgument, msg)
+		}
+		*field.value = value
+	}
+	if err := failover.validate(); err != nil {
+		return nvmePathFailover{}, err
+	}
+	return failover, nil
+}
+
+func (f *nvmePathFailover) validate() error {
+	if f.CtrlrLossTimeoutSec < -1 {
+		msg := fmt.Sprintf("ctrlr loss timeout %d s must be -1 or non-negative", f.CtrlrLossTimeoutSec)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if f.ReconnectDelaySec < 0 {
+		msg := fmt.Sprintf("reconnect delay %d s must be non-negative", f.ReconnectDelaySec)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if f.FastIoFailTimeoutSec < 0 {
+		msg := fmt.Sprintf("fast io fail timeout %d s must be non-negative", f.FastIoFailTimeoutSec)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if f.CtrlrLossTimeoutSec != -1 && f.ReconnectDelaySec > 0 && f.ReconnectDelaySec >= f.CtrlrLossTimeoutSec {
+		msg := fmt.Sprintf("reconnect delay %d s must be less than ctrlr loss timeout %d s", f.ReconnectDelaySec, f.CtrlrLossTimeoutSec)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func nvmePathFailoverStoreKey(name string) string {
+	return "failover/" + name
+}
+
+// setNvmePathFailover records failover timing of path in the store
+func (s *Server) setNvmePathFailover(name string, failover nvmePathFailover) {
+	if failover == (nvmePathFailover{}) {
+		return
+	}
+	value := &structpb.Struct{Fields: make(map[string]*structpb.Value)}
+	for _, field := range failover.fields() {
+		if *field.value != 0 {
+			value.Fields[field.key] = structpb.NewNumberValue(float64(*field.value))
+		}
+	}
+	if err := s.store.Set(nvmePathFailoverStoreKey(name), value); err != nil {
+		log.Printf("error: failed to store failover of %v: %v", name, err)
+	}
+}
+
+// nvmePathFailover returns failover timing of path kept in the store
+func (s *Server) nvmePathFailover(name string) nvmePathFailover {
+	failover := nvmePathFailover{}
+	value := &structpb.Struct{}
+	found, err := s.store.Get(nvmePathFailoverStoreKey(name), value)
+	if err != nil {
+		log.Printf("error: failed to load failover of %v: %v", name, err)
+	}
+	if !found || err != nil {
+		return failover
+	}
+	for _, field := range failover.fields() {
+		*field.value = int(value.Fields[field.key].GetNumberValue())
+	}
+	return failover
+}
+
+// clearNvmePathFailover forgets failover timing of a deleted path
+func (s *Server) clearNvmePathFailover(name string) {
+	if err := s.store.Delete(nvmePathFailoverStoreKey(name)); err != nil {
+		log.Printf("error: failed to delete failover of %v: %v", name, err)
+	}
+}
+
+func sendNvmePathFailover(ctx context.Context, failover nvmePathFailover) {
+	md := metadata.MD{}
+	for _, field := range failover.fields() {
+		if *field.value != 0 {
+			md.Set(field.key, strconv.Itoa(*field.value))
+		}
+	}
+	if md.Len() == 0 {
+		return
+	}
+	if err := grpc.SetHeader(ctx, md); err != nil {
+		log.Printf("error: failed to send failover: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+	"github.com/philippgille/gokv/gomap"
+)
+
+func TestBackEnd_CreateNvmePathFailover(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	attachParams := `{"name":"opi-nvme8","trtype":"TCP","traddr":"127.0.0.1","hostnqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","adrfam":"IPV4","trsvcid":"4444","subnqn":"nqn.2016-06.io.spdk:cnode1"%s}`
+	tests := map[string]struct {
+		md      []string
+		params  []string
+		want    nvmePathFailover
+		errCode codes.Code
+		errMsg  string
+	}{
+		"all failover options": {
+			md: []string{
+				NvmePathCtrlrLossTimeoutMetadataKey, "30",
+				NvmePathReconnectDelayMetadataKey, "5",
+				NvmePathFastIOFailTimeoutMetadataKey, "10",
+			},
+			params:  []string{fmt.Sprintf(attachParams, `,"ctrlr_loss_timeout_sec":30,"reconnect_delay_sec":5,"fast_io_fail_timeout_sec":10`)},
+			want:    nvmePathFailover{CtrlrLossTimeoutSec: 30, ReconnectDelaySec: 5, FastIoFailTimeoutSec: 10},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"reconnect forever": {
+			md: []string{
+				NvmePathCtrlrLossTimeoutMetadataKey, "-1",
+				NvmePathReconnectDelayMetadataKey, "60",
+			},
+			params:  []string{fmt.Sprintf(attachParams, `,"ctrlr_loss_timeout_sec":-1,"reconnect_delay_sec":60`)},
+			want:    nvmePathFailover{CtrlrLossTimeoutSec: -1, ReconnectDelaySec: 60},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"no failover options": {
+			md:      nil,
+			params:  []string{fmt.Sprintf(attachParams, "")},
+			want:    nvmePathFailover{},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"reconnect delay not less than ctrlr loss timeout": {
+			md: []string{
+				NvmePathCtrlrLossTimeoutMetadataKey, "5",
+				NvmePathReconnectDelayMetadataKey, "5",
+			},
+			params:  nil,
+			want:    nvmePathFailover{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("reconnect delay %d s must be less than ctrlr loss timeout %d s", 5, 5),
+		},
+		"negative reconnect delay": {
+			md:      []string{NvmePathReconnectDelayMetadataKey, "-5"},
+			params:  nil,
+			want:    nvmePathFailover{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("reconnect delay %d s must be non-negative", -5),
+		},
+		"invalid ctrlr loss timeout": {
+			md:      []string{NvmePathCtrlrLossTimeoutMetadataKey, "-2"},
+			params:  nil,
+			want:    nvmePathFailover{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("ctrlr loss timeout %d s must be -1 or non-negative", -2),
+		},
+		"malformed fast io fail timeout": {
+			md:      []string{NvmePathFastIOFailTimeoutMetadataKey, "10s"},
+			params:  nil,
+			want:    nvmePathFailover{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("invalid %s %q", NvmePathFastIOFailTimeoutMetadataKey, "10s"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			spdk := []string{}
+			if tt.params != nil {
+				spdk = []string{`{"id":%d,"error":{"code":0,"message":""},"result":["mytest"]}`}
+			}
+			testEnv := createTestEnvironment(spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+			testEnv.opiSpdkServer.Volumes.NvmeControllers[testNvmeCtrlName] = utils.ProtoClone(&testNvmeCtrlWithName)
+
+			ctx := metadata.AppendToOutgoingContext(testEnv.ctx, tt.md...)
+			var header metadata.MD
+			request := &pb.CreateNvmePathRequest{Parent: testNvmeCtrlName, NvmePath: &testNvmePath, NvmePathId: testNvmePathID}
+			_, err := testEnv.client.CreateNvmePath(ctx, request, grpc.Header(&header))
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if !reflect.DeepEqual(recorder.params, tt.params) {
+				t.Error("spdk params: expected", tt.params, "received", recorder.params)
+			}
+			if failover := testEnv.opiSpdkServer.nvmePathFailover(testNvmePathName); failover != tt.want {
+				t.Error("stored failover: expected", tt.want, "received", failover)
+			}
+			for i := 0; i+1 < len(tt.md) && tt.errCode == codes.OK; i += 2 {
+				if values := header.Get(tt.md[i]); !reflect.DeepEqual(values, []string{tt.md[i+1]}) {
+					t.Error("header", tt.md[i], "expected", tt.md[i+1], "received", values)
+				}
+			}
+		})
+	}
+}
+
+func TestBackEnd_GetNvmePathFailover(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"opi-nvme8","ctrlrs":[{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"127.0.0.1","trsvcid":"4444","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":1,"host":{"nqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","addr":"127.0.0.1","svcid":"53412"}}]}]}`})
+	defer testEnv.Close()
+	testEnv.opiSpdkServer.Volumes.NvmePaths[testNvmePathName] = utils.ProtoClone(&testNvmePathWithName)
+	failover := nvmePathFailover{CtrlrLossTimeoutSec: -1, ReconnectDelaySec: 10}
+	testEnv.opiSpdkServer.setNvmePathFailover(testNvmePathName, failover)
+
+	// server restarted on the same store still knows failover of its paths
+	restarted := NewServer(testEnv.opiSpdkServer.rpc, testEnv.opiSpdkServer.store)
+	if stored := restarted.nvmePathFailover(testNvmePathName); stored != failover {
+		t.Error("stored failover: expected", failover, "received", stored)
+	}
+
+	var header metadata.MD
+	request := &pb.GetNvmePathRequest{Name: testNvmePathName}
+	if _, err := testEnv.client.GetNvmePath(testEnv.ctx, request, grpc.Header(&header)); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if values := header.Get(NvmePathCtrlrLossTimeoutMetadataKey); !reflect.DeepEqual(values, []string{"-1"}) {
+		t.Error("header ctrlr loss timeout: expected", -1, "received", values)
+	}
+	if values := header.Get(NvmePathReconnectDelayMetadataKey); !reflect.DeepEqual(values, []string{"10"}) {
+		t.Error("header reconnect delay: expected", 10, "received", values)
+	}
+	if values := header.Get(NvmePathFastIOFailTimeoutMetadataKey); values != nil {
+		t.Error("header fast io fail timeout: expected none, received", values)
+	}
+}
+
+func TestBackEnd_DeleteNvmePathFailover(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`})
+	defer testEnv.Close()
+	testEnv.opiSpdkServer.Volumes.NvmeControllers[testNvmeCtrlName] = utils.ProtoClone(&testNvmeCtrlWithName)
+	testEnv.opiSpdkServer.Volumes.NvmePaths[testNvmePathName] = utils.ProtoClone(&testNvmePathWithName)
+	testEnv.opiSpdkServer.setNvmePathFailover(testNvmePathName, nvmePathFailover{CtrlrLossTimeoutSec: 30})
+
+	request := &pb.DeleteNvmePathRequest{Name: testNvmePathName}
+	if _, err := testEnv.client.DeleteNvmePath(testEnv.ctx, request); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if failover := testEnv.opiSpdkServer.nvmePathFailover(testNvmePathName); failover != (nvmePathFailover{}) {
+		t.Error("expected failover of deleted path to be released, received", failover)
+	}
+}
+
+func TestBackEnd_NvmePathFailoverStorePrefix(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	options := gomap.DefaultOptions
+	options.Codec = utils.ProtoCodec{}
+	shared := gomap.NewStore(options)
+	failover := nvmePathFailover{CtrlrLossTimeoutSec: 30}
+	NewServer(testEnv.jsonRPC, utils.NewPrefixedStore(shared, "bridge-1")).setNvmePathFailover(testNvmePathName, failover)
+
+	// bridges sharing store with different prefixes do not see each other's paths
+	other := NewServer(testEnv.jsonRPC, utils.NewPrefixedStore(shared, "bridge-2"))
+	if stored := other.nvmePathFailover(testNvmePathName); stored != (nvmePathFailover{}) {
+		t.Error("expected no failover under another prefix, received", stored)
+	}
+	restarted := NewServer(testEnv.jsonRPC, utils.NewPrefixedStore(shared, "bridge-1"))
+	if stored := restarted.nvmePathFailover(testNvmePathName); stored != failover {
+		t.Error("stored failover: expected", failover, "received", stored)
+	}
+}
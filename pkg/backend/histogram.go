@@ -0,0 +1,268 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// VolumeLatencyHistogramServiceName is full name of the service reporting
+// latency distribution of BackEnd volumes. It is not part of OPI API, so it
+// is registered with a hand written service descriptor
+const VolumeLatencyHistogramServiceName = "opi_spdk_bridge.v1.VolumeLatencyHistogramService"
+
+// bdevEnableHistogramParams are parameters of bdev_enable_histogram
+// TODO: remove once gospdk supports histograms
+type bdevEnableHistogramParams struct {
+	Name   string `json:"name"`
+	Enable bool   `json:"enable"`
+}
+
+// bdevGetHistogramParams are parameters of bdev_get_histogram
+type bdevGetHistogramParams struct {
+	Name string `json:"name"`
+}
+
+// bdevGetHistogramResult is result of bdev_get_histogram. Histogram is base64
+// encoded array of little endian uint64 counts of IOs per bucket
+type bdevGetHistogramResult struct {
+	Histogram   string `json:"histogram"`
+	BucketShift int    `json:"bucket_shift"`
+	TscRate     uint64 `json:"tsc_rate"`
+}
+
+// LatencyBucket is number of IOs completed with latency in [StartUs, EndUs)
+type LatencyBucket struct {
+	StartUs float64 `json:"start_us"`
+	EndUs   float64 `json:"end_us"`
+	Count   uint64  `json:"count"`
+}
+
+// VolumeLatencyHistogram is latency distribution of a volume since its
+// histogram was enabled. Only buckets with IOs are reported
+type VolumeLatencyHistogram struct {
+	Name    string          `json:"name"`
+	Total   uint64          `json:"total"`
+	Buckets []LatencyBucket `json:"buckets"`
+}
+
+// parseHistogram converts SPDK histogram buckets, see
+// spdk/include/spdk_internal/histogram_data.h, to latency buckets
+func parseHistogram(result *bdevGetHistogramResult) ([]LatencyBucket, uint64, error) {
+	data, err := base64.StdEncoding.DecodeString(result.Histogram)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid histogram encoding: %v", err)
+	}
+	if result.BucketShift <= 0 || result.BucketShift >= 64 || result.TscRate == 0 {
+		return nil, 0, fmt.Errorf("invalid histogram bucket shift %d or tsc rate %d", result.BucketShift, result.TscRate)
+	}
+	perRange := 1 << result.BucketShift
+	ranges := 64 - result.BucketShift + 1
+	if len(data) != 8*perRange*ranges {
+		return nil, 0, fmt.Errorf("expecting histogram of %d buckets, got %d bytes", perRange*ranges, len(data))
+	}
+	toUs := func(ticks uint64) float64 {
+		return float64(ticks) * 1000 * 1000 / float64(result.TscRate)
+	}
+	buckets := []LatencyBucket{}
+	total := uint64(0)
+	end := uint64(0)
+	for r := 0; r < ranges; r++ {
+		for i := 0; i < perRange; i++ {
+			start := end
+			if r > 0 {
+				end = 1<<(r+result.BucketShift-1) + uint64(i+1)<<(r-1)
+			} else {
+				end = uint64(i + 1)
+			}
+			index := 8 * (r*perRange + i)
+			count := binary.LittleEndian.Uint64(data[index : index+8])
+			if count == 0 {
+				continue
+			}
+			total += count
+			buckets = append(buckets, LatencyBucket{StartUs: toUs(start), EndUs: toUs(end), Count: count})
+		}
+	}
+	return buckets, total, nil
+}
+
+// histogramVolumeExists checks name refers to a BackEnd volume
+func (s *Server) histogramVolumeExists(name string) error {
+	if err := resourcename.Validate(name); err != nil {
+		return status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	s.mapsMu.RLock()
+	_, null := s.Volumes.NullVolumes[name]
+	_, malloc := s.Volumes.MallocVolumes[name]
+	_, aio := s.Volumes.AioVolumes[name]
+	s.mapsMu.RUnlock()
+	if !null && !malloc && !aio {
+		return status.Errorf(codes.NotFound, "unable to find key %s", name)
+	}
+	return nil
+}
+
+// enableHistogram turns latency histogram of bdev on or off
+func (s *Server) enableHistogram(ctx context.Context, bdevName string, enable bool) error {
+	params := bdevEnableHistogramParams{
+		Name:   bdevName,
+		Enable: enable,
+	}
+	var result bool
+	err := s.rpc.Call(ctx, "bdev_enable_histogram", &params, &result)
+	if err != nil {
+		return err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	if !result {
+		msg := fmt.Sprintf("Could not set histogram of %s enabled to %v", bdevName, enable)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+// GetVolumeLatencyHistogram returns latency distribution of a volume. The
+// first call enables histogram of the volume, so it reports IOs completed
+// since then
+func (s *Server) GetVolumeLatencyHistogram(ctx context.Context, in *wrapperspb.StringValue) (*VolumeLatencyHistogram, error) {
+	if err := s.histogramVolumeExists(in.GetValue()); err != nil {
+		return nil, err
+	}
+	bdevName := utils.ResourceNameToID(in.Value)
+	s.mapsMu.RLock()
+	enabled := s.histograms[bdevName]
+	s.mapsMu.RUnlock()
+	if !enabled {
+		if err := s.enableHistogram(ctx, bdevName, true); err != nil {
+			return nil, err
+		}
+		s.mapsMu.Lock()
+		s.histograms[bdevName] = true
+		s.mapsMu.Unlock()
+	}
+	params := bdevGetHistogramParams{
+		Name: bdevName,
+	}
+	var result bdevGetHistogramResult
+	err := s.rpc.Call(ctx, "bdev_get_histogram", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	buckets, total, err := parseHistogram(&result)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	return &VolumeLatencyHistogram{Name: in.Value, Total: total, Buckets: buckets}, nil
+}
+
+// DisableVolumeLatencyHistogram stops collecting latency distribution of a
+// volume, dropping collected data
+func (s *Server) DisableVolumeLatencyHistogram(ctx context.Context, in *wrapperspb.StringValue) (*emptypb.Empty, error) {
+	if err := s.histogramVolumeExists(in.GetValue()); err != nil {
+		return nil, err
+	}
+	bdevName := utils.ResourceNameToID(in.Value)
+	s.mapsMu.RLock()
+	enabled := s.histograms[bdevName]
+	s.mapsMu.RUnlock()
+	if !enabled {
+		return &emptypb.Empty{}, nil
+	}
+	if err := s.enableHistogram(ctx, bdevName, false); err != nil {
+		return nil, err
+	}
+	s.clearHistogram(bdevName)
+	return &emptypb.Empty{}, nil
+}
+
+// clearHistogram forgets histogram state of a deleted bdev
+func (s *Server) clearHistogram(bdevName string) {
+	s.mapsMu.Lock()
+	defer s.mapsMu.Unlock()
+	delete(s.histograms, bdevName)
+}
+
+// GetVolumeLatencyHistogramStruct returns VolumeLatencyHistogram as a struct
+func (s *Server) GetVolumeLatencyHistogramStruct(ctx context.Context, in *wrapperspb.StringValue) (*structpb.Struct, error) {
+	histogram, err := s.GetVolumeLatencyHistogram(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(histogram)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	response := &structpb.Struct{}
+	if err := response.UnmarshalJSON(data); err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	return response, nil
+}
+
+// volumeLatencyHistogramServiceServer is implemented by Server
+type volumeLatencyHistogramServiceServer interface {
+	GetVolumeLatencyHistogramStruct(context.Context, *wrapperspb.StringValue) (*structpb.Struct, error)
+	DisableVolumeLatencyHistogram(context.Context, *wrapperspb.StringValue) (*emptypb.Empty, error)
+}
+
+func volumeLatencyHistogramHandler(method string, call func(volumeLatencyHistogramServiceServer, context.Context, *wrapperspb.StringValue) (interface{}, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(wrapperspb.StringValue)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(volumeLatencyHistogramServiceServer), ctx, in)
+			}
+			info := &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: "/" + VolumeLatencyHistogramServiceName + "/" + method,
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(volumeLatencyHistogramServiceServer), ctx, req.(*wrapperspb.StringValue))
+			}
+			return interceptor(ctx, in, info, handler)
+		},
+	}
+}
+
+var volumeLatencyHistogramServiceDesc = grpc.ServiceDesc{
+	ServiceName: VolumeLatencyHistogramServiceName,
+	HandlerType: (*volumeLatencyHistogramServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		volumeLatencyHistogramHandler("GetVolumeLatencyHistogram", func(srv volumeLatencyHistogramServiceServer, ctx context.Context, in *wrapperspb.StringValue) (interface{}, error) {
+			return srv.GetVolumeLatencyHistogramStruct(ctx, in)
+		}),
+		volumeLatencyHistogramHandler("DisableVolumeLatencyHistogram", func(srv volumeLatencyHistogramServiceServer, ctx context.Context, in *wrapperspb.StringValue) (interface{}, error) {
+			return srv.DisableVolumeLatencyHistogram(ctx, in)
+		}),
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterVolumeLatencyHistogramServer registers volume latency histogram
+// service on s
+func RegisterVolumeLatencyHistogramServer(s *grpc.Server, srv *Server) {
+	s.RegisterService(&volumeLatencyHistogramServiceDesc, srv)
+}
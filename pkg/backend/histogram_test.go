@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+// encodeHistogram builds bdev_get_histogram result with bucket shift 1, so
+// there are 2 buckets per each of 64 ranges, and given bucket counts
+func encodeHistogram(counts map[int]uint64) string {
+	data := make([]byte, 8*2*64)
+	for index, count := range counts {
+		binary.LittleEndian.PutUint64(data[8*index:], count)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestBackEnd_GetVolumeLatencyHistogram(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	// 1 tick is 1 us, bucket 0 is [0, 1) ticks, bucket 5 (range 2) is [6, 8)
+	histogram := fmt.Sprintf(`{"id":%%d,"error":{"code":0,"message":""},"result":{"histogram":"%s","bucket_shift":1,"tsc_rate":1000000}}`,
+		encodeHistogram(map[int]uint64{0: 3, 5: 7}))
+	buckets := []LatencyBucket{{StartUs: 0, EndUs: 1, Count: 3}, {StartUs: 6, EndUs: 8, Count: 7}}
+
+	tests := map[string]struct {
+		in       string
+		enabled  bool
+		spdk     []string
+		methods  []string
+		out      *VolumeLatencyHistogram
+		errCode  codes.Code
+		errMsg   string
+		tracking bool
+	}{
+		"histogram is enabled on first request": {
+			in:       testNullVolumeName,
+			enabled:  false,
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`, histogram},
+			methods:  []string{"bdev_enable_histogram", "bdev_get_histogram"},
+			out:      &VolumeLatencyHistogram{Name: testNullVolumeName, Total: 10, Buckets: buckets},
+			errCode:  codes.OK,
+			errMsg:   "",
+			tracking: true,
+		},
+		"enabled histogram is only collected": {
+			in:       testNullVolumeName,
+			enabled:  true,
+			spdk:     []string{histogram},
+			methods:  []string{"bdev_get_histogram"},
+			out:      &VolumeLatencyHistogram{Name: testNullVolumeName, Total: 10, Buckets: buckets},
+			errCode:  codes.OK,
+			errMsg:   "",
+			tracking: true,
+		},
+		"failed enable": {
+			in:       testNullVolumeName,
+			enabled:  false,
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":false}`},
+			methods:  []string{"bdev_enable_histogram"},
+			out:      nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("Could not set histogram of %s enabled to %v", testNullVolumeID, true),
+			tracking: false,
+		},
+		"truncated histogram": {
+			in:       testNullVolumeName,
+			enabled:  true,
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"histogram":"AAAAAAAAAAA=","bucket_shift":1,"tsc_rate":1000000}}`},
+			methods:  []string{"bdev_get_histogram"},
+			out:      nil,
+			errCode:  codes.Internal,
+			errMsg:   fmt.Sprintf("expecting histogram of %d buckets, got %d bytes", 128, 8),
+			tracking: true,
+		},
+		"unknown volume": {
+			in:       utils.ResourceIDToVolumeName("unknown-id"),
+			enabled:  false,
+			spdk:     []string{},
+			methods:  nil,
+			out:      nil,
+			errCode:  codes.NotFound,
+			errMsg:   fmt.Sprintf("unable to find key %s", utils.ResourceIDToVolumeName("unknown-id")),
+			tracking: false,
+		},
+		"malformed name": {
+			in:       "-ABC-DEF",
+			enabled:  false,
+			spdk:     []string{},
+			methods:  nil,
+			out:      nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			tracking: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+			testEnv.opiSpdkServer.Volumes.NullVolumes[testNullVolumeName] = utils.ProtoClone(&testNullVolume)
+			if tt.enabled {
+				testEnv.opiSpdkServer.histograms[testNullVolumeID] = true
+			}
+
+			response, err := testEnv.opiSpdkServer.GetVolumeLatencyHistogram(testEnv.ctx, wrapperspb.String(tt.in))
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+			if !reflect.DeepEqual(recorder.methods, tt.methods) {
+				t.Error("methods: expected", tt.methods, "received", recorder.methods)
+			}
+			if enabled := testEnv.opiSpdkServer.histograms[testNullVolumeID]; enabled != tt.tracking {
+				t.Error("histogram enabled: expected", tt.tracking, "received", enabled)
+			}
+		})
+	}
+}
+
+func TestBackEnd_DisableVolumeLatencyHistogram(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`})
+	defer testEnv.Close()
+	recorder := testEnv.recordSpdkParams()
+	testEnv.opiSpdkServer.Volumes.NullVolumes[testNullVolumeName] = utils.ProtoClone(&testNullVolume)
+	testEnv.opiSpdkServer.histograms[testNullVolumeID] = true
+
+	// second call finds histogram disabled and does not call SPDK
+	for i := 0; i < 2; i++ {
+		if _, err := testEnv.opiSpdkServer.DisableVolumeLatencyHistogram(testEnv.ctx, wrapperspb.String(testNullVolumeName)); err != nil {
+			t.Fatal("expected no error, received", err)
+		}
+	}
+	params := []string{fmt.Sprintf(`{"name":"%s","enable":false}`, testNullVolumeID)}
+	if !reflect.DeepEqual(recorder.params, params) {
+		t.Error("spdk params: expected", params, "received", recorder.params)
+	}
+	if _, ok := testEnv.opiSpdkServer.histograms[testNullVolumeID]; ok {
+		t.Error("expected histogram to be disabled")
+	}
+}
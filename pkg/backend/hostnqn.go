@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/google/uuid"
+)
+
+// hostNqnUUIDPrefix is prefix of UUID based NQNs defined by Nvme base
+// specification
+const hostNqnUUIDPrefix = "nqn.2014-08.org.nvmexpress:uuid:"
+
+// defaultHostID returns UUID derived from host name, so that it is stable
+// across restarts, but differs between hosts
+func defaultHostID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Printf("error: failed to get host name for host ID: %v", err)
+		hostname = "localhost"
+	}
+	return uuid.NewSHA1(uuid.NameSpaceDNS, []byte(hostname)).String()
+}
+
+// SetHostID sets UUID identifying this host, which hostnqn of TCP Nvme paths
+// created without one is derived from. Empty hostID derives it from host
+// name
+func (s *Server) SetHostID(hostID string) error {
+	if hostID == "" {
+		s.hostID = defaultHostID()
+		return nil
+	}
+	parsed, err := uuid.Parse(hostID)
+	if err != nil {
+		return fmt.Errorf("invalid host ID %q: %v", hostID, err)
+	}
+	s.hostID = parsed.String()
+	return nil
+}
+
+// derivedHostnqn returns hostnqn for path to remote controller created
+// without one. Host ID of the controller is preferred, so hostnqn and
+// hostid SPDK connects with match
+func (s *Server) derivedHostnqn(controllerName string) string {
+	s.mapsMu.RLock()
+	hostID := s.nvmeHostIDs[controllerName]
+	s.mapsMu.RUnlock()
+	if hostID == "" {
+		hostID = s.hostID
+	}
+	return hostNqnUUIDPrefix + hostID
+}
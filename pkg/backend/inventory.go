@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/opiproject/gospdk/spdk"
+	pc "github.com/opiproject/opi-api/inventory/v1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+// spdkPCIeDriver is reported as driver of NVMe devices attached to SPDK
+// which are not visible in sysfs, e.g. in a container
+const spdkPCIeDriver = "spdk"
+
+func storagePCIeDevices() ([]*pc.PCIeDeviceInfo, error) {
+	return utils.StoragePCIeDevices(utils.SysfsPCIDevicesDir)
+}
+
+// GetInventory reports storage related hardware of the host: mass storage
+// PCI devices and local NVMe devices attached to SPDK
+func (s *Server) GetInventory(ctx context.Context, in *pc.GetInventoryRequest) (*pc.Inventory, error) {
+	if in.Name != "" {
+		log.Printf("Inventory %v requested, returning full inventory", in.Name)
+	}
+	devices, err := s.pciDevices()
+	if err != nil {
+		log.Printf("error: failed to list PCI devices: %v", err)
+		devices = []*pc.PCIeDeviceInfo{}
+	}
+	byAddress := make(map[string]*pc.PCIeDeviceInfo, len(devices))
+	for _, device := range devices {
+		byAddress[strings.ToLower(device.Address)] = device
+	}
+
+	var result []spdk.BdevNvmeGetControllerResult
+	err = s.rpc.Call(ctx, "bdev_nvme_get_controllers", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	for _, controller := range result {
+		for _, ctrlr := range controller.Ctrlrs {
+			if !strings.EqualFold(ctrlr.Trid.Trtype, "PCIe") {
+				continue
+			}
+			address := strings.ToLower(ctrlr.Trid.Traddr)
+			if _, ok := byAddress[address]; ok {
+				continue
+			}
+			device := &pc.PCIeDeviceInfo{
+				Driver:  spdkPCIeDriver,
+				Address: address,
+				Product: controller.Name,
+			}
+			byAddress[address] = device
+			devices = append(devices, device)
+		}
+	}
+	sort.Slice(devices, func(i int, j int) bool {
+		return devices[i].Address < devices[j].Address
+	})
+	return &pc.Inventory{Pci: devices}, nil
+}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pc "github.com/opiproject/opi-api/inventory/v1/gen/go"
+)
+
+func TestBackEnd_GetInventory(t *testing.T) {
+	controllers := `{"id":%d,"error":{"code":0,"message":""},"result":[` +
+		`{"name":"Nvme0","ctrlrs":[{"state":"enabled","trid":{"trtype":"PCIe","traddr":"0000:5e:00.0"},"cntlid":0,"host":{"nqn":"","addr":"","svcid":""}}]},` +
+		`{"name":"Nvme1","ctrlrs":[{"state":"enabled","trid":{"trtype":"PCIe","traddr":"0000:AF:00.0"},"cntlid":0,"host":{"nqn":"","addr":"","svcid":""}}]},` +
+		`{"name":"OpiNvme8","ctrlrs":[{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"127.0.0.1","trsvcid":"4444","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":1,"host":{"nqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","addr":"","svcid":""}}]}` +
+		`]}`
+	sysfsDevice := &pc.PCIeDeviceInfo{Driver: "vfio-pci", Address: "0000:5e:00.0", Vendor: "0x8086", Product: "0x0a54", Revision: "0x00"}
+	sataDevice := &pc.PCIeDeviceInfo{Driver: "ahci", Address: "0000:00:17.0", Vendor: "0x8086", Product: "0xa352", Revision: "0x10"}
+
+	tests := map[string]struct {
+		pciDevices []*pc.PCIeDeviceInfo
+		pciErr     error
+		spdk       []string
+		out        []*pc.PCIeDeviceInfo
+		errCode    codes.Code
+		errMsg     string
+	}{
+		"sysfs devices merged with SPDK controllers": {
+			pciDevices: []*pc.PCIeDeviceInfo{sysfsDevice, sataDevice},
+			pciErr:     nil,
+			spdk:       []string{controllers},
+			out: []*pc.PCIeDeviceInfo{
+				sataDevice,
+				sysfsDevice,
+				{Driver: "spdk", Address: "0000:af:00.0", Product: "Nvme1"},
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"sysfs not available": {
+			pciDevices: nil,
+			pciErr:     errors.New("no sysfs"),
+			spdk:       []string{controllers},
+			out: []*pc.PCIeDeviceInfo{
+				{Driver: "spdk", Address: "0000:5e:00.0", Product: "Nvme0"},
+				{Driver: "spdk", Address: "0000:af:00.0", Product: "Nvme1"},
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"no devices": {
+			pciDevices: []*pc.PCIeDeviceInfo{},
+			pciErr:     nil,
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":[]}`},
+			out:        []*pc.PCIeDeviceInfo{},
+			errCode:    codes.OK,
+			errMsg:     "",
+		},
+		"SPDK error": {
+			pciDevices: []*pc.PCIeDeviceInfo{},
+			pciErr:     nil,
+			spdk:       []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":[]}`},
+			out:        nil,
+			errCode:    codes.Unknown,
+			errMsg:     fmt.Sprintf("bdev_nvme_get_controllers: %v", "json response error: myopierr"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			testEnv.opiSpdkServer.pciDevices = func() ([]*pc.PCIeDeviceInfo, error) {
+				devices := make([]*pc.PCIeDeviceInfo, len(tt.pciDevices))
+				for i, device := range tt.pciDevices {
+					devices[i] = proto.Clone(device).(*pc.PCIeDeviceInfo)
+				}
+				return devices, tt.pciErr
+			}
+
+			response, err := testEnv.client.GetInventory(testEnv.ctx, &pc.GetInventoryRequest{})
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if tt.out == nil {
+				if response != nil {
+					t.Error("response: expected nil, received", response)
+				}
+				return
+			}
+			if !proto.Equal(response, &pc.Inventory{Pci: tt.out}) {
+				t.Error("response: expected", tt.out, "received", response.GetPci())
+			}
+		})
+	}
+}
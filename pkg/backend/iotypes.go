@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/opiproject/gospdk/spdk"
+)
+
+// SupportedIoTypesHeaderKey is response header key carrying JSON encoded
+// SupportedIoTypes of the volume returned by Get call
+const SupportedIoTypesHeaderKey = "opi-supported-io-types"
+
+// SupportedIoTypes reports which IO types a volume is able to serve
+type SupportedIoTypes struct {
+	Read            bool `json:"read"`
+	Write           bool `json:"write"`
+	Unmap           bool `json:"unmap"`
+	WriteZeroes     bool `json:"write_zeroes"`
+	Flush           bool `json:"flush"`
+	Reset           bool `json:"reset"`
+	Compare         bool `json:"compare"`
+	CompareAndWrite bool `json:"compare_and_write"`
+	Abort           bool `json:"abort"`
+	NvmeAdmin       bool `json:"nvme_admin"`
+	NvmeIo          bool `json:"nvme_io"`
+}
+
+// bdevGetBdevsResult extends spdk.BdevGetBdevsResult with supported_io_types
+// and product name
+// TODO: remove once gospdk supports supported_io_types and product_name
+type bdevGetBdevsResult struct {
+	spdk.BdevGetBdevsResult
+	SupportedIoTypes *SupportedIoTypes `json:"supported_io_types,omitempty"`
+	ProductName      string            `json:"product_name,omitempty"`
+}
+
+func sendSupportedIoTypes(ctx context.Context, ioTypes *SupportedIoTypes) {
+	if ioTypes == nil {
+		return
+	}
+	data, err := json.Marshal(ioTypes)
+	if err != nil {
+		log.Printf("error: failed to marshal supported io types: %v", err)
+		return
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(SupportedIoTypesHeaderKey, string(data))); err != nil {
+		log.Printf("error: failed to send supported io types: %v", err)
+	}
+}
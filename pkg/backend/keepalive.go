@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// NvmeKeepAliveTimeoutMetadataKey is metadata key carrying keep-alive
+// timeout in milliseconds of remote controller connections, since
+// NvmeRemoteController has no such field. Set by client on create, applied
+// to paths of the controller and returned by server in header
+const NvmeKeepAliveTimeoutMetadataKey = "opi-nvme-keep-alive-timeout-ms"
+
+const (
+	// minNvmeKeepAliveTimeoutMs is the shortest keep-alive timeout accepted,
+	// shorter ones disconnect on ordinary network jitter
+	minNvmeKeepAliveTimeoutMs = 1000
+	// maxNvmeKeepAliveTimeoutMs is the longest keep-alive timeout accepted
+	maxNvmeKeepAliveTimeoutMs = 3600000
+)
+
+// nvmeKeepAliveTimeoutFromContext returns keep-alive timeout requested by
+// client or 0 to use SPDK default if omitted
+func nvmeKeepAliveTimeoutFromContext(ctx context.Context) (int, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(NvmeKeepAliveTimeoutMetadataKey)
+	if len(values) == 0 {
+		return 0, nil
+	}
+	timeout, err := strconv.Atoi(values[0])
+	if err != nil {
+		msg := fmt.Sprintf("invalid keep-alive timeout %q", values[0])
+		return 0, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if timeout < minNvmeKeepAliveTimeoutMs || timeout > maxNvmeKeepAliveTimeoutMs {
+		msg := fmt.Sprintf("keep-alive timeout %d ms is out of range [%d, %d]", timeout, minNvmeKeepAliveTimeoutMs, maxNvmeKeepAliveTimeoutMs)
+		return 0, status.Errorf(codes.InvalidArgument, msg)
+	}
+	return timeout, nil
+}
+
+func sendNvmeKeepAliveTimeout(ctx context.Context, timeout int) {
+	if timeout == 0 {
+		return
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(NvmeKeepAliveTimeoutMetadataKey, strconv.Itoa(timeout))); err != nil {
+		log.Printf("error: failed to send keep-alive timeout: %v", err)
+	}
+}
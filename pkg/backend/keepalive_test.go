@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestBackEnd_CreateNvmeRemoteControllerKeepAliveTimeout(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		timeout string
+		want    int
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid timeout": {
+			timeout: "10000",
+			want:    10000,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"no timeout": {
+			timeout: "",
+			want:    0,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"too short timeout": {
+			timeout: "999",
+			want:    0,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("keep-alive timeout %d ms is out of range [%d, %d]", 999, minNvmeKeepAliveTimeoutMs, maxNvmeKeepAliveTimeoutMs),
+		},
+		"too long timeout": {
+			timeout: "3600001",
+			want:    0,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("keep-alive timeout %d ms is out of range [%d, %d]", 3600001, minNvmeKeepAliveTimeoutMs, maxNvmeKeepAliveTimeoutMs),
+		},
+		"malformed timeout": {
+			timeout: "10s",
+			want:    0,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("invalid keep-alive timeout %q", "10s"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			ctx := testEnv.ctx
+			if tt.timeout != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, NvmeKeepAliveTimeoutMetadataKey, tt.timeout)
+			}
+			var header metadata.MD
+			request := &pb.CreateNvmeRemoteControllerRequest{NvmeRemoteController: &testNvmeCtrl, NvmeRemoteControllerId: testNvmeCtrlID}
+			_, err := testEnv.client.CreateNvmeRemoteController(ctx, request, grpc.Header(&header))
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if timeout := testEnv.opiSpdkServer.nvmeKeepAliveTimeouts[testNvmeCtrlName]; timeout != tt.want {
+				t.Error("persisted timeout: expected", tt.want, "received", timeout)
+			}
+			values := header.Get(NvmeKeepAliveTimeoutMetadataKey)
+			if tt.want != 0 && !reflect.DeepEqual(values, []string{tt.timeout}) {
+				t.Error("header timeout: expected", tt.timeout, "received", values)
+			}
+		})
+	}
+}
+
+func TestBackEnd_GetNvmeRemoteControllerKeepAliveTimeout(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	testEnv.opiSpdkServer.Volumes.NvmeControllers[testNvmeCtrlName] = utils.ProtoClone(&testNvmeCtrlWithName)
+	testEnv.opiSpdkServer.nvmeKeepAliveTimeouts[testNvmeCtrlName] = 15000
+
+	var header metadata.MD
+	request := &pb.GetNvmeRemoteControllerRequest{Name: testNvmeCtrlName}
+	if _, err := testEnv.client.GetNvmeRemoteController(testEnv.ctx, request, grpc.Header(&header)); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if values := header.Get(NvmeKeepAliveTimeoutMetadataKey); !reflect.DeepEqual(values, []string{"15000"}) {
+		t.Error("header timeout: expected", 15000, "received", values)
+	}
+}
+
+func TestBackEnd_CreateNvmePathKeepAliveTimeout(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{`{"id":%d,"error":{"code":0,"message":""},"result":["mytest"]}`})
+	defer testEnv.Close()
+	recorder := testEnv.recordSpdkParams()
+	testEnv.opiSpdkServer.Volumes.NvmeControllers[testNvmeCtrlName] = utils.ProtoClone(&testNvmeCtrlWithName)
+	testEnv.opiSpdkServer.nvmeKeepAliveTimeouts[testNvmeCtrlName] = 15000
+
+	request := &pb.CreateNvmePathRequest{Parent: testNvmeCtrlName, NvmePath: &testNvmePath, NvmePathId: testNvmePathID}
+	if _, err := testEnv.client.CreateNvmePath(testEnv.ctx, request); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	params := []string{`{"name":"opi-nvme8","trtype":"TCP","traddr":"127.0.0.1","hostnqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","adrfam":"IPV4","trsvcid":"4444","subnqn":"nqn.2016-06.io.spdk:cnode1","keep_alive_timeout_ms":15000}`}
+	if !reflect.DeepEqual(recorder.params, params) {
+		t.Error("spdk params: expected", params, "received", recorder.params)
+	}
+}
+
+func TestBackEnd_DeleteNvmeRemoteControllerKeepAliveTimeout(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	testEnv.opiSpdkServer.Volumes.NvmeControllers[testNvmeCtrlName] = utils.ProtoClone(&testNvmeCtrlWithName)
+	testEnv.opiSpdkServer.nvmeKeepAliveTimeouts[testNvmeCtrlName] = 15000
+
+	request := &pb.DeleteNvmeRemoteControllerRequest{Name: testNvmeCtrlName}
+	if _, err := testEnv.client.DeleteNvmeRemoteController(testEnv.ctx, request); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if _, ok := testEnv.opiSpdkServer.nvmeKeepAliveTimeouts[testNvmeCtrlName]; ok {
+		t.Error("expected keep-alive timeout of deleted controller to be released")
+	}
+}
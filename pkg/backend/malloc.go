@@ -10,7 +10,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"path"
 	"sort"
 
 	"github.com/opiproject/gospdk/spdk"
@@ -20,7 +19,6 @@ import (
 	"github.com/google/uuid"
 	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/fieldmask"
-	"go.einride.tech/aip/resourceid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -34,23 +32,45 @@ func sortMallocVolumes(volumes []*pb.MallocVolume) {
 
 // CreateMallocVolume creates a Malloc volume instance
 func (s *Server) CreateMallocVolume(ctx context.Context, in *pb.CreateMallocVolumeRequest) (*pb.MallocVolume, error) {
+	// 0 or omitted block size means use configured default
+	if in.MallocVolume != nil {
+		applyDefaultBlockSize(&in.MallocVolume.BlockSize, s.blockSizes.Malloc)
+	}
 	// check input correctness
 	if err := s.validateCreateMallocVolumeRequest(in); err != nil {
 		return nil, err
 	}
+	ttl, err := ttlFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	annotations, err := s.annotationsFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	// see https://google.aip.dev/133#user-specified-ids
-	resourceID := resourceid.NewSystemGenerated()
+	resourceID, err := s.idempotencyKeys.ResourceID(ctx, "MallocVolume", in.MallocVolumeId)
+	if err != nil {
+		return nil, err
+	}
 	if in.MallocVolumeId != "" {
 		log.Printf("client provided the ID of a resource %v, ignoring the name field %v", in.MallocVolumeId, in.MallocVolume.Name)
-		resourceID = in.MallocVolumeId
 	}
 	in.MallocVolume.Name = utils.ResourceIDToVolumeName(resourceID)
+	unlock := s.resourceLocks.Lock(in.MallocVolume.Name)
+	defer unlock()
 	// idempotent API when called with same key, should return same object
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.MallocVolumes[in.MallocVolume.Name]
+	s.mapsMu.RUnlock()
 	if ok {
 		log.Printf("Already existing MallocVolume with id %v", in.MallocVolume.Name)
+		sendAnnotations(ctx, s.Annotations(resourceID))
 		return volume, nil
 	}
+	if err := s.checkVolumeUUIDFree(ctx, in.MallocVolume.Uuid); err != nil {
+		return nil, err
+	}
 	// not found, so create a new one
 	params := spdk.BdevMallocCreateParams{
 		Name:         resourceID,
@@ -58,9 +78,13 @@ func (s *Server) CreateMallocVolume(ctx context.Context, in *pb.CreateMallocVolu
 		NumBlocks:    int(in.GetMallocVolume().GetBlocksCount()),
 		MdSize:       int(in.GetMallocVolume().GetMetadataSize()),
 		MdInterleave: true,
+		UUID:         in.GetMallocVolume().GetUuid(),
+	}
+	if utils.SkipSpdkCallInDryRun(ctx, "bdev_malloc_create", &params) {
+		return utils.ProtoClone(in.MallocVolume), nil
 	}
 	var result spdk.BdevMallocCreateResult
-	err := s.rpc.Call(ctx, "bdev_malloc_create", &params, &result)
+	err = s.rpc.Call(ctx, "bdev_malloc_create", &params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -70,7 +94,12 @@ func (s *Server) CreateMallocVolume(ctx context.Context, in *pb.CreateMallocVolu
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
 	response := utils.ProtoClone(in.MallocVolume)
+	s.mapsMu.Lock()
 	s.Volumes.MallocVolumes[in.MallocVolume.Name] = response
+	s.mapsMu.Unlock()
+	s.setExpiry(in.MallocVolume.Name, ttl)
+	s.setAnnotations(resourceID, annotations)
+	sendAnnotations(ctx, annotations)
 	return response, nil
 }
 
@@ -80,8 +109,12 @@ func (s *Server) DeleteMallocVolume(ctx context.Context, in *pb.DeleteMallocVolu
 	if err := s.validateDeleteMallocVolumeRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.MallocVolumes[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			return &emptypb.Empty{}, nil
@@ -89,10 +122,13 @@ func (s *Server) DeleteMallocVolume(ctx context.Context, in *pb.DeleteMallocVolu
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	params := spdk.BdevMallocDeleteParams{
 		Name: resourceID,
 	}
+	if utils.SkipSpdkCallInDryRun(ctx, "bdev_malloc_delete", &params) {
+		return &emptypb.Empty{}, nil
+	}
 	var result spdk.BdevMallocDeleteResult
 	err := s.rpc.Call(ctx, "bdev_malloc_delete", &params, &result)
 	if err != nil {
@@ -103,7 +139,12 @@ func (s *Server) DeleteMallocVolume(ctx context.Context, in *pb.DeleteMallocVolu
 		msg := fmt.Sprintf("Could not delete Malloc Dev: %s", params.Name)
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
+	s.mapsMu.Lock()
 	delete(s.Volumes.MallocVolumes, volume.Name)
+	s.mapsMu.Unlock()
+	s.clearExpiry(volume.Name)
+	s.clearAnnotations(resourceID)
+	s.clearHistogram(resourceID)
 	return &emptypb.Empty{}, nil
 }
 
@@ -113,13 +154,17 @@ func (s *Server) UpdateMallocVolume(ctx context.Context, in *pb.UpdateMallocVolu
 	if err := s.validateUpdateMallocVolumeRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.MallocVolume.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.MallocVolumes[in.MallocVolume.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			log.Printf("Got AllowMissing, create a new resource, don't return error when resource not found")
 			params := spdk.BdevMallocCreateParams{
-				Name:      path.Base(in.MallocVolume.Name),
+				Name:      utils.ResourceNameToID(in.MallocVolume.Name),
 				BlockSize: int(in.GetMallocVolume().GetBlockSize()),
 				NumBlocks: int(in.GetMallocVolume().GetBlocksCount()),
 			}
@@ -134,13 +179,15 @@ func (s *Server) UpdateMallocVolume(ctx context.Context, in *pb.UpdateMallocVolu
 				return nil, status.Errorf(codes.InvalidArgument, msg)
 			}
 			response := utils.ProtoClone(in.MallocVolume)
+			s.mapsMu.Lock()
 			s.Volumes.MallocVolumes[in.MallocVolume.Name] = response
+			s.mapsMu.Unlock()
 			return response, nil
 		}
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.MallocVolume.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	// update_mask = 2
 	if err := fieldmask.Validate(in.UpdateMask, in.MallocVolume); err != nil {
 		return nil, err
@@ -174,7 +221,9 @@ func (s *Server) UpdateMallocVolume(ctx context.Context, in *pb.UpdateMallocVolu
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
 	response := utils.ProtoClone(in.MallocVolume)
+	s.mapsMu.Lock()
 	s.Volumes.MallocVolumes[in.MallocVolume.Name] = response
+	s.mapsMu.Unlock()
 	return response, nil
 }
 
@@ -200,13 +249,14 @@ func (s *Server) ListMallocVolumes(ctx context.Context, in *pb.ListMallocVolumes
 	result, hasMoreElements := utils.LimitPagination(result, offset, size)
 	if hasMoreElements {
 		token = uuid.New().String()
-		s.Pagination[token] = offset + size
+		s.Pagination.Set(token, offset+size)
 	}
 	Blobarray := make([]*pb.MallocVolume, len(result))
 	for i := range result {
 		r := &result[i]
 		Blobarray[i] = &pb.MallocVolume{Name: r.Name, Uuid: r.UUID, BlockSize: r.BlockSize, BlocksCount: r.NumBlocks}
 	}
+	Blobarray, token = utils.LimitPaginationBySize(Blobarray, offset, token, s.Pagination)
 	sortMallocVolumes(Blobarray)
 	return &pb.ListMallocVolumesResponse{MallocVolumes: Blobarray, NextPageToken: token}, nil
 }
@@ -218,12 +268,14 @@ func (s *Server) GetMallocVolume(ctx context.Context, in *pb.GetMallocVolumeRequ
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.MallocVolumes[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	params := spdk.BdevGetBdevsParams{
 		Name: resourceID,
 	}
@@ -237,6 +289,7 @@ func (s *Server) GetMallocVolume(ctx context.Context, in *pb.GetMallocVolumeRequ
 		msg := fmt.Sprintf("expecting exactly 1 result, got %d", len(result))
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
+	sendAnnotations(ctx, s.Annotations(resourceID))
 	return &pb.MallocVolume{Name: result[0].Name, Uuid: result[0].UUID, BlockSize: result[0].BlockSize, BlocksCount: result[0].NumBlocks}, nil
 }
 
@@ -247,12 +300,14 @@ func (s *Server) StatsMallocVolume(ctx context.Context, in *pb.StatsMallocVolume
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.MallocVolumes[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	params := spdk.BdevGetIostatParams{
 		Name: resourceID,
 	}
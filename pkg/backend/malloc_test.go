@@ -7,10 +7,16 @@
 package backend
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/opiproject/gospdk/spdk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/fieldmaskpb"
@@ -113,6 +119,33 @@ func TestBackEnd_CreateMallocVolume(t *testing.T) {
 			errMsg:  "",
 			exist:   true,
 		},
+		"omitted block size uses default": {
+			id:      testMallocVolumeID,
+			in:      &pb.MallocVolume{BlocksCount: 64},
+			out:     &testMallocVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"supplied block size overrides default": {
+			id:      testMallocVolumeID,
+			in:      &pb.MallocVolume{BlockSize: 4096, BlocksCount: 64},
+			out:     &pb.MallocVolume{BlockSize: 4096, BlocksCount: 64},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"unsupported block size": {
+			id:      testMallocVolumeID,
+			in:      &pb.MallocVolume{BlockSize: 1000, BlocksCount: 64},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("unsupported block size %d, supported sizes are %v", 1000, supportedBlockSizes),
+			exist:   false,
+		},
 		"no required field": {
 			id:      testAioVolumeID,
 			in:      nil,
@@ -493,7 +526,7 @@ func TestBackEnd_ListMallocVolumes(t *testing.T) {
 			testEnv := createTestEnvironment(tt.spdk)
 			defer testEnv.Close()
 
-			testEnv.opiSpdkServer.Pagination["existing-pagination-token"] = 1
+			testEnv.opiSpdkServer.Pagination.Set("existing-pagination-token", 1)
 
 			request := &pb.ListMallocVolumesRequest{PageSize: tt.size, PageToken: tt.token}
 			response, err := testEnv.client.ListMallocVolumes(testEnv.ctx, request)
@@ -843,3 +876,95 @@ func TestBackEnd_DeleteMallocVolume(t *testing.T) {
 		})
 	}
 }
+
+type stubJSONRRPC struct {
+	methods []string
+	bdevs   []spdk.BdevGetBdevsResult
+}
+
+// build time check that struct implements interface
+var _ spdk.JSONRPC = (*stubJSONRRPC)(nil)
+
+func (s *stubJSONRRPC) GetID() uint64 {
+	return 0
+}
+
+func (s *stubJSONRRPC) StartUnixListener() net.Listener {
+	return nil
+}
+
+func (s *stubJSONRRPC) GetVersion(_ context.Context) string {
+	return ""
+}
+
+func (s *stubJSONRRPC) Call(_ context.Context, method string, _ interface{}, result interface{}) error {
+	s.methods = append(s.methods, method)
+	if bdevs, ok := result.(*[]spdk.BdevGetBdevsResult); ok {
+		*bdevs = s.bdevs
+	}
+	return nil
+}
+
+func TestBackEnd_MallocVolumeDryRun(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		call        func(context.Context, *testEnv, grpc.CallOption) error
+		existBefore bool
+		existAfter  bool
+		method      string
+	}{
+		"create": {
+			call: func(ctx context.Context, env *testEnv, opt grpc.CallOption) error {
+				request := &pb.CreateMallocVolumeRequest{MallocVolume: &testMallocVolume, MallocVolumeId: testMallocVolumeID}
+				response, err := env.client.CreateMallocVolume(ctx, request, opt)
+				if !proto.Equal(response, &testMallocVolumeWithName) {
+					t.Error("response: expected", &testMallocVolumeWithName, "received", response)
+				}
+				return err
+			},
+			existBefore: false,
+			existAfter:  false,
+			method:      "bdev_malloc_create",
+		},
+		"delete": {
+			call: func(ctx context.Context, env *testEnv, opt grpc.CallOption) error {
+				request := &pb.DeleteMallocVolumeRequest{Name: testMallocVolumeName}
+				_, err := env.client.DeleteMallocVolume(ctx, request, opt)
+				return err
+			},
+			existBefore: true,
+			existAfter:  true,
+			method:      "bdev_malloc_delete",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+			stub := &stubJSONRRPC{}
+			testEnv.opiSpdkServer.rpc = stub
+			if tt.existBefore {
+				testEnv.opiSpdkServer.Volumes.MallocVolumes[testMallocVolumeName] = utils.ProtoClone(&testMallocVolumeWithName)
+			}
+
+			var header metadata.MD
+			ctx := metadata.AppendToOutgoingContext(testEnv.ctx, utils.DryRunMetadataKey, "true")
+			if err := tt.call(ctx, testEnv, grpc.Header(&header)); err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+
+			if len(stub.methods) != 0 {
+				t.Error("expected no SPDK calls, received", stub.methods)
+			}
+			_, ok := testEnv.opiSpdkServer.Volumes.MallocVolumes[testMallocVolumeName]
+			if ok != tt.existAfter {
+				t.Error("expect volume exist", tt.existAfter, "received", ok)
+			}
+			calls := header.Get(utils.DryRunHeaderKey)
+			if len(calls) != 1 || !strings.Contains(calls[0], `"method":"`+tt.method+`"`) {
+				t.Error("dry run calls: expected", tt.method, "received", calls)
+			}
+		})
+	}
+}
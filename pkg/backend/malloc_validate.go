@@ -11,21 +11,22 @@ import (
 	"go.einride.tech/aip/resourcename"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
 
 func (s *Server) validateCreateMallocVolumeRequest(in *pb.CreateMallocVolumeRequest) error {
+	v := &utils.Validator{}
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
-	}
+	v.CheckRequiredFields(in)
 	// see https://google.aip.dev/133#user-specified-ids
 	if in.MallocVolumeId != "" {
-		if err := resourceid.ValidateUserSettable(in.MallocVolumeId); err != nil {
-			return err
-		}
+		v.Check("malloc_volume_id", resourceid.ValidateUserSettable(in.MallocVolumeId))
+	}
+	if in.MallocVolume != nil {
+		v.Check("malloc_volume.block_size", validateBlockSize(in.MallocVolume.BlockSize))
 	}
-	// TODO: validate also: block_size, blocks_count, md_size, uuid
-	return nil
+	// TODO: validate also: blocks_count, md_size, uuid
+	return v.Err()
 }
 
 func (s *Server) validateDeleteMallocVolumeRequest(in *pb.DeleteMallocVolumeRequest) error {
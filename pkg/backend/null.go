@@ -9,7 +9,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"path"
 	"sort"
 
 	"github.com/opiproject/gospdk/spdk"
@@ -19,7 +18,6 @@ import (
 	"github.com/google/uuid"
 	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/fieldmask"
-	"go.einride.tech/aip/resourceid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -31,33 +29,84 @@ func sortNullVolumes(volumes []*pb.NullVolume) {
 	})
 }
 
+// bdevNullCreateParams extends SPDK create parameters with UUID and NUMA
+// placement hint
+type bdevNullCreateParams struct {
+	spdk.BdevNullCreateParams
+	UUID   string `json:"uuid,omitempty"`
+	NumaID *int32 `json:"numa_id,omitempty"`
+}
+
 // CreateNullVolume creates a Null volume instance
 func (s *Server) CreateNullVolume(ctx context.Context, in *pb.CreateNullVolumeRequest) (*pb.NullVolume, error) {
+	// 0 or omitted block size means use configured default
+	if in.NullVolume != nil {
+		applyDefaultBlockSize(&in.NullVolume.BlockSize, s.blockSizes.Null)
+	}
 	// check input correctness
 	if err := s.validateCreateNullVolumeRequest(in); err != nil {
 		return nil, err
 	}
+	numaNode, err := s.numaNodeFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	qosProfile, err := s.qosProfileFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := ttlFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	annotations, err := s.annotationsFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	// see https://google.aip.dev/133#user-specified-ids
-	resourceID := resourceid.NewSystemGenerated()
+	resourceID, err := s.idempotencyKeys.ResourceID(ctx, "NullVolume", in.NullVolumeId)
+	if err != nil {
+		return nil, err
+	}
 	if in.NullVolumeId != "" {
 		log.Printf("client provided the ID of a resource %v, ignoring the name field %v", in.NullVolumeId, in.NullVolume.Name)
-		resourceID = in.NullVolumeId
 	}
 	in.NullVolume.Name = utils.ResourceIDToVolumeName(resourceID)
+	unlock := s.resourceLocks.Lock(in.NullVolume.Name)
+	defer unlock()
 	// idempotent API when called with same key, should return same object
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.NullVolumes[in.NullVolume.Name]
+	s.mapsMu.RUnlock()
 	if ok {
 		log.Printf("Already existing NullVolume with id %v", in.NullVolume.Name)
+		sendQosProfile(ctx, s.appliedQosProfile(volume.Name))
+		sendAnnotations(ctx, s.Annotations(resourceID))
 		return volume, nil
 	}
+	// generate UUID if omitted, so that the stored volume records it
+	in.NullVolume.Uuid, err = utils.EnsureUUID(in.NullVolume.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeUUIDFree(ctx, in.NullVolume.Uuid); err != nil {
+		return nil, err
+	}
 	// not found, so create a new one
-	params := spdk.BdevNullCreateParams{
-		Name:      resourceID,
-		BlockSize: int(in.GetNullVolume().GetBlockSize()),
-		NumBlocks: int(in.GetNullVolume().GetBlocksCount()),
+	params := bdevNullCreateParams{
+		BdevNullCreateParams: spdk.BdevNullCreateParams{
+			Name:      resourceID,
+			BlockSize: int(in.GetNullVolume().GetBlockSize()),
+			NumBlocks: int(in.GetNullVolume().GetBlocksCount()),
+		},
+		UUID:   in.NullVolume.Uuid,
+		NumaID: numaNode,
+	}
+	if utils.SkipSpdkCallInDryRun(ctx, "bdev_null_create", &params) {
+		return utils.ProtoClone(in.NullVolume), nil
 	}
 	var result spdk.BdevNullCreateResult
-	err := s.rpc.Call(ctx, "bdev_null_create", &params, &result)
+	err = s.rpc.Call(ctx, "bdev_null_create", &params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -66,8 +115,21 @@ func (s *Server) CreateNullVolume(ctx context.Context, in *pb.CreateNullVolumeRe
 		msg := fmt.Sprintf("Could not create Null Dev: %s", params.Name)
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
+	if err := s.applyQosProfile(ctx, resourceID, qosProfile); err != nil {
+		s.rollbackBdevCreate(ctx, "bdev_null_delete", resourceID)
+		return nil, err
+	}
 	response := utils.ProtoClone(in.NullVolume)
+	s.mapsMu.Lock()
 	s.Volumes.NullVolumes[in.NullVolume.Name] = response
+	if qosProfile != nil {
+		s.qosProfiles[in.NullVolume.Name] = qosProfile
+	}
+	s.mapsMu.Unlock()
+	s.setExpiry(in.NullVolume.Name, ttl)
+	sendQosProfile(ctx, qosProfile)
+	s.setAnnotations(resourceID, annotations)
+	sendAnnotations(ctx, annotations)
 	return response, nil
 }
 
@@ -77,8 +139,12 @@ func (s *Server) DeleteNullVolume(ctx context.Context, in *pb.DeleteNullVolumeRe
 	if err := s.validateDeleteNullVolumeRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.NullVolumes[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			return &emptypb.Empty{}, nil
@@ -86,10 +152,13 @@ func (s *Server) DeleteNullVolume(ctx context.Context, in *pb.DeleteNullVolumeRe
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	params := spdk.BdevNullDeleteParams{
 		Name: resourceID,
 	}
+	if utils.SkipSpdkCallInDryRun(ctx, "bdev_null_delete", &params) {
+		return &emptypb.Empty{}, nil
+	}
 	var result spdk.BdevNullDeleteResult
 	err := s.rpc.Call(ctx, "bdev_null_delete", &params, &result)
 	if err != nil {
@@ -100,7 +169,13 @@ func (s *Server) DeleteNullVolume(ctx context.Context, in *pb.DeleteNullVolumeRe
 		msg := fmt.Sprintf("Could not delete Null Dev: %s", params.Name)
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
+	s.mapsMu.Lock()
 	delete(s.Volumes.NullVolumes, volume.Name)
+	delete(s.qosProfiles, volume.Name)
+	s.mapsMu.Unlock()
+	s.clearExpiry(volume.Name)
+	s.clearAnnotations(resourceID)
+	s.clearHistogram(resourceID)
 	return &emptypb.Empty{}, nil
 }
 
@@ -110,13 +185,17 @@ func (s *Server) UpdateNullVolume(ctx context.Context, in *pb.UpdateNullVolumeRe
 	if err := s.validateUpdateNullVolumeRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.NullVolume.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.NullVolumes[in.NullVolume.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			log.Printf("Got AllowMissing, create a new resource, don't return error when resource not found")
 			params := spdk.BdevNullCreateParams{
-				Name:      path.Base(in.NullVolume.Name),
+				Name:      utils.ResourceNameToID(in.NullVolume.Name),
 				BlockSize: int(in.GetNullVolume().GetBlockSize()),
 				NumBlocks: int(in.GetNullVolume().GetBlocksCount()),
 			}
@@ -131,13 +210,15 @@ func (s *Server) UpdateNullVolume(ctx context.Context, in *pb.UpdateNullVolumeRe
 				return nil, status.Errorf(codes.InvalidArgument, msg)
 			}
 			response := utils.ProtoClone(in.NullVolume)
+			s.mapsMu.Lock()
 			s.Volumes.NullVolumes[in.NullVolume.Name] = response
+			s.mapsMu.Unlock()
 			return response, nil
 		}
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.NullVolume.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	// update_mask = 2
 	if err := fieldmask.Validate(in.UpdateMask, in.NullVolume); err != nil {
 		return nil, err
@@ -170,8 +251,14 @@ func (s *Server) UpdateNullVolume(ctx context.Context, in *pb.UpdateNullVolumeRe
 		msg := fmt.Sprintf("Could not create Null Dev: %s", params2.Name)
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
+	// recreated bdev has no limits, so restore the ones applied on create
+	if err := s.applyQosProfile(ctx, resourceID, s.appliedQosProfile(volume.Name)); err != nil {
+		return nil, err
+	}
 	response := utils.ProtoClone(in.NullVolume)
+	s.mapsMu.Lock()
 	s.Volumes.NullVolumes[in.NullVolume.Name] = response
+	s.mapsMu.Unlock()
 	return response, nil
 }
 
@@ -197,13 +284,14 @@ func (s *Server) ListNullVolumes(ctx context.Context, in *pb.ListNullVolumesRequ
 	result, hasMoreElements := utils.LimitPagination(result, offset, size)
 	if hasMoreElements {
 		token = uuid.New().String()
-		s.Pagination[token] = offset + size
+		s.Pagination.Set(token, offset+size)
 	}
 	Blobarray := make([]*pb.NullVolume, len(result))
 	for i := range result {
 		r := &result[i]
 		Blobarray[i] = &pb.NullVolume{Name: r.Name, Uuid: r.UUID, BlockSize: r.BlockSize, BlocksCount: r.NumBlocks}
 	}
+	Blobarray, token = utils.LimitPaginationBySize(Blobarray, offset, token, s.Pagination)
 	sortNullVolumes(Blobarray)
 	return &pb.ListNullVolumesResponse{NullVolumes: Blobarray, NextPageToken: token}, nil
 }
@@ -215,16 +303,18 @@ func (s *Server) GetNullVolume(ctx context.Context, in *pb.GetNullVolumeRequest)
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.NullVolumes[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	params := spdk.BdevGetBdevsParams{
 		Name: resourceID,
 	}
-	var result []spdk.BdevGetBdevsResult
+	var result []bdevGetBdevsResult
 	err := s.rpc.Call(ctx, "bdev_get_bdevs", &params, &result)
 	if err != nil {
 		return nil, err
@@ -234,6 +324,9 @@ func (s *Server) GetNullVolume(ctx context.Context, in *pb.GetNullVolumeRequest)
 		msg := fmt.Sprintf("expecting exactly 1 result, got %d", len(result))
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
+	sendSupportedIoTypes(ctx, result[0].SupportedIoTypes)
+	sendQosProfile(ctx, s.appliedQosProfile(volume.Name))
+	sendAnnotations(ctx, s.Annotations(resourceID))
 	return &pb.NullVolume{Name: result[0].Name, Uuid: result[0].UUID, BlockSize: result[0].BlockSize, BlocksCount: result[0].NumBlocks}, nil
 }
 
@@ -244,12 +337,14 @@ func (s *Server) StatsNullVolume(ctx context.Context, in *pb.StatsNullVolumeRequ
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.NullVolumes[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	params := spdk.BdevGetIostatParams{
 		Name: resourceID,
 	}
@@ -6,6 +6,7 @@
 package backend
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"testing"
@@ -14,7 +15,10 @@ import (
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/fieldmaskpb"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
@@ -27,11 +31,13 @@ var (
 	testNullVolume     = pb.NullVolume{
 		BlockSize:   512,
 		BlocksCount: 64,
+		Uuid:        "3f2a6c1e-5b7d-4e8f-9a0b-1c2d3e4f5a6b",
 	}
 	testNullVolumeWithName = pb.NullVolume{
 		Name:        testNullVolumeName,
 		BlockSize:   testNullVolume.BlockSize,
 		BlocksCount: testNullVolume.BlocksCount,
+		Uuid:        testNullVolume.Uuid,
 	}
 )
 
@@ -109,6 +115,33 @@ func TestBackEnd_CreateNullVolume(t *testing.T) {
 			errMsg:  "",
 			exist:   true,
 		},
+		"omitted block size uses default": {
+			id:      testNullVolumeID,
+			in:      &pb.NullVolume{BlocksCount: 64, Uuid: testNullVolume.Uuid},
+			out:     &testNullVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"supplied block size overrides default": {
+			id:      testNullVolumeID,
+			in:      &pb.NullVolume{BlockSize: 4096, BlocksCount: 64, Uuid: testNullVolume.Uuid},
+			out:     &pb.NullVolume{BlockSize: 4096, BlocksCount: 64, Uuid: testNullVolume.Uuid},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"unsupported block size": {
+			id:      testNullVolumeID,
+			in:      &pb.NullVolume{BlockSize: 1000, BlocksCount: 64},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("unsupported block size %d, supported sizes are %v", 1000, supportedBlockSizes),
+			exist:   false,
+		},
 		"no required field": {
 			id:      testAioVolumeID,
 			in:      nil,
@@ -155,6 +188,188 @@ func TestBackEnd_CreateNullVolume(t *testing.T) {
 	}
 }
 
+func TestBackEnd_CreateNullVolumeIdempotencyKey(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":""}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`,
+	})
+	defer testEnv.Close()
+	recorder := testEnv.recordSpdkParams()
+	ctx := metadata.AppendToOutgoingContext(testEnv.ctx, utils.IdempotencyKeyMetadataKey, "retry-1")
+
+	// first attempt fails, so the retry creates the volume under the same ID
+	_, err := testEnv.client.CreateNullVolume(ctx, &pb.CreateNullVolumeRequest{NullVolume: &testNullVolume})
+	if err == nil {
+		t.Fatal("expected first create to fail")
+	}
+	first, err := testEnv.client.CreateNullVolume(ctx, &pb.CreateNullVolumeRequest{NullVolume: &testNullVolume})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	retried, err := testEnv.client.CreateNullVolume(ctx, &pb.CreateNullVolumeRequest{NullVolume: &testNullVolume})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	if !proto.Equal(retried, first) {
+		t.Error("response: expected", first, "received", retried)
+	}
+	if len(testEnv.opiSpdkServer.Volumes.NullVolumes) != 1 {
+		t.Error("expected exactly 1 volume, received", testEnv.opiSpdkServer.Volumes.NullVolumes)
+	}
+	if len(recorder.params) != 2 || recorder.params[0] != recorder.params[1] {
+		t.Error("expected both SPDK calls with the same params, received", recorder.params)
+	}
+}
+
+func TestBackEnd_CreateNullVolumeValidationErrors(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	illegalIDMsg := fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0")
+	blockSizeMsg := fmt.Sprintf("unsupported block size %d, supported sizes are %v", 1000, supportedBlockSizes)
+	tests := map[string]struct {
+		id      string
+		in      *pb.NullVolume
+		errCode codes.Code
+		errMsg  string
+		fields  []string
+	}{
+		"single violation": {
+			id:      testNullVolumeID,
+			in:      &pb.NullVolume{BlockSize: 1000, BlocksCount: 64},
+			errCode: codes.InvalidArgument,
+			errMsg:  blockSizeMsg,
+			fields:  []string{"null_volume.block_size"},
+		},
+		"illegal resource_id and unsupported block size": {
+			id:      "CapitalLettersNotAllowed",
+			in:      &pb.NullVolume{BlockSize: 1000, BlocksCount: 64},
+			errCode: codes.InvalidArgument,
+			errMsg:  illegalIDMsg + "; " + blockSizeMsg,
+			fields:  []string{"null_volume_id", "null_volume.block_size"},
+		},
+		"missing blocks count and illegal resource_id": {
+			id:      "CapitalLettersNotAllowed",
+			in:      &pb.NullVolume{BlockSize: 512},
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: null_volume.blocks_count; " + illegalIDMsg,
+			fields:  []string{"null_volume.blocks_count", "null_volume_id"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			request := &pb.CreateNullVolumeRequest{NullVolume: tt.in, NullVolumeId: tt.id}
+			_, err := testEnv.client.CreateNullVolume(testEnv.ctx, request)
+
+			er, ok := status.FromError(err)
+			if !ok {
+				t.Fatal("expected grpc error status")
+			}
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			var fields []string
+			for _, detail := range er.Details() {
+				if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+					for _, violation := range badRequest.FieldViolations {
+						fields = append(fields, violation.Field)
+					}
+				}
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Error("violated fields: expected", tt.fields, "received", fields)
+			}
+		})
+	}
+}
+
+func TestBackEnd_CreateNullVolumeNumaNode(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		numaNode string
+		spdk     []string
+		params   []string
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"no numa node hint": {
+			numaNode: "",
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			params:   []string{`{"block_size":512,"num_blocks":64,"name":"mytest","uuid":"3f2a6c1e-5b7d-4e8f-9a0b-1c2d3e4f5a6b"}`},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"valid numa node hint": {
+			numaNode: "1",
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			params:   []string{`{"block_size":512,"num_blocks":64,"name":"mytest","uuid":"3f2a6c1e-5b7d-4e8f-9a0b-1c2d3e4f5a6b","numa_id":1}`},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"numa node out of range": {
+			numaNode: "2",
+			spdk:     []string{},
+			params:   nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   "numa node 2 is out of range, host has 2 node(s)",
+		},
+		"negative numa node": {
+			numaNode: "-1",
+			spdk:     []string{},
+			params:   nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   "numa node -1 is out of range, host has 2 node(s)",
+		},
+		"not a number numa node": {
+			numaNode: "first",
+			spdk:     []string{},
+			params:   nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("invalid numa node %q", "first"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			testEnv.opiSpdkServer.numaNodeCount = func() int { return 2 }
+			recorder := testEnv.recordSpdkParams()
+
+			ctx := testEnv.ctx
+			if tt.numaNode != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, NumaNodeMetadataKey, tt.numaNode)
+			}
+			request := &pb.CreateNullVolumeRequest{NullVolume: &testNullVolume, NullVolumeId: testNullVolumeID}
+			_, err := testEnv.client.CreateNullVolume(ctx, request)
+
+			if !reflect.DeepEqual(recorder.params, tt.params) {
+				t.Error("spdk params: expected", tt.params, "received", recorder.params)
+			}
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+		})
+	}
+}
+
 func TestBackEnd_UpdateNullVolume(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
 
@@ -492,7 +707,7 @@ func TestBackEnd_ListNullVolumes(t *testing.T) {
 			testEnv := createTestEnvironment(tt.spdk)
 			defer testEnv.Close()
 
-			testEnv.opiSpdkServer.Pagination["existing-pagination-token"] = 1
+			testEnv.opiSpdkServer.Pagination.Set("existing-pagination-token", 1)
 
 			request := &pb.ListNullVolumesRequest{PageSize: tt.size, PageToken: tt.token}
 			response, err := testEnv.client.ListNullVolumes(testEnv.ctx, request)
@@ -540,7 +755,7 @@ func TestBackEnd_GetNullVolume(t *testing.T) {
 			out:     nil,
 			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":false}`},
 			errCode: codes.Unknown,
-			errMsg:  fmt.Sprintf("bdev_get_bdevs: %v", "json: cannot unmarshal bool into Go value of type []spdk.BdevGetBdevsResult"),
+			errMsg:  fmt.Sprintf("bdev_get_bdevs: %v", "json: cannot unmarshal bool into Go value of type []backend.bdevGetBdevsResult"),
 		},
 		"valid request with empty SPDK response": {
 			in:      testNullVolumeName,
@@ -627,6 +842,65 @@ func TestBackEnd_GetNullVolume(t *testing.T) {
 	}
 }
 
+func TestBackEnd_GetNullVolumeSupportedIoTypes(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		spdk    []string
+		ioTypes *SupportedIoTypes
+	}{
+		"supported io types reported by SPDK": {
+			spdk: []string{`{"jsonrpc":"2.0","id":%d,"result":[{"name":"Null0","block_size":512,"num_blocks":131072,"uuid":"88112c76-8c49-4395-955a-0d695b1d2099","supported_io_types":{"read":true,"write":true,"unmap":false,"write_zeroes":true,"flush":false,"reset":true,"compare":false,"compare_and_write":false,"abort":true,"nvme_admin":false,"nvme_io":false}}]}`},
+			ioTypes: &SupportedIoTypes{
+				Read:        true,
+				Write:       true,
+				Unmap:       false,
+				WriteZeroes: true,
+				Flush:       false,
+				Reset:       true,
+				Abort:       true,
+			},
+		},
+		"supported io types omitted by SPDK": {
+			spdk:    []string{`{"jsonrpc":"2.0","id":%d,"result":[{"name":"Null0","block_size":512,"num_blocks":131072,"uuid":"88112c76-8c49-4395-955a-0d695b1d2099"}]}`},
+			ioTypes: nil,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.Volumes.NullVolumes[testNullVolumeName] = utils.ProtoClone(&testNullVolumeWithName)
+
+			var header metadata.MD
+			request := &pb.GetNullVolumeRequest{Name: testNullVolumeName}
+			_, err := testEnv.client.GetNullVolume(testEnv.ctx, request, grpc.Header(&header))
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+
+			values := header.Get(SupportedIoTypesHeaderKey)
+			if tt.ioTypes == nil {
+				if len(values) != 0 {
+					t.Error("expected no supported io types, received", values)
+				}
+				return
+			}
+			if len(values) != 1 {
+				t.Fatal("expected supported io types in header, received", values)
+			}
+			ioTypes := &SupportedIoTypes{}
+			if err := json.Unmarshal([]byte(values[0]), ioTypes); err != nil {
+				t.Fatal(err)
+			}
+			if *ioTypes != *tt.ioTypes {
+				t.Error("supported io types: expected", tt.ioTypes, "received", ioTypes)
+			}
+		})
+	}
+}
+
 func TestBackEnd_StatsNullVolume(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
 	tests := map[string]struct {
@@ -842,3 +1116,31 @@ func TestBackEnd_DeleteNullVolume(t *testing.T) {
 		})
 	}
 }
+
+func TestBackEnd_DeleteNullVolumeSpdkErrorDetails(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{`{"id":%d,"error":{"code":-19,"message":"No such device"},"result":null}`})
+	defer testEnv.Close()
+
+	testEnv.opiSpdkServer.Volumes.NullVolumes[testNullVolumeName] = utils.ProtoClone(&testNullVolumeWithName)
+
+	request := &pb.DeleteNullVolumeRequest{Name: testNullVolumeName}
+	_, err := testEnv.client.DeleteNullVolume(testEnv.ctx, request)
+
+	er, _ := status.FromError(err)
+	if er.Code() != codes.Unknown {
+		t.Error("error code: expected", codes.Unknown, "received", er.Code())
+	}
+	expectedMsg := "bdev_null_delete: json response error: No such device"
+	if er.Message() != expectedMsg {
+		t.Error("error message: expected", expectedMsg, "received", er.Message())
+	}
+	spdkErr, ok := utils.SpdkErrorFromStatus(er)
+	if !ok {
+		t.Fatal("expected SPDK error in status details, received", er.Details())
+	}
+	expected := &utils.SpdkError{Method: "bdev_null_delete", Code: -19, Message: "No such device"}
+	if !reflect.DeepEqual(spdkErr, expected) {
+		t.Error("SPDK error: expected", expected, "received", spdkErr)
+	}
+}
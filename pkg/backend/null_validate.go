@@ -10,21 +10,23 @@ import (
 	"go.einride.tech/aip/resourcename"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
 
 func (s *Server) validateCreateNullVolumeRequest(in *pb.CreateNullVolumeRequest) error {
+	v := &utils.Validator{}
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
-	}
+	v.CheckRequiredFields(in)
 	// see https://google.aip.dev/133#user-specified-ids
 	if in.NullVolumeId != "" {
-		if err := resourceid.ValidateUserSettable(in.NullVolumeId); err != nil {
-			return err
-		}
+		v.Check("null_volume_id", resourceid.ValidateUserSettable(in.NullVolumeId))
+	}
+	if in.NullVolume != nil {
+		v.Check("null_volume.block_size", validateBlockSize(in.NullVolume.BlockSize))
+		v.Check("null_volume.uuid", utils.ValidateUUID(in.NullVolume.Uuid))
 	}
-	// TODO: validate also: block_size, blocks_count, uuid
-	return nil
+	// TODO: validate also: blocks_count
+	return v.Err()
 }
 
 func (s *Server) validateDeleteNullVolumeRequest(in *pb.DeleteNullVolumeRequest) error {
@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// NumaNodeMetadataKey is request metadata key carrying NUMA node the volume
+// should be placed on, since volume protos have no such field
+const NumaNodeMetadataKey = "opi-numa-node"
+
+// numaNodeFromContext returns NUMA node requested for a volume or nil if
+// no placement hint was provided
+func (s *Server) numaNodeFromContext(ctx context.Context) (*int32, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(NumaNodeMetadataKey)
+	if len(values) == 0 {
+		return nil, nil
+	}
+	node, err := strconv.ParseInt(values[0], 10, 32)
+	if err != nil {
+		msg := fmt.Sprintf("invalid numa node %q", values[0])
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	nodeCount := s.numaNodeCount()
+	if node < 0 || node >= int64(nodeCount) {
+		msg := fmt.Sprintf("numa node %d is out of range, host has %d node(s)", node, nodeCount)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	numaNode := int32(node)
+	return &numaNode, nil
+}
@@ -7,8 +7,8 @@ package backend
 
 import (
 	"context"
+	"fmt"
 	"log"
-	"path"
 	"sort"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
@@ -17,8 +17,9 @@ import (
 	"github.com/google/uuid"
 	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/fieldmask"
-	"go.einride.tech/aip/resourceid"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
@@ -29,39 +30,98 @@ func sortNvmeRemoteControllers(controllers []*pb.NvmeRemoteController) {
 	})
 }
 
+// NvmeHostIDMetadataKey is metadata key carrying fabrics host ID of remote
+// controller, set by client on create and returned by server in header
+const NvmeHostIDMetadataKey = "opi-nvme-hostid"
+
 // CreateNvmeRemoteController creates an Nvme remote controller
-func (s *Server) CreateNvmeRemoteController(_ context.Context, in *pb.CreateNvmeRemoteControllerRequest) (*pb.NvmeRemoteController, error) {
+func (s *Server) CreateNvmeRemoteController(ctx context.Context, in *pb.CreateNvmeRemoteControllerRequest) (*pb.NvmeRemoteController, error) {
 	// check input correctness
 	if err := s.validateCreateNvmeRemoteControllerRequest(in); err != nil {
 		return nil, err
 	}
+	hostID, err := nvmeHostIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keepAliveTimeout, err := nvmeKeepAliveTimeoutFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	// see https://google.aip.dev/133#user-specified-ids
-	resourceID := resourceid.NewSystemGenerated()
+	resourceID, err := s.idempotencyKeys.ResourceID(ctx, "NvmeRemoteController", in.NvmeRemoteControllerId)
+	if err != nil {
+		return nil, err
+	}
 	if in.NvmeRemoteControllerId != "" {
 		log.Printf("client provided the ID of a resource %v, ignoring the name field %v", in.NvmeRemoteControllerId, in.NvmeRemoteController.Name)
-		resourceID = in.NvmeRemoteControllerId
 	}
 	in.NvmeRemoteController.Name = utils.ResourceIDToRemoteControllerName(resourceID)
+	unlock := s.resourceLocks.Lock(in.NvmeRemoteController.Name)
+	defer unlock()
 	// idempotent API when called with same key, should return same object
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.NvmeControllers[in.NvmeRemoteController.Name]
+	existingHostID := s.nvmeHostIDs[in.NvmeRemoteController.Name]
+	existingKeepAliveTimeout := s.nvmeKeepAliveTimeouts[in.NvmeRemoteController.Name]
+	s.mapsMu.RUnlock()
 	if ok {
 		log.Printf("Already existing NvmeRemoteController with id %v", in.NvmeRemoteController.Name)
+		s.sendNvmeHostID(ctx, existingHostID)
+		sendNvmeKeepAliveTimeout(ctx, existingKeepAliveTimeout)
 		return volume, nil
 	}
 	// not found, so create a new one
 	response := utils.ProtoClone(in.NvmeRemoteController)
+	if utils.DryRunRequested(ctx) {
+		return response, nil
+	}
+	s.mapsMu.Lock()
 	s.Volumes.NvmeControllers[in.NvmeRemoteController.Name] = response
+	s.nvmeHostIDs[in.NvmeRemoteController.Name] = hostID
+	if keepAliveTimeout != 0 {
+		s.nvmeKeepAliveTimeouts[in.NvmeRemoteController.Name] = keepAliveTimeout
+	}
+	s.mapsMu.Unlock()
+	s.sendNvmeHostID(ctx, hostID)
+	sendNvmeKeepAliveTimeout(ctx, keepAliveTimeout)
 	return response, nil
 }
 
+// nvmeHostIDFromContext returns host ID requested by client in canonical
+// form or generates a new one if it was omitted
+func nvmeHostIDFromContext(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(NvmeHostIDMetadataKey)
+	if len(values) == 0 {
+		return uuid.New().String(), nil
+	}
+	hostID, err := uuid.Parse(values[0])
+	if err != nil {
+		msg := fmt.Sprintf("invalid host id %q: %v", values[0], err)
+		return "", status.Errorf(codes.InvalidArgument, msg)
+	}
+	return hostID.String(), nil
+}
+
+func (s *Server) sendNvmeHostID(ctx context.Context, hostID string) {
+	if err := grpc.SetHeader(ctx, metadata.Pairs(NvmeHostIDMetadataKey, hostID)); err != nil {
+		log.Printf("error: failed to send host id: %v", err)
+	}
+}
+
 // DeleteNvmeRemoteController deletes an Nvme remote controller
-func (s *Server) DeleteNvmeRemoteController(_ context.Context, in *pb.DeleteNvmeRemoteControllerRequest) (*emptypb.Empty, error) {
+func (s *Server) DeleteNvmeRemoteController(ctx context.Context, in *pb.DeleteNvmeRemoteControllerRequest) (*emptypb.Empty, error) {
 	// check input correctness
 	if err := s.validateDeleteNvmeRemoteControllerRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.NvmeControllers[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			return &emptypb.Empty{}, nil
@@ -72,7 +132,14 @@ func (s *Server) DeleteNvmeRemoteController(_ context.Context, in *pb.DeleteNvme
 	if s.numberOfPathsForController(in.Name) > 0 {
 		return nil, status.Error(codes.FailedPrecondition, "NvmePaths exist for controller")
 	}
+	if utils.DryRunRequested(ctx) {
+		return &emptypb.Empty{}, nil
+	}
+	s.mapsMu.Lock()
 	delete(s.Volumes.NvmeControllers, volume.Name)
+	delete(s.nvmeHostIDs, volume.Name)
+	delete(s.nvmeKeepAliveTimeouts, volume.Name)
+	s.mapsMu.Unlock()
 	return &emptypb.Empty{}, nil
 }
 
@@ -91,8 +158,12 @@ func (s *Server) UpdateNvmeRemoteController(_ context.Context, in *pb.UpdateNvme
 	if err := s.validateUpdateNvmeRemoteControllerRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.NvmeRemoteController.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	controller, ok := s.Volumes.NvmeControllers[in.NvmeRemoteController.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			log.Printf("TODO: in case of AllowMissing, create a new resource, don;t return error")
@@ -112,20 +183,34 @@ func (s *Server) UpdateNvmeRemoteController(_ context.Context, in *pb.UpdateNvme
 }
 
 // ListNvmeRemoteControllers lists an Nvme remote controllers
-func (s *Server) ListNvmeRemoteControllers(_ context.Context, in *pb.ListNvmeRemoteControllersRequest) (*pb.ListNvmeRemoteControllersResponse, error) {
+func (s *Server) ListNvmeRemoteControllers(ctx context.Context, in *pb.ListNvmeRemoteControllersRequest) (*pb.ListNvmeRemoteControllersResponse, error) {
 	// check required fields
 	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
 		return nil, err
 	}
+	trtype, err := nvmeTransportFilterFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	// fetch object from the database
 	size, offset, perr := utils.ExtractPagination(in.PageSize, in.PageToken, s.Pagination)
 	if perr != nil {
 		return nil, perr
 	}
 
-	Blobarray := []*pb.NvmeRemoteController{}
+	s.mapsMu.RLock()
+	controllers := make([]*pb.NvmeRemoteController, 0, len(s.Volumes.NvmeControllers))
 	for _, controller := range s.Volumes.NvmeControllers {
-		Blobarray = append(Blobarray, controller)
+		controllers = append(controllers, controller)
+	}
+	s.mapsMu.RUnlock()
+	Blobarray := []*pb.NvmeRemoteController{}
+	for _, controller := range controllers {
+		// filter before pagination, so that page size applies to the
+		// filtered list
+		if s.remoteControllerHasTransport(controller, trtype) {
+			Blobarray = append(Blobarray, controller)
+		}
 	}
 	sortNvmeRemoteControllers(Blobarray)
 
@@ -134,24 +219,29 @@ func (s *Server) ListNvmeRemoteControllers(_ context.Context, in *pb.ListNvmeRem
 	Blobarray, hasMoreElements := utils.LimitPagination(Blobarray, offset, size)
 	if hasMoreElements {
 		token = uuid.New().String()
-		s.Pagination[token] = offset + size
+		s.Pagination.Set(token, offset+size)
 	}
+	Blobarray, token = utils.LimitPaginationBySize(Blobarray, offset, token, s.Pagination)
 	return &pb.ListNvmeRemoteControllersResponse{NvmeRemoteControllers: Blobarray, NextPageToken: token}, nil
 }
 
 // GetNvmeRemoteController gets an Nvme remote controller
-func (s *Server) GetNvmeRemoteController(_ context.Context, in *pb.GetNvmeRemoteControllerRequest) (*pb.NvmeRemoteController, error) {
+func (s *Server) GetNvmeRemoteController(ctx context.Context, in *pb.GetNvmeRemoteControllerRequest) (*pb.NvmeRemoteController, error) {
 	// check input correctness
 	if err := s.validateGetNvmeRemoteControllerRequest(in); err != nil {
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.NvmeControllers[in.Name]
+	keepAliveTimeout := s.nvmeKeepAliveTimeouts[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
 
+	sendNvmeKeepAliveTimeout(ctx, keepAliveTimeout)
 	response := utils.ProtoClone(volume)
 	return response, nil
 }
@@ -163,12 +253,14 @@ func (s *Server) StatsNvmeRemoteController(_ context.Context, in *pb.StatsNvmeRe
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.NvmeControllers[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	name := path.Base(volume.Name)
+	name := utils.ResourceNameToID(volume.Name)
 	log.Printf("TODO: send name to SPDK and get back stats: %v", name)
 	return &pb.StatsNvmeRemoteControllerResponse{Stats: &pb.VolumeStats{ReadOpsCount: -1, WriteOpsCount: -1}}, nil
 }
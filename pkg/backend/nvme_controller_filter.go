@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// NvmeTransportFilterMetadataKey is metadata key limiting
+// ListNvmeRemoteControllers to controllers of a transport type, e.g.
+// NVME_TRANSPORT_TYPE_TCP or TCP, since the request has no filter field.
+// The same filter has to be sent with all pages of the list
+const NvmeTransportFilterMetadataKey = "opi-nvme-transport-filter"
+
+// nvmeTransportFilterFromContext returns transport type requested by client
+// or unspecified type to list all controllers if omitted
+func nvmeTransportFilterFromContext(ctx context.Context) (pb.NvmeTransportType, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(NvmeTransportFilterMetadataKey)
+	if len(values) == 0 || values[0] == "" {
+		return pb.NvmeTransportType_NVME_TRANSPORT_TYPE_UNSPECIFIED, nil
+	}
+	name := strings.ToUpper(values[0])
+	if !strings.HasPrefix(name, "NVME_TRANSPORT_TYPE_") {
+		name = "NVME_TRANSPORT_TYPE_" + name
+	}
+	trtype, ok := pb.NvmeTransportType_value[name]
+	if !ok {
+		msg := fmt.Sprintf("unknown transport type filter %q", values[0])
+		return pb.NvmeTransportType_NVME_TRANSPORT_TYPE_UNSPECIFIED, status.Errorf(codes.InvalidArgument, msg)
+	}
+	return pb.NvmeTransportType(trtype), nil
+}
+
+// remoteControllerHasTransport tells whether controller uses transport type
+// trtype, which is the type of any of its paths or TCP for controllers with
+// TCP parameters. Controllers without paths of other types are not known
+// to use them
+func (s *Server) remoteControllerHasTransport(controller *pb.NvmeRemoteController, trtype pb.NvmeTransportType) bool {
+	if trtype == pb.NvmeTransportType_NVME_TRANSPORT_TYPE_UNSPECIFIED {
+		return true
+	}
+	if trtype == pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP && controller.Tcp != nil {
+		return true
+	}
+	controllerID := utils.ResourceNameToID(controller.Name)
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	for _, path := range s.Volumes.NvmePaths {
+		if utils.GetRemoteControllerIDFromNvmeRemoteName(path.Name) == controllerID && path.Trtype == trtype {
+			return true
+		}
+	}
+	return false
+}
@@ -10,9 +10,12 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/google/uuid"
 	"google.golang.org/protobuf/proto"
 
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 
@@ -118,6 +121,85 @@ func TestBackEnd_CreateNvmeRemoteController(t *testing.T) {
 	}
 }
 
+func TestBackEnd_CreateNvmeRemoteControllerHostID(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		hostID  string
+		want    string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"explicit host id": {
+			hostID:  "feb98abe-d51f-40c8-b348-2753f3571d3c",
+			want:    "feb98abe-d51f-40c8-b348-2753f3571d3c",
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"explicit host id in non canonical form": {
+			hostID:  "FEB98ABE-D51F-40C8-B348-2753F3571D3C",
+			want:    "feb98abe-d51f-40c8-b348-2753f3571d3c",
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"auto-generated host id": {
+			hostID:  "",
+			want:    "",
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"malformed host id": {
+			hostID:  "not-a-uuid",
+			want:    "",
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("invalid host id %q: %v", "not-a-uuid", "invalid UUID length: 10"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			ctx := testEnv.ctx
+			if tt.hostID != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, NvmeHostIDMetadataKey, tt.hostID)
+			}
+			var header metadata.MD
+			request := &pb.CreateNvmeRemoteControllerRequest{NvmeRemoteController: &testNvmeCtrl, NvmeRemoteControllerId: testNvmeCtrlID}
+			_, err := testEnv.client.CreateNvmeRemoteController(ctx, request, grpc.Header(&header))
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			hostID, ok := testEnv.opiSpdkServer.nvmeHostIDs[testNvmeCtrlName]
+			if ok != (tt.errCode == codes.OK) {
+				t.Fatal("expected host id to be persisted only on success, received", hostID)
+			}
+			if !ok {
+				return
+			}
+			if tt.want != "" && hostID != tt.want {
+				t.Error("host id: expected", tt.want, "received", hostID)
+			}
+			if _, err := uuid.Parse(hostID); err != nil {
+				t.Error("expected valid uuid host id, received", hostID)
+			}
+			if values := header.Get(NvmeHostIDMetadataKey); !reflect.DeepEqual(values, []string{hostID}) {
+				t.Error("header host id: expected", hostID, "received", values)
+			}
+		})
+	}
+}
+
 func TestBackEnd_ResetNvmeRemoteController(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
 	tests := map[string]struct {
@@ -174,6 +256,15 @@ func TestBackEnd_ListNvmeRemoteControllers(t *testing.T) {
 		token               string
 		existingControllers map[string]*pb.NvmeRemoteController
 	}{
+		"no remote controllers were created": {
+			in:                  testNvmeCtrlID,
+			out:                 []*pb.NvmeRemoteController{},
+			errCode:             codes.OK,
+			errMsg:              "",
+			size:                0,
+			token:               "",
+			existingControllers: map[string]*pb.NvmeRemoteController{},
+		},
 		"valid request with valid SPDK response": {
 			in: testNvmeCtrlID,
 			out: []*pb.NvmeRemoteController{
@@ -300,7 +391,7 @@ func TestBackEnd_ListNvmeRemoteControllers(t *testing.T) {
 			testEnv := createTestEnvironment([]string{})
 			defer testEnv.Close()
 
-			testEnv.opiSpdkServer.Pagination["existing-pagination-token"] = 1
+			testEnv.opiSpdkServer.Pagination.Set("existing-pagination-token", 1)
 			for k, v := range tt.existingControllers {
 				testEnv.opiSpdkServer.Volumes.NvmeControllers[k] = utils.ProtoClone(v)
 			}
@@ -331,6 +422,54 @@ func TestBackEnd_ListNvmeRemoteControllers(t *testing.T) {
 	}
 }
 
+func TestBackEnd_ListNvmeRemoteControllersByteBudget(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	t.Cleanup(func() { _ = utils.SetListByteBudget(utils.DefaultListByteBudget) })
+	if err := utils.SetListByteBudget(500); err != nil {
+		t.Fatal(err)
+	}
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	const count = 40
+	for i := 0; i < count; i++ {
+		name := utils.ResourceIDToRemoteControllerName(fmt.Sprintf("OpiNvme%02d", i))
+		testEnv.opiSpdkServer.Volumes.NvmeControllers[name] = &pb.NvmeRemoteController{Name: name}
+	}
+
+	received := map[string]bool{}
+	token := ""
+	for pages := 1; ; pages++ {
+		request := &pb.ListNvmeRemoteControllersRequest{PageToken: token}
+		response, err := testEnv.client.ListNvmeRemoteControllers(testEnv.ctx, request)
+		if err != nil {
+			t.Fatal("expected no error, received", err)
+		}
+		if proto.Size(response) > 500+len(response.GetNextPageToken())+2 {
+			t.Error("expected response to fit into byte budget, received size", proto.Size(response))
+		}
+		for _, controller := range response.GetNvmeRemoteControllers() {
+			if received[controller.Name] {
+				t.Error("duplicate controller received", controller.Name)
+			}
+			received[controller.Name] = true
+		}
+		token = response.GetNextPageToken()
+		if token == "" {
+			if pages == 1 {
+				t.Error("expected response to be truncated with next page token")
+			}
+			break
+		}
+		if pages > count {
+			t.Fatal("expected pagination to end")
+		}
+	}
+	if len(received) != count {
+		t.Error("expected", count, "controllers, received", len(received))
+	}
+}
+
 func TestBackEnd_GetNvmeRemoteController(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
 	tests := map[string]struct {
@@ -536,3 +675,119 @@ func TestBackEnd_DeleteNvmeRemoteController(t *testing.T) {
 		})
 	}
 }
+
+func TestBackEnd_ListNvmeRemoteControllersTransportFilter(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	controllerName := utils.ResourceIDToRemoteControllerName
+	tests := map[string]struct {
+		filter  string
+		size    int32
+		pages   [][]string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"unspecified filter returns all": {
+			filter:  "",
+			size:    0,
+			pages:   [][]string{{controllerName("bare"), controllerName("pcie1"), controllerName("pcie2"), controllerName("tcp1"), controllerName("tcp2")}},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"explicit unspecified filter returns all": {
+			filter:  "NVME_TRANSPORT_TYPE_UNSPECIFIED",
+			size:    0,
+			pages:   [][]string{{controllerName("bare"), controllerName("pcie1"), controllerName("pcie2"), controllerName("tcp1"), controllerName("tcp2")}},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"tcp short name": {
+			filter:  "tcp",
+			size:    0,
+			pages:   [][]string{{controllerName("tcp1"), controllerName("tcp2")}},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"pcie paginated after filtering": {
+			filter:  "NVME_TRANSPORT_TYPE_PCIE",
+			size:    1,
+			pages:   [][]string{{controllerName("pcie1")}, {controllerName("pcie2")}},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"tcp page size larger than filtered": {
+			filter:  "TCP",
+			size:    3,
+			pages:   [][]string{{controllerName("tcp1"), controllerName("tcp2")}},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown transport": {
+			filter:  "ethernet",
+			size:    0,
+			pages:   nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("unknown transport type filter %q", "ethernet"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+			controllers := testEnv.opiSpdkServer.Volumes.NvmeControllers
+			controllers[controllerName("tcp1")] = &pb.NvmeRemoteController{Name: controllerName("tcp1"), Tcp: &pb.TcpController{}}
+			controllers[controllerName("tcp2")] = &pb.NvmeRemoteController{Name: controllerName("tcp2")}
+			controllers[controllerName("pcie1")] = &pb.NvmeRemoteController{Name: controllerName("pcie1")}
+			controllers[controllerName("pcie2")] = &pb.NvmeRemoteController{Name: controllerName("pcie2")}
+			controllers[controllerName("bare")] = &pb.NvmeRemoteController{Name: controllerName("bare")}
+			paths := testEnv.opiSpdkServer.Volumes.NvmePaths
+			for controller, trtype := range map[string]pb.NvmeTransportType{
+				"tcp2":  pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
+				"pcie1": pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE,
+				"pcie2": pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE,
+			} {
+				pathName := utils.ResourceIDToNvmePathName(controller, "path")
+				paths[pathName] = &pb.NvmePath{Name: pathName, Trtype: trtype}
+			}
+
+			ctx := testEnv.ctx
+			if tt.filter != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, NvmeTransportFilterMetadataKey, tt.filter)
+			}
+			pages := [][]string{}
+			token := ""
+			for {
+				request := &pb.ListNvmeRemoteControllersRequest{PageSize: tt.size, PageToken: token}
+				response, err := testEnv.client.ListNvmeRemoteControllers(ctx, request)
+				if er, ok := status.FromError(err); ok {
+					if er.Code() != tt.errCode {
+						t.Error("error code: expected", tt.errCode, "received", er.Code())
+					}
+					if er.Message() != tt.errMsg {
+						t.Error("error message: expected", tt.errMsg, "received", er.Message())
+					}
+				} else {
+					t.Error("expected grpc error status")
+				}
+				if err != nil {
+					pages = nil
+					break
+				}
+				names := []string{}
+				for _, controller := range response.NvmeRemoteControllers {
+					names = append(names, controller.Name)
+				}
+				pages = append(pages, names)
+				token = response.NextPageToken
+				if token == "" || len(pages) > len(tt.pages) {
+					break
+				}
+			}
+
+			if !reflect.DeepEqual(pages, tt.pages) {
+				t.Error("pages: expected", tt.pages, "received", pages)
+			}
+		})
+	}
+}
@@ -10,21 +10,19 @@ import (
 	"go.einride.tech/aip/resourcename"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
 
 func (s *Server) validateCreateNvmeRemoteControllerRequest(in *pb.CreateNvmeRemoteControllerRequest) error {
+	v := &utils.Validator{}
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
-	}
+	v.CheckRequiredFields(in)
 	// see https://google.aip.dev/133#user-specified-ids
 	if in.NvmeRemoteControllerId != "" {
-		if err := resourceid.ValidateUserSettable(in.NvmeRemoteControllerId); err != nil {
-			return err
-		}
+		v.Check("nvme_remote_controller_id", resourceid.ValidateUserSettable(in.NvmeRemoteControllerId))
 	}
 	// TODO: validate also: block_size, blocks_count, uuid, filename
-	return nil
+	return v.Err()
 }
 
 func (s *Server) validateDeleteNvmeRemoteControllerRequest(in *pb.DeleteNvmeRemoteControllerRequest) error {
@@ -10,8 +10,8 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/opiproject/gospdk/spdk"
@@ -21,7 +21,6 @@ import (
 	"github.com/google/uuid"
 	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/fieldmask"
-	"go.einride.tech/aip/resourceid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -33,30 +32,52 @@ func sortNvmePaths(paths []*pb.NvmePath) {
 	})
 }
 
+// bdevNvmeAttachControllerParams extends SPDK attach parameters with host ID,
+// keep-alive timeout and failover timing
+type bdevNvmeAttachControllerParams struct {
+	spdk.BdevNvmeAttachControllerParams
+	nvmePathFailover
+	Hostid             string `json:"hostid,omitempty"`
+	KeepAliveTimeoutMs int    `json:"keep_alive_timeout_ms,omitempty"`
+}
+
 // CreateNvmePath creates a new Nvme path
 func (s *Server) CreateNvmePath(ctx context.Context, in *pb.CreateNvmePathRequest) (*pb.NvmePath, error) {
 	// check input correctness
 	if err := s.validateCreateNvmePathRequest(in); err != nil {
 		return nil, err
 	}
+	failover, err := nvmePathFailoverFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	resourceID := resourceid.NewSystemGenerated()
+	resourceID, err := s.idempotencyKeys.ResourceID(ctx, "NvmePath", in.NvmePathId)
+	if err != nil {
+		return nil, err
+	}
 	if in.NvmePathId != "" {
 		log.Printf("client provided the ID of a resource %v, ignoring the name field %v", in.NvmePathId, in.NvmePath.Name)
-		resourceID = in.NvmePathId
 	}
 	in.NvmePath.Name = utils.ResourceIDToNvmePathName(
 		utils.GetRemoteControllerIDFromNvmeRemoteName(in.Parent),
 		resourceID,
 	)
 
+	unlock := s.resourceLocks.Lock(in.NvmePath.Name)
+	defer unlock()
+	s.mapsMu.RLock()
 	nvmePath, ok := s.Volumes.NvmePaths[in.NvmePath.Name]
+	s.mapsMu.RUnlock()
 	if ok {
 		log.Printf("Already existing NvmePath with id %v", in.NvmePath.Name)
+		sendNvmePathFailover(ctx, s.nvmePathFailover(nvmePath.Name))
 		return nvmePath, nil
 	}
 
+	s.mapsMu.RLock()
 	controller, ok := s.Volumes.NvmeControllers[in.Parent]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find NvmeRemoteController by key %s", in.Parent)
 		return nil, err
@@ -68,6 +89,11 @@ func (s *Server) CreateNvmePath(ctx context.Context, in *pb.CreateNvmePathReques
 		return nil, err
 	}
 
+	if in.NvmePath.Trtype == pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP && in.NvmePath.Fabrics.Hostnqn == "" {
+		// stored with the path, so that reconnects reuse the same hostnqn
+		in.NvmePath.Fabrics.Hostnqn = s.derivedHostnqn(controller.Name)
+	}
+
 	multipath := ""
 	if numberOfPaths := s.numberOfPathsForController(controller.Name); numberOfPaths > 0 {
 		// set multipath parameter only when at least one path already exists
@@ -87,28 +113,43 @@ func (s *Server) CreateNvmePath(ctx context.Context, in *pb.CreateNvmePathReques
 
 		psk = keyFile
 	}
-	params := spdk.BdevNvmeAttachControllerParams{
-		Name:      utils.GetRemoteControllerIDFromNvmeRemoteName(controller.Name),
-		Trtype:    s.opiTransportToSpdk(in.NvmePath.GetTrtype()),
-		Traddr:    in.NvmePath.GetTraddr(),
-		Adrfam:    utils.OpiAdressFamilyToSpdk(in.NvmePath.GetFabrics().GetAdrfam()),
-		Trsvcid:   fmt.Sprint(in.NvmePath.GetFabrics().GetTrsvcid()),
-		Subnqn:    in.NvmePath.GetFabrics().GetSubnqn(),
-		Hostnqn:   in.NvmePath.GetFabrics().GetHostnqn(),
-		Multipath: multipath,
-		Hdgst:     controller.GetTcp().GetHdgst(),
-		Ddgst:     controller.GetTcp().GetDdgst(),
-		Psk:       psk,
+	s.mapsMu.RLock()
+	hostID, keepAliveTimeout := s.nvmeHostIDs[controller.Name], s.nvmeKeepAliveTimeouts[controller.Name]
+	s.mapsMu.RUnlock()
+	params := bdevNvmeAttachControllerParams{
+		BdevNvmeAttachControllerParams: spdk.BdevNvmeAttachControllerParams{
+			Name:      utils.GetRemoteControllerIDFromNvmeRemoteName(controller.Name),
+			Trtype:    s.opiTransportToSpdk(in.NvmePath.GetTrtype()),
+			Traddr:    in.NvmePath.GetTraddr(),
+			Adrfam:    utils.OpiAdressFamilyToSpdk(in.NvmePath.GetFabrics().GetAdrfam()),
+			Trsvcid:   fmt.Sprint(in.NvmePath.GetFabrics().GetTrsvcid()),
+			Subnqn:    in.NvmePath.GetFabrics().GetSubnqn(),
+			Hostnqn:   in.NvmePath.GetFabrics().GetHostnqn(),
+			Multipath: multipath,
+			Hdgst:     controller.GetTcp().GetHdgst(),
+			Ddgst:     controller.GetTcp().GetDdgst(),
+			Psk:       psk,
+		},
+		nvmePathFailover:   failover,
+		Hostid:             hostID,
+		KeepAliveTimeoutMs: keepAliveTimeout,
+	}
+	if utils.SkipSpdkCallInDryRun(ctx, "bdev_nvme_attach_controller", &params) {
+		return utils.ProtoClone(in.NvmePath), nil
 	}
 	var result []spdk.BdevNvmeAttachControllerResult
-	err := s.rpc.Call(ctx, "bdev_nvme_attach_controller", &params, &result)
+	err = s.rpc.Call(ctx, "bdev_nvme_attach_controller", &params, &result)
 	if err != nil {
 		return nil, err
 	}
 	log.Printf("Received from SPDK: %v", result)
 
 	response := utils.ProtoClone(in.NvmePath)
+	s.mapsMu.Lock()
 	s.Volumes.NvmePaths[in.NvmePath.Name] = response
+	s.mapsMu.Unlock()
+	s.setNvmePathFailover(in.NvmePath.Name, failover)
+	sendNvmePathFailover(ctx, failover)
 	return response, nil
 }
 
@@ -118,7 +159,11 @@ func (s *Server) DeleteNvmePath(ctx context.Context, in *pb.DeleteNvmePathReques
 	if err := s.validateDeleteNvmePathRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.Name)
+	defer unlock()
+	s.mapsMu.RLock()
 	nvmePath, ok := s.Volumes.NvmePaths[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			return &emptypb.Empty{}, nil
@@ -129,7 +174,9 @@ func (s *Server) DeleteNvmePath(ctx context.Context, in *pb.DeleteNvmePathReques
 	controllerName := utils.ResourceIDToRemoteControllerName(
 		utils.GetRemoteControllerIDFromNvmeRemoteName(in.Name),
 	)
+	s.mapsMu.RLock()
 	controller, ok := s.Volumes.NvmeControllers[controllerName]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.Internal, "unable to find NvmeRemoteController by key %s", controllerName)
 		return nil, err
@@ -144,6 +191,9 @@ func (s *Server) DeleteNvmePath(ctx context.Context, in *pb.DeleteNvmePathReques
 		Subnqn:  nvmePath.GetFabrics().GetSubnqn(),
 	}
 
+	if utils.SkipSpdkCallInDryRun(ctx, "bdev_nvme_detach_controller", &params) {
+		return &emptypb.Empty{}, nil
+	}
 	var result spdk.BdevNvmeDetachControllerResult
 	err := s.rpc.Call(ctx, "bdev_nvme_detach_controller", &params, &result)
 	if err != nil {
@@ -155,7 +205,10 @@ func (s *Server) DeleteNvmePath(ctx context.Context, in *pb.DeleteNvmePathReques
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
 
+	s.mapsMu.Lock()
 	delete(s.Volumes.NvmePaths, in.Name)
+	s.mapsMu.Unlock()
+	s.clearNvmePathFailover(in.Name)
 
 	return &emptypb.Empty{}, nil
 }
@@ -166,8 +219,12 @@ func (s *Server) UpdateNvmePath(_ context.Context, in *pb.UpdateNvmePathRequest)
 	if err := s.validateUpdateNvmePathRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.NvmePath.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	nvmePath, ok := s.Volumes.NvmePaths[in.NvmePath.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			log.Printf("TODO: in case of AllowMissing, create a new resource, don;t return error")
@@ -175,7 +232,7 @@ func (s *Server) UpdateNvmePath(_ context.Context, in *pb.UpdateNvmePathRequest)
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.NvmePath.Name)
 		return nil, err
 	}
-	resourceID := path.Base(nvmePath.Name)
+	resourceID := utils.ResourceNameToID(nvmePath.Name)
 	// update_mask = 2
 	if err := fieldmask.Validate(in.UpdateMask, in.NvmePath); err != nil {
 		return nil, err
@@ -186,7 +243,8 @@ func (s *Server) UpdateNvmePath(_ context.Context, in *pb.UpdateNvmePathRequest)
 	return response, nil
 }
 
-// ListNvmePaths lists Nvme path
+// ListNvmePaths lists Nvme paths of a controller with transport details
+// established by SPDK
 func (s *Server) ListNvmePaths(ctx context.Context, in *pb.ListNvmePathsRequest) (*pb.ListNvmePathsResponse, error) {
 	// check required fields
 	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
@@ -203,19 +261,42 @@ func (s *Server) ListNvmePaths(ctx context.Context, in *pb.ListNvmePathsRequest)
 		return nil, err
 	}
 	log.Printf("Received from SPDK: %v", result)
+
+	controllerID := utils.GetRemoteControllerIDFromNvmeRemoteName(in.Parent)
+	var controller *spdk.BdevNvmeGetControllerResult
+	for i := range result {
+		if result[i].Name == controllerID {
+			controller = &result[i]
+		}
+	}
+	Blobarray := []*pb.NvmePath{}
+	s.mapsMu.RLock()
+	for _, path := range s.Volumes.NvmePaths {
+		if utils.GetRemoteControllerIDFromNvmeRemoteName(path.Name) != controllerID {
+			continue
+		}
+		var ctrlr *spdkPathController
+		if controller != nil {
+			ctrlr = findSpdkPathController(controller, path)
+		}
+		if ctrlr == nil {
+			log.Printf("SPDK does not report unambiguous path for %v, using requested one", path.Name)
+			Blobarray = append(Blobarray, utils.ProtoClone(path))
+			continue
+		}
+		Blobarray = append(Blobarray, negotiatedNvmePath(path, ctrlr))
+	}
+	s.mapsMu.RUnlock()
+	sortNvmePaths(Blobarray)
+
 	token := ""
-	log.Printf("Limiting result len(%d) to [%d:%d]", len(result), offset, size)
-	result, hasMoreElements := utils.LimitPagination(result, offset, size)
+	log.Printf("Limiting result len(%d) to [%d:%d]", len(Blobarray), offset, size)
+	Blobarray, hasMoreElements := utils.LimitPagination(Blobarray, offset, size)
 	if hasMoreElements {
 		token = uuid.New().String()
-		s.Pagination[token] = offset + size
+		s.Pagination.Set(token, offset+size)
 	}
-	Blobarray := make([]*pb.NvmePath, len(result))
-	for i := range result {
-		r := &result[i]
-		Blobarray[i] = &pb.NvmePath{Name: r.Name /* TODO: fill this */}
-	}
-	sortNvmePaths(Blobarray)
+	Blobarray, token = utils.LimitPaginationBySize(Blobarray, offset, token, s.Pagination)
 	return &pb.ListNvmePathsResponse{NvmePaths: Blobarray, NextPageToken: token}, nil
 }
 
@@ -226,7 +307,9 @@ func (s *Server) GetNvmePath(ctx context.Context, in *pb.GetNvmePathRequest) (*p
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	path, ok := s.Volumes.NvmePaths[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
@@ -239,37 +322,151 @@ func (s *Server) GetNvmePath(ctx context.Context, in *pb.GetNvmePathRequest) (*p
 	}
 	log.Printf("Received from SPDK: %v", result)
 
+	controllerID := utils.GetRemoteControllerIDFromNvmeRemoteName(path.Name)
 	for i := range result {
 		r := &result[i]
-		if r.Name != "" {
-			return &pb.NvmePath{ /* TODO: fill this */ }, nil
+		if r.Name != controllerID {
+			continue
+		}
+		if ctrlr := findSpdkPathController(r, path); ctrlr != nil {
+			sendNvmePathFailover(ctx, s.nvmePathFailover(path.Name))
+			return negotiatedNvmePath(path, ctrlr), nil
 		}
 	}
-	msg := fmt.Sprintf("Could not find NQN: %s", path.Fabrics.Subnqn)
+	msg := fmt.Sprintf("Could not find NQN: %s", path.GetFabrics().GetSubnqn())
 	return nil, status.Errorf(codes.InvalidArgument, msg)
 }
 
-// StatsNvmePath gets Nvme path stats
+// spdkPathController is a single path of a controller reported by
+// bdev_nvme_get_controllers. It aliases the unnamed element type of
+// spdk.BdevNvmeGetControllerResult.Ctrlrs, so it has to be kept identical
+type spdkPathController = struct {
+	State string `json:"state"`
+	Trid  struct {
+		Trtype  string `json:"trtype"`
+		Adrfam  string `json:"adrfam"`
+		Traddr  string `json:"traddr"`
+		Trsvcid string `json:"trsvcid"`
+		Subnqn  string `json:"subnqn"`
+	} `json:"trid"`
+	Cntlid int `json:"cntlid"`
+	Host   struct {
+		Nqn   string `json:"nqn"`
+		Addr  string `json:"addr"`
+		Svcid string `json:"svcid"`
+	} `json:"host"`
+}
+
+// findSpdkPathController returns path of SPDK controller established for
+// nvmePath. The address SPDK connected to can differ from the requested one,
+// so the only path to the subsystem is accepted even if the address differs
+func findSpdkPathController(controller *spdk.BdevNvmeGetControllerResult, nvmePath *pb.NvmePath) *spdkPathController {
+	var candidates []*spdkPathController
+	for i := range controller.Ctrlrs {
+		ctrlr := &controller.Ctrlrs[i]
+		if ctrlr.Trid.Subnqn != nvmePath.GetFabrics().GetSubnqn() {
+			continue
+		}
+		if ctrlr.Trid.Traddr == nvmePath.GetTraddr() &&
+			ctrlr.Trid.Trsvcid == fmt.Sprint(nvmePath.GetFabrics().GetTrsvcid()) {
+			return ctrlr
+		}
+		candidates = append(candidates, ctrlr)
+	}
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+	return nil
+}
+
+// negotiatedNvmePath replaces requested transport details of nvmePath by
+// the ones actually established by SPDK
+func negotiatedNvmePath(nvmePath *pb.NvmePath, ctrlr *spdkPathController) *pb.NvmePath {
+	response := utils.ProtoClone(nvmePath)
+	if trtype, ok := pb.NvmeTransportType_value["NVME_TRANSPORT_TYPE_"+strings.ToUpper(ctrlr.Trid.Trtype)]; ok {
+		response.Trtype = pb.NvmeTransportType(trtype)
+	}
+	if ctrlr.Trid.Traddr != "" {
+		response.Traddr = ctrlr.Trid.Traddr
+	}
+	if response.Fabrics == nil {
+		return response
+	}
+	if trsvcid, err := strconv.ParseInt(ctrlr.Trid.Trsvcid, 10, 64); err == nil {
+		response.Fabrics.Trsvcid = trsvcid
+	}
+	if adrfam, ok := pb.NvmeAddressFamily_value["NVME_ADDRESS_FAMILY_"+strings.ToUpper(ctrlr.Trid.Adrfam)]; ok {
+		response.Fabrics.Adrfam = pb.NvmeAddressFamily(adrfam)
+	}
+	if ctrlr.Host.Addr != "" {
+		response.Fabrics.SourceTraddr = ctrlr.Host.Addr
+	}
+	if svcid, err := strconv.ParseInt(ctrlr.Host.Svcid, 10, 64); err == nil {
+		response.Fabrics.SourceTrsvcid = svcid
+	}
+	if ctrlr.Host.Nqn != "" {
+		response.Fabrics.Hostnqn = ctrlr.Host.Nqn
+	}
+	return response
+}
+
+// StatsNvmePath gets Nvme path stats. SPDK does not account IO per path, so
+// stats of all namespace bdevs of the path controller are returned, shared
+// by all its paths
 func (s *Server) StatsNvmePath(ctx context.Context, in *pb.StatsNvmePathRequest) (*pb.StatsNvmePathResponse, error) {
 	// check input correctness
 	if err := s.validateStatsNvmePathRequest(in); err != nil {
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Volumes.NvmePaths[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
-	log.Printf("TODO: send name to SPDK and get back stats: %v", resourceID)
-	var result spdk.NvmfGetSubsystemStatsResult
-	err := s.rpc.Call(ctx, "nvmf_get_stats", nil, &result)
+	result, err := s.bdevsIostat(ctx)
 	if err != nil {
 		return nil, err
 	}
 	log.Printf("Received from SPDK: %v", result)
-	return &pb.StatsNvmePathResponse{Stats: &pb.VolumeStats{ReadOpsCount: -1, WriteOpsCount: -1}}, nil
+
+	controllerID := utils.GetRemoteControllerIDFromNvmeRemoteName(volume.Name)
+	stats := &pb.VolumeStats{}
+	found := 0
+	for i := range result.Bdevs {
+		r := &result.Bdevs[i]
+		if !isNvmeNamespaceBdev(controllerID, r.Name) {
+			continue
+		}
+		found++
+		stats.ReadBytesCount += int32(r.BytesRead)
+		stats.ReadOpsCount += int32(r.NumReadOps)
+		stats.WriteBytesCount += int32(r.BytesWritten)
+		stats.WriteOpsCount += int32(r.NumWriteOps)
+		stats.UnmapBytesCount += int32(r.BytesUnmapped)
+		stats.UnmapOpsCount += int32(r.NumUnmapOps)
+		stats.ReadLatencyTicks += int32(r.ReadLatencyTicks)
+		stats.WriteLatencyTicks += int32(r.WriteLatencyTicks)
+		stats.UnmapLatencyTicks += int32(r.UnmapLatencyTicks)
+	}
+	if found == 0 {
+		msg := fmt.Sprintf("expecting at least 1 result, got %v", found)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	return &pb.StatsNvmePathResponse{Stats: stats}, nil
+}
+
+// isNvmeNamespaceBdev tells whether bdev is a namespace of Nvme controller,
+// which SPDK names as <controller>n<nsid>
+func isNvmeNamespaceBdev(controllerID, bdev string) bool {
+	nsid := strings.TrimPrefix(bdev, controllerID+"n")
+	if nsid == bdev || nsid == "" {
+		return false
+	}
+	_, err := strconv.ParseUint(nsid, 10, 32)
+	return err == nil
 }
 
 func (s *Server) opiTransportToSpdk(transport pb.NvmeTransportType) string {
@@ -285,6 +482,8 @@ func (s *Server) opiMultipathToSpdk(multipath pb.NvmeMultipath) string {
 func (s *Server) numberOfPathsForController(controllerName string) int {
 	numberOfPaths := 0
 	prefix := controllerName + "/"
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
 	for _, path := range s.Volumes.NvmePaths {
 		if strings.HasPrefix(path.Name, prefix) {
 			numberOfPaths++
@@ -292,3 +491,22 @@ func (s *Server) numberOfPathsForController(controllerName string) int {
 	}
 	return numberOfPaths
 }
+
+// SetStatsCollector sets collector sharing stats of all bdevs between stats
+// requests, nil queries SPDK on every request
+func (s *Server) SetStatsCollector(collector *utils.StatsCollector) {
+	s.stats = collector
+}
+
+// bdevsIostat returns stats of all bdevs. The result must not be modified,
+// since it may be shared with other requests
+func (s *Server) bdevsIostat(ctx context.Context) (*spdk.BdevGetIostatResult, error) {
+	if s.stats != nil {
+		return s.stats.Iostat(ctx)
+	}
+	var result spdk.BdevGetIostatResult
+	if err := s.rpc.Call(ctx, "bdev_get_iostat", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -11,7 +11,9 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -308,6 +310,185 @@ func TestBackEnd_CreateNvmePath(t *testing.T) {
 	}
 }
 
+func TestBackEnd_CreateNvmePathHostID(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		hostID string
+		params []string
+	}{
+		"controller with host id": {
+			hostID: "feb98abe-d51f-40c8-b348-2753f3571d3c",
+			params: []string{`{"name":"opi-nvme8","trtype":"TCP","traddr":"127.0.0.1","hostnqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","adrfam":"IPV4","trsvcid":"4444","subnqn":"nqn.2016-06.io.spdk:cnode1","hostid":"feb98abe-d51f-40c8-b348-2753f3571d3c"}`},
+		},
+		"controller without host id": {
+			hostID: "",
+			params: []string{`{"name":"opi-nvme8","trtype":"TCP","traddr":"127.0.0.1","hostnqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","adrfam":"IPV4","trsvcid":"4444","subnqn":"nqn.2016-06.io.spdk:cnode1"}`},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{`{"id":%d,"error":{"code":0,"message":""},"result":["mytest"]}`})
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+
+			testEnv.opiSpdkServer.Volumes.NvmeControllers[testNvmeCtrlName] = utils.ProtoClone(&testNvmeCtrlWithName)
+			if tt.hostID != "" {
+				testEnv.opiSpdkServer.nvmeHostIDs[testNvmeCtrlName] = tt.hostID
+			}
+
+			request := &pb.CreateNvmePathRequest{Parent: testNvmeCtrlName, NvmePath: &testNvmePath, NvmePathId: testNvmePathID}
+			if _, err := testEnv.client.CreateNvmePath(testEnv.ctx, request); err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+
+			if !reflect.DeepEqual(recorder.params, tt.params) {
+				t.Error("spdk params: expected", tt.params, "received", recorder.params)
+			}
+		})
+	}
+}
+
+func TestBackEnd_CreateNvmePathHostnqn(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	const hostID = "0c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f"
+	const ctrlHostID = "feb98abe-d51f-40c8-b348-2753f3571d3c"
+	tests := map[string]struct {
+		hostnqn    string
+		ctrlHostID string
+		spdk       []string
+		outHostnqn string
+		errCode    codes.Code
+		errMsg     string
+	}{
+		"omitted hostnqn is derived from host id": {
+			hostnqn:    "",
+			ctrlHostID: "",
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":["mytest"]}`},
+			outHostnqn: "nqn.2014-08.org.nvmexpress:uuid:" + hostID,
+			errCode:    codes.OK,
+			errMsg:     "",
+		},
+		"omitted hostnqn is derived from controller host id": {
+			hostnqn:    "",
+			ctrlHostID: ctrlHostID,
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":["mytest"]}`},
+			outHostnqn: "nqn.2014-08.org.nvmexpress:uuid:" + ctrlHostID,
+			errCode:    codes.OK,
+			errMsg:     "",
+		},
+		"provided hostnqn is kept": {
+			hostnqn:    "nqn.2024-01.com.example:host-a",
+			ctrlHostID: "",
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":["mytest"]}`},
+			outHostnqn: "nqn.2024-01.com.example:host-a",
+			errCode:    codes.OK,
+			errMsg:     "",
+		},
+		"malformed hostnqn": {
+			hostnqn:    "host-a",
+			ctrlHostID: "",
+			spdk:       []string{},
+			outHostnqn: "",
+			errCode:    codes.InvalidArgument,
+			errMsg:     fmt.Sprintf("NQN value (%s) does not match pattern", "host-a"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+			if err := testEnv.opiSpdkServer.SetHostID(hostID); err != nil {
+				t.Fatal(err)
+			}
+
+			testEnv.opiSpdkServer.Volumes.NvmeControllers[testNvmeCtrlName] = utils.ProtoClone(&testNvmeCtrlWithName)
+			if tt.ctrlHostID != "" {
+				testEnv.opiSpdkServer.nvmeHostIDs[testNvmeCtrlName] = tt.ctrlHostID
+			}
+
+			path := utils.ProtoClone(&testNvmePath)
+			path.Fabrics.Hostnqn = tt.hostnqn
+			request := &pb.CreateNvmePathRequest{Parent: testNvmeCtrlName, NvmePath: path, NvmePathId: testNvmePathID}
+			response, err := testEnv.client.CreateNvmePath(testEnv.ctx, request)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if tt.errCode != codes.OK {
+				if len(recorder.params) != 0 {
+					t.Error("expected no SPDK calls, received", recorder.params)
+				}
+				return
+			}
+			if hostnqn := response.GetFabrics().GetHostnqn(); hostnqn != tt.outHostnqn {
+				t.Error("hostnqn: expected", tt.outHostnqn, "received", hostnqn)
+			}
+			// stored, so that reconnects reuse the same hostnqn
+			if stored := testEnv.opiSpdkServer.Volumes.NvmePaths[testNvmePathName]; stored.GetFabrics().GetHostnqn() != tt.outHostnqn {
+				t.Error("stored hostnqn: expected", tt.outHostnqn, "received", stored.GetFabrics().GetHostnqn())
+			}
+			if len(recorder.params) != 1 || !strings.Contains(recorder.params[0], `"hostnqn":"`+tt.outHostnqn+`"`) {
+				t.Error("expected hostnqn", tt.outHostnqn, "passed to SPDK, received", recorder.params)
+			}
+		})
+	}
+}
+
+func TestBackEnd_SetHostID(t *testing.T) {
+	tests := map[string]struct {
+		hostID    string
+		outHostID string
+		errMsg    string
+	}{
+		"empty host id is derived from host name": {
+			hostID:    "",
+			outHostID: defaultHostID(),
+			errMsg:    "",
+		},
+		"host id is canonicalized": {
+			hostID:    "0C1D2E3F-4A5B-4C6D-8E7F-9A0B1C2D3E4F",
+			outHostID: "0c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f",
+			errMsg:    "",
+		},
+		"invalid host id": {
+			hostID:    "host-a",
+			outHostID: "",
+			errMsg:    fmt.Sprintf("invalid host ID %q: %v", "host-a", "invalid UUID length: 6"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := &Server{}
+			err := server.SetHostID(tt.hostID)
+
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tt.errMsg {
+				t.Error("expected error", tt.errMsg, "received", errMsg)
+			}
+			if server.hostID != tt.outHostID {
+				t.Error("host id: expected", tt.outHostID, "received", server.hostID)
+			}
+		})
+	}
+}
+
 func TestBackEnd_DeleteNvmePath(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
 	tests := map[string]struct {
@@ -614,6 +795,25 @@ func TestBackEnd_UpdateNvmePath(t *testing.T) {
 
 func TestBackEnd_ListNvmePaths(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testNvmePath2Name := utils.ResourceIDToNvmePathName(testNvmeCtrlID, "mytest2")
+	testNvmePath2 := utils.ProtoClone(&testNvmePathWithName)
+	testNvmePath2.Name = testNvmePath2Name
+	testNvmePath2.Fabrics.Trsvcid = 4445
+	listedNvmePath := func(name string, trsvcid, sourceTrsvcid int64) *pb.NvmePath {
+		return &pb.NvmePath{
+			Name:   name,
+			Trtype: pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
+			Traddr: "127.0.0.1",
+			Fabrics: &pb.FabricsPath{
+				Adrfam:        pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4,
+				Subnqn:        "nqn.2016-06.io.spdk:cnode1",
+				Hostnqn:       "nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c",
+				Trsvcid:       trsvcid,
+				SourceTraddr:  "127.0.0.1",
+				SourceTrsvcid: sourceTrsvcid,
+			},
+		}
+	}
 	tests := map[string]struct {
 		in      string
 		out     []*pb.NvmePath
@@ -622,155 +822,148 @@ func TestBackEnd_ListNvmePaths(t *testing.T) {
 		errMsg  string
 		size    int32
 		token   string
+		stored  bool
 	}{
-		// "valid request with invalid SPDK response": {
-		// 	in: testNvmePathID,
-		// 	out: nil,
-		// 	spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":[]}`},
-		// 	errCode: codes.InvalidArgument,
-		// 	errMsg: fmt.Sprintf("Could not find any namespaces for NQN: %v", "nqn.2022-09.io.spdk:opi3"),
-		// 	size: 0,
-		// 	token: "",
-		// },
-		// "valid request with invalid marshal SPDK response": {
-		// 	in: testNvmePathID,
-		// 	out: nil,
-		// 	spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":false}`},
-		// 	errCode: codes.Unknown,
-		// 	errMsg: fmt.Sprintf("bdev_nvme_get_controllers: %v", "json: cannot unmarshal bool into Go value of type []spdk.BdevGetBdevsResult"),
-		// 	size: 0,
-		// 	token: "",
-		// },
-		// "valid request with empty SPDK response": {
-		// 	in: testNvmePathID,
-		// 	out: nil,
-		// 	spdk: []string{""},
-		// 	errCode: codes.Unknown,
-		// 	errMsg: fmt.Sprintf("bdev_nvme_get_controllers: %v", "EOF"),
-		// 	size: 0,
-		// 	token: "",
-		// },
-		// "valid request with ID mismatch SPDK response": {
-		// 	in: testNvmePathID,
-		// 	out: nil,
-		// 	spdk: []string{`{"id":0,"error":{"code":0,"message":""},"result":[]}`},
-		// 	errCode: codes.Unknown,
-		// 	errMsg: fmt.Sprintf("bdev_nvme_get_controllers: %v", "json response ID mismatch"),
-		// 	size: 0,
-		// 	token: "",
-		// },
-		// "valid request with error code from SPDK response": {
-		// 	in: testNvmePathID,
-		// 	out: nil,
-		// 	spdk: []string{`{"id":%d,"error":{"code":1,"message":"myopierr"}}`},
-		// 	errCode: codes.Unknown,
-		// 	errMsg: fmt.Sprintf("bdev_nvme_get_controllers: %v", "json response error: myopierr"),
-		// 	size: 0,
-		// 	token: "",
-		// },
-		// "valid request with valid SPDK response": {
-		// 	in: testNvmePathID,
-		// 	out: []*pb.NvmePath{
-		// 		{
-		// 			Name:    "Malloc0",
-		// 			Trtype:  pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
-		// 			Adrfam:  pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4,
-		// 			Traddr:  "127.0.0.1",
-		// 			Trsvcid: 4444,
-		// 		},
-		// 		{
-		// 			Name:    "Malloc1",
-		// 			Trtype:  pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
-		// 			Adrfam:  pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4,
-		// 			Traddr:  "127.0.0.1",
-		// 			Trsvcid: 4444,
-		// 		},
-		// 	},
-		// 	spdk: []string{`{"jsonrpc":"2.0","id":%d,"result":[` +
-		// 		`{"name":"Malloc1","aliases":["88112c76-8c49-4395-955a-0d695b1d2099"],"product_name":"Malloc disk","block_size":512,"num_blocks":131072,"uuid":"88112c76-8c49-4395-955a-0d695b1d2099","assigned_rate_limits":{"rw_ios_per_sec":0,"rw_mbytes_per_sec":0,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0},"claimed":false,"zoned":false,"supported_io_types":{"read":true,"write":true,"unmap":true,"write_zeroes":true,"flush":true,"reset":true,"compare":false,"compare_and_write":false,"abort":true,"nvme_admin":false,"nvme_io":false},"driver_specific":{}},` +
-		// 		`{"name":"Malloc0","aliases":["11d3902e-d9bb-49a7-bb27-cd7261ef3217"],"product_name":"Malloc disk","block_size":512,"num_blocks":131072,"uuid":"11d3902e-d9bb-49a7-bb27-cd7261ef3217","assigned_rate_limits":{"rw_ios_per_sec":0,"rw_mbytes_per_sec":0,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0},"claimed":false,"zoned":false,"supported_io_types":{"read":true,"write":true,"unmap":true,"write_zeroes":true,"flush":true,"reset":true,"compare":false,"compare_and_write":false,"abort":true,"nvme_admin":false,"nvme_io":false},"driver_specific":{}}` +
-		// 		`]}`},
-		// 	errCode: codes.OK,
-		// 	errMsg: "",
-		// 	size: 0,
-		// 	token: "",
-		// },
-		// "pagination overflow": {
-		// 	in: testNvmePathID,
-		// 	out: []*pb.NvmePath{
-		// 		{
-		// 			Name:    "Malloc0",
-		// 			Trtype:  pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
-		// 			Adrfam:  pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4,
-		// 			Traddr:  "127.0.0.1",
-		// 			Trsvcid: 4444,
-		// 		},
-		// 		{
-		// 			Name:    "Malloc1",
-		// 			Trtype:  pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
-		// 			Adrfam:  pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4,
-		// 			Traddr:  "127.0.0.1",
-		// 			Trsvcid: 4444,
-		// 		},
-		// 	},
-		// 	spdk: []string{`{"jsonrpc":"2.0","id":%d,"result":[{"name":"Malloc0","aliases":["11d3902e-d9bb-49a7-bb27-cd7261ef3217"],"product_name":"Malloc disk","block_size":512,"num_blocks":131072,"uuid":"11d3902e-d9bb-49a7-bb27-cd7261ef3217","assigned_rate_limits":{"rw_ios_per_sec":0,"rw_mbytes_per_sec":0,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0},"claimed":false,"zoned":false,"supported_io_types":{"read":true,"write":true,"unmap":true,"write_zeroes":true,"flush":true,"reset":true,"compare":false,"compare_and_write":false,"abort":true,"nvme_admin":false,"nvme_io":false},"driver_specific":{}},{"name":"Malloc1","aliases":["88112c76-8c49-4395-955a-0d695b1d2099"],"product_name":"Malloc disk","block_size":512,"num_blocks":131072,"uuid":"88112c76-8c49-4395-955a-0d695b1d2099","assigned_rate_limits":{"rw_ios_per_sec":0,"rw_mbytes_per_sec":0,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0},"claimed":false,"zoned":false,"supported_io_types":{"read":true,"write":true,"unmap":true,"write_zeroes":true,"flush":true,"reset":true,"compare":false,"compare_and_write":false,"abort":true,"nvme_admin":false,"nvme_io":false},"driver_specific":{}}]}`},
-		// 	errCode: codes.OK,
-		// 	errMsg: "",
-		// 	size: 1000,
-		// 	token: "",
-		// },
-		// "pagination negative": {
-		// 	in: testNvmePathID,
-		// 	out: nil,
-		// 	spdk: []string{},
-		// 	errCode: codes.InvalidArgument,
-		// 	errMsg: "negative PageSize is not allowed",
-		// 	size: -10,
-		// 	token: "",
-		// },
-		// "pagination error": {
-		// 	in: testNvmePathID,
-		// 	out: nil,
-		// 	spdk: []string{},
-		// 	errCode: codes.NotFound,
-		// 	errMsg: fmt.Sprintf("unable to find pagination token %s", "unknown-pagination-token"),
-		// 	size: 0,
-		// 	token: "unknown-pagination-token",
-		// },
-		// "pagination": {
-		// 	in: testNvmePathID,
-		// 	out: []*pb.NvmePath{
-		// 		{
-		// 			Name:    "Malloc0",
-		// 			Trtype:  pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
-		// 			Adrfam:  pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4,
-		// 			Traddr:  "127.0.0.1",
-		// 			Trsvcid: 4444,
-		// 		},
-		// 	},
-		// 	spdk: []string{`{"jsonrpc":"2.0","id":%d,"result":[{"name":"Malloc0","aliases":["11d3902e-d9bb-49a7-bb27-cd7261ef3217"],"product_name":"Malloc disk","block_size":512,"num_blocks":131072,"uuid":"11d3902e-d9bb-49a7-bb27-cd7261ef3217","assigned_rate_limits":{"rw_ios_per_sec":0,"rw_mbytes_per_sec":0,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0},"claimed":false,"zoned":false,"supported_io_types":{"read":true,"write":true,"unmap":true,"write_zeroes":true,"flush":true,"reset":true,"compare":false,"compare_and_write":false,"abort":true,"nvme_admin":false,"nvme_io":false},"driver_specific":{}},{"name":"Malloc1","aliases":["88112c76-8c49-4395-955a-0d695b1d2099"],"product_name":"Malloc disk","block_size":512,"num_blocks":131072,"uuid":"88112c76-8c49-4395-955a-0d695b1d2099","assigned_rate_limits":{"rw_ios_per_sec":0,"rw_mbytes_per_sec":0,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0},"claimed":false,"zoned":false,"supported_io_types":{"read":true,"write":true,"unmap":true,"write_zeroes":true,"flush":true,"reset":true,"compare":false,"compare_and_write":false,"abort":true,"nvme_admin":false,"nvme_io":false},"driver_specific":{}}]}`},
-		// 	errCode: codes.OK,
-		// 	errMsg: "",
-		// 	size: 1,
-		// 	token: "",
-		// },
-		// "pagination offset": {
-		// 	in: testNvmePathID,
-		// 	out: []*pb.NvmePath{
-		// 		{
-		// 			Name:    "Malloc1",
-		// 			Trtype:  pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
-		// 			Adrfam:  pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4,
-		// 			Traddr:  "127.0.0.1",
-		// 			Trsvcid: 4444,
-		// 		},
-		// 	},
-		// 	spdk: []string{`{"jsonrpc":"2.0","id":%d,"result":[{"name":"Malloc0","aliases":["11d3902e-d9bb-49a7-bb27-cd7261ef3217"],"product_name":"Malloc disk","block_size":512,"num_blocks":131072,"uuid":"11d3902e-d9bb-49a7-bb27-cd7261ef3217","assigned_rate_limits":{"rw_ios_per_sec":0,"rw_mbytes_per_sec":0,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0},"claimed":false,"zoned":false,"supported_io_types":{"read":true,"write":true,"unmap":true,"write_zeroes":true,"flush":true,"reset":true,"compare":false,"compare_and_write":false,"abort":true,"nvme_admin":false,"nvme_io":false},"driver_specific":{}},{"name":"Malloc1","aliases":["88112c76-8c49-4395-955a-0d695b1d2099"],"product_name":"Malloc disk","block_size":512,"num_blocks":131072,"uuid":"88112c76-8c49-4395-955a-0d695b1d2099","assigned_rate_limits":{"rw_ios_per_sec":0,"rw_mbytes_per_sec":0,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0},"claimed":false,"zoned":false,"supported_io_types":{"read":true,"write":true,"unmap":true,"write_zeroes":true,"flush":true,"reset":true,"compare":false,"compare_and_write":false,"abort":true,"nvme_admin":false,"nvme_io":false},"driver_specific":{}}]}`},
-		// 	errCode: codes.OK,
-		// 	errMsg: "",
-		// 	size: 1,
-		// 	token: "existing-pagination-token",
-		// },
+		"valid request with empty SPDK result and no stored paths": {
+			in:      testNvmeCtrlName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[]}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    0,
+			token:   "",
+			stored:  false,
+		},
+		"valid request with empty SPDK result returns stored paths": {
+			in:      testNvmeCtrlName,
+			out:     []*pb.NvmePath{&testNvmePathWithName, testNvmePath2},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[]}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    0,
+			token:   "",
+			stored:  true,
+		},
+		"valid request with invalid marshal SPDK response": {
+			in:      testNvmeCtrlName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":false}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("bdev_nvme_get_controllers: %v", "json: cannot unmarshal bool into Go value of type []spdk.BdevNvmeGetControllerResult"),
+			size:    0,
+			token:   "",
+			stored:  true,
+		},
+		"valid request with empty SPDK response": {
+			in:      testNvmeCtrlName,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("bdev_nvme_get_controllers: %v", "EOF"),
+			size:    0,
+			token:   "",
+			stored:  true,
+		},
+		"valid request with ID mismatch SPDK response": {
+			in:      testNvmeCtrlName,
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":[]}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("bdev_nvme_get_controllers: %v", "json response ID mismatch"),
+			size:    0,
+			token:   "",
+			stored:  true,
+		},
+		"valid request with error code from SPDK response": {
+			in:      testNvmeCtrlName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("bdev_nvme_get_controllers: %v", "json response error: myopierr"),
+			size:    0,
+			token:   "",
+			stored:  true,
+		},
+		"valid request with valid SPDK response": {
+			in: testNvmeCtrlName,
+			out: []*pb.NvmePath{
+				listedNvmePath(testNvmePathName, 4444, 53412),
+				listedNvmePath(testNvmePath2Name, 4445, 53413),
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"opi-nvme8","ctrlrs":[{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"127.0.0.1","trsvcid":"4444","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":1,"host":{"nqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","addr":"127.0.0.1","svcid":"53412"}},{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"127.0.0.1","trsvcid":"4445","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":2,"host":{"nqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","addr":"127.0.0.1","svcid":"53413"}}]},{"name":"other","ctrlrs":[]}]}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    0,
+			token:   "",
+			stored:  true,
+		},
+		"valid request with controller without paths": {
+			in:      utils.ResourceIDToRemoteControllerName("other"),
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"opi-nvme8","ctrlrs":[{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"127.0.0.1","trsvcid":"4444","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":1,"host":{"nqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","addr":"127.0.0.1","svcid":"53412"}},{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"127.0.0.1","trsvcid":"4445","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":2,"host":{"nqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","addr":"127.0.0.1","svcid":"53413"}}]},{"name":"other","ctrlrs":[]}]}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    0,
+			token:   "",
+			stored:  true,
+		},
+		"pagination overflow": {
+			in: testNvmeCtrlName,
+			out: []*pb.NvmePath{
+				listedNvmePath(testNvmePathName, 4444, 53412),
+				listedNvmePath(testNvmePath2Name, 4445, 53413),
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"opi-nvme8","ctrlrs":[{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"127.0.0.1","trsvcid":"4444","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":1,"host":{"nqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","addr":"127.0.0.1","svcid":"53412"}},{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"127.0.0.1","trsvcid":"4445","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":2,"host":{"nqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","addr":"127.0.0.1","svcid":"53413"}}]},{"name":"other","ctrlrs":[]}]}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    1000,
+			token:   "",
+			stored:  true,
+		},
+		"pagination negative": {
+			in:      testNvmeCtrlName,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "negative PageSize is not allowed",
+			size:    -10,
+			token:   "",
+			stored:  true,
+		},
+		"pagination error": {
+			in:      testNvmeCtrlName,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find pagination token %s", "unknown-pagination-token"),
+			size:    0,
+			token:   "unknown-pagination-token",
+			stored:  true,
+		},
+		"pagination": {
+			in: testNvmeCtrlName,
+			out: []*pb.NvmePath{
+				listedNvmePath(testNvmePathName, 4444, 53412),
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"opi-nvme8","ctrlrs":[{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"127.0.0.1","trsvcid":"4444","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":1,"host":{"nqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","addr":"127.0.0.1","svcid":"53412"}},{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"127.0.0.1","trsvcid":"4445","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":2,"host":{"nqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","addr":"127.0.0.1","svcid":"53413"}}]},{"name":"other","ctrlrs":[]}]}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    1,
+			token:   "",
+			stored:  true,
+		},
+		"pagination offset": {
+			in: testNvmeCtrlName,
+			out: []*pb.NvmePath{
+				listedNvmePath(testNvmePath2Name, 4445, 53413),
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"opi-nvme8","ctrlrs":[{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"127.0.0.1","trsvcid":"4444","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":1,"host":{"nqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","addr":"127.0.0.1","svcid":"53412"}},{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"127.0.0.1","trsvcid":"4445","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":2,"host":{"nqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","addr":"127.0.0.1","svcid":"53413"}}]},{"name":"other","ctrlrs":[]}]}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    1,
+			token:   "existing-pagination-token",
+			stored:  true,
+		},
 		"no required field": {
 			in:      "",
 			out:     []*pb.NvmePath{},
@@ -779,6 +972,7 @@ func TestBackEnd_ListNvmePaths(t *testing.T) {
 			errMsg:  "missing required field: parent",
 			size:    0,
 			token:   "",
+			stored:  true,
 		},
 	}
 
@@ -788,7 +982,11 @@ func TestBackEnd_ListNvmePaths(t *testing.T) {
 			testEnv := createTestEnvironment(tt.spdk)
 			defer testEnv.Close()
 
-			testEnv.opiSpdkServer.Pagination["existing-pagination-token"] = 1
+			testEnv.opiSpdkServer.Pagination.Set("existing-pagination-token", 1)
+			if tt.stored {
+				testEnv.opiSpdkServer.Volumes.NvmePaths[testNvmePathName] = utils.ProtoClone(&testNvmePathWithName)
+				testEnv.opiSpdkServer.Volumes.NvmePaths[testNvmePath2.Name] = utils.ProtoClone(testNvmePath2)
+			}
 
 			request := &pb.ListNvmePathsRequest{Parent: tt.in, PageSize: tt.size, PageToken: tt.token}
 			response, err := testEnv.client.ListNvmePaths(testEnv.ctx, request)
@@ -825,13 +1023,13 @@ func TestBackEnd_GetNvmePath(t *testing.T) {
 		errCode codes.Code
 		errMsg  string
 	}{
-		// "valid request with invalid SPDK response": {
-		// 	in: testNvmePathName,
-		// 	out: nil,
-		// 	spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":[]}`},
-		// 	errCode: codes.InvalidArgument,
-		// 	errMsg: fmt.Sprintf("expecting exactly 1 result, got %v", "0"),
-		// },
+		"valid request with invalid SPDK response": {
+			in:      testNvmePathName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[]}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not find NQN: %s", "nqn.2016-06.io.spdk:cnode1"),
+		},
 		"valid request with invalid marshal SPDK response": {
 			in:      testNvmePathName,
 			out:     nil,
@@ -860,19 +1058,58 @@ func TestBackEnd_GetNvmePath(t *testing.T) {
 			errCode: codes.Unknown,
 			errMsg:  fmt.Sprintf("bdev_nvme_get_controllers: %v", "json response error: myopierr"),
 		},
-		// "valid request with valid SPDK response": {
-		// 	in: testNvmePathName,
-		// 	out: &pb.NvmePath{
-		// 		Name:    "Malloc1",
-		// 		Trtype:  pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
-		// 		Adrfam:  pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4,
-		// 		Traddr:  "127.0.0.1",
-		// 		Trsvcid: 4444,
-		// 	},
-		// 	spdk: []string{`{"jsonrpc":"2.0","id":%d,"result":[{"name":"Malloc1","aliases":["88112c76-8c49-4395-955a-0d695b1d2099"],"product_name":"Malloc disk","block_size":512,"num_blocks":131072,"uuid":"88112c76-8c49-4395-955a-0d695b1d2099","assigned_rate_limits":{"rw_ios_per_sec":0,"rw_mbytes_per_sec":0,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0},"claimed":false,"zoned":false,"supported_io_types":{"read":true,"write":true,"unmap":true,"write_zeroes":true,"flush":true,"reset":true,"compare":false,"compare_and_write":false,"abort":true,"nvme_admin":false,"nvme_io":false},"driver_specific":{}}]}`},
-		// 	errCode: codes.OK,
-		// 	errMsg: "",
-		// },
+		"valid request with valid SPDK response": {
+			in: testNvmePathName,
+			out: &pb.NvmePath{
+				Name:   testNvmePathName,
+				Trtype: pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
+				Traddr: "127.0.0.1",
+				Fabrics: &pb.FabricsPath{
+					Adrfam:        pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4,
+					Subnqn:        "nqn.2016-06.io.spdk:cnode1",
+					Hostnqn:       "nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c",
+					Trsvcid:       4444,
+					SourceTraddr:  "127.0.0.1",
+					SourceTrsvcid: 53412,
+				},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"opi-nvme8","ctrlrs":[{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"127.0.0.1","trsvcid":"4444","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":1,"host":{"nqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","addr":"127.0.0.1","svcid":"53412"}}]}]}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with negotiated address different from requested": {
+			in: testNvmePathName,
+			out: &pb.NvmePath{
+				Name:   testNvmePathName,
+				Trtype: pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
+				Traddr: "10.0.0.2",
+				Fabrics: &pb.FabricsPath{
+					Adrfam:        pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4,
+					Subnqn:        "nqn.2016-06.io.spdk:cnode1",
+					Hostnqn:       "nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c",
+					Trsvcid:       4420,
+					SourceTraddr:  "10.0.0.1",
+					SourceTrsvcid: 53412,
+				},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"other-controller","ctrlrs":[]},{"name":"opi-nvme8","ctrlrs":[{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"10.0.0.2","trsvcid":"4420","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":1,"host":{"nqn":"nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c","addr":"10.0.0.1","svcid":"53412"}}]}]}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with controller not found in SPDK response": {
+			in:      testNvmePathName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"other-controller","ctrlrs":[{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"127.0.0.1","trsvcid":"4444","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":1,"host":{"nqn":"","addr":"127.0.0.1","svcid":"53412"}}]}]}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not find NQN: %v", "nqn.2016-06.io.spdk:cnode1"),
+		},
+		"valid request with ambiguous paths in SPDK response": {
+			in:      testNvmePathName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"opi-nvme8","ctrlrs":[{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"10.0.0.2","trsvcid":"4420","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":1,"host":{"nqn":"","addr":"","svcid":""}},{"state":"enabled","trid":{"trtype":"TCP","adrfam":"IPv4","traddr":"10.0.0.3","trsvcid":"4420","subnqn":"nqn.2016-06.io.spdk:cnode1"},"cntlid":2,"host":{"nqn":"","addr":"","svcid":""}}]}]}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not find NQN: %v", "nqn.2016-06.io.spdk:cnode1"),
+		},
 		"valid request with unknown key": {
 			in:      "unknown-id",
 			out:     nil,
@@ -934,55 +1171,77 @@ func TestBackEnd_StatsNvmePath(t *testing.T) {
 		errCode codes.Code
 		errMsg  string
 	}{
-		// "valid request with invalid SPDK response": {
-		// 	in: testNvmePathName,
-		// 	out: nil,
-		// 	spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"tick_rate":0,"ticks":0,"bdevs":null}}`},
-		// 	errCode: codes.InvalidArgument,
-		// 	errMsg: fmt.Sprintf("expecting exactly 1 result, got %v", "0"),
-		// },
-		// "valid request with invalid marshal SPDK response": {
-		// 	in: testNvmePathName,
-		// 	out: nil,
-		// 	spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":false}`},
-		// 	errCode: codes.Unknown,
-		// 	errMsg: fmt.Sprintf("bdev_get_iostat: %v", "json: cannot unmarshal bool into Go value of type spdk.BdevGetIostatResult"),
-		// },
-		// "valid request with empty SPDK response": {
-		// 	in: testNvmePathName,
-		// 	out: nil,
-		// 	spdk: []string{""},
-		// 	errCode: codes.Unknown,
-		// 	errMsg: fmt.Sprintf("bdev_get_iostat: %v", "EOF"),
-		// },
-		// "valid request with ID mismatch SPDK response": {
-		// 	in: testNvmePathName,
-		// 	out: nil,
-		// 	spdk: []string{`{"id":0,"error":{"code":0,"message":""},"result":{"tick_rate":0,"ticks":0,"bdevs":null}}`},
-		// 	errCode: codes.Unknown,
-		// 	errMsg: fmt.Sprintf("bdev_get_iostat: %v", "json response ID mismatch"),
-		// },
-		// "valid request with error code from SPDK response": {
-		// 	in: testNvmePathName,
-		// 	out: nil,
-		// 	spdk: []string{`{"id":%d,"error":{"code":1,"message":"myopierr"}}`},
-		// 	errCode: codes.Unknown,
-		// 	errMsg: fmt.Sprintf("bdev_get_iostat: %v", "json response error: myopierr"),
-		// },
-		// "valid request with valid SPDK response": {
-		// 	in: testNvmePathName,
-		// 	out: &pb.VolumeStats{
-		// 		ReadBytesCount:    1,
-		// 		ReadOpsCount:      2,
-		// 		WriteBytesCount:   3,
-		// 		WriteOpsCount:     4,
-		// 		ReadLatencyTicks:  7,
-		// 		WriteLatencyTicks: 8,
-		// 	},
-		// 	spdk: []string{`{"jsonrpc":"2.0","id":%d,"result":{"tick_rate":2490000000,"ticks":18787040917434338,"bdevs":[{"name":"mytest","bytes_read":1,"num_read_ops":2,"bytes_written":3,"num_write_ops":4,"bytes_unmapped":0,"num_unmap_ops":0,"read_latency_ticks":7,"write_latency_ticks":8,"unmap_latency_ticks":0}]}}`},
-		// 	errCode: codes.OK,
-		// 	errMsg: "",
-		// },
+		"valid request with invalid SPDK response": {
+			in:      testNvmePathName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"tick_rate":0,"ticks":0,"bdevs":null}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("expecting at least 1 result, got %v", "0"),
+		},
+		"valid request with invalid marshal SPDK response": {
+			in:      testNvmePathName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":false}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("bdev_get_iostat: %v", "json: cannot unmarshal bool into Go value of type spdk.BdevGetIostatResult"),
+		},
+		"valid request with empty SPDK response": {
+			in:      testNvmePathName,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("bdev_get_iostat: %v", "EOF"),
+		},
+		"valid request with ID mismatch SPDK response": {
+			in:      testNvmePathName,
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"tick_rate":0,"ticks":0,"bdevs":null}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("bdev_get_iostat: %v", "json response ID mismatch"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      testNvmePathName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("bdev_get_iostat: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in: testNvmePathName,
+			out: &pb.VolumeStats{
+				ReadBytesCount:    1,
+				ReadOpsCount:      2,
+				WriteBytesCount:   3,
+				WriteOpsCount:     4,
+				ReadLatencyTicks:  7,
+				WriteLatencyTicks: 8,
+			},
+			spdk:    []string{`{"jsonrpc":"2.0","id":%d,"result":{"tick_rate":2490000000,"ticks":18787040917434338,"bdevs":[{"name":"opi-nvme8n1","bytes_read":1,"num_read_ops":2,"bytes_written":3,"num_write_ops":4,"bytes_unmapped":0,"num_unmap_ops":0,"read_latency_ticks":7,"write_latency_ticks":8,"unmap_latency_ticks":0}]}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with multiple namespaces SPDK response": {
+			in: testNvmePathName,
+			out: &pb.VolumeStats{
+				ReadBytesCount:    11,
+				ReadOpsCount:      22,
+				WriteBytesCount:   33,
+				WriteOpsCount:     44,
+				UnmapBytesCount:   55,
+				UnmapOpsCount:     66,
+				ReadLatencyTicks:  77,
+				WriteLatencyTicks: 88,
+				UnmapLatencyTicks: 99,
+			},
+			spdk: []string{`{"jsonrpc":"2.0","id":%d,"result":{"tick_rate":2490000000,"ticks":18787040917434338,"bdevs":[` +
+				`{"name":"opi-nvme8n1","bytes_read":1,"num_read_ops":2,"bytes_written":3,"num_write_ops":4,"bytes_unmapped":5,"num_unmap_ops":6,"read_latency_ticks":7,"write_latency_ticks":8,"unmap_latency_ticks":9},` +
+				`{"name":"opi-nvme8n2","bytes_read":10,"num_read_ops":20,"bytes_written":30,"num_write_ops":40,"bytes_unmapped":50,"num_unmap_ops":60,"read_latency_ticks":70,"write_latency_ticks":80,"unmap_latency_ticks":90},` +
+				`{"name":"opi-nvme80n1","bytes_read":100,"num_read_ops":100,"bytes_written":100,"num_write_ops":100,"bytes_unmapped":100,"num_unmap_ops":100,"read_latency_ticks":100,"write_latency_ticks":100,"unmap_latency_ticks":100},` +
+				`{"name":"Malloc0","bytes_read":100,"num_read_ops":100,"bytes_written":100,"num_write_ops":100,"bytes_unmapped":100,"num_unmap_ops":100,"read_latency_ticks":100,"write_latency_ticks":100,"unmap_latency_ticks":100}` +
+				`]}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
 		"valid request with unknown key": {
 			in:      "unknown-id",
 			out:     nil,
@@ -1027,3 +1286,32 @@ func TestBackEnd_StatsNvmePath(t *testing.T) {
 		})
 	}
 }
+
+func TestBackEnd_StatsNvmePathSharedStats(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"jsonrpc":"2.0","id":%d,"result":{"tick_rate":2490000000,"ticks":18787040917434338,"bdevs":[{"name":"opi-nvme8n1","bytes_read":1,"num_read_ops":2,"bytes_written":3,"num_write_ops":4,"bytes_unmapped":0,"num_unmap_ops":0,"read_latency_ticks":7,"write_latency_ticks":8,"unmap_latency_ticks":0}]}}`,
+	})
+	defer testEnv.Close()
+	testEnv.opiSpdkServer.SetStatsCollector(utils.NewStatsCollector(testEnv.opiSpdkServer.rpc, time.Hour))
+	testEnv.opiSpdkServer.Volumes.NvmePaths[testNvmePathName] = utils.ProtoClone(&testNvmePathWithName)
+	expected := &pb.VolumeStats{
+		ReadBytesCount:    1,
+		ReadOpsCount:      2,
+		WriteBytesCount:   3,
+		WriteOpsCount:     4,
+		ReadLatencyTicks:  7,
+		WriteLatencyTicks: 8,
+	}
+
+	// the second request is served by stats of the first SPDK call
+	for i := 0; i < 2; i++ {
+		response, err := testEnv.client.StatsNvmePath(testEnv.ctx, &pb.StatsNvmePathRequest{Name: testNvmePathName})
+		if err != nil {
+			t.Fatal("request", i, "expected no error, received", err)
+		}
+		if !proto.Equal(response.GetStats(), expected) {
+			t.Error("request", i, "response: expected", expected, "received", response.GetStats())
+		}
+	}
+}
@@ -13,42 +13,42 @@ import (
 	"google.golang.org/grpc/status"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
 
 func (s *Server) validateCreateNvmePathRequest(in *pb.CreateNvmePathRequest) error {
+	v := &utils.Validator{}
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
-	}
+	v.CheckRequiredFields(in)
 	// see https://google.aip.dev/133#user-specified-ids
 	if in.NvmePathId != "" {
-		if err := resourceid.ValidateUserSettable(in.NvmePathId); err != nil {
-			return err
-		}
+		v.Check("nvme_path_id", resourceid.ValidateUserSettable(in.NvmePathId))
 	}
 	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	if err := resourcename.Validate(in.Parent); err != nil {
-		return err
+	if in.Parent != "" {
+		v.Check("parent", resourcename.Validate(in.Parent))
 	}
 	// validate Fabrics and Type coordinated
-	switch in.NvmePath.Trtype {
-	case pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE:
-		if in.NvmePath.Fabrics != nil {
-			err := status.Errorf(codes.InvalidArgument, "fabrics field is not allowed for pcie transport")
-			return err
-		}
-	case pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP:
-		fallthrough
-	case pb.NvmeTransportType_NVME_TRANSPORT_TYPE_RDMA:
-		if in.NvmePath.Fabrics == nil {
-			err := status.Errorf(codes.InvalidArgument, "missing required field for fabrics transports: fabrics")
-			return err
+	if in.NvmePath != nil {
+		switch in.NvmePath.Trtype {
+		case pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE:
+			if in.NvmePath.Fabrics != nil {
+				v.Check("nvme_path.fabrics", status.Errorf(codes.InvalidArgument, "fabrics field is not allowed for pcie transport"))
+			}
+		case pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP:
+			fallthrough
+		case pb.NvmeTransportType_NVME_TRANSPORT_TYPE_RDMA:
+			if in.NvmePath.Fabrics == nil {
+				v.Check("nvme_path.fabrics", status.Errorf(codes.InvalidArgument, "missing required field for fabrics transports: fabrics"))
+			}
+			if in.NvmePath.GetFabrics().GetHostnqn() != "" {
+				v.Check("nvme_path.fabrics.hostnqn", utils.ValidateNqn(in.NvmePath.Fabrics.Hostnqn))
+			}
+		default:
+			v.Check("nvme_path.trtype", status.Errorf(codes.InvalidArgument, "not supported transport type: %v", in.NvmePath.Trtype))
 		}
-	default:
-		err := status.Errorf(codes.InvalidArgument, "not supported transport type: %v", in.NvmePath.Trtype)
-		return err
 	}
-	return nil
+	return v.Err()
 }
 
 func (s *Server) validateDeleteNvmePathRequest(in *pb.DeleteNvmePathRequest) error {
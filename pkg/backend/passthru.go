@@ -0,0 +1,358 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"github.com/google/uuid"
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// PassthruVolumeServiceName is full name of the service creating passthru
+// bdevs on top of other bdevs. It is not part of OPI API, so it is
+// registered with a hand written service descriptor
+const PassthruVolumeServiceName = "opi_spdk_bridge.v1.PassthruVolumeService"
+
+// spdkNoSuchDevice is code of error SPDK answers with for unknown bdev
+const spdkNoSuchDevice = -19
+
+// bdevPassthruCreateParams are parameters of bdev_passthru_create
+// TODO: use spdk.BdevPassthruCreateParams when gospdk provides it
+type bdevPassthruCreateParams struct {
+	BaseBdevName string `json:"base_bdev_name"`
+	Name         string `json:"name"`
+}
+
+// bdevPassthruDeleteParams are parameters of bdev_passthru_delete
+type bdevPassthruDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// PassthruVolume is passthru bdev forwarding all IOs to the bdev it is
+// layered on, useful to insert a layer between volumes for testing
+type PassthruVolume struct {
+	Name string `json:"name"`
+	// VolumeNameRef is name of the base bdev IOs are passed to
+	VolumeNameRef string `json:"volume_name_ref"`
+}
+
+// GetName returns name of passthru volume
+func (v *PassthruVolume) GetName() string {
+	return v.Name
+}
+
+// passthruVolumeRequest is CreatePassthruVolume request
+type passthruVolumeRequest struct {
+	PassthruVolumeID string `json:"passthru_volume_id"`
+	VolumeNameRef    string `json:"volume_name_ref"`
+}
+
+// passthruListRequest is ListPassthruVolumes request
+type passthruListRequest struct {
+	PageSize  int32  `json:"page_size"`
+	PageToken string `json:"page_token"`
+}
+
+// passthruListResponse is ListPassthruVolumes response
+type passthruListResponse struct {
+	PassthruVolumes []*PassthruVolume `json:"passthru_volumes"`
+	NextPageToken   string            `json:"next_page_token"`
+}
+
+func sortPassthruVolumes(volumes []*PassthruVolume) {
+	sort.Slice(volumes, func(i int, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+}
+
+// decodeStruct converts struct request to its Go representation
+func decodeStruct(in *structpb.Struct, request interface{}) error {
+	data, err := in.MarshalJSON()
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	if err := json.Unmarshal(data, request); err != nil {
+		return status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+// encodeStruct converts Go representation of response to struct
+func encodeStruct(response interface{}) (*structpb.Struct, error) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	encoded := &structpb.Struct{}
+	if err := encoded.UnmarshalJSON(data); err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	return encoded, nil
+}
+
+// checkBaseBdevExists fails with NotFound if SPDK has no bdev named
+// bdevName, so that passthru volume is not created on a missing one
+func (s *Server) checkBaseBdevExists(ctx context.Context, bdevName string) error {
+	params := spdk.BdevGetBdevsParams{
+		Name: bdevName,
+	}
+	var result []spdk.BdevGetBdevsResult
+	err := s.rpc.Call(ctx, "bdev_get_bdevs", &params, &result)
+	var spdkErr *utils.SpdkError
+	if errors.As(err, &spdkErr) && spdkErr.Code == spdkNoSuchDevice {
+		return status.Errorf(codes.NotFound, "unable to find base bdev %s", bdevName)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	if len(result) != 1 {
+		msg := fmt.Sprintf("expecting exactly 1 result, got %d", len(result))
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+// CreatePassthruVolume creates passthru bdev on top of existing bdev.
+// Request is a struct with optional passthru_volume_id and volume_name_ref
+// naming the base bdev, response is PassthruVolume struct
+func (s *Server) CreatePassthruVolume(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	var request passthruVolumeRequest
+	if err := decodeStruct(in, &request); err != nil {
+		return nil, err
+	}
+	// check input correctness
+	if request.VolumeNameRef == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "missing required field: volume_name_ref")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if request.PassthruVolumeID != "" {
+		if err := resourceid.ValidateUserSettable(request.PassthruVolumeID); err != nil {
+			msg := fmt.Sprintf("invalid passthru_volume_id: %v", err)
+			return nil, status.Errorf(codes.InvalidArgument, msg)
+		}
+	}
+	resourceID, err := s.idempotencyKeys.ResourceID(ctx, "PassthruVolume", request.PassthruVolumeID)
+	if err != nil {
+		return nil, err
+	}
+	name := utils.ResourceIDToVolumeName(resourceID)
+	unlock := s.resourceLocks.Lock(name)
+	defer unlock()
+	// idempotent API when called with same key, should return same object
+	s.mapsMu.RLock()
+	volume, ok := s.Volumes.PassthruVolumes[name]
+	s.mapsMu.RUnlock()
+	if ok {
+		log.Printf("Already existing PassthruVolume with id %v", name)
+		return encodeStruct(volume)
+	}
+	// not found, so create a new one
+	if err := s.checkBaseBdevExists(ctx, request.VolumeNameRef); err != nil {
+		return nil, err
+	}
+	volume = &PassthruVolume{Name: name, VolumeNameRef: request.VolumeNameRef}
+	params := bdevPassthruCreateParams{
+		BaseBdevName: request.VolumeNameRef,
+		Name:         resourceID,
+	}
+	if utils.SkipSpdkCallInDryRun(ctx, "bdev_passthru_create", &params) {
+		return encodeStruct(volume)
+	}
+	var result string
+	err = s.rpc.Call(ctx, "bdev_passthru_create", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	if result == "" {
+		msg := fmt.Sprintf("Could not create Passthru Dev: %s", params.Name)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	s.mapsMu.Lock()
+	s.Volumes.PassthruVolumes[name] = volume
+	s.mapsMu.Unlock()
+	return encodeStruct(volume)
+}
+
+// DeletePassthruVolume deletes passthru bdev, leaving its base bdev in
+// place. Request is a struct with name and optional allow_missing
+func (s *Server) DeletePassthruVolume(ctx context.Context, in *structpb.Struct) (*emptypb.Empty, error) {
+	name := in.GetFields()["name"].GetStringValue()
+	if name == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "missing required field: name")
+	}
+	if err := resourcename.Validate(name); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	unlock := s.resourceLocks.Lock(name)
+	defer unlock()
+	// fetch object from the database
+	s.mapsMu.RLock()
+	volume, ok := s.Volumes.PassthruVolumes[name]
+	s.mapsMu.RUnlock()
+	if !ok {
+		if in.GetFields()["allow_missing"].GetBoolValue() {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+		return nil, err
+	}
+	params := bdevPassthruDeleteParams{
+		Name: utils.ResourceNameToID(volume.Name),
+	}
+	if utils.SkipSpdkCallInDryRun(ctx, "bdev_passthru_delete", &params) {
+		return &emptypb.Empty{}, nil
+	}
+	var result bool
+	err := s.rpc.Call(ctx, "bdev_passthru_delete", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	if !result {
+		msg := fmt.Sprintf("Could not delete Passthru Dev: %s", params.Name)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	s.mapsMu.Lock()
+	delete(s.Volumes.PassthruVolumes, volume.Name)
+	s.mapsMu.Unlock()
+	return &emptypb.Empty{}, nil
+}
+
+// GetPassthruVolume gets passthru volume checking SPDK still holds its
+// bdev. Request is a struct with name, response is PassthruVolume struct
+func (s *Server) GetPassthruVolume(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	name := in.GetFields()["name"].GetStringValue()
+	if name == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "missing required field: name")
+	}
+	if err := resourcename.Validate(name); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	// fetch object from the database
+	s.mapsMu.RLock()
+	volume, ok := s.Volumes.PassthruVolumes[name]
+	s.mapsMu.RUnlock()
+	if !ok {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+		return nil, err
+	}
+	params := spdk.BdevGetBdevsParams{
+		Name: utils.ResourceNameToID(volume.Name),
+	}
+	var result []spdk.BdevGetBdevsResult
+	err := s.rpc.Call(ctx, "bdev_get_bdevs", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	if len(result) != 1 {
+		msg := fmt.Sprintf("expecting exactly 1 result, got %d", len(result))
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	return encodeStruct(volume)
+}
+
+// ListPassthruVolumes lists passthru volumes. Request is a struct with
+// optional page_size and page_token, response is a struct with
+// passthru_volumes and next_page_token
+func (s *Server) ListPassthruVolumes(_ context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	var request passthruListRequest
+	if err := decodeStruct(in, &request); err != nil {
+		return nil, err
+	}
+	size, offset, perr := utils.ExtractPagination(request.PageSize, request.PageToken, s.Pagination)
+	if perr != nil {
+		return nil, perr
+	}
+	// fetch object from the database
+	s.mapsMu.RLock()
+	Blobarray := make([]*PassthruVolume, 0, len(s.Volumes.PassthruVolumes))
+	for _, volume := range s.Volumes.PassthruVolumes {
+		Blobarray = append(Blobarray, volume)
+	}
+	s.mapsMu.RUnlock()
+	sortPassthruVolumes(Blobarray)
+	token := ""
+	log.Printf("Limiting result len(%d) to [%d:%d]", len(Blobarray), offset, size)
+	Blobarray, hasMoreElements := utils.LimitPagination(Blobarray, offset, size)
+	if hasMoreElements {
+		token = uuid.New().String()
+		s.Pagination.Set(token, offset+size)
+	}
+	return encodeStruct(&passthruListResponse{PassthruVolumes: Blobarray, NextPageToken: token})
+}
+
+// passthruVolumeServiceServer is implemented by Server
+type passthruVolumeServiceServer interface {
+	CreatePassthruVolume(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	DeletePassthruVolume(context.Context, *structpb.Struct) (*emptypb.Empty, error)
+	GetPassthruVolume(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	ListPassthruVolumes(context.Context, *structpb.Struct) (*structpb.Struct, error)
+}
+
+func passthruVolumeHandler(method string, call func(passthruVolumeServiceServer, context.Context, *structpb.Struct) (interface{}, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(structpb.Struct)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(passthruVolumeServiceServer), ctx, in)
+			}
+			info := &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: "/" + PassthruVolumeServiceName + "/" + method,
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(passthruVolumeServiceServer), ctx, req.(*structpb.Struct))
+			}
+			return interceptor(ctx, in, info, handler)
+		},
+	}
+}
+
+var passthruVolumeServiceDesc = grpc.ServiceDesc{
+	ServiceName: PassthruVolumeServiceName,
+	HandlerType: (*passthruVolumeServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		passthruVolumeHandler("CreatePassthruVolume", func(srv passthruVolumeServiceServer, ctx context.Context, in *structpb.Struct) (interface{}, error) {
+			return srv.CreatePassthruVolume(ctx, in)
+		}),
+		passthruVolumeHandler("DeletePassthruVolume", func(srv passthruVolumeServiceServer, ctx context.Context, in *structpb.Struct) (interface{}, error) {
+			return srv.DeletePassthruVolume(ctx, in)
+		}),
+		passthruVolumeHandler("GetPassthruVolume", func(srv passthruVolumeServiceServer, ctx context.Context, in *structpb.Struct) (interface{}, error) {
+			return srv.GetPassthruVolume(ctx, in)
+		}),
+		passthruVolumeHandler("ListPassthruVolumes", func(srv passthruVolumeServiceServer, ctx context.Context, in *structpb.Struct) (interface{}, error) {
+			return srv.ListPassthruVolumes(ctx, in)
+		}),
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterPassthruVolumeServer registers passthru volume service on s
+func RegisterPassthruVolumeServer(s *grpc.Server, srv *Server) {
+	s.RegisterService(&passthruVolumeServiceDesc, srv)
+}
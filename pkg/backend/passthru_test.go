@@ -0,0 +1,468 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var (
+	testPassthruVolumeID   = "passthru-test"
+	testPassthruVolumeName = utils.ResourceIDToVolumeName(testPassthruVolumeID)
+	testPassthruVolume     = PassthruVolume{
+		Name:          testPassthruVolumeName,
+		VolumeNameRef: "Malloc0",
+	}
+)
+
+func newTestStruct(t *testing.T, fields map[string]interface{}) *structpb.Struct {
+	in, err := structpb.NewStruct(fields)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	return in
+}
+
+func checkStatus(t *testing.T, err error, errCode codes.Code, errMsg string) {
+	if er, ok := status.FromError(err); ok {
+		if er.Code() != errCode {
+			t.Error("error code: expected", errCode, "received", er.Code())
+		}
+		if er.Message() != errMsg {
+			t.Error("error message: expected", errMsg, "received", er.Message())
+		}
+	} else {
+		t.Error("expected grpc error status")
+	}
+}
+
+func TestBackEnd_CreatePassthruVolume(t *testing.T) {
+	tests := map[string]struct {
+		id      string
+		ref     string
+		exist   bool
+		spdk    []string
+		methods []string
+		params  []string
+		stored  bool
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			id:  testPassthruVolumeID,
+			ref: "Malloc0",
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"Malloc0"}]}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":"passthru-test"}`,
+			},
+			methods: []string{"bdev_get_bdevs", "bdev_passthru_create"},
+			params:  []string{`{"name":"Malloc0"}`, `{"base_bdev_name":"Malloc0","name":"passthru-test"}`},
+			stored:  true,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"missing base bdev": {
+			id:      testPassthruVolumeID,
+			ref:     "Malloc0",
+			spdk:    []string{`{"id":%d,"error":{"code":-19,"message":"No such device"},"result":null}`},
+			methods: []string{"bdev_get_bdevs"},
+			params:  []string{`{"name":"Malloc0"}`},
+			stored:  false,
+			errCode: codes.NotFound,
+			errMsg:  "unable to find base bdev Malloc0",
+		},
+		"error checking base bdev": {
+			id:      testPassthruVolumeID,
+			ref:     "Malloc0",
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":null}`},
+			methods: []string{"bdev_get_bdevs"},
+			params:  []string{`{"name":"Malloc0"}`},
+			stored:  false,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("bdev_get_bdevs: %v", "json response error: myopierr"),
+		},
+		"valid request with invalid SPDK response": {
+			id:  testPassthruVolumeID,
+			ref: "Malloc0",
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"Malloc0"}]}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":""}`,
+			},
+			methods: []string{"bdev_get_bdevs", "bdev_passthru_create"},
+			params:  []string{`{"name":"Malloc0"}`, `{"base_bdev_name":"Malloc0","name":"passthru-test"}`},
+			stored:  false,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create Passthru Dev: %v", testPassthruVolumeID),
+		},
+		"valid request with error code from SPDK response": {
+			id:  testPassthruVolumeID,
+			ref: "Malloc0",
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"Malloc0"}]}`,
+				`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":""}`,
+			},
+			methods: []string{"bdev_get_bdevs", "bdev_passthru_create"},
+			params:  []string{`{"name":"Malloc0"}`, `{"base_bdev_name":"Malloc0","name":"passthru-test"}`},
+			stored:  false,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("bdev_passthru_create: %v", "json response error: myopierr"),
+		},
+		"already exists": {
+			id:      testPassthruVolumeID,
+			ref:     "Malloc0",
+			exist:   true,
+			spdk:    []string{},
+			methods: nil,
+			params:  nil,
+			stored:  true,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"no required volume_name_ref field": {
+			id:      testPassthruVolumeID,
+			ref:     "",
+			spdk:    []string{},
+			methods: nil,
+			params:  nil,
+			stored:  false,
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: volume_name_ref",
+		},
+		"illegal resource_id": {
+			id:      "CapitalLettersNotAllowed",
+			ref:     "Malloc0",
+			spdk:    []string{},
+			methods: nil,
+			params:  nil,
+			stored:  false,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("invalid passthru_volume_id: user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+
+			if tt.exist {
+				volume := testPassthruVolume
+				testEnv.opiSpdkServer.Volumes.PassthruVolumes[testPassthruVolumeName] = &volume
+			}
+
+			in := newTestStruct(t, map[string]interface{}{
+				"passthru_volume_id": tt.id,
+				"volume_name_ref":    tt.ref,
+			})
+			response, err := testEnv.opiSpdkServer.CreatePassthruVolume(testEnv.ctx, in)
+
+			checkStatus(t, err, tt.errCode, tt.errMsg)
+			if !reflect.DeepEqual(recorder.methods, tt.methods) {
+				t.Error("methods: expected", tt.methods, "received", recorder.methods)
+			}
+			if !reflect.DeepEqual(recorder.params, tt.params) {
+				t.Error("params: expected", tt.params, "received", recorder.params)
+			}
+			if err == nil && response.GetFields()["name"].GetStringValue() != testPassthruVolumeName {
+				t.Error("response: expected name", testPassthruVolumeName, "received", response)
+			}
+			if err == nil && response.GetFields()["volume_name_ref"].GetStringValue() != testPassthruVolume.VolumeNameRef {
+				t.Error("response: expected volume_name_ref", testPassthruVolume.VolumeNameRef, "received", response)
+			}
+
+			volume, ok := testEnv.opiSpdkServer.Volumes.PassthruVolumes[testPassthruVolumeName]
+			if ok != tt.stored {
+				t.Error("expect passthru volume stored", tt.stored, "received", ok)
+			}
+			if ok && !reflect.DeepEqual(*volume, testPassthruVolume) {
+				t.Error("stored: expected", testPassthruVolume, "received", *volume)
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeletePassthruVolume(t *testing.T) {
+	tests := map[string]struct {
+		name         string
+		allowMissing bool
+		spdk         []string
+		methods      []string
+		remaining    bool
+		errCode      codes.Code
+		errMsg       string
+	}{
+		"valid request": {
+			name:      testPassthruVolumeName,
+			spdk:      []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			methods:   []string{"bdev_passthru_delete"},
+			remaining: false,
+			errCode:   codes.OK,
+			errMsg:    "",
+		},
+		"valid request with invalid SPDK response": {
+			name:      testPassthruVolumeName,
+			spdk:      []string{`{"id":%d,"error":{"code":0,"message":""},"result":false}`},
+			methods:   []string{"bdev_passthru_delete"},
+			remaining: true,
+			errCode:   codes.InvalidArgument,
+			errMsg:    fmt.Sprintf("Could not delete Passthru Dev: %s", testPassthruVolumeID),
+		},
+		"valid request with error code from SPDK response": {
+			name:      testPassthruVolumeName,
+			spdk:      []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":false}`},
+			methods:   []string{"bdev_passthru_delete"},
+			remaining: true,
+			errCode:   codes.Unknown,
+			errMsg:    fmt.Sprintf("bdev_passthru_delete: %v", "json response error: myopierr"),
+		},
+		"valid request with unknown key": {
+			name:      utils.ResourceIDToVolumeName("unknown-id"),
+			spdk:      []string{},
+			methods:   nil,
+			remaining: true,
+			errCode:   codes.NotFound,
+			errMsg:    fmt.Sprintf("unable to find key %v", utils.ResourceIDToVolumeName("unknown-id")),
+		},
+		"unknown key with missing allowed": {
+			name:         utils.ResourceIDToVolumeName("unknown-id"),
+			allowMissing: true,
+			spdk:         []string{},
+			methods:      nil,
+			remaining:    true,
+			errCode:      codes.OK,
+			errMsg:       "",
+		},
+		"malformed name": {
+			name:      utils.ResourceIDToVolumeName("-ABC-DEF"),
+			spdk:      []string{},
+			methods:   nil,
+			remaining: true,
+			errCode:   codes.InvalidArgument,
+			errMsg:    fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			name:      "",
+			spdk:      []string{},
+			methods:   nil,
+			remaining: true,
+			errCode:   codes.InvalidArgument,
+			errMsg:    "missing required field: name",
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+
+			volume := testPassthruVolume
+			testEnv.opiSpdkServer.Volumes.PassthruVolumes[testPassthruVolumeName] = &volume
+
+			in := newTestStruct(t, map[string]interface{}{
+				"name":          tt.name,
+				"allow_missing": tt.allowMissing,
+			})
+			_, err := testEnv.opiSpdkServer.DeletePassthruVolume(testEnv.ctx, in)
+
+			checkStatus(t, err, tt.errCode, tt.errMsg)
+			if !reflect.DeepEqual(recorder.methods, tt.methods) {
+				t.Error("methods: expected", tt.methods, "received", recorder.methods)
+			}
+			if _, ok := testEnv.opiSpdkServer.Volumes.PassthruVolumes[testPassthruVolumeName]; ok != tt.remaining {
+				t.Error("expect passthru volume remaining", tt.remaining, "received", ok)
+			}
+		})
+	}
+}
+
+func TestBackEnd_GetPassthruVolume(t *testing.T) {
+	tests := map[string]struct {
+		name    string
+		spdk    []string
+		out     *PassthruVolume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with valid SPDK response": {
+			name:    testPassthruVolumeName,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"passthru-test"}]}`},
+			out:     &testPassthruVolume,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with invalid SPDK response": {
+			name:    testPassthruVolumeName,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[]}`},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("expecting exactly 1 result, got %d", 0),
+		},
+		"valid request with error code from SPDK response": {
+			name:    testPassthruVolumeName,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":[]}`},
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("bdev_get_bdevs: %v", "json response error: myopierr"),
+		},
+		"valid request with unknown key": {
+			name:    utils.ResourceIDToVolumeName("unknown-id"),
+			spdk:    []string{},
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToVolumeName("unknown-id")),
+		},
+		"malformed name": {
+			name:    utils.ResourceIDToVolumeName("-ABC-DEF"),
+			spdk:    []string{},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			name:    "",
+			spdk:    []string{},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			volume := testPassthruVolume
+			testEnv.opiSpdkServer.Volumes.PassthruVolumes[testPassthruVolumeName] = &volume
+
+			in := newTestStruct(t, map[string]interface{}{"name": tt.name})
+			response, err := testEnv.opiSpdkServer.GetPassthruVolume(testEnv.ctx, in)
+
+			checkStatus(t, err, tt.errCode, tt.errMsg)
+			var out *PassthruVolume
+			if response != nil {
+				out = &PassthruVolume{
+					Name:          response.GetFields()["name"].GetStringValue(),
+					VolumeNameRef: response.GetFields()["volume_name_ref"].GetStringValue(),
+				}
+			}
+			if !reflect.DeepEqual(out, tt.out) {
+				t.Error("response: expected", tt.out, "received", out)
+			}
+		})
+	}
+}
+
+func TestBackEnd_ListPassthruVolumes(t *testing.T) {
+	otherVolume := PassthruVolume{
+		Name:          utils.ResourceIDToVolumeName("passthru-other"),
+		VolumeNameRef: "Null0",
+	}
+	tests := map[string]struct {
+		size    int32
+		token   string
+		out     []string
+		more    bool
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			size:    0,
+			token:   "",
+			out:     []string{otherVolume.Name, testPassthruVolumeName},
+			more:    false,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"pagination": {
+			size:    1,
+			token:   "",
+			out:     []string{otherVolume.Name},
+			more:    true,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"pagination offset": {
+			size:    1,
+			token:   "existing-pagination-token",
+			out:     []string{testPassthruVolumeName},
+			more:    false,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"pagination negative": {
+			size:    -10,
+			token:   "",
+			out:     nil,
+			more:    false,
+			errCode: codes.InvalidArgument,
+			errMsg:  "negative PageSize is not allowed",
+		},
+		"pagination error": {
+			size:    0,
+			token:   "unknown-pagination-token",
+			out:     nil,
+			more:    false,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find pagination token %s", "unknown-pagination-token"),
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			volume, other := testPassthruVolume, otherVolume
+			testEnv.opiSpdkServer.Volumes.PassthruVolumes[volume.Name] = &volume
+			testEnv.opiSpdkServer.Volumes.PassthruVolumes[other.Name] = &other
+			testEnv.opiSpdkServer.Pagination.Set("existing-pagination-token", 1)
+
+			in := newTestStruct(t, map[string]interface{}{
+				"page_size":  float64(tt.size),
+				"page_token": tt.token,
+			})
+			response, err := testEnv.opiSpdkServer.ListPassthruVolumes(testEnv.ctx, in)
+
+			checkStatus(t, err, tt.errCode, tt.errMsg)
+			var names []string
+			for _, value := range response.GetFields()["passthru_volumes"].GetListValue().GetValues() {
+				names = append(names, value.GetStructValue().GetFields()["name"].GetStringValue())
+			}
+			if !reflect.DeepEqual(names, tt.out) {
+				t.Error("response: expected", tt.out, "received", names)
+			}
+			if token := response.GetFields()["next_page_token"].GetStringValue(); (token != "") != tt.more {
+				t.Error("expected next page", tt.more, "received token", token)
+			}
+		})
+	}
+}
+
+func TestRegisterPassthruVolumeServer(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	s := grpc.NewServer()
+	RegisterPassthruVolumeServer(s, testEnv.opiSpdkServer)
+
+	info, ok := s.GetServiceInfo()[PassthruVolumeServiceName]
+	if !ok {
+		t.Fatal("expected", PassthruVolumeServiceName, "to be registered")
+	}
+	if len(info.Methods) != 4 {
+		t.Error("methods: expected 4, received", info.Methods)
+	}
+}
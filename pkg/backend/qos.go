@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+// QosProfileMetadataKey is request metadata key carrying JSON encoded
+// QosProfile overriding the default one on volume create, since volume
+// protos have no such field
+const QosProfileMetadataKey = "opi-qos-profile"
+
+// QosProfileHeaderKey is response header key carrying JSON encoded
+// AppliedQosProfile of the volume returned by Create and Get calls
+const QosProfileHeaderKey = "opi-qos-profile"
+
+const (
+	// QosProfileSourceDefault means the configured default profile was applied
+	QosProfileSourceDefault = "default"
+	// QosProfileSourceExplicit means the profile provided in request was applied
+	QosProfileSourceExplicit = "explicit"
+)
+
+// QosProfile contains rate limits applied to a volume. Zero means no limit
+type QosProfile struct {
+	RwIosPerSec    int `json:"rw_ios_per_sec"`
+	RwMbytesPerSec int `json:"rw_mbytes_per_sec"`
+	RMbytesPerSec  int `json:"r_mbytes_per_sec"`
+	WMbytesPerSec  int `json:"w_mbytes_per_sec"`
+}
+
+// IsZero reports whether the profile has no limits
+func (p QosProfile) IsZero() bool {
+	return p == QosProfile{}
+}
+
+// AppliedQosProfile is QoS profile set on a volume and where it came from
+type AppliedQosProfile struct {
+	QosProfile
+	Source string `json:"source"`
+}
+
+func validateQosProfile(profile QosProfile) error {
+	if profile.RwIosPerSec < 0 || profile.RwMbytesPerSec < 0 ||
+		profile.RMbytesPerSec < 0 || profile.WMbytesPerSec < 0 {
+		return fmt.Errorf("qos limits cannot be negative: %+v", profile)
+	}
+	return nil
+}
+
+// qosProfileFromContext returns profile to apply to a new volume. A profile
+// provided in request metadata overrides the configured default
+func (s *Server) qosProfileFromContext(ctx context.Context) (*AppliedQosProfile, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(QosProfileMetadataKey)
+	if len(values) == 0 {
+		if s.defaultQos.IsZero() {
+			return nil, nil
+		}
+		return &AppliedQosProfile{QosProfile: s.defaultQos, Source: QosProfileSourceDefault}, nil
+	}
+	var profile QosProfile
+	if err := json.Unmarshal([]byte(values[0]), &profile); err != nil {
+		msg := fmt.Sprintf("invalid qos profile %q", values[0])
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if err := validateQosProfile(profile); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	return &AppliedQosProfile{QosProfile: profile, Source: QosProfileSourceExplicit}, nil
+}
+
+// applyQosProfile sets profile limits on bdev. Profile without limits is
+// not sent to SPDK since new bdevs are not limited
+func (s *Server) applyQosProfile(ctx context.Context, bdevName string, profile *AppliedQosProfile) error {
+	if profile == nil || profile.IsZero() {
+		return nil
+	}
+	params := spdk.BdevQoSParams{
+		Name:           bdevName,
+		RwIosPerSec:    profile.RwIosPerSec,
+		RwMbytesPerSec: profile.RwMbytesPerSec,
+		RMbytesPerSec:  profile.RMbytesPerSec,
+		WMbytesPerSec:  profile.WMbytesPerSec,
+	}
+	var result spdk.BdevQoSResult
+	err := s.rpc.Call(ctx, "bdev_set_qos_limit", &params, &result)
+	if err != nil {
+		return err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	if !result {
+		msg := fmt.Sprintf("Could not set %v QoS profile on %s", profile.Source, bdevName)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+// appliedQosProfile returns QoS profile applied to volume on create
+func (s *Server) appliedQosProfile(volumeName string) *AppliedQosProfile {
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	return s.qosProfiles[volumeName]
+}
+
+// rollbackBdevCreate deletes bdev created by a request failed afterwards
+func (s *Server) rollbackBdevCreate(ctx context.Context, method string, bdevName string) {
+	ctx, cancel := utils.CleanupContext(ctx)
+	defer cancel()
+	params := struct {
+		Name string `json:"name"`
+	}{
+		Name: bdevName,
+	}
+	var result bool
+	err := s.rpc.Call(ctx, method, &params, &result)
+	if err != nil || !result {
+		log.Printf("error: failed to delete %v after failed create: %v", bdevName, err)
+	}
+}
+
+func sendQosProfile(ctx context.Context, profile *AppliedQosProfile) {
+	if profile == nil {
+		return
+	}
+	data, err := json.Marshal(profile)
+	if err != nil {
+		log.Printf("error: failed to marshal qos profile: %v", err)
+		return
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(QosProfileHeaderKey, string(data))); err != nil {
+		log.Printf("error: failed to send qos profile: %v", err)
+	}
+}
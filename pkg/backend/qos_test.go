@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+func TestBackEnd_CreateNullVolumeDefaultQos(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	defaultQos := QosProfile{RwIosPerSec: 10000, RwMbytesPerSec: 100}
+
+	tests := map[string]struct {
+		defaultQos QosProfile
+		profile    string
+		spdk       []string
+		params     []string
+		header     []string
+		stored     *AppliedQosProfile
+		errCode    codes.Code
+		errMsg     string
+	}{
+		"default profile applied": {
+			defaultQos: defaultQos,
+			profile:    "",
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			params: []string{
+				`{"block_size":512,"num_blocks":64,"name":"mytest","uuid":"3f2a6c1e-5b7d-4e8f-9a0b-1c2d3e4f5a6b"}`,
+				`{"name":"mytest","rw_ios_per_sec":10000,"rw_mbytes_per_sec":100,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0}`,
+			},
+			header:  []string{`{"rw_ios_per_sec":10000,"rw_mbytes_per_sec":100,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0,"source":"default"}`},
+			stored:  &AppliedQosProfile{QosProfile: defaultQos, Source: QosProfileSourceDefault},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"explicit profile overrides default": {
+			defaultQos: defaultQos,
+			profile:    `{"r_mbytes_per_sec":50,"w_mbytes_per_sec":20}`,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			params: []string{
+				`{"block_size":512,"num_blocks":64,"name":"mytest","uuid":"3f2a6c1e-5b7d-4e8f-9a0b-1c2d3e4f5a6b"}`,
+				`{"name":"mytest","rw_ios_per_sec":0,"rw_mbytes_per_sec":0,"r_mbytes_per_sec":50,"w_mbytes_per_sec":20}`,
+			},
+			header:  []string{`{"rw_ios_per_sec":0,"rw_mbytes_per_sec":0,"r_mbytes_per_sec":50,"w_mbytes_per_sec":20,"source":"explicit"}`},
+			stored:  &AppliedQosProfile{QosProfile: QosProfile{RMbytesPerSec: 50, WMbytesPerSec: 20}, Source: QosProfileSourceExplicit},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"explicit profile without limits disables default": {
+			defaultQos: defaultQos,
+			profile:    `{}`,
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			params:     []string{`{"block_size":512,"num_blocks":64,"name":"mytest","uuid":"3f2a6c1e-5b7d-4e8f-9a0b-1c2d3e4f5a6b"}`},
+			header:     []string{`{"rw_ios_per_sec":0,"rw_mbytes_per_sec":0,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0,"source":"explicit"}`},
+			stored:     &AppliedQosProfile{Source: QosProfileSourceExplicit},
+			errCode:    codes.OK,
+			errMsg:     "",
+		},
+		"no default profile configured": {
+			defaultQos: QosProfile{},
+			profile:    "",
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			params:     []string{`{"block_size":512,"num_blocks":64,"name":"mytest","uuid":"3f2a6c1e-5b7d-4e8f-9a0b-1c2d3e4f5a6b"}`},
+			header:     nil,
+			stored:     nil,
+			errCode:    codes.OK,
+			errMsg:     "",
+		},
+		"malformed explicit profile": {
+			defaultQos: defaultQos,
+			profile:    "fast",
+			spdk:       []string{},
+			params:     nil,
+			header:     nil,
+			stored:     nil,
+			errCode:    codes.InvalidArgument,
+			errMsg:     fmt.Sprintf("invalid qos profile %q", "fast"),
+		},
+		"negative explicit limit": {
+			defaultQos: defaultQos,
+			profile:    `{"rw_ios_per_sec":-5}`,
+			spdk:       []string{},
+			params:     nil,
+			header:     nil,
+			stored:     nil,
+			errCode:    codes.InvalidArgument,
+			errMsg:     fmt.Sprintf("qos limits cannot be negative: %+v", QosProfile{RwIosPerSec: -5}),
+		},
+		"failed to set profile deletes volume": {
+			defaultQos: defaultQos,
+			profile:    "",
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":false}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			params: []string{
+				`{"block_size":512,"num_blocks":64,"name":"mytest","uuid":"3f2a6c1e-5b7d-4e8f-9a0b-1c2d3e4f5a6b"}`,
+				`{"name":"mytest","rw_ios_per_sec":10000,"rw_mbytes_per_sec":100,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0}`,
+				`{"name":"mytest"}`,
+			},
+			header:  nil,
+			stored:  nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not set %v QoS profile on %v", QosProfileSourceDefault, testNullVolumeID),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			testEnv.opiSpdkServer.defaultQos = tt.defaultQos
+			recorder := testEnv.recordSpdkParams()
+
+			ctx := testEnv.ctx
+			if tt.profile != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, QosProfileMetadataKey, tt.profile)
+			}
+			var header metadata.MD
+			request := &pb.CreateNullVolumeRequest{NullVolume: &testNullVolume, NullVolumeId: testNullVolumeID}
+			_, err := testEnv.client.CreateNullVolume(ctx, request, grpc.Header(&header))
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if !reflect.DeepEqual(recorder.params, tt.params) {
+				t.Error("spdk params: expected", tt.params, "received", recorder.params)
+			}
+			if values := header.Get(QosProfileHeaderKey); !reflect.DeepEqual(values, tt.header) {
+				t.Error("header: expected", tt.header, "received", values)
+			}
+			if stored := testEnv.opiSpdkServer.qosProfiles[testNullVolumeName]; !reflect.DeepEqual(stored, tt.stored) {
+				t.Error("stored profile: expected", tt.stored, "received", stored)
+			}
+		})
+	}
+}
+
+func TestBackEnd_CreateAioVolumeDefaultQos(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+	})
+	defer testEnv.Close()
+	testEnv.opiSpdkServer.defaultQos = QosProfile{RwIosPerSec: 2000}
+	recorder := testEnv.recordSpdkParams()
+
+	request := &pb.CreateAioVolumeRequest{AioVolume: &testAioVolume, AioVolumeId: testAioVolumeID}
+	if _, err := testEnv.client.CreateAioVolume(testEnv.ctx, request); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	wantParams := []string{
+		`{"name":"mytest","filename":"/tmp/aio_bdev_file","block_size":512,"uuid":"7e8d9c0b-1a2f-4b3c-8d4e-5f6a7b8c9d0e"}`,
+		`{"name":"mytest","rw_ios_per_sec":2000,"rw_mbytes_per_sec":0,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0}`,
+	}
+	if !reflect.DeepEqual(recorder.params, wantParams) {
+		t.Error("spdk params: expected", wantParams, "received", recorder.params)
+	}
+	wantStored := &AppliedQosProfile{QosProfile: QosProfile{RwIosPerSec: 2000}, Source: QosProfileSourceDefault}
+	if stored := testEnv.opiSpdkServer.qosProfiles[testAioVolumeName]; !reflect.DeepEqual(stored, wantStored) {
+		t.Error("stored profile: expected", wantStored, "received", stored)
+	}
+}
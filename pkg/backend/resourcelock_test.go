@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// spdkNullBdevs emulates SPDK Null bdevs state and is safe for concurrent
+// calls. It records the highest number of calls in flight at once
+type spdkNullBdevs struct {
+	spdk.JSONRPC
+	mu          sync.Mutex
+	bdevs       map[string]bool
+	inFlight    int
+	maxInFlight int
+}
+
+func (r *spdkNullBdevs) Call(_ context.Context, method string, args, result interface{}) error {
+	r.mu.Lock()
+	r.inFlight++
+	if r.inFlight > r.maxInFlight {
+		r.maxInFlight = r.inFlight
+	}
+	r.mu.Unlock()
+	// widen the window for interleaving calls
+	time.Sleep(time.Millisecond)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inFlight--
+	switch method {
+	case "bdev_null_create":
+		name := args.(*spdk.BdevNullCreateParams).Name
+		if r.bdevs[name] {
+			return fmt.Errorf("%s: bdev %s already exists", method, name)
+		}
+		r.bdevs[name] = true
+		*result.(*spdk.BdevNullCreateResult) = spdk.BdevNullCreateResult(name)
+	case "bdev_null_delete":
+		name := args.(*spdk.BdevNullDeleteParams).Name
+		if !r.bdevs[name] {
+			return fmt.Errorf("%s: bdev %s does not exist", method, name)
+		}
+		delete(r.bdevs, name)
+		*result.(*spdk.BdevNullDeleteResult) = true
+	default:
+		return fmt.Errorf("%s: unexpected call", method)
+	}
+	return nil
+}
+
+func TestBackEnd_ConcurrentUpdateDeleteNullVolume(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	bdevs := &spdkNullBdevs{
+		JSONRPC: testEnv.opiSpdkServer.rpc,
+		bdevs:   map[string]bool{testNullVolumeID: true},
+	}
+	testEnv.opiSpdkServer.rpc = bdevs
+	testEnv.opiSpdkServer.Volumes.NullVolumes[testNullVolumeName] = utils.ProtoClone(&testNullVolumeWithName)
+
+	const calls = 8
+	errs := make(chan error, 2*calls)
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			request := &pb.UpdateNullVolumeRequest{NullVolume: utils.ProtoClone(&testNullVolumeWithName)}
+			_, err := testEnv.client.UpdateNullVolume(testEnv.ctx, request)
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			request := &pb.DeleteNullVolumeRequest{Name: testNullVolumeName}
+			_, err := testEnv.client.DeleteNullVolume(testEnv.ctx, request)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	deleted := 0
+	for err := range errs {
+		switch status.Code(err) {
+		case codes.OK:
+		case codes.NotFound:
+			deleted++
+		default:
+			t.Error("expected OK or NotFound once deleted, received", err)
+		}
+	}
+	if deleted == 0 {
+		t.Error("expected calls after delete to fail as not found")
+	}
+	if bdevs.maxInFlight != 1 {
+		t.Error("expected SPDK calls on the same volume to be serialized, received", bdevs.maxInFlight, "in flight")
+	}
+	if _, ok := testEnv.opiSpdkServer.Volumes.NullVolumes[testNullVolumeName]; ok || bdevs.bdevs[testNullVolumeID] {
+		t.Error("expected volume deleted from both server and SPDK, stored:", ok, "in SPDK:", bdevs.bdevs[testNullVolumeID])
+	}
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestBackEnd_StateRoundTrip(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	testEnv.opiSpdkServer.Volumes.NvmeControllers[testNvmeCtrlName] = utils.ProtoClone(&testNvmeCtrlWithName)
+	testEnv.opiSpdkServer.Volumes.NvmePaths[testNvmePathName] = utils.ProtoClone(&testNvmePathWithName)
+	testEnv.opiSpdkServer.Volumes.NullVolumes[testNullVolumeName] = utils.ProtoClone(&testNullVolumeWithName)
+	testEnv.opiSpdkServer.Volumes.AioVolumes[testAioVolumeName] = utils.ProtoClone(&testAioVolumeWithName)
+	testEnv.opiSpdkServer.Volumes.MallocVolumes[testMallocVolumeName] = utils.ProtoClone(&testMallocVolumeWithName)
+	passthruVolume := testPassthruVolume
+	testEnv.opiSpdkServer.Volumes.PassthruVolumes[testPassthruVolumeName] = &passthruVolume
+
+	state, err := utils.NewStateServer(testEnv.opiSpdkServer).ExportState(testEnv.ctx, &emptypb.Empty{})
+	if err != nil {
+		t.Fatal("expected no error on export, received", err)
+	}
+
+	importEnv := createTestEnvironment([]string{})
+	defer importEnv.Close()
+	request := &pb.GetNvmeRemoteControllerRequest{Name: testNvmeCtrlName}
+	if _, err := importEnv.client.GetNvmeRemoteController(importEnv.ctx, request); status.Code(err) != codes.NotFound {
+		t.Fatal("expected", codes.NotFound, "before import, received", err)
+	}
+	if _, err := utils.NewStateServer(importEnv.opiSpdkServer).ImportState(importEnv.ctx, state); err != nil {
+		t.Fatal("expected no error on import, received", err)
+	}
+
+	response, err := importEnv.client.GetNvmeRemoteController(importEnv.ctx, request)
+	if err != nil {
+		t.Fatal("expected no error on get, received", err)
+	}
+	if !proto.Equal(response, &testNvmeCtrlWithName) {
+		t.Error("response: expected", &testNvmeCtrlWithName, "received", response)
+	}
+	if !proto.Equal(importEnv.opiSpdkServer.Volumes.NvmePaths[testNvmePathName], &testNvmePathWithName) {
+		t.Error("expected imported path", testNvmePathName)
+	}
+	if !proto.Equal(importEnv.opiSpdkServer.Volumes.NullVolumes[testNullVolumeName], &testNullVolumeWithName) {
+		t.Error("expected imported null volume", testNullVolumeName)
+	}
+	if !proto.Equal(importEnv.opiSpdkServer.Volumes.AioVolumes[testAioVolumeName], &testAioVolumeWithName) {
+		t.Error("expected imported aio volume", testAioVolumeName)
+	}
+	if !proto.Equal(importEnv.opiSpdkServer.Volumes.MallocVolumes[testMallocVolumeName], &testMallocVolumeWithName) {
+		t.Error("expected imported malloc volume", testMallocVolumeName)
+	}
+	if volume, ok := importEnv.opiSpdkServer.Volumes.PassthruVolumes[testPassthruVolumeName]; !ok || *volume != testPassthruVolume {
+		t.Error("expected imported passthru volume", testPassthruVolumeName)
+	}
+}
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// sleepingSpdk emulates hung SPDK not responding to calls for a while
+type sleepingSpdk struct {
+	spdk.JSONRPC
+	delay time.Duration
+}
+
+func (r *sleepingSpdk) Call(ctx context.Context, _ string, _, _ interface{}) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(r.delay):
+		return nil
+	}
+}
+
+func TestBackEnd_SpdkTimeout(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	hung := &sleepingSpdk{JSONRPC: testEnv.opiSpdkServer.rpc, delay: time.Second}
+	testEnv.opiSpdkServer.rpc = utils.NewTimeoutJSONRPC(hung, 20*time.Millisecond)
+	testEnv.opiSpdkServer.Volumes.NullVolumes[testNullVolumeName] = utils.ProtoClone(&testNullVolumeWithName)
+
+	start := time.Now()
+	request := &pb.GetNullVolumeRequest{Name: testNullVolumeName}
+	response, err := testEnv.client.GetNullVolume(testEnv.ctx, request)
+	if elapsed := time.Since(start); elapsed >= hung.delay {
+		t.Error("expected handler not to wait for hung SPDK, took", elapsed)
+	}
+
+	er, _ := status.FromError(err)
+	if er.Code() != codes.DeadlineExceeded {
+		t.Error("error code: expected", codes.DeadlineExceeded, "received", er.Code(), err)
+	}
+	if response != nil {
+		t.Error("response: expected nil, received", response)
+	}
+}
@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+// TTLMetadataKey is request metadata key carrying time to live of a volume
+// in Go duration format, e.g. "90s" or "1h", since volume protos have no
+// such field. Volume is deleted by the reaper when it expires
+const TTLMetadataKey = "opi-ttl"
+
+// ttlFromContext returns time to live provided in request metadata or zero
+// if the volume should not expire
+func ttlFromContext(ctx context.Context) (time.Duration, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(TTLMetadataKey)
+	if len(values) == 0 {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(values[0])
+	if err != nil {
+		msg := fmt.Sprintf("invalid ttl %q", values[0])
+		return 0, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if ttl <= 0 {
+		msg := fmt.Sprintf("ttl must be positive, got %v", ttl)
+		return 0, status.Errorf(codes.InvalidArgument, msg)
+	}
+	return ttl, nil
+}
+
+func expiryStoreKey(name string) string {
+	return "expiry/" + name
+}
+
+// setExpiry records when volume expires. Zero ttl means it never expires
+func (s *Server) setExpiry(name string, ttl time.Duration) {
+	if ttl == 0 {
+		return
+	}
+	expiry := time.Now().Add(ttl)
+	s.expiriesMu.Lock()
+	s.expiries[name] = expiry
+	s.expiriesMu.Unlock()
+	if err := s.store.Set(expiryStoreKey(name), timestamppb.New(expiry)); err != nil {
+		log.Printf("error: failed to store expiry of %v: %v", name, err)
+	}
+}
+
+// clearExpiry forgets expiry of a deleted volume
+func (s *Server) clearExpiry(name string) {
+	s.expiriesMu.Lock()
+	_, ok := s.expiries[name]
+	delete(s.expiries, name)
+	s.expiriesMu.Unlock()
+	if !ok {
+		return
+	}
+	if err := s.store.Delete(expiryStoreKey(name)); err != nil {
+		log.Printf("error: failed to delete expiry of %v: %v", name, err)
+	}
+}
+
+// RestoreExpiries loads expiries of stored volumes from the store, so
+// that volumes imported at startup still expire
+func (s *Server) RestoreExpiries() {
+	s.mapsMu.RLock()
+	var names []string
+	for name := range s.Volumes.NullVolumes {
+		names = append(names, name)
+	}
+	for name := range s.Volumes.AioVolumes {
+		names = append(names, name)
+	}
+	for name := range s.Volumes.MallocVolumes {
+		names = append(names, name)
+	}
+	s.mapsMu.RUnlock()
+
+	for _, name := range names {
+		value := &timestamppb.Timestamp{}
+		found, err := s.store.Get(expiryStoreKey(name), value)
+		if err != nil {
+			log.Printf("error: failed to load expiry of %v: %v", name, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+		s.expiriesMu.Lock()
+		s.expiries[name] = value.AsTime()
+		s.expiriesMu.Unlock()
+	}
+}
+
+// ReapedVolumes returns number of volumes deleted because their ttl expired
+func (s *Server) ReapedVolumes() uint64 {
+	return s.reaped.Load()
+}
+
+// SetResourceReleaser sets releaser notified of volumes deleted by the
+// reaper, since its deletes bypass interceptors
+func (s *Server) SetResourceReleaser(releaser utils.ResourceReleaser) {
+	s.releaser = releaser
+}
+
+// ReapExpiredVolumes deletes volumes expired at now and returns how many
+// of them were deleted. Volumes failed to be deleted are retried on the
+// next call
+func (s *Server) ReapExpiredVolumes(ctx context.Context, now time.Time) int {
+	var expired []string
+	s.expiriesMu.Lock()
+	for name, expiry := range s.expiries {
+		if !now.Before(expiry) {
+			expired = append(expired, name)
+		}
+	}
+	s.expiriesMu.Unlock()
+
+	reaped := 0
+	for _, name := range expired {
+		s.mapsMu.RLock()
+		_, null := s.Volumes.NullVolumes[name]
+		_, aio := s.Volumes.AioVolumes[name]
+		_, malloc := s.Volumes.MallocVolumes[name]
+		s.mapsMu.RUnlock()
+		var err error
+		switch {
+		case null:
+			_, err = s.DeleteNullVolume(ctx, &pb.DeleteNullVolumeRequest{Name: name, AllowMissing: true})
+		case aio:
+			_, err = s.DeleteAioVolume(ctx, &pb.DeleteAioVolumeRequest{Name: name, AllowMissing: true})
+		case malloc:
+			_, err = s.DeleteMallocVolume(ctx, &pb.DeleteMallocVolumeRequest{Name: name, AllowMissing: true})
+		default:
+			// already deleted
+			s.clearExpiry(name)
+			continue
+		}
+		if err != nil {
+			log.Printf("error: failed to reap expired volume %v: %v", name, err)
+			continue
+		}
+		log.Printf("Reaped expired volume %v", name)
+		if s.releaser != nil {
+			s.releaser.Release(name)
+		}
+		s.reaped.Add(1)
+		reaped++
+	}
+	return reaped
+}
+
+// RunVolumeReaper deletes expired volumes every interval until ctx is done
+func (s *Server) RunVolumeReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.ReapExpiredVolumes(ctx, now)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestBackEnd_CreateNullVolumeTTL(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		ttl        string
+		spdk       []string
+		wantExpiry bool
+		errCode    codes.Code
+		errMsg     string
+	}{
+		"no ttl": {
+			ttl:        "",
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			wantExpiry: false,
+			errCode:    codes.OK,
+			errMsg:     "",
+		},
+		"valid ttl": {
+			ttl:        "1h",
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			wantExpiry: true,
+			errCode:    codes.OK,
+			errMsg:     "",
+		},
+		"zero ttl": {
+			ttl:        "0s",
+			spdk:       []string{},
+			wantExpiry: false,
+			errCode:    codes.InvalidArgument,
+			errMsg:     fmt.Sprintf("ttl must be positive, got %v", time.Duration(0)),
+		},
+		"negative ttl": {
+			ttl:        "-5m",
+			spdk:       []string{},
+			wantExpiry: false,
+			errCode:    codes.InvalidArgument,
+			errMsg:     fmt.Sprintf("ttl must be positive, got %v", -5*time.Minute),
+		},
+		"malformed ttl": {
+			ttl:        "tomorrow",
+			spdk:       []string{},
+			wantExpiry: false,
+			errCode:    codes.InvalidArgument,
+			errMsg:     fmt.Sprintf("invalid ttl %q", "tomorrow"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			ctx := testEnv.ctx
+			if tt.ttl != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, TTLMetadataKey, tt.ttl)
+			}
+			request := &pb.CreateNullVolumeRequest{NullVolume: &testNullVolume, NullVolumeId: testNullVolumeID}
+			_, err := testEnv.client.CreateNullVolume(ctx, request)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			_, hasExpiry := testEnv.opiSpdkServer.expiries[testNullVolumeName]
+			if hasExpiry != tt.wantExpiry {
+				t.Error("expiry recorded: expected", tt.wantExpiry, "received", hasExpiry)
+			}
+			stored, err := testEnv.opiSpdkServer.store.Get(expiryStoreKey(testNullVolumeName), &timestamppb.Timestamp{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored != tt.wantExpiry {
+				t.Error("expiry stored: expected", tt.wantExpiry, "received", stored)
+			}
+		})
+	}
+}
+
+type recordingReleaser struct {
+	released []string
+}
+
+func (r *recordingReleaser) Release(name string) {
+	r.released = append(r.released, name)
+}
+
+func TestBackEnd_ReapExpiredVolumes(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":"persistent"}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+	})
+	defer testEnv.Close()
+
+	ctx := metadata.AppendToOutgoingContext(testEnv.ctx, TTLMetadataKey, "1ms")
+	request := &pb.CreateNullVolumeRequest{NullVolume: &testNullVolume, NullVolumeId: testNullVolumeID}
+	if _, err := testEnv.client.CreateNullVolume(ctx, request); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	persistentVolume := &pb.NullVolume{BlockSize: 512, BlocksCount: 64}
+	request = &pb.CreateNullVolumeRequest{NullVolume: persistentVolume, NullVolumeId: "persistent"}
+	if _, err := testEnv.client.CreateNullVolume(testEnv.ctx, request); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	releaser := &recordingReleaser{}
+	testEnv.opiSpdkServer.SetResourceReleaser(releaser)
+
+	if reaped := testEnv.opiSpdkServer.ReapExpiredVolumes(testEnv.ctx, time.Now().Add(-time.Hour)); reaped != 0 {
+		t.Error("reaped before expiry: expected", 0, "received", reaped)
+	}
+	if reaped := testEnv.opiSpdkServer.ReapExpiredVolumes(testEnv.ctx, time.Now().Add(time.Hour)); reaped != 1 {
+		t.Error("reaped after expiry: expected", 1, "received", reaped)
+	}
+
+	if _, ok := testEnv.opiSpdkServer.Volumes.NullVolumes[testNullVolumeName]; ok {
+		t.Error("expected volume with expired ttl to be deleted")
+	}
+	if _, ok := testEnv.opiSpdkServer.Volumes.NullVolumes[utils.ResourceIDToVolumeName("persistent")]; !ok {
+		t.Error("expected volume without ttl to persist")
+	}
+	if len(testEnv.opiSpdkServer.expiries) != 0 {
+		t.Error("expected no expiries left, received", testEnv.opiSpdkServer.expiries)
+	}
+	stored, err := testEnv.opiSpdkServer.store.Get(expiryStoreKey(testNullVolumeName), &timestamppb.Timestamp{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored {
+		t.Error("expected expiry to be deleted from store")
+	}
+	if reaped := testEnv.opiSpdkServer.ReapedVolumes(); reaped != 1 {
+		t.Error("reaped counter: expected", 1, "received", reaped)
+	}
+	if expected := []string{testNullVolumeName}; !reflect.DeepEqual(releaser.released, expected) {
+		t.Error("released: expected", expected, "received", releaser.released)
+	}
+}
+
+func TestBackEnd_RestoreExpiries(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	expiry := time.Now().Add(time.Hour).Truncate(time.Microsecond)
+	persistentVolumeName := utils.ResourceIDToVolumeName("persistent")
+	testEnv.opiSpdkServer.Volumes.NullVolumes[testNullVolumeName] = utils.ProtoClone(&testNullVolume)
+	testEnv.opiSpdkServer.Volumes.NullVolumes[persistentVolumeName] = utils.ProtoClone(&testNullVolume)
+	if err := testEnv.opiSpdkServer.store.Set(expiryStoreKey(testNullVolumeName), timestamppb.New(expiry)); err != nil {
+		t.Fatal(err)
+	}
+
+	testEnv.opiSpdkServer.RestoreExpiries()
+
+	expected := map[string]time.Time{testNullVolumeName: expiry}
+	if len(testEnv.opiSpdkServer.expiries) != 1 || !testEnv.opiSpdkServer.expiries[testNullVolumeName].Equal(expiry) {
+		t.Error("expiries: expected", expected, "received", testEnv.opiSpdkServer.expiries)
+	}
+	if reaped := testEnv.opiSpdkServer.ReapExpiredVolumes(testEnv.ctx, time.Now()); reaped != 0 {
+		t.Error("reaped before restored expiry: expected", 0, "received", reaped)
+	}
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SpdkUUIDCollisionCheckFeature is feature flag extending UUID collision
+// check of created volumes to SPDK bdevs not created by the bridge
+const SpdkUUIDCollisionCheckFeature = "spdk_uuid_collision_check"
+
+// spdkNoSuchDeviceError is error SPDK returns for unknown bdev
+const spdkNoSuchDeviceError = "No such device"
+
+// checkVolumeUUIDFree returns AlreadyExists naming the volume which already
+// uses uuid, so the client does not get an opaque SPDK duplicate error
+func (s *Server) checkVolumeUUIDFree(ctx context.Context, uuid string) error {
+	if uuid == "" {
+		return nil
+	}
+	owner := ""
+	s.mapsMu.RLock()
+	for name, volume := range s.Volumes.AioVolumes {
+		if strings.EqualFold(volume.Uuid, uuid) {
+			owner = name
+		}
+	}
+	for name, volume := range s.Volumes.NullVolumes {
+		if strings.EqualFold(volume.Uuid, uuid) {
+			owner = name
+		}
+	}
+	for name, volume := range s.Volumes.MallocVolumes {
+		if strings.EqualFold(volume.Uuid, uuid) {
+			owner = name
+		}
+	}
+	s.mapsMu.RUnlock()
+	if owner == "" && utils.FeatureEnabled(SpdkUUIDCollisionCheckFeature) {
+		// SPDK resolves bdev names as well as UUIDs
+		params := spdk.BdevGetBdevsParams{
+			Name: uuid,
+		}
+		var result []spdk.BdevGetBdevsResult
+		err := s.rpc.Call(ctx, "bdev_get_bdevs", &params, &result)
+		if err != nil && !strings.Contains(err.Error(), spdkNoSuchDeviceError) {
+			return err
+		}
+		log.Printf("Received from SPDK: %v", result)
+		if len(result) > 0 {
+			owner = result[0].Name
+		}
+	}
+	if owner != "" {
+		msg := fmt.Sprintf("uuid %s is already used by volume %s", uuid, owner)
+		return status.Errorf(codes.AlreadyExists, msg)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestBackEnd_CreateVolumeUUIDCollision(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	const uuid = "11d3902e-d9bb-49a7-bb27-cd7261ef3217"
+	createNull := func(env *testEnv) error {
+		volume := utils.ProtoClone(&testNullVolume)
+		volume.Uuid = uuid
+		_, err := env.client.CreateNullVolume(env.ctx, &pb.CreateNullVolumeRequest{NullVolume: volume, NullVolumeId: testNullVolumeID})
+		return err
+	}
+	createMalloc := func(env *testEnv) error {
+		volume := utils.ProtoClone(&testMallocVolume)
+		volume.Uuid = uuid
+		_, err := env.client.CreateMallocVolume(env.ctx, &pb.CreateMallocVolumeRequest{MallocVolume: volume, MallocVolumeId: "malloc-uuid"})
+		return err
+	}
+	createAio := func(env *testEnv) error {
+		volume := utils.ProtoClone(&testAioVolume)
+		volume.Uuid = uuid
+		_, err := env.client.CreateAioVolume(env.ctx, &pb.CreateAioVolumeRequest{AioVolume: volume, AioVolumeId: testAioVolumeID})
+		return err
+	}
+
+	tests := map[string]struct {
+		create    func(env *testEnv) error
+		taken     bool
+		spdkCheck bool
+		spdk      []string
+		params    []string
+		errCode   codes.Code
+		errMsg    string
+	}{
+		"free uuid of null volume": {
+			create:    createNull,
+			taken:     false,
+			spdkCheck: false,
+			spdk:      []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			params:    []string{`{"block_size":512,"num_blocks":64,"name":"mytest","uuid":"` + uuid + `"}`},
+			errCode:   codes.OK,
+			errMsg:    "",
+		},
+		"free uuid of aio volume": {
+			create:    createAio,
+			taken:     false,
+			spdkCheck: false,
+			spdk:      []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			params:    []string{`{"name":"mytest","filename":"/tmp/aio_bdev_file","block_size":512,"uuid":"` + uuid + `"}`},
+			errCode:   codes.OK,
+			errMsg:    "",
+		},
+		"free uuid of malloc volume": {
+			create:    createMalloc,
+			taken:     false,
+			spdkCheck: false,
+			spdk:      []string{`{"id":%d,"error":{"code":0,"message":""},"result":"malloc-uuid"}`},
+			params:    []string{`{"num_blocks":64,"block_size":512,"md_interleave":true,"name":"malloc-uuid","uuid":"` + uuid + `"}`},
+			errCode:   codes.OK,
+			errMsg:    "",
+		},
+		"uuid of malloc volume taken by another volume": {
+			create:    createMalloc,
+			taken:     true,
+			spdkCheck: false,
+			spdk:      []string{},
+			params:    nil,
+			errCode:   codes.AlreadyExists,
+			errMsg:    fmt.Sprintf("uuid %s is already used by volume %s", uuid, testMallocVolumeName),
+		},
+		"uuid taken by another volume": {
+			create:    createNull,
+			taken:     true,
+			spdkCheck: false,
+			spdk:      []string{},
+			params:    nil,
+			errCode:   codes.AlreadyExists,
+			errMsg:    fmt.Sprintf("uuid %s is already used by volume %s", uuid, testMallocVolumeName),
+		},
+		"uuid taken by another volume of other type": {
+			create:    createAio,
+			taken:     true,
+			spdkCheck: false,
+			spdk:      []string{},
+			params:    nil,
+			errCode:   codes.AlreadyExists,
+			errMsg:    fmt.Sprintf("uuid %s is already used by volume %s", uuid, testMallocVolumeName),
+		},
+		"uuid free in SPDK": {
+			create:    createNull,
+			taken:     false,
+			spdkCheck: true,
+			spdk: []string{
+				`{"id":%d,"error":{"code":-19,"message":"No such device"},"result":null}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`,
+			},
+			params: []string{
+				`{"name":"` + uuid + `"}`,
+				`{"block_size":512,"num_blocks":64,"name":"mytest","uuid":"` + uuid + `"}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"uuid taken in SPDK": {
+			create:    createAio,
+			taken:     false,
+			spdkCheck: true,
+			spdk:      []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"Nvme0n1","uuid":"` + uuid + `"}]}`},
+			params:    []string{`{"name":"` + uuid + `"}`},
+			errCode:   codes.AlreadyExists,
+			errMsg:    fmt.Sprintf("uuid %s is already used by volume %s", uuid, "Nvme0n1"),
+		},
+		"SPDK check error": {
+			create:    createNull,
+			taken:     false,
+			spdkCheck: true,
+			spdk:      []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":null}`},
+			params:    []string{`{"name":"` + uuid + `"}`},
+			errCode:   codes.Unknown,
+			errMsg:    fmt.Sprintf("bdev_get_bdevs: %v", "json response error: myopierr"),
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+			utils.SetFeatureFlags(map[string]bool{SpdkUUIDCollisionCheckFeature: tt.spdkCheck})
+			t.Cleanup(func() { utils.SetFeatureFlags(nil) })
+			if tt.taken {
+				volume := utils.ProtoClone(&testMallocVolume)
+				volume.Name = testMallocVolumeName
+				// uuids are compared case insensitive
+				volume.Uuid = "11D3902E-D9BB-49A7-BB27-CD7261EF3217"
+				testEnv.opiSpdkServer.Volumes.MallocVolumes[testMallocVolumeName] = volume
+			}
+
+			err := tt.create(testEnv)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if !reflect.DeepEqual(recorder.params, tt.params) {
+				t.Error("spdk params: expected", tt.params, "received", recorder.params)
+			}
+		})
+	}
+}
+
+func TestBackEnd_CreateVolumeUUIDGenerated(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	createNull := func(env *testEnv, uuid string) (string, error) {
+		volume := utils.ProtoClone(&testNullVolume)
+		volume.Uuid = uuid
+		response, err := env.client.CreateNullVolume(env.ctx, &pb.CreateNullVolumeRequest{NullVolume: volume, NullVolumeId: testNullVolumeID})
+		if err != nil {
+			return "", err
+		}
+		if stored := env.opiSpdkServer.Volumes.NullVolumes[testNullVolumeName]; stored.GetUuid() != response.Uuid {
+			return "", fmt.Errorf("stored uuid %v differs from returned %v", stored.GetUuid(), response.Uuid)
+		}
+		return response.Uuid, nil
+	}
+	createAio := func(env *testEnv, uuid string) (string, error) {
+		volume := utils.ProtoClone(&testAioVolume)
+		volume.Uuid = uuid
+		response, err := env.client.CreateAioVolume(env.ctx, &pb.CreateAioVolumeRequest{AioVolume: volume, AioVolumeId: testAioVolumeID})
+		if err != nil {
+			return "", err
+		}
+		if stored := env.opiSpdkServer.Volumes.AioVolumes[testAioVolumeName]; stored.GetUuid() != response.Uuid {
+			return "", fmt.Errorf("stored uuid %v differs from returned %v", stored.GetUuid(), response.Uuid)
+		}
+		return response.Uuid, nil
+	}
+
+	tests := map[string]struct {
+		create  func(env *testEnv, uuid string) (string, error)
+		uuid    string
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"omitted uuid of null volume is generated": {
+			create:  createNull,
+			uuid:    "",
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"omitted uuid of aio volume is generated": {
+			create:  createAio,
+			uuid:    "",
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"invalid uuid of null volume": {
+			create:  createNull,
+			uuid:    "not-a-uuid",
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("invalid uuid %q: %v", "not-a-uuid", "invalid UUID length: 10"),
+		},
+		"invalid uuid of aio volume": {
+			create:  createAio,
+			uuid:    "not-a-uuid",
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("invalid uuid %q: %v", "not-a-uuid", "invalid UUID length: 10"),
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+
+			uuid, err := tt.create(testEnv, tt.uuid)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if tt.errCode != codes.OK {
+				if len(recorder.params) != 0 {
+					t.Error("expected no SPDK calls, received", recorder.params)
+				}
+				return
+			}
+			if utils.ValidateUUID(uuid) != nil || uuid == "" {
+				t.Error("expected generated uuid, received", uuid)
+			}
+			if len(recorder.params) != 1 || !strings.Contains(recorder.params[0], `"uuid":"`+uuid+`"`) {
+				t.Error("expected generated uuid passed to SPDK, received", recorder.params)
+			}
+		})
+	}
+}
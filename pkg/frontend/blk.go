@@ -9,7 +9,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"path"
 	"sort"
 
 	"github.com/opiproject/gospdk/spdk"
@@ -19,7 +18,6 @@ import (
 	"github.com/google/uuid"
 	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/fieldmask"
-	"go.einride.tech/aip/resourceid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -32,34 +30,78 @@ func sortVirtioBlks(virtioBlks []*pb.VirtioBlk) {
 	})
 }
 
+// virtioBlkMethods returns SPDK methods creating and deleting virtio-blk
+// controllers by the transport and whether they are vhost controllers
+func (s *Server) virtioBlkMethods() (createMethod, deleteMethod string, vhost bool) {
+	if methods, ok := s.Virt.transport.(VirtioBlkSpdkMethods); ok {
+		return methods.CreateMethod(), methods.DeleteMethod(), false
+	}
+	return "vhost_create_blk_controller", "vhost_delete_controller", true
+}
+
+// createdVirtioBlkCtrlrs returns controllers created by non vhost transport
+// in the shape of vhost_get_controllers result, since SPDK cannot list them
+func (s *Server) createdVirtioBlkCtrlrs() []spdk.VhostGetControllersResult {
+	s.mapsMu.RLock()
+	result := make([]spdk.VhostGetControllersResult, 0, len(s.Virt.BlkCtrls))
+	for name := range s.Virt.BlkCtrls {
+		result = append(result, spdk.VhostGetControllersResult{Ctrlr: utils.ResourceNameToID(name)})
+	}
+	s.mapsMu.RUnlock()
+	sort.Slice(result, func(i int, j int) bool {
+		return result[i].Ctrlr < result[j].Ctrlr
+	})
+	return result
+}
+
 // CreateVirtioBlk creates a Virtio block device
 func (s *Server) CreateVirtioBlk(ctx context.Context, in *pb.CreateVirtioBlkRequest) (*pb.VirtioBlk, error) {
 	// check input correctness
 	if err := s.validateCreateVirtioBlkRequest(in); err != nil {
 		return nil, err
 	}
+	serial, err := virtioBlkSerialFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	// see https://google.aip.dev/133#user-specified-ids
-	resourceID := resourceid.NewSystemGenerated()
+	resourceID, err := s.idempotencyKeys.ResourceID(ctx, "VirtioBlk", in.VirtioBlkId)
+	if err != nil {
+		return nil, err
+	}
 	if in.VirtioBlkId != "" {
 		log.Printf("client provided the ID of a resource %v, ignoring the name field %v", in.VirtioBlkId, in.VirtioBlk.Name)
-		resourceID = in.VirtioBlkId
 	}
 	in.VirtioBlk.Name = utils.ResourceIDToVolumeName(resourceID)
 
+	unlock := s.resourceLocks.Lock(in.VirtioBlk.Name)
+	defer unlock()
 	// idempotent API when called with same key, should return same object
+	s.mapsMu.RLock()
 	controller, ok := s.Virt.BlkCtrls[in.VirtioBlk.Name]
+	s.mapsMu.RUnlock()
 	if ok {
 		log.Printf("Already existing NvmeController with id %v", in.VirtioBlk.Name)
+		sendVirtioBlkSerial(ctx, s.virtioBlkSerial(controller.Name))
 		return controller, nil
 	}
+	if serial == "" {
+		serial = s.newVirtioBlkSerial()
+	} else if err := s.virtioBlkSerialFree(serial); err != nil {
+		return nil, err
+	}
 	// not found, so create a new one
 	params, err := s.Virt.transport.CreateParams(in.VirtioBlk)
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
+	createMethod, _, _ := s.virtioBlkMethods()
+	if utils.SkipSpdkCallInDryRun(ctx, createMethod, &params) {
+		return utils.ProtoClone(in.VirtioBlk), nil
+	}
 	var result spdk.VhostCreateBlkControllerResult
-	err = s.rpc.Call(ctx, "vhost_create_blk_controller", &params, &result)
+	err = s.rpc.Call(ctx, createMethod, &params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -70,7 +112,11 @@ func (s *Server) CreateVirtioBlk(ctx context.Context, in *pb.CreateVirtioBlkRequ
 	}
 	response := utils.ProtoClone(in.VirtioBlk)
 	// response.Status = &pb.NvmeControllerStatus{Active: true}
+	s.mapsMu.Lock()
 	s.Virt.BlkCtrls[in.VirtioBlk.Name] = response
+	s.mapsMu.Unlock()
+	s.setVirtioBlkSerial(in.VirtioBlk.Name, serial)
+	sendVirtioBlkSerial(ctx, serial)
 	return response, nil
 }
 
@@ -80,8 +126,12 @@ func (s *Server) DeleteVirtioBlk(ctx context.Context, in *pb.DeleteVirtioBlkRequ
 	if err := s.validateDeleteVirtioBlkRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	controller, ok := s.Virt.BlkCtrls[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			return &emptypb.Empty{}, nil
@@ -96,8 +146,12 @@ func (s *Server) DeleteVirtioBlk(ctx context.Context, in *pb.DeleteVirtioBlkRequ
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	_, deleteMethod, _ := s.virtioBlkMethods()
+	if utils.SkipSpdkCallInDryRun(ctx, deleteMethod, &params) {
+		return &emptypb.Empty{}, nil
+	}
 	var result spdk.VhostDeleteControllerResult
-	err = s.rpc.Call(ctx, "vhost_delete_controller", &params, &result)
+	err = s.rpc.Call(ctx, deleteMethod, &params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -106,7 +160,10 @@ func (s *Server) DeleteVirtioBlk(ctx context.Context, in *pb.DeleteVirtioBlkRequ
 		msg := fmt.Sprintf("Could not delete virtio-blk: %s", in.Name)
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
+	s.mapsMu.Lock()
 	delete(s.Virt.BlkCtrls, controller.Name)
+	s.mapsMu.Unlock()
+	s.clearVirtioBlkSerial(controller.Name)
 	return &emptypb.Empty{}, nil
 }
 
@@ -116,8 +173,12 @@ func (s *Server) UpdateVirtioBlk(_ context.Context, in *pb.UpdateVirtioBlkReques
 	if err := s.validateUpdateVirtioBlkRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.VirtioBlk.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Virt.BlkCtrls[in.VirtioBlk.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			log.Printf("TODO: in case of AllowMissing, create a new resource, don;t return error")
@@ -125,7 +186,7 @@ func (s *Server) UpdateVirtioBlk(_ context.Context, in *pb.UpdateVirtioBlkReques
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.VirtioBlk.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	// update_mask = 2
 	if err := fieldmask.Validate(in.UpdateMask, in.VirtioBlk); err != nil {
 		return nil, err
@@ -146,17 +207,21 @@ func (s *Server) ListVirtioBlks(ctx context.Context, in *pb.ListVirtioBlksReques
 		return nil, perr
 	}
 	var result []spdk.VhostGetControllersResult
-	err := s.rpc.Call(ctx, "vhost_get_controllers", nil, &result)
-	if err != nil {
-		return nil, err
+	if _, _, vhost := s.virtioBlkMethods(); vhost {
+		err := s.rpc.Call(ctx, "vhost_get_controllers", nil, &result)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Received from SPDK: %v", result)
+	} else {
+		result = s.createdVirtioBlkCtrlrs()
 	}
-	log.Printf("Received from SPDK: %v", result)
 	token := ""
 	log.Printf("Limiting result len(%d) to [%d:%d]", len(result), offset, size)
 	result, hasMoreElements := utils.LimitPagination(result, offset, size)
 	if hasMoreElements {
 		token = uuid.New().String()
-		s.Pagination[token] = offset + size
+		s.Pagination.Set(token, offset+size)
 	}
 	Blobarray := make([]*pb.VirtioBlk, len(result))
 	for i := range result {
@@ -170,6 +235,7 @@ func (s *Server) ListVirtioBlks(ctx context.Context, in *pb.ListVirtioBlksReques
 			},
 			VolumeNameRef: "TBD"}
 	}
+	Blobarray, token = utils.LimitPaginationBySize(Blobarray, offset, token, s.Pagination)
 	sortVirtioBlks(Blobarray)
 
 	return &pb.ListVirtioBlksResponse{VirtioBlks: Blobarray, NextPageToken: token}, nil
@@ -182,25 +248,30 @@ func (s *Server) GetVirtioBlk(ctx context.Context, in *pb.GetVirtioBlkRequest) (
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Virt.BlkCtrls[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
-	params := spdk.VhostGetControllersParams{
-		Name: resourceID,
-	}
-	var result []spdk.VhostGetControllersResult
-	err := s.rpc.Call(ctx, "vhost_get_controllers", &params, &result)
-	if err != nil {
-		return nil, err
-	}
-	log.Printf("Received from SPDK: %v", result)
-	if len(result) != 1 {
-		msg := fmt.Sprintf("expecting exactly 1 result, got %d", len(result))
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+	if _, _, vhost := s.virtioBlkMethods(); vhost {
+		resourceID := utils.ResourceNameToID(volume.Name)
+		params := spdk.VhostGetControllersParams{
+			Name: resourceID,
+		}
+		var result []spdk.VhostGetControllersResult
+		err := s.rpc.Call(ctx, "vhost_get_controllers", &params, &result)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Received from SPDK: %v", result)
+		if len(result) != 1 {
+			msg := fmt.Sprintf("expecting exactly 1 result, got %d", len(result))
+			return nil, status.Errorf(codes.InvalidArgument, msg)
+		}
 	}
+	sendVirtioBlkSerial(ctx, s.virtioBlkSerial(volume.Name))
 	return &pb.VirtioBlk{
 		Name: in.Name,
 		PcieId: &pb.PciEndpoint{
@@ -212,19 +283,34 @@ func (s *Server) GetVirtioBlk(ctx context.Context, in *pb.GetVirtioBlkRequest) (
 }
 
 // StatsVirtioBlk gets a Virtio block device stats
-func (s *Server) StatsVirtioBlk(_ context.Context, in *pb.StatsVirtioBlkRequest) (*pb.StatsVirtioBlkResponse, error) {
+func (s *Server) StatsVirtioBlk(ctx context.Context, in *pb.StatsVirtioBlkRequest) (*pb.StatsVirtioBlkResponse, error) {
 	log.Printf("StatsVirtioBlk: Received from client: %v", in)
 	// check input correctness
 	if err := s.validateStatsVirtioBlkRequest(in); err != nil {
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Virt.BlkCtrls[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
-	log.Printf("TODO: send name to SPDK and get back stats: %v", resourceID)
-	return nil, status.Errorf(codes.Unimplemented, "StatsVirtioBlk method is not implemented")
+	bdev := volume.GetVolumeNameRef()
+	if bdev == "" {
+		err := status.Errorf(codes.NotFound, "no backing bdev for %s", in.Name)
+		return nil, err
+	}
+	stats, err := s.volumesStats(ctx, []string{bdev})
+	if err != nil {
+		return nil, withCode(err, codes.Unavailable)
+	}
+	// SPDK keeps iostat of every existing bdev, so no entry means backing
+	// bdev is gone
+	if stats == nil {
+		err := status.Errorf(codes.NotFound, "unable to find bdev %s of %s", bdev, in.Name)
+		return nil, err
+	}
+	return &pb.StatsVirtioBlkResponse{Stats: stats}, nil
 }
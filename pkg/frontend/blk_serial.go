@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// VirtioBlkSerialMetadataKey is metadata key carrying serial number of
+// virtio-blk device guest uses to identify it, since VirtioBlk has no such
+// field. Set by client on create, a unique serial is generated if omitted.
+// Returned by server in header of Create and Get calls
+const VirtioBlkSerialMetadataKey = "opi-virtio-blk-serial"
+
+// maxVirtioBlkSerialLength is VIRTIO_BLK_ID_BYTES, size of serial reported
+// by virtio-blk device
+const maxVirtioBlkSerialLength = 20
+
+// virtioBlkSerialFromContext returns serial requested by client or empty
+// string if omitted
+func virtioBlkSerialFromContext(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(VirtioBlkSerialMetadataKey)
+	if len(values) == 0 {
+		return "", nil
+	}
+	serial := values[0]
+	if serial == "" || len(serial) > maxVirtioBlkSerialLength {
+		msg := fmt.Sprintf("virtio-blk serial %q must be 1 to %d characters long", serial, maxVirtioBlkSerialLength)
+		return "", status.Errorf(codes.InvalidArgument, msg)
+	}
+	for _, c := range serial {
+		if c < '!' || c > '~' {
+			msg := fmt.Sprintf("virtio-blk serial %q must contain printable ASCII characters only", serial)
+			return "", status.Errorf(codes.InvalidArgument, msg)
+		}
+	}
+	return serial, nil
+}
+
+// virtioBlkSerialFree checks serial is not used by another virtio-blk
+func (s *Server) virtioBlkSerialFree(serial string) error {
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	if name, ok := s.Virt.serials[serial]; ok {
+		msg := fmt.Sprintf("virtio-blk serial %s is already used by %s", serial, name)
+		return status.Errorf(codes.AlreadyExists, msg)
+	}
+	return nil
+}
+
+// newVirtioBlkSerial generates serial not used by any virtio-blk
+func (s *Server) newVirtioBlkSerial() string {
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	for {
+		serial := strings.ToUpper(strings.ReplaceAll(uuid.New().String(), "-", ""))[:maxVirtioBlkSerialLength]
+		if _, ok := s.Virt.serials[serial]; !ok {
+			return serial
+		}
+	}
+}
+
+// setVirtioBlkSerial indexes serial of virtio-blk name
+func (s *Server) setVirtioBlkSerial(name string, serial string) {
+	s.mapsMu.Lock()
+	defer s.mapsMu.Unlock()
+	s.Virt.serials[serial] = name
+	s.Virt.blkSerials[name] = serial
+}
+
+// clearVirtioBlkSerial releases serial of a deleted virtio-blk
+func (s *Server) clearVirtioBlkSerial(name string) {
+	s.mapsMu.Lock()
+	defer s.mapsMu.Unlock()
+	delete(s.Virt.serials, s.Virt.blkSerials[name])
+	delete(s.Virt.blkSerials, name)
+}
+
+// virtioBlkSerial returns serial of virtio-blk name
+func (s *Server) virtioBlkSerial(name string) string {
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	return s.Virt.blkSerials[name]
+}
+
+func sendVirtioBlkSerial(ctx context.Context, serial string) {
+	if serial == "" {
+		return
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(VirtioBlkSerialMetadataKey, serial)); err != nil {
+		log.Printf("error: failed to send virtio-blk serial: %v", err)
+	}
+}
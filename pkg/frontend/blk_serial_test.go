@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_CreateVirtioBlkSerial(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	otherVirtioCtrlName := utils.ResourceIDToVolumeName("virtio-blk-other")
+	tests := map[string]struct {
+		serial   string
+		existing map[string]string
+		spdk     []string
+		out      string
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"serial provided": {
+			serial:   "OPI-SERIAL-1",
+			existing: map[string]string{},
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			out:      "OPI-SERIAL-1",
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"duplicate serial": {
+			serial:   "OPI-SERIAL-1",
+			existing: map[string]string{otherVirtioCtrlName: "OPI-SERIAL-1"},
+			spdk:     []string{},
+			out:      "",
+			errCode:  codes.AlreadyExists,
+			errMsg:   fmt.Sprintf("virtio-blk serial %s is already used by %s", "OPI-SERIAL-1", otherVirtioCtrlName),
+		},
+		"too long serial": {
+			serial:   strings.Repeat("A", maxVirtioBlkSerialLength+1),
+			existing: map[string]string{},
+			spdk:     []string{},
+			out:      "",
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("virtio-blk serial %q must be 1 to %d characters long", strings.Repeat("A", maxVirtioBlkSerialLength+1), maxVirtioBlkSerialLength),
+		},
+		"non printable serial": {
+			serial:   "OPI SERIAL",
+			existing: map[string]string{},
+			spdk:     []string{},
+			out:      "",
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("virtio-blk serial %q must contain printable ASCII characters only", "OPI SERIAL"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			for name, serial := range tt.existing {
+				testEnv.opiSpdkServer.setVirtioBlkSerial(name, serial)
+			}
+
+			ctx := metadata.AppendToOutgoingContext(testEnv.ctx, VirtioBlkSerialMetadataKey, tt.serial)
+			var header metadata.MD
+			request := &pb.CreateVirtioBlkRequest{VirtioBlk: &testVirtioCtrl, VirtioBlkId: testVirtioCtrlID}
+			_, err := testEnv.client.CreateVirtioBlk(ctx, request, grpc.Header(&header))
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if serial := testEnv.opiSpdkServer.Virt.blkSerials[testVirtioCtrlName]; serial != tt.out {
+				t.Error("indexed serial: expected", tt.out, "received", serial)
+			}
+			values := header.Get(VirtioBlkSerialMetadataKey)
+			if tt.out != "" && (len(values) != 1 || values[0] != tt.out) {
+				t.Error("serial header: expected", tt.out, "received", values)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_VirtioBlkSerialGeneratedAndReleased(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+	})
+	defer testEnv.Close()
+
+	serials := map[string]bool{}
+	for _, id := range []string{testVirtioCtrlID, "virtio-blk-other"} {
+		var header metadata.MD
+		request := &pb.CreateVirtioBlkRequest{VirtioBlk: &testVirtioCtrl, VirtioBlkId: id}
+		if _, err := testEnv.client.CreateVirtioBlk(testEnv.ctx, request, grpc.Header(&header)); err != nil {
+			t.Fatal("expected no error, received", err)
+		}
+		values := header.Get(VirtioBlkSerialMetadataKey)
+		if len(values) != 1 || len(values[0]) != maxVirtioBlkSerialLength {
+			t.Fatal("expected generated serial of", maxVirtioBlkSerialLength, "characters, received", values)
+		}
+		if serials[values[0]] {
+			t.Error("expected unique generated serials, received duplicate", values[0])
+		}
+		serials[values[0]] = true
+	}
+
+	if _, err := testEnv.client.DeleteVirtioBlk(testEnv.ctx, &pb.DeleteVirtioBlkRequest{Name: testVirtioCtrlName}); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if len(testEnv.opiSpdkServer.Virt.serials) != 1 || len(testEnv.opiSpdkServer.Virt.blkSerials) != 1 {
+		t.Error("expected serial of deleted virtio-blk to be released, received", testEnv.opiSpdkServer.Virt.serials)
+	}
+}
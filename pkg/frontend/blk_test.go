@@ -434,7 +434,7 @@ func TestFrontEnd_ListVirtioBlks(t *testing.T) {
 			testEnv := createTestEnvironment(tt.spdk)
 			defer testEnv.Close()
 
-			testEnv.opiSpdkServer.Pagination["existing-pagination-token"] = 1
+			testEnv.opiSpdkServer.Pagination.Set("existing-pagination-token", 1)
 
 			request := &pb.ListVirtioBlksRequest{PageSize: tt.size, PageToken: tt.token}
 			response, err := testEnv.client.ListVirtioBlks(testEnv.ctx, request)
@@ -572,12 +572,53 @@ func TestFrontEnd_StatsVirtioBlk(t *testing.T) {
 		errCode codes.Code
 		errMsg  string
 	}{
-		"unimplemented method": {
+		"valid request with valid SPDK response": {
 			testVirtioCtrlID,
+			&pb.VolumeStats{
+				ReadBytesCount:    1245184,
+				ReadOpsCount:      304,
+				WriteBytesCount:   524288,
+				WriteOpsCount:     128,
+				UnmapBytesCount:   65536,
+				UnmapOpsCount:     2,
+				ReadLatencyTicks:  6851536,
+				WriteLatencyTicks: 2925440,
+				UnmapLatencyTicks: 11200,
+			},
+			[]string{`{"id":%d,"error":{"code":0,"message":""},"result":{"tick_rate":2200000000,"ticks":31847203648,"bdevs":[` +
+				`{"name":"Malloc0","bytes_read":36864,"num_read_ops":9,"bytes_written":0,"num_write_ops":0,"bytes_unmapped":0,"num_unmap_ops":0,"bytes_copied":0,"num_copy_ops":0,"read_latency_ticks":178380,"max_read_latency_ticks":32440,"min_read_latency_ticks":9910,"write_latency_ticks":0,"max_write_latency_ticks":0,"min_write_latency_ticks":0,"unmap_latency_ticks":0,"max_unmap_latency_ticks":0,"min_unmap_latency_ticks":0,"copy_latency_ticks":0,"max_copy_latency_ticks":0,"min_copy_latency_ticks":0,"io_error":{}},` +
+				`{"name":"Malloc42","bytes_read":1245184,"num_read_ops":304,"bytes_written":524288,"num_write_ops":128,"bytes_unmapped":65536,"num_unmap_ops":2,"bytes_copied":0,"num_copy_ops":0,"read_latency_ticks":6851536,"max_read_latency_ticks":63120,"min_read_latency_ticks":11280,"write_latency_ticks":2925440,"max_write_latency_ticks":41600,"min_write_latency_ticks":14220,"unmap_latency_ticks":11200,"max_unmap_latency_ticks":6100,"min_unmap_latency_ticks":5100,"copy_latency_ticks":0,"max_copy_latency_ticks":0,"min_copy_latency_ticks":0,"io_error":{}}` +
+				`]}}`},
+			codes.OK,
+			"",
+		},
+		"valid request with missing backing bdev": {
+			testVirtioCtrlID,
+			nil,
+			[]string{`{"id":%d,"error":{"code":0,"message":""},"result":{"tick_rate":2200000000,"ticks":31847203648,"bdevs":[{"name":"Malloc0","bytes_read":36864,"num_read_ops":9}]}}`},
+			codes.NotFound,
+			fmt.Sprintf("unable to find bdev %v of %v", "Malloc42", testVirtioCtrlID),
+		},
+		"valid request with invalid marshal SPDK response": {
+			testVirtioCtrlID,
+			nil,
+			[]string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			codes.Unavailable,
+			fmt.Sprintf("bdev_get_iostat: %v", "json: cannot unmarshal bool into Go value of type spdk.BdevGetIostatResult"),
+		},
+		"valid request with error code from SPDK response": {
+			testVirtioCtrlID,
+			nil,
+			[]string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{}}`},
+			codes.Unavailable,
+			fmt.Sprintf("bdev_get_iostat: %v", "json response error: myopierr"),
+		},
+		"controller without backing bdev": {
+			"no-bdev-id",
 			nil,
 			[]string{},
-			codes.Unimplemented,
-			fmt.Sprintf("%v method is not implemented", "StatsVirtioBlk"),
+			codes.NotFound,
+			fmt.Sprintf("no backing bdev for %v", "no-bdev-id"),
 		},
 		"valid request with unknown key": {
 			"unknown-id",
@@ -602,6 +643,7 @@ func TestFrontEnd_StatsVirtioBlk(t *testing.T) {
 			defer testEnv.Close()
 
 			testEnv.opiSpdkServer.Virt.BlkCtrls[testVirtioCtrlID] = utils.ProtoClone(&testVirtioCtrl)
+			testEnv.opiSpdkServer.Virt.BlkCtrls["no-bdev-id"] = &pb.VirtioBlk{Name: "no-bdev-id"}
 
 			request := &pb.StatsVirtioBlkRequest{Name: tt.in}
 			response, err := testEnv.client.StatsVirtioBlk(testEnv.ctx, request)
@@ -760,3 +802,51 @@ func TestFrontEnd_DeleteVirtioBlk(t *testing.T) {
 		})
 	}
 }
+
+func TestFrontEnd_VirtioBlkVfioUserTransport(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+	})
+	defer testEnv.Close()
+	testEnv.opiSpdkServer.Virt.transport = NewVirtioBlkVfioUserTransport(t.TempDir(), testEnv.opiSpdkServer.rpc)
+	recorder := testEnv.recordSpdkParams()
+
+	request := &pb.CreateVirtioBlkRequest{VirtioBlk: &testVirtioCtrl, VirtioBlkId: testVirtioCtrlID}
+	if _, err := testEnv.client.CreateVirtioBlk(testEnv.ctx, request); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	// SPDK cannot report vfio-user endpoints, so created ones are returned
+	if _, err := testEnv.client.GetVirtioBlk(testEnv.ctx, &pb.GetVirtioBlkRequest{Name: testVirtioCtrlName}); err != nil {
+		t.Error("expected no error, received", err)
+	}
+	response, err := testEnv.client.ListVirtioBlks(testEnv.ctx, &pb.ListVirtioBlksRequest{})
+	if err != nil {
+		t.Error("expected no error, received", err)
+	}
+	if blks := response.GetVirtioBlks(); len(blks) != 1 || blks[0].Name != testVirtioCtrlName {
+		t.Error("expected listed", testVirtioCtrlName, "received", blks)
+	}
+
+	if _, err := testEnv.client.DeleteVirtioBlk(testEnv.ctx, &pb.DeleteVirtioBlkRequest{Name: testVirtioCtrlName}); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	wantMethods := []string{"vfu_virtio_create_blk_endpoint", "vfu_virtio_delete_endpoint"}
+	if !reflect.DeepEqual(recorder.methods, wantMethods) {
+		t.Error("spdk methods: expected", wantMethods, "received", recorder.methods)
+	}
+	wantParams := []string{
+		fmt.Sprintf(`{"name":%q,"bdev_name":"Malloc42"}`, testVirtioCtrlID),
+		fmt.Sprintf(`{"name":%q}`, testVirtioCtrlID),
+	}
+	if !reflect.DeepEqual(recorder.params, wantParams) {
+		t.Error("spdk params: expected", wantParams, "received", recorder.params)
+	}
+	if _, ok := testEnv.opiSpdkServer.Virt.BlkCtrls[testVirtioCtrlName]; ok {
+		t.Error("expected virtio-blk deleted")
+	}
+}
@@ -10,24 +10,22 @@ import (
 	"go.einride.tech/aip/resourcename"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
 
 func (s *Server) validateCreateVirtioBlkRequest(in *pb.CreateVirtioBlkRequest) error {
+	v := &utils.Validator{}
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
-	}
+	v.CheckRequiredFields(in)
 	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	if err := resourcename.Validate(in.VirtioBlk.VolumeNameRef); err != nil {
-		return err
+	if in.GetVirtioBlk().GetVolumeNameRef() != "" {
+		v.Check("virtio_blk.volume_name_ref", resourcename.Validate(in.VirtioBlk.VolumeNameRef))
 	}
 	// see https://google.aip.dev/133#user-specified-ids
 	if in.VirtioBlkId != "" {
-		if err := resourceid.ValidateUserSettable(in.VirtioBlkId); err != nil {
-			return err
-		}
+		v.Check("virtio_blk_id", resourceid.ValidateUserSettable(in.VirtioBlkId))
 	}
-	return nil
+	return v.Err()
 }
 
 func (s *Server) validateDeleteVirtioBlkRequest(in *pb.DeleteVirtioBlkRequest) error {
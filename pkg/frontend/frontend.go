@@ -7,6 +7,7 @@ package frontend
 
 import (
 	"log"
+	"sync"
 
 	"github.com/philippgille/gokv"
 
@@ -21,6 +22,7 @@ type NvmeParameters struct {
 	Controllers map[string]*pb.NvmeController
 	Namespaces  map[string]*pb.NvmeNamespace
 	transports  map[pb.NvmeTransportType]NvmeTransport
+	anaGroups   map[string]int32
 }
 
 // VirtioParameters contains all VirtIO related structures
@@ -29,6 +31,10 @@ type VirtioParameters struct {
 	ScsiCtrls map[string]*pb.VirtioScsiController
 	ScsiLuns  map[string]*pb.VirtioScsiLun
 	transport VirtioBlkTransport
+	// serials maps virtio-blk serials to names of devices using them
+	serials map[string]string
+	// blkSerials maps virtio-blk names to their serials
+	blkSerials map[string]string
 }
 
 // Server contains frontend related OPI services
@@ -41,9 +47,31 @@ type Server struct {
 	store      gokv.Store
 	Nvme       NvmeParameters
 	Virt       VirtioParameters
-	Pagination map[string]int
+	Pagination *utils.Pagination
+	// AutoPause pauses subsystems around namespace and listener changes
+	AutoPause bool
+	// emptyStats is policy applied when SPDK reports no stats for a resource
+	emptyStats string
+	// nqnBase is base of NQNs generated for subsystems created without one,
+	// empty if generation is disabled
+	nqnBase string
 
 	keyToTemporaryFile func(pskKey []byte) (string, error)
+	// iostatSamples keeps last sampled stats per volume to compute IOPS
+	iostatSamples   map[string]iostatSample
+	iostatSamplesMu sync.Mutex
+	// resourceLocks serializes mutating operations on the same resource
+	resourceLocks *utils.KeyedMutex
+	// mapsMu guards Nvme and Virt maps, since resourceLocks serialize
+	// operations on one resource only
+	mapsMu sync.RWMutex
+	// idempotencyKeys resolves IDs of resources created with idempotency key
+	idempotencyKeys *utils.IdempotencyKeys
+	// releaser is notified of children deleted with cascaded subsystem
+	releaser utils.ResourceReleaser
+	// stats shares stats of all bdevs between stats requests, nil queries
+	// SPDK on every request
+	stats *utils.StatsCollector
 }
 
 // NewServer creates initialized instance of FrontEnd server communicating
@@ -65,16 +93,23 @@ func NewServer(jsonRPC spdk.JSONRPC, store gokv.Store) *Server {
 			transports: map[pb.NvmeTransportType]NvmeTransport{
 				pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP: NewNvmeTCPTransport(jsonRPC),
 			},
+			anaGroups: make(map[string]int32),
 		},
 		Virt: VirtioParameters{
-			BlkCtrls:  make(map[string]*pb.VirtioBlk),
-			ScsiCtrls: make(map[string]*pb.VirtioScsiController),
-			ScsiLuns:  make(map[string]*pb.VirtioScsiLun),
-			transport: NewVhostUserBlkTransport(),
+			BlkCtrls:   make(map[string]*pb.VirtioBlk),
+			ScsiCtrls:  make(map[string]*pb.VirtioScsiController),
+			ScsiLuns:   make(map[string]*pb.VirtioScsiLun),
+			transport:  NewVhostUserBlkTransport(),
+			serials:    make(map[string]string),
+			blkSerials: make(map[string]string),
 		},
-		Pagination: make(map[string]int),
+		Pagination: utils.NewPagination(),
 
 		keyToTemporaryFile: utils.KeyToTemporaryFile,
+		iostatSamples:      make(map[string]iostatSample),
+		emptyStats:         EmptyStatsNoData,
+		resourceLocks:      utils.NewKeyedMutex(),
+		idempotencyKeys:    utils.NewIdempotencyKeys(store),
 	}
 }
 
@@ -112,3 +147,30 @@ func NewCustomizedServer(
 	server.Virt.transport = virtioBlkTransport
 	return server
 }
+
+// ResourceCounts returns number of FrontEnd resources per resource type
+func (s *Server) ResourceCounts() map[string]int {
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	return map[string]int{
+		"nvme_subsystems":         len(s.Nvme.Subsystems),
+		"nvme_controllers":        len(s.Nvme.Controllers),
+		"nvme_namespaces":         len(s.Nvme.Namespaces),
+		"virtio_blks":             len(s.Virt.BlkCtrls),
+		"virtio_scsi_controllers": len(s.Virt.ScsiCtrls),
+		"virtio_scsi_luns":        len(s.Virt.ScsiLuns),
+	}
+}
+
+// StateResources returns FrontEnd resources per resource type for export
+// and import of bridge state
+func (s *Server) StateResources() map[string]utils.StateResource {
+	return map[string]utils.StateResource{
+		"nvme_subsystems":         utils.ProtoStateResource(&s.mapsMu, s.Nvme.Subsystems),
+		"nvme_controllers":        utils.ProtoStateResource(&s.mapsMu, s.Nvme.Controllers),
+		"nvme_namespaces":         utils.ProtoStateResource(&s.mapsMu, s.Nvme.Namespaces),
+		"virtio_blks":             utils.ProtoStateResource(&s.mapsMu, s.Virt.BlkCtrls),
+		"virtio_scsi_controllers": utils.ProtoStateResource(&s.mapsMu, s.Virt.ScsiCtrls),
+		"virtio_scsi_luns":        utils.ProtoStateResource(&s.mapsMu, s.Virt.ScsiLuns),
+	}
+}
@@ -7,9 +7,11 @@ package frontend
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"net"
 	"os"
+	"reflect"
 	"testing"
 
 	"google.golang.org/grpc"
@@ -28,6 +30,7 @@ var checkGlobalTestProtoObjectsNotChanged = utils.CheckTestProtoObjectsNotChange
 	&testController,
 	&testSubsystem,
 	&testNamespace,
+	&testVirtioScsiLun,
 )
 
 // TODO: move test infrastructure code to a separate (test/server) package to avoid duplication
@@ -56,6 +59,30 @@ func (e *testEnv) Close() {
 	}
 }
 
+// spdkParamsRecorder keeps called methods and JSON of params sent to SPDK to
+// verify them in tests
+type spdkParamsRecorder struct {
+	spdk.JSONRPC
+	methods []string
+	params  []string
+}
+
+func (r *spdkParamsRecorder) Call(ctx context.Context, method string, args, result interface{}) error {
+	data, err := json.Marshal(args)
+	if err != nil {
+		log.Panic(err)
+	}
+	r.methods = append(r.methods, method)
+	r.params = append(r.params, string(data))
+	return r.JSONRPC.Call(ctx, method, args, result)
+}
+
+func (e *testEnv) recordSpdkParams() *spdkParamsRecorder {
+	recorder := &spdkParamsRecorder{JSONRPC: e.opiSpdkServer.rpc}
+	e.opiSpdkServer.rpc = recorder
+	return recorder
+}
+
 func createTestEnvironment(spdkResponses []string) *testEnv {
 	env := &testEnv{}
 	env.testSocket = utils.GenerateSocketName("frontend")
@@ -173,3 +200,25 @@ func TestFrontEnd_NewCustomizedServer(t *testing.T) {
 		})
 	}
 }
+
+func TestFrontEnd_ResourceCounts(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+	testEnv.opiSpdkServer.Nvme.Controllers[testControllerName] = utils.ProtoClone(&testController)
+	testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = utils.ProtoClone(&testNamespace)
+	testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName+"1"] = utils.ProtoClone(&testNamespace)
+	testEnv.opiSpdkServer.Virt.BlkCtrls[testVirtioCtrlName] = utils.ProtoClone(&testVirtioCtrl)
+
+	expected := map[string]int{
+		"nvme_subsystems":         1,
+		"nvme_controllers":        1,
+		"nvme_namespaces":         2,
+		"virtio_blks":             1,
+		"virtio_scsi_controllers": 0,
+		"virtio_scsi_luns":        0,
+	}
+	if counts := testEnv.opiSpdkServer.ResourceCounts(); !reflect.DeepEqual(counts, expected) {
+		t.Error("counts: expected", expected, "received", counts)
+	}
+}
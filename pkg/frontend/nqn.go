@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+var nqnBasePattern = regexp.MustCompile(`^nqn\.[0-9]{4}-[0-9]{2}(\.[a-zA-Z0-9]+)+$`)
+
+// SetNqnBase enables generation of NQNs for Nvme subsystems created without
+// one. base is date and reversed domain part of NQN, e.g.
+// nqn.2024-01.com.example, and generated NQNs are <base>:opi:<resource id>.
+// Empty base disables generation, so NQN is required
+func (s *Server) SetNqnBase(base string) error {
+	if base != "" && !nqnBasePattern.MatchString(base) {
+		return fmt.Errorf("NQN base %q does not match pattern nqn.yyyy-mm.reverse.domain", base)
+	}
+	s.nqnBase = base
+	return nil
+}
+
+// generateNqn returns NQN for subsystem resourceID created without NQN
+func (s *Server) generateNqn(resourceID string) (string, error) {
+	nqn := fmt.Sprintf("%s:opi:%s", s.nqnBase, resourceID)
+	if err := utils.ValidateNqn(nqn); err != nil {
+		return "", err
+	}
+	return nqn, nil
+}
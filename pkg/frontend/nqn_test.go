@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_SetNqnBase(t *testing.T) {
+	tests := map[string]struct {
+		base   string
+		errMsg string
+	}{
+		"empty base": {
+			base:   "",
+			errMsg: "",
+		},
+		"valid base": {
+			base:   "nqn.2024-01.com.example",
+			errMsg: "",
+		},
+		"base with colon": {
+			base:   "nqn.2024-01.com.example:opi",
+			errMsg: fmt.Sprintf("NQN base %q does not match pattern nqn.yyyy-mm.reverse.domain", "nqn.2024-01.com.example:opi"),
+		},
+		"base without date": {
+			base:   "nqn.com.example",
+			errMsg: fmt.Sprintf("NQN base %q does not match pattern nqn.yyyy-mm.reverse.domain", "nqn.com.example"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			err := testEnv.opiSpdkServer.SetNqnBase(tt.base)
+
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tt.errMsg {
+				t.Error("error: expected", tt.errMsg, "received", errMsg)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_CreateNvmeSubsystemGeneratedNqn(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	longBase := "nqn.2024-01." + strings.Repeat("a", 200)
+	tests := map[string]struct {
+		base    string
+		nqn     string
+		spdk    []string
+		outNqn  string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"empty nqn without base": {
+			base:    "",
+			nqn:     "",
+			spdk:    []string{},
+			outNqn:  "",
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("missing required field: nvme_subsystem.spec.nqn; NQN value (%s) does not match pattern", ""),
+		},
+		"empty nqn with base": {
+			base: "nqn.2024-01.com.example",
+			nqn:  "",
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"jsonrpc":"2.0","id":%d,"result":{"version":"SPDK v20.10","fields":{"major":20,"minor":10,"patch":0,"suffix":""}}}`,
+			},
+			outNqn:  "nqn.2024-01.com.example:opi:" + testSubsystemID,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"explicit nqn with base": {
+			base: "nqn.2024-01.com.example",
+			nqn:  "nqn.2022-09.io.spdk:opi3",
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"jsonrpc":"2.0","id":%d,"result":{"version":"SPDK v20.10","fields":{"major":20,"minor":10,"patch":0,"suffix":""}}}`,
+			},
+			outNqn:  "nqn.2022-09.io.spdk:opi3",
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"generated nqn too long": {
+			base:    longBase,
+			nqn:     "",
+			spdk:    []string{},
+			outNqn:  "",
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Nqn value (%s) is too long, have to be between 1 and %d", longBase+":opi:"+testSubsystemID, utils.MaxNqnLength),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			if err := testEnv.opiSpdkServer.SetNqnBase(tt.base); err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+			recorder := testEnv.recordSpdkParams()
+
+			request := &pb.CreateNvmeSubsystemRequest{
+				NvmeSubsystem:   &pb.NvmeSubsystem{Spec: &pb.NvmeSubsystemSpec{Nqn: tt.nqn}},
+				NvmeSubsystemId: testSubsystemID,
+			}
+			response, err := testEnv.client.CreateNvmeSubsystem(testEnv.ctx, request)
+
+			if response.GetSpec().GetNqn() != tt.outNqn {
+				t.Error("nqn: expected", tt.outNqn, "received", response.GetSpec().GetNqn())
+			}
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if tt.outNqn == "" {
+				return
+			}
+			if stored := testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName]; stored.GetSpec().GetNqn() != tt.outNqn {
+				t.Error("stored nqn: expected", tt.outNqn, "received", stored.GetSpec().GetNqn())
+			}
+			sent := fmt.Sprintf(`"nqn":%q`, tt.outNqn)
+			if len(recorder.params) == 0 || !strings.Contains(recorder.params[0], sent) {
+				t.Error("spdk params: expected", sent, "received", recorder.params)
+			}
+		})
+	}
+}
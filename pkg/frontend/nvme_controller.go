@@ -9,8 +9,10 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"path"
+	"net"
 	"sort"
+	"strconv"
+	"strings"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
@@ -18,7 +20,6 @@ import (
 	"github.com/google/uuid"
 	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/fieldmask"
-	"go.einride.tech/aip/resourceid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
@@ -31,6 +32,89 @@ func sortNvmeControllers(controllers []*pb.NvmeController) {
 	})
 }
 
+// rollbackNvmeController removes listener of controller after a later step
+// of its creation failed, so that it is not left dangling in SPDK. Rollback
+// is best effort, its failure is only logged
+func rollbackNvmeController(ctx context.Context, transport NvmeTransport, ctrlr *pb.NvmeController, subsys *pb.NvmeSubsystem, cause error) {
+	log.Printf("error: failed to create NvmeController %v, removing its listener: %v", ctrlr.Name, cause)
+	ctx, cancel := utils.CleanupContext(ctx)
+	defer cancel()
+	if err := transport.DeleteController(ctx, ctrlr, subsys); err != nil {
+		log.Printf("error: failed to remove listener of NvmeController %v: %v", ctrlr.Name, err)
+	}
+}
+
+// listenerAddress returns transport, address and port controller listens on,
+// normalized so that the same listener is always reported the same way, e.g.
+// IPv4-mapped IPv6 addresses as IPv4. It returns false for controllers
+// without fabrics listener, e.g. vfio-user ones
+func listenerAddress(ctrlr *pb.NvmeController) (string, bool) {
+	fabrics := ctrlr.GetSpec().GetFabricsId()
+	if fabrics == nil || fabrics.GetTraddr() == "" {
+		return "", false
+	}
+	addr := strings.ToLower(fabrics.GetTraddr())
+	if ip := net.ParseIP(addr); ip != nil {
+		if ipv4 := ip.To4(); ipv4 != nil {
+			ip = ipv4
+		}
+		addr = ip.String()
+	}
+	port := fabrics.GetTrsvcid()
+	if number, err := strconv.Atoi(port); err == nil {
+		port = strconv.Itoa(number)
+	}
+	return fmt.Sprintf("%v %v", ctrlr.GetSpec().GetTrtype(), net.JoinHostPort(addr, port)), true
+}
+
+// listenerLockKey returns key of resourceLocks serializing controllers
+// adding listener, it cannot clash with resource names
+func listenerLockKey(listener string) string {
+	return "listener:" + listener
+}
+
+// FindNvmeController returns controller stored under name
+func (s *Server) FindNvmeController(name string) (*pb.NvmeController, bool) {
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	controller, ok := s.Nvme.Controllers[name]
+	return controller, ok
+}
+
+// NvmeControllersSnapshot returns stored controllers keyed by name, copied so
+// that callers iterate them without holding the maps lock
+func (s *Server) NvmeControllersSnapshot() map[string]*pb.NvmeController {
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	controllers := make(map[string]*pb.NvmeController, len(s.Nvme.Controllers))
+	for name, controller := range s.Nvme.Controllers {
+		controllers[name] = controller
+	}
+	return controllers
+}
+
+// checkListenerNotInUse fails with AlreadyExists if an active controller of
+// any subsystem already listens on the same transport, address and port as
+// ctrlr, since SPDK rejects such listener with a cryptic error
+func (s *Server) checkListenerNotInUse(ctrlr *pb.NvmeController) error {
+	listener, ok := listenerAddress(ctrlr)
+	if !ok {
+		return nil
+	}
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	for _, controller := range s.Nvme.Controllers {
+		if !controller.GetStatus().GetActive() {
+			continue
+		}
+		if existing, ok := listenerAddress(controller); ok && existing == listener {
+			msg := fmt.Sprintf("listener %v is already used by %v", listener, controller.Name)
+			return status.Errorf(codes.AlreadyExists, msg)
+		}
+	}
+	return nil
+}
+
 // CreateNvmeController creates an Nvme controller
 func (s *Server) CreateNvmeController(ctx context.Context, in *pb.CreateNvmeControllerRequest) (*pb.NvmeController, error) {
 	// check input correctness
@@ -38,24 +122,41 @@ func (s *Server) CreateNvmeController(ctx context.Context, in *pb.CreateNvmeCont
 		return nil, err
 	}
 	// see https://google.aip.dev/133#user-specified-ids
-	resourceID := resourceid.NewSystemGenerated()
+	resourceID, err := s.idempotencyKeys.ResourceID(ctx, "NvmeController", in.NvmeControllerId)
+	if err != nil {
+		return nil, err
+	}
 	if in.NvmeControllerId != "" {
 		log.Printf("client provided the ID of a resource %v, ignoring the name field %v", in.NvmeControllerId, in.NvmeController.Name)
-		resourceID = in.NvmeControllerId
 	}
 	in.NvmeController.Name = utils.ResourceIDToControllerName(utils.GetSubsystemIDFromNvmeName(in.Parent), resourceID)
+	unlock := s.resourceLocks.Lock(in.NvmeController.Name)
+	defer unlock()
 	// idempotent API when called with same key, should return same object
+	s.mapsMu.RLock()
 	controller, ok := s.Nvme.Controllers[in.NvmeController.Name]
+	s.mapsMu.RUnlock()
 	if ok {
 		log.Printf("Already existing NvmeController with name %v", in.NvmeController.Name)
 		return controller, nil
 	}
 	// not found, so create a new one
+	s.mapsMu.RLock()
 	subsys, ok := s.Nvme.Subsystems[in.Parent]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := fmt.Errorf("unable to find subsystem %s", in.Parent)
 		return nil, err
 	}
+	// controllers of other names may add the same listener concurrently,
+	// serialize them until the created controller is stored
+	if listener, ok := listenerAddress(in.NvmeController); ok {
+		unlockListener := s.resourceLocks.Lock(listenerLockKey(listener))
+		defer unlockListener()
+	}
+	if err := s.checkListenerNotInUse(in.NvmeController); err != nil {
+		return nil, err
+	}
 
 	transport, ok := s.Nvme.transports[in.NvmeController.Spec.Trtype]
 	if !ok {
@@ -63,15 +164,30 @@ func (s *Server) CreateNvmeController(ctx context.Context, in *pb.CreateNvmeCont
 			"handler for transport type %v is not registered", in.NvmeController.Spec.Trtype)
 	}
 
-	err := transport.CreateController(ctx, in.NvmeController, subsys)
+	listenerAdded := false
+	err = s.withSubsystemPaused(ctx, subsys.Spec.Nqn, func() error {
+		if err := transport.CreateController(ctx, in.NvmeController, subsys); err != nil {
+			return err
+		}
+		listenerAdded = true
+		return nil
+	})
 	if err != nil {
+		if listenerAdded && !utils.DryRunRequested(ctx) {
+			rollbackNvmeController(ctx, transport, in.NvmeController, subsys, err)
+		}
 		return nil, err
 	}
 
 	response := utils.ProtoClone(in.NvmeController)
 	response.Spec.NvmeControllerId = proto.Int32(-1)
 	response.Status = &pb.NvmeControllerStatus{Active: true}
+	if utils.DryRunRequested(ctx) {
+		return response, nil
+	}
+	s.mapsMu.Lock()
 	s.Nvme.Controllers[in.NvmeController.Name] = response
+	s.mapsMu.Unlock()
 
 	return response, nil
 }
@@ -82,8 +198,12 @@ func (s *Server) DeleteNvmeController(ctx context.Context, in *pb.DeleteNvmeCont
 	if err := s.validateDeleteNvmeControllerRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	controller, ok := s.Nvme.Controllers[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			return &emptypb.Empty{}, nil
@@ -92,7 +212,9 @@ func (s *Server) DeleteNvmeController(ctx context.Context, in *pb.DeleteNvmeCont
 		return nil, err
 	}
 	subsysName := utils.ResourceIDToSubsystemName(utils.GetSubsystemIDFromNvmeName(in.Name))
+	s.mapsMu.RLock()
 	subsys, ok := s.Nvme.Subsystems[subsysName]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := fmt.Errorf("unable to find subsystem %s", subsysName)
 		return nil, err
@@ -104,12 +226,22 @@ func (s *Server) DeleteNvmeController(ctx context.Context, in *pb.DeleteNvmeCont
 			"handler for transport type %v is not registered", controller.Spec.Trtype)
 	}
 
-	err := transport.DeleteController(ctx, controller, subsys)
-	if err != nil {
-		return nil, err
+	// listener of inactive controller was already removed by RemoveAllListeners
+	if controller.GetStatus().GetActive() {
+		err := s.withSubsystemPaused(ctx, subsys.Spec.Nqn, func() error {
+			return transport.DeleteController(ctx, controller, subsys)
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	if utils.DryRunRequested(ctx) {
+		return &emptypb.Empty{}, nil
+	}
+	s.mapsMu.Lock()
 	delete(s.Nvme.Controllers, controller.Name)
+	s.mapsMu.Unlock()
 	return &emptypb.Empty{}, nil
 }
 
@@ -119,8 +251,12 @@ func (s *Server) UpdateNvmeController(_ context.Context, in *pb.UpdateNvmeContro
 	if err := s.validateUpdateNvmeControllerRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.NvmeController.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	ctrlr, ok := s.Nvme.Controllers[in.NvmeController.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			log.Printf("TODO: in case of AllowMissing, create a new resource, don;t return error")
@@ -128,7 +264,7 @@ func (s *Server) UpdateNvmeController(_ context.Context, in *pb.UpdateNvmeContro
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.NvmeController.Name)
 		return nil, err
 	}
-	resourceID := path.Base(ctrlr.Name)
+	resourceID := utils.ResourceNameToID(ctrlr.Name)
 	// update_mask = 2
 	if err := fieldmask.Validate(in.UpdateMask, in.NvmeController); err != nil {
 		return nil, err
@@ -136,7 +272,9 @@ func (s *Server) UpdateNvmeController(_ context.Context, in *pb.UpdateNvmeContro
 	log.Printf("TODO: use resourceID=%v", resourceID)
 	response := utils.ProtoClone(in.NvmeController)
 	response.Status = &pb.NvmeControllerStatus{Active: true}
+	s.mapsMu.Lock()
 	s.Nvme.Controllers[in.NvmeController.Name] = response
+	s.mapsMu.Unlock()
 	return response, nil
 }
 
@@ -147,13 +285,15 @@ func (s *Server) ListNvmeControllers(_ context.Context, in *pb.ListNvmeControlle
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	Blobarray := []*pb.NvmeController{}
 	for _, controller := range s.Nvme.Controllers {
 		Blobarray = append(Blobarray, controller)
 	}
+	s.mapsMu.RUnlock()
 	sortNvmeControllers(Blobarray)
 	token := uuid.New().String()
-	s.Pagination[token] = int(in.PageSize)
+	s.Pagination.Set(token, int(in.PageSize))
 	return &pb.ListNvmeControllersResponse{NvmeControllers: Blobarray, NextPageToken: token}, nil
 }
 
@@ -164,7 +304,9 @@ func (s *Server) GetNvmeController(_ context.Context, in *pb.GetNvmeControllerRe
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	controller, ok := s.Nvme.Controllers[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
@@ -173,18 +315,27 @@ func (s *Server) GetNvmeController(_ context.Context, in *pb.GetNvmeControllerRe
 }
 
 // StatsNvmeController gets an Nvme controller stats
-func (s *Server) StatsNvmeController(_ context.Context, in *pb.StatsNvmeControllerRequest) (*pb.StatsNvmeControllerResponse, error) {
+func (s *Server) StatsNvmeController(ctx context.Context, in *pb.StatsNvmeControllerRequest) (*pb.StatsNvmeControllerResponse, error) {
 	// check input correctness
 	if err := s.validateStatsNvmeControllerRequest(in); err != nil {
 		return nil, err
 	}
 	// fetch object from the database
-	ctrlr, ok := s.Nvme.Controllers[in.Name]
+	s.mapsMu.RLock()
+	_, ok := s.Nvme.Controllers[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(ctrlr.Name)
-	log.Printf("TODO: send name to SPDK and get back stats: %v", resourceID)
-	return &pb.StatsNvmeControllerResponse{Stats: &pb.VolumeStats{ReadOpsCount: -1, WriteOpsCount: -1}}, nil
+	volumes := s.subsystemVolumes(utils.GetSubsystemIDFromNvmeName(in.Name))
+	stats, err := s.volumesStats(ctx, volumes)
+	if err != nil {
+		return nil, err
+	}
+	stats, err = s.emptyStatsOr(ctx, in.Name, stats)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.StatsNvmeControllerResponse{Stats: stats}, nil
 }
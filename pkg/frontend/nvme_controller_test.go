@@ -6,9 +6,12 @@
 package frontend
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 
@@ -161,6 +164,90 @@ func TestFrontEnd_CreateNvmeController(t *testing.T) {
 			false,
 			testSubsystemName,
 		},
+		"valid request with queue limits": {
+			testControllerID,
+			&pb.NvmeController{
+				Spec: &pb.NvmeControllerSpec{
+					Endpoint:         testController.Spec.Endpoint,
+					NvmeControllerId: proto.Int32(1),
+					Trtype:           pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
+					MaxNsq:           8,
+					MaxNcq:           8,
+					Sqes:             7,
+				},
+			},
+			&pb.NvmeController{
+				Spec: &pb.NvmeControllerSpec{
+					Endpoint:         testController.Spec.Endpoint,
+					NvmeControllerId: proto.Int32(-1),
+					Trtype:           pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
+					MaxNsq:           8,
+					MaxNcq:           8,
+					Sqes:             7,
+				},
+				Status: &pb.NvmeControllerStatus{
+					Active: true,
+				},
+			},
+			[]string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			codes.OK,
+			"",
+			false,
+			testSubsystemName,
+		},
+		"out of range max_nsq": {
+			testControllerID,
+			&pb.NvmeController{
+				Spec: &pb.NvmeControllerSpec{
+					Endpoint:         testController.Spec.Endpoint,
+					NvmeControllerId: proto.Int32(1),
+					Trtype:           pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
+					MaxNsq:           65536,
+				},
+			},
+			nil,
+			[]string{},
+			codes.InvalidArgument,
+			"max_nsq 65536 is out of range, must be 0 to 65535",
+			false,
+			testSubsystemName,
+		},
+		"out of range sqes": {
+			testControllerID,
+			&pb.NvmeController{
+				Spec: &pb.NvmeControllerSpec{
+					Endpoint:         testController.Spec.Endpoint,
+					NvmeControllerId: proto.Int32(1),
+					Trtype:           pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
+					Sqes:             17,
+				},
+			},
+			nil,
+			[]string{},
+			codes.InvalidArgument,
+			"sqes 17 is out of range, must be 0 to 16",
+			false,
+			testSubsystemName,
+		},
+		"negative max_ncq and mismatched cqes": {
+			testControllerID,
+			&pb.NvmeController{
+				Spec: &pb.NvmeControllerSpec{
+					Endpoint:         testController.Spec.Endpoint,
+					NvmeControllerId: proto.Int32(1),
+					Trtype:           pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
+					MaxNcq:           -1,
+					Sqes:             6,
+					Cqes:             4,
+				},
+			},
+			nil,
+			[]string{},
+			codes.InvalidArgument,
+			"max_ncq -1 is out of range, must be 0 to 65535; cqes 4 must match sqes 6, queues share depth",
+			false,
+			testSubsystemName,
+		},
 		"already exists": {
 			testControllerID,
 			&pb.NvmeController{
@@ -213,8 +300,8 @@ func TestFrontEnd_CreateNvmeController(t *testing.T) {
 			},
 			nil,
 			[]string{},
-			codes.Unknown,
-			"missing required field: parent",
+			codes.InvalidArgument,
+			"missing required field: parent; invalid endpoint type passed for transport",
 			false,
 			"",
 		},
@@ -342,6 +429,310 @@ func TestFrontEnd_CreateNvmeController(t *testing.T) {
 	}
 }
 
+// slowNvmeTransport takes a while to add listener and records how many
+// listeners are added concurrently
+type slowNvmeTransport struct {
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	created     int
+}
+
+func (t *slowNvmeTransport) CreateController(context.Context, *pb.NvmeController, *pb.NvmeSubsystem) error {
+	t.mu.Lock()
+	t.inFlight++
+	if t.inFlight > t.maxInFlight {
+		t.maxInFlight = t.inFlight
+	}
+	t.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	t.mu.Lock()
+	t.inFlight--
+	t.created++
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *slowNvmeTransport) DeleteController(context.Context, *pb.NvmeController, *pb.NvmeSubsystem) error {
+	return nil
+}
+
+func TestFrontEnd_CreateNvmeControllerListenerInUseConcurrently(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	transport := &slowNvmeTransport{}
+	testEnv.opiSpdkServer.Nvme.transports[pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP] = transport
+	testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+
+	const calls = 8
+	errs := make(chan error, calls)
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			request := &pb.CreateNvmeControllerRequest{
+				Parent:           testSubsystemName,
+				NvmeController:   &pb.NvmeController{Spec: utils.ProtoClone(testController.Spec)},
+				NvmeControllerId: fmt.Sprintf("controller-%d", i),
+			}
+			_, err := testEnv.client.CreateNvmeController(testEnv.ctx, request)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		switch status.Code(err) {
+		case codes.OK:
+			created++
+		case codes.AlreadyExists:
+		default:
+			t.Error("expected OK or AlreadyExists, received", err)
+		}
+	}
+	if created != 1 || transport.created != 1 || len(testEnv.opiSpdkServer.Nvme.Controllers) != 1 {
+		t.Error("expected single controller with the listener, created:", created,
+			"listeners:", transport.created, "stored:", len(testEnv.opiSpdkServer.Nvme.Controllers))
+	}
+	if transport.maxInFlight != 1 {
+		t.Error("expected listener adds serialized, received", transport.maxInFlight, "in flight")
+	}
+}
+
+func TestFrontEnd_CreateNvmeControllerDistinctListenersConcurrently(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	transport := &slowNvmeTransport{}
+	testEnv.opiSpdkServer.Nvme.transports[pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP] = transport
+	testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+
+	const calls = 8
+	errs := make(chan error, calls)
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			spec := utils.ProtoClone(testController.Spec)
+			spec.GetFabricsId().Trsvcid = fmt.Sprint(4420 + i)
+			request := &pb.CreateNvmeControllerRequest{
+				Parent:           testSubsystemName,
+				NvmeController:   &pb.NvmeController{Spec: spec},
+				NvmeControllerId: fmt.Sprintf("controller-%d", i),
+			}
+			_, err := testEnv.client.CreateNvmeController(testEnv.ctx, request)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error("expected OK, received", err)
+		}
+	}
+	if transport.created != calls || len(testEnv.opiSpdkServer.Nvme.Controllers) != calls {
+		t.Error("expected", calls, "controllers, listeners:", transport.created,
+			"stored:", len(testEnv.opiSpdkServer.Nvme.Controllers))
+	}
+}
+
+func TestFrontEnd_CreateNvmeControllerListenerInUse(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	otherControllerName := utils.ResourceIDToControllerName("subsystem-other", "controller-other")
+	otherIPv6ControllerName := utils.ResourceIDToControllerName("subsystem-other", "controller-other-ipv6")
+	fabrics := func(traddr, trsvcid string, adrfam pb.NvmeAddressFamily) *pb.NvmeControllerSpec_FabricsId {
+		return &pb.NvmeControllerSpec_FabricsId{
+			FabricsId: &pb.FabricsEndpoint{Traddr: traddr, Trsvcid: trsvcid, Adrfam: adrfam},
+		}
+	}
+	tests := map[string]struct {
+		endpoint *pb.NvmeControllerSpec_FabricsId
+		inactive bool
+		spdk     []string
+		methods  []string
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"same listener in another subsystem": {
+			endpoint: fabrics("127.0.0.1", "4420", pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4),
+			spdk:     []string{},
+			methods:  nil,
+			errCode:  codes.AlreadyExists,
+			errMsg:   fmt.Sprintf("listener %v is already used by %v", "NVME_TRANSPORT_TYPE_TCP 127.0.0.1:4420", otherControllerName),
+		},
+		"same listener as IPv4-mapped IPv6 address": {
+			endpoint: fabrics("::ffff:127.0.0.1", "4420", pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV6),
+			spdk:     []string{},
+			methods:  nil,
+			errCode:  codes.AlreadyExists,
+			errMsg:   fmt.Sprintf("listener %v is already used by %v", "NVME_TRANSPORT_TYPE_TCP 127.0.0.1:4420", otherControllerName),
+		},
+		"same listener as differently written IPv6 address": {
+			endpoint: fabrics("FE80:0:0::0001", "4420", pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV6),
+			spdk:     []string{},
+			methods:  nil,
+			errCode:  codes.AlreadyExists,
+			errMsg:   fmt.Sprintf("listener %v is already used by %v", "NVME_TRANSPORT_TYPE_TCP [fe80::1]:4420", otherIPv6ControllerName),
+		},
+		"distinct port": {
+			endpoint: fabrics("127.0.0.1", "4421", pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4),
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			methods:  []string{"nvmf_subsystem_add_listener"},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"distinct address": {
+			endpoint: fabrics("127.0.0.2", "4420", pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4),
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			methods:  []string{"nvmf_subsystem_add_listener"},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"same listener of inactive controller": {
+			endpoint: fabrics("127.0.0.1", "4420", pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4),
+			inactive: true,
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			methods:  []string{"nvmf_subsystem_add_listener"},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+			testEnv.opiSpdkServer.Nvme.transports[pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP] = NewNvmeTCPTransport(recorder)
+
+			testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+			other := utils.ProtoClone(&testController)
+			other.Name = otherControllerName
+			other.Status.Active = !tt.inactive
+			testEnv.opiSpdkServer.Nvme.Controllers[otherControllerName] = other
+			otherIPv6 := utils.ProtoClone(&testController)
+			otherIPv6.Name = otherIPv6ControllerName
+			otherIPv6.Spec.Endpoint = fabrics("fe80::1", "4420", pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV6)
+			testEnv.opiSpdkServer.Nvme.Controllers[otherIPv6ControllerName] = otherIPv6
+
+			request := &pb.CreateNvmeControllerRequest{
+				Parent: testSubsystemName,
+				NvmeController: &pb.NvmeController{
+					Spec: &pb.NvmeControllerSpec{
+						Endpoint: tt.endpoint,
+						Trtype:   pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
+					},
+				},
+				NvmeControllerId: testControllerID,
+			}
+			_, err := testEnv.client.CreateNvmeController(testEnv.ctx, request)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if !reflect.DeepEqual(recorder.methods, tt.methods) {
+				t.Error("methods: expected", tt.methods, "received", recorder.methods)
+			}
+			if _, ok := testEnv.opiSpdkServer.Nvme.Controllers[testControllerName]; ok != (tt.errCode == codes.OK) {
+				t.Error("expected controller stored", tt.errCode == codes.OK, "received", ok)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_CreateNvmeControllerRollback(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		spdk    []string
+		methods []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"failed resume removes listener": {
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":false}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			methods: []string{"nvmf_subsystem_pause", "nvmf_subsystem_add_listener", "nvmf_subsystem_resume", "nvmf_subsystem_remove_listener"},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("nvmf_subsystem_resume: %v", "json response error: myopierr"),
+		},
+		"failed listener removal keeps original error": {
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":false}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":false}`,
+			},
+			methods: []string{"nvmf_subsystem_pause", "nvmf_subsystem_add_listener", "nvmf_subsystem_resume", "nvmf_subsystem_remove_listener"},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("nvmf_subsystem_resume: %v", "json response error: myopierr"),
+		},
+		"failed listener add is not rolled back": {
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":false}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			methods: []string{"nvmf_subsystem_pause", "nvmf_subsystem_add_listener", "nvmf_subsystem_resume"},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create CTRL: %v", testControllerName),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+			testEnv.opiSpdkServer.Nvme.transports[pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP] = NewNvmeTCPTransport(recorder)
+			testEnv.opiSpdkServer.AutoPause = true
+			testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+
+			request := &pb.CreateNvmeControllerRequest{
+				Parent:           testSubsystemName,
+				NvmeController:   &pb.NvmeController{Spec: &pb.NvmeControllerSpec{Endpoint: testController.Spec.Endpoint, Trtype: pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP}},
+				NvmeControllerId: testControllerID,
+			}
+			_, err := testEnv.client.CreateNvmeController(testEnv.ctx, request)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+			if !reflect.DeepEqual(recorder.methods, tt.methods) {
+				t.Error("methods: expected", tt.methods, "received", recorder.methods)
+			}
+			if _, ok := testEnv.opiSpdkServer.Nvme.Controllers[testControllerName]; ok {
+				t.Error("expected controller not to be stored")
+			}
+		})
+	}
+}
+
 func TestFrontEnd_DeleteNvmeController(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
 	tests := map[string]struct {
@@ -791,10 +1182,7 @@ func TestFrontEnd_StatsNvmeController(t *testing.T) {
 	}{
 		"valid request with valid SPDK response": {
 			testControllerName,
-			&pb.VolumeStats{
-				ReadOpsCount:  -1,
-				WriteOpsCount: -1,
-			},
+			&pb.VolumeStats{},
 			[]string{},
 			codes.OK,
 			"",
@@ -11,39 +11,72 @@ import (
 	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/resourceid"
 	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
 
+const (
+	// maxNvmeIoQueues is max number of IO queues Nvme controller can have
+	maxNvmeIoQueues = 65535
+	// maxNvmeQueueEntriesPower is log2 of max number of Nvme queue entries
+	maxNvmeQueueEntriesPower = 16
+)
+
+// checkRange returns error if value of field is not in [min, max] range
+func checkRange(field string, value int32, min int32, max int32) error {
+	if value < min || value > max {
+		msg := fmt.Sprintf("%s %d is out of range, must be %d to %d", field, value, min, max)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
 func (s *Server) validateCreateNvmeControllerRequest(in *pb.CreateNvmeControllerRequest) error {
+	v := &utils.Validator{}
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
-	}
+	v.CheckRequiredFields(in)
 	// see https://google.aip.dev/133#user-specified-ids
 	if in.NvmeControllerId != "" {
-		if err := resourceid.ValidateUserSettable(in.NvmeControllerId); err != nil {
-			return err
-		}
+		v.Check("nvme_controller_id", resourceid.ValidateUserSettable(in.NvmeControllerId))
 	}
 
-	switch in.NvmeController.Spec.Trtype {
-	case pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE:
-		if _, ok := in.NvmeController.Spec.Endpoint.(*pb.NvmeControllerSpec_PcieId); !ok {
-			return errors.New("invalid endpoint type passed for transport")
+	if spec := in.GetNvmeController().GetSpec(); spec != nil {
+		switch spec.Trtype {
+		case pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE:
+			if _, ok := spec.Endpoint.(*pb.NvmeControllerSpec_PcieId); !ok {
+				v.Check("nvme_controller.spec.endpoint", errors.New("invalid endpoint type passed for transport"))
+			}
+		case pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP:
+			fallthrough
+		case pb.NvmeTransportType_NVME_TRANSPORT_TYPE_RDMA:
+			if _, ok := spec.Endpoint.(*pb.NvmeControllerSpec_FabricsId); !ok {
+				v.Check("nvme_controller.spec.endpoint", errors.New("invalid endpoint type passed for transport"))
+			}
+		default:
+			v.Check("nvme_controller.spec.trtype", fmt.Errorf("not supported transport type: %v", spec.Trtype))
 		}
-	case pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP:
-		fallthrough
-	case pb.NvmeTransportType_NVME_TRANSPORT_TYPE_RDMA:
-		if _, ok := in.NvmeController.Spec.Endpoint.(*pb.NvmeControllerSpec_FabricsId); !ok {
-			return errors.New("invalid endpoint type passed for transport")
+		v.Check("nvme_controller.spec.max_nsq", checkRange("max_nsq", spec.MaxNsq, 0, maxNvmeIoQueues))
+		v.Check("nvme_controller.spec.max_ncq", checkRange("max_ncq", spec.MaxNcq, 0, maxNvmeIoQueues))
+		if spec.MaxNsq != 0 && spec.MaxNcq != 0 && spec.MaxNsq != spec.MaxNcq {
+			msg := fmt.Sprintf("max_ncq %d must match max_nsq %d, queues are created in pairs", spec.MaxNcq, spec.MaxNsq)
+			v.Check("nvme_controller.spec.max_ncq", status.Errorf(codes.InvalidArgument, msg))
+		}
+		v.Check("nvme_controller.spec.sqes", checkRange("sqes", spec.Sqes, 0, maxNvmeQueueEntriesPower))
+		v.Check("nvme_controller.spec.cqes", checkRange("cqes", spec.Cqes, 0, maxNvmeQueueEntriesPower))
+		if spec.Sqes != 0 && spec.Cqes != 0 && spec.Sqes != spec.Cqes {
+			msg := fmt.Sprintf("cqes %d must match sqes %d, queues share depth", spec.Cqes, spec.Sqes)
+			v.Check("nvme_controller.spec.cqes", status.Errorf(codes.InvalidArgument, msg))
 		}
-	default:
-		return fmt.Errorf("not supported transport type: %v", in.NvmeController.Spec.Trtype)
 	}
 
 	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	return resourcename.Validate(in.Parent)
+	if in.Parent != "" {
+		v.Check("parent", resourcename.Validate(in.Parent))
+	}
+	return v.Err()
 }
 
 func (s *Server) validateDeleteNvmeControllerRequest(in *pb.DeleteNvmeControllerRequest) error {
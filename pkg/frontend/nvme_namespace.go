@@ -9,18 +9,19 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"path"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/opiproject/gospdk/spdk"
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 
 	"github.com/google/uuid"
-	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/fieldmask"
-	"go.einride.tech/aip/resourceid"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
@@ -31,50 +32,296 @@ func sortNvmeNamespaces(namespaces []*pb.NvmeNamespace) {
 	})
 }
 
+// withCode replaces codes.Unknown of err, which errors not created by the
+// status package get, by code keeping the message and details. It lets
+// namespace handlers tell malformed requests (InvalidArgument) apart from
+// SPDK failures (Unavailable)
+func withCode(err error, code codes.Code) error {
+	st := status.Convert(err)
+	if st.Code() != codes.Unknown {
+		return err
+	}
+	p := st.Proto()
+	p.Code = int32(code)
+	return status.FromProto(p).Err()
+}
+
+// findNamespaceSubsystem returns subsystem namespaces of which are
+// under subsysName
+func (s *Server) findNamespaceSubsystem(subsysName string) (*pb.NvmeSubsystem, error) {
+	s.mapsMu.RLock()
+	subsys, ok := s.Nvme.Subsystems[subsysName]
+	s.mapsMu.RUnlock()
+	if !ok {
+		err := status.Errorf(codes.NotFound, "unable to find subsystem %s", subsysName)
+		return nil, err
+	}
+	return subsys, nil
+}
+
+// NvmeNamespaceAnaGroupMetadataKey is metadata key carrying ANA group ID of
+// namespace, set by client on create and returned by server in header
+const NvmeNamespaceAnaGroupMetadataKey = "opi-nvme-anagrpid"
+
+// defaultMaxNamespaces is used by SPDK when subsystem max_namespaces is not set
+const defaultMaxNamespaces = 32
+
+// nvmfSubsystemAddNsParams extends spdk.NvmfSubsystemAddNsParams with anagrpid
+// TODO: remove once gospdk supports anagrpid
+type nvmfSubsystemAddNsParams struct {
+	Nqn       string `json:"nqn"`
+	Namespace struct {
+		Nsid     int    `json:"nsid"`
+		BdevName string `json:"bdev_name"`
+		UUID     string `json:"uuid,omitempty"`
+		Anagrpid int32  `json:"anagrpid,omitempty"`
+	} `json:"namespace"`
+}
+
+// anaGroupFromContext returns ANA group requested by client or 0 if omitted.
+// ANA group IDs of a subsystem range from 1 to its max_namespaces
+func anaGroupFromContext(ctx context.Context, subsysName string, subsys *pb.NvmeSubsystem) (int32, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(NvmeNamespaceAnaGroupMetadataKey)
+	if len(values) == 0 {
+		return 0, nil
+	}
+	anaGroup, err := strconv.ParseInt(values[0], 10, 32)
+	if err != nil || anaGroup < 1 {
+		msg := fmt.Sprintf("invalid anagrpid %q", values[0])
+		return 0, status.Errorf(codes.InvalidArgument, msg)
+	}
+	anaGroups := subsys.Spec.MaxNamespaces
+	if anaGroups == 0 {
+		anaGroups = defaultMaxNamespaces
+	}
+	if anaGroup > anaGroups {
+		msg := fmt.Sprintf("anagrpid %d is out of range, subsystem %s has %d ANA group(s)", anaGroup, subsysName, anaGroups)
+		return 0, status.Errorf(codes.InvalidArgument, msg)
+	}
+	return int32(anaGroup), nil
+}
+
+// nvmeNamespaceAnaGroup returns ANA group namespace name was created in
+func (s *Server) nvmeNamespaceAnaGroup(name string) int32 {
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	return s.Nvme.anaGroups[name]
+}
+
+func (s *Server) sendNvmeNamespaceAnaGroup(ctx context.Context, anaGroup int32) {
+	if anaGroup == 0 {
+		return
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(NvmeNamespaceAnaGroupMetadataKey, strconv.Itoa(int(anaGroup)))); err != nil {
+		log.Printf("error: failed to send anagrpid: %v", err)
+	}
+}
+
+// NvmeNamespaceBlockSizeMetadataKey is request metadata key carrying block
+// size the host expects from namespace backing volume
+const NvmeNamespaceBlockSizeMetadataKey = "opi-nvme-expected-block-size"
+
+// NvmeNamespaceBlockSizeWarningTrailerKey is response trailer key carrying
+// warning about backing volume block size not matching the expected one
+const NvmeNamespaceBlockSizeWarningTrailerKey = "opi-nvme-block-size-warning"
+
+// StrictNamespaceBlockSizeFeature is feature flag turning block size
+// mismatch of namespace backing volume from a warning into an error
+const StrictNamespaceBlockSizeFeature = "strict_namespace_block_size"
+
+// spdkNoSuchDeviceError is error SPDK returns for unknown bdev
+const spdkNoSuchDeviceError = "No such device"
+
+// getNamespaceVolumeBdev returns bdev backing volume referenced by namespace
+// or FailedPrecondition if there is no such bdev, so that SPDK is not left
+// half configured by a namespace add failing on unknown volume
+func (s *Server) getNamespaceVolumeBdev(ctx context.Context, name string, volume string) (*bdevGetBdevsResult, error) {
+	params := spdk.BdevGetBdevsParams{
+		Name: volume,
+	}
+	var result []bdevGetBdevsResult
+	err := s.rpc.Call(ctx, "bdev_get_bdevs", &params, &result)
+	if err != nil && !strings.Contains(err.Error(), spdkNoSuchDeviceError) {
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	if err != nil || len(result) == 0 {
+		msg := fmt.Sprintf("volume %s referenced by namespace %s does not exist", volume, name)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	return volumeBdev(result)
+}
+
+// namespaceBlockSizeFromContext returns block size of volume expected by
+// client or 0 if omitted
+func namespaceBlockSizeFromContext(ctx context.Context) (int64, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(NvmeNamespaceBlockSizeMetadataKey)
+	if len(values) == 0 {
+		return 0, nil
+	}
+	expected, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil || expected < 1 {
+		msg := fmt.Sprintf("invalid expected block size %q", values[0])
+		return 0, status.Errorf(codes.InvalidArgument, msg)
+	}
+	return expected, nil
+}
+
+// checkNamespaceBlockSize compares block size of volume with the one
+// provided by client, if any. Mismatch is returned as a warning unless
+// strict mode is enabled
+func checkNamespaceBlockSize(volume string, bdev *bdevGetBdevsResult, expected int64) (string, error) {
+	if expected == 0 || bdev.BlockSize == expected {
+		return "", nil
+	}
+	msg := fmt.Sprintf("volume %s block size %d does not match expected %d", volume, bdev.BlockSize, expected)
+	if utils.FeatureEnabled(StrictNamespaceBlockSizeFeature) {
+		return "", status.Errorf(codes.FailedPrecondition, msg)
+	}
+	return msg, nil
+}
+
+func sendNvmeNamespaceBlockSizeWarning(ctx context.Context, warning string) {
+	if warning == "" {
+		return
+	}
+	if err := grpc.SetTrailer(ctx, metadata.Pairs(NvmeNamespaceBlockSizeWarningTrailerKey, warning)); err != nil {
+		log.Printf("error: failed to send block size warning: %v", err)
+	}
+}
+
+func (s *Server) numberOfNamespacesInSubsystem(subsysID string) int {
+	number := 0
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	for name := range s.Nvme.Namespaces {
+		if utils.GetSubsystemIDFromNvmeName(name) == subsysID {
+			number++
+		}
+	}
+	return number
+}
+
+// findNamespaceByNguidOrUUID looks for a namespace in the subsystem with the
+// same NGUID or UUID, so a retried create with a new id is not duplicated
+func (s *Server) findNamespaceByNguidOrUUID(subsysID string, spec *pb.NvmeNamespaceSpec) *pb.NvmeNamespace {
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	for name, namespace := range s.Nvme.Namespaces {
+		if utils.GetSubsystemIDFromNvmeName(name) != subsysID {
+			continue
+		}
+		if spec.Nguid != "" && strings.EqualFold(namespace.GetSpec().GetNguid(), spec.Nguid) {
+			return namespace
+		}
+		if spec.Uuid != "" && strings.EqualFold(namespace.GetSpec().GetUuid(), spec.Uuid) {
+			return namespace
+		}
+	}
+	return nil
+}
+
 // CreateNvmeNamespace creates an Nvme namespace
 func (s *Server) CreateNvmeNamespace(ctx context.Context, in *pb.CreateNvmeNamespaceRequest) (*pb.NvmeNamespace, error) {
 	// check input correctness
 	if err := s.validateCreateNvmeNamespaceRequest(in); err != nil {
-		return nil, err
+		return nil, withCode(err, codes.InvalidArgument)
 	}
 	// see https://google.aip.dev/133#user-specified-ids
-	resourceID := resourceid.NewSystemGenerated()
+	resourceID, err := s.idempotencyKeys.ResourceID(ctx, "NvmeNamespace", in.NvmeNamespaceId)
+	if err != nil {
+		return nil, err
+	}
 	if in.NvmeNamespaceId != "" {
 		log.Printf("client provided the ID of a resource %v, ignoring the name field %v", in.NvmeNamespaceId, in.NvmeNamespace.Name)
-		resourceID = in.NvmeNamespaceId
 	}
 	in.NvmeNamespace.Name = utils.ResourceIDToNamespaceName(utils.GetSubsystemIDFromNvmeName(in.Parent), resourceID)
+	unlock := s.resourceLocks.Lock(in.NvmeNamespace.Name)
+	defer unlock()
 	// idempotent API when called with same key, should return same object
 	// fetch object from the database
+	s.mapsMu.RLock()
 	namespace, ok := s.Nvme.Namespaces[in.NvmeNamespace.Name]
+	s.mapsMu.RUnlock()
 	if ok {
 		log.Printf("Already existing NvmeNamespace with id %v", in.NvmeNamespace.Name)
+		s.sendNvmeNamespaceAnaGroup(ctx, s.nvmeNamespaceAnaGroup(namespace.Name))
 		return namespace, nil
 	}
 	// not found, so create a new one
+	s.mapsMu.RLock()
 	subsys, ok := s.Nvme.Subsystems[in.Parent]
+	s.mapsMu.RUnlock()
 	if !ok {
-		err := fmt.Errorf("unable to find subsystem %s", in.Parent)
+		msg := fmt.Sprintf("subsystem %s referenced by namespace %s does not exist", in.Parent, in.NvmeNamespace.Name)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	subsysID := utils.GetSubsystemIDFromNvmeName(in.Parent)
+	if namespace := s.findNamespaceByNguidOrUUID(subsysID, in.NvmeNamespace.Spec); namespace != nil {
+		log.Printf("Already existing NvmeNamespace %v with same NGUID/UUID as %v", namespace.Name, in.NvmeNamespace.Name)
+		s.sendNvmeNamespaceAnaGroup(ctx, s.nvmeNamespaceAnaGroup(namespace.Name))
+		return namespace, nil
+	}
+	// 0 means no limit was configured for the subsystem
+	maxNamespaces := int(subsys.Spec.MaxNamespaces)
+	if maxNamespaces > 0 && s.numberOfNamespacesInSubsystem(subsysID) >= maxNamespaces {
+		msg := fmt.Sprintf("subsystem %s is full, max_namespaces %d reached", in.Parent, maxNamespaces)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	anaGroup, err := anaGroupFromContext(ctx, in.Parent, subsys)
+	if err != nil {
+		return nil, err
+	}
+	blockSize, err := namespaceBlockSizeFromContext(ctx)
+	if err != nil {
 		return nil, err
 	}
+	bdev, err := s.getNamespaceVolumeBdev(ctx, in.NvmeNamespace.Name, in.NvmeNamespace.Spec.VolumeNameRef)
+	if err != nil {
+		return nil, withCode(err, codes.Unavailable)
+	}
+	blockSizeWarning, err := checkNamespaceBlockSize(in.NvmeNamespace.Spec.VolumeNameRef, bdev, blockSize)
+	if err != nil {
+		return nil, withCode(err, codes.Unavailable)
+	}
 
-	params := spdk.NvmfSubsystemAddNsParams{
+	// generate UUID if omitted, so that the stored namespace records it
+	in.NvmeNamespace.Spec.Uuid, err = utils.EnsureUUID(in.NvmeNamespace.Spec.Uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	params := nvmfSubsystemAddNsParams{
 		Nqn: subsys.Spec.Nqn,
 	}
 
 	// TODO: using bdev for volume id as a middle end handle for now
 	params.Namespace.Nsid = int(in.NvmeNamespace.Spec.HostNsid)
 	params.Namespace.BdevName = in.NvmeNamespace.Spec.VolumeNameRef
+	params.Namespace.UUID = in.NvmeNamespace.Spec.Uuid
+	params.Namespace.Anagrpid = anaGroup
 
 	var result spdk.NvmfSubsystemAddNsResult
-	err := s.rpc.Call(ctx, "nvmf_subsystem_add_ns", &params, &result)
+	err = s.withSubsystemPaused(ctx, subsys.Spec.Nqn, func() error {
+		if utils.SkipSpdkCallInDryRun(ctx, "nvmf_subsystem_add_ns", &params) {
+			result = spdk.NvmfSubsystemAddNsResult(params.Namespace.Nsid)
+			return nil
+		}
+		err := s.rpc.Call(ctx, "nvmf_subsystem_add_ns", &params, &result)
+		if err != nil {
+			return err
+		}
+		log.Printf("Received from SPDK: %v", result)
+		if result < 0 {
+			msg := fmt.Sprintf("Could not create NS: %s", in.NvmeNamespace.Name)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, err
-	}
-	log.Printf("Received from SPDK: %v", result)
-	if result < 0 {
-		msg := fmt.Sprintf("Could not create NS: %s", in.NvmeNamespace.Name)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+		return nil, withCode(err, codes.Unavailable)
 	}
 
 	response := utils.ProtoClone(in.NvmeNamespace)
@@ -83,7 +330,20 @@ func (s *Server) CreateNvmeNamespace(ctx context.Context, in *pb.CreateNvmeNames
 		OperState: pb.NvmeNamespaceStatus_OPER_STATE_ONLINE,
 	}
 	response.Spec.HostNsid = int32(result)
+	if utils.DryRunRequested(ctx) {
+		return response, nil
+	}
+	s.mapsMu.Lock()
 	s.Nvme.Namespaces[in.NvmeNamespace.Name] = response
+	if anaGroup != 0 {
+		s.Nvme.anaGroups[in.NvmeNamespace.Name] = anaGroup
+	}
+	s.mapsMu.Unlock()
+	s.sendNvmeNamespaceAnaGroup(ctx, anaGroup)
+	if blockSizeWarning != "" {
+		log.Printf("warning: %v", blockSizeWarning)
+	}
+	sendNvmeNamespaceBlockSizeWarning(ctx, blockSizeWarning)
 	return response, nil
 }
 
@@ -91,10 +351,14 @@ func (s *Server) CreateNvmeNamespace(ctx context.Context, in *pb.CreateNvmeNames
 func (s *Server) DeleteNvmeNamespace(ctx context.Context, in *pb.DeleteNvmeNamespaceRequest) (*emptypb.Empty, error) {
 	// check input correctness
 	if err := s.validateDeleteNvmeNamespaceRequest(in); err != nil {
-		return nil, err
+		return nil, withCode(err, codes.InvalidArgument)
 	}
+	unlock := s.resourceLocks.Lock(in.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	namespace, ok := s.Nvme.Namespaces[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			return &emptypb.Empty{}, nil
@@ -102,10 +366,20 @@ func (s *Server) DeleteNvmeNamespace(ctx context.Context, in *pb.DeleteNvmeNames
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
+	// detached namespace was already removed from SPDK
+	if namespaceDetached(namespace) {
+		if utils.DryRunRequested(ctx) {
+			return &emptypb.Empty{}, nil
+		}
+		s.mapsMu.Lock()
+		delete(s.Nvme.Namespaces, namespace.Name)
+		delete(s.Nvme.anaGroups, namespace.Name)
+		s.mapsMu.Unlock()
+		return &emptypb.Empty{}, nil
+	}
 	subsysName := utils.ResourceIDToSubsystemName(utils.GetSubsystemIDFromNvmeName(in.Name))
-	subsys, ok := s.Nvme.Subsystems[subsysName]
-	if !ok {
-		err := fmt.Errorf("unable to find subsystem %s", subsysName)
+	subsys, err := s.findNamespaceSubsystem(subsysName)
+	if err != nil {
 		return nil, err
 	}
 
@@ -114,16 +388,31 @@ func (s *Server) DeleteNvmeNamespace(ctx context.Context, in *pb.DeleteNvmeNames
 		Nsid: int(namespace.Spec.HostNsid),
 	}
 	var result spdk.NvmfSubsystemRemoveNsResult
-	err := s.rpc.Call(ctx, "nvmf_subsystem_remove_ns", &params, &result)
+	err = s.withSubsystemPaused(ctx, subsys.Spec.Nqn, func() error {
+		if utils.SkipSpdkCallInDryRun(ctx, "nvmf_subsystem_remove_ns", &params) {
+			return nil
+		}
+		err := s.rpc.Call(ctx, "nvmf_subsystem_remove_ns", &params, &result)
+		if err != nil {
+			return err
+		}
+		log.Printf("Received from SPDK: %v", result)
+		if !result {
+			msg := fmt.Sprintf("Could not delete NS: %s", in.Name)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, err
+		return nil, withCode(err, codes.Unavailable)
 	}
-	log.Printf("Received from SPDK: %v", result)
-	if !result {
-		msg := fmt.Sprintf("Could not delete NS: %s", in.Name)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+	if utils.DryRunRequested(ctx) {
+		return &emptypb.Empty{}, nil
 	}
+	s.mapsMu.Lock()
 	delete(s.Nvme.Namespaces, namespace.Name)
+	delete(s.Nvme.anaGroups, namespace.Name)
+	s.mapsMu.Unlock()
 	return &emptypb.Empty{}, nil
 }
 
@@ -131,10 +420,14 @@ func (s *Server) DeleteNvmeNamespace(ctx context.Context, in *pb.DeleteNvmeNames
 func (s *Server) UpdateNvmeNamespace(_ context.Context, in *pb.UpdateNvmeNamespaceRequest) (*pb.NvmeNamespace, error) {
 	// check input correctness
 	if err := s.validateUpdateNvmeNamespaceRequest(in); err != nil {
-		return nil, err
+		return nil, withCode(err, codes.InvalidArgument)
 	}
+	unlock := s.resourceLocks.Lock(in.NvmeNamespace.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	namespace, ok := s.Nvme.Namespaces[in.NvmeNamespace.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			log.Printf("TODO: in case of AllowMissing, create a new resource, don;t return error")
@@ -142,7 +435,7 @@ func (s *Server) UpdateNvmeNamespace(_ context.Context, in *pb.UpdateNvmeNamespa
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.NvmeNamespace.Name)
 		return nil, err
 	}
-	resourceID := path.Base(namespace.Name)
+	resourceID := utils.ResourceNameToID(namespace.Name)
 	// update_mask = 2
 	if err := fieldmask.Validate(in.UpdateMask, in.NvmeNamespace); err != nil {
 		return nil, err
@@ -153,16 +446,18 @@ func (s *Server) UpdateNvmeNamespace(_ context.Context, in *pb.UpdateNvmeNamespa
 		State:     pb.NvmeNamespaceStatus_STATE_ENABLED,
 		OperState: pb.NvmeNamespaceStatus_OPER_STATE_ONLINE,
 	}
+	s.mapsMu.Lock()
 	s.Nvme.Namespaces[in.NvmeNamespace.Name] = response
+	s.mapsMu.Unlock()
 
 	return response, nil
 }
 
 // ListNvmeNamespaces lists Nvme namespaces
 func (s *Server) ListNvmeNamespaces(ctx context.Context, in *pb.ListNvmeNamespacesRequest) (*pb.ListNvmeNamespacesResponse, error) {
-	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return nil, err
+	// check input correctness
+	if err := s.validateListNvmeNamespacesRequest(in); err != nil {
+		return nil, withCode(err, codes.InvalidArgument)
 	}
 	// fetch object from the database
 	size, offset, perr := utils.ExtractPagination(in.PageSize, in.PageToken, s.Pagination)
@@ -170,17 +465,16 @@ func (s *Server) ListNvmeNamespaces(ctx context.Context, in *pb.ListNvmeNamespac
 		return nil, perr
 	}
 
-	subsys, ok := s.Nvme.Subsystems[in.Parent]
-	if !ok {
-		err := fmt.Errorf("unable to find subsystem %s", in.Parent)
+	subsys, err := s.findNamespaceSubsystem(in.Parent)
+	if err != nil {
 		return nil, err
 	}
 	nqn := subsys.Spec.Nqn
 
 	var result []spdk.NvmfGetSubsystemsResult
-	err := s.rpc.Call(ctx, "nvmf_get_subsystems", nil, &result)
+	err = s.rpc.Call(ctx, "nvmf_get_subsystems", nil, &result)
 	if err != nil {
-		return nil, err
+		return nil, withCode(err, codes.Unavailable)
 	}
 	log.Printf("Received from SPDK: %v", result)
 	token := ""
@@ -193,7 +487,7 @@ func (s *Server) ListNvmeNamespaces(ctx context.Context, in *pb.ListNvmeNamespac
 			rr.Namespaces, hasMoreElements = utils.LimitPagination(rr.Namespaces, offset, size)
 			if hasMoreElements {
 				token = uuid.New().String()
-				s.Pagination[token] = offset + size
+				s.Pagination.Set(token, offset+size)
 			}
 			for j := range rr.Namespaces {
 				r := &rr.Namespaces[j]
@@ -201,42 +495,47 @@ func (s *Server) ListNvmeNamespaces(ctx context.Context, in *pb.ListNvmeNamespac
 			}
 		}
 	}
-	if len(Blobarray) > 0 {
-		sortNvmeNamespaces(Blobarray)
-		return &pb.ListNvmeNamespacesResponse{NvmeNamespaces: Blobarray, NextPageToken: token}, nil
-	}
-
-	msg := fmt.Sprintf("Could not find any namespaces for NQN: %s", nqn)
-	return nil, status.Errorf(codes.InvalidArgument, msg)
+	Blobarray, token = utils.LimitPaginationBySize(Blobarray, offset, token, s.Pagination)
+	sortNvmeNamespaces(Blobarray)
+	return &pb.ListNvmeNamespacesResponse{NvmeNamespaces: Blobarray, NextPageToken: token}, nil
 }
 
 // GetNvmeNamespace gets an Nvme namespace
 func (s *Server) GetNvmeNamespace(ctx context.Context, in *pb.GetNvmeNamespaceRequest) (*pb.NvmeNamespace, error) {
 	// check input correctness
 	if err := s.validateGetNvmeNamespaceRequest(in); err != nil {
-		return nil, err
+		return nil, withCode(err, codes.InvalidArgument)
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	namespace, ok := s.Nvme.Namespaces[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
+	// detached namespace is not in SPDK anymore
+	if namespaceDetached(namespace) {
+		return &pb.NvmeNamespace{
+			Name:   namespace.Name,
+			Spec:   &pb.NvmeNamespaceSpec{HostNsid: namespace.Spec.HostNsid},
+			Status: utils.ProtoClone(namespace.Status),
+		}, nil
+	}
 	// TODO: do we even query SPDK to confirm if namespace is present?
 	// return namespace, nil
 
 	// fetch subsystems -> namespaces from Server, match the nsid to find the corresponding namespace
 	subsysName := utils.ResourceIDToSubsystemName(utils.GetSubsystemIDFromNvmeName(in.Name))
-	subsys, ok := s.Nvme.Subsystems[subsysName]
-	if !ok {
-		err := fmt.Errorf("unable to find subsystem %s", subsysName)
+	subsys, err := s.findNamespaceSubsystem(subsysName)
+	if err != nil {
 		return nil, err
 	}
 
 	var result []spdk.NvmfGetSubsystemsResult
-	err := s.rpc.Call(ctx, "nvmf_get_subsystems", nil, &result)
+	err = s.rpc.Call(ctx, "nvmf_get_subsystems", nil, &result)
 	if err != nil {
-		return nil, err
+		return nil, withCode(err, codes.Unavailable)
 	}
 	log.Printf("Received from SPDK: %v", result)
 	for i := range result {
@@ -245,6 +544,7 @@ func (s *Server) GetNvmeNamespace(ctx context.Context, in *pb.GetNvmeNamespaceRe
 			for j := range rr.Namespaces {
 				r := &rr.Namespaces[j]
 				if int32(r.Nsid) == namespace.Spec.HostNsid {
+					s.sendNvmeNamespaceAnaGroup(ctx, s.nvmeNamespaceAnaGroup(namespace.Name))
 					return &pb.NvmeNamespace{
 						Name: namespace.Name,
 						Spec: &pb.NvmeNamespaceSpec{HostNsid: namespace.Spec.HostNsid},
@@ -256,26 +556,34 @@ func (s *Server) GetNvmeNamespace(ctx context.Context, in *pb.GetNvmeNamespaceRe
 				}
 			}
 			msg := fmt.Sprintf("Could not find NSID: %d", namespace.Spec.HostNsid)
-			return nil, status.Errorf(codes.InvalidArgument, msg)
+			return nil, status.Errorf(codes.NotFound, msg)
 		}
 	}
 	msg := fmt.Sprintf("Could not find NQN: %s", subsys.Spec.Nqn)
-	return nil, status.Errorf(codes.InvalidArgument, msg)
+	return nil, status.Errorf(codes.NotFound, msg)
 }
 
 // StatsNvmeNamespace gets an Nvme namespace stats
-func (s *Server) StatsNvmeNamespace(_ context.Context, in *pb.StatsNvmeNamespaceRequest) (*pb.StatsNvmeNamespaceResponse, error) {
+func (s *Server) StatsNvmeNamespace(ctx context.Context, in *pb.StatsNvmeNamespaceRequest) (*pb.StatsNvmeNamespaceResponse, error) {
 	// check input correctness
 	if err := s.validateStatsNvmeNamespaceRequest(in); err != nil {
-		return nil, err
+		return nil, withCode(err, codes.InvalidArgument)
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	namespace, ok := s.Nvme.Namespaces[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(namespace.Name)
-	log.Printf("TODO: send name to SPDK and get back stats: %v", resourceID)
-	return &pb.StatsNvmeNamespaceResponse{Stats: &pb.VolumeStats{ReadOpsCount: -1, WriteOpsCount: -1}}, nil
+	stats, err := s.volumesStats(ctx, []string{namespace.Spec.VolumeNameRef})
+	if err != nil {
+		return nil, withCode(err, codes.Unavailable)
+	}
+	stats, err = s.emptyStatsOr(ctx, in.Name, stats)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.StatsNvmeNamespaceResponse{Stats: stats}, nil
 }
@@ -0,0 +1,197 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/opiproject/gospdk/spdk"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NvmeNamespaceAttachmentServiceName is full name of the service hiding
+// Nvme namespaces from hosts and showing them again. It is not part of OPI
+// API, so it is registered with a hand written service descriptor
+const NvmeNamespaceAttachmentServiceName = "opi_spdk_bridge.v1.NvmeNamespaceAttachmentService"
+
+// namespaceDetached tells whether namespace was detached from its subsystem
+func namespaceDetached(namespace *pb.NvmeNamespace) bool {
+	return namespace.GetStatus().GetState() == pb.NvmeNamespaceStatus_STATE_DISABLED
+}
+
+// DetachNvmeNamespace removes namespace from its subsystem, so hosts do not
+// see it anymore, keeping its backing volume and the namespace itself, which
+// is reported disabled until attached again
+func (s *Server) DetachNvmeNamespace(ctx context.Context, in *pb.GetNvmeNamespaceRequest) (*pb.NvmeNamespace, error) {
+	// check input correctness
+	if err := s.validateGetNvmeNamespaceRequest(in); err != nil {
+		return nil, withCode(err, codes.InvalidArgument)
+	}
+	unlock := s.resourceLocks.Lock(in.Name)
+	defer unlock()
+	// fetch object from the database
+	s.mapsMu.RLock()
+	namespace, ok := s.Nvme.Namespaces[in.Name]
+	s.mapsMu.RUnlock()
+	if !ok {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	if namespaceDetached(namespace) {
+		log.Printf("Already detached NvmeNamespace %v", in.Name)
+		return namespace, nil
+	}
+	subsysName := utils.ResourceIDToSubsystemName(utils.GetSubsystemIDFromNvmeName(in.Name))
+	subsys, err := s.findNamespaceSubsystem(subsysName)
+	if err != nil {
+		return nil, err
+	}
+
+	params := spdk.NvmfSubsystemRemoveNsParams{
+		Nqn:  subsys.Spec.Nqn,
+		Nsid: int(namespace.Spec.HostNsid),
+	}
+	var result spdk.NvmfSubsystemRemoveNsResult
+	err = s.withSubsystemPaused(ctx, subsys.Spec.Nqn, func() error {
+		err := s.rpc.Call(ctx, "nvmf_subsystem_remove_ns", &params, &result)
+		if err != nil {
+			return err
+		}
+		log.Printf("Received from SPDK: %v", result)
+		if !result {
+			msg := fmt.Sprintf("Could not detach NS: %s", in.Name)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, withCode(err, codes.Unavailable)
+	}
+
+	response := utils.ProtoClone(namespace)
+	response.Status = &pb.NvmeNamespaceStatus{
+		State:     pb.NvmeNamespaceStatus_STATE_DISABLED,
+		OperState: pb.NvmeNamespaceStatus_OPER_STATE_OFFLINE,
+	}
+	s.mapsMu.Lock()
+	s.Nvme.Namespaces[in.Name] = response
+	s.mapsMu.Unlock()
+	return response, nil
+}
+
+// AttachNvmeNamespace adds detached namespace back to its subsystem with the
+// same NSID, volume and ANA group, so hosts see it again
+func (s *Server) AttachNvmeNamespace(ctx context.Context, in *pb.GetNvmeNamespaceRequest) (*pb.NvmeNamespace, error) {
+	// check input correctness
+	if err := s.validateGetNvmeNamespaceRequest(in); err != nil {
+		return nil, withCode(err, codes.InvalidArgument)
+	}
+	unlock := s.resourceLocks.Lock(in.Name)
+	defer unlock()
+	// fetch object from the database
+	s.mapsMu.RLock()
+	namespace, ok := s.Nvme.Namespaces[in.Name]
+	s.mapsMu.RUnlock()
+	if !ok {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	if !namespaceDetached(namespace) {
+		log.Printf("Already attached NvmeNamespace %v", in.Name)
+		return namespace, nil
+	}
+	subsysName := utils.ResourceIDToSubsystemName(utils.GetSubsystemIDFromNvmeName(in.Name))
+	subsys, err := s.findNamespaceSubsystem(subsysName)
+	if err != nil {
+		return nil, err
+	}
+
+	params := nvmfSubsystemAddNsParams{
+		Nqn: subsys.Spec.Nqn,
+	}
+	params.Namespace.Nsid = int(namespace.Spec.HostNsid)
+	params.Namespace.BdevName = namespace.Spec.VolumeNameRef
+	params.Namespace.Anagrpid = s.nvmeNamespaceAnaGroup(in.Name)
+
+	var result spdk.NvmfSubsystemAddNsResult
+	err = s.withSubsystemPaused(ctx, subsys.Spec.Nqn, func() error {
+		err := s.rpc.Call(ctx, "nvmf_subsystem_add_ns", &params, &result)
+		if err != nil {
+			return err
+		}
+		log.Printf("Received from SPDK: %v", result)
+		if int32(result) != namespace.Spec.HostNsid {
+			msg := fmt.Sprintf("Could not attach NS: %s", in.Name)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, withCode(err, codes.Unavailable)
+	}
+
+	response := utils.ProtoClone(namespace)
+	response.Status = &pb.NvmeNamespaceStatus{
+		State:     pb.NvmeNamespaceStatus_STATE_ENABLED,
+		OperState: pb.NvmeNamespaceStatus_OPER_STATE_ONLINE,
+	}
+	s.mapsMu.Lock()
+	s.Nvme.Namespaces[in.Name] = response
+	s.mapsMu.Unlock()
+	s.sendNvmeNamespaceAnaGroup(ctx, s.nvmeNamespaceAnaGroup(in.Name))
+	return response, nil
+}
+
+// nvmeNamespaceAttachmentServiceServer is implemented by Server
+type nvmeNamespaceAttachmentServiceServer interface {
+	DetachNvmeNamespace(context.Context, *pb.GetNvmeNamespaceRequest) (*pb.NvmeNamespace, error)
+	AttachNvmeNamespace(context.Context, *pb.GetNvmeNamespaceRequest) (*pb.NvmeNamespace, error)
+}
+
+func nvmeNamespaceAttachmentHandler(method string, call func(nvmeNamespaceAttachmentServiceServer, context.Context, *pb.GetNvmeNamespaceRequest) (*pb.NvmeNamespace, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(pb.GetNvmeNamespaceRequest)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(nvmeNamespaceAttachmentServiceServer), ctx, in)
+			}
+			info := &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: "/" + NvmeNamespaceAttachmentServiceName + "/" + method,
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(nvmeNamespaceAttachmentServiceServer), ctx, req.(*pb.GetNvmeNamespaceRequest))
+			}
+			return interceptor(ctx, in, info, handler)
+		},
+	}
+}
+
+var nvmeNamespaceAttachmentServiceDesc = grpc.ServiceDesc{
+	ServiceName: NvmeNamespaceAttachmentServiceName,
+	HandlerType: (*nvmeNamespaceAttachmentServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		nvmeNamespaceAttachmentHandler("DetachNvmeNamespace", nvmeNamespaceAttachmentServiceServer.DetachNvmeNamespace),
+		nvmeNamespaceAttachmentHandler("AttachNvmeNamespace", nvmeNamespaceAttachmentServiceServer.AttachNvmeNamespace),
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterNvmeNamespaceAttachmentServer registers namespace attachment
+// service on s
+func RegisterNvmeNamespaceAttachmentServer(s *grpc.Server, srv *Server) {
+	s.RegisterService(&nvmeNamespaceAttachmentServiceDesc, srv)
+}
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_DetachAttachNvmeNamespace(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":22}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":[{"nqn":"nqn.2022-09.io.spdk:opi3","namespaces":[{"nsid":22}]}]}`,
+	})
+	defer testEnv.Close()
+	testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+	namespace := utils.ProtoClone(&testNamespace)
+	namespace.Name = testNamespaceName
+	namespace.Spec.VolumeNameRef = "Malloc1"
+	testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = namespace
+	request := &pb.GetNvmeNamespaceRequest{Name: testNamespaceName}
+
+	detached, err := testEnv.opiSpdkServer.DetachNvmeNamespace(testEnv.ctx, request)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if detached.Status.State != pb.NvmeNamespaceStatus_STATE_DISABLED ||
+		detached.Status.OperState != pb.NvmeNamespaceStatus_OPER_STATE_OFFLINE {
+		t.Error("status: expected disabled and offline, received", detached.Status)
+	}
+	if detached.Spec.VolumeNameRef != "Malloc1" {
+		t.Error("volume: expected Malloc1 to be kept, received", detached.Spec.VolumeNameRef)
+	}
+
+	// detached namespace is reported without asking SPDK
+	got, err := testEnv.opiSpdkServer.GetNvmeNamespace(testEnv.ctx, request)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if !proto.Equal(got.Status, detached.Status) {
+		t.Error("get status: expected", detached.Status, "received", got.Status)
+	}
+
+	// repeated detach does not call SPDK
+	if _, err := testEnv.opiSpdkServer.DetachNvmeNamespace(testEnv.ctx, request); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	attached, err := testEnv.opiSpdkServer.AttachNvmeNamespace(testEnv.ctx, request)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if attached.Status.State != pb.NvmeNamespaceStatus_STATE_ENABLED ||
+		attached.Status.OperState != pb.NvmeNamespaceStatus_OPER_STATE_ONLINE {
+		t.Error("status: expected enabled and online, received", attached.Status)
+	}
+
+	got, err = testEnv.opiSpdkServer.GetNvmeNamespace(testEnv.ctx, request)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if got.Status.State != pb.NvmeNamespaceStatus_STATE_ENABLED {
+		t.Error("get status: expected enabled, received", got.Status)
+	}
+
+	s := grpc.NewServer()
+	RegisterNvmeNamespaceAttachmentServer(s, testEnv.opiSpdkServer)
+	info, ok := s.GetServiceInfo()[NvmeNamespaceAttachmentServiceName]
+	if !ok {
+		t.Fatal("expected", NvmeNamespaceAttachmentServiceName, "to be registered")
+	}
+	if len(info.Methods) != 2 {
+		t.Error("methods: expected Detach and Attach, received", info.Methods)
+	}
+}
+
+func TestFrontEnd_DetachNvmeNamespace(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		in       string
+		spdk     []string
+		detached bool
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"valid request with valid SPDK response": {
+			in:       testNamespaceName,
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			detached: true,
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"valid request with invalid SPDK response": {
+			in:       testNamespaceName,
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":false}`},
+			detached: false,
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("Could not detach NS: %v", testNamespaceName),
+		},
+		"valid request with error code from SPDK response": {
+			in:       testNamespaceName,
+			spdk:     []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":false}`},
+			detached: false,
+			errCode:  codes.Unavailable,
+			errMsg:   fmt.Sprintf("nvmf_subsystem_remove_ns: %v", "json response error: myopierr"),
+		},
+		"unknown namespace": {
+			in:       utils.ResourceIDToNamespaceName(testSubsystemID, "unknown-namespace-id"),
+			spdk:     []string{},
+			detached: false,
+			errCode:  codes.NotFound,
+			errMsg:   fmt.Sprintf("unable to find key %v", utils.ResourceIDToNamespaceName(testSubsystemID, "unknown-namespace-id")),
+		},
+		"malformed name": {
+			in:       "-ABC-DEF",
+			spdk:     []string{},
+			detached: false,
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+			testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = utils.ProtoClone(&testNamespace)
+			testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName].Name = testNamespaceName
+
+			_, err := testEnv.opiSpdkServer.DetachNvmeNamespace(testEnv.ctx, &pb.GetNvmeNamespaceRequest{Name: tt.in})
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+			if detached := namespaceDetached(testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName]); detached != tt.detached {
+				t.Error("detached: expected", tt.detached, "received", detached)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_AttachNvmeNamespace(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		in       string
+		spdk     []string
+		detached bool
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"valid request with valid SPDK response": {
+			in:       testNamespaceName,
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":22}`},
+			detached: false,
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"valid request with other nsid SPDK response": {
+			in:       testNamespaceName,
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":23}`},
+			detached: true,
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("Could not attach NS: %v", testNamespaceName),
+		},
+		"valid request with error code from SPDK response": {
+			in:       testNamespaceName,
+			spdk:     []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":-1}`},
+			detached: true,
+			errCode:  codes.Unavailable,
+			errMsg:   fmt.Sprintf("nvmf_subsystem_add_ns: %v", "json response error: myopierr"),
+		},
+		"unknown namespace": {
+			in:       utils.ResourceIDToNamespaceName(testSubsystemID, "unknown-namespace-id"),
+			spdk:     []string{},
+			detached: true,
+			errCode:  codes.NotFound,
+			errMsg:   fmt.Sprintf("unable to find key %v", utils.ResourceIDToNamespaceName(testSubsystemID, "unknown-namespace-id")),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+			namespace := utils.ProtoClone(&testNamespace)
+			namespace.Name = testNamespaceName
+			namespace.Status.State = pb.NvmeNamespaceStatus_STATE_DISABLED
+			namespace.Status.OperState = pb.NvmeNamespaceStatus_OPER_STATE_OFFLINE
+			testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = namespace
+
+			_, err := testEnv.opiSpdkServer.AttachNvmeNamespace(testEnv.ctx, &pb.GetNvmeNamespaceRequest{Name: tt.in})
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+			if detached := namespaceDetached(testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName]); detached != tt.detached {
+				t.Error("detached: expected", tt.detached, "received", detached)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_DeleteDetachedNvmeNamespace(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+	namespace := utils.ProtoClone(&testNamespace)
+	namespace.Name = testNamespaceName
+	namespace.Status.State = pb.NvmeNamespaceStatus_STATE_DISABLED
+	testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = namespace
+
+	_, err := testEnv.opiSpdkServer.DeleteNvmeNamespace(testEnv.ctx, &pb.DeleteNvmeNamespaceRequest{Name: testNamespaceName})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if _, ok := testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName]; ok {
+		t.Error("expected detached namespace to be deleted")
+	}
+}
@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// NvmeNamespaceBatchServiceName is full name of the service creating
+// multiple Nvme namespaces in a single call. It is not part of OPI API, so
+// it is registered with a hand written service descriptor
+const NvmeNamespaceBatchServiceName = "opi_spdk_bridge.v1.NvmeNamespaceBatchService"
+
+// nvmeNamespacesBatchRequest is BatchCreateNvmeNamespaces request, items of
+// which are CreateNvmeNamespaceRequest in protobuf JSON format. Parent of
+// items can be omitted, it defaults to parent of the batch
+type nvmeNamespacesBatchRequest struct {
+	Parent   string            `json:"parent"`
+	Requests []json.RawMessage `json:"requests"`
+}
+
+// NvmeNamespaceBatchResult is result of a single item of
+// BatchCreateNvmeNamespaces, in the same order as requested
+type NvmeNamespaceBatchResult struct {
+	Code          string          `json:"code"`
+	Message       string          `json:"message,omitempty"`
+	NvmeNamespace json.RawMessage `json:"nvme_namespace,omitempty"`
+}
+
+// CreateNvmeNamespaces creates namespaces of requests in subsystem parent one
+// by one, the same way CreateNvmeNamespace does, so already existing
+// namespaces are returned as they are. A failed item does not stop the
+// remaining ones, its error is returned in its result instead
+func (s *Server) CreateNvmeNamespaces(ctx context.Context, parent string, requests []*pb.CreateNvmeNamespaceRequest) []*NvmeNamespaceBatchResult {
+	results := make([]*NvmeNamespaceBatchResult, 0, len(requests))
+	for i, request := range requests {
+		if request.Parent == "" {
+			request.Parent = parent
+		}
+		if request.Parent != parent {
+			msg := fmt.Sprintf("request %d parent %s does not match batch parent %s", i, request.Parent, parent)
+			err := status.Errorf(codes.InvalidArgument, msg)
+			results = append(results, newNvmeNamespaceBatchResult(nil, err))
+			continue
+		}
+		namespace, err := s.CreateNvmeNamespace(ctx, request)
+		results = append(results, newNvmeNamespaceBatchResult(namespace, err))
+	}
+	return results
+}
+
+func newNvmeNamespaceBatchResult(namespace *pb.NvmeNamespace, err error) *NvmeNamespaceBatchResult {
+	if err != nil {
+		st := status.Convert(err)
+		log.Printf("error: failed to create namespace in batch: %v", st.Message())
+		return &NvmeNamespaceBatchResult{Code: st.Code().String(), Message: st.Message()}
+	}
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(namespace)
+	if err != nil {
+		return &NvmeNamespaceBatchResult{Code: codes.Internal.String(), Message: err.Error()}
+	}
+	return &NvmeNamespaceBatchResult{Code: codes.OK.String(), NvmeNamespace: data}
+}
+
+// BatchCreateNvmeNamespaces creates multiple namespaces of a subsystem in a
+// single call. Request is a struct with parent subsystem name and requests
+// list of CreateNvmeNamespaceRequest, response is a struct with results list
+// of NvmeNamespaceBatchResult. Items are created directly, so they are not
+// accounted to tenant quotas of NvmeNamespace
+func (s *Server) BatchCreateNvmeNamespaces(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	data, err := in.MarshalJSON()
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	var batch nvmeNamespacesBatchRequest
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	if batch.Parent == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "missing required field: parent")
+	}
+	if err := resourcename.Validate(batch.Parent); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	requests := make([]*pb.CreateNvmeNamespaceRequest, 0, len(batch.Requests))
+	for i, item := range batch.Requests {
+		request := &pb.CreateNvmeNamespaceRequest{}
+		if err := protojson.Unmarshal(item, request); err != nil {
+			msg := fmt.Sprintf("invalid request %d: %v", i, err)
+			return nil, status.Errorf(codes.InvalidArgument, msg)
+		}
+		requests = append(requests, request)
+	}
+
+	results := s.CreateNvmeNamespaces(ctx, batch.Parent, requests)
+	data, err = json.Marshal(map[string]interface{}{"results": results})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	response := &structpb.Struct{}
+	if err := response.UnmarshalJSON(data); err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	return response, nil
+}
+
+// nvmeNamespaceBatchServiceServer is implemented by Server
+type nvmeNamespaceBatchServiceServer interface {
+	BatchCreateNvmeNamespaces(context.Context, *structpb.Struct) (*structpb.Struct, error)
+}
+
+var nvmeNamespaceBatchServiceDesc = grpc.ServiceDesc{
+	ServiceName: NvmeNamespaceBatchServiceName,
+	HandlerType: (*nvmeNamespaceBatchServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "BatchCreateNvmeNamespaces",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(structpb.Struct)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(nvmeNamespaceBatchServiceServer).BatchCreateNvmeNamespaces(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: "/" + NvmeNamespaceBatchServiceName + "/BatchCreateNvmeNamespaces",
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(nvmeNamespaceBatchServiceServer).BatchCreateNvmeNamespaces(ctx, req.(*structpb.Struct))
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterNvmeNamespaceBatchServer registers namespace batch service on s
+func RegisterNvmeNamespaceBatchServer(s *grpc.Server, srv *Server) {
+	s.RegisterService(&nvmeNamespaceBatchServiceDesc, srv)
+}
@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_BatchCreateNvmeNamespaces(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	otherNamespaceName := utils.ResourceIDToNamespaceName(testSubsystemID, "namespace-other")
+	namespaceRequest := func(id string) interface{} {
+		return map[string]interface{}{
+			"nvme_namespace_id": id,
+			"nvme_namespace":    map[string]interface{}{"spec": map[string]interface{}{"volume_name_ref": "Malloc1"}},
+		}
+	}
+
+	tests := map[string]struct {
+		parent   string
+		requests []interface{}
+		spdk     []string
+		codes    []string
+		names    []string
+		nsids    []float64
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"all success": {
+			parent:   testSubsystemName,
+			requests: []interface{}{namespaceRequest(testNamespaceID), namespaceRequest("namespace-other")},
+			spdk: []string{
+				testVolumeBdev,
+				`{"id":%d,"error":{"code":0,"message":""},"result":1}`,
+				testVolumeBdev,
+				`{"id":%d,"error":{"code":0,"message":""},"result":2}`,
+			},
+			codes:   []string{"OK", "OK"},
+			names:   []string{testNamespaceName, otherNamespaceName},
+			nsids:   []float64{1, 2},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"partial failure": {
+			parent:   testSubsystemName,
+			requests: []interface{}{namespaceRequest(testNamespaceID), namespaceRequest("namespace-other")},
+			spdk: []string{
+				testVolumeBdev,
+				`{"id":%d,"error":{"code":0,"message":""},"result":-1}`,
+				testVolumeBdev,
+				`{"id":%d,"error":{"code":0,"message":""},"result":2}`,
+			},
+			codes:   []string{"InvalidArgument", "OK"},
+			names:   []string{"", otherNamespaceName},
+			nsids:   []float64{0, 2},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"already exists": {
+			parent:   testSubsystemName,
+			requests: []interface{}{namespaceRequest("namespace-other"), namespaceRequest("existing")},
+			spdk: []string{
+				testVolumeBdev,
+				`{"id":%d,"error":{"code":0,"message":""},"result":2}`,
+			},
+			codes:   []string{"OK", "OK"},
+			names:   []string{otherNamespaceName, utils.ResourceIDToNamespaceName(testSubsystemID, "existing")},
+			nsids:   []float64{2, 22},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"mismatching parent": {
+			parent: testSubsystemName,
+			requests: []interface{}{
+				map[string]interface{}{
+					"parent":            utils.ResourceIDToSubsystemName("subsystem-other"),
+					"nvme_namespace_id": "namespace-other",
+					"nvme_namespace":    map[string]interface{}{"spec": map[string]interface{}{"volume_name_ref": "Malloc1"}},
+				},
+			},
+			spdk:    []string{},
+			codes:   []string{"InvalidArgument"},
+			names:   []string{""},
+			nsids:   []float64{0},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"empty batch": {
+			parent:   testSubsystemName,
+			requests: []interface{}{},
+			spdk:     []string{},
+			codes:    []string{},
+			names:    []string{},
+			nsids:    []float64{},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"invalid request": {
+			parent:   testSubsystemName,
+			requests: []interface{}{map[string]interface{}{"unknown_field": true}},
+			spdk:     []string{},
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("invalid request 0: %v", protojson.Unmarshal([]byte(`{"unknown_field":true}`), &pb.CreateNvmeNamespaceRequest{})),
+		},
+		"missing parent": {
+			parent:   "",
+			requests: []interface{}{namespaceRequest(testNamespaceID)},
+			spdk:     []string{},
+			errCode:  codes.InvalidArgument,
+			errMsg:   "missing required field: parent",
+		},
+		"malformed parent": {
+			parent:   "-ABC-DEF",
+			requests: []interface{}{namespaceRequest(testNamespaceID)},
+			spdk:     []string{},
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+			existingName := utils.ResourceIDToNamespaceName(testSubsystemID, "existing")
+			testEnv.opiSpdkServer.Nvme.Namespaces[existingName] = utils.ProtoClone(&testNamespace)
+			testEnv.opiSpdkServer.Nvme.Namespaces[existingName].Name = existingName
+
+			in, err := structpb.NewStruct(map[string]interface{}{"parent": tt.parent, "requests": tt.requests})
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+			response, err := testEnv.opiSpdkServer.BatchCreateNvmeNamespaces(testEnv.ctx, in)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+			if err != nil {
+				return
+			}
+
+			resultCodes := []string{}
+			names := []string{}
+			nsids := []float64{}
+			for _, value := range response.GetFields()["results"].GetListValue().GetValues() {
+				result := value.GetStructValue().GetFields()
+				namespace := result["nvme_namespace"].GetStructValue().GetFields()
+				resultCodes = append(resultCodes, result["code"].GetStringValue())
+				names = append(names, namespace["name"].GetStringValue())
+				nsids = append(nsids, namespace["spec"].GetStructValue().GetFields()["host_nsid"].GetNumberValue())
+				if result["code"].GetStringValue() != "OK" && result["message"].GetStringValue() == "" {
+					t.Error("expected failed item to have message, received", result)
+				}
+			}
+			if !reflect.DeepEqual(resultCodes, tt.codes) {
+				t.Error("codes: expected", tt.codes, "received", resultCodes)
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Error("names: expected", tt.names, "received", names)
+			}
+			if !reflect.DeepEqual(nsids, tt.nsids) {
+				t.Error("nsids: expected", tt.nsids, "received", nsids)
+			}
+			for _, name := range tt.names {
+				if _, ok := testEnv.opiSpdkServer.Nvme.Namespaces[name]; name != "" && !ok {
+					t.Error("expected namespace", name, "to be stored")
+				}
+			}
+		})
+	}
+
+	s := grpc.NewServer()
+	RegisterNvmeNamespaceBatchServer(s, &Server{})
+	info, ok := s.GetServiceInfo()[NvmeNamespaceBatchServiceName]
+	if !ok {
+		t.Fatal("expected", NvmeNamespaceBatchServiceName, "to be registered")
+	}
+	if len(info.Methods) != 1 || info.Methods[0].Name != "BatchCreateNvmeNamespaces" {
+		t.Error("methods: expected [BatchCreateNvmeNamespaces], received", info.Methods)
+	}
+}
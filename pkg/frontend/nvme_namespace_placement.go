@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/opiproject/gospdk/spdk"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// NvmeNamespacePlacementServiceName is full name of the service describing
+// Nvme namespaces for external schedulers. It is not part of OPI API, so it
+// is registered with a hand written service descriptor
+const NvmeNamespacePlacementServiceName = "opi_spdk_bridge.v1.NvmeNamespacePlacementService"
+
+// backingTypes maps SPDK bdev product names to volume types
+var backingTypes = map[string]string{
+	"Null disk":   "null",
+	"Malloc disk": "malloc",
+	"AIO disk":    "aio",
+	"NVMe disk":   "nvme",
+	"crypto":      "encrypted",
+}
+
+// bdevRateLimits are QoS limits assigned to a bdev, 0 means no limit
+type bdevRateLimits struct {
+	RwIosPerSec    int `json:"rw_ios_per_sec"`
+	RwMbytesPerSec int `json:"rw_mbytes_per_sec"`
+	RMbytesPerSec  int `json:"r_mbytes_per_sec"`
+	WMbytesPerSec  int `json:"w_mbytes_per_sec"`
+}
+
+// bdevGetBdevsResult extends spdk.BdevGetBdevsResult with product name and
+// assigned rate limits
+// TODO: remove once gospdk provides them
+type bdevGetBdevsResult struct {
+	spdk.BdevGetBdevsResult
+	ProductName        string         `json:"product_name"`
+	AssignedRateLimits bdevRateLimits `json:"assigned_rate_limits"`
+}
+
+// iostatSample is cumulative number of operations of a volume at SPDK tick
+type iostatSample struct {
+	ops   int
+	ticks int64
+}
+
+// NvmeNamespacePlacement contains namespace spec together with backing
+// volume properties and load used as placement hints by schedulers
+type NvmeNamespacePlacement struct {
+	Name        string         `json:"name"`
+	Subsystem   string         `json:"subsystem"`
+	Nqn         string         `json:"nqn"`
+	HostNsid    int32          `json:"host_nsid"`
+	Volume      string         `json:"volume"`
+	BackingType string         `json:"backing_type"`
+	BlockSize   int64          `json:"block_size"`
+	BlocksCount int64          `json:"blocks_count"`
+	Qos         bdevRateLimits `json:"qos"`
+	ReadOps     int            `json:"read_ops"`
+	WriteOps    int            `json:"write_ops"`
+	// Iops is rate since the namespace was described last time or since
+	// SPDK start on first describe
+	Iops float64 `json:"iops"`
+}
+
+// volumeBdev returns the only bdev bdev_get_bdevs reported for a volume
+func volumeBdev(result []bdevGetBdevsResult) (*bdevGetBdevsResult, error) {
+	if len(result) != 1 {
+		msg := fmt.Sprintf("expecting exactly 1 result, got %d", len(result))
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	return &result[0], nil
+}
+
+// volumeIops returns cumulative read and write operations of volume reported
+// by bdev_get_iostat and rate of operations since previous sample of the volume
+func (s *Server) volumeIops(volume string, result *spdk.BdevGetIostatResult) (int, int, float64, error) {
+	if len(result.Bdevs) != 1 {
+		msg := fmt.Sprintf("expecting exactly 1 result, got %d", len(result.Bdevs))
+		return 0, 0, 0, status.Errorf(codes.InvalidArgument, msg)
+	}
+	stats := result.Bdevs[0]
+	current := iostatSample{ops: stats.NumReadOps + stats.NumWriteOps, ticks: result.Ticks}
+
+	s.iostatSamplesMu.Lock()
+	defer s.iostatSamplesMu.Unlock()
+	previous := s.iostatSamples[volume]
+	if previous.ticks > current.ticks || previous.ops > current.ops {
+		// SPDK restarted or volume was recreated
+		previous = iostatSample{}
+	}
+	s.iostatSamples[volume] = current
+	iops := 0.0
+	if ticks := current.ticks - previous.ticks; ticks > 0 {
+		iops = float64(current.ops-previous.ops) * float64(result.TickRate) / float64(ticks)
+	}
+	return stats.NumReadOps, stats.NumWriteOps, iops, nil
+}
+
+// DescribeNvmeNamespacePlacement returns placement hints of an Nvme namespace
+func (s *Server) DescribeNvmeNamespacePlacement(ctx context.Context, in *pb.GetNvmeNamespaceRequest) (*NvmeNamespacePlacement, error) {
+	// check input correctness
+	if err := s.validateGetNvmeNamespaceRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	s.mapsMu.RLock()
+	namespace, ok := s.Nvme.Namespaces[in.Name]
+	s.mapsMu.RUnlock()
+	if !ok {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	subsysName := utils.ResourceIDToSubsystemName(utils.GetSubsystemIDFromNvmeName(in.Name))
+	s.mapsMu.RLock()
+	subsys, ok := s.Nvme.Subsystems[subsysName]
+	s.mapsMu.RUnlock()
+	if !ok {
+		err := fmt.Errorf("unable to find subsystem %s", subsysName)
+		return nil, err
+	}
+	volume := namespace.Spec.VolumeNameRef
+	// bdev and its stats are independent, so fetch them concurrently
+	batch := utils.NewSpdkBatch(ctx, s.rpc)
+	var bdevs []bdevGetBdevsResult
+	batch.Get("bdev_get_bdevs", &spdk.BdevGetBdevsParams{Name: volume}, &bdevs)
+	var iostat spdk.BdevGetIostatResult
+	batch.Get("bdev_get_iostat", &spdk.BdevGetIostatParams{Name: volume}, &iostat)
+	if err := batch.Wait(); err != nil {
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v %v", bdevs, iostat)
+	bdev, err := volumeBdev(bdevs)
+	if err != nil {
+		return nil, err
+	}
+	readOps, writeOps, iops, err := s.volumeIops(volume, &iostat)
+	if err != nil {
+		return nil, err
+	}
+	backingType, ok := backingTypes[bdev.ProductName]
+	if !ok {
+		backingType = bdev.ProductName
+	}
+	return &NvmeNamespacePlacement{
+		Name:        namespace.Name,
+		Subsystem:   subsysName,
+		Nqn:         subsys.Spec.Nqn,
+		HostNsid:    namespace.Spec.HostNsid,
+		Volume:      volume,
+		BackingType: backingType,
+		BlockSize:   bdev.BlockSize,
+		BlocksCount: bdev.NumBlocks,
+		Qos:         bdev.AssignedRateLimits,
+		ReadOps:     readOps,
+		WriteOps:    writeOps,
+		Iops:        iops,
+	}, nil
+}
+
+// DescribeNamespacePlacement returns NvmeNamespacePlacement as a struct
+func (s *Server) DescribeNamespacePlacement(ctx context.Context, in *pb.GetNvmeNamespaceRequest) (*structpb.Struct, error) {
+	placement, err := s.DescribeNvmeNamespacePlacement(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(placement)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	response := &structpb.Struct{}
+	if err := response.UnmarshalJSON(data); err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	return response, nil
+}
+
+// nvmeNamespacePlacementServiceServer is implemented by Server
+type nvmeNamespacePlacementServiceServer interface {
+	DescribeNamespacePlacement(context.Context, *pb.GetNvmeNamespaceRequest) (*structpb.Struct, error)
+}
+
+var nvmeNamespacePlacementServiceDesc = grpc.ServiceDesc{
+	ServiceName: NvmeNamespacePlacementServiceName,
+	HandlerType: (*nvmeNamespacePlacementServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "DescribeNamespacePlacement",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(pb.GetNvmeNamespaceRequest)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(nvmeNamespacePlacementServiceServer).DescribeNamespacePlacement(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: "/" + NvmeNamespacePlacementServiceName + "/DescribeNamespacePlacement",
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(nvmeNamespacePlacementServiceServer).DescribeNamespacePlacement(ctx, req.(*pb.GetNvmeNamespaceRequest))
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterNvmeNamespacePlacementServer registers namespace placement service
+// on s
+func RegisterNvmeNamespacePlacementServer(s *grpc.Server, srv *Server) {
+	s.RegisterService(&nvmeNamespacePlacementServiceDesc, srv)
+}
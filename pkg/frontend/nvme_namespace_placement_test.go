@@ -0,0 +1,291 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/opiproject/gospdk/spdk"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+// spdkMethodResponder answers SPDK calls with responses queued per method,
+// since calls issued concurrently arrive in no particular order. If
+// concurrent is set, no call is answered until that many are in flight
+type spdkMethodResponder struct {
+	spdk.JSONRPC
+	mu         sync.Mutex
+	responses  map[string][]string
+	concurrent int
+	inFlight   int
+	allArrived chan struct{}
+}
+
+func (e *testEnv) respondSpdkByMethod(responses map[string][]string, concurrent int) *spdkMethodResponder {
+	responder := &spdkMethodResponder{
+		JSONRPC:    e.opiSpdkServer.rpc,
+		responses:  responses,
+		concurrent: concurrent,
+		allArrived: make(chan struct{}),
+	}
+	e.opiSpdkServer.rpc = responder
+	return responder
+}
+
+func (r *spdkMethodResponder) Call(_ context.Context, method string, _, result interface{}) error {
+	r.mu.Lock()
+	r.inFlight++
+	if r.inFlight == r.concurrent {
+		close(r.allArrived)
+	}
+	queued := r.responses[method]
+	if len(queued) == 0 {
+		r.mu.Unlock()
+		return fmt.Errorf("%s: unexpected call", method)
+	}
+	data := queued[0]
+	r.responses[method] = queued[1:]
+	r.mu.Unlock()
+
+	if r.concurrent > 0 {
+		select {
+		case <-r.allArrived:
+		case <-time.After(time.Second):
+			return fmt.Errorf("%s: calls were not issued concurrently", method)
+		}
+	}
+	var response spdk.RPCResponse
+	if err := json.Unmarshal([]byte(data), &response); err != nil {
+		return fmt.Errorf("%s: %s", method, err)
+	}
+	if response.Error.Code != 0 {
+		return fmt.Errorf("%s: json response error: %s", method, response.Error.Message)
+	}
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("%s: %s", method, err)
+	}
+	return nil
+}
+
+func TestFrontEnd_DescribeNvmeNamespacePlacement(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	bdev := `{"error":{"code":0,"message":""},"result":[{"name":"Malloc1","product_name":"Malloc disk","block_size":4096,"num_blocks":1024,"assigned_rate_limits":{"rw_ios_per_sec":2000,"rw_mbytes_per_sec":100,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0}}]}`
+	placement := &NvmeNamespacePlacement{
+		Name:        testNamespaceName,
+		Subsystem:   testSubsystemName,
+		Nqn:         testSubsystem.Spec.Nqn,
+		HostNsid:    22,
+		Volume:      "Malloc1",
+		BackingType: "malloc",
+		BlockSize:   4096,
+		BlocksCount: 1024,
+		Qos:         bdevRateLimits{RwIosPerSec: 2000, RwMbytesPerSec: 100},
+		ReadOps:     300,
+		WriteOps:    100,
+		Iops:        200,
+	}
+
+	tests := map[string]struct {
+		in      string
+		spdk    map[string][]string
+		out     *NvmeNamespacePlacement
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in: testNamespaceName,
+			spdk: map[string][]string{
+				"bdev_get_bdevs":  {bdev},
+				"bdev_get_iostat": {`{"error":{"code":0,"message":""},"result":{"tick_rate":1000,"ticks":2000,"bdevs":[{"name":"Malloc1","num_read_ops":300,"num_write_ops":100}]}}`},
+			},
+			out:     placement,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown backing type is reported as product name": {
+			in: testNamespaceName,
+			spdk: map[string][]string{
+				"bdev_get_bdevs":  {`{"error":{"code":0,"message":""},"result":[{"name":"Malloc1","product_name":"Split Disk","block_size":512,"num_blocks":8}]}`},
+				"bdev_get_iostat": {`{"error":{"code":0,"message":""},"result":{"tick_rate":1000,"ticks":0,"bdevs":[{"name":"Malloc1"}]}}`},
+			},
+			out: &NvmeNamespacePlacement{
+				Name:        testNamespaceName,
+				Subsystem:   testSubsystemName,
+				Nqn:         testSubsystem.Spec.Nqn,
+				HostNsid:    22,
+				Volume:      "Malloc1",
+				BackingType: "Split Disk",
+				BlockSize:   512,
+				BlocksCount: 8,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown namespace": {
+			in:      utils.ResourceIDToNamespaceName(testSubsystemID, "unknown-namespace-id"),
+			spdk:    map[string][]string{},
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToNamespaceName(testSubsystemID, "unknown-namespace-id")),
+		},
+		"bdev_get_bdevs error": {
+			in: testNamespaceName,
+			spdk: map[string][]string{
+				"bdev_get_bdevs":  {`{"error":{"code":1,"message":"myopierr"},"result":[]}`},
+				"bdev_get_iostat": {`{"error":{"code":0,"message":""},"result":{"tick_rate":1000,"ticks":2000,"bdevs":[]}}`},
+			},
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("bdev_get_bdevs: %v", "json response error: myopierr"),
+		},
+		"no stats of volume": {
+			in: testNamespaceName,
+			spdk: map[string][]string{
+				"bdev_get_bdevs":  {bdev},
+				"bdev_get_iostat": {`{"error":{"code":0,"message":""},"result":{"tick_rate":1000,"ticks":2000,"bdevs":[]}}`},
+			},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("expecting exactly 1 result, got %d", 0),
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+			testEnv.respondSpdkByMethod(tt.spdk, 0)
+
+			testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+			namespace := utils.ProtoClone(&testNamespace)
+			namespace.Name = testNamespaceName
+			namespace.Spec.VolumeNameRef = "Malloc1"
+			testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = namespace
+
+			response, err := testEnv.opiSpdkServer.DescribeNvmeNamespacePlacement(testEnv.ctx, &pb.GetNvmeNamespaceRequest{Name: tt.in})
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			// SPDK errors are converted to status by gRPC, here it is called directly
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestFrontEnd_DescribeNvmeNamespacePlacementIops(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	bdev := `{"error":{"code":0,"message":""},"result":[{"name":"Malloc1","product_name":"Malloc disk","block_size":512,"num_blocks":64}]}`
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	testEnv.respondSpdkByMethod(map[string][]string{
+		"bdev_get_bdevs": {bdev, bdev},
+		"bdev_get_iostat": {
+			`{"error":{"code":0,"message":""},"result":{"tick_rate":1000,"ticks":10000,"bdevs":[{"name":"Malloc1","num_read_ops":100,"num_write_ops":0}]}}`,
+			`{"error":{"code":0,"message":""},"result":{"tick_rate":1000,"ticks":12000,"bdevs":[{"name":"Malloc1","num_read_ops":1100,"num_write_ops":500}]}}`,
+		},
+	}, 0)
+	testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+	namespace := utils.ProtoClone(&testNamespace)
+	namespace.Name = testNamespaceName
+	namespace.Spec.VolumeNameRef = "Malloc1"
+	testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = namespace
+
+	// first describe reports rate since SPDK start, next one since previous
+	for _, expected := range []float64{10, 750} {
+		placement, err := testEnv.opiSpdkServer.DescribeNvmeNamespacePlacement(testEnv.ctx, &pb.GetNvmeNamespaceRequest{Name: testNamespaceName})
+		if err != nil {
+			t.Fatal("expected no error, received", err)
+		}
+		if placement.Iops != expected {
+			t.Error("iops: expected", expected, "received", placement.Iops)
+		}
+	}
+}
+
+func TestFrontEnd_DescribeNamespacePlacement(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	testEnv.respondSpdkByMethod(map[string][]string{
+		"bdev_get_bdevs":  {`{"error":{"code":0,"message":""},"result":[{"name":"Malloc1","product_name":"AIO disk","block_size":512,"num_blocks":64}]}`},
+		"bdev_get_iostat": {`{"error":{"code":0,"message":""},"result":{"tick_rate":1000,"ticks":0,"bdevs":[{"name":"Malloc1"}]}}`},
+	}, 0)
+	testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+	namespace := utils.ProtoClone(&testNamespace)
+	namespace.Name = testNamespaceName
+	namespace.Spec.VolumeNameRef = "Malloc1"
+	testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = namespace
+
+	response, err := testEnv.opiSpdkServer.DescribeNamespacePlacement(testEnv.ctx, &pb.GetNvmeNamespaceRequest{Name: testNamespaceName})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	fields := response.GetFields()
+	if fields["backing_type"].GetStringValue() != "aio" {
+		t.Error("backing_type: expected aio, received", fields["backing_type"])
+	}
+	if fields["block_size"].GetNumberValue() != 512 {
+		t.Error("block_size: expected 512, received", fields["block_size"])
+	}
+	if _, ok := fields["qos"].GetKind().(*structpb.Value_StructValue); !ok {
+		t.Error("qos: expected struct, received", fields["qos"])
+	}
+
+	s := grpc.NewServer()
+	RegisterNvmeNamespacePlacementServer(s, testEnv.opiSpdkServer)
+	info, ok := s.GetServiceInfo()[NvmeNamespacePlacementServiceName]
+	if !ok {
+		t.Fatal("expected", NvmeNamespacePlacementServiceName, "to be registered")
+	}
+	if len(info.Methods) != 1 || info.Methods[0].Name != "DescribeNamespacePlacement" {
+		t.Error("methods: expected [DescribeNamespacePlacement], received", info.Methods)
+	}
+}
+
+func TestFrontEnd_DescribeNvmeNamespacePlacementConcurrentGets(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	// neither call is answered until both are in flight
+	testEnv.respondSpdkByMethod(map[string][]string{
+		"bdev_get_bdevs":  {`{"error":{"code":0,"message":""},"result":[{"name":"Malloc1","product_name":"Null disk","block_size":512,"num_blocks":64}]}`},
+		"bdev_get_iostat": {`{"error":{"code":0,"message":""},"result":{"tick_rate":1000,"ticks":1000,"bdevs":[{"name":"Malloc1","num_read_ops":7,"num_write_ops":3}]}}`},
+	}, 2)
+	testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+	namespace := utils.ProtoClone(&testNamespace)
+	namespace.Name = testNamespaceName
+	namespace.Spec.VolumeNameRef = "Malloc1"
+	testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = namespace
+
+	placement, err := testEnv.opiSpdkServer.DescribeNvmeNamespacePlacement(testEnv.ctx, &pb.GetNvmeNamespaceRequest{Name: testNamespaceName})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if placement.BackingType != "null" || placement.BlocksCount != 64 {
+		t.Error("bdev: expected null volume of 64 blocks, received", placement.BackingType, placement.BlocksCount)
+	}
+	if placement.ReadOps != 7 || placement.WriteOps != 3 || placement.Iops != 10 {
+		t.Error("stats: expected 7 reads, 3 writes and 10 iops, received", placement.ReadOps, placement.WriteOps, placement.Iops)
+	}
+}
@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// NvmeNamespaceReservationServiceName is full name of the service reporting
+// NVMe reservations of namespaces. It is not part of OPI API, so it is
+// registered with a hand written service descriptor
+const NvmeNamespaceReservationServiceName = "opi_spdk_bridge.v1.NvmeNamespaceReservationService"
+
+// reservationTypes maps NVMe reservation types to names reported to clients
+var reservationTypes = map[int]string{
+	0: "none",
+	1: "write_exclusive",
+	2: "exclusive_access",
+	3: "write_exclusive_registrants_only",
+	4: "exclusive_access_registrants_only",
+	5: "write_exclusive_all_registrants",
+	6: "exclusive_access_all_registrants",
+}
+
+type nvmfNsReservationReportParams struct {
+	Nqn  string `json:"nqn"`
+	Nsid int    `json:"nsid"`
+}
+
+// nvmfNsReservationReportResult follows fields of NVMe Reservation Report
+// data structure
+type nvmfNsReservationReportResult struct {
+	Generation  uint32 `json:"generation"`
+	Rtype       int    `json:"rtype"`
+	Ptpls       bool   `json:"ptpls"`
+	Registrants []struct {
+		Cntlid int    `json:"cntlid"`
+		Rcsts  int    `json:"rcsts"`
+		Hostid string `json:"hostid"`
+		Rkey   uint64 `json:"rkey"`
+	} `json:"registrants"`
+}
+
+// NvmeNamespaceRegistrant is a host registered for reservations of namespace
+type NvmeNamespaceRegistrant struct {
+	HostID       string `json:"host_id"`
+	ControllerID int    `json:"controller_id"`
+	// Key is reported as a string, since 64 bit keys do not fit into JSON
+	// numbers
+	Key    uint64 `json:"key,string"`
+	Holder bool   `json:"holder"`
+}
+
+// NvmeNamespaceReservation is current reservation of an Nvme namespace
+type NvmeNamespaceReservation struct {
+	Name       string `json:"name"`
+	Nqn        string `json:"nqn"`
+	HostNsid   int32  `json:"host_nsid"`
+	Generation uint32 `json:"generation"`
+	// Type is none when namespace is not reserved
+	Type                    string                    `json:"type"`
+	PersistThroughPowerLoss bool                      `json:"persist_through_power_loss"`
+	Registrants             []NvmeNamespaceRegistrant `json:"registrants"`
+}
+
+// DescribeNvmeNamespaceReservation returns current reservation type and
+// registrants of an Nvme namespace as reported by SPDK
+func (s *Server) DescribeNvmeNamespaceReservation(ctx context.Context, in *pb.GetNvmeNamespaceRequest) (*NvmeNamespaceReservation, error) {
+	// check input correctness
+	if err := s.validateGetNvmeNamespaceRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	s.mapsMu.RLock()
+	namespace, ok := s.Nvme.Namespaces[in.Name]
+	s.mapsMu.RUnlock()
+	if !ok {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	subsysName := utils.ResourceIDToSubsystemName(utils.GetSubsystemIDFromNvmeName(in.Name))
+	s.mapsMu.RLock()
+	subsys, ok := s.Nvme.Subsystems[subsysName]
+	s.mapsMu.RUnlock()
+	if !ok {
+		err := fmt.Errorf("unable to find subsystem %s", subsysName)
+		return nil, err
+	}
+	params := nvmfNsReservationReportParams{
+		Nqn:  subsys.Spec.Nqn,
+		Nsid: int(namespace.Spec.HostNsid),
+	}
+	var result nvmfNsReservationReportResult
+	err := s.rpc.Call(ctx, "nvmf_ns_reservation_report", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	reservationType, ok := reservationTypes[result.Rtype]
+	if !ok {
+		msg := fmt.Sprintf("Could not report reservation of NS: %s, unknown reservation type %d", in.Name, result.Rtype)
+		return nil, status.Errorf(codes.Internal, msg)
+	}
+	registrants := make([]NvmeNamespaceRegistrant, 0, len(result.Registrants))
+	for _, r := range result.Registrants {
+		registrants = append(registrants, NvmeNamespaceRegistrant{
+			HostID:       r.Hostid,
+			ControllerID: r.Cntlid,
+			Key:          r.Rkey,
+			// bit 0 of reservation status is set for the holder
+			Holder: r.Rcsts&1 != 0,
+		})
+	}
+	return &NvmeNamespaceReservation{
+		Name:                    namespace.Name,
+		Nqn:                     subsys.Spec.Nqn,
+		HostNsid:                namespace.Spec.HostNsid,
+		Generation:              result.Generation,
+		Type:                    reservationType,
+		PersistThroughPowerLoss: result.Ptpls,
+		Registrants:             registrants,
+	}, nil
+}
+
+// DescribeNamespaceReservation returns NvmeNamespaceReservation as a struct
+func (s *Server) DescribeNamespaceReservation(ctx context.Context, in *pb.GetNvmeNamespaceRequest) (*structpb.Struct, error) {
+	reservation, err := s.DescribeNvmeNamespaceReservation(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(reservation)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	response := &structpb.Struct{}
+	if err := response.UnmarshalJSON(data); err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	return response, nil
+}
+
+// nvmeNamespaceReservationServiceServer is implemented by Server
+type nvmeNamespaceReservationServiceServer interface {
+	DescribeNamespaceReservation(context.Context, *pb.GetNvmeNamespaceRequest) (*structpb.Struct, error)
+}
+
+var nvmeNamespaceReservationServiceDesc = grpc.ServiceDesc{
+	ServiceName: NvmeNamespaceReservationServiceName,
+	HandlerType: (*nvmeNamespaceReservationServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "DescribeNamespaceReservation",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(pb.GetNvmeNamespaceRequest)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(nvmeNamespaceReservationServiceServer).DescribeNamespaceReservation(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: "/" + NvmeNamespaceReservationServiceName + "/DescribeNamespaceReservation",
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(nvmeNamespaceReservationServiceServer).DescribeNamespaceReservation(ctx, req.(*pb.GetNvmeNamespaceRequest))
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterNvmeNamespaceReservationServer registers namespace reservation
+// service on s
+func RegisterNvmeNamespaceReservationServer(s *grpc.Server, srv *Server) {
+	s.RegisterService(&nvmeNamespaceReservationServiceDesc, srv)
+}
@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_DescribeNvmeNamespaceReservation(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		in      string
+		spdk    []string
+		out     *NvmeNamespaceReservation
+		errCode codes.Code
+		errMsg  string
+	}{
+		"no reservation": {
+			in:   testNamespaceName,
+			spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"generation":0,"rtype":0,"ptpls":false,"registrants":[]}}`},
+			out: &NvmeNamespaceReservation{
+				Name:        testNamespaceName,
+				Nqn:         testSubsystem.Spec.Nqn,
+				HostNsid:    22,
+				Type:        "none",
+				Registrants: []NvmeNamespaceRegistrant{},
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"no registrants reported": {
+			in:   testNamespaceName,
+			spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":{}}`},
+			out: &NvmeNamespaceReservation{
+				Name:        testNamespaceName,
+				Nqn:         testSubsystem.Spec.Nqn,
+				HostNsid:    22,
+				Type:        "none",
+				Registrants: []NvmeNamespaceRegistrant{},
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"multiple registrants": {
+			in: testNamespaceName,
+			spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"generation":3,"rtype":5,"ptpls":true,"registrants":[` +
+				`{"cntlid":1,"rcsts":1,"hostid":"8bd6c6b4-2d3a-4f5e-9a1b-2c3d4e5f6a7b","rkey":18446744073709551615},` +
+				`{"cntlid":2,"rcsts":0,"hostid":"0c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f","rkey":42}]}}`},
+			out: &NvmeNamespaceReservation{
+				Name:                    testNamespaceName,
+				Nqn:                     testSubsystem.Spec.Nqn,
+				HostNsid:                22,
+				Generation:              3,
+				Type:                    "write_exclusive_all_registrants",
+				PersistThroughPowerLoss: true,
+				Registrants: []NvmeNamespaceRegistrant{
+					{HostID: "8bd6c6b4-2d3a-4f5e-9a1b-2c3d4e5f6a7b", ControllerID: 1, Key: 18446744073709551615, Holder: true},
+					{HostID: "0c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f", ControllerID: 2, Key: 42, Holder: false},
+				},
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown reservation type": {
+			in:      testNamespaceName,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"generation":1,"rtype":9,"registrants":[]}}`},
+			out:     nil,
+			errCode: codes.Internal,
+			errMsg:  fmt.Sprintf("Could not report reservation of NS: %s, unknown reservation type %d", testNamespaceName, 9),
+		},
+		"valid request with error code from SPDK response": {
+			in:      testNamespaceName,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":null}`},
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("nvmf_ns_reservation_report: %v", "json response error: myopierr"),
+		},
+		"unknown key": {
+			in:      utils.ResourceIDToNamespaceName(testSubsystemID, "unknown-namespace-id"),
+			spdk:    []string{},
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToNamespaceName(testSubsystemID, "unknown-namespace-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			spdk:    []string{},
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+
+			testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+			namespace := utils.ProtoClone(&testNamespace)
+			namespace.Name = testNamespaceName
+			testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = namespace
+
+			response, err := testEnv.opiSpdkServer.DescribeNvmeNamespaceReservation(testEnv.ctx, &pb.GetNvmeNamespaceRequest{Name: tt.in})
+			err = status.Convert(err).Err()
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if len(tt.spdk) > 0 {
+				params := []string{fmt.Sprintf(`{"nqn":"%v","nsid":22}`, testSubsystem.Spec.Nqn)}
+				if !reflect.DeepEqual(recorder.params, params) {
+					t.Error("params: expected", params, "received", recorder.params)
+				}
+			}
+		})
+	}
+}
+
+func TestFrontEnd_DescribeNamespaceReservation(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":{"generation":1,"rtype":1,"registrants":[{"cntlid":1,"rcsts":1,"hostid":"8bd6c6b4-2d3a-4f5e-9a1b-2c3d4e5f6a7b","rkey":18446744073709551615}]}}`,
+	})
+	defer testEnv.Close()
+	testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+	namespace := utils.ProtoClone(&testNamespace)
+	namespace.Name = testNamespaceName
+	testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = namespace
+
+	response, err := testEnv.opiSpdkServer.DescribeNamespaceReservation(testEnv.ctx, &pb.GetNvmeNamespaceRequest{Name: testNamespaceName})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	fields := response.GetFields()
+	if fields["type"].GetStringValue() != "write_exclusive" {
+		t.Error("type: expected write_exclusive, received", fields["type"])
+	}
+	registrants := fields["registrants"].GetListValue().GetValues()
+	if len(registrants) != 1 {
+		t.Fatal("registrants: expected 1, received", registrants)
+	}
+	// keys are strings, so that they are not rounded by JSON numbers
+	if key := registrants[0].GetStructValue().GetFields()["key"].GetStringValue(); key != "18446744073709551615" {
+		t.Error("key: expected 18446744073709551615, received", key)
+	}
+
+	s := grpc.NewServer()
+	RegisterNvmeNamespaceReservationServer(s, testEnv.opiSpdkServer)
+	info, ok := s.GetServiceInfo()[NvmeNamespaceReservationServiceName]
+	if !ok {
+		t.Fatal("expected", NvmeNamespaceReservationServiceName, "to be registered")
+	}
+	if len(info.Methods) != 1 || info.Methods[0].Name != "DescribeNamespaceReservation" {
+		t.Error("methods: expected [DescribeNamespaceReservation], received", info.Methods)
+	}
+}
@@ -8,11 +8,14 @@ package frontend
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"google.golang.org/protobuf/proto"
 
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/fieldmaskpb"
@@ -24,6 +27,7 @@ import (
 var (
 	testNamespaceID   = "namespace-test"
 	testNamespaceName = utils.ResourceIDToNamespaceName(testSubsystemID, testNamespaceID)
+	testVolumeBdev    = `{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"Malloc1","block_size":512,"num_blocks":256}]}`
 	testNamespace     = pb.NvmeNamespace{
 		Spec: &pb.NvmeNamespaceSpec{
 			HostNsid: 22,
@@ -70,7 +74,7 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 			},
 			nil,
 			[]string{},
-			codes.Unknown,
+			codes.InvalidArgument,
 			fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
 			false,
 			testSubsystemName,
@@ -81,7 +85,7 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 				Spec: spec,
 			},
 			nil,
-			[]string{`{"id":%d,"error":{"code":0,"message":""},"result":-1}`},
+			[]string{testVolumeBdev, `{"id":%d,"error":{"code":0,"message":""},"result":-1}`},
 			codes.InvalidArgument,
 			fmt.Sprintf("Could not create NS: %v", testNamespaceName),
 			false,
@@ -93,8 +97,8 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 				Spec: spec,
 			},
 			nil,
-			[]string{""},
-			codes.Unknown,
+			[]string{testVolumeBdev, ""},
+			codes.Unavailable,
 			fmt.Sprintf("nvmf_subsystem_add_ns: %v", "EOF"),
 			false,
 			testSubsystemName,
@@ -105,8 +109,8 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 				Spec: spec,
 			},
 			nil,
-			[]string{`{"id":0,"error":{"code":0,"message":""},"result":-1}`},
-			codes.Unknown,
+			[]string{testVolumeBdev, `{"id":0,"error":{"code":0,"message":""},"result":-1}`},
+			codes.Unavailable,
 			fmt.Sprintf("nvmf_subsystem_add_ns: %v", "json response ID mismatch"),
 			false,
 			testSubsystemName,
@@ -117,8 +121,8 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 				Spec: spec,
 			},
 			nil,
-			[]string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":-1}`},
-			codes.Unknown,
+			[]string{testVolumeBdev, `{"id":%d,"error":{"code":1,"message":"myopierr"},"result":-1}`},
+			codes.Unavailable,
 			fmt.Sprintf("nvmf_subsystem_add_ns: %v", "json response error: myopierr"),
 			false,
 			testSubsystemName,
@@ -135,12 +139,49 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 					OperState: pb.NvmeNamespaceStatus_OPER_STATE_ONLINE,
 				},
 			},
-			[]string{`{"id":%d,"error":{"code":0,"message":""},"result":22}`},
+			[]string{testVolumeBdev, `{"id":%d,"error":{"code":0,"message":""},"result":22}`},
 			codes.OK,
 			"",
 			false,
 			testSubsystemName,
 		},
+		"missing subsystem": {
+			testNamespaceID,
+			&pb.NvmeNamespace{
+				Spec: spec,
+			},
+			nil,
+			[]string{},
+			codes.FailedPrecondition,
+			fmt.Sprintf("subsystem %v referenced by namespace %v does not exist",
+				utils.ResourceIDToSubsystemName("subsystem-missing"), utils.ResourceIDToNamespaceName("subsystem-missing", testNamespaceID)),
+			false,
+			utils.ResourceIDToSubsystemName("subsystem-missing"),
+		},
+		"missing volume": {
+			testNamespaceID,
+			&pb.NvmeNamespace{
+				Spec: spec,
+			},
+			nil,
+			[]string{`{"id":%d,"error":{"code":-19,"message":"No such device"},"result":null}`},
+			codes.FailedPrecondition,
+			fmt.Sprintf("volume %v referenced by namespace %v does not exist", "Malloc1", testNamespaceName),
+			false,
+			testSubsystemName,
+		},
+		"volume lookup failure": {
+			testNamespaceID,
+			&pb.NvmeNamespace{
+				Spec: spec,
+			},
+			nil,
+			[]string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":null}`},
+			codes.Unavailable,
+			fmt.Sprintf("bdev_get_bdevs: %v", "json response error: myopierr"),
+			false,
+			testSubsystemName,
+		},
 		"already exists": {
 			testNamespaceID,
 			&pb.NvmeNamespace{
@@ -162,7 +203,7 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 			},
 			nil,
 			[]string{},
-			codes.Unknown,
+			codes.InvalidArgument,
 			fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 			false,
 			"-ABC-DEF",
@@ -176,7 +217,7 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 			},
 			nil,
 			[]string{},
-			codes.Unknown,
+			codes.InvalidArgument,
 			fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 			false,
 			testSubsystemName,
@@ -186,7 +227,7 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 			nil,
 			nil,
 			[]string{},
-			codes.Unknown,
+			codes.InvalidArgument,
 			"missing required field: nvme_namespace",
 			false,
 			testSubsystemName,
@@ -200,7 +241,7 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 			},
 			nil,
 			[]string{},
-			codes.Unknown,
+			codes.InvalidArgument,
 			"missing required field: parent",
 			false,
 			"",
@@ -212,7 +253,7 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 			},
 			nil,
 			[]string{},
-			codes.Unknown,
+			codes.InvalidArgument,
 			"missing required field: nvme_namespace.spec.volume_name_ref",
 			false,
 			testSubsystemName,
@@ -257,6 +298,362 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 	}
 }
 
+func TestFrontEnd_CreateNvmeNamespaceMaxNamespaces(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	spec := &pb.NvmeNamespaceSpec{
+		HostNsid:      22,
+		VolumeNameRef: "Malloc1",
+	}
+	t.Cleanup(utils.CheckTestProtoObjectsNotChanged(spec)(t, t.Name()))
+	otherSubsystemNamespaceName := utils.ResourceIDToNamespaceName("other-subsystem", "namespace-other")
+
+	tests := map[string]struct {
+		maxNamespaces int64
+		existing      []string
+		spdk          []string
+		errCode       codes.Code
+		errMsg        string
+	}{
+		"no limit configured": {
+			maxNamespaces: 0,
+			existing:      []string{utils.ResourceIDToNamespaceName(testSubsystemID, "namespace-1")},
+			spdk:          []string{testVolumeBdev, `{"id":%d,"error":{"code":0,"message":""},"result":22}`},
+			errCode:       codes.OK,
+			errMsg:        "",
+		},
+		"under limit": {
+			maxNamespaces: 2,
+			existing: []string{
+				utils.ResourceIDToNamespaceName(testSubsystemID, "namespace-1"),
+				otherSubsystemNamespaceName,
+			},
+			spdk:    []string{testVolumeBdev, `{"id":%d,"error":{"code":0,"message":""},"result":22}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"at limit": {
+			maxNamespaces: 2,
+			existing: []string{
+				utils.ResourceIDToNamespaceName(testSubsystemID, "namespace-1"),
+				utils.ResourceIDToNamespaceName(testSubsystemID, "namespace-2"),
+			},
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("subsystem %v is full, max_namespaces %v reached", testSubsystemName, 2),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			subsystem := utils.ProtoClone(&testSubsystem)
+			subsystem.Spec.MaxNamespaces = tt.maxNamespaces
+			testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = subsystem
+			for _, namespaceName := range tt.existing {
+				testEnv.opiSpdkServer.Nvme.Namespaces[namespaceName] = utils.ProtoClone(&testNamespace)
+				testEnv.opiSpdkServer.Nvme.Namespaces[namespaceName].Name = namespaceName
+			}
+
+			request := &pb.CreateNvmeNamespaceRequest{Parent: testSubsystemName, NvmeNamespace: &pb.NvmeNamespace{Spec: spec}, NvmeNamespaceId: testNamespaceID}
+			_, err := testEnv.client.CreateNvmeNamespace(testEnv.ctx, request)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+		})
+	}
+}
+
+func TestFrontEnd_CreateNvmeNamespaceAnaGroup(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	spec := &pb.NvmeNamespaceSpec{
+		HostNsid:      22,
+		VolumeNameRef: "Malloc1",
+		Uuid:          "1b4e28ba-2fa1-11d2-883f-b9a761bde3fb",
+	}
+	t.Cleanup(utils.CheckTestProtoObjectsNotChanged(spec)(t, t.Name()))
+
+	tests := map[string]struct {
+		anaGroup      string
+		maxNamespaces int64
+		spdk          []string
+		params        []string
+		errCode       codes.Code
+		errMsg        string
+	}{
+		"anagrpid omitted": {
+			anaGroup:      "",
+			maxNamespaces: 0,
+			spdk:          []string{testVolumeBdev, `{"id":%d,"error":{"code":0,"message":""},"result":22}`},
+			params:        []string{`{"name":"Malloc1"}`, `{"nqn":"nqn.2022-09.io.spdk:opi3","namespace":{"nsid":22,"bdev_name":"Malloc1","uuid":"1b4e28ba-2fa1-11d2-883f-b9a761bde3fb"}}`},
+			errCode:       codes.OK,
+			errMsg:        "",
+		},
+		"anagrpid within max_namespaces": {
+			anaGroup:      "4",
+			maxNamespaces: 4,
+			spdk:          []string{testVolumeBdev, `{"id":%d,"error":{"code":0,"message":""},"result":22}`},
+			params:        []string{`{"name":"Malloc1"}`, `{"nqn":"nqn.2022-09.io.spdk:opi3","namespace":{"nsid":22,"bdev_name":"Malloc1","uuid":"1b4e28ba-2fa1-11d2-883f-b9a761bde3fb","anagrpid":4}}`},
+			errCode:       codes.OK,
+			errMsg:        "",
+		},
+		"anagrpid within default max_namespaces": {
+			anaGroup:      "32",
+			maxNamespaces: 0,
+			spdk:          []string{testVolumeBdev, `{"id":%d,"error":{"code":0,"message":""},"result":22}`},
+			params:        []string{`{"name":"Malloc1"}`, `{"nqn":"nqn.2022-09.io.spdk:opi3","namespace":{"nsid":22,"bdev_name":"Malloc1","uuid":"1b4e28ba-2fa1-11d2-883f-b9a761bde3fb","anagrpid":32}}`},
+			errCode:       codes.OK,
+			errMsg:        "",
+		},
+		"anagrpid beyond max_namespaces": {
+			anaGroup:      "5",
+			maxNamespaces: 4,
+			spdk:          []string{},
+			params:        nil,
+			errCode:       codes.InvalidArgument,
+			errMsg:        fmt.Sprintf("anagrpid %v is out of range, subsystem %v has %v ANA group(s)", 5, testSubsystemName, 4),
+		},
+		"zero anagrpid": {
+			anaGroup:      "0",
+			maxNamespaces: 4,
+			spdk:          []string{},
+			params:        nil,
+			errCode:       codes.InvalidArgument,
+			errMsg:        fmt.Sprintf("invalid anagrpid %q", "0"),
+		},
+		"non numeric anagrpid": {
+			anaGroup:      "group-1",
+			maxNamespaces: 4,
+			spdk:          []string{},
+			params:        nil,
+			errCode:       codes.InvalidArgument,
+			errMsg:        fmt.Sprintf("invalid anagrpid %q", "group-1"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+
+			subsystem := utils.ProtoClone(&testSubsystem)
+			subsystem.Spec.MaxNamespaces = tt.maxNamespaces
+			testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = subsystem
+
+			ctx := testEnv.ctx
+			if tt.anaGroup != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, NvmeNamespaceAnaGroupMetadataKey, tt.anaGroup)
+			}
+			var header metadata.MD
+			request := &pb.CreateNvmeNamespaceRequest{Parent: testSubsystemName, NvmeNamespace: &pb.NvmeNamespace{Spec: spec}, NvmeNamespaceId: testNamespaceID}
+			_, err := testEnv.client.CreateNvmeNamespace(ctx, request, grpc.Header(&header))
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if !reflect.DeepEqual(recorder.params, tt.params) {
+				t.Error("params: expected", tt.params, "received", recorder.params)
+			}
+
+			var wantHeader []string
+			if tt.errCode == codes.OK && tt.anaGroup != "" {
+				wantHeader = []string{tt.anaGroup}
+			}
+			if values := header.Get(NvmeNamespaceAnaGroupMetadataKey); !reflect.DeepEqual(values, wantHeader) {
+				t.Error("header anagrpid: expected", wantHeader, "received", values)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_CreateNvmeNamespaceUUID(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		uuid    string
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"provided uuid is kept": {
+			uuid:    "1b4e28ba-2fa1-11d2-883f-b9a761bde3fb",
+			spdk:    []string{testVolumeBdev, `{"id":%d,"error":{"code":0,"message":""},"result":22}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"omitted uuid is generated": {
+			uuid:    "",
+			spdk:    []string{testVolumeBdev, `{"id":%d,"error":{"code":0,"message":""},"result":22}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"invalid uuid": {
+			uuid:    "not-a-uuid",
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("invalid uuid %q: %v", "not-a-uuid", "invalid UUID length: 10"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+			testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+
+			spec := &pb.NvmeNamespaceSpec{HostNsid: 22, VolumeNameRef: "Malloc1", Uuid: tt.uuid}
+			request := &pb.CreateNvmeNamespaceRequest{Parent: testSubsystemName, NvmeNamespace: &pb.NvmeNamespace{Spec: spec}, NvmeNamespaceId: testNamespaceID}
+			response, err := testEnv.client.CreateNvmeNamespace(testEnv.ctx, request)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if tt.errCode != codes.OK {
+				if len(recorder.params) != 0 {
+					t.Error("expected no SPDK calls, received", recorder.params)
+				}
+				return
+			}
+			uuid := response.GetSpec().GetUuid()
+			if tt.uuid != "" && uuid != tt.uuid {
+				t.Error("uuid: expected", tt.uuid, "received", uuid)
+			}
+			if uuid == "" || utils.ValidateUUID(uuid) != nil {
+				t.Error("expected generated uuid, received", uuid)
+			}
+			if stored := testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName]; stored.GetSpec().GetUuid() != uuid {
+				t.Error("stored uuid: expected", uuid, "received", stored.GetSpec().GetUuid())
+			}
+			if len(recorder.params) != 2 || !strings.Contains(recorder.params[1], `"uuid":"`+uuid+`"`) {
+				t.Error("expected uuid passed to SPDK, received", recorder.params)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_CreateNvmeNamespaceIdempotentByNguid(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	const nguid = "1b4e28ba-2fa1-11d2-883f-b9a761bde3fb"
+	const uuid = "3d6a4adc-4ac3-33f4-aa50-dbc983df05ad"
+	existingName := utils.ResourceIDToNamespaceName(testSubsystemID, "namespace-existing")
+	existing := &pb.NvmeNamespace{
+		Name: existingName,
+		Spec: &pb.NvmeNamespaceSpec{
+			HostNsid:      22,
+			VolumeNameRef: "Malloc1",
+			Nguid:         nguid,
+			Uuid:          uuid,
+		},
+		Status: &pb.NvmeNamespaceStatus{
+			State:     pb.NvmeNamespaceStatus_STATE_ENABLED,
+			OperState: pb.NvmeNamespaceStatus_OPER_STATE_ONLINE,
+		},
+	}
+	t.Cleanup(utils.CheckTestProtoObjectsNotChanged(existing)(t, t.Name()))
+
+	tests := map[string]struct {
+		existingSubsys string
+		spec           *pb.NvmeNamespaceSpec
+		spdk           []string
+		outName        string
+		outNsid        int32
+	}{
+		"duplicate nguid returns existing namespace": {
+			existingSubsys: testSubsystemID,
+			spec:           &pb.NvmeNamespaceSpec{VolumeNameRef: "Malloc1", Nguid: nguid},
+			spdk:           []string{},
+			outName:        existingName,
+			outNsid:        22,
+		},
+		"duplicate uuid returns existing namespace": {
+			existingSubsys: testSubsystemID,
+			spec:           &pb.NvmeNamespaceSpec{VolumeNameRef: "Malloc1", Uuid: uuid},
+			spdk:           []string{},
+			outName:        existingName,
+			outNsid:        22,
+		},
+		"duplicate nguid in different case returns existing namespace": {
+			existingSubsys: testSubsystemID,
+			spec:           &pb.NvmeNamespaceSpec{VolumeNameRef: "Malloc1", Nguid: strings.ToUpper(nguid)},
+			spdk:           []string{},
+			outName:        existingName,
+			outNsid:        22,
+		},
+		"distinct nguid creates new namespace": {
+			existingSubsys: testSubsystemID,
+			spec:           &pb.NvmeNamespaceSpec{VolumeNameRef: "Malloc1", Nguid: "2c5f39cb-3fb2-22e3-994f-cab872cef4fc"},
+			spdk:           []string{testVolumeBdev, `{"id":%d,"error":{"code":0,"message":""},"result":23}`},
+			outName:        testNamespaceName,
+			outNsid:        23,
+		},
+		"duplicate nguid in other subsystem creates new namespace": {
+			existingSubsys: "subsystem-other",
+			spec:           &pb.NvmeNamespaceSpec{VolumeNameRef: "Malloc1", Nguid: nguid},
+			spdk:           []string{testVolumeBdev, `{"id":%d,"error":{"code":0,"message":""},"result":23}`},
+			outName:        testNamespaceName,
+			outNsid:        23,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+			stored := utils.ProtoClone(existing)
+			stored.Name = utils.ResourceIDToNamespaceName(tt.existingSubsys, "namespace-existing")
+			testEnv.opiSpdkServer.Nvme.Namespaces[stored.Name] = stored
+
+			request := &pb.CreateNvmeNamespaceRequest{Parent: testSubsystemName, NvmeNamespace: &pb.NvmeNamespace{Spec: tt.spec}, NvmeNamespaceId: testNamespaceID}
+			response, err := testEnv.client.CreateNvmeNamespace(testEnv.ctx, request)
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+
+			if response.Name != tt.outName {
+				t.Error("name: expected", tt.outName, "received", response.Name)
+			}
+			if response.Spec.HostNsid != tt.outNsid {
+				t.Error("host nsid: expected", tt.outNsid, "received", response.Spec.HostNsid)
+			}
+			wantNamespaces := 1
+			if tt.outName != existingName || tt.existingSubsys != testSubsystemID {
+				wantNamespaces = 2
+			}
+			if len(testEnv.opiSpdkServer.Nvme.Namespaces) != wantNamespaces {
+				t.Error("number of namespaces: expected", wantNamespaces, "received", len(testEnv.opiSpdkServer.Nvme.Namespaces))
+			}
+		})
+	}
+}
+
 func TestFrontEnd_DeleteNvmeNamespace(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
 	tests := map[string]struct {
@@ -279,7 +676,7 @@ func TestFrontEnd_DeleteNvmeNamespace(t *testing.T) {
 			testNamespaceName,
 			nil,
 			[]string{""},
-			codes.Unknown,
+			codes.Unavailable,
 			fmt.Sprintf("nvmf_subsystem_remove_ns: %v", "EOF"),
 			false,
 		},
@@ -287,7 +684,7 @@ func TestFrontEnd_DeleteNvmeNamespace(t *testing.T) {
 			testNamespaceName,
 			nil,
 			[]string{`{"id":0,"error":{"code":0,"message":""},"result":false}`},
-			codes.Unknown,
+			codes.Unavailable,
 			fmt.Sprintf("nvmf_subsystem_remove_ns: %v", "json response ID mismatch"),
 			false,
 		},
@@ -295,7 +692,7 @@ func TestFrontEnd_DeleteNvmeNamespace(t *testing.T) {
 			testNamespaceName,
 			nil,
 			[]string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":false}`},
-			codes.Unknown,
+			codes.Unavailable,
 			fmt.Sprintf("nvmf_subsystem_remove_ns: %v", "json response error: myopierr"),
 			false,
 		},
@@ -327,7 +724,7 @@ func TestFrontEnd_DeleteNvmeNamespace(t *testing.T) {
 			"-ABC-DEF",
 			&emptypb.Empty{},
 			[]string{},
-			codes.Unknown,
+			codes.InvalidArgument,
 			fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 			false,
 		},
@@ -335,7 +732,7 @@ func TestFrontEnd_DeleteNvmeNamespace(t *testing.T) {
 			"",
 			&emptypb.Empty{},
 			[]string{},
-			codes.Unknown,
+			codes.InvalidArgument,
 			"missing required field: name",
 			false,
 		},
@@ -451,7 +848,7 @@ func TestFrontEnd_UpdateNvmeNamespace(t *testing.T) {
 			&pb.NvmeNamespace{Name: "-ABC-DEF", Spec: spec},
 			nil,
 			[]string{},
-			codes.Unknown,
+			codes.InvalidArgument,
 			fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 			false,
 		},
@@ -465,7 +862,7 @@ func TestFrontEnd_UpdateNvmeNamespace(t *testing.T) {
 			},
 			nil,
 			[]string{},
-			codes.Unknown,
+			codes.InvalidArgument,
 			fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 			false,
 		},
@@ -531,12 +928,23 @@ func TestFrontEnd_ListNvmeNamespaces(t *testing.T) {
 		size    int32
 		token   string
 	}{
-		"valid request with invalid SPDK response": {
+		"valid request with empty result SPDK response": {
 			testSubsystemName,
 			nil,
 			[]string{`{"id":%d,"error":{"code":0,"message":""},"result":[]}`},
-			codes.InvalidArgument,
-			fmt.Sprintf("Could not find any namespaces for NQN: %v", "nqn.2022-09.io.spdk:opi3"),
+			codes.OK,
+			"",
+			0,
+			"",
+		},
+		"valid request with subsystem without namespaces": {
+			testSubsystemName,
+			nil,
+			[]string{`{"jsonrpc":"2.0","id":%d,"result":[` +
+				`{"nqn":"nqn.2022-09.io.spdk:opi3","subtype":"Nvme","listen_addresses":[],"allow_any_host":false,"hosts":[],"serial_number":"SPDK00000000000001","model_number":"SPDK_Controller1","max_namespaces":32,"min_cntlid":1,"max_cntlid":65519,"namespaces":[]}` +
+				`]}`},
+			codes.OK,
+			"",
 			0,
 			"",
 		},
@@ -544,7 +952,7 @@ func TestFrontEnd_ListNvmeNamespaces(t *testing.T) {
 			testSubsystemName,
 			nil,
 			[]string{`{"id":%d,"error":{"code":0,"message":""},"result":false}`},
-			codes.Unknown,
+			codes.Unavailable,
 			fmt.Sprintf("nvmf_get_subsystems: %v", "json: cannot unmarshal bool into Go value of type []spdk.NvmfGetSubsystemsResult"),
 			0,
 			"",
@@ -553,7 +961,7 @@ func TestFrontEnd_ListNvmeNamespaces(t *testing.T) {
 			testSubsystemName,
 			nil,
 			[]string{""},
-			codes.Unknown,
+			codes.Unavailable,
 			fmt.Sprintf("nvmf_get_subsystems: %v", "EOF"),
 			0,
 			"",
@@ -562,7 +970,7 @@ func TestFrontEnd_ListNvmeNamespaces(t *testing.T) {
 			testSubsystemName,
 			nil,
 			[]string{`{"id":0,"error":{"code":0,"message":""},"result":[]}`},
-			codes.Unknown,
+			codes.Unavailable,
 			fmt.Sprintf("nvmf_get_subsystems: %v", "json response ID mismatch"),
 			0,
 			"",
@@ -571,7 +979,7 @@ func TestFrontEnd_ListNvmeNamespaces(t *testing.T) {
 			testSubsystemName,
 			nil,
 			[]string{`{"id":%d,"error":{"code":1,"message":"myopierr"}}`},
-			codes.Unknown,
+			codes.Unavailable,
 			fmt.Sprintf("nvmf_get_subsystems: %v", "json response error: myopierr"),
 			0,
 			"",
@@ -652,7 +1060,7 @@ func TestFrontEnd_ListNvmeNamespaces(t *testing.T) {
 			"unknown-namespace-id",
 			nil,
 			[]string{},
-			codes.Unknown,
+			codes.NotFound,
 			fmt.Sprintf("unable to find subsystem %v", "unknown-namespace-id"),
 			0,
 			"",
@@ -661,7 +1069,7 @@ func TestFrontEnd_ListNvmeNamespaces(t *testing.T) {
 			"",
 			[]*pb.NvmeNamespace{},
 			[]string{},
-			codes.Unknown,
+			codes.InvalidArgument,
 			"missing required field: parent",
 			0,
 			"",
@@ -678,7 +1086,7 @@ func TestFrontEnd_ListNvmeNamespaces(t *testing.T) {
 			testEnv.opiSpdkServer.Nvme.Namespaces[utils.ResourceIDToVolumeName("ns0")] = utils.ProtoClone(&testNamespaces[0])
 			testEnv.opiSpdkServer.Nvme.Namespaces[utils.ResourceIDToVolumeName("ns1")] = utils.ProtoClone(&testNamespaces[1])
 			testEnv.opiSpdkServer.Nvme.Namespaces[utils.ResourceIDToVolumeName("ns2")] = utils.ProtoClone(&testNamespaces[2])
-			testEnv.opiSpdkServer.Pagination["existing-pagination-token"] = 1
+			testEnv.opiSpdkServer.Pagination.Set("existing-pagination-token", 1)
 
 			request := &pb.ListNvmeNamespacesRequest{Parent: tt.in, PageSize: tt.size, PageToken: tt.token}
 			response, err := testEnv.client.ListNvmeNamespaces(testEnv.ctx, request)
@@ -719,35 +1127,35 @@ func TestFrontEnd_GetNvmeNamespace(t *testing.T) {
 			testNamespaceName,
 			nil,
 			[]string{`{"id":%d,"error":{"code":0,"message":""},"result":[]}`},
-			codes.InvalidArgument,
+			codes.NotFound,
 			fmt.Sprintf("Could not find NQN: %v", "nqn.2022-09.io.spdk:opi3"),
 		},
 		"valid request with invalid marshal SPDK response": {
 			testNamespaceName,
 			nil,
 			[]string{`{"id":%d,"error":{"code":0,"message":""},"result":false}`},
-			codes.Unknown,
+			codes.Unavailable,
 			fmt.Sprintf("nvmf_get_subsystems: %v", "json: cannot unmarshal bool into Go value of type []spdk.NvmfGetSubsystemsResult"),
 		},
 		"valid request with empty SPDK response": {
 			testNamespaceName,
 			nil,
 			[]string{""},
-			codes.Unknown,
+			codes.Unavailable,
 			fmt.Sprintf("nvmf_get_subsystems: %v", "EOF"),
 		},
 		"valid request with ID mismatch SPDK response": {
 			testNamespaceName,
 			nil,
 			[]string{`{"id":0,"error":{"code":0,"message":""},"result":[]}`},
-			codes.Unknown,
+			codes.Unavailable,
 			fmt.Sprintf("nvmf_get_subsystems: %v", "json response ID mismatch"),
 		},
 		"valid request with error code from SPDK response": {
 			testNamespaceName,
 			nil,
 			[]string{`{"id":%d,"error":{"code":1,"message":"myopierr"}}`},
-			codes.Unknown,
+			codes.Unavailable,
 			fmt.Sprintf("nvmf_get_subsystems: %v", "json response error: myopierr"),
 		},
 		"valid request with valid SPDK response": {
@@ -777,14 +1185,14 @@ func TestFrontEnd_GetNvmeNamespace(t *testing.T) {
 			"-ABC-DEF",
 			nil,
 			[]string{},
-			codes.Unknown,
+			codes.InvalidArgument,
 			fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 		},
 		"no required field": {
 			"",
 			nil,
 			[]string{},
-			codes.Unknown,
+			codes.InvalidArgument,
 			"missing required field: name",
 		},
 	}
@@ -832,13 +1240,29 @@ func TestFrontEnd_StatsNvmeNamespace(t *testing.T) {
 		"valid request with valid SPDK response": {
 			testNamespaceName,
 			&pb.VolumeStats{
-				ReadOpsCount:  -1,
-				WriteOpsCount: -1,
+				ReadBytesCount:  4096,
+				ReadOpsCount:    8,
+				WriteBytesCount: 2048,
+				WriteOpsCount:   4,
 			},
-			[]string{},
+			[]string{`{"id":%d,"error":{"code":0,"message":""},"result":{"tick_rate":1000,"ticks":2000,"bdevs":[{"name":"Malloc0","bytes_read":512,"num_read_ops":1},{"name":"Malloc1","bytes_read":4096,"num_read_ops":8,"bytes_written":2048,"num_write_ops":4}]}}`},
+			codes.OK,
+			"",
+		},
+		"valid request with empty SPDK response": {
+			testNamespaceName,
+			&pb.VolumeStats{},
+			[]string{`{"id":%d,"error":{"code":0,"message":""},"result":{"tick_rate":1000,"ticks":2000,"bdevs":[]}}`},
 			codes.OK,
 			"",
 		},
+		"valid request with invalid marshal SPDK response": {
+			testNamespaceName,
+			nil,
+			[]string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			codes.Unavailable,
+			fmt.Sprintf("bdev_get_iostat: %v", "json: cannot unmarshal bool into Go value of type spdk.BdevGetIostatResult"),
+		},
 		"valid request with unknown key": {
 			utils.ResourceIDToVolumeName("unknown-id"),
 			nil,
@@ -850,7 +1274,7 @@ func TestFrontEnd_StatsNvmeNamespace(t *testing.T) {
 			"-ABC-DEF",
 			nil,
 			[]string{},
-			codes.Unknown,
+			codes.InvalidArgument,
 			fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 		},
 	}
@@ -861,7 +1285,9 @@ func TestFrontEnd_StatsNvmeNamespace(t *testing.T) {
 			testEnv := createTestEnvironment(tt.spdk)
 			defer testEnv.Close()
 
-			testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = utils.ProtoClone(&testNamespace)
+			namespace := utils.ProtoClone(&testNamespace)
+			namespace.Spec.VolumeNameRef = "Malloc1"
+			testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = namespace
 
 			request := &pb.StatsNvmeNamespaceRequest{Name: tt.in}
 			response, err := testEnv.client.StatsNvmeNamespace(testEnv.ctx, request)
@@ -883,3 +1309,182 @@ func TestFrontEnd_StatsNvmeNamespace(t *testing.T) {
 		})
 	}
 }
+
+func TestFrontEnd_CreateNvmeNamespaceBlockSizeCheck(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	bdev := `{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"Malloc1","block_size":4096,"num_blocks":256,"uuid":"9d5c3d1c-c5e3-4b2b-8b5e-1a5a1f7d8a8b"}]}`
+	mismatch := fmt.Sprintf("volume %s block size %d does not match expected %d", "Malloc1", 4096, 512)
+
+	tests := map[string]struct {
+		expected string
+		strict   bool
+		spdk     []string
+		warning  []string
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"no expected block size": {
+			expected: "",
+			strict:   true,
+			spdk:     []string{bdev, `{"id":%d,"error":{"code":0,"message":""},"result":22}`},
+			warning:  nil,
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"matching block size": {
+			expected: "4096",
+			strict:   true,
+			spdk:     []string{bdev, `{"id":%d,"error":{"code":0,"message":""},"result":22}`},
+			warning:  nil,
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"mismatching block size warns": {
+			expected: "512",
+			strict:   false,
+			spdk:     []string{bdev, `{"id":%d,"error":{"code":0,"message":""},"result":22}`},
+			warning:  []string{mismatch},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"mismatching block size fails in strict mode": {
+			expected: "512",
+			strict:   true,
+			spdk:     []string{bdev},
+			warning:  nil,
+			errCode:  codes.FailedPrecondition,
+			errMsg:   mismatch,
+		},
+		"invalid expected block size": {
+			expected: "4k",
+			strict:   false,
+			spdk:     []string{},
+			warning:  nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("invalid expected block size %q", "4k"),
+		},
+		"missing volume": {
+			expected: "512",
+			strict:   false,
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":[]}`},
+			warning:  nil,
+			errCode:  codes.FailedPrecondition,
+			errMsg:   fmt.Sprintf("volume %v referenced by namespace %v does not exist", "Malloc1", testNamespaceName),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			utils.SetFeatureFlags(map[string]bool{StrictNamespaceBlockSizeFeature: tt.strict})
+			t.Cleanup(func() { utils.SetFeatureFlags(nil) })
+
+			testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+
+			ctx := testEnv.ctx
+			if tt.expected != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, NvmeNamespaceBlockSizeMetadataKey, tt.expected)
+			}
+			var trailer metadata.MD
+			spec := &pb.NvmeNamespaceSpec{VolumeNameRef: "Malloc1"}
+			request := &pb.CreateNvmeNamespaceRequest{Parent: testSubsystemName, NvmeNamespace: &pb.NvmeNamespace{Spec: spec}, NvmeNamespaceId: testNamespaceID}
+			_, err := testEnv.client.CreateNvmeNamespace(ctx, request, grpc.Trailer(&trailer))
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if warning := trailer.Get(NvmeNamespaceBlockSizeWarningTrailerKey); !reflect.DeepEqual(warning, tt.warning) {
+				t.Error("warning: expected", tt.warning, "received", warning)
+			}
+			_, created := testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName]
+			if created != (tt.errCode == codes.OK) {
+				t.Error("namespace created: expected", tt.errCode == codes.OK, "received", created)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_NvmeNamespaceErrorCodes(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		call    func(testEnv *testEnv) error
+		errCode codes.Code
+		errMsg  string
+	}{
+		"create in missing subsystem": {
+			call: func(testEnv *testEnv) error {
+				request := &pb.CreateNvmeNamespaceRequest{
+					Parent:          testSubsystemName,
+					NvmeNamespace:   &pb.NvmeNamespace{Spec: &pb.NvmeNamespaceSpec{VolumeNameRef: "Malloc1"}},
+					NvmeNamespaceId: "other-namespace",
+				}
+				_, err := testEnv.client.CreateNvmeNamespace(testEnv.ctx, request)
+				return err
+			},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("subsystem %v referenced by namespace %v does not exist", testSubsystemName, utils.ResourceIDToNamespaceName(testSubsystemID, "other-namespace")),
+		},
+		"delete in missing subsystem": {
+			call: func(testEnv *testEnv) error {
+				_, err := testEnv.client.DeleteNvmeNamespace(testEnv.ctx, &pb.DeleteNvmeNamespaceRequest{Name: testNamespaceName})
+				return err
+			},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find subsystem %v", testSubsystemName),
+		},
+		"get in missing subsystem": {
+			call: func(testEnv *testEnv) error {
+				_, err := testEnv.client.GetNvmeNamespace(testEnv.ctx, &pb.GetNvmeNamespaceRequest{Name: testNamespaceName})
+				return err
+			},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find subsystem %v", testSubsystemName),
+		},
+		"list in missing subsystem": {
+			call: func(testEnv *testEnv) error {
+				_, err := testEnv.client.ListNvmeNamespaces(testEnv.ctx, &pb.ListNvmeNamespacesRequest{Parent: testSubsystemName})
+				return err
+			},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find subsystem %v", testSubsystemName),
+		},
+		"list in malformed subsystem name": {
+			call: func(testEnv *testEnv) error {
+				_, err := testEnv.client.ListNvmeNamespaces(testEnv.ctx, &pb.ListNvmeNamespacesRequest{Parent: "-ABC-DEF"})
+				return err
+			},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+			testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = utils.ProtoClone(&testNamespace)
+
+			err := tt.call(testEnv)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+		})
+	}
+}
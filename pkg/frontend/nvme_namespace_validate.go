@@ -10,25 +10,29 @@ import (
 	"go.einride.tech/aip/resourcename"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
 
 func (s *Server) validateCreateNvmeNamespaceRequest(in *pb.CreateNvmeNamespaceRequest) error {
+	v := &utils.Validator{}
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
-	}
+	v.CheckRequiredFields(in)
 	// see https://google.aip.dev/133#user-specified-ids
 	if in.NvmeNamespaceId != "" {
-		if err := resourceid.ValidateUserSettable(in.NvmeNamespaceId); err != nil {
-			return err
-		}
+		v.Check("nvme_namespace_id", resourceid.ValidateUserSettable(in.NvmeNamespaceId))
 	}
 	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	if err := resourcename.Validate(in.Parent); err != nil {
-		return err
+	if in.Parent != "" {
+		v.Check("parent", resourcename.Validate(in.Parent))
 	}
 	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	return resourcename.Validate(in.NvmeNamespace.Spec.VolumeNameRef)
+	if in.GetNvmeNamespace().GetSpec().GetVolumeNameRef() != "" {
+		v.Check("nvme_namespace.spec.volume_name_ref", resourcename.Validate(in.NvmeNamespace.Spec.VolumeNameRef))
+	}
+	if in.GetNvmeNamespace().GetSpec().GetUuid() != "" {
+		v.Check("nvme_namespace.spec.uuid", utils.ValidateUUID(in.NvmeNamespace.Spec.Uuid))
+	}
+	return v.Err()
 }
 
 func (s *Server) validateDeleteNvmeNamespaceRequest(in *pb.DeleteNvmeNamespaceRequest) error {
@@ -53,6 +57,15 @@ func (s *Server) validateUpdateNvmeNamespaceRequest(in *pb.UpdateNvmeNamespaceRe
 	return resourcename.Validate(in.NvmeNamespace.Name)
 }
 
+func (s *Server) validateListNvmeNamespacesRequest(in *pb.ListNvmeNamespacesRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Parent)
+}
+
 func (s *Server) validateGetNvmeNamespaceRequest(in *pb.GetNvmeNamespaceRequest) error {
 	// check required fields
 	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
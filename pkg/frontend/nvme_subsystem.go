@@ -7,10 +7,10 @@ package frontend
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
-	"path"
 	"sort"
 
 	"github.com/opiproject/gospdk/spdk"
@@ -20,8 +20,9 @@ import (
 	"github.com/google/uuid"
 	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/fieldmask"
-	"go.einride.tech/aip/resourceid"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
@@ -39,24 +40,35 @@ func (s *Server) CreateNvmeSubsystem(ctx context.Context, in *pb.CreateNvmeSubsy
 		return nil, err
 	}
 	// see https://google.aip.dev/133#user-specified-ids
-	resourceID := resourceid.NewSystemGenerated()
+	resourceID, err := s.idempotencyKeys.ResourceID(ctx, "NvmeSubsystem", in.NvmeSubsystemId)
+	if err != nil {
+		return nil, err
+	}
 	if in.NvmeSubsystemId != "" {
 		log.Printf("client provided the ID of a resource %v, ignoring the name field %v", in.NvmeSubsystemId, in.NvmeSubsystem.Name)
-		resourceID = in.NvmeSubsystemId
 	}
 	in.NvmeSubsystem.Name = utils.ResourceIDToSubsystemName(resourceID)
+	unlock := s.resourceLocks.Lock(in.NvmeSubsystem.Name)
+	defer unlock()
 	// idempotent API when called with same key, should return same object
+	s.mapsMu.RLock()
 	subsys, ok := s.Nvme.Subsystems[in.NvmeSubsystem.Name]
+	s.mapsMu.RUnlock()
 	if ok {
 		log.Printf("Already existing NvmeSubsystem with id %v", in.NvmeSubsystem.Name)
 		return subsys, nil
 	}
-	// check if another object exists with same NQN, it is not allowed
-	for _, item := range s.Nvme.Subsystems {
-		if in.NvmeSubsystem.Spec.Nqn == item.Spec.Nqn {
-			msg := fmt.Sprintf("Could not create NQN: %s since object %s with same NQN already exists", in.NvmeSubsystem.Spec.Nqn, item.Name)
-			return nil, status.Errorf(codes.AlreadyExists, msg)
+	if in.NvmeSubsystem.Spec.Nqn == "" {
+		nqn, err := s.generateNqn(resourceID)
+		if err != nil {
+			return nil, err
 		}
+		log.Printf("Generated NQN %v for NvmeSubsystem %v", nqn, in.NvmeSubsystem.Name)
+		in.NvmeSubsystem.Spec.Nqn = nqn
+	}
+	// check if another object exists with same NQN, it is not allowed
+	if err := s.checkNqnNotInUse(in.NvmeSubsystem.Spec.Nqn); err != nil {
+		return nil, err
 	}
 	// not found, so create a new one
 	params := spdk.NvmfCreateSubsystemParams{
@@ -66,8 +78,22 @@ func (s *Server) CreateNvmeSubsystem(ctx context.Context, in *pb.CreateNvmeSubsy
 		AllowAnyHost:  (in.NvmeSubsystem.Spec.Hostnqn == ""),
 		MaxNamespaces: int(in.NvmeSubsystem.Spec.MaxNamespaces),
 	}
+	if utils.SkipSpdkCallInDryRun(ctx, "nvmf_create_subsystem", &params) {
+		if in.NvmeSubsystem.Spec.Hostnqn != "" {
+			hostParams := spdk.NvmfSubsystemAddHostParams{
+				Nqn:  in.NvmeSubsystem.Spec.Nqn,
+				Host: in.NvmeSubsystem.Spec.Hostnqn,
+			}
+			// key file is not created by dry run
+			if len(in.NvmeSubsystem.Spec.Psk) > 0 {
+				hostParams.Psk = utils.RedactedPlaceholder
+			}
+			utils.SkipSpdkCallInDryRun(ctx, "nvmf_subsystem_add_host", &hostParams)
+		}
+		return utils.ProtoClone(in.NvmeSubsystem), nil
+	}
 	var result spdk.NvmfCreateSubsystemResult
-	err := s.rpc.Call(ctx, "nvmf_create_subsystem", &params, &result)
+	err = s.rpc.Call(ctx, "nvmf_create_subsystem", &params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -117,18 +143,41 @@ func (s *Server) CreateNvmeSubsystem(ctx context.Context, in *pb.CreateNvmeSubsy
 	log.Printf("Received from SPDK: %v", ver)
 	response := utils.ProtoClone(in.NvmeSubsystem)
 	response.Status = &pb.NvmeSubsystemStatus{FirmwareRevision: ver.Version}
+	s.mapsMu.Lock()
 	s.Nvme.Subsystems[in.NvmeSubsystem.Name] = response
+	s.mapsMu.Unlock()
 	return response, nil
 }
 
+// checkNqnNotInUse fails with AlreadyExists if a subsystem with nqn exists
+func (s *Server) checkNqnNotInUse(nqn string) error {
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	for _, item := range s.Nvme.Subsystems {
+		if nqn == item.Spec.Nqn {
+			msg := fmt.Sprintf("Could not create NQN: %s since object %s with same NQN already exists", nqn, item.Name)
+			return status.Errorf(codes.AlreadyExists, msg)
+		}
+	}
+	return nil
+}
+
 // DeleteNvmeSubsystem deletes an Nvme Subsystem
 func (s *Server) DeleteNvmeSubsystem(ctx context.Context, in *pb.DeleteNvmeSubsystemRequest) (*emptypb.Empty, error) {
 	// check input correctness
 	if err := s.validateDeleteNvmeSubsystemRequest(in); err != nil {
 		return nil, err
 	}
+	cascade, err := NvmeSubsystemCascadeRequested(ctx)
+	if err != nil {
+		return nil, err
+	}
+	unlock := s.resourceLocks.Lock(in.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	subsys, ok := s.Nvme.Subsystems[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			return &emptypb.Empty{}, nil
@@ -136,11 +185,24 @@ func (s *Server) DeleteNvmeSubsystem(ctx context.Context, in *pb.DeleteNvmeSubsy
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
+	controllers, namespaces := s.subsystemChildren(in.Name)
+	if len(controllers) != 0 || len(namespaces) != 0 {
+		if !cascade {
+			msg := fmt.Sprintf("NvmeSubsystem %s has %d controllers and %d namespaces, delete them first or request cascade delete", in.Name, len(controllers), len(namespaces))
+			return nil, status.Errorf(codes.FailedPrecondition, msg)
+		}
+		if err := s.deleteSubsystemChildren(ctx, controllers, namespaces); err != nil {
+			return nil, err
+		}
+	}
 	params := spdk.NvmfDeleteSubsystemParams{
 		Nqn: subsys.Spec.Nqn,
 	}
+	if utils.SkipSpdkCallInDryRun(ctx, "nvmf_delete_subsystem", &params) {
+		return &emptypb.Empty{}, nil
+	}
 	var result spdk.NvmfDeleteSubsystemResult
-	err := s.rpc.Call(ctx, "nvmf_delete_subsystem", &params, &result)
+	err = s.rpc.Call(ctx, "nvmf_delete_subsystem", &params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -149,7 +211,9 @@ func (s *Server) DeleteNvmeSubsystem(ctx context.Context, in *pb.DeleteNvmeSubsy
 		msg := fmt.Sprintf("Could not delete NQN: %s", subsys.Spec.Nqn)
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
+	s.mapsMu.Lock()
 	delete(s.Nvme.Subsystems, subsys.Name)
+	s.mapsMu.Unlock()
 	return &emptypb.Empty{}, nil
 }
 
@@ -159,8 +223,12 @@ func (s *Server) UpdateNvmeSubsystem(_ context.Context, in *pb.UpdateNvmeSubsyst
 	if err := s.validateUpdateNvmeSubsystemRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.NvmeSubsystem.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	subsys, ok := s.Nvme.Subsystems[in.NvmeSubsystem.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			log.Printf("TODO: in case of AllowMissing, create a new resource, don;t return error")
@@ -168,7 +236,7 @@ func (s *Server) UpdateNvmeSubsystem(_ context.Context, in *pb.UpdateNvmeSubsyst
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.NvmeSubsystem.Name)
 		return nil, err
 	}
-	resourceID := path.Base(subsys.Name)
+	resourceID := utils.ResourceNameToID(subsys.Name)
 	// update_mask = 2
 	if err := fieldmask.Validate(in.UpdateMask, in.NvmeSubsystem); err != nil {
 		return nil, err
@@ -199,13 +267,14 @@ func (s *Server) ListNvmeSubsystems(ctx context.Context, in *pb.ListNvmeSubsyste
 	result, hasMoreElements := utils.LimitPagination(result, offset, size)
 	if hasMoreElements {
 		token = uuid.New().String()
-		s.Pagination[token] = offset + size
+		s.Pagination.Set(token, offset+size)
 	}
 	Blobarray := make([]*pb.NvmeSubsystem, len(result))
 	for i := range result {
 		r := &result[i]
 		Blobarray[i] = &pb.NvmeSubsystem{Spec: &pb.NvmeSubsystemSpec{Nqn: r.Nqn, SerialNumber: r.SerialNumber, ModelNumber: r.ModelNumber}}
 	}
+	Blobarray, token = utils.LimitPaginationBySize(Blobarray, offset, token, s.Pagination)
 	sortNvmeSubsystems(Blobarray)
 	return &pb.ListNvmeSubsystemsResponse{NvmeSubsystems: Blobarray, NextPageToken: token}, nil
 }
@@ -217,7 +286,9 @@ func (s *Server) GetNvmeSubsystem(ctx context.Context, in *pb.GetNvmeSubsystemRe
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	subsys, ok := s.Nvme.Subsystems[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
@@ -247,12 +318,14 @@ func (s *Server) StatsNvmeSubsystem(ctx context.Context, in *pb.StatsNvmeSubsyst
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	subsys, ok := s.Nvme.Subsystems[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(subsys.Name)
+	resourceID := utils.ResourceNameToID(subsys.Name)
 	log.Printf("TODO: send name to SPDK and get back stats: %v", resourceID)
 	var result spdk.NvmfGetSubsystemStatsResult
 	err := s.rpc.Call(ctx, "nvmf_get_stats", nil, &result)
@@ -260,5 +333,62 @@ func (s *Server) StatsNvmeSubsystem(ctx context.Context, in *pb.StatsNvmeSubsyst
 		return nil, err
 	}
 	log.Printf("Received from SPDK: %v", result)
+	// VolumeStats has no room for poll group breakdown, send it in trailer
+	breakdown, err := json.Marshal(newNvmeSubsystemStats(&result))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if err := grpc.SetTrailer(ctx, metadata.Pairs(NvmeSubsystemStatsTrailerKey, string(breakdown))); err != nil {
+		log.Printf("error: failed to set subsystem stats trailer: %v", err)
+	}
 	return &pb.StatsNvmeSubsystemResponse{Stats: &pb.VolumeStats{ReadOpsCount: -1, WriteOpsCount: -1}}, nil
 }
+
+// NvmeSubsystemStatsTrailerKey is response trailer key carrying JSON encoded
+// NvmeSubsystemStats of StatsNvmeSubsystem call
+const NvmeSubsystemStatsTrailerKey = "opi-nvme-subsystem-stats"
+
+// NvmePollGroupStats contains statistics of a single nvmf target poll group
+type NvmePollGroupStats struct {
+	Name               string   `json:"name"`
+	AdminQpairs        int      `json:"admin_qpairs"`
+	IoQpairs           int      `json:"io_qpairs"`
+	CurrentAdminQpairs int      `json:"current_admin_qpairs"`
+	CurrentIoQpairs    int      `json:"current_io_qpairs"`
+	PendingBdevIo      int      `json:"pending_bdev_io"`
+	Transports         []string `json:"transports"`
+}
+
+// NvmeSubsystemStats contains poll group breakdown of nvmf target load
+// returned alongside VolumeStats
+type NvmeSubsystemStats struct {
+	TickRate   int                  `json:"tick_rate"`
+	PollGroups []NvmePollGroupStats `json:"poll_groups"`
+	// Transports maps transport type to number of poll groups serving it
+	Transports map[string]int `json:"transports"`
+}
+
+func newNvmeSubsystemStats(result *spdk.NvmfGetSubsystemStatsResult) *NvmeSubsystemStats {
+	stats := &NvmeSubsystemStats{
+		TickRate:   result.TickRate,
+		PollGroups: make([]NvmePollGroupStats, 0, len(result.PollGroups)),
+		Transports: make(map[string]int),
+	}
+	for _, group := range result.PollGroups {
+		groupStats := NvmePollGroupStats{
+			Name:               group.Name,
+			AdminQpairs:        group.AdminQpairs,
+			IoQpairs:           group.IoQpairs,
+			CurrentAdminQpairs: group.CurrentAdminQpairs,
+			CurrentIoQpairs:    group.CurrentIoQpairs,
+			PendingBdevIo:      group.PendingBdevIo,
+			Transports:         make([]string, 0, len(group.Transports)),
+		}
+		for _, transport := range group.Transports {
+			groupStats.Transports = append(groupStats.Transports, transport.Trtype)
+			stats.Transports[transport.Trtype]++
+		}
+		stats.PollGroups = append(stats.PollGroups, groupStats)
+	}
+	return stats
+}
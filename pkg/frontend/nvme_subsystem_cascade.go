@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// NvmeSubsystemCascadeMetadataKey is metadata key which, when set to
+// "true", makes DeleteNvmeSubsystem delete controllers and namespaces of the
+// subsystem first, since DeleteNvmeSubsystemRequest has no such field.
+// Without it subsystem with controllers or namespaces is not deleted
+const NvmeSubsystemCascadeMetadataKey = "opi-cascade-delete"
+
+// NvmeSubsystemCascadeRequested tells whether client requested cascade
+// delete of subsystem
+func NvmeSubsystemCascadeRequested(ctx context.Context) (bool, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(NvmeSubsystemCascadeMetadataKey)
+	if len(values) == 0 {
+		return false, nil
+	}
+	cascade, err := strconv.ParseBool(values[0])
+	if err != nil {
+		msg := fmt.Sprintf("invalid cascade delete flag %q", values[0])
+		return false, status.Errorf(codes.InvalidArgument, msg)
+	}
+	return cascade, nil
+}
+
+// subsystemChildren returns sorted names of controllers and namespaces of
+// subsystem
+func (s *Server) subsystemChildren(subsysName string) (controllers []string, namespaces []string) {
+	subsysID := utils.ResourceNameToID(subsysName)
+	s.mapsMu.RLock()
+	for name := range s.Nvme.Controllers {
+		if utils.GetSubsystemIDFromNvmeName(name) == subsysID {
+			controllers = append(controllers, name)
+		}
+	}
+	for name := range s.Nvme.Namespaces {
+		if utils.GetSubsystemIDFromNvmeName(name) == subsysID {
+			namespaces = append(namespaces, name)
+		}
+	}
+	s.mapsMu.RUnlock()
+	sort.Strings(controllers)
+	sort.Strings(namespaces)
+	return controllers, namespaces
+}
+
+// SetResourceReleaser sets releaser notified of controllers and namespaces
+// deleted with cascaded subsystem, since their deletes bypass interceptors
+func (s *Server) SetResourceReleaser(releaser utils.ResourceReleaser) {
+	s.releaser = releaser
+}
+
+// deleteSubsystemChildren deletes controllers of subsystem first, so that
+// hosts disconnect before namespaces are removed, and namespaces then. Dry
+// run deletes nothing, so children are left in place
+func (s *Server) deleteSubsystemChildren(ctx context.Context, controllers []string, namespaces []string) error {
+	dryRun := utils.DryRunRequested(ctx)
+	for _, name := range controllers {
+		if _, err := s.DeleteNvmeController(ctx, &pb.DeleteNvmeControllerRequest{Name: name}); err != nil {
+			return err
+		}
+		s.releaseChild(name, dryRun)
+	}
+	for _, name := range namespaces {
+		if _, err := s.DeleteNvmeNamespace(ctx, &pb.DeleteNvmeNamespaceRequest{Name: name}); err != nil {
+			return err
+		}
+		s.releaseChild(name, dryRun)
+	}
+	return nil
+}
+
+func (s *Server) releaseChild(name string, dryRun bool) {
+	if s.releaser != nil && !dryRun {
+		s.releaser.Release(name)
+	}
+}
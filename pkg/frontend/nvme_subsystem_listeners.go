@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"log"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/fieldbehavior"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// NvmeSubsystemListenersServiceName is full name of the service managing
+// listeners of Nvme subsystems. It is not part of OPI API, so it is
+// registered with a hand written service descriptor
+const NvmeSubsystemListenersServiceName = "opi_spdk_bridge.v1.NvmeSubsystemListenersService"
+
+// subsystemListeners returns TCP controllers of subsystem which listen for
+// connections, sorted by name
+func (s *Server) subsystemListeners(subsysName string) []*pb.NvmeController {
+	subsysID := utils.GetSubsystemIDFromNvmeName(subsysName)
+	listeners := []*pb.NvmeController{}
+	s.mapsMu.RLock()
+	for _, controller := range s.Nvme.Controllers {
+		if controller.GetSpec().GetTrtype() != pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP ||
+			!controller.GetStatus().GetActive() ||
+			utils.GetSubsystemIDFromNvmeName(controller.Name) != subsysID {
+			continue
+		}
+		listeners = append(listeners, controller)
+	}
+	s.mapsMu.RUnlock()
+	sortNvmeControllers(listeners)
+	return listeners
+}
+
+// RemoveAllListeners removes listeners of all TCP controllers of subsystem,
+// so it stops accepting connections. Controllers and namespaces are kept,
+// controllers are marked inactive
+func (s *Server) RemoveAllListeners(ctx context.Context, in *pb.GetNvmeSubsystemRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return nil, err
+	}
+	if err := resourcename.Validate(in.Name); err != nil {
+		return nil, err
+	}
+	s.mapsMu.RLock()
+	subsys, ok := s.Nvme.Subsystems[in.Name]
+	s.mapsMu.RUnlock()
+	if !ok {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	transport, ok := s.Nvme.transports[pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound,
+			"handler for transport type %v is not registered", pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP)
+	}
+	listeners := s.subsystemListeners(in.Name)
+	if len(listeners) == 0 {
+		return &emptypb.Empty{}, nil
+	}
+
+	err := s.withSubsystemPaused(ctx, subsys.Spec.Nqn, func() error {
+		for _, controller := range listeners {
+			if err := transport.DeleteController(ctx, controller, subsys); err != nil {
+				return err
+			}
+			inactive := utils.ProtoClone(controller)
+			inactive.Status.Active = false
+			s.mapsMu.Lock()
+			s.Nvme.Controllers[controller.Name] = inactive
+			s.mapsMu.Unlock()
+			log.Printf("Removed listener of %v", controller.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// nvmeSubsystemListenersServiceServer is implemented by Server
+type nvmeSubsystemListenersServiceServer interface {
+	RemoveAllListeners(context.Context, *pb.GetNvmeSubsystemRequest) (*emptypb.Empty, error)
+}
+
+var nvmeSubsystemListenersServiceDesc = grpc.ServiceDesc{
+	ServiceName: NvmeSubsystemListenersServiceName,
+	HandlerType: (*nvmeSubsystemListenersServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RemoveAllListeners",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(pb.GetNvmeSubsystemRequest)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(nvmeSubsystemListenersServiceServer).RemoveAllListeners(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: "/" + NvmeSubsystemListenersServiceName + "/RemoveAllListeners",
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(nvmeSubsystemListenersServiceServer).RemoveAllListeners(ctx, req.(*pb.GetNvmeSubsystemRequest))
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterNvmeSubsystemListenersServer registers subsystem listeners service
+// on s
+func RegisterNvmeSubsystemListenersServer(s *grpc.Server, srv *Server) {
+	s.RegisterService(&nvmeSubsystemListenersServiceDesc, srv)
+}
@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_RemoveAllListeners(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	secondControllerName := utils.ResourceIDToControllerName(testSubsystemID, "controller-second")
+	otherSubsystemControllerName := utils.ResourceIDToControllerName("subsystem-other", "controller-other")
+
+	tests := map[string]struct {
+		in          string
+		spdk        []string
+		methods     []string
+		inactive    []string
+		errCode     codes.Code
+		errMsg      string
+		noSubsystem bool
+	}{
+		"all listeners are removed": {
+			in: testSubsystemName,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			methods:  []string{"nvmf_subsystem_remove_listener", "nvmf_subsystem_remove_listener"},
+			inactive: []string{testControllerName, secondControllerName},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"failed removal keeps remaining listeners": {
+			in: testSubsystemName,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":false}`,
+			},
+			methods:  []string{"nvmf_subsystem_remove_listener", "nvmf_subsystem_remove_listener"},
+			inactive: []string{secondControllerName},
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("Could not delete CTRL: %s", testControllerName),
+		},
+		"missing subsystem": {
+			in:          testSubsystemName,
+			spdk:        []string{},
+			methods:     nil,
+			inactive:    []string{},
+			errCode:     codes.NotFound,
+			errMsg:      fmt.Sprintf("unable to find key %s", testSubsystemName),
+			noSubsystem: true,
+		},
+		"malformed name": {
+			in:       "-ABC-DEF",
+			spdk:     []string{},
+			methods:  nil,
+			inactive: []string{},
+			errCode:  codes.Unknown,
+			errMsg:   fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+			testEnv.opiSpdkServer.Nvme.transports[pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP] = NewNvmeTCPTransport(recorder)
+			if !tt.noSubsystem {
+				testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+			}
+			testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = utils.ProtoClone(&testNamespace)
+			for _, name := range []string{testControllerName, secondControllerName, otherSubsystemControllerName} {
+				controller := utils.ProtoClone(&testController)
+				controller.Name = name
+				testEnv.opiSpdkServer.Nvme.Controllers[name] = controller
+			}
+
+			_, err := testEnv.opiSpdkServer.RemoveAllListeners(testEnv.ctx, &pb.GetNvmeSubsystemRequest{Name: tt.in})
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if !reflect.DeepEqual(recorder.methods, tt.methods) {
+				t.Error("methods: expected", tt.methods, "received", recorder.methods)
+			}
+			if len(testEnv.opiSpdkServer.Nvme.Controllers) != 3 {
+				t.Error("expected all controllers to remain, received", testEnv.opiSpdkServer.Nvme.Controllers)
+			}
+			if _, ok := testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName]; !ok {
+				t.Error("expected namespace to remain")
+			}
+			inactive := []string{}
+			for _, name := range []string{testControllerName, secondControllerName, otherSubsystemControllerName} {
+				if !testEnv.opiSpdkServer.Nvme.Controllers[name].Status.Active {
+					inactive = append(inactive, name)
+				}
+			}
+			if !reflect.DeepEqual(inactive, tt.inactive) {
+				t.Error("inactive controllers: expected", tt.inactive, "received", inactive)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_DeleteNvmeControllerWithoutListener(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	recorder := testEnv.recordSpdkParams()
+	testEnv.opiSpdkServer.Nvme.transports[pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP] = NewNvmeTCPTransport(recorder)
+	testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+	controller := utils.ProtoClone(&testController)
+	controller.Name = testControllerName
+	controller.Status.Active = false
+	testEnv.opiSpdkServer.Nvme.Controllers[testControllerName] = controller
+
+	_, err := testEnv.client.DeleteNvmeController(testEnv.ctx, &pb.DeleteNvmeControllerRequest{Name: testControllerName})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if len(recorder.methods) != 0 {
+		t.Error("expected no SPDK calls for removed listener, received", recorder.methods)
+	}
+	if _, ok := testEnv.opiSpdkServer.Nvme.Controllers[testControllerName]; ok {
+		t.Error("expected controller to be deleted")
+	}
+}
@@ -7,8 +7,11 @@ package frontend
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"reflect"
 	"strings"
@@ -16,11 +19,14 @@ import (
 
 	"google.golang.org/protobuf/proto"
 
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/fieldmaskpb"
 
+	"github.com/opiproject/gospdk/spdk"
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
@@ -247,7 +253,7 @@ func TestFrontEnd_CreateNvmeSubsystem(t *testing.T) {
 			out:     nil,
 			spdk:    []string{},
 			errCode: codes.InvalidArgument,
-			errMsg:  fmt.Sprintf("ModelNumber value (%s) is too long, have to be between 1 and %d", strings.Repeat("c", 41), 40),
+			errMsg:  fmt.Sprintf("NQN value (%s) does not match pattern; ModelNumber value (%s) is too long, have to be between 1 and %d", strings.Repeat("a", 223), strings.Repeat("c", 41), 40),
 			exist:   false,
 		},
 		"too long serial field": {
@@ -263,7 +269,7 @@ func TestFrontEnd_CreateNvmeSubsystem(t *testing.T) {
 			out:     nil,
 			spdk:    []string{},
 			errCode: codes.InvalidArgument,
-			errMsg:  fmt.Sprintf("SerialNumber value (%s) is too long, have to be between 1 and %d", strings.Repeat("b", 21), 20),
+			errMsg:  fmt.Sprintf("NQN value (%s) does not match pattern; SerialNumber value (%s) is too long, have to be between 1 and %d", strings.Repeat("a", 223), strings.Repeat("b", 21), 20),
 			exist:   false,
 		},
 	}
@@ -327,6 +333,37 @@ func TestFrontEnd_CreateNvmeSubsystem(t *testing.T) {
 	}
 }
 
+func TestFrontEnd_CreateNvmeSubsystemIdempotencyKey(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+		`{"jsonrpc":"2.0","id":%d,"result":{"version":"SPDK v20.10","fields":{"major":20,"minor":10,"patch":0,"suffix":""}}}`,
+	})
+	defer testEnv.Close()
+	recorder := testEnv.recordSpdkParams()
+	ctx := metadata.AppendToOutgoingContext(testEnv.ctx, utils.IdempotencyKeyMetadataKey, "retry-1")
+
+	first, err := testEnv.client.CreateNvmeSubsystem(ctx, &pb.CreateNvmeSubsystemRequest{NvmeSubsystem: &testSubsystem})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	retried, err := testEnv.client.CreateNvmeSubsystem(ctx, &pb.CreateNvmeSubsystemRequest{NvmeSubsystem: &testSubsystem})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	if !proto.Equal(retried, first) {
+		t.Error("response: expected", first, "received", retried)
+	}
+	if len(testEnv.opiSpdkServer.Nvme.Subsystems) != 1 {
+		t.Error("expected exactly 1 subsystem, received", testEnv.opiSpdkServer.Nvme.Subsystems)
+	}
+	expectedMethods := []string{"nvmf_create_subsystem", "spdk_get_version"}
+	if !reflect.DeepEqual(recorder.methods, expectedMethods) {
+		t.Error("spdk methods: expected", expectedMethods, "received", recorder.methods)
+	}
+}
+
 func TestFrontEnd_DeleteNvmeSubsystem(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
 	tests := map[string]struct {
@@ -439,6 +476,181 @@ func TestFrontEnd_DeleteNvmeSubsystem(t *testing.T) {
 	}
 }
 
+func TestFrontEnd_DeleteNvmeSubsystemCascade(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	secondNamespaceName := utils.ResourceIDToNamespaceName(testSubsystemID, "second-namespace")
+	tests := map[string]struct {
+		cascade        string
+		children       bool
+		spdk           []string
+		methods        []string
+		childrenRemain bool
+		errCode        codes.Code
+		errMsg         string
+	}{
+		"children without cascade": {
+			cascade:        "",
+			children:       true,
+			spdk:           []string{},
+			methods:        nil,
+			childrenRemain: true,
+			errCode:        codes.FailedPrecondition,
+			errMsg:         fmt.Sprintf("NvmeSubsystem %s has 1 controllers and 2 namespaces, delete them first or request cascade delete", testSubsystemName),
+		},
+		"children with cascade disabled": {
+			cascade:        "false",
+			children:       true,
+			spdk:           []string{},
+			methods:        nil,
+			childrenRemain: true,
+			errCode:        codes.FailedPrecondition,
+			errMsg:         fmt.Sprintf("NvmeSubsystem %s has 1 controllers and 2 namespaces, delete them first or request cascade delete", testSubsystemName),
+		},
+		"cascade with multiple namespaces": {
+			cascade:  "true",
+			children: true,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			// listener is removed by transport, which is not recorded
+			methods:        []string{"nvmf_subsystem_remove_ns", "nvmf_subsystem_remove_ns", "nvmf_delete_subsystem"},
+			childrenRemain: false,
+			errCode:        codes.OK,
+			errMsg:         "",
+		},
+		"cascade without children": {
+			cascade:        "true",
+			children:       false,
+			spdk:           []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			methods:        []string{"nvmf_delete_subsystem"},
+			childrenRemain: false,
+			errCode:        codes.OK,
+			errMsg:         "",
+		},
+		"cascade stops on failed namespace delete": {
+			cascade:  "true",
+			children: true,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":false}`,
+			},
+			methods:        []string{"nvmf_subsystem_remove_ns"},
+			childrenRemain: true,
+			errCode:        codes.Unavailable,
+			errMsg:         fmt.Sprintf("nvmf_subsystem_remove_ns: %v", "json response error: myopierr"),
+		},
+		"invalid cascade flag": {
+			cascade:        "maybe",
+			children:       true,
+			spdk:           []string{},
+			methods:        nil,
+			childrenRemain: true,
+			errCode:        codes.InvalidArgument,
+			errMsg:         fmt.Sprintf("invalid cascade delete flag %q", "maybe"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+			testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+			testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName].Name = testSubsystemName
+			if tt.children {
+				testEnv.opiSpdkServer.Nvme.Controllers[testControllerName] = utils.ProtoClone(&testController)
+				testEnv.opiSpdkServer.Nvme.Controllers[testControllerName].Name = testControllerName
+				testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = utils.ProtoClone(&testNamespace)
+				testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName].Name = testNamespaceName
+				testEnv.opiSpdkServer.Nvme.Namespaces[secondNamespaceName] = utils.ProtoClone(&testNamespace)
+				testEnv.opiSpdkServer.Nvme.Namespaces[secondNamespaceName].Name = secondNamespaceName
+				testEnv.opiSpdkServer.Nvme.Namespaces[secondNamespaceName].Spec.HostNsid = 23
+			}
+
+			ctx := testEnv.ctx
+			if tt.cascade != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, NvmeSubsystemCascadeMetadataKey, tt.cascade)
+			}
+			request := &pb.DeleteNvmeSubsystemRequest{Name: testSubsystemName}
+			_, err := testEnv.client.DeleteNvmeSubsystem(ctx, request)
+
+			if !reflect.DeepEqual(recorder.methods, tt.methods) {
+				t.Error("spdk methods: expected", tt.methods, "received", recorder.methods)
+			}
+			_, subsysRemains := testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName]
+			if subsysRemains != (tt.errCode != codes.OK) {
+				t.Error("subsystem remains: expected", tt.errCode != codes.OK, "received", subsysRemains)
+			}
+			children := len(testEnv.opiSpdkServer.Nvme.Namespaces) + len(testEnv.opiSpdkServer.Nvme.Controllers)
+			if (children != 0) != tt.childrenRemain {
+				t.Error("children remain: expected", tt.childrenRemain, "received", children)
+			}
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+		})
+	}
+}
+
+func TestFrontEnd_DeleteNvmeSubsystemCascadeReleasesQuota(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+	})
+	defer testEnv.Close()
+	quotas, err := utils.NewTenantQuotas(map[string]int{"NvmeController": 1, "NvmeNamespace": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEnv.opiSpdkServer.SetResourceReleaser(quotas)
+	// children are accounted to tenant as created through the interceptor
+	create := func(method string, created proto.Message) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(utils.TenantMetadataKey, "tenant-a"))
+		info := &grpc.UnaryServerInfo{FullMethod: "/opi_api.storage.v1.FrontendNvmeService/" + method}
+		handler := func(context.Context, interface{}) (interface{}, error) { return created, nil }
+		_, err := quotas.UnaryServerInterceptor(ctx, nil, info, handler)
+		return err
+	}
+	if err := create("CreateNvmeController", &pb.NvmeController{Name: testControllerName}); err != nil {
+		t.Fatal(err)
+	}
+	if err := create("CreateNvmeNamespace", &pb.NvmeNamespace{Name: testNamespaceName}); err != nil {
+		t.Fatal(err)
+	}
+	testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+	testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName].Name = testSubsystemName
+	testEnv.opiSpdkServer.Nvme.Controllers[testControllerName] = utils.ProtoClone(&testController)
+	testEnv.opiSpdkServer.Nvme.Controllers[testControllerName].Name = testControllerName
+	testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = utils.ProtoClone(&testNamespace)
+	testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName].Name = testNamespaceName
+
+	ctx := metadata.AppendToOutgoingContext(testEnv.ctx, NvmeSubsystemCascadeMetadataKey, "true")
+	if _, err := testEnv.client.DeleteNvmeSubsystem(ctx, &pb.DeleteNvmeSubsystemRequest{Name: testSubsystemName}); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	if err := create("CreateNvmeController", &pb.NvmeController{Name: testControllerName + "-new"}); err != nil {
+		t.Error("expected controller quota released by cascade delete, received", err)
+	}
+	if err := create("CreateNvmeNamespace", &pb.NvmeNamespace{Name: testNamespaceName + "-new"}); err != nil {
+		t.Error("expected namespace quota released by cascade delete, received", err)
+	}
+}
+
 func TestFrontEnd_UpdateNvmeSubsystem(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
 	tests := map[string]struct {
@@ -686,7 +898,7 @@ func TestFrontEnd_ListNvmeSubsystem(t *testing.T) {
 			testEnv := createTestEnvironment(tt.spdk)
 			defer testEnv.Close()
 
-			testEnv.opiSpdkServer.Pagination["existing-pagination-token"] = 1
+			testEnv.opiSpdkServer.Pagination.Set("existing-pagination-token", 1)
 
 			request := &pb.ListNvmeSubsystemsRequest{PageSize: tt.size, PageToken: tt.token}
 			response, err := testEnv.client.ListNvmeSubsystems(testEnv.ctx, request)
@@ -902,3 +1114,205 @@ func TestFrontEnd_StatsNvmeSubsystem(t *testing.T) {
 		})
 	}
 }
+
+func TestFrontEnd_StatsNvmeSubsystemPollGroups(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		spdk  []string
+		stats *NvmeSubsystemStats
+	}{
+		"single poll group with multiple transports": {
+			spdk: []string{`{"jsonrpc":"2.0","id":%d,"result":{"tick_rate":2490000000,"poll_groups":[{"name":"nvmf_tgt_poll_group_0","admin_qpairs":0,"io_qpairs":0,"current_admin_qpairs":0,"current_io_qpairs":0,"pending_bdev_io":0,"transports":[{"trtype":"TCP"},{"trtype":"VFIOUSER"}]}]}}`},
+			stats: &NvmeSubsystemStats{
+				TickRate: 2490000000,
+				PollGroups: []NvmePollGroupStats{
+					{Name: "nvmf_tgt_poll_group_0", Transports: []string{"TCP", "VFIOUSER"}},
+				},
+				Transports: map[string]int{"TCP": 1, "VFIOUSER": 1},
+			},
+		},
+		"multiple poll groups": {
+			spdk: []string{`{"jsonrpc":"2.0","id":%d,"result":{"tick_rate":2490000000,"poll_groups":[{"name":"nvmf_tgt_poll_group_0","admin_qpairs":1,"io_qpairs":4,"current_admin_qpairs":1,"current_io_qpairs":2,"pending_bdev_io":3,"transports":[{"trtype":"TCP"},{"trtype":"VFIOUSER"}]},{"name":"nvmf_tgt_poll_group_1","admin_qpairs":0,"io_qpairs":5,"current_admin_qpairs":0,"current_io_qpairs":5,"pending_bdev_io":7,"transports":[{"trtype":"TCP"}]}]}}`},
+			stats: &NvmeSubsystemStats{
+				TickRate: 2490000000,
+				PollGroups: []NvmePollGroupStats{
+					{Name: "nvmf_tgt_poll_group_0", AdminQpairs: 1, IoQpairs: 4, CurrentAdminQpairs: 1, CurrentIoQpairs: 2, PendingBdevIo: 3, Transports: []string{"TCP", "VFIOUSER"}},
+					{Name: "nvmf_tgt_poll_group_1", AdminQpairs: 0, IoQpairs: 5, CurrentAdminQpairs: 0, CurrentIoQpairs: 5, PendingBdevIo: 7, Transports: []string{"TCP"}},
+				},
+				Transports: map[string]int{"TCP": 2, "VFIOUSER": 1},
+			},
+		},
+		"no poll groups": {
+			spdk: []string{`{"jsonrpc":"2.0","id":%d,"result":{"tick_rate":2490000000,"poll_groups":[]}}`},
+			stats: &NvmeSubsystemStats{
+				TickRate:   2490000000,
+				PollGroups: []NvmePollGroupStats{},
+				Transports: map[string]int{},
+			},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+
+			var trailer metadata.MD
+			request := &pb.StatsNvmeSubsystemRequest{Name: testSubsystemName}
+			response, err := testEnv.client.StatsNvmeSubsystem(testEnv.ctx, request, grpc.Trailer(&trailer))
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+
+			if response.GetStats().GetReadOpsCount() != -1 || response.GetStats().GetWriteOpsCount() != -1 {
+				t.Error("volume stats: expected placeholders kept, received", response.GetStats())
+			}
+
+			values := trailer.Get(NvmeSubsystemStatsTrailerKey)
+			if len(values) != 1 {
+				t.Fatal("expected poll group stats in trailer, received", trailer)
+			}
+			stats := &NvmeSubsystemStats{}
+			if err := json.Unmarshal([]byte(values[0]), stats); err != nil {
+				t.Fatal("failed to unmarshal poll group stats", err)
+			}
+			if !reflect.DeepEqual(stats, tt.stats) {
+				t.Error("poll group stats: expected", tt.stats, "received", stats)
+			}
+		})
+	}
+}
+
+type stubJSONRRPC struct {
+	methods []string
+}
+
+// build time check that struct implements interface
+var _ spdk.JSONRPC = (*stubJSONRRPC)(nil)
+
+func (s *stubJSONRRPC) GetID() uint64 {
+	return 0
+}
+
+func (s *stubJSONRRPC) StartUnixListener() net.Listener {
+	return nil
+}
+
+func (s *stubJSONRRPC) GetVersion(_ context.Context) string {
+	return ""
+}
+
+func (s *stubJSONRRPC) Call(_ context.Context, method string, _ interface{}, result interface{}) error {
+	s.methods = append(s.methods, method)
+	if bdevs, ok := result.(*[]bdevGetBdevsResult); ok {
+		*bdevs = []bdevGetBdevsResult{{BdevGetBdevsResult: spdk.BdevGetBdevsResult{Name: "Malloc1", BlockSize: 512}}}
+	}
+	return nil
+}
+
+func TestFrontEnd_NvmeDryRun(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	psk := []byte("NVMeTLSkey-1:01:MDAxMTIyMzM0NDU1NjY3Nzg4OTlhYWJiY2NkZGVlZmZwJEiQ:")
+	tests := map[string]struct {
+		call          func(context.Context, *testEnv, grpc.CallOption) error
+		subsysBefore  bool
+		subsysAfter   bool
+		namespaceName string
+		queries       []string
+		methods       []string
+	}{
+		"create subsystem": {
+			call: func(ctx context.Context, env *testEnv, opt grpc.CallOption) error {
+				request := &pb.CreateNvmeSubsystemRequest{
+					NvmeSubsystemId: testSubsystemID,
+					NvmeSubsystem: &pb.NvmeSubsystem{Spec: &pb.NvmeSubsystemSpec{
+						Nqn:     testSubsystem.Spec.Nqn,
+						Hostnqn: "nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c",
+						Psk:     psk,
+					}},
+				}
+				response, err := env.client.CreateNvmeSubsystem(ctx, request, opt)
+				if response.GetName() != testSubsystemName {
+					t.Error("response: expected", testSubsystemName, "received", response)
+				}
+				return err
+			},
+			subsysBefore: false,
+			subsysAfter:  false,
+			methods:      []string{"nvmf_create_subsystem", "nvmf_subsystem_add_host"},
+		},
+		"delete subsystem": {
+			call: func(ctx context.Context, env *testEnv, opt grpc.CallOption) error {
+				_, err := env.client.DeleteNvmeSubsystem(ctx, &pb.DeleteNvmeSubsystemRequest{Name: testSubsystemName}, opt)
+				return err
+			},
+			subsysBefore: true,
+			subsysAfter:  true,
+			methods:      []string{"nvmf_delete_subsystem"},
+		},
+		"create namespace with auto pause": {
+			call: func(ctx context.Context, env *testEnv, opt grpc.CallOption) error {
+				request := &pb.CreateNvmeNamespaceRequest{
+					Parent:          testSubsystemName,
+					NvmeNamespaceId: testNamespaceID,
+					NvmeNamespace:   &pb.NvmeNamespace{Spec: &pb.NvmeNamespaceSpec{HostNsid: 22, VolumeNameRef: "Malloc1"}},
+				}
+				response, err := env.client.CreateNvmeNamespace(ctx, request, opt)
+				if response.GetSpec().GetHostNsid() != 22 {
+					t.Error("response: expected host nsid 22, received", response)
+				}
+				return err
+			},
+			subsysBefore:  true,
+			subsysAfter:   true,
+			namespaceName: testNamespaceName,
+			queries:       []string{"bdev_get_bdevs"},
+			methods:       []string{"nvmf_subsystem_pause", "nvmf_subsystem_add_ns", "nvmf_subsystem_resume"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+			stub := &stubJSONRRPC{}
+			testEnv.opiSpdkServer.rpc = stub
+			testEnv.opiSpdkServer.AutoPause = true
+			if tt.subsysBefore {
+				testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+			}
+
+			var header metadata.MD
+			ctx := metadata.AppendToOutgoingContext(testEnv.ctx, utils.DryRunMetadataKey, "true")
+			if err := tt.call(ctx, testEnv, grpc.Header(&header)); err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+
+			if !reflect.DeepEqual(stub.methods, tt.queries) {
+				t.Error("SPDK calls: expected", tt.queries, "received", stub.methods)
+			}
+			if _, ok := testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName]; ok != tt.subsysAfter {
+				t.Error("expect subsystem exist", tt.subsysAfter, "received", ok)
+			}
+			if _, ok := testEnv.opiSpdkServer.Nvme.Namespaces[tt.namespaceName]; ok {
+				t.Error("expected namespace not to be stored")
+			}
+			methods := []string{}
+			for _, value := range header.Get(utils.DryRunHeaderKey) {
+				var call utils.DryRunSpdkCall
+				if err := json.Unmarshal([]byte(value), &call); err != nil {
+					t.Fatal("expected no error, received", err)
+				}
+				methods = append(methods, call.Method)
+				if bytes.Contains([]byte(value), psk) {
+					t.Error("expected psk not to be reported, received", value)
+				}
+			}
+			if !reflect.DeepEqual(methods, tt.methods) {
+				t.Error("dry run calls: expected", tt.methods, "received", methods)
+			}
+		})
+	}
+}
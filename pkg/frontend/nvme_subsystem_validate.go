@@ -6,7 +6,6 @@ package frontend
 
 import (
 	"fmt"
-	"regexp"
 
 	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/resourceid"
@@ -16,41 +15,40 @@ import (
 	"google.golang.org/grpc/status"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
 
 func (s *Server) validateCreateNvmeSubsystemRequest(in *pb.CreateNvmeSubsystemRequest) error {
-	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
+	v := &utils.Validator{}
+	// check required fields, empty Nqn is generated on create if NQN base
+	// is set
+	if s.nqnBase == "" {
+		v.CheckRequiredFields(in)
+	} else {
+		v.CheckRequiredFieldsExcept(in, "nvme_subsystem.spec.nqn")
 	}
 	// see https://google.aip.dev/133#user-specified-ids
 	if in.NvmeSubsystemId != "" {
-		if err := resourceid.ValidateUserSettable(in.NvmeSubsystemId); err != nil {
-			return err
-		}
+		v.Check("nvme_subsystem_id", resourceid.ValidateUserSettable(in.NvmeSubsystemId))
 	}
-	// check Nqn length
-	if len(in.NvmeSubsystem.Spec.Nqn) > 223 {
-		msg := fmt.Sprintf("Nqn value (%s) is too long, have to be between 1 and 223", in.NvmeSubsystem.Spec.Nqn)
-		return status.Errorf(codes.InvalidArgument, msg)
+	spec := in.GetNvmeSubsystem().GetSpec()
+	if spec == nil {
+		return v.Err()
+	}
+	if spec.Nqn != "" || s.nqnBase == "" {
+		v.Check("nvme_subsystem.spec.nqn", utils.ValidateNqn(spec.Nqn))
 	}
 	// check SerialNumber length
-	if len(in.NvmeSubsystem.Spec.SerialNumber) > 20 {
-		msg := fmt.Sprintf("SerialNumber value (%s) is too long, have to be between 1 and 20", in.NvmeSubsystem.Spec.SerialNumber)
-		return status.Errorf(codes.InvalidArgument, msg)
+	if len(spec.SerialNumber) > 20 {
+		msg := fmt.Sprintf("SerialNumber value (%s) is too long, have to be between 1 and 20", spec.SerialNumber)
+		v.Check("nvme_subsystem.spec.serial_number", status.Errorf(codes.InvalidArgument, msg))
 	}
 	// check ModelNumber length
-	if len(in.NvmeSubsystem.Spec.ModelNumber) > 40 {
-		msg := fmt.Sprintf("ModelNumber value (%s) is too long, have to be between 1 and 40", in.NvmeSubsystem.Spec.ModelNumber)
-		return status.Errorf(codes.InvalidArgument, msg)
-	}
-	// check if the NQN matches the pattern
-	regex := regexp.MustCompile(`^nqn\.[0-9]{4}-[0-9]{2}(\.[a-zA-Z0-9]+)+(:[a-zA-Z0-9-.]+)+$`)
-	if !regex.MatchString(in.NvmeSubsystem.Spec.Nqn) {
-		msg := fmt.Sprintf("NQN value (%s) does not match pattern", in.NvmeSubsystem.Spec.Nqn)
-		return status.Errorf(codes.InvalidArgument, msg)
+	if len(spec.ModelNumber) > 40 {
+		msg := fmt.Sprintf("ModelNumber value (%s) is too long, have to be between 1 and 40", spec.ModelNumber)
+		v.Check("nvme_subsystem.spec.model_number", status.Errorf(codes.InvalidArgument, msg))
 	}
-	return nil
+	return v.Err()
 }
 
 func (s *Server) validateDeleteNvmeSubsystemRequest(in *pb.DeleteNvmeSubsystemRequest) error {
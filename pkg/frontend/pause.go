@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// nvmfSubsystemPauseParams are params of nvmf_subsystem_pause and
+// nvmf_subsystem_resume
+// TODO: replace once gospdk supports subsystem pause/resume
+type nvmfSubsystemPauseParams struct {
+	Nqn string `json:"nqn"`
+}
+
+// withSubsystemPaused runs mutate with subsystem nqn paused if AutoPause is
+// enabled. The subsystem is resumed even if mutate fails, so it is never
+// left paused
+func (s *Server) withSubsystemPaused(ctx context.Context, nqn string, mutate func() error) (err error) {
+	if !s.AutoPause {
+		return mutate()
+	}
+	if err := s.callSubsystemPauseRPC(ctx, "nvmf_subsystem_pause", nqn); err != nil {
+		return err
+	}
+	defer func() {
+		// resume even if ctx is done, not to leave the subsystem paused
+		cleanupCtx, cancel := utils.CleanupContext(ctx)
+		defer cancel()
+		resumeErr := s.callSubsystemPauseRPC(cleanupCtx, "nvmf_subsystem_resume", nqn)
+		if resumeErr == nil {
+			return
+		}
+		if err != nil {
+			log.Printf("error: failed to resume subsystem %v after failure %v: %v", nqn, err, resumeErr)
+			return
+		}
+		err = resumeErr
+	}()
+	return mutate()
+}
+
+func (s *Server) callSubsystemPauseRPC(ctx context.Context, method string, nqn string) error {
+	params := nvmfSubsystemPauseParams{
+		Nqn: nqn,
+	}
+	if utils.SkipSpdkCallInDryRun(ctx, method, &params) {
+		return nil
+	}
+	var result bool
+	err := s.rpc.Call(ctx, method, &params, &result)
+	if err != nil {
+		return err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	if !result {
+		msg := fmt.Sprintf("Could not %v subsystem: %s", method, nqn)
+		return status.Errorf(codes.Unavailable, msg)
+	}
+	return nil
+}
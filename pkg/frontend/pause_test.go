@@ -0,0 +1,234 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_AutoPause(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	createNamespace := func(env *testEnv) error {
+		request := &pb.CreateNvmeNamespaceRequest{
+			Parent:          testSubsystemName,
+			NvmeNamespace:   &pb.NvmeNamespace{Spec: &pb.NvmeNamespaceSpec{HostNsid: 22, VolumeNameRef: "Malloc1"}},
+			NvmeNamespaceId: "namespace-new",
+		}
+		_, err := env.client.CreateNvmeNamespace(env.ctx, request)
+		return err
+	}
+	deleteNamespace := func(env *testEnv) error {
+		_, err := env.client.DeleteNvmeNamespace(env.ctx, &pb.DeleteNvmeNamespaceRequest{Name: testNamespaceName})
+		return err
+	}
+	createController := func(env *testEnv) error {
+		request := &pb.CreateNvmeControllerRequest{
+			Parent: testSubsystemName,
+			NvmeController: &pb.NvmeController{Spec: &pb.NvmeControllerSpec{
+				Endpoint: &pb.NvmeControllerSpec_FabricsId{
+					FabricsId: &pb.FabricsEndpoint{
+						Traddr:  "127.0.0.1",
+						Trsvcid: "4421",
+						Adrfam:  pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4,
+					},
+				},
+				Trtype: pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
+			}},
+			NvmeControllerId: "controller-new",
+		}
+		_, err := env.client.CreateNvmeController(env.ctx, request)
+		return err
+	}
+	deleteController := func(env *testEnv) error {
+		_, err := env.client.DeleteNvmeController(env.ctx, &pb.DeleteNvmeControllerRequest{Name: testControllerName})
+		return err
+	}
+
+	tests := map[string]struct {
+		autoPause bool
+		call      func(env *testEnv) error
+		spdk      []string
+		methods   []string
+		errCode   codes.Code
+		errMsg    string
+	}{
+		"namespace add is bracketed": {
+			autoPause: true,
+			call:      createNamespace,
+			spdk: []string{
+				testVolumeBdev,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":22}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			methods: []string{"bdev_get_bdevs", "nvmf_subsystem_pause", "nvmf_subsystem_add_ns", "nvmf_subsystem_resume"},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"namespace remove is bracketed": {
+			autoPause: true,
+			call:      deleteNamespace,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			methods: []string{"nvmf_subsystem_pause", "nvmf_subsystem_remove_ns", "nvmf_subsystem_resume"},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"listener add is bracketed": {
+			autoPause: true,
+			call:      createController,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			methods: []string{"nvmf_subsystem_pause", "nvmf_subsystem_add_listener", "nvmf_subsystem_resume"},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"listener remove is bracketed": {
+			autoPause: true,
+			call:      deleteController,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			methods: []string{"nvmf_subsystem_pause", "nvmf_subsystem_remove_listener", "nvmf_subsystem_resume"},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"resume runs on failed mutation": {
+			autoPause: true,
+			call:      createNamespace,
+			spdk: []string{
+				testVolumeBdev,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":0}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			methods: []string{"bdev_get_bdevs", "nvmf_subsystem_pause", "nvmf_subsystem_add_ns", "nvmf_subsystem_resume"},
+			errCode: codes.Unavailable,
+			errMsg:  fmt.Sprintf("nvmf_subsystem_add_ns: %v", "json response error: myopierr"),
+		},
+		"resume runs on rejected mutation": {
+			autoPause: true,
+			call:      deleteNamespace,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":false}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			methods: []string{"nvmf_subsystem_pause", "nvmf_subsystem_remove_ns", "nvmf_subsystem_resume"},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not delete NS: %v", testNamespaceName),
+		},
+		"failed pause skips mutation": {
+			autoPause: true,
+			call:      createController,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":false}`,
+			},
+			methods: []string{"nvmf_subsystem_pause"},
+			errCode: codes.Unavailable,
+			errMsg:  fmt.Sprintf("Could not nvmf_subsystem_pause subsystem: %v", testSubsystem.Spec.Nqn),
+		},
+		"failed resume is reported": {
+			autoPause: true,
+			call:      createNamespace,
+			spdk: []string{
+				testVolumeBdev,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":22}`,
+				`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":false}`,
+			},
+			methods: []string{"bdev_get_bdevs", "nvmf_subsystem_pause", "nvmf_subsystem_add_ns", "nvmf_subsystem_resume"},
+			errCode: codes.Unavailable,
+			errMsg:  fmt.Sprintf("nvmf_subsystem_resume: %v", "json response error: myopierr"),
+		},
+		"auto pause disabled": {
+			autoPause: false,
+			call:      createNamespace,
+			spdk: []string{
+				testVolumeBdev,
+				`{"id":%d,"error":{"code":0,"message":""},"result":22}`,
+			},
+			methods: []string{"bdev_get_bdevs", "nvmf_subsystem_add_ns"},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+			testEnv.opiSpdkServer.Nvme.transports[pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP] = NewNvmeTCPTransport(recorder)
+			testEnv.opiSpdkServer.AutoPause = tt.autoPause
+
+			testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = utils.ProtoClone(&testSubsystem)
+			testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = utils.ProtoClone(&testNamespace)
+			testEnv.opiSpdkServer.Nvme.Controllers[testControllerName] = utils.ProtoClone(&testController)
+			testEnv.opiSpdkServer.Nvme.Controllers[testControllerName].Name = testControllerName
+
+			err := tt.call(testEnv)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if !reflect.DeepEqual(recorder.methods, tt.methods) {
+				t.Error("methods: expected", tt.methods, "received", recorder.methods)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_AutoPauseResumeAfterCancel(t *testing.T) {
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+	})
+	defer testEnv.Close()
+	recorder := testEnv.recordSpdkParams()
+	testEnv.opiSpdkServer.rpc = utils.NewTimeoutJSONRPC(recorder, 0)
+	testEnv.opiSpdkServer.AutoPause = true
+	ctx, cancel := context.WithCancel(testEnv.ctx)
+	defer cancel()
+	canceled := status.Error(codes.Canceled, "client canceled")
+
+	err := testEnv.opiSpdkServer.withSubsystemPaused(ctx, testSubsystem.Spec.Nqn, func() error {
+		cancel()
+		return canceled
+	})
+
+	if err != canceled {
+		t.Error("error: expected", canceled, "received", err)
+	}
+	methods := []string{"nvmf_subsystem_pause", "nvmf_subsystem_resume"}
+	if !reflect.DeepEqual(recorder.methods, methods) {
+		t.Error("methods: expected", methods, "received", recorder.methods)
+	}
+}
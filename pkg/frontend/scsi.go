@@ -9,7 +9,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"path"
 	"sort"
 
 	"github.com/opiproject/gospdk/spdk"
@@ -39,19 +38,25 @@ func (s *Server) CreateVirtioScsiController(ctx context.Context, in *pb.CreateVi
 		return nil, err
 	}
 	// see https://google.aip.dev/133#user-specified-ids
-	resourceID := resourceid.NewSystemGenerated()
 	if in.VirtioScsiControllerId != "" {
 		err := resourceid.ValidateUserSettable(in.VirtioScsiControllerId)
 		if err != nil {
 			return nil, err
 		}
 		log.Printf("client provided the ID of a resource %v, ignoring the name field %v", in.VirtioScsiControllerId, in.VirtioScsiController.Name)
-		resourceID = in.VirtioScsiControllerId
+	}
+	resourceID, err := s.idempotencyKeys.ResourceID(ctx, "VirtioScsiController", in.VirtioScsiControllerId)
+	if err != nil {
+		return nil, err
 	}
 	in.VirtioScsiController.Name = utils.ResourceIDToVolumeName(resourceID)
 
+	unlock := s.resourceLocks.Lock(in.VirtioScsiController.Name)
+	defer unlock()
 	// idempotent API when called with same key, should return same object
+	s.mapsMu.RLock()
 	controller, ok := s.Virt.ScsiCtrls[in.VirtioScsiController.Name]
+	s.mapsMu.RUnlock()
 	if ok {
 		log.Printf("Already existing VirtioScsiController with id %v", in.VirtioScsiController.Name)
 		return controller, nil
@@ -60,8 +65,11 @@ func (s *Server) CreateVirtioScsiController(ctx context.Context, in *pb.CreateVi
 	params := spdk.VhostCreateScsiControllerParams{
 		Ctrlr: resourceID,
 	}
+	if utils.SkipSpdkCallInDryRun(ctx, "vhost_create_scsi_controller", &params) {
+		return utils.ProtoClone(in.VirtioScsiController), nil
+	}
 	var result spdk.VhostCreateScsiControllerResult
-	err := s.rpc.Call(ctx, "vhost_create_scsi_controller", &params, &result)
+	err = s.rpc.Call(ctx, "vhost_create_scsi_controller", &params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +79,9 @@ func (s *Server) CreateVirtioScsiController(ctx context.Context, in *pb.CreateVi
 	}
 	response := utils.ProtoClone(in.VirtioScsiController)
 	// response.Status = &pb.VirtioScsiControllerStatus{Active: true}
+	s.mapsMu.Lock()
 	s.Virt.ScsiCtrls[in.VirtioScsiController.Name] = response
+	s.mapsMu.Unlock()
 	return response, nil
 }
 
@@ -85,8 +95,12 @@ func (s *Server) DeleteVirtioScsiController(ctx context.Context, in *pb.DeleteVi
 	if err := resourcename.Validate(in.Name); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	controller, ok := s.Virt.ScsiCtrls[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			return &emptypb.Empty{}, nil
@@ -94,10 +108,13 @@ func (s *Server) DeleteVirtioScsiController(ctx context.Context, in *pb.DeleteVi
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(controller.Name)
+	resourceID := utils.ResourceNameToID(controller.Name)
 	params := spdk.VhostDeleteControllerParams{
 		Ctrlr: resourceID,
 	}
+	if utils.SkipSpdkCallInDryRun(ctx, "vhost_delete_controller", &params) {
+		return &emptypb.Empty{}, nil
+	}
 	var result spdk.VhostDeleteControllerResult
 	err := s.rpc.Call(ctx, "vhost_delete_controller", &params, &result)
 	if err != nil {
@@ -107,7 +124,9 @@ func (s *Server) DeleteVirtioScsiController(ctx context.Context, in *pb.DeleteVi
 	if !result {
 		log.Printf("Could not delete: %v", in)
 	}
+	s.mapsMu.Lock()
 	delete(s.Virt.ScsiCtrls, controller.Name)
+	s.mapsMu.Unlock()
 	return &emptypb.Empty{}, nil
 }
 
@@ -121,8 +140,12 @@ func (s *Server) UpdateVirtioScsiController(_ context.Context, in *pb.UpdateVirt
 	if err := resourcename.Validate(in.VirtioScsiController.Name); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.VirtioScsiController.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Virt.ScsiCtrls[in.VirtioScsiController.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			log.Printf("TODO: in case of AllowMissing, create a new resource, don;t return error")
@@ -130,7 +153,7 @@ func (s *Server) UpdateVirtioScsiController(_ context.Context, in *pb.UpdateVirt
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.VirtioScsiController.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	// update_mask = 2
 	if err := fieldmask.Validate(in.UpdateMask, in.VirtioScsiController); err != nil {
 		return nil, err
@@ -161,13 +184,14 @@ func (s *Server) ListVirtioScsiControllers(ctx context.Context, in *pb.ListVirti
 	result, hasMoreElements := utils.LimitPagination(result, offset, size)
 	if hasMoreElements {
 		token = uuid.New().String()
-		s.Pagination[token] = offset + size
+		s.Pagination.Set(token, offset+size)
 	}
 	Blobarray := make([]*pb.VirtioScsiController, len(result))
 	for i := range result {
 		r := &result[i]
 		Blobarray[i] = &pb.VirtioScsiController{Name: utils.ResourceIDToVolumeName(r.Ctrlr)}
 	}
+	Blobarray, token = utils.LimitPaginationBySize(Blobarray, offset, token, s.Pagination)
 	sortScsiControllers(Blobarray)
 	return &pb.ListVirtioScsiControllersResponse{VirtioScsiControllers: Blobarray, NextPageToken: token}, nil
 }
@@ -183,12 +207,14 @@ func (s *Server) GetVirtioScsiController(ctx context.Context, in *pb.GetVirtioSc
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Virt.ScsiCtrls[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	params := spdk.VhostGetControllersParams{
 		Name: resourceID,
 	}
@@ -216,17 +242,20 @@ func (s *Server) StatsVirtioScsiController(_ context.Context, in *pb.StatsVirtio
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Virt.ScsiCtrls[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	log.Printf("TODO: send name to SPDK and get back stats: %v", resourceID)
 	return &pb.StatsVirtioScsiControllerResponse{}, nil
 }
 
-// CreateVirtioScsiLun creates a Virtio SCSI LUN
+// CreateVirtioScsiLun attaches volume as a LUN to a target of Virtio SCSI
+// controller referenced by target_name_ref
 func (s *Server) CreateVirtioScsiLun(ctx context.Context, in *pb.CreateVirtioScsiLunRequest) (*pb.VirtioScsiLun, error) {
 	// check required fields
 	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
@@ -236,47 +265,91 @@ func (s *Server) CreateVirtioScsiLun(ctx context.Context, in *pb.CreateVirtioScs
 	if err := resourcename.Validate(in.VirtioScsiLun.VolumeNameRef); err != nil {
 		return nil, err
 	}
+	if err := resourcename.Validate(in.VirtioScsiLun.TargetNameRef); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid target_name_ref: %v", err)
+	}
+	requestedTarget, err := virtioScsiLunTargetFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	// see https://google.aip.dev/133#user-specified-ids
-	resourceID := resourceid.NewSystemGenerated()
 	if in.VirtioScsiLunId != "" {
 		err := resourceid.ValidateUserSettable(in.VirtioScsiLunId)
 		if err != nil {
 			return nil, err
 		}
 		log.Printf("client provided the ID of a resource %v, ignoring the name field %v", in.VirtioScsiLunId, in.VirtioScsiLun.Name)
-		resourceID = in.VirtioScsiLunId
+	}
+	resourceID, err := s.idempotencyKeys.ResourceID(ctx, "VirtioScsiLun", in.VirtioScsiLunId)
+	if err != nil {
+		return nil, err
 	}
 	in.VirtioScsiLun.Name = utils.ResourceIDToVolumeName(resourceID)
 
+	unlock := s.resourceLocks.Lock(in.VirtioScsiLun.Name)
+	defer unlock()
 	// idempotent API when called with same key, should return same object
+	s.mapsMu.RLock()
 	lun, ok := s.Virt.ScsiLuns[in.VirtioScsiLun.Name]
+	s.mapsMu.RUnlock()
 	if ok {
 		log.Printf("Already existing VirtioScsiLun with id %v", in.VirtioScsiLun.Name)
+		mapping, found := s.virtioScsiLun(lun.Name)
+		sendVirtioScsiLunTarget(ctx, mapping, found)
 		return lun, nil
 	}
+	// targets of controller are picked under its lock
+	unlockController := s.resourceLocks.Lock(in.VirtioScsiLun.TargetNameRef)
+	defer unlockController()
+	s.mapsMu.RLock()
+	_, ok = s.Virt.ScsiCtrls[in.VirtioScsiLun.TargetNameRef]
+	s.mapsMu.RUnlock()
+	if !ok {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.VirtioScsiLun.TargetNameRef)
+		return nil, err
+	}
+	target, err := s.virtioScsiLunTarget(in.VirtioScsiLun.Name, in.VirtioScsiLun.TargetNameRef, requestedTarget)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.getScsiLunVolumeBdev(ctx, in.VirtioScsiLun.Name, in.VirtioScsiLun.VolumeNameRef); err != nil {
+		return nil, err
+	}
 	// not found, so create a new one
 	params := struct {
 		Name string `json:"ctrlr"`
 		Num  int    `json:"scsi_target_num"`
 		Bdev string `json:"bdev_name"`
 	}{
-		Name: resourceID,
-		Num:  5,
+		Name: utils.ResourceNameToID(in.VirtioScsiLun.TargetNameRef),
+		Num:  target,
 		Bdev: in.VirtioScsiLun.VolumeNameRef,
 	}
+	if utils.SkipSpdkCallInDryRun(ctx, "vhost_scsi_controller_add_target", &params) {
+		return utils.ProtoClone(in.VirtioScsiLun), nil
+	}
 	var result int
-	err := s.rpc.Call(ctx, "vhost_scsi_controller_add_target", &params, &result)
+	err = s.rpc.Call(ctx, "vhost_scsi_controller_add_target", &params, &result)
 	if err != nil {
 		return nil, err
 	}
 	log.Printf("Received from SPDK: %v", result)
+	if result != target {
+		msg := fmt.Sprintf("Could not create LUN: %s, SPDK used target %d instead of %d", in.VirtioScsiLun.Name, result, target)
+		return nil, status.Errorf(codes.Internal, msg)
+	}
 	response := utils.ProtoClone(in.VirtioScsiLun)
 	// response.Status = &pb.VirtioScsiLunStatus{Active: true}
+	s.mapsMu.Lock()
 	s.Virt.ScsiLuns[in.VirtioScsiLun.Name] = response
+	s.mapsMu.Unlock()
+	mapping := virtioScsiLun{controller: in.VirtioScsiLun.TargetNameRef, target: target}
+	s.setVirtioScsiLun(in.VirtioScsiLun.Name, mapping)
+	sendVirtioScsiLunTarget(ctx, mapping, true)
 	return response, nil
 }
 
-// DeleteVirtioScsiLun deletes a Virtio SCSI LUN
+// DeleteVirtioScsiLun detaches a Virtio SCSI LUN from target of its controller
 func (s *Server) DeleteVirtioScsiLun(ctx context.Context, in *pb.DeleteVirtioScsiLunRequest) (*emptypb.Empty, error) {
 	// check required fields
 	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
@@ -286,8 +359,12 @@ func (s *Server) DeleteVirtioScsiLun(ctx context.Context, in *pb.DeleteVirtioScs
 	if err := resourcename.Validate(in.Name); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	lun, ok := s.Virt.ScsiLuns[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			return &emptypb.Empty{}, nil
@@ -295,13 +372,22 @@ func (s *Server) DeleteVirtioScsiLun(ctx context.Context, in *pb.DeleteVirtioScs
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(lun.Name)
+	mapping, ok := s.virtioScsiLun(lun.Name)
+	if !ok {
+		msg := fmt.Sprintf("Could not delete LUN: %s, its SCSI target is unknown", lun.Name)
+		return nil, status.Errorf(codes.Internal, msg)
+	}
+	unlockController := s.resourceLocks.Lock(mapping.controller)
+	defer unlockController()
 	params := struct {
 		Name string `json:"ctrlr"`
 		Num  int    `json:"scsi_target_num"`
 	}{
-		Name: resourceID,
-		Num:  5,
+		Name: utils.ResourceNameToID(mapping.controller),
+		Num:  mapping.target,
+	}
+	if utils.SkipSpdkCallInDryRun(ctx, "vhost_scsi_controller_remove_target", &params) {
+		return &emptypb.Empty{}, nil
 	}
 	var result bool
 	err := s.rpc.Call(ctx, "vhost_scsi_controller_remove_target", &params, &result)
@@ -312,7 +398,10 @@ func (s *Server) DeleteVirtioScsiLun(ctx context.Context, in *pb.DeleteVirtioScs
 	if !result {
 		log.Printf("Could not delete: %v", in)
 	}
+	s.mapsMu.Lock()
 	delete(s.Virt.ScsiLuns, lun.Name)
+	s.mapsMu.Unlock()
+	s.clearVirtioScsiLun(lun.Name)
 	return &emptypb.Empty{}, nil
 }
 
@@ -326,8 +415,12 @@ func (s *Server) UpdateVirtioScsiLun(_ context.Context, in *pb.UpdateVirtioScsiL
 	if err := resourcename.Validate(in.VirtioScsiLun.Name); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.VirtioScsiLun.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Virt.ScsiLuns[in.VirtioScsiLun.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			log.Printf("TODO: in case of AllowMissing, create a new resource, don;t return error")
@@ -335,7 +428,7 @@ func (s *Server) UpdateVirtioScsiLun(_ context.Context, in *pb.UpdateVirtioScsiL
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.VirtioScsiLun.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	// update_mask = 2
 	if err := fieldmask.Validate(in.UpdateMask, in.VirtioScsiLun); err != nil {
 		return nil, err
@@ -366,7 +459,7 @@ func (s *Server) ListVirtioScsiLuns(ctx context.Context, in *pb.ListVirtioScsiLu
 	result, hasMoreElements := utils.LimitPagination(result, offset, size)
 	if hasMoreElements {
 		token = uuid.New().String()
-		s.Pagination[token] = offset + size
+		s.Pagination.Set(token, offset+size)
 	}
 	Blobarray := make([]*pb.VirtioScsiLun, len(result))
 	for i := range result {
@@ -375,6 +468,7 @@ func (s *Server) ListVirtioScsiLuns(ctx context.Context, in *pb.ListVirtioScsiLu
 			VolumeNameRef: utils.ResourceIDToVolumeName(r.Ctrlr),
 		}
 	}
+	Blobarray, token = utils.LimitPaginationBySize(Blobarray, offset, token, s.Pagination)
 	return &pb.ListVirtioScsiLunsResponse{VirtioScsiLuns: Blobarray, NextPageToken: token}, nil
 }
 
@@ -389,12 +483,16 @@ func (s *Server) GetVirtioScsiLun(ctx context.Context, in *pb.GetVirtioScsiLunRe
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Virt.ScsiLuns[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	mapping, found := s.virtioScsiLun(volume.Name)
+	sendVirtioScsiLunTarget(ctx, mapping, found)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	params := spdk.VhostGetControllersParams{
 		Name: resourceID,
 	}
@@ -422,12 +520,14 @@ func (s *Server) StatsVirtioScsiLun(_ context.Context, in *pb.StatsVirtioScsiLun
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.Virt.ScsiLuns[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	log.Printf("TODO: send name to SPDK and get back stats: %v", resourceID)
 	return &pb.StatsVirtioScsiLunResponse{}, nil
 }
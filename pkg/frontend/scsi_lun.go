@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/opiproject/gospdk/spdk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// VirtioScsiLunTargetMetadataKey is metadata key carrying SCSI target number
+// of LUN within its virtio-scsi controller, since VirtioScsiLun has no such
+// field. Set by client on create, the lowest free target is used if omitted.
+// Returned by server in header of Create and Get calls
+const VirtioScsiLunTargetMetadataKey = "opi-virtio-scsi-target-num"
+
+// maxVirtioScsiTargets is SPDK_VHOST_SCSI_CTRLR_MAX_DEVS, number of targets
+// of virtio-scsi controller
+const maxVirtioScsiTargets = 8
+
+// virtioScsiLun is mapping of LUN to target of its controller kept in the
+// store, since VirtioScsiLun has no target number
+type virtioScsiLun struct {
+	controller string
+	target     int
+}
+
+// virtioScsiLunTargetFromContext returns target number requested by client
+// or -1 if omitted
+func virtioScsiLunTargetFromContext(ctx context.Context) (int, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(VirtioScsiLunTargetMetadataKey)
+	if len(values) == 0 {
+		return -1, nil
+	}
+	target, err := strconv.Atoi(values[0])
+	if err != nil || target < 0 || target >= maxVirtioScsiTargets {
+		msg := fmt.Sprintf("invalid SCSI target number %q, must be 0 to %d", values[0], maxVirtioScsiTargets-1)
+		return 0, status.Errorf(codes.InvalidArgument, msg)
+	}
+	return target, nil
+}
+
+// virtioScsiLunTarget picks target of controller for LUN name. Requested
+// target must not be used by another LUN, otherwise the lowest free target
+// is picked
+func (s *Server) virtioScsiLunTarget(name string, controller string, requested int) (int, error) {
+	s.mapsMu.RLock()
+	var lunNames []string
+	for lunName, lun := range s.Virt.ScsiLuns {
+		if lun.TargetNameRef == controller {
+			lunNames = append(lunNames, lunName)
+		}
+	}
+	s.mapsMu.RUnlock()
+	used := make(map[int]string)
+	for _, lunName := range lunNames {
+		if mapping, ok := s.virtioScsiLun(lunName); ok {
+			used[mapping.target] = lunName
+		}
+	}
+	if requested >= 0 {
+		if lunName, ok := used[requested]; ok {
+			msg := fmt.Sprintf("SCSI target %d of %s is already used by %s", requested, controller, lunName)
+			return 0, status.Errorf(codes.AlreadyExists, msg)
+		}
+		return requested, nil
+	}
+	for target := 0; target < maxVirtioScsiTargets; target++ {
+		if _, ok := used[target]; !ok {
+			return target, nil
+		}
+	}
+	msg := fmt.Sprintf("no free SCSI target of %s for %s, all %d are used", controller, name, maxVirtioScsiTargets)
+	return 0, status.Errorf(codes.ResourceExhausted, msg)
+}
+
+// getScsiLunVolumeBdev checks bdev backing volume referenced by LUN exists
+// or returns FailedPrecondition
+func (s *Server) getScsiLunVolumeBdev(ctx context.Context, name string, volume string) error {
+	params := spdk.BdevGetBdevsParams{
+		Name: volume,
+	}
+	var result []bdevGetBdevsResult
+	err := s.rpc.Call(ctx, "bdev_get_bdevs", &params, &result)
+	if err != nil && !strings.Contains(err.Error(), spdkNoSuchDeviceError) {
+		return err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	if err != nil || len(result) == 0 {
+		msg := fmt.Sprintf("volume %s referenced by LUN %s does not exist", volume, name)
+		return status.Errorf(codes.FailedPrecondition, msg)
+	}
+	return nil
+}
+
+func virtioScsiLunStoreKey(name string) string {
+	return "scsi-lun/" + name
+}
+
+// setVirtioScsiLun records controller and target of LUN in the store
+func (s *Server) setVirtioScsiLun(name string, lun virtioScsiLun) {
+	value := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"controller": structpb.NewStringValue(lun.controller),
+		"target":     structpb.NewNumberValue(float64(lun.target)),
+	}}
+	if err := s.store.Set(virtioScsiLunStoreKey(name), value); err != nil {
+		log.Printf("error: failed to store SCSI target of %v: %v", name, err)
+	}
+}
+
+// virtioScsiLun returns controller and target of LUN kept in the store
+func (s *Server) virtioScsiLun(name string) (virtioScsiLun, bool) {
+	value := &structpb.Struct{}
+	found, err := s.store.Get(virtioScsiLunStoreKey(name), value)
+	if err != nil {
+		log.Printf("error: failed to load SCSI target of %v: %v", name, err)
+	}
+	if !found || err != nil {
+		return virtioScsiLun{}, false
+	}
+	return virtioScsiLun{
+		controller: value.Fields["controller"].GetStringValue(),
+		target:     int(value.Fields["target"].GetNumberValue()),
+	}, true
+}
+
+// clearVirtioScsiLun forgets controller and target of a deleted LUN
+func (s *Server) clearVirtioScsiLun(name string) {
+	if err := s.store.Delete(virtioScsiLunStoreKey(name)); err != nil {
+		log.Printf("error: failed to delete SCSI target of %v: %v", name, err)
+	}
+}
+
+func sendVirtioScsiLunTarget(ctx context.Context, lun virtioScsiLun, found bool) {
+	if !found {
+		return
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(VirtioScsiLunTargetMetadataKey, strconv.Itoa(lun.target))); err != nil {
+		log.Printf("error: failed to send SCSI target: %v", err)
+	}
+}
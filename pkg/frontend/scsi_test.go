@@ -5,7 +5,29 @@
 package frontend
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+var (
+	testScsiCtrlID      = "scsi-ctrl-test"
+	testScsiCtrlName    = utils.ResourceIDToVolumeName(testScsiCtrlID)
+	testVirtioScsiLunID = "scsi-lun-test"
+	testVirtioScsiLun   = pb.VirtioScsiLun{
+		TargetNameRef: testScsiCtrlName,
+		VolumeNameRef: "Malloc1",
+	}
+	testVirtioScsiLunName = utils.ResourceIDToVolumeName(testVirtioScsiLunID)
 )
 
 func TestFrontEnd_CreateVirtioScsiController(_ *testing.T) {
@@ -32,12 +54,219 @@ func TestFrontEnd_StatsVirtioScsiController(_ *testing.T) {
 
 }
 
-func TestFrontEnd_CreateVirtioScsiLun(_ *testing.T) {
+func TestFrontEnd_CreateVirtioScsiLun(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	otherLunName := utils.ResourceIDToVolumeName("scsi-lun-other")
+	tests := map[string]struct {
+		target   string
+		existing map[string]int
+		noCtrl   bool
+		spdk     []string
+		out      *pb.VirtioScsiLun
+		outNum   int
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"target provided": {
+			target:   "3",
+			existing: map[string]int{},
+			spdk:     []string{testVolumeBdev, `{"id":%d,"error":{"code":0,"message":""},"result":3}`},
+			out:      &testVirtioScsiLun,
+			outNum:   3,
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"lowest free target picked": {
+			target:   "",
+			existing: map[string]int{otherLunName: 0},
+			spdk:     []string{testVolumeBdev, `{"id":%d,"error":{"code":0,"message":""},"result":1}`},
+			out:      &testVirtioScsiLun,
+			outNum:   1,
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"duplicate target": {
+			target:   "3",
+			existing: map[string]int{otherLunName: 3},
+			spdk:     []string{},
+			out:      nil,
+			errCode:  codes.AlreadyExists,
+			errMsg:   fmt.Sprintf("SCSI target %d of %s is already used by %s", 3, testScsiCtrlName, otherLunName),
+		},
+		"target out of range": {
+			target:   "8",
+			existing: map[string]int{},
+			spdk:     []string{},
+			out:      nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("invalid SCSI target number %q, must be 0 to %d", "8", 7),
+		},
+		"negative target": {
+			target:   "-1",
+			existing: map[string]int{},
+			spdk:     []string{},
+			out:      nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("invalid SCSI target number %q, must be 0 to %d", "-1", 7),
+		},
+		"unknown controller": {
+			target:   "",
+			existing: map[string]int{},
+			noCtrl:   true,
+			spdk:     []string{},
+			out:      nil,
+			errCode:  codes.NotFound,
+			errMsg:   fmt.Sprintf("unable to find key %s", testScsiCtrlName),
+		},
+		"unknown volume": {
+			target:   "",
+			existing: map[string]int{},
+			spdk:     []string{`{"id":%d,"error":{"code":-19,"message":"No such device"},"result":null}`},
+			out:      nil,
+			errCode:  codes.FailedPrecondition,
+			errMsg:   fmt.Sprintf("volume %s referenced by LUN %s does not exist", "Malloc1", testVirtioScsiLunName),
+		},
+		"valid request with error code from SPDK response": {
+			target:   "",
+			existing: map[string]int{},
+			spdk:     []string{testVolumeBdev, `{"id":%d,"error":{"code":1,"message":"myopierr"},"result":null}`},
+			out:      nil,
+			errCode:  codes.Unknown,
+			errMsg:   fmt.Sprintf("vhost_scsi_controller_add_target: %v", "json response error: myopierr"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+			if !tt.noCtrl {
+				testEnv.opiSpdkServer.Virt.ScsiCtrls[testScsiCtrlName] = &pb.VirtioScsiController{Name: testScsiCtrlName}
+			}
+			for lunName, target := range tt.existing {
+				testEnv.opiSpdkServer.Virt.ScsiLuns[lunName] = &pb.VirtioScsiLun{Name: lunName, TargetNameRef: testScsiCtrlName, VolumeNameRef: "Malloc0"}
+				testEnv.opiSpdkServer.setVirtioScsiLun(lunName, virtioScsiLun{controller: testScsiCtrlName, target: target})
+			}
 
+			ctx := testEnv.ctx
+			if tt.target != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, VirtioScsiLunTargetMetadataKey, tt.target)
+			}
+			var header metadata.MD
+			request := &pb.CreateVirtioScsiLunRequest{VirtioScsiLun: &testVirtioScsiLun, VirtioScsiLunId: testVirtioScsiLunID}
+			response, err := testEnv.client.CreateVirtioScsiLun(ctx, request, grpc.Header(&header))
+
+			if tt.out != nil {
+				expected := utils.ProtoClone(tt.out)
+				expected.Name = testVirtioScsiLunName
+				if !proto.Equal(response, expected) {
+					t.Error("response: expected", expected, "received", response)
+				}
+				params := fmt.Sprintf(`{"ctrlr":"%s","scsi_target_num":%d,"bdev_name":"Malloc1"}`, testScsiCtrlID, tt.outNum)
+				if len(recorder.params) != 2 || recorder.params[1] != params {
+					t.Error("params: expected", params, "received", recorder.params)
+				}
+				values := header.Get(VirtioScsiLunTargetMetadataKey)
+				if len(values) != 1 || values[0] != fmt.Sprint(tt.outNum) {
+					t.Error("target header: expected", tt.outNum, "received", values)
+				}
+				lun, ok := testEnv.opiSpdkServer.virtioScsiLun(testVirtioScsiLunName)
+				if expected := (virtioScsiLun{controller: testScsiCtrlName, target: tt.outNum}); !ok || !reflect.DeepEqual(lun, expected) {
+					t.Error("stored LUN: expected", expected, "received", lun, ok)
+				}
+			} else if response != nil {
+				t.Error("response: expected nil, received", response)
+			}
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+		})
+	}
 }
 
-func TestFrontEnd_DeleteVirtioScsiLun(_ *testing.T) {
+func TestFrontEnd_DeleteVirtioScsiLun(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		in      string
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request": {
+			in:      testVirtioScsiLunName,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with error code from SPDK response": {
+			in:      testVirtioScsiLunName,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":false}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("vhost_scsi_controller_remove_target: %v", "json response error: myopierr"),
+		},
+		"valid request with unknown key": {
+			in:      utils.ResourceIDToVolumeName("unknown-lun-id"),
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToVolumeName("unknown-lun-id")),
+		},
+		"unknown key with missing allowed": {
+			in:      utils.ResourceIDToVolumeName("unknown-lun-id"),
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+			lun := utils.ProtoClone(&testVirtioScsiLun)
+			lun.Name = testVirtioScsiLunName
+			testEnv.opiSpdkServer.Virt.ScsiLuns[testVirtioScsiLunName] = lun
+			testEnv.opiSpdkServer.setVirtioScsiLun(testVirtioScsiLunName, virtioScsiLun{controller: testScsiCtrlName, target: 3})
+
+			request := &pb.DeleteVirtioScsiLunRequest{Name: tt.in, AllowMissing: tt.missing}
+			_, err := testEnv.client.DeleteVirtioScsiLun(testEnv.ctx, request)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
 
+			if len(tt.spdk) > 0 {
+				params := []string{fmt.Sprintf(`{"ctrlr":"%s","scsi_target_num":3}`, testScsiCtrlID)}
+				if !reflect.DeepEqual(recorder.params, params) {
+					t.Error("params: expected", params, "received", recorder.params)
+				}
+			}
+			_, stored := testEnv.opiSpdkServer.virtioScsiLun(testVirtioScsiLunName)
+			_, ok := testEnv.opiSpdkServer.Virt.ScsiLuns[testVirtioScsiLunName]
+			deleted := tt.in == testVirtioScsiLunName && tt.errCode == codes.OK
+			if ok == deleted || stored == deleted {
+				t.Error("expected LUN deleted", deleted, "received present", ok, "stored", stored)
+			}
+		})
+	}
 }
 
 func TestFrontEnd_UpdateVirtioScsiLun(_ *testing.T) {
@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_StateRoundTrip(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	spdkSubsystems := `{"id":%d,"error":{"code":0,"message":""},"result":[{"nqn":"nqn.2022-09.io.spdk:opi3","serial_number":"OpiSerialNumber3","model_number":"OpiModelNumber3"}]}`
+	testEnv := createTestEnvironment([]string{spdkSubsystems})
+	defer testEnv.Close()
+	subsystem := utils.ProtoClone(&testSubsystem)
+	subsystem.Name = testSubsystemName
+	testEnv.opiSpdkServer.Nvme.Subsystems[testSubsystemName] = subsystem
+	controller := utils.ProtoClone(&testController)
+	controller.Name = testControllerName
+	testEnv.opiSpdkServer.Nvme.Controllers[testControllerName] = controller
+	namespace := utils.ProtoClone(&testNamespace)
+	namespace.Name = testNamespaceName
+	testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = namespace
+	virtioBlk := utils.ProtoClone(&testVirtioCtrl)
+	virtioBlk.Name = testVirtioCtrlName
+	testEnv.opiSpdkServer.Virt.BlkCtrls[testVirtioCtrlName] = virtioBlk
+
+	state, err := utils.NewStateServer(testEnv.opiSpdkServer).ExportState(testEnv.ctx, &emptypb.Empty{})
+	if err != nil {
+		t.Fatal("expected no error on export, received", err)
+	}
+
+	importEnv := createTestEnvironment([]string{spdkSubsystems})
+	defer importEnv.Close()
+	if _, err := utils.NewStateServer(importEnv.opiSpdkServer).ImportState(importEnv.ctx, state); err != nil {
+		t.Fatal("expected no error on import, received", err)
+	}
+
+	for _, server := range []*Server{testEnv.opiSpdkServer, importEnv.opiSpdkServer} {
+		if !proto.Equal(server.Nvme.Subsystems[testSubsystemName], subsystem) {
+			t.Error("expected subsystem", subsystem, "received", server.Nvme.Subsystems[testSubsystemName])
+		}
+		if !proto.Equal(server.Nvme.Namespaces[testNamespaceName], namespace) {
+			t.Error("expected namespace", namespace, "received", server.Nvme.Namespaces[testNamespaceName])
+		}
+		if !proto.Equal(server.Virt.BlkCtrls[testVirtioCtrlName], virtioBlk) {
+			t.Error("expected virtio-blk", virtioBlk, "received", server.Virt.BlkCtrls[testVirtioCtrlName])
+		}
+	}
+
+	exportedSubsystem, err := testEnv.client.GetNvmeSubsystem(testEnv.ctx, &pb.GetNvmeSubsystemRequest{Name: testSubsystemName})
+	if err != nil {
+		t.Fatal("expected no error on get, received", err)
+	}
+	importedSubsystem, err := importEnv.client.GetNvmeSubsystem(importEnv.ctx, &pb.GetNvmeSubsystemRequest{Name: testSubsystemName})
+	if err != nil {
+		t.Fatal("expected no error on get after import, received", err)
+	}
+	if !proto.Equal(importedSubsystem, exportedSubsystem) {
+		t.Error("subsystem: expected", exportedSubsystem, "received", importedSubsystem)
+	}
+
+	exportedController, err := testEnv.client.GetNvmeController(testEnv.ctx, &pb.GetNvmeControllerRequest{Name: testControllerName})
+	if err != nil {
+		t.Fatal("expected no error on get, received", err)
+	}
+	importedController, err := importEnv.client.GetNvmeController(importEnv.ctx, &pb.GetNvmeControllerRequest{Name: testControllerName})
+	if err != nil {
+		t.Fatal("expected no error on get after import, received", err)
+	}
+	if !proto.Equal(importedController, exportedController) {
+		t.Error("controller: expected", exportedController, "received", importedController)
+	}
+}
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/opiproject/gospdk/spdk"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Policies applied when SPDK iostat has no entry for requested resource
+const (
+	// EmptyStatsNoData returns zero stats and sets StatsNoDataHeaderKey
+	EmptyStatsNoData = "no-data"
+	// EmptyStatsNotFound fails the call with codes.NotFound
+	EmptyStatsNotFound = "not-found"
+)
+
+// StatsNoDataHeaderKey is response header key set to "true" when stats are
+// zeros because SPDK reported no entry for the resource
+const StatsNoDataHeaderKey = "opi-stats-no-data"
+
+// SetEmptyStats sets policy applied when SPDK reports no stats for Nvme
+// controllers and namespaces
+func (s *Server) SetEmptyStats(policy string) error {
+	switch policy {
+	case EmptyStatsNoData, EmptyStatsNotFound:
+		s.emptyStats = policy
+		return nil
+	default:
+		return fmt.Errorf("unknown empty stats policy %q, supported are %v",
+			policy, []string{EmptyStatsNoData, EmptyStatsNotFound})
+	}
+}
+
+// SetStatsCollector sets collector sharing stats of all bdevs between stats
+// requests, nil queries SPDK on every request
+func (s *Server) SetStatsCollector(collector *utils.StatsCollector) {
+	s.stats = collector
+}
+
+// bdevsIostat returns stats of all bdevs. The result must not be modified,
+// since it may be shared with other requests
+func (s *Server) bdevsIostat(ctx context.Context) (*spdk.BdevGetIostatResult, error) {
+	if s.stats != nil {
+		return s.stats.Iostat(ctx)
+	}
+	var result spdk.BdevGetIostatResult
+	if err := s.rpc.Call(ctx, "bdev_get_iostat", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// volumesStats sums SPDK iostat of volumes. It returns nil if SPDK reports
+// none of them
+func (s *Server) volumesStats(ctx context.Context, volumes []string) (*pb.VolumeStats, error) {
+	if len(volumes) == 0 {
+		return nil, nil
+	}
+	// query all bdevs, since SPDK fails the call for a named missing bdev
+	// instead of reporting no entry
+	result, err := s.bdevsIostat(ctx)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	wanted := make(map[string]bool, len(volumes))
+	for _, volume := range volumes {
+		wanted[volume] = true
+	}
+	var stats *pb.VolumeStats
+	for _, bdev := range result.Bdevs {
+		if !wanted[bdev.Name] {
+			continue
+		}
+		if stats == nil {
+			stats = &pb.VolumeStats{}
+		}
+		stats.ReadBytesCount += int32(bdev.BytesRead)
+		stats.ReadOpsCount += int32(bdev.NumReadOps)
+		stats.WriteBytesCount += int32(bdev.BytesWritten)
+		stats.WriteOpsCount += int32(bdev.NumWriteOps)
+		stats.UnmapBytesCount += int32(bdev.BytesUnmapped)
+		stats.UnmapOpsCount += int32(bdev.NumUnmapOps)
+		stats.ReadLatencyTicks += int32(bdev.ReadLatencyTicks)
+		stats.WriteLatencyTicks += int32(bdev.WriteLatencyTicks)
+		stats.UnmapLatencyTicks += int32(bdev.UnmapLatencyTicks)
+	}
+	return stats, nil
+}
+
+// emptyStatsOr returns stats or, if SPDK reported none for resource name,
+// applies the configured empty stats policy
+func (s *Server) emptyStatsOr(ctx context.Context, name string, stats *pb.VolumeStats) (*pb.VolumeStats, error) {
+	if stats != nil {
+		return stats, nil
+	}
+	if s.emptyStats == EmptyStatsNotFound {
+		msg := fmt.Sprintf("no stats reported for %s", name)
+		return nil, status.Errorf(codes.NotFound, msg)
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(StatsNoDataHeaderKey, "true")); err != nil {
+		log.Printf("error: failed to send stats no-data flag: %v", err)
+	}
+	return &pb.VolumeStats{}, nil
+}
+
+// subsystemVolumes returns volumes of all namespaces of a subsystem
+func (s *Server) subsystemVolumes(subsysID string) []string {
+	volumes := []string{}
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	for _, namespace := range s.Nvme.Namespaces {
+		if utils.GetSubsystemIDFromNvmeName(namespace.Name) == subsysID {
+			volumes = append(volumes, namespace.GetSpec().GetVolumeNameRef())
+		}
+	}
+	return volumes
+}
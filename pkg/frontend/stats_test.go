@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package frontend implements the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_SetEmptyStats(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	for _, policy := range []string{EmptyStatsNoData, EmptyStatsNotFound} {
+		if err := testEnv.opiSpdkServer.SetEmptyStats(policy); err != nil {
+			t.Error("expected no error for", policy, "received", err)
+		}
+	}
+	err := testEnv.opiSpdkServer.SetEmptyStats("zeros")
+	errMsg := fmt.Sprintf("unknown empty stats policy %q, supported are %v", "zeros", []string{EmptyStatsNoData, EmptyStatsNotFound})
+	if err == nil || err.Error() != errMsg {
+		t.Error("expected error", errMsg, "received", err)
+	}
+}
+
+func TestFrontEnd_EmptyStats(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	emptyIostat := `{"id":%d,"error":{"code":0,"message":""},"result":{"tick_rate":1000,"ticks":2000,"bdevs":[{"name":"Malloc0","num_read_ops":1}]}}`
+	tests := map[string]struct {
+		policy  string
+		spdk    []string
+		out     *pb.VolumeStats
+		noData  []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"no entry with no-data policy": {
+			policy:  EmptyStatsNoData,
+			spdk:    []string{emptyIostat},
+			out:     &pb.VolumeStats{},
+			noData:  []string{"true"},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"no entry with not-found policy": {
+			policy:  EmptyStatsNotFound,
+			spdk:    []string{emptyIostat},
+			out:     nil,
+			noData:  nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("no stats reported for %s", testNamespaceName),
+		},
+		"entry with not-found policy": {
+			policy:  EmptyStatsNotFound,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"tick_rate":1000,"ticks":2000,"bdevs":[{"name":"Malloc1","num_read_ops":3}]}}`},
+			out:     &pb.VolumeStats{ReadOpsCount: 3},
+			noData:  nil,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			if err := testEnv.opiSpdkServer.SetEmptyStats(tt.policy); err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+			namespace := utils.ProtoClone(&testNamespace)
+			namespace.Spec.VolumeNameRef = "Malloc1"
+			testEnv.opiSpdkServer.Nvme.Namespaces[testNamespaceName] = namespace
+
+			var header metadata.MD
+			request := &pb.StatsNvmeNamespaceRequest{Name: testNamespaceName}
+			response, err := testEnv.client.StatsNvmeNamespace(testEnv.ctx, request, grpc.Header(&header))
+
+			if !proto.Equal(response.GetStats(), tt.out) {
+				t.Error("response: expected", tt.out, "received", response.GetStats())
+			}
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if values := header.Get(StatsNoDataHeaderKey); !reflect.DeepEqual(values, tt.noData) {
+				t.Error("no-data header: expected", tt.noData, "received", values)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_StatsNvmeControllerSumsNamespaces(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":{"tick_rate":1000,"ticks":2000,"bdevs":[` +
+			`{"name":"Malloc1","num_read_ops":3,"num_write_ops":1},{"name":"Malloc2","num_read_ops":5,"num_write_ops":2},{"name":"Malloc3","num_read_ops":100}]}}`,
+	})
+	defer testEnv.Close()
+	testEnv.opiSpdkServer.Nvme.Controllers[testControllerName] = utils.ProtoClone(&testController)
+	for i, volume := range []string{"Malloc1", "Malloc2"} {
+		namespace := utils.ProtoClone(&testNamespace)
+		namespace.Name = utils.ResourceIDToNamespaceName(testSubsystemID, fmt.Sprintf("namespace-%d", i))
+		namespace.Spec.VolumeNameRef = volume
+		testEnv.opiSpdkServer.Nvme.Namespaces[namespace.Name] = namespace
+	}
+	other := utils.ProtoClone(&testNamespace)
+	other.Name = utils.ResourceIDToNamespaceName("subsystem-other", "namespace-other")
+	other.Spec.VolumeNameRef = "Malloc3"
+	testEnv.opiSpdkServer.Nvme.Namespaces[other.Name] = other
+
+	response, err := testEnv.client.StatsNvmeController(testEnv.ctx, &pb.StatsNvmeControllerRequest{Name: testControllerName})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	out := &pb.VolumeStats{ReadOpsCount: 8, WriteOpsCount: 3}
+	if !proto.Equal(response.GetStats(), out) {
+		t.Error("response: expected", out, "received", response.GetStats())
+	}
+}
@@ -10,7 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"path"
+	"os"
 
 	"github.com/opiproject/gospdk/spdk"
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
@@ -33,6 +33,48 @@ type VirtioBlkTransport interface {
 	DeleteParams(virtioBlk *pb.VirtioBlk) (any, error)
 }
 
+// VirtioBlkSpdkMethods is implemented by VirtioBlkTransport which creates and
+// deletes virtio-blk controllers by SPDK methods other than vhost ones. Such
+// controllers are not reported by vhost_get_controllers, so they are
+// reported as created
+type VirtioBlkSpdkMethods interface {
+	CreateMethod() string
+	DeleteMethod() string
+}
+
+// NvmeControllerQueues are queue limits of Nvme controller. Zero values are
+// omitted, so that SPDK applies its transport defaults
+type NvmeControllerQueues struct {
+	MaxIoQpairs   int32 `json:"max_io_qpairs_per_ctrlr,omitempty"`
+	MaxQueueDepth int32 `json:"max_queue_depth,omitempty"`
+}
+
+// NewNvmeControllerQueues returns queue limits requested in spec. SPDK
+// pairs submission and completion queues, so max_nsq and max_ncq, which are
+// validated to match when both set, give number of IO queue pairs, and
+// sqes and cqes, log2 of queue entries, give queue depth
+func NewNvmeControllerQueues(spec *pb.NvmeControllerSpec) NvmeControllerQueues {
+	queues := NvmeControllerQueues{MaxIoQpairs: spec.GetMaxNsq()}
+	if queues.MaxIoQpairs == 0 {
+		queues.MaxIoQpairs = spec.GetMaxNcq()
+	}
+	entries := spec.GetSqes()
+	if entries == 0 {
+		entries = spec.GetCqes()
+	}
+	if entries != 0 {
+		queues.MaxQueueDepth = 1 << entries
+	}
+	return queues
+}
+
+// NvmfSubsystemAddListenerParams are nvmf_subsystem_add_listener params
+// along with queue limits of the controller the listener is added for
+type NvmfSubsystemAddListenerParams struct {
+	spdk.NvmfSubsystemAddListenerParams
+	NvmeControllerQueues
+}
+
 type nvmeTCPTransport struct {
 	rpc spdk.JSONRPC
 }
@@ -56,7 +98,13 @@ func (c *nvmeTCPTransport) CreateController(
 	ctrlr *pb.NvmeController,
 	subsys *pb.NvmeSubsystem,
 ) error {
-	params := c.params(ctrlr, subsys)
+	params := NvmfSubsystemAddListenerParams{
+		NvmfSubsystemAddListenerParams: c.params(ctrlr, subsys),
+		NvmeControllerQueues:           NewNvmeControllerQueues(ctrlr.GetSpec()),
+	}
+	if utils.SkipSpdkCallInDryRun(ctx, "nvmf_subsystem_add_listener", &params) {
+		return nil
+	}
 	var result spdk.NvmfSubsystemAddListenerResult
 	err := c.rpc.Call(ctx, "nvmf_subsystem_add_listener", &params, &result)
 	if err != nil {
@@ -77,6 +125,9 @@ func (c *nvmeTCPTransport) DeleteController(
 	subsys *pb.NvmeSubsystem,
 ) error {
 	params := c.params(ctrlr, subsys)
+	if utils.SkipSpdkCallInDryRun(ctx, "nvmf_subsystem_remove_listener", &params) {
+		return nil
+	}
 	var result spdk.NvmfSubsystemAddListenerResult
 	err := c.rpc.Call(ctx, "nvmf_subsystem_remove_listener", &params, &result)
 	if err != nil {
@@ -121,7 +172,7 @@ func (v vhostUserBlkTransport) CreateParams(virtioBlk *pb.VirtioBlk) (any, error
 		return nil, err
 	}
 
-	resourceID := path.Base(virtioBlk.Name)
+	resourceID := utils.ResourceNameToID(virtioBlk.Name)
 	return spdk.VhostCreateBlkControllerParams{
 		Ctrlr:   resourceID,
 		DevName: virtioBlk.VolumeNameRef,
@@ -133,7 +184,7 @@ func (v vhostUserBlkTransport) DeleteParams(virtioBlk *pb.VirtioBlk) (any, error
 		return nil, err
 	}
 
-	resourceID := path.Base(virtioBlk.Name)
+	resourceID := utils.ResourceNameToID(virtioBlk.Name)
 	return spdk.VhostDeleteControllerParams{
 		Ctrlr: resourceID,
 	}, nil
@@ -151,3 +202,107 @@ func (v vhostUserBlkTransport) verifyTransportSpecificParams(virtioBlk *pb.Virti
 
 	return nil
 }
+
+// vfuTgtSetBasePathParams is params of vfu_tgt_set_base_path
+// TODO: use spdk.VfuTgtSetBasePathParams when gospdk provides it
+type vfuTgtSetBasePathParams struct {
+	Path string `json:"path"`
+}
+
+// vfuVirtioCreateBlkEndpointParams is params of vfu_virtio_create_blk_endpoint
+// TODO: use spdk.VfuVirtioCreateBlkEndpointParams when gospdk provides it
+type vfuVirtioCreateBlkEndpointParams struct {
+	Name     string `json:"name"`
+	BdevName string `json:"bdev_name"`
+}
+
+// vfuVirtioDeleteEndpointParams is params of vfu_virtio_delete_endpoint
+// TODO: use spdk.VfuVirtioDeleteEndpointParams when gospdk provides it
+type vfuVirtioDeleteEndpointParams struct {
+	Name string `json:"name"`
+}
+
+type virtioBlkVfioUserTransport struct{}
+
+// build time check that struct implements interfaces
+var (
+	_ VirtioBlkTransport   = (*virtioBlkVfioUserTransport)(nil)
+	_ VirtioBlkSpdkMethods = (*virtioBlkVfioUserTransport)(nil)
+)
+
+// NewVirtioBlkVfioUserTransport creates objects to handle virtio-blk over
+// vfio-user transport specifics. SPDK is configured to create endpoint
+// sockets, named by virtio-blk resource id, in ctrlrDir
+func NewVirtioBlkVfioUserTransport(ctrlrDir string, rpc spdk.JSONRPC) VirtioBlkTransport {
+	if ctrlrDir == "" {
+		log.Panicf("ctrlrDir cannot be empty")
+	}
+
+	dir, err := os.Stat(ctrlrDir)
+	if err != nil {
+		log.Panicf("%v path cannot be evaluated", ctrlrDir)
+	}
+	if !dir.IsDir() {
+		log.Panicf("%v is not a directory", ctrlrDir)
+	}
+
+	if rpc == nil {
+		log.Panicf("rpc cannot be nil")
+	}
+
+	params := vfuTgtSetBasePathParams{Path: ctrlrDir}
+	var result bool
+	if err := rpc.Call(context.Background(), "vfu_tgt_set_base_path", &params, &result); err != nil {
+		log.Panicf("failed to set vfio-user base path %v: %v", ctrlrDir, err)
+	}
+	log.Printf("Received from SPDK: %v", result)
+	if !result {
+		log.Panicf("could not set vfio-user base path %v", ctrlrDir)
+	}
+
+	return &virtioBlkVfioUserTransport{}
+}
+
+func (v virtioBlkVfioUserTransport) CreateMethod() string {
+	return "vfu_virtio_create_blk_endpoint"
+}
+
+func (v virtioBlkVfioUserTransport) DeleteMethod() string {
+	return "vfu_virtio_delete_endpoint"
+}
+
+func (v virtioBlkVfioUserTransport) CreateParams(virtioBlk *pb.VirtioBlk) (any, error) {
+	if err := v.verifyTransportSpecificParams(virtioBlk); err != nil {
+		return nil, err
+	}
+
+	resourceID := utils.ResourceNameToID(virtioBlk.Name)
+	return vfuVirtioCreateBlkEndpointParams{
+		Name:     resourceID,
+		BdevName: virtioBlk.VolumeNameRef,
+	}, nil
+}
+
+func (v virtioBlkVfioUserTransport) DeleteParams(virtioBlk *pb.VirtioBlk) (any, error) {
+	if err := v.verifyTransportSpecificParams(virtioBlk); err != nil {
+		return nil, err
+	}
+
+	resourceID := utils.ResourceNameToID(virtioBlk.Name)
+	return vfuVirtioDeleteEndpointParams{
+		Name: resourceID,
+	}, nil
+}
+
+func (v virtioBlkVfioUserTransport) verifyTransportSpecificParams(virtioBlk *pb.VirtioBlk) error {
+	pcieID := virtioBlk.PcieId
+	if pcieID.PortId.Value != 0 {
+		return errors.New("only port 0 is supported for vfio-user virtio-blk")
+	}
+
+	if pcieID.VirtualFunction.Value != 0 {
+		return errors.New("virtual functions are not supported for vfio-user virtio-blk")
+	}
+
+	return nil
+}
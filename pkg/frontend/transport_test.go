@@ -6,10 +6,15 @@
 package frontend
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	"github.com/opiproject/gospdk/spdk"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
 )
 
 func TestNewNvmeVfiouserTransport(t *testing.T) {
@@ -47,3 +52,121 @@ func TestNewNvmeVfiouserTransport(t *testing.T) {
 		})
 	}
 }
+
+func TestNewVirtioBlkVfioUserTransport(t *testing.T) {
+	ctrlrDir := t.TempDir()
+	file, err := os.CreateTemp(ctrlrDir, "ctrlr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		ctrlrDir  string
+		nilRPC    bool
+		spdk      []string
+		wantPanic bool
+	}{
+		"empty ctrlr dir": {
+			ctrlrDir:  "",
+			spdk:      []string{},
+			wantPanic: true,
+		},
+		"non existing ctrlr dir": {
+			ctrlrDir:  filepath.Join(ctrlrDir, "missing"),
+			spdk:      []string{},
+			wantPanic: true,
+		},
+		"ctrlr dir is a file": {
+			ctrlrDir:  file.Name(),
+			spdk:      []string{},
+			wantPanic: true,
+		},
+		"nil json rpc": {
+			ctrlrDir:  ctrlrDir,
+			nilRPC:    true,
+			spdk:      []string{},
+			wantPanic: true,
+		},
+		"spdk failed to set base path": {
+			ctrlrDir:  ctrlrDir,
+			spdk:      []string{`{"id":%d,"error":{"code":0,"message":""},"result":false}`},
+			wantPanic: true,
+		},
+		"valid transport": {
+			ctrlrDir:  ctrlrDir,
+			spdk:      []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			wantPanic: false,
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+			var rpc spdk.JSONRPC = recorder
+			if tt.nilRPC {
+				rpc = nil
+			}
+			defer func() {
+				r := recover()
+				if (r != nil) != tt.wantPanic {
+					t.Errorf("NewVirtioBlkVfioUserTransport() recover = %v, wantPanic = %v", r, tt.wantPanic)
+				}
+				if len(tt.spdk) == 0 {
+					return
+				}
+				wantParams := []string{fmt.Sprintf(`{"path":%q}`, ctrlrDir)}
+				if !reflect.DeepEqual(recorder.params, wantParams) {
+					t.Error("spdk params: expected", wantParams, "received", recorder.params)
+				}
+			}()
+
+			gotTransport := NewVirtioBlkVfioUserTransport(tt.ctrlrDir, rpc)
+			if _, ok := gotTransport.(VirtioBlkSpdkMethods); !ok {
+				t.Error("expected transport to use its own SPDK methods")
+			}
+		})
+	}
+}
+
+func TestNvmeTCPTransportCreateControllerQueues(t *testing.T) {
+	tests := map[string]struct {
+		spec       *pb.NvmeControllerSpec
+		wantParams string
+	}{
+		"default queues": {
+			spec:       &pb.NvmeControllerSpec{Endpoint: testController.Spec.Endpoint},
+			wantParams: `{"nqn":"nqn.2022-09.io.spdk:opi3","listen_address":{"trtype":"tcp","traddr":"127.0.0.1","trsvcid":"4420","adrfam":"IPV4"}}`,
+		},
+		"queue limits": {
+			spec:       &pb.NvmeControllerSpec{Endpoint: testController.Spec.Endpoint, MaxNsq: 16, MaxNcq: 16, Sqes: 10, Cqes: 10},
+			wantParams: `{"nqn":"nqn.2022-09.io.spdk:opi3","listen_address":{"trtype":"tcp","traddr":"127.0.0.1","trsvcid":"4420","adrfam":"IPV4"},"max_io_qpairs_per_ctrlr":16,"max_queue_depth":1024}`,
+		},
+		"only completion queue limits": {
+			spec:       &pb.NvmeControllerSpec{Endpoint: testController.Spec.Endpoint, MaxNcq: 2, Cqes: 5},
+			wantParams: `{"nqn":"nqn.2022-09.io.spdk:opi3","listen_address":{"trtype":"tcp","traddr":"127.0.0.1","trsvcid":"4420","adrfam":"IPV4"},"max_io_qpairs_per_ctrlr":2,"max_queue_depth":32}`,
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`})
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+
+			err := NewNvmeTCPTransport(recorder).CreateController(context.Background(),
+				&pb.NvmeController{Name: testControllerName, Spec: tt.spec}, &testSubsystem)
+
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+			wantParams := []string{tt.wantParams}
+			if !reflect.DeepEqual(recorder.params, wantParams) {
+				t.Error("spdk params: expected", wantParams, "received", recorder.params)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package kvm automates plugging of SPDK devices to a QEMU instance
+package kvm
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/philippgille/gokv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// deviceLocationsStoreKey is store key of bus and slot assigned to each
+// device, kept as a single struct since the store cannot list keys
+const deviceLocationsStoreKey = "kvm/device-locations"
+
+// deviceAssignments keeps bus and slot of devices plugged to QEMU buses,
+// persisted in the store, so that devices re-created after bridge restart
+// are plugged to the same slot and do not collide with each other
+type deviceAssignments struct {
+	store gokv.Store
+
+	mu        sync.Mutex
+	locations map[string]deviceLocation
+}
+
+// newDeviceAssignments restores assignments kept in store
+func newDeviceAssignments(store gokv.Store) *deviceAssignments {
+	a := &deviceAssignments{
+		store:     store,
+		locations: make(map[string]deviceLocation),
+	}
+	value := &structpb.Struct{}
+	found, err := store.Get(deviceLocationsStoreKey, value)
+	if err != nil {
+		log.Printf("error: failed to load device locations: %v", err)
+	}
+	if !found || err != nil {
+		return a
+	}
+	for name, field := range value.Fields {
+		fields := field.GetStructValue().GetFields()
+		bus := fields["bus"].GetStringValue()
+		addr := fields["addr"].GetStringValue()
+		a.locations[name] = deviceLocation{Bus: &bus, Addr: &addr}
+	}
+	log.Printf("Restored bus locations of %d device(s)", len(a.locations))
+	return a
+}
+
+// assign records location of device name. Location assigned to the device
+// before is returned instead of the requested one. Locations not on a
+// specific bus are left to QEMU and not recorded
+func (a *deviceAssignments) assign(name string, location deviceLocation) (deviceLocation, error) {
+	if location.Bus == nil || location.Addr == nil {
+		return location, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if assigned, ok := a.locations[name]; ok {
+		if *assigned.Bus != *location.Bus || *assigned.Addr != *location.Addr {
+			log.Printf("Reusing bus %v slot %v assigned to %v before", *assigned.Bus, *assigned.Addr, name)
+		}
+		location = assigned
+	}
+	for other, assigned := range a.locations {
+		if other != name && *assigned.Bus == *location.Bus && *assigned.Addr == *location.Addr {
+			msg := fmt.Sprintf("bus %v slot %v of %v is already assigned to %v", *location.Bus, *location.Addr, name, other)
+			return deviceLocation{}, status.Errorf(codes.FailedPrecondition, msg)
+		}
+	}
+	a.locations[name] = location
+	a.persist()
+	return location, nil
+}
+
+// release forgets location of device name unplugged from QEMU
+func (a *deviceAssignments) release(name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.locations[name]; !ok {
+		return
+	}
+	delete(a.locations, name)
+	a.persist()
+}
+
+func (a *deviceAssignments) persist() {
+	value := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(a.locations))}
+	for name, location := range a.locations {
+		value.Fields[name] = structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"bus":  structpb.NewStringValue(*location.Bus),
+			"addr": structpb.NewStringValue(*location.Addr),
+		}})
+	}
+	if err := a.store.Set(deviceLocationsStoreKey, value); err != nil {
+		log.Printf("error: failed to store device locations: %v", err)
+	}
+}
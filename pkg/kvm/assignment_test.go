@@ -0,0 +1,219 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package kvm automates plugging of SPDK devices to a QEMU instance
+package kvm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/philippgille/gokv"
+	"github.com/philippgille/gokv/gomap"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/frontend"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newTestDeviceStore(t *testing.T, locations map[string]interface{}) gokv.Store {
+	options := gomap.DefaultOptions
+	options.Codec = utils.ProtoCodec{}
+	store := gomap.NewStore(options)
+	if locations != nil {
+		value, err := structpb.NewStruct(locations)
+		if err != nil {
+			t.Fatal("expected no error, received", err)
+		}
+		if err := store.Set(deviceLocationsStoreKey, value); err != nil {
+			t.Fatal("expected no error, received", err)
+		}
+	}
+	return store
+}
+
+func testDeviceLocation(bus, addr string) deviceLocation {
+	return deviceLocation{Bus: &bus, Addr: &addr}
+}
+
+func TestDeviceAssignments(t *testing.T) {
+	tests := map[string]struct {
+		stored   map[string]interface{}
+		name     string
+		location deviceLocation
+		out      deviceLocation
+		errCode  codes.Code
+		errMsg   string
+		restored map[string]deviceLocation
+	}{
+		"new device": {
+			stored:   nil,
+			name:     "dev0",
+			location: testDeviceLocation("pci.opi.0", "0x1"),
+			out:      testDeviceLocation("pci.opi.0", "0x1"),
+			errCode:  codes.OK,
+			errMsg:   "",
+			restored: map[string]deviceLocation{"dev0": testDeviceLocation("pci.opi.0", "0x1")},
+		},
+		"restored device reuses its slot": {
+			stored: map[string]interface{}{
+				"dev0": map[string]interface{}{"bus": "pci.opi.1", "addr": "0x5"},
+			},
+			name:     "dev0",
+			location: testDeviceLocation("pci.opi.0", "0x1"),
+			out:      testDeviceLocation("pci.opi.1", "0x5"),
+			errCode:  codes.OK,
+			errMsg:   "",
+			restored: map[string]deviceLocation{"dev0": testDeviceLocation("pci.opi.1", "0x5")},
+		},
+		"slot occupied by restored device": {
+			stored: map[string]interface{}{
+				"dev1": map[string]interface{}{"bus": "pci.opi.0", "addr": "0x1"},
+			},
+			name:     "dev0",
+			location: testDeviceLocation("pci.opi.0", "0x1"),
+			out:      deviceLocation{},
+			errCode:  codes.FailedPrecondition,
+			errMsg:   "bus pci.opi.0 slot 0x1 of dev0 is already assigned to dev1",
+			restored: map[string]deviceLocation{"dev1": testDeviceLocation("pci.opi.0", "0x1")},
+		},
+		"stored slot of device is now occupied": {
+			stored: map[string]interface{}{
+				"dev0": map[string]interface{}{"bus": "pci.opi.0", "addr": "0x1"},
+				"dev1": map[string]interface{}{"bus": "pci.opi.0", "addr": "0x1"},
+			},
+			name:     "dev0",
+			location: testDeviceLocation("pci.opi.0", "0x2"),
+			out:      deviceLocation{},
+			errCode:  codes.FailedPrecondition,
+			errMsg:   "bus pci.opi.0 slot 0x1 of dev0 is already assigned to dev1",
+			restored: map[string]deviceLocation{
+				"dev0": testDeviceLocation("pci.opi.0", "0x1"),
+				"dev1": testDeviceLocation("pci.opi.0", "0x1"),
+			},
+		},
+		"location assigned by QEMU is not recorded": {
+			stored:   nil,
+			name:     "dev0",
+			location: deviceLocation{},
+			out:      deviceLocation{},
+			errCode:  codes.OK,
+			errMsg:   "",
+			restored: map[string]deviceLocation{},
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			store := newTestDeviceStore(t, tt.stored)
+
+			out, err := newDeviceAssignments(store).assign(tt.name, tt.location)
+
+			if !reflect.DeepEqual(out, tt.out) {
+				t.Error("location: expected", tt.out, "received", out)
+			}
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			// simulate restart
+			restored := newDeviceAssignments(store).locations
+			if !reflect.DeepEqual(restored, tt.restored) {
+				t.Error("restored: expected", tt.restored, "received", restored)
+			}
+		})
+	}
+}
+
+func TestDeviceAssignmentsRelease(t *testing.T) {
+	store := newTestDeviceStore(t, map[string]interface{}{
+		"dev0": map[string]interface{}{"bus": "pci.opi.0", "addr": "0x1"},
+	})
+
+	newDeviceAssignments(store).release("dev0")
+
+	restored := newDeviceAssignments(store)
+	if len(restored.locations) != 0 {
+		t.Error("expected no locations after release, received", restored.locations)
+	}
+	if _, err := restored.assign("dev1", testDeviceLocation("pci.opi.0", "0x1")); err != nil {
+		t.Error("expected released slot to be free, received", err)
+	}
+}
+
+func TestCreateNvmeControllerAfterRestart(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		stored       map[string]interface{}
+		errCode      codes.Code
+		errMsg       string
+		mockQmpCalls *mockQmpCalls
+	}{
+		"device reuses slot assigned before restart": {
+			stored: map[string]interface{}{
+				testNvmeControllerName: map[string]interface{}{"bus": "pci.opi.0", "addr": "0x3"},
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			mockQmpCalls: newMockQmpCalls().
+				ExpectAddNvmeControllerWithAddress(testNvmeControllerID, testSubsystemID, "pci.opi.0", 3).
+				ExpectQueryPci(testNvmeControllerID),
+		},
+		"slot assigned to another device before restart": {
+			stored: map[string]interface{}{
+				"other-device": map[string]interface{}{"bus": "pci.opi.1", "addr": "0xb"},
+			},
+			errCode:      codes.FailedPrecondition,
+			errMsg:       "bus pci.opi.1 slot 0xb of " + testNvmeControllerName + " is already assigned to other-device",
+			mockQmpCalls: nil,
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			store := newTestDeviceStore(t, tt.stored)
+			qmpServer := startMockQmpServer(t, tt.mockQmpCalls)
+			defer qmpServer.Stop()
+			opiSpdkServer := frontend.NewCustomizedServer(alwaysSuccessfulJSONRPC, store,
+				map[pb.NvmeTransportType]frontend.NvmeTransport{
+					pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE: NewNvmeVfiouserTransport(qmpServer.testDir, alwaysSuccessfulJSONRPC),
+				}, frontend.NewVhostUserBlkTransport())
+			opiSpdkServer.Nvme.Subsystems[testSubsystemName] = &testSubsystem
+			kvmServer := NewServer(opiSpdkServer, store, qmpServer.socketPath, qmpServer.testDir, []string{"pci.opi.0", "pci.opi.1"})
+			kvmServer.timeout = qmplibTimeout
+
+			_, err := kvmServer.CreateNvmeController(context.Background(), utils.ProtoClone(testCreateNvmeControllerRequest))
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+			if !qmpServer.WereExpectedCallsPerformed() {
+				t.Errorf("Not all expected calls were performed")
+			}
+			if _, ok := opiSpdkServer.Nvme.Controllers[testNvmeControllerName]; ok != (err == nil) {
+				t.Error("expected controller to be kept only on success, found", ok)
+			}
+			if dirExists(controllerDirPath(qmpServer.testDir, testSubsystemID)) != (err == nil) {
+				t.Error("expected controller dir to be kept only on success")
+			}
+		})
+	}
+}
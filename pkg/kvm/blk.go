@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -23,7 +24,11 @@ func (s *Server) CreateVirtioBlk(ctx context.Context, in *pb.CreateVirtioBlkRequ
 	location, err := s.locator.Calculate(in.VirtioBlk.PcieId)
 	if err != nil {
 		log.Println("Failed to calculate device location:", err)
-		return nil, errDeviceEndpoint
+		return nil, err
+	}
+	// QEMU is left untouched by dry run
+	if utils.DryRunRequested(ctx) {
+		return s.Server.CreateVirtioBlk(ctx, in)
 	}
 
 	out, err := s.Server.CreateVirtioBlk(ctx, in)
@@ -32,7 +37,14 @@ func (s *Server) CreateVirtioBlk(ctx context.Context, in *pb.CreateVirtioBlkRequ
 		return out, err
 	}
 
-	mon, err := newMonitor(s.qmpAddress, s.protocol, s.timeout, s.pollDevicePresenceStep)
+	location, err = s.assignments.assign(out.Name, location)
+	if err != nil {
+		log.Println("Couldn't assign device location:", err)
+		_, _ = s.Server.DeleteVirtioBlk(context.Background(), &pb.DeleteVirtioBlkRequest{Name: out.Name})
+		return nil, err
+	}
+
+	mon, err := s.dialMonitor()
 	if err != nil {
 		log.Println("Couldn't create QEMU monitor")
 		_, _ = s.Server.DeleteVirtioBlk(context.Background(), &pb.DeleteVirtioBlkRequest{Name: out.Name})
@@ -40,7 +52,7 @@ func (s *Server) CreateVirtioBlk(ctx context.Context, in *pb.CreateVirtioBlkRequ
 	}
 	defer mon.Disconnect()
 
-	ctrlr := filepath.Join(s.ctrlrDir, filepath.Base(out.Name))
+	ctrlr := filepath.Join(s.ctrlrDir, utils.ResourceNameToID(out.Name))
 	qemuChardevID := toQemuID(out.Name)
 	if err := mon.AddChardev(qemuChardevID, ctrlr); err != nil {
 		log.Println("Couldn't add chardev:", err)
@@ -61,7 +73,10 @@ func (s *Server) CreateVirtioBlk(ctx context.Context, in *pb.CreateVirtioBlkRequ
 
 // DeleteVirtioBlk deletes a virtio-blk device and detaches it from QEMU instance
 func (s *Server) DeleteVirtioBlk(ctx context.Context, in *pb.DeleteVirtioBlkRequest) (*emptypb.Empty, error) {
-	mon, monErr := newMonitor(s.qmpAddress, s.protocol, s.timeout, s.pollDevicePresenceStep)
+	if utils.DryRunRequested(ctx) {
+		return s.Server.DeleteVirtioBlk(ctx, in)
+	}
+	mon, monErr := s.dialMonitor()
 	if monErr != nil {
 		log.Println("Couldn't create QEMU monitor")
 		return nil, errMonitorCreation
@@ -72,6 +87,8 @@ func (s *Server) DeleteVirtioBlk(ctx context.Context, in *pb.DeleteVirtioBlkRequ
 	delDevErr := mon.DeleteVirtioBlkDevice(qemuDeviceID)
 	if delDevErr != nil {
 		log.Printf("Couldn't delete virtio-blk: %v", delDevErr)
+	} else {
+		s.assignments.release(in.Name)
 	}
 
 	qemuChardevID := toQemuID(in.Name)
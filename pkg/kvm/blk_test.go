@@ -131,8 +131,8 @@ func TestCreateVirtioBlk(t *testing.T) {
 		"virtio-blk creation with physical function goes out of buses": {
 			in:      testCreateVirtioBlkRequest,
 			out:     nil,
-			errCode: status.Convert(errDeviceEndpoint).Code(),
-			errMsg:  status.Convert(errDeviceEndpoint).Message(),
+			errCode: codes.InvalidArgument,
+			errMsg:  "physical function 42 does not fall on any of valid buses pci.opi.0, must be 0 to 31",
 			jsonRPC: alwaysSuccessfulJSONRPC,
 			buses:   []string{"pci.opi.0"},
 		},
@@ -146,8 +146,8 @@ func TestCreateVirtioBlk(t *testing.T) {
 				MaxIoQps:      1,
 			}, VirtioBlkId: testVirtioBlkID},
 			out:     nil,
-			errCode: status.Convert(errDeviceEndpoint).Code(),
-			errMsg:  status.Convert(errDeviceEndpoint).Message(),
+			errCode: codes.InvalidArgument,
+			errMsg:  "physical function -1 does not fall on any of valid buses pci.opi.0, must be 0 to 31",
 			jsonRPC: alwaysSuccessfulJSONRPC,
 			buses:   []string{"pci.opi.0"},
 		},
@@ -176,7 +176,7 @@ func TestCreateVirtioBlk(t *testing.T) {
 			if tt.nonDefaultQmpAddress != "" {
 				qmpAddress = tt.nonDefaultQmpAddress
 			}
-			kvmServer := NewServer(opiSpdkServer, qmpAddress, qmpServer.testDir, tt.buses)
+			kvmServer := NewServer(opiSpdkServer, store, qmpAddress, qmpServer.testDir, tt.buses)
 			kvmServer.timeout = qmplibTimeout
 			request := utils.ProtoClone(tt.in)
 
@@ -283,7 +283,7 @@ func TestDeleteVirtioBlk(t *testing.T) {
 			if tt.nonDefaultQmpAddress != "" {
 				qmpAddress = tt.nonDefaultQmpAddress
 			}
-			kvmServer := NewServer(opiSpdkServer, qmpAddress, qmpServer.testDir, nil)
+			kvmServer := NewServer(opiSpdkServer, store, qmpAddress, qmpServer.testDir, nil)
 			kvmServer.timeout = qmplibTimeout
 			request := utils.ProtoClone(testDeleteVirtioBlkRequest)
 
@@ -9,10 +9,11 @@ import (
 	"log"
 	"net"
 	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/opiproject/opi-spdk-bridge/pkg/frontend"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+	"github.com/philippgille/gokv"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -22,6 +23,13 @@ const (
 	unixSocketProtocol = "unix"
 )
 
+// Defaults of connecting to QMP, see SetQmpConnectPolicy
+const (
+	DefaultQmpDialTimeout    = 2 * time.Second
+	DefaultQmpConnectRetries = 3
+	DefaultQmpConnectBackoff = 100 * time.Millisecond
+)
+
 var (
 	errAddChardevFailed       = status.Error(codes.FailedPrecondition, "couldn't add chardev")
 	errMonitorCreation        = status.Error(codes.Internal, "failed to create QEMU monitor")
@@ -31,7 +39,6 @@ var (
 	errInvalidSubsystem       = status.Error(codes.InvalidArgument, "invalid subsystem")
 	errDevicePartiallyDeleted = status.Error(codes.Internal, "device is partially deleted")
 	errFailedToCreateNvmeDir  = status.Error(codes.FailedPrecondition, "cannot create directory for Nvme controller")
-	errDeviceEndpoint         = status.Error(codes.InvalidArgument, "values in endpoint cannot be used to calculate device location")
 	errNoPcieEndpoint         = status.Error(codes.InvalidArgument, "no pcie endpoint provided")
 )
 
@@ -47,15 +54,28 @@ type Server struct {
 	timeout                time.Duration
 	pollDevicePresenceStep time.Duration
 
-	locator deviceLocator
+	// dialTimeout bounds connecting to QMP and capabilities negotiation
+	dialTimeout time.Duration
+	// connectRetries is number of reconnects to QMP after failed connect,
+	// delayed by connectBackoff doubled with every retry
+	connectRetries int
+	connectBackoff time.Duration
+
+	locator     deviceLocator
+	assignments *deviceAssignments
 }
 
-// NewServer creates instance of KvmServer
-func NewServer(s *frontend.Server, qmpAddress string, ctrlrDir string, buses []string) *Server {
+// NewServer creates instance of KvmServer. Bus locations of devices plugged
+// before restart are restored from store
+func NewServer(s *frontend.Server, store gokv.Store, qmpAddress string, ctrlrDir string, buses []string) *Server {
 	if s == nil {
 		log.Fatalf("Frontend Server cannot be nil")
 	}
 
+	if store == nil {
+		log.Fatalf("Store cannot be nil")
+	}
+
 	if qmpAddress == "" {
 		log.Fatalf("qmpAddress cannot be empty")
 	}
@@ -77,7 +97,44 @@ func NewServer(s *frontend.Server, qmpAddress string, ctrlrDir string, buses []s
 		qmpProtocol,
 		timeout,
 		pollDevicePresenceStep,
-		newDeviceLocator(buses)}
+		DefaultQmpDialTimeout,
+		DefaultQmpConnectRetries,
+		DefaultQmpConnectBackoff,
+		newDeviceLocator(buses),
+		newDeviceAssignments(store)}
+}
+
+// SetQmpConnectPolicy sets timeout of connecting to QMP and number of
+// reconnects with exponential backoff when connecting fails, so that device
+// plug and unplug survive QEMU being slow or restarting
+func (s *Server) SetQmpConnectPolicy(dialTimeout time.Duration, retries int, backoff time.Duration) error {
+	if dialTimeout <= 0 {
+		return fmt.Errorf("QMP dial timeout must be positive, got %v", dialTimeout)
+	}
+	if retries < 0 {
+		return fmt.Errorf("QMP connect retries %d cannot be negative", retries)
+	}
+	if backoff < 0 {
+		return fmt.Errorf("QMP connect backoff %v cannot be negative", backoff)
+	}
+	s.dialTimeout = dialTimeout
+	s.connectRetries = retries
+	s.connectBackoff = backoff
+	return nil
+}
+
+// dialMonitor connects to QMP on a fresh connection, reconnecting with
+// backoff on failure
+func (s *Server) dialMonitor() (*monitor, error) {
+	delay := s.connectBackoff
+	mon, err := newMonitor(s.qmpAddress, s.protocol, s.dialTimeout, s.timeout, s.pollDevicePresenceStep)
+	for attempt := 1; attempt <= s.connectRetries && err != nil; attempt++ {
+		log.Printf("error: failed to connect to QMP, retry %d/%d in %v: %v", attempt, s.connectRetries, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+		mon, err = newMonitor(s.qmpAddress, s.protocol, s.dialTimeout, s.timeout, s.pollDevicePresenceStep)
+	}
+	return mon, err
 }
 
 func getProtocol(qmpAddress string) (string, error) {
@@ -105,7 +162,7 @@ func isTCPAddress(qmpAddress string) bool {
 }
 
 func toQemuID(name string) string {
-	resourceID := filepath.Base(name)
+	resourceID := utils.ResourceNameToID(name)
 	// qemu id cannot start with numbers. Add prefix
 	return "opi-" + resourceID
 }
@@ -92,6 +92,14 @@ func (s *stubJSONRRPC) Call(_ context.Context, method string, arg, result interf
 			}
 			*resultCreateNvmeController = spdk.NvmfSubsystemAddListenerResult(true)
 		}
+	} else if method == "nvmf_delete_subsystem" {
+		if s.err == nil {
+			resultDeleteSubsystem, ok := result.(*spdk.NvmfDeleteSubsystemResult)
+			if !ok {
+				log.Panicf("Unexpected type for subsystem deletion result")
+			}
+			*resultDeleteSubsystem = spdk.NvmfDeleteSubsystemResult(true)
+		}
 	}
 	s.arg = arg
 
@@ -283,15 +291,22 @@ func startMockQmpServer(t *testing.T, m *mockQmpCalls) *mockQmpServer {
 	}
 
 	s.socketPath = filepath.Join(s.testDir, "qmp.sock")
+	s.test = t
+	s.listen()
+
+	return s
+}
+
+// listen accepts single QMP connection and serves expected calls on it
+func (s *mockQmpServer) listen() {
 	socket, err := net.Listen("unix", s.socketPath)
 	if err != nil {
 		log.Panic(err.Error())
 	}
 	s.socket = socket
-	s.test = t
 
 	go func() {
-		conn, err := s.socket.Accept()
+		conn, err := socket.Accept()
 		if err != nil {
 			return
 		}
@@ -306,8 +321,6 @@ func startMockQmpServer(t *testing.T, m *mockQmpCalls) *mockQmpServer {
 			s.handleExpectedCall(call, conn)
 		}
 	}()
-
-	return s
 }
 
 func (s *mockQmpServer) Stop() {
@@ -378,3 +391,166 @@ func (s *mockQmpServer) read(conn net.Conn) string {
 	log.Println("QMP server got :", data)
 	return data
 }
+
+func TestSetQmpConnectPolicy(t *testing.T) {
+	tests := map[string]struct {
+		dialTimeout time.Duration
+		retries     int
+		backoff     time.Duration
+		errMsg      string
+	}{
+		"valid policy": {
+			dialTimeout: time.Second,
+			retries:     5,
+			backoff:     time.Millisecond,
+			errMsg:      "",
+		},
+		"reconnect disabled": {
+			dialTimeout: time.Second,
+			retries:     0,
+			backoff:     0,
+			errMsg:      "",
+		},
+		"zero dial timeout": {
+			dialTimeout: 0,
+			retries:     5,
+			backoff:     time.Millisecond,
+			errMsg:      "QMP dial timeout must be positive, got 0s",
+		},
+		"negative retries": {
+			dialTimeout: time.Second,
+			retries:     -1,
+			backoff:     time.Millisecond,
+			errMsg:      "QMP connect retries -1 cannot be negative",
+		},
+		"negative backoff": {
+			dialTimeout: time.Second,
+			retries:     5,
+			backoff:     -time.Millisecond,
+			errMsg:      "QMP connect backoff -1ms cannot be negative",
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			kvmServer := &Server{}
+
+			err := kvmServer.SetQmpConnectPolicy(tt.dialTimeout, tt.retries, tt.backoff)
+
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tt.errMsg {
+				t.Error("error: expected", tt.errMsg, "received", errMsg)
+			}
+		})
+	}
+}
+
+func TestDialMonitorReconnect(t *testing.T) {
+	tests := map[string]struct {
+		unavailableFor time.Duration
+		retries        int
+		connected      bool
+	}{
+		"QMP available": {
+			unavailableFor: 0,
+			retries:        0,
+			connected:      true,
+		},
+		"QMP comes up while reconnecting": {
+			unavailableFor: 30 * time.Millisecond,
+			retries:        5,
+			connected:      true,
+		},
+		"QMP does not come up before retries are exhausted": {
+			unavailableFor: time.Second,
+			retries:        1,
+			connected:      false,
+		},
+		"reconnect disabled": {
+			unavailableFor: 30 * time.Millisecond,
+			retries:        0,
+			connected:      false,
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			qmpServer := startMockQmpServer(t, nil)
+			defer qmpServer.Stop()
+			kvmServer := &Server{qmpAddress: qmpServer.socketPath, protocol: unixSocketProtocol, timeout: qmplibTimeout}
+			if err := kvmServer.SetQmpConnectPolicy(qmplibTimeout, tt.retries, 10*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+			restarted := make(chan struct{})
+			if tt.unavailableFor > 0 {
+				if err := qmpServer.socket.Close(); err != nil {
+					t.Fatal(err)
+				}
+				qmpServer.socket = nil
+				go func() {
+					defer close(restarted)
+					time.Sleep(tt.unavailableFor)
+					qmpServer.listen()
+				}()
+			} else {
+				close(restarted)
+			}
+
+			mon, err := kvmServer.dialMonitor()
+
+			if tt.connected {
+				if err != nil {
+					t.Error("expected QMP connection, received", err)
+				} else {
+					mon.Disconnect()
+				}
+			} else if err == nil {
+				mon.Disconnect()
+				t.Error("expected no QMP connection")
+			}
+			<-restarted
+		})
+	}
+}
+
+func TestDialMonitorHandshakeTimeout(t *testing.T) {
+	testDir, err := os.MkdirTemp("", "opi-spdk-kvm-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+	socketPath := filepath.Join(testDir, "qmp.sock")
+	// QEMU accepting connections but never greeting
+	socket, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socket.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		conn, err := socket.Accept()
+		if err != nil {
+			return
+		}
+		<-done
+		_ = conn.Close()
+	}()
+	kvmServer := &Server{qmpAddress: socketPath, protocol: unixSocketProtocol, timeout: qmplibTimeout}
+	if err := kvmServer.SetQmpConnectPolicy(50*time.Millisecond, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = kvmServer.dialMonitor()
+
+	if err == nil {
+		t.Fatal("expected QMP handshake to time out")
+	}
+	if elapsed := time.Since(start); elapsed > qmpServerOperationTimeout {
+		t.Error("expected handshake to be abandoned after dial timeout, took", elapsed)
+	}
+}
@@ -7,10 +7,16 @@ package kvm
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// maxDevicesOnBus is number of slots of a PCI bus
+const maxDevicesOnBus = 32
+
 type deviceLocation struct {
 	Bus  *string
 	Addr *string
@@ -52,9 +58,16 @@ type busDeviceLocator struct {
 	buses []string
 }
 
+// Calculate maps physical function of endpoint onto configured buses, 32
+// functions per bus in order of buses. Requests falling on a bus, which is
+// not configured, are rejected with InvalidArgument listing valid buses
 func (l busDeviceLocator) Calculate(endpoint *pb.PciEndpoint) (deviceLocation, error) {
 	if endpoint == nil {
-		return deviceLocation{}, fmt.Errorf("pci endpoint is required to calculate device location")
+		return deviceLocation{}, status.Error(codes.InvalidArgument, "pci endpoint is required to calculate device location")
+	}
+	if vf := endpoint.GetVirtualFunction().GetValue(); vf != 0 {
+		msg := fmt.Sprintf("virtual function %d is not supported, devices are plugged to buses as physical functions", vf)
+		return deviceLocation{}, status.Errorf(codes.InvalidArgument, msg)
 	}
 	bus, addr, err := l.calculateBusAddr(endpoint.GetPhysicalFunction().GetValue())
 	if err != nil {
@@ -69,21 +82,14 @@ func (l busDeviceLocator) Calculate(endpoint *pb.PciEndpoint) (deviceLocation, e
 }
 
 func (l busDeviceLocator) calculateBusAddr(physicalFunction int32) (bus string, addr uint32, err error) {
-	if physicalFunction < 0 {
-		err = fmt.Errorf("physical function cannot be negative")
+	maxPhysicalFunction := int32(len(l.buses))*maxDevicesOnBus - 1
+	if physicalFunction < 0 || physicalFunction > maxPhysicalFunction {
+		msg := fmt.Sprintf("physical function %d does not fall on any of valid buses %s, must be 0 to %d",
+			physicalFunction, strings.Join(l.buses, ", "), maxPhysicalFunction)
+		err = status.Errorf(codes.InvalidArgument, msg)
 		return
 	}
-
-	var maxDevicesOnBus int32 = 32
-	for _, qemuBus := range l.buses {
-		if physicalFunction >= maxDevicesOnBus {
-			physicalFunction -= maxDevicesOnBus
-		} else {
-			addr = uint32(physicalFunction)
-			bus = qemuBus
-			return
-		}
-	}
-	err = fmt.Errorf("no corresponding bus found for physical function: %v", physicalFunction)
+	bus = l.buses[physicalFunction/maxDevicesOnBus]
+	addr = uint32(physicalFunction % maxDevicesOnBus)
 	return
 }
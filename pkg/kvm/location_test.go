@@ -7,6 +7,11 @@ package kvm
 import (
 	"reflect"
 	"testing"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 func TestNewDeviceLocator(t *testing.T) {
@@ -54,3 +59,83 @@ func TestNewDeviceLocator(t *testing.T) {
 		})
 	}
 }
+
+func TestBusDeviceLocatorCalculate(t *testing.T) {
+	tests := map[string]struct {
+		endpoint *pb.PciEndpoint
+		bus      string
+		addr     string
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"first function of first bus": {
+			endpoint: &pb.PciEndpoint{PhysicalFunction: wrapperspb.Int32(0)},
+			bus:      "pci.opi.0",
+			addr:     "0x0",
+		},
+		"last function of first bus": {
+			endpoint: &pb.PciEndpoint{PhysicalFunction: wrapperspb.Int32(31)},
+			bus:      "pci.opi.0",
+			addr:     "0x1f",
+		},
+		"function of second bus": {
+			endpoint: &pb.PciEndpoint{
+				PhysicalFunction: wrapperspb.Int32(33),
+				VirtualFunction:  wrapperspb.Int32(0),
+			},
+			bus:  "pci.opi.1",
+			addr: "0x1",
+		},
+		"function on unknown bus": {
+			endpoint: &pb.PciEndpoint{PhysicalFunction: wrapperspb.Int32(64)},
+			errCode:  codes.InvalidArgument,
+			errMsg:   "physical function 64 does not fall on any of valid buses pci.opi.0, pci.opi.1, must be 0 to 63",
+		},
+		"negative physical function": {
+			endpoint: &pb.PciEndpoint{PhysicalFunction: wrapperspb.Int32(-1)},
+			errCode:  codes.InvalidArgument,
+			errMsg:   "physical function -1 does not fall on any of valid buses pci.opi.0, pci.opi.1, must be 0 to 63",
+		},
+		"virtual function": {
+			endpoint: &pb.PciEndpoint{
+				PhysicalFunction: wrapperspb.Int32(1),
+				VirtualFunction:  wrapperspb.Int32(1),
+			},
+			errCode: codes.InvalidArgument,
+			errMsg:  "virtual function 1 is not supported, devices are plugged to buses as physical functions",
+		},
+		"nil endpoint": {
+			endpoint: nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   "pci endpoint is required to calculate device location",
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			locator := newDeviceLocator([]string{"pci.opi.0", "pci.opi.1"})
+
+			location, err := locator.Calculate(tt.endpoint)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+			if tt.errCode != codes.OK {
+				return
+			}
+			if location.Bus == nil || *location.Bus != tt.bus {
+				t.Error("bus: expected", tt.bus, "received", location.Bus)
+			}
+			if location.Addr == nil || *location.Addr != tt.addr {
+				t.Error("addr: expected", tt.addr, "received", location.Addr)
+			}
+		})
+	}
+}
@@ -28,15 +28,15 @@ type monitor struct {
 	pollDevicePresenceStep    time.Duration
 }
 
-func newMonitor(qmpAddress string, protocol string,
+func newMonitor(qmpAddress string, protocol string, dialTimeout time.Duration,
 	timeout time.Duration, pollDevicePresenceStep time.Duration) (*monitor, error) {
-	mon, err := qmp.NewSocketMonitor(protocol, qmpAddress, timeout)
+	mon, err := qmp.NewSocketMonitor(protocol, qmpAddress, dialTimeout)
 	if err != nil {
 		log.Printf("couldn't create QEMU monitor: %v", err)
 		return nil, err
 	}
 
-	if err := mon.Connect(); err != nil {
+	if err := connectMonitor(mon, dialTimeout); err != nil {
 		log.Printf("Failed to connect to QEMU: %v", err)
 		return nil, err
 	}
@@ -50,6 +50,27 @@ func newMonitor(qmpAddress string, protocol string,
 		pollDevicePresenceStep:    pollDevicePresenceStep}, nil
 }
 
+// connectMonitor negotiates QMP capabilities within timeout. qmp.SocketMonitor
+// waits for QEMU greeting without deadline, so QEMU accepting connections
+// but not answering is disconnected to unblock it
+func connectMonitor(mon *qmp.SocketMonitor, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- mon.Connect()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			_ = mon.Disconnect()
+		}
+		return err
+	case <-time.After(timeout):
+		_ = mon.Disconnect()
+		<-done
+		return fmt.Errorf("QEMU did not answer QMP handshake within %v", timeout)
+	}
+}
+
 func (m *monitor) Disconnect() {
 	err := m.mon.Disconnect()
 	if err != nil {
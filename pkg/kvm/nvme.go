@@ -11,10 +11,12 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"google.golang.org/protobuf/types/known/emptypb"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/frontend"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
 
@@ -34,7 +36,7 @@ func (s *Server) CreateNvmeController(ctx context.Context, in *pb.CreateNvmeCont
 	location, err := s.locator.Calculate(in.GetNvmeController().GetSpec().GetPcieId())
 	if err != nil {
 		log.Println("Failed to calculate device location: ", err)
-		return nil, errDeviceEndpoint
+		return nil, err
 	}
 
 	// Create request can miss Name field which is generated in spdk bridge.
@@ -44,6 +46,10 @@ func (s *Server) CreateNvmeController(ctx context.Context, in *pb.CreateNvmeCont
 		log.Println("Failed to get subsystem id from:", in.Parent)
 		return nil, errInvalidSubsystem
 	}
+	// QEMU and controller directory are left untouched by dry run
+	if utils.DryRunRequested(ctx) {
+		return s.Server.CreateNvmeController(ctx, in)
+	}
 
 	err = createControllerDir(s.ctrlrDir, dirName)
 	if err != nil {
@@ -59,7 +65,15 @@ func (s *Server) CreateNvmeController(ctx context.Context, in *pb.CreateNvmeCont
 	}
 	name := out.Name
 
-	mon, monErr := newMonitor(s.qmpAddress, s.protocol, s.timeout, s.pollDevicePresenceStep)
+	location, err = s.assignments.assign(name, location)
+	if err != nil {
+		log.Println("Couldn't assign device location:", err)
+		_, _ = s.Server.DeleteNvmeController(context.Background(), &pb.DeleteNvmeControllerRequest{Name: name})
+		_ = deleteControllerDir(s.ctrlrDir, dirName)
+		return nil, err
+	}
+
+	mon, monErr := s.dialMonitor()
 	if monErr != nil {
 		log.Println("Couldn't create QEMU monitor")
 		_, _ = s.Server.DeleteNvmeController(context.Background(), &pb.DeleteNvmeControllerRequest{Name: name})
@@ -80,12 +94,12 @@ func (s *Server) CreateNvmeController(ctx context.Context, in *pb.CreateNvmeCont
 
 // DeleteNvmeController deletes an Nvme controller device and detaches it from QEMU instance
 func (s *Server) DeleteNvmeController(ctx context.Context, in *pb.DeleteNvmeControllerRequest) (*emptypb.Empty, error) {
-	controller, ok := s.Nvme.Controllers[in.GetName()]
-	if !ok || controller.GetSpec().GetTrtype() != pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE {
+	controller, ok := s.FindNvmeController(in.GetName())
+	if !ok || controller.GetSpec().GetTrtype() != pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE || utils.DryRunRequested(ctx) {
 		return s.Server.DeleteNvmeController(ctx, in)
 	}
 
-	mon, monErr := newMonitor(s.qmpAddress, s.protocol, s.timeout, s.pollDevicePresenceStep)
+	mon, monErr := s.dialMonitor()
 	if monErr != nil {
 		log.Println("Couldn't create QEMU monitor")
 		return nil, errMonitorCreation
@@ -102,6 +116,8 @@ func (s *Server) DeleteNvmeController(ctx context.Context, in *pb.DeleteNvmeCont
 	delNvmeErr := mon.DeleteNvmeControllerDevice(qemuDeviceID)
 	if delNvmeErr != nil {
 		log.Printf("Couldn't delete Nvme controller: %v", delNvmeErr)
+	} else {
+		s.assignments.release(in.Name)
 	}
 
 	response, spdkErr := s.Server.DeleteNvmeController(ctx, in)
@@ -123,8 +139,33 @@ func (s *Server) DeleteNvmeController(ctx context.Context, in *pb.DeleteNvmeCont
 	return response, err
 }
 
+// DeleteNvmeSubsystem unplugs Nvme controllers of subsystem from QEMU
+// before cascade delete, since the underlying server removes only their
+// SPDK part
+func (s *Server) DeleteNvmeSubsystem(ctx context.Context, in *pb.DeleteNvmeSubsystemRequest) (*emptypb.Empty, error) {
+	cascade, err := frontend.NvmeSubsystemCascadeRequested(ctx)
+	if err != nil || !cascade || utils.DryRunRequested(ctx) {
+		return s.Server.DeleteNvmeSubsystem(ctx, in)
+	}
+	subsysID := utils.ResourceNameToID(in.GetName())
+	var controllers []string
+	for name, controller := range s.NvmeControllersSnapshot() {
+		if controller.GetSpec().GetTrtype() == pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE &&
+			utils.GetSubsystemIDFromNvmeName(name) == subsysID {
+			controllers = append(controllers, name)
+		}
+	}
+	sort.Strings(controllers)
+	for _, name := range controllers {
+		if _, err := s.DeleteNvmeController(ctx, &pb.DeleteNvmeControllerRequest{Name: name}); err != nil {
+			return nil, err
+		}
+	}
+	return s.Server.DeleteNvmeSubsystem(ctx, in)
+}
+
 func (s *Server) findDirName(name string) (string, error) {
-	ctrlr, ok := s.Server.Nvme.Controllers[name]
+	ctrlr, ok := s.FindNvmeController(name)
 	if !ok {
 		return "", errNoController
 	}
@@ -20,6 +20,7 @@ import (
 	"github.com/opiproject/opi-spdk-bridge/pkg/frontend"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/wrapperspb"
@@ -210,8 +211,8 @@ func TestCreateNvmeController(t *testing.T) {
 		"Nvme creation with physical function goes out of buses": {
 			in:      testCreateNvmeControllerRequest,
 			out:     nil,
-			errCode: status.Convert(errDeviceEndpoint).Code(),
-			errMsg:  status.Convert(errDeviceEndpoint).Message(),
+			errCode: codes.InvalidArgument,
+			errMsg:  "physical function 43 does not fall on any of valid buses pci.opi.0, must be 0 to 31",
 			jsonRPC: alwaysSuccessfulJSONRPC,
 			buses:   []string{"pci.opi.0"},
 		},
@@ -235,8 +236,8 @@ func TestCreateNvmeController(t *testing.T) {
 					},
 				}, NvmeControllerId: testNvmeControllerID},
 			out:     nil,
-			errCode: status.Convert(errDeviceEndpoint).Code(),
-			errMsg:  status.Convert(errDeviceEndpoint).Message(),
+			errCode: codes.InvalidArgument,
+			errMsg:  "physical function -1 does not fall on any of valid buses pci.opi.0, must be 0 to 31",
 			jsonRPC: alwaysSuccessfulJSONRPC,
 			buses:   []string{"pci.opi.0"},
 		},
@@ -276,7 +277,7 @@ func TestCreateNvmeController(t *testing.T) {
 					pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE: NewNvmeVfiouserTransport(qmpServer.testDir, tt.jsonRPC),
 				}, frontend.NewVhostUserBlkTransport())
 			opiSpdkServer.Nvme.Subsystems[testSubsystemName] = &testSubsystem
-			kvmServer := NewServer(opiSpdkServer, qmpAddress, qmpServer.testDir, tt.buses)
+			kvmServer := NewServer(opiSpdkServer, store, qmpAddress, qmpServer.testDir, tt.buses)
 			kvmServer.timeout = qmplibTimeout
 			testCtrlrDir := controllerDirPath(qmpServer.testDir, testSubsystemID)
 			if tt.ctrlrDirExistsBeforeOperation &&
@@ -433,7 +434,7 @@ func TestDeleteNvmeController(t *testing.T) {
 					utils.ProtoClone(testCreateNvmeControllerRequest.NvmeController)
 				opiSpdkServer.Nvme.Controllers[testNvmeControllerName].Name = testNvmeControllerName
 			}
-			kvmServer := NewServer(opiSpdkServer, qmpAddress, qmpServer.testDir, nil)
+			kvmServer := NewServer(opiSpdkServer, store, qmpAddress, qmpServer.testDir, nil)
 			kvmServer.timeout = qmplibTimeout
 			testCtrlrDir := controllerDirPath(qmpServer.testDir, testSubsystemID)
 			if tt.ctrlrDirExistsBeforeOperation {
@@ -473,3 +474,46 @@ func TestDeleteNvmeController(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteNvmeSubsystemCascade(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	options := gomap.DefaultOptions
+	options.Codec = utils.ProtoCodec{}
+	store := gomap.NewStore(options)
+	qmpServer := startMockQmpServer(t, newMockQmpCalls().
+		ExpectDeleteNvmeController(testNvmeControllerID).
+		ExpectNoDeviceQueryPci())
+	defer qmpServer.Stop()
+	opiSpdkServer := frontend.NewCustomizedServer(alwaysSuccessfulJSONRPC, store,
+		map[pb.NvmeTransportType]frontend.NvmeTransport{
+			pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE: NewNvmeVfiouserTransport(qmpServer.testDir, alwaysSuccessfulJSONRPC),
+		}, frontend.NewVhostUserBlkTransport())
+	opiSpdkServer.Nvme.Subsystems[testSubsystemName] = &testSubsystem
+	opiSpdkServer.Nvme.Controllers[testNvmeControllerName] =
+		utils.ProtoClone(testCreateNvmeControllerRequest.NvmeController)
+	opiSpdkServer.Nvme.Controllers[testNvmeControllerName].Name = testNvmeControllerName
+	kvmServer := NewServer(opiSpdkServer, store, qmpServer.socketPath, qmpServer.testDir, nil)
+	kvmServer.timeout = qmplibTimeout
+	testCtrlrDir := controllerDirPath(qmpServer.testDir, testSubsystemID)
+	if err := os.Mkdir(testCtrlrDir, os.ModePerm); err != nil {
+		log.Panic(err)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(frontend.NvmeSubsystemCascadeMetadataKey, "true"))
+
+	_, err := kvmServer.DeleteNvmeSubsystem(ctx, &pb.DeleteNvmeSubsystemRequest{Name: testSubsystemName})
+
+	if err != nil {
+		t.Error("expected no error, received", err)
+	}
+	if !qmpServer.WereExpectedCallsPerformed() {
+		t.Errorf("Not all expected calls were performed")
+	}
+	if dirExists(testCtrlrDir) {
+		t.Error("expected controller dir to be deleted")
+	}
+	if len(opiSpdkServer.Nvme.Controllers) != 0 || len(opiSpdkServer.Nvme.Subsystems) != 0 {
+		t.Error("expected controller and subsystem to be deleted, received",
+			opiSpdkServer.Nvme.Controllers, opiSpdkServer.Nvme.Subsystems)
+	}
+}
@@ -68,7 +68,13 @@ func (c *nvmeVfiouserTransport) CreateController(
 		return status.Error(codes.InvalidArgument, "hostnqn for subsystem is not supported for vfiouser")
 	}
 
-	params := c.params(ctrlr, subsys)
+	params := frontend.NvmfSubsystemAddListenerParams{
+		NvmfSubsystemAddListenerParams: c.params(ctrlr, subsys),
+		NvmeControllerQueues:           frontend.NewNvmeControllerQueues(ctrlr.GetSpec()),
+	}
+	if utils.SkipSpdkCallInDryRun(ctx, "nvmf_subsystem_add_listener", &params) {
+		return nil
+	}
 	var result spdk.NvmfSubsystemAddListenerResult
 	err := c.rpc.Call(ctx, "nvmf_subsystem_add_listener", &params, &result)
 	if err != nil {
@@ -89,6 +95,9 @@ func (c *nvmeVfiouserTransport) DeleteController(
 	subsys *pb.NvmeSubsystem,
 ) error {
 	params := c.params(ctrlr, subsys)
+	if utils.SkipSpdkCallInDryRun(ctx, "nvmf_subsystem_remove_listener", &params) {
+		return nil
+	}
 	var result spdk.NvmfSubsystemAddListenerResult
 	err := c.rpc.Call(ctx, "nvmf_subsystem_remove_listener", &params, &result)
 	if err != nil {
@@ -13,6 +13,7 @@ import (
 
 	"github.com/opiproject/gospdk/spdk"
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/frontend"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
@@ -72,6 +73,23 @@ func TestNewNvmeVfiouserTransport(t *testing.T) {
 	}
 }
 
+func testVfiouserListenerParams(ctrlrDir string) spdk.NvmfSubsystemAddListenerParams {
+	return spdk.NvmfSubsystemAddListenerParams{
+		Nqn: "nqn.2014-08.org.nvmexpress:uuid:1630a3a6-5bac-4563-a1a6-d2b0257c282a",
+		ListenAddress: struct {
+			Trtype  string "json:\"trtype\""
+			Traddr  string "json:\"traddr\""
+			Trsvcid string "json:\"trsvcid,omitempty\""
+			Adrfam  string "json:\"adrfam,omitempty\""
+		}{
+			Trtype:  "vfiouser",
+			Traddr:  filepath.Join(ctrlrDir, "subsys0"),
+			Trsvcid: "",
+			Adrfam:  "",
+		},
+	}
+}
+
 func TestNvmeVfiouserTransportCreateController(t *testing.T) {
 	tmpDir := t.TempDir()
 	tests := map[string]struct {
@@ -79,6 +97,8 @@ func TestNvmeVfiouserTransportCreateController(t *testing.T) {
 		vf         int32
 		port       int32
 		hostnqn    string
+		maxNsq     int32
+		sqes       int32
 		wantErr    bool
 		wantParams any
 	}{
@@ -112,19 +132,21 @@ func TestNvmeVfiouserTransportCreateController(t *testing.T) {
 			port:    0,
 			hostnqn: "",
 			wantErr: false,
-			wantParams: &spdk.NvmfSubsystemAddListenerParams{
-				Nqn: "nqn.2014-08.org.nvmexpress:uuid:1630a3a6-5bac-4563-a1a6-d2b0257c282a",
-				ListenAddress: struct {
-					Trtype  string "json:\"trtype\""
-					Traddr  string "json:\"traddr\""
-					Trsvcid string "json:\"trsvcid,omitempty\""
-					Adrfam  string "json:\"adrfam,omitempty\""
-				}{
-					Trtype:  "vfiouser",
-					Traddr:  filepath.Join(tmpDir, "subsys0"),
-					Trsvcid: "",
-					Adrfam:  "",
-				},
+			wantParams: &frontend.NvmfSubsystemAddListenerParams{
+				NvmfSubsystemAddListenerParams: testVfiouserListenerParams(tmpDir),
+			},
+		},
+		"successful params with queue limits": {
+			pf:      3,
+			vf:      0,
+			port:    0,
+			hostnqn: "",
+			maxNsq:  4,
+			sqes:    7,
+			wantErr: false,
+			wantParams: &frontend.NvmfSubsystemAddListenerParams{
+				NvmfSubsystemAddListenerParams: testVfiouserListenerParams(tmpDir),
+				NvmeControllerQueues:           frontend.NvmeControllerQueues{MaxIoQpairs: 4, MaxQueueDepth: 128},
 			},
 		},
 	}
@@ -146,6 +168,8 @@ func TestNvmeVfiouserTransportCreateController(t *testing.T) {
 							},
 						},
 						Trtype: pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE,
+						MaxNsq: tt.maxNsq,
+						Sqes:   tt.sqes,
 					},
 				}, &pb.NvmeSubsystem{
 					Spec: &pb.NvmeSubsystemSpec{
@@ -0,0 +1,293 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package middleend implements the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// CompositeVolumeServiceName is full name of the service creating encrypted
+// volumes with QoS limits in a single call. It is not part of OPI API, so it
+// is registered with a hand written service descriptor
+const CompositeVolumeServiceName = "opi_spdk_bridge.v1.CompositeVolumeService"
+
+const (
+	compositeEncryptedVolumeSuffix = "-crypto"
+	compositeQosVolumeSuffix       = "-qos"
+)
+
+// compositeVolumeRequest is CreateCompositeVolume request. Encrypted volume
+// and limits are in protobuf JSON format
+type compositeVolumeRequest struct {
+	CompositeVolumeID string          `json:"composite_volume_id"`
+	EncryptedVolume   json.RawMessage `json:"encrypted_volume"`
+	Limits            json.RawMessage `json:"limits"`
+}
+
+// CompositeVolume is encrypted volume with QoS limits applied on top of it,
+// created and deleted as a whole
+type CompositeVolume struct {
+	Name string `json:"name"`
+	// BdevName is name of the crypto bdev to be exposed to hosts
+	BdevName string `json:"bdev_name"`
+	// Volumes are names of encrypted and QoS volumes composing the
+	// composite volume in creation order
+	Volumes []string `json:"volumes"`
+}
+
+// GetName returns name of composite volume
+func (v *CompositeVolume) GetName() string {
+	return v.Name
+}
+
+// ComposeVolume creates encrypted volume on top of volume referenced by
+// encrypted and QoS volume with limits on top of the crypto bdev. Created
+// volumes are named by id with -crypto and -qos suffixes. If QoS volume
+// cannot be created, encrypted volume is deleted again
+func (s *Server) ComposeVolume(ctx context.Context, id string, encrypted *pb.EncryptedVolume, limits *pb.Limits) (*CompositeVolume, error) {
+	if err := resourceid.ValidateUserSettable(id); err != nil {
+		msg := fmt.Sprintf("invalid composite_volume_id: %v", err)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	encryptedID := id + compositeEncryptedVolumeSuffix
+	qosVolume := &pb.QosVolume{
+		Name:          utils.ResourceIDToVolumeName(id + compositeQosVolumeSuffix),
+		VolumeNameRef: encryptedID,
+		Limits:        limits,
+	}
+	// reject invalid cipher and limits before anything is created
+	if _, err := s.expectedKeyLengthInBits(encrypted.Cipher); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if limits.GetMax() == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "missing required field: limits.max")
+	}
+	if err := s.verifyQosVolume(qosVolume); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	name := utils.ResourceIDToVolumeName(id)
+	unlock := s.resourceLocks.Lock(name)
+	defer unlock()
+	s.mapsMu.RLock()
+	volume, ok := s.volumes.compositeVolumes[name]
+	s.mapsMu.RUnlock()
+	if ok {
+		log.Printf("Already existing CompositeVolume with name %v", name)
+		return volume, nil
+	}
+
+	encryptedVolume, err := s.CreateEncryptedVolume(ctx, &pb.CreateEncryptedVolumeRequest{
+		EncryptedVolume:   encrypted,
+		EncryptedVolumeId: encryptedID,
+	})
+	if err != nil {
+		return nil, status.Convert(err).Err()
+	}
+	qosVolume, err = s.CreateQosVolume(ctx, &pb.CreateQosVolumeRequest{
+		QosVolume:   qosVolume,
+		QosVolumeId: id + compositeQosVolumeSuffix,
+	})
+	if err != nil {
+		log.Printf("error: failed to create QoS volume of %v, deleting %v: %v", name, encryptedVolume.Name, err)
+		if _, rerr := s.DeleteEncryptedVolume(ctx, &pb.DeleteEncryptedVolumeRequest{Name: encryptedVolume.Name}); rerr != nil {
+			log.Printf("error: failed to delete %v: %v", encryptedVolume.Name, rerr)
+		}
+		return nil, status.Convert(err).Err()
+	}
+
+	response := &CompositeVolume{
+		Name:     name,
+		BdevName: encryptedID,
+		Volumes:  []string{encryptedVolume.Name, qosVolume.Name},
+	}
+	if utils.DryRunRequested(ctx) {
+		return response, nil
+	}
+	s.mapsMu.Lock()
+	s.volumes.compositeVolumes[name] = response
+	s.mapsMu.Unlock()
+	return response, nil
+}
+
+// DecomposeVolume deletes volumes composing composite volume in reverse
+// order of creation. Deleted volumes are dropped from the composite volume
+// one by one, so that a failed delete can be retried
+func (s *Server) DecomposeVolume(ctx context.Context, name string, allowMissing bool) error {
+	if err := resourcename.Validate(name); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	unlock := s.resourceLocks.Lock(name)
+	defer unlock()
+	s.mapsMu.RLock()
+	volume, ok := s.volumes.compositeVolumes[name]
+	s.mapsMu.RUnlock()
+	if !ok {
+		if allowMissing {
+			return nil
+		}
+		return status.Errorf(codes.NotFound, "unable to find key %s", name)
+	}
+	for i := len(volume.Volumes) - 1; i >= 0; i-- {
+		member := volume.Volumes[i]
+		var err error
+		if strings.HasSuffix(member, compositeQosVolumeSuffix) {
+			_, err = s.DeleteQosVolume(ctx, &pb.DeleteQosVolumeRequest{Name: member, AllowMissing: true})
+		} else {
+			_, err = s.DeleteEncryptedVolume(ctx, &pb.DeleteEncryptedVolumeRequest{Name: member, AllowMissing: true})
+		}
+		if err != nil {
+			return status.Convert(err).Err()
+		}
+		if utils.DryRunRequested(ctx) {
+			continue
+		}
+		s.mapsMu.Lock()
+		volume.Volumes = volume.Volumes[:i]
+		s.mapsMu.Unlock()
+	}
+	if utils.DryRunRequested(ctx) {
+		return nil
+	}
+	s.mapsMu.Lock()
+	delete(s.volumes.compositeVolumes, name)
+	s.mapsMu.Unlock()
+	return nil
+}
+
+// CreateCompositeVolume creates encrypted volume with QoS limits in a single
+// call. Request is a struct with composite_volume_id, encrypted_volume and
+// limits, response is CompositeVolume struct
+func (s *Server) CreateCompositeVolume(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	data, err := in.MarshalJSON()
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	var request compositeVolumeRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	if request.EncryptedVolume == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "missing required field: encrypted_volume")
+	}
+	if request.Limits == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "missing required field: limits")
+	}
+	encrypted := &pb.EncryptedVolume{}
+	if err := protojson.Unmarshal(request.EncryptedVolume, encrypted); err != nil {
+		msg := fmt.Sprintf("invalid encrypted_volume: %v", err)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	limits := &pb.Limits{}
+	if err := protojson.Unmarshal(request.Limits, limits); err != nil {
+		msg := fmt.Sprintf("invalid limits: %v", err)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+
+	volume, err := s.ComposeVolume(ctx, request.CompositeVolumeID, encrypted, limits)
+	if err != nil {
+		return nil, err
+	}
+	data, err = json.Marshal(volume)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	response := &structpb.Struct{}
+	if err := response.UnmarshalJSON(data); err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	return response, nil
+}
+
+// DeleteCompositeVolume deletes composite volume with all volumes composing
+// it. Request is a struct with name and optional allow_missing
+func (s *Server) DeleteCompositeVolume(ctx context.Context, in *structpb.Struct) (*emptypb.Empty, error) {
+	name := in.GetFields()["name"].GetStringValue()
+	if name == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "missing required field: name")
+	}
+	allowMissing := in.GetFields()["allow_missing"].GetBoolValue()
+	if err := s.DecomposeVolume(ctx, name, allowMissing); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// compositeVolumeServiceServer is implemented by Server
+type compositeVolumeServiceServer interface {
+	CreateCompositeVolume(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	DeleteCompositeVolume(context.Context, *structpb.Struct) (*emptypb.Empty, error)
+}
+
+var compositeVolumeServiceDesc = grpc.ServiceDesc{
+	ServiceName: CompositeVolumeServiceName,
+	HandlerType: (*compositeVolumeServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateCompositeVolume",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(structpb.Struct)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(compositeVolumeServiceServer).CreateCompositeVolume(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: "/" + CompositeVolumeServiceName + "/CreateCompositeVolume",
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(compositeVolumeServiceServer).CreateCompositeVolume(ctx, req.(*structpb.Struct))
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		},
+		{
+			MethodName: "DeleteCompositeVolume",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(structpb.Struct)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(compositeVolumeServiceServer).DeleteCompositeVolume(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: "/" + CompositeVolumeServiceName + "/DeleteCompositeVolume",
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(compositeVolumeServiceServer).DeleteCompositeVolume(ctx, req.(*structpb.Struct))
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterCompositeVolumeServer registers composite volume service on s.
+// Key of encrypted volume is redacted in logged requests
+func RegisterCompositeVolumeServer(s *grpc.Server, srv *Server) {
+	utils.RedactLoggedStructFields(CompositeVolumeServiceName, "encrypted_volume.key")
+	s.RegisterService(&compositeVolumeServiceDesc, srv)
+}
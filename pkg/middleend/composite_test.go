@@ -0,0 +1,364 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package middleend implements the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"github.com/opiproject/gospdk/spdk"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var (
+	testCompositeVolumeID   = "composite-test"
+	testCompositeVolumeName = utils.ResourceIDToVolumeName(testCompositeVolumeID)
+	testCompositeVolume     = CompositeVolume{
+		Name:     testCompositeVolumeName,
+		BdevName: testCompositeVolumeID + "-crypto",
+		Volumes: []string{
+			utils.ResourceIDToVolumeName(testCompositeVolumeID + "-crypto"),
+			utils.ResourceIDToVolumeName(testCompositeVolumeID + "-qos"),
+		},
+	}
+)
+
+func TestMiddleEnd_CreateCompositeVolume(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	encrypted := map[string]interface{}{
+		"volume_name_ref": encryptedVolume.VolumeNameRef,
+		"key":             base64.StdEncoding.EncodeToString(encryptedVolume.Key),
+		"cipher":          encryptedVolume.Cipher.String(),
+	}
+	limits := map[string]interface{}{"max": map[string]interface{}{"rw_bandwidth_mbs": 1}}
+
+	tests := map[string]struct {
+		encrypted interface{}
+		limits    interface{}
+		exist     bool
+		spdk      []string
+		methods   []string
+		stored    bool
+		errCode   codes.Code
+		errMsg    string
+	}{
+		"valid request": {
+			encrypted: encrypted,
+			limits:    limits,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":"composite-test-crypto"}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			methods: []string{"accel_crypto_key_create", "bdev_crypto_create", "bdev_set_qos_limit"},
+			stored:  true,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"qos failure rolls back encrypted volume": {
+			encrypted: encrypted,
+			limits:    limits,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":"composite-test-crypto"}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":false}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			methods: []string{
+				"accel_crypto_key_create", "bdev_crypto_create", "bdev_set_qos_limit",
+				"bdev_crypto_delete", "accel_crypto_key_destroy",
+			},
+			stored:  false,
+			errCode: status.Convert(spdk.ErrUnexpectedSpdkCallResult).Code(),
+			errMsg:  status.Convert(spdk.ErrUnexpectedSpdkCallResult).Message(),
+		},
+		"encrypted volume failure": {
+			encrypted: encrypted,
+			limits:    limits,
+			spdk:      []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":false}`},
+			methods:   []string{"accel_crypto_key_create"},
+			stored:    false,
+			errCode:   codes.Unknown,
+			errMsg:    "accel_crypto_key_create: json response error: myopierr",
+		},
+		"already exists": {
+			encrypted: encrypted,
+			limits:    limits,
+			exist:     true,
+			spdk:      []string{},
+			methods:   nil,
+			stored:    true,
+			errCode:   codes.OK,
+			errMsg:    "",
+		},
+		"unsupported cipher": {
+			encrypted: map[string]interface{}{
+				"volume_name_ref": encryptedVolume.VolumeNameRef,
+				"key":             base64.StdEncoding.EncodeToString(encryptedVolume.Key),
+				"cipher":          pb.EncryptionType_ENCRYPTION_TYPE_UNSPECIFIED.String(),
+			},
+			limits:  limits,
+			spdk:    []string{},
+			methods: nil,
+			stored:  false,
+			errCode: codes.InvalidArgument,
+			errMsg:  "only AES_XTS_256 and AES_XTS_128 are supported",
+		},
+		"negative limit": {
+			encrypted: encrypted,
+			limits:    map[string]interface{}{"max": map[string]interface{}{"rw_bandwidth_mbs": -1}},
+			spdk:      []string{},
+			methods:   nil,
+			stored:    false,
+			errCode:   codes.InvalidArgument,
+			errMsg:    "QoS volume max_limit rw_bandwidth_mbs cannot be negative",
+		},
+		"missing max limit": {
+			encrypted: encrypted,
+			limits:    map[string]interface{}{},
+			spdk:      []string{},
+			methods:   nil,
+			stored:    false,
+			errCode:   codes.InvalidArgument,
+			errMsg:    "missing required field: limits.max",
+		},
+		"missing limits": {
+			encrypted: encrypted,
+			limits:    nil,
+			spdk:      []string{},
+			methods:   nil,
+			stored:    false,
+			errCode:   codes.InvalidArgument,
+			errMsg:    "missing required field: limits",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+			if tt.exist {
+				volume := testCompositeVolume
+				testEnv.opiSpdkServer.volumes.compositeVolumes[testCompositeVolumeName] = &volume
+			}
+
+			fields := map[string]interface{}{
+				"composite_volume_id": testCompositeVolumeID,
+				"encrypted_volume":    tt.encrypted,
+			}
+			if tt.limits != nil {
+				fields["limits"] = tt.limits
+			}
+			in, err := structpb.NewStruct(fields)
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+			response, err := testEnv.opiSpdkServer.CreateCompositeVolume(testEnv.ctx, in)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+			if !reflect.DeepEqual(recorder.methods, tt.methods) {
+				t.Error("methods: expected", tt.methods, "received", recorder.methods)
+			}
+			if err == nil && response.GetFields()["bdev_name"].GetStringValue() != testCompositeVolume.BdevName {
+				t.Error("response: expected bdev", testCompositeVolume.BdevName, "received", response)
+			}
+
+			volume, ok := testEnv.opiSpdkServer.volumes.compositeVolumes[testCompositeVolumeName]
+			if ok != tt.stored {
+				t.Error("expect composite volume stored", tt.stored, "received", ok)
+			}
+			if ok && !reflect.DeepEqual(*volume, testCompositeVolume) {
+				t.Error("stored: expected", testCompositeVolume, "received", *volume)
+			}
+			if !tt.exist && len(testEnv.opiSpdkServer.volumes.encVolumes) != len(testEnv.opiSpdkServer.volumes.qosVolumes) {
+				t.Error("expected no partially created composite volume, received",
+					testEnv.opiSpdkServer.volumes.encVolumes, testEnv.opiSpdkServer.volumes.qosVolumes)
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_DeleteCompositeVolume(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	encryptedName := testCompositeVolume.Volumes[0]
+	qosName := testCompositeVolume.Volumes[1]
+
+	tests := map[string]struct {
+		name         string
+		allowMissing bool
+		exist        bool
+		spdk         []string
+		methods      []string
+		remaining    []string
+		errCode      codes.Code
+		errMsg       string
+	}{
+		"valid request": {
+			name:  testCompositeVolumeName,
+			exist: true,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			methods:   []string{"bdev_set_qos_limit", "bdev_crypto_delete", "accel_crypto_key_destroy"},
+			remaining: nil,
+			errCode:   codes.OK,
+			errMsg:    "",
+		},
+		"encrypted volume failure keeps it in chain": {
+			name:  testCompositeVolumeName,
+			exist: true,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":false}`,
+			},
+			methods:   []string{"bdev_set_qos_limit", "bdev_crypto_delete"},
+			remaining: []string{encryptedName},
+			errCode:   codes.Unknown,
+			errMsg:    "bdev_crypto_delete: json response error: myopierr",
+		},
+		"qos failure keeps whole chain": {
+			name:  testCompositeVolumeName,
+			exist: true,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":false}`,
+			},
+			methods:   []string{"bdev_set_qos_limit"},
+			remaining: []string{encryptedName, qosName},
+			errCode:   status.Convert(spdk.ErrUnexpectedSpdkCallResult).Code(),
+			errMsg:    status.Convert(spdk.ErrUnexpectedSpdkCallResult).Message(),
+		},
+		"not found": {
+			name:      testCompositeVolumeName,
+			exist:     false,
+			spdk:      []string{},
+			methods:   nil,
+			remaining: nil,
+			errCode:   codes.NotFound,
+			errMsg:    "unable to find key " + testCompositeVolumeName,
+		},
+		"not found with allow missing": {
+			name:         testCompositeVolumeName,
+			allowMissing: true,
+			exist:        false,
+			spdk:         []string{},
+			methods:      nil,
+			remaining:    nil,
+			errCode:      codes.OK,
+			errMsg:       "",
+		},
+		"missing name": {
+			name:      "",
+			exist:     true,
+			spdk:      []string{},
+			methods:   nil,
+			remaining: []string{encryptedName, qosName},
+			errCode:   codes.InvalidArgument,
+			errMsg:    "missing required field: name",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+			if tt.exist {
+				volume := testCompositeVolume
+				volume.Volumes = append([]string{}, testCompositeVolume.Volumes...)
+				testEnv.opiSpdkServer.volumes.compositeVolumes[testCompositeVolumeName] = &volume
+				testEnv.opiSpdkServer.volumes.encVolumes[encryptedName] = &pb.EncryptedVolume{
+					Name:          encryptedName,
+					VolumeNameRef: encryptedVolume.VolumeNameRef,
+					Cipher:        encryptedVolume.Cipher,
+				}
+				testEnv.opiSpdkServer.volumes.qosVolumes[qosName] = &pb.QosVolume{
+					Name:          qosName,
+					VolumeNameRef: testCompositeVolume.BdevName,
+					Limits:        &pb.Limits{Max: &pb.QosLimit{RwBandwidthMbs: 1}},
+				}
+			}
+
+			in, err := structpb.NewStruct(map[string]interface{}{"name": tt.name, "allow_missing": tt.allowMissing})
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+			_, err = testEnv.opiSpdkServer.DeleteCompositeVolume(testEnv.ctx, in)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+			if !reflect.DeepEqual(recorder.methods, tt.methods) {
+				t.Error("methods: expected", tt.methods, "received", recorder.methods)
+			}
+
+			var remaining []string
+			if volume, ok := testEnv.opiSpdkServer.volumes.compositeVolumes[testCompositeVolumeName]; ok {
+				remaining = volume.Volumes
+			}
+			if !reflect.DeepEqual(remaining, tt.remaining) {
+				t.Error("remaining volumes: expected", tt.remaining, "received", remaining)
+			}
+		})
+	}
+}
+
+func TestRegisterCompositeVolumeServer_KeyNotLogged(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	RegisterCompositeVolumeServer(grpc.NewServer(), testEnv.opiSpdkServer)
+	request, err := structpb.NewStruct(map[string]interface{}{
+		"composite_volume_id": testCompositeVolumeID,
+		"encrypted_volume":    map[string]interface{}{"volume_name_ref": "volume-test", "key": "c2VjcmV0LWtleQ=="},
+		"limits":              map[string]interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	logger := utils.InterceptorLogger(log.New(&buf, "", 0))
+
+	logger.Log(context.Background(), logging.LevelInfo, "started call",
+		logging.ServiceFieldKey, CompositeVolumeServiceName, "grpc.request.content", request)
+
+	output := buf.String()
+	if strings.Contains(output, "c2VjcmV0LWtleQ==") || !strings.Contains(output, utils.RedactedPlaceholder) {
+		t.Error("expected key to be redacted, received", output)
+	}
+	if !strings.Contains(output, "volume-test") {
+		t.Error("expected other fields to be logged, received", output)
+	}
+}
@@ -6,11 +6,11 @@
 package middleend
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"fmt"
 	"log"
-	"path"
 	"sort"
 
 	"github.com/google/uuid"
@@ -21,7 +21,9 @@ import (
 	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/resourceid"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -31,10 +33,40 @@ func sortEncryptedVolumes(volumes []*pb.EncryptedVolume) {
 	})
 }
 
+// SharedCryptoKeyMetadataKey is request metadata key referencing an existing
+// accel crypto key to be used instead of creating a per-volume one
+const SharedCryptoKeyMetadataKey = "opi-crypto-key-name"
+
+// CryptoKeyEncodingMetadataKey is request metadata key specifying encoding
+// (hex or base64) of the key provided in EncryptedVolume. Key is used as raw
+// bytes when no encoding is specified
+const CryptoKeyEncodingMetadataKey = "opi-crypto-key-encoding"
+
+// WeakCryptoKeyCheckFeature is feature flag enabling rejection of all-zero
+// and low-entropy keys
+const WeakCryptoKeyCheckFeature = "weak_crypto_key_check"
+
+func sharedCryptoKeyFromContext(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(SharedCryptoKeyMetadataKey); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// sharedKeyName returns externally managed crypto key of encrypted volume
+func (s *Server) sharedKeyName(volumeName string) (string, bool) {
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	keyName, shared := s.volumes.sharedKeys[volumeName]
+	return keyName, shared
+}
+
 // CreateEncryptedVolume creates an encrypted volume
 func (s *Server) CreateEncryptedVolume(ctx context.Context, in *pb.CreateEncryptedVolumeRequest) (*pb.EncryptedVolume, error) {
+	sharedKeyName := sharedCryptoKeyFromContext(ctx)
 	// check input correctness
-	if err := s.validateCreateEncryptedVolumeRequest(in); err != nil {
+	if err := s.validateCreateEncryptedVolumeRequest(in, sharedKeyName); err != nil {
 		return nil, err
 	}
 	// see https://google.aip.dev/133#user-specified-ids
@@ -45,34 +77,67 @@ func (s *Server) CreateEncryptedVolume(ctx context.Context, in *pb.CreateEncrypt
 	}
 	in.EncryptedVolume.Name = utils.ResourceIDToVolumeName(resourceID)
 
-	if err := s.verifyEncryptedVolume(in.EncryptedVolume); err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+	// volume with decoded key material, used only to create the key
+	keyedVolume := in.EncryptedVolume
+	if sharedKeyName == "" {
+		var err error
+		if keyedVolume, err = decodeEncryptedVolumeKey(ctx, in.EncryptedVolume); err != nil {
+			return nil, err
+		}
+		if err := s.verifyEncryptedVolume(keyedVolume); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	} else {
+		if _, err := s.expectedKeyLengthInBits(in.EncryptedVolume.Cipher); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 	}
 
+	unlock := s.resourceLocks.Lock(in.EncryptedVolume.Name)
+	defer unlock()
 	// idempotent API when called with same key, should return same object
+	s.mapsMu.RLock()
 	volume, ok := s.volumes.encVolumes[in.EncryptedVolume.Name]
+	s.mapsMu.RUnlock()
 	if ok {
 		log.Printf("Already existing EncryptedVolume with id %v", in.EncryptedVolume.Name)
 		return volume, nil
 	}
 
-	// first create a key
-	params1 := s.getAccelCryptoKeyCreateParams(in.EncryptedVolume)
-	var result1 spdk.AccelCryptoKeyCreateResult
-	err1 := s.rpc.Call(ctx, "accel_crypto_key_create", &params1, &result1)
-	if err1 != nil {
-		return nil, err1
-	}
-	log.Printf("Received from SPDK: %v", result1)
-	if !result1 {
-		msg := fmt.Sprintf("Could not create Crypto Key: %s", string(in.EncryptedVolume.Key))
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+	keyName := resourceID
+	if sharedKeyName != "" {
+		// reuse existing key, make sure it is there
+		if err := s.findAccelCryptoKey(ctx, sharedKeyName); err != nil {
+			return nil, err
+		}
+		keyName = sharedKeyName
+	} else {
+		// first create a key
+		params1 := s.getAccelCryptoKeyCreateParams(keyedVolume)
+		// key material is not reported by dry run
+		redacted := params1
+		redacted.Key, redacted.Key2 = utils.RedactedPlaceholder, utils.RedactedPlaceholder
+		if !utils.SkipSpdkCallInDryRun(ctx, "accel_crypto_key_create", &redacted) {
+			var result1 spdk.AccelCryptoKeyCreateResult
+			err1 := s.rpc.Call(ctx, "accel_crypto_key_create", &params1, &result1)
+			if err1 != nil {
+				return nil, err1
+			}
+			log.Printf("Received from SPDK: %v", result1)
+			if !result1 {
+				msg := fmt.Sprintf("Could not create Crypto Key: %s", string(in.EncryptedVolume.Key))
+				return nil, status.Errorf(codes.InvalidArgument, msg)
+			}
+		}
 	}
 	// create bdev now
 	params := spdk.BdevCryptoCreateParams{
 		Name:         resourceID,
 		BaseBdevName: in.EncryptedVolume.VolumeNameRef,
-		KeyName:      resourceID,
+		KeyName:      keyName,
+	}
+	if utils.SkipSpdkCallInDryRun(ctx, "bdev_crypto_create", &params) {
+		return utils.ProtoClone(in.EncryptedVolume), nil
 	}
 	var result spdk.BdevCryptoCreateResult
 	err := s.rpc.Call(ctx, "bdev_crypto_create", &params, &result)
@@ -85,7 +150,12 @@ func (s *Server) CreateEncryptedVolume(ctx context.Context, in *pb.CreateEncrypt
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
 	response := utils.ProtoClone(in.EncryptedVolume)
+	s.mapsMu.Lock()
 	s.volumes.encVolumes[in.EncryptedVolume.Name] = response
+	if sharedKeyName != "" {
+		s.volumes.sharedKeys[in.EncryptedVolume.Name] = sharedKeyName
+	}
+	s.mapsMu.Unlock()
 	return response, nil
 }
 
@@ -95,8 +165,12 @@ func (s *Server) DeleteEncryptedVolume(ctx context.Context, in *pb.DeleteEncrypt
 	if err := s.validateDeleteEncryptedVolumeRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.volumes.encVolumes[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			return &emptypb.Empty{}, nil
@@ -104,10 +178,16 @@ func (s *Server) DeleteEncryptedVolume(ctx context.Context, in *pb.DeleteEncrypt
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	bdevCryptoDeleteParams := spdk.BdevCryptoDeleteParams{
 		Name: resourceID,
 	}
+	if utils.SkipSpdkCallInDryRun(ctx, "bdev_crypto_delete", &bdevCryptoDeleteParams) {
+		if _, shared := s.sharedKeyName(volume.Name); !shared {
+			utils.SkipSpdkCallInDryRun(ctx, "accel_crypto_key_destroy", &spdk.AccelCryptoKeyDestroyParams{KeyName: resourceID})
+		}
+		return &emptypb.Empty{}, nil
+	}
 	var bdevCryptoDeleteResult spdk.BdevCryptoDeleteResult
 	err := s.rpc.Call(ctx, "bdev_crypto_delete", &bdevCryptoDeleteParams, &bdevCryptoDeleteResult)
 	if err != nil {
@@ -119,21 +199,27 @@ func (s *Server) DeleteEncryptedVolume(ctx context.Context, in *pb.DeleteEncrypt
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
 
-	keyDestroyParams := spdk.AccelCryptoKeyDestroyParams{
-		KeyName: resourceID,
-	}
-	var keyDestroyResult spdk.AccelCryptoKeyDestroyResult
-	err = s.rpc.Call(ctx, "accel_crypto_key_destroy", &keyDestroyParams, &keyDestroyResult)
-	if err != nil {
-		return nil, err
-	}
-	log.Printf("Received from SPDK: %v", keyDestroyResult)
-	if !keyDestroyResult {
-		msg := fmt.Sprintf("Could not destroy Crypto Key: %v", keyDestroyParams.KeyName)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+	// shared keys are managed externally and outlive the volume
+	if _, shared := s.sharedKeyName(volume.Name); !shared {
+		keyDestroyParams := spdk.AccelCryptoKeyDestroyParams{
+			KeyName: resourceID,
+		}
+		var keyDestroyResult spdk.AccelCryptoKeyDestroyResult
+		err = s.rpc.Call(ctx, "accel_crypto_key_destroy", &keyDestroyParams, &keyDestroyResult)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Received from SPDK: %v", keyDestroyResult)
+		if !keyDestroyResult {
+			msg := fmt.Sprintf("Could not destroy Crypto Key: %v", keyDestroyParams.KeyName)
+			return nil, status.Errorf(codes.InvalidArgument, msg)
+		}
 	}
 
+	s.mapsMu.Lock()
 	delete(s.volumes.encVolumes, volume.Name)
+	delete(s.volumes.sharedKeys, volume.Name)
+	s.mapsMu.Unlock()
 	return &emptypb.Empty{}, nil
 }
 
@@ -143,11 +229,29 @@ func (s *Server) UpdateEncryptedVolume(ctx context.Context, in *pb.UpdateEncrypt
 	if err := s.validateUpdateEncryptedVolumeRequest(in); err != nil {
 		return nil, err
 	}
+	keyedVolume, err := decodeEncryptedVolumeKey(ctx, in.EncryptedVolume)
+	if err != nil {
+		return nil, err
+	}
+	unlock := s.resourceLocks.Lock(in.EncryptedVolume.Name)
+	defer unlock()
 	// fetch object from the database
-	if err := s.verifyEncryptedVolume(in.EncryptedVolume); err != nil {
+	if err := s.verifyEncryptedVolume(keyedVolume); err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
-	resourceID := path.Base(in.EncryptedVolume.Name)
+	s.mapsMu.RLock()
+	volume, ok := s.volumes.encVolumes[in.EncryptedVolume.Name]
+	s.mapsMu.RUnlock()
+	if ok && proto.Equal(volume, in.EncryptedVolume) {
+		log.Printf("Nothing to update in EncryptedVolume with id %v", in.EncryptedVolume.Name)
+		return volume, nil
+	}
+	// SPDK binds a key to crypto bdev on creation and cannot replace it in
+	// place, so bdev is recreated under the same name, but key is recreated
+	// only when key or cipher changed
+	rotateKey := !ok || volume.Cipher != in.EncryptedVolume.Cipher ||
+		!bytes.Equal(volume.Key, in.EncryptedVolume.Key)
+	resourceID := utils.ResourceNameToID(in.EncryptedVolume.Name)
 	// first delete old bdev
 	params1 := spdk.BdevCryptoDeleteParams{
 		Name: resourceID,
@@ -162,36 +266,42 @@ func (s *Server) UpdateEncryptedVolume(ctx context.Context, in *pb.UpdateEncrypt
 		msg := fmt.Sprintf("Could not delete Crypto: %s", params1.Name)
 		return nil, status.Errorf(codes.InvalidArgument, msg)
 	}
-	// now delete a key
-	params0 := spdk.AccelCryptoKeyDestroyParams{
-		KeyName: resourceID,
-	}
-	var result0 spdk.AccelCryptoKeyDestroyResult
-	err0 := s.rpc.Call(ctx, "accel_crypto_key_destroy", &params0, &result0)
-	if err0 != nil {
-		return nil, err0
-	}
-	log.Printf("Received from SPDK: %v", result0)
-	if !result0 {
-		msg := fmt.Sprintf("Could not destroy Crypto Key: %v", params0.KeyName)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
-	}
-	params2 := s.getAccelCryptoKeyCreateParams(in.EncryptedVolume)
-	var result2 spdk.AccelCryptoKeyCreateResult
-	err2 := s.rpc.Call(ctx, "accel_crypto_key_create", &params2, &result2)
-	if err2 != nil {
-		return nil, err2
-	}
-	log.Printf("Received from SPDK: %v", result2)
-	if !result2 {
-		msg := fmt.Sprintf("Could not create Crypto Key: %s", string(in.EncryptedVolume.Key))
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+	keyName := resourceID
+	if sharedKeyName, shared := s.sharedKeyName(in.EncryptedVolume.Name); shared {
+		// shared keys are managed externally, keep using the same one
+		keyName = sharedKeyName
+	} else if rotateKey {
+		// now delete a key
+		params0 := spdk.AccelCryptoKeyDestroyParams{
+			KeyName: resourceID,
+		}
+		var result0 spdk.AccelCryptoKeyDestroyResult
+		err0 := s.rpc.Call(ctx, "accel_crypto_key_destroy", &params0, &result0)
+		if err0 != nil {
+			return nil, err0
+		}
+		log.Printf("Received from SPDK: %v", result0)
+		if !result0 {
+			msg := fmt.Sprintf("Could not destroy Crypto Key: %v", params0.KeyName)
+			return nil, status.Errorf(codes.InvalidArgument, msg)
+		}
+		params2 := s.getAccelCryptoKeyCreateParams(keyedVolume)
+		var result2 spdk.AccelCryptoKeyCreateResult
+		err2 := s.rpc.Call(ctx, "accel_crypto_key_create", &params2, &result2)
+		if err2 != nil {
+			return nil, err2
+		}
+		log.Printf("Received from SPDK: %v", result2)
+		if !result2 {
+			msg := fmt.Sprintf("Could not create Crypto Key: %s", string(in.EncryptedVolume.Key))
+			return nil, status.Errorf(codes.InvalidArgument, msg)
+		}
 	}
 	// create bdev now
 	params3 := spdk.BdevCryptoCreateParams{
 		Name:         resourceID,
 		BaseBdevName: in.EncryptedVolume.VolumeNameRef,
-		KeyName:      resourceID,
+		KeyName:      keyName,
 	}
 	var result3 spdk.BdevCryptoCreateResult
 	err3 := s.rpc.Call(ctx, "bdev_crypto_create", &params3, &result3)
@@ -205,6 +315,9 @@ func (s *Server) UpdateEncryptedVolume(ctx context.Context, in *pb.UpdateEncrypt
 	}
 	// return result
 	response := utils.ProtoClone(in.EncryptedVolume)
+	s.mapsMu.Lock()
+	s.volumes.encVolumes[in.EncryptedVolume.Name] = response
+	s.mapsMu.Unlock()
 	return response, nil
 }
 
@@ -230,13 +343,14 @@ func (s *Server) ListEncryptedVolumes(ctx context.Context, in *pb.ListEncryptedV
 	result, hasMoreElements := utils.LimitPagination(result, offset, size)
 	if hasMoreElements {
 		token = uuid.New().String()
-		s.Pagination[token] = offset + size
+		s.Pagination.Set(token, offset+size)
 	}
 	Blobarray := make([]*pb.EncryptedVolume, len(result))
 	for i := range result {
 		r := &result[i]
 		Blobarray[i] = &pb.EncryptedVolume{Name: r.Name}
 	}
+	Blobarray, token = utils.LimitPaginationBySize(Blobarray, offset, token, s.Pagination)
 	sortEncryptedVolumes(Blobarray)
 
 	return &pb.ListEncryptedVolumesResponse{EncryptedVolumes: Blobarray, NextPageToken: token}, nil
@@ -249,12 +363,14 @@ func (s *Server) GetEncryptedVolume(ctx context.Context, in *pb.GetEncryptedVolu
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.volumes.encVolumes[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	params := spdk.BdevGetBdevsParams{
 		Name: resourceID,
 	}
@@ -278,12 +394,14 @@ func (s *Server) StatsEncryptedVolume(ctx context.Context, in *pb.StatsEncrypted
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.volumes.encVolumes[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	resourceID := path.Base(volume.Name)
+	resourceID := utils.ResourceNameToID(volume.Name)
 	params := spdk.BdevGetIostatParams{
 		Name: resourceID,
 	}
@@ -311,6 +429,22 @@ func (s *Server) StatsEncryptedVolume(ctx context.Context, in *pb.StatsEncrypted
 	}}, nil
 }
 
+func (s *Server) findAccelCryptoKey(ctx context.Context, keyName string) error {
+	params := spdk.AccelCryptoKeyGetParams{
+		KeyName: keyName,
+	}
+	var result []spdk.AccelCryptoKeyGetResult
+	err := s.rpc.Call(ctx, "accel_crypto_keys_get", &params, &result)
+	if err != nil {
+		return err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	if len(result) != 1 {
+		return status.Errorf(codes.NotFound, "unable to find crypto key %s", keyName)
+	}
+	return nil
+}
+
 func (s *Server) getAccelCryptoKeyCreateParams(volume *pb.EncryptedVolume) spdk.AccelCryptoKeyCreateParams {
 	var params spdk.AccelCryptoKeyCreateParams
 
@@ -318,7 +452,7 @@ func (s *Server) getAccelCryptoKeyCreateParams(volume *pb.EncryptedVolume) spdk.
 	keyHalf := len(volume.Key) / 2
 	params.Key = hex.EncodeToString(volume.Key[:keyHalf])
 	params.Key2 = hex.EncodeToString(volume.Key[keyHalf:])
-	params.Name = path.Base(volume.Name)
+	params.Name = utils.ResourceNameToID(volume.Name)
 	params.TweakMode = s.tweakMode
 
 	return params
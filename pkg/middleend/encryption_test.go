@@ -6,14 +6,27 @@
 package middleend
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"log"
+	"net"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/fieldmaskpb"
@@ -251,8 +264,8 @@ func TestMiddleEnd_CreateEncryptedVolume(t *testing.T) {
 			in:      &pb.EncryptedVolume{},
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
-			errMsg:  "missing required field: encrypted_volume.volume_name_ref",
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: encrypted_volume.volume_name_ref; missing required field: encrypted_volume.key; missing required field: encrypted_volume.cipher",
 			exist:   false,
 		},
 		"malformed volume name": {
@@ -305,6 +318,318 @@ func TestMiddleEnd_CreateEncryptedVolume(t *testing.T) {
 	}
 }
 
+func TestMiddleEnd_CreateEncryptedVolumeKeyValidation(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	t.Cleanup(func() { utils.SetFeatureFlags(nil) })
+	randomKey := make([]byte, 32)
+	for i := range randomKey {
+		randomKey[i] = byte(i*7 + 3)
+	}
+	keyParams := fmt.Sprintf(`{"cipher":"AES_XTS","key":"%v","key2":"%v","tweak_mode":"SIMPLE_LBA","name":"%v"}`,
+		hex.EncodeToString(randomKey[:16]), hex.EncodeToString(randomKey[16:]), encryptedVolumeID)
+	bdevParams := fmt.Sprintf(`{"base_bdev_name":"volume-test","name":"%v","key_name":"%v"}`, encryptedVolumeID, encryptedVolumeID)
+	spdkSuccess := []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`, `{"id":%d,"error":{"code":0,"message":""},"result":"my_crypto_bdev"}`}
+
+	tests := map[string]struct {
+		key          []byte
+		encoding     string
+		entropyCheck bool
+		spdk         []string
+		params       []string
+		errCode      codes.Code
+		errMsg       string
+	}{
+		"valid raw key": {
+			key:          randomKey,
+			encoding:     "",
+			entropyCheck: true,
+			spdk:         spdkSuccess,
+			params:       []string{keyParams, bdevParams},
+			errCode:      codes.OK,
+			errMsg:       "",
+		},
+		"valid hex key": {
+			key:          []byte(hex.EncodeToString(randomKey)),
+			encoding:     "hex",
+			entropyCheck: true,
+			spdk:         spdkSuccess,
+			params:       []string{keyParams, bdevParams},
+			errCode:      codes.OK,
+			errMsg:       "",
+		},
+		"valid base64 key": {
+			key:          []byte(base64.StdEncoding.EncodeToString(randomKey)),
+			encoding:     "base64",
+			entropyCheck: true,
+			spdk:         spdkSuccess,
+			params:       []string{keyParams, bdevParams},
+			errCode:      codes.OK,
+			errMsg:       "",
+		},
+		"wrong encoding key": {
+			key:          []byte(base64.StdEncoding.EncodeToString(randomKey)),
+			encoding:     "hex",
+			entropyCheck: false,
+			spdk:         []string{},
+			params:       nil,
+			errCode:      codes.InvalidArgument,
+			errMsg:       fmt.Sprintf("key is not valid hex: %v", hex.InvalidByteError('w')),
+		},
+		"unsupported encoding": {
+			key:          randomKey,
+			encoding:     "base32",
+			entropyCheck: false,
+			spdk:         []string{},
+			params:       nil,
+			errCode:      codes.InvalidArgument,
+			errMsg:       fmt.Sprintf("unsupported key encoding %q, supported are hex and base64", "base32"),
+		},
+		"all-zero key": {
+			key:          make([]byte, 32),
+			encoding:     "",
+			entropyCheck: true,
+			spdk:         []string{},
+			params:       nil,
+			errCode:      codes.InvalidArgument,
+			errMsg:       "all-zero key is not allowed",
+		},
+		"all-zero key without entropy check": {
+			key:          make([]byte, 32),
+			encoding:     "",
+			entropyCheck: false,
+			spdk:         spdkSuccess,
+			params: []string{
+				fmt.Sprintf(`{"cipher":"AES_XTS","key":"%v","key2":"%v","tweak_mode":"SIMPLE_LBA","name":"%v"}`,
+					hex.EncodeToString(make([]byte, 16)), hex.EncodeToString(make([]byte, 16)), encryptedVolumeID),
+				bdevParams,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"low entropy key": {
+			key:          []byte("abababababababababababababababab"),
+			encoding:     "",
+			entropyCheck: true,
+			spdk:         []string{},
+			params:       nil,
+			errCode:      codes.InvalidArgument,
+			errMsg:       "key has too low entropy, only 2 distinct bytes",
+		},
+		"equal key halves": {
+			key:          encryptedVolume.Key,
+			encoding:     "",
+			entropyCheck: true,
+			spdk:         []string{},
+			params:       nil,
+			errCode:      codes.InvalidArgument,
+			errMsg:       "key halves must differ for AES_XTS",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			recorder := testEnv.recordSpdkParams()
+			utils.SetFeatureFlags(map[string]bool{WeakCryptoKeyCheckFeature: tt.entropyCheck})
+
+			ctx := testEnv.ctx
+			if tt.encoding != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, CryptoKeyEncodingMetadataKey, tt.encoding)
+			}
+			volume := utils.ProtoClone(&encryptedVolume)
+			volume.Key = tt.key
+			request := &pb.CreateEncryptedVolumeRequest{EncryptedVolume: volume, EncryptedVolumeId: encryptedVolumeID}
+			response, err := testEnv.client.CreateEncryptedVolume(ctx, request)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if !reflect.DeepEqual(recorder.params, tt.params) {
+				t.Error("spdk params: expected", tt.params, "received", recorder.params)
+			}
+			if err == nil && !reflect.DeepEqual(response.Key, tt.key) {
+				t.Error("key: expected key as provided", tt.key, "received", response.Key)
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_CreateEncryptedVolumeSpdkCalls(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		record bool
+		spdk   []string
+		calls  []string
+	}{
+		"recording not requested": {
+			record: false,
+			spdk:   []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`, `{"id":%d,"error":{"code":0,"message":""},"result":"my_crypto_bdev"}`},
+			calls:  nil,
+		},
+		"recorded successful create": {
+			record: true,
+			spdk:   []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`, `{"id":%d,"error":{"code":0,"message":""},"result":"my_crypto_bdev"}`},
+			calls:  []string{"accel_crypto_key_create,bdev_crypto_create"},
+		},
+		"recorded create failed on key": {
+			record: true,
+			spdk:   []string{`{"id":%d,"error":{"code":0,"message":""},"result":false}`},
+			calls:  []string{"accel_crypto_key_create"},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			ctx := testEnv.ctx
+			if tt.record {
+				ctx = metadata.AppendToOutgoingContext(ctx, utils.SpdkCallsMetadataKey, "true")
+			}
+			var trailer metadata.MD
+			request := &pb.CreateEncryptedVolumeRequest{EncryptedVolume: &encryptedVolume, EncryptedVolumeId: encryptedVolumeID}
+			_, _ = testEnv.client.CreateEncryptedVolume(ctx, request, grpc.Trailer(&trailer))
+
+			calls := trailer.Get(utils.SpdkCallsTrailerKey)
+			if !reflect.DeepEqual(calls, tt.calls) {
+				t.Error("spdk calls: expected", tt.calls, "received", calls)
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_CreateEncryptedVolumeSharedKey(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	volumeWithoutKey := &pb.EncryptedVolume{
+		VolumeNameRef: encryptedVolume.VolumeNameRef,
+		Cipher:        encryptedVolume.Cipher,
+	}
+	tests := map[string]struct {
+		in      *pb.EncryptedVolume
+		out     *pb.EncryptedVolume
+		spdk    []string
+		calls   []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"shared key exists": {
+			in:      volumeWithoutKey,
+			out:     volumeWithoutKey,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"tenant-kek","cipher":"AES_XTS","key":"00","key2":"01"}]}`, `{"id":%d,"error":{"code":0,"message":""},"result":"my_crypto_bdev"}`},
+			calls:   []string{"accel_crypto_keys_get,bdev_crypto_create"},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"shared key exists and key provided": {
+			in:      &encryptedVolume,
+			out:     &encryptedVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"tenant-kek","cipher":"AES_XTS","key":"00","key2":"01"}]}`, `{"id":%d,"error":{"code":0,"message":""},"result":"my_crypto_bdev"}`},
+			calls:   []string{"accel_crypto_keys_get,bdev_crypto_create"},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"shared key does not exist": {
+			in:      volumeWithoutKey,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[]}`},
+			calls:   []string{"accel_crypto_keys_get"},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find crypto key %v", "tenant-kek"),
+		},
+		"shared key with unsupported cipher": {
+			in: &pb.EncryptedVolume{
+				VolumeNameRef: encryptedVolume.VolumeNameRef,
+				Cipher:        pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_192,
+			},
+			out:     nil,
+			spdk:    []string{},
+			calls:   []string{""},
+			errCode: codes.InvalidArgument,
+			errMsg:  "only AES_XTS_256 and AES_XTS_128 are supported",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.out != nil {
+				tt.out = utils.ProtoClone(tt.out)
+				tt.out.Name = encryptedVolumeName
+			}
+
+			ctx := metadata.AppendToOutgoingContext(testEnv.ctx,
+				SharedCryptoKeyMetadataKey, "tenant-kek",
+				utils.SpdkCallsMetadataKey, "true")
+			var trailer metadata.MD
+			request := &pb.CreateEncryptedVolumeRequest{EncryptedVolume: tt.in, EncryptedVolumeId: encryptedVolumeID}
+			response, err := testEnv.client.CreateEncryptedVolume(ctx, request, grpc.Trailer(&trailer))
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			if calls := trailer.Get(utils.SpdkCallsTrailerKey); !reflect.DeepEqual(calls, tt.calls) {
+				t.Error("spdk calls: expected", tt.calls, "received", calls)
+			}
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			_, shared := testEnv.opiSpdkServer.volumes.sharedKeys[encryptedVolumeName]
+			if shared != (tt.errCode == codes.OK) {
+				t.Error("shared key: expected", tt.errCode == codes.OK, "received", shared)
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_DeleteEncryptedVolumeSharedKey(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`})
+	defer testEnv.Close()
+
+	testEnv.opiSpdkServer.volumes.encVolumes[encryptedVolumeName] = utils.ProtoClone(&encryptedVolumeWithName)
+	testEnv.opiSpdkServer.volumes.sharedKeys[encryptedVolumeName] = "tenant-kek"
+
+	ctx := metadata.AppendToOutgoingContext(testEnv.ctx, utils.SpdkCallsMetadataKey, "true")
+	var trailer metadata.MD
+	request := &pb.DeleteEncryptedVolumeRequest{Name: encryptedVolumeName}
+	_, err := testEnv.client.DeleteEncryptedVolume(ctx, request, grpc.Trailer(&trailer))
+	if err != nil {
+		t.Error("expected no error, received", err)
+	}
+
+	calls := []string{"bdev_crypto_delete"}
+	if received := trailer.Get(utils.SpdkCallsTrailerKey); !reflect.DeepEqual(received, calls) {
+		t.Error("spdk calls: expected", calls, "received", received)
+	}
+	if _, shared := testEnv.opiSpdkServer.volumes.sharedKeys[encryptedVolumeName]; shared {
+		t.Error("expected shared key reference to be removed")
+	}
+}
+
 func TestMiddleEnd_UpdateEncryptedVolume(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
 
@@ -653,6 +978,79 @@ func TestMiddleEnd_UpdateEncryptedVolume(t *testing.T) {
 	}
 }
 
+func TestMiddleEnd_UpdateEncryptedVolumeKeyRotation(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	ok := `{"id":%d,"error":{"code":0,"message":""},"result":true}`
+	created := `{"id":%d,"error":{"code":0,"message":""},"result":"mytest"}`
+	tests := map[string]struct {
+		in    *pb.EncryptedVolume
+		spdk  []string
+		calls string
+	}{
+		"nothing changed": {
+			in:    &encryptedVolumeWithName,
+			spdk:  []string{},
+			calls: "",
+		},
+		"base volume changed": {
+			in: &pb.EncryptedVolume{
+				Name:          encryptedVolumeName,
+				VolumeNameRef: "volume-test-2",
+				Key:           encryptedVolumeWithName.Key,
+				Cipher:        encryptedVolumeWithName.Cipher,
+			},
+			spdk:  []string{ok, created},
+			calls: "bdev_crypto_delete,bdev_crypto_create",
+		},
+		"key changed": {
+			in: &pb.EncryptedVolume{
+				Name:          encryptedVolumeName,
+				VolumeNameRef: encryptedVolumeWithName.VolumeNameRef,
+				Key:           []byte("fedcba9876543210fedcba9876543210"),
+				Cipher:        encryptedVolumeWithName.Cipher,
+			},
+			spdk:  []string{ok, ok, ok, created},
+			calls: "bdev_crypto_delete,accel_crypto_key_destroy,accel_crypto_key_create,bdev_crypto_create",
+		},
+		"cipher changed": {
+			in: &pb.EncryptedVolume{
+				Name:          encryptedVolumeName,
+				VolumeNameRef: encryptedVolumeWithName.VolumeNameRef,
+				Key:           []byte("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"),
+				Cipher:        pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_256,
+			},
+			spdk:  []string{ok, ok, ok, created},
+			calls: "bdev_crypto_delete,accel_crypto_key_destroy,accel_crypto_key_create,bdev_crypto_create",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			testEnv.opiSpdkServer.volumes.encVolumes[encryptedVolumeName] = utils.ProtoClone(&encryptedVolumeWithName)
+
+			ctx := metadata.AppendToOutgoingContext(testEnv.ctx, utils.SpdkCallsMetadataKey, "true")
+			var trailer metadata.MD
+			request := &pb.UpdateEncryptedVolumeRequest{EncryptedVolume: tt.in}
+			response, err := testEnv.client.UpdateEncryptedVolume(ctx, request, grpc.Trailer(&trailer))
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+
+			if !proto.Equal(response, tt.in) {
+				t.Error("response: expected", tt.in, "received", response)
+			}
+			if received := trailer.Get(utils.SpdkCallsTrailerKey); !reflect.DeepEqual(received, []string{tt.calls}) {
+				t.Error("spdk calls: expected", tt.calls, "received", received)
+			}
+			if volume := testEnv.opiSpdkServer.volumes.encVolumes[encryptedVolumeName]; !proto.Equal(volume, tt.in) {
+				t.Error("stored volume: expected", tt.in, "received", volume)
+			}
+		})
+	}
+}
+
 func TestMiddleEnd_ListEncryptedVolumes(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
 	tests := map[string]struct {
@@ -804,7 +1202,7 @@ func TestMiddleEnd_ListEncryptedVolumes(t *testing.T) {
 			testEnv := createTestEnvironment(tt.spdk)
 			defer testEnv.Close()
 
-			testEnv.opiSpdkServer.Pagination["existing-pagination-token"] = 1
+			testEnv.opiSpdkServer.Pagination.Set("existing-pagination-token", 1)
 
 			request := &pb.ListEncryptedVolumesRequest{Parent: tt.in, PageSize: tt.size, PageToken: tt.token}
 			response, err := testEnv.client.ListEncryptedVolumes(testEnv.ctx, request)
@@ -1177,3 +1575,134 @@ func TestMiddleEnd_DeleteEncryptedVolume(t *testing.T) {
 		})
 	}
 }
+
+func TestMiddleEnd_CreateEncryptedVolumePayloadLogRedacted(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	t.Cleanup(func() {
+		_ = utils.SetLogLevel("debug")
+		_ = utils.SetLogFormat(utils.LogFormatText)
+	})
+	_ = utils.SetLogLevel("debug")
+
+	for _, format := range []string{utils.LogFormatText, utils.LogFormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			if err := utils.SetLogFormat(format); err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+			testEnv := createTestEnvironment([]string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":"my_crypto_bdev"}`,
+			})
+			defer testEnv.Close()
+
+			var buf bytes.Buffer
+			listener := bufconn.Listen(1024 * 1024)
+			server := grpc.NewServer(grpc.UnaryInterceptor(
+				logging.UnaryServerInterceptor(utils.InterceptorLogger(log.New(&buf, "", 0)),
+					logging.WithLogOnEvents(logging.PayloadReceived, logging.PayloadSent),
+				)))
+			pb.RegisterMiddleendEncryptionServiceServer(server, testEnv.opiSpdkServer)
+			go func() { _ = server.Serve(listener) }()
+			defer server.Stop()
+			conn, err := grpc.DialContext(testEnv.ctx, "",
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }))
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+			defer conn.Close()
+
+			request := &pb.CreateEncryptedVolumeRequest{EncryptedVolume: &encryptedVolume, EncryptedVolumeId: encryptedVolumeID}
+			response, err := pb.NewMiddleendEncryptionServiceClient(conn).CreateEncryptedVolume(testEnv.ctx, request)
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+			if !bytes.Equal(response.Key, encryptedVolume.Key) {
+				t.Error("expected response key not to be redacted, received", response.Key)
+			}
+
+			output := buf.String()
+			if strings.Count(output, "grpc.request.content") != 1 || strings.Count(output, "grpc.response.content") != 1 {
+				t.Fatal("expected request and response payloads to be logged, received", output)
+			}
+			for _, key := range []string{
+				string(encryptedVolume.Key),
+				base64.StdEncoding.EncodeToString(encryptedVolume.Key),
+				hex.EncodeToString(encryptedVolume.Key),
+			} {
+				if strings.Contains(output, key) {
+					t.Error("expected key", key, "not to be logged, received", output)
+				}
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_EncryptedVolumeDryRun(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		call        func(context.Context, *testEnv, grpc.CallOption) error
+		existBefore bool
+		methods     []string
+	}{
+		"create": {
+			call: func(ctx context.Context, env *testEnv, opt grpc.CallOption) error {
+				request := &pb.CreateEncryptedVolumeRequest{EncryptedVolume: &encryptedVolume, EncryptedVolumeId: encryptedVolumeID}
+				response, err := env.client.CreateEncryptedVolume(ctx, request, opt)
+				if !proto.Equal(response, &encryptedVolumeWithName) {
+					t.Error("response: expected", &encryptedVolumeWithName, "received", response)
+				}
+				return err
+			},
+			existBefore: false,
+			methods:     []string{"accel_crypto_key_create", "bdev_crypto_create"},
+		},
+		"delete": {
+			call: func(ctx context.Context, env *testEnv, opt grpc.CallOption) error {
+				_, err := env.client.DeleteEncryptedVolume(ctx, &pb.DeleteEncryptedVolumeRequest{Name: encryptedVolumeName}, opt)
+				return err
+			},
+			existBefore: true,
+			methods:     []string{"bdev_crypto_delete", "accel_crypto_key_destroy"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+			stub := &stubJSONRRPC{}
+			testEnv.opiSpdkServer.rpc = stub
+			if tt.existBefore {
+				testEnv.opiSpdkServer.volumes.encVolumes[encryptedVolumeName] = utils.ProtoClone(&encryptedVolumeWithName)
+			}
+
+			var header metadata.MD
+			ctx := metadata.AppendToOutgoingContext(testEnv.ctx, utils.DryRunMetadataKey, "true")
+			if err := tt.call(ctx, testEnv, grpc.Header(&header)); err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+
+			if len(stub.params) != 0 {
+				t.Error("expected no SPDK calls, received", stub.params)
+			}
+			if _, ok := testEnv.opiSpdkServer.volumes.encVolumes[encryptedVolumeName]; ok != tt.existBefore {
+				t.Error("expect volume exist", tt.existBefore, "received", ok)
+			}
+			methods := []string{}
+			for _, value := range header.Get(utils.DryRunHeaderKey) {
+				var call utils.DryRunSpdkCall
+				if err := json.Unmarshal([]byte(value), &call); err != nil {
+					t.Fatal("expected no error, received", err)
+				}
+				methods = append(methods, call.Method)
+				if strings.Contains(value, hex.EncodeToString(encryptedVolume.Key[:16])) {
+					t.Error("expected key not to be reported, received", value)
+				}
+			}
+			if !reflect.DeepEqual(methods, tt.methods) {
+				t.Error("dry run calls: expected", tt.methods, "received", methods)
+			}
+		})
+	}
+}
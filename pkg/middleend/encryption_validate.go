@@ -5,32 +5,43 @@
 package middleend
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"strings"
 
 	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/resourceid"
 	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
-func (s *Server) validateCreateEncryptedVolumeRequest(in *pb.CreateEncryptedVolumeRequest) error {
-	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
+func (s *Server) validateCreateEncryptedVolumeRequest(in *pb.CreateEncryptedVolumeRequest, sharedKeyName string) error {
+	v := &utils.Validator{}
+	// check required fields, key is not required when shared one is referenced
+	requiredFields := &fieldmaskpb.FieldMask{Paths: []string{"*"}}
+	if sharedKeyName != "" {
+		requiredFields.Paths = []string{"encrypted_volume", "encrypted_volume.volume_name_ref", "encrypted_volume.cipher"}
 	}
+	v.CheckRequiredFieldsWithMask(in, requiredFields)
 	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	if err := resourcename.Validate(in.EncryptedVolume.VolumeNameRef); err != nil {
-		return err
+	if in.GetEncryptedVolume().GetVolumeNameRef() != "" {
+		v.Check("encrypted_volume.volume_name_ref", resourcename.Validate(in.EncryptedVolume.VolumeNameRef))
 	}
 	// see https://google.aip.dev/133#user-specified-ids
 	if in.EncryptedVolumeId != "" {
-		if err := resourceid.ValidateUserSettable(in.EncryptedVolumeId); err != nil {
-			return err
-		}
+		v.Check("encrypted_volume_id", resourceid.ValidateUserSettable(in.EncryptedVolumeId))
 	}
 	// TODO: validate also: block_size, blocks_count, uuid, filename
-	return nil
+	return v.Err()
 }
 
 func (s *Server) validateDeleteEncryptedVolumeRequest(in *pb.DeleteEncryptedVolumeRequest) error {
@@ -74,21 +85,79 @@ func (s *Server) validateStatsEncryptedVolumeRequest(in *pb.StatsEncryptedVolume
 }
 
 func (s *Server) verifyEncryptedVolume(volume *pb.EncryptedVolume) error {
-	keyLengthInBits := len(volume.Key) * 8
-	expectedKeyLengthInBits := 0
-	switch {
-	case volume.Cipher == pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_256:
-		expectedKeyLengthInBits = 512
-	case volume.Cipher == pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_128:
-		expectedKeyLengthInBits = 256
-	default:
-		return fmt.Errorf("only AES_XTS_256 and AES_XTS_128 are supported")
+	expectedKeyLengthInBits, err := s.expectedKeyLengthInBits(volume.Cipher)
+	if err != nil {
+		return err
 	}
 
+	keyLengthInBits := len(volume.Key) * 8
 	if keyLengthInBits != expectedKeyLengthInBits {
 		return fmt.Errorf("expected key size %vb, provided size %vb",
 			expectedKeyLengthInBits, keyLengthInBits)
 	}
 
+	if utils.FeatureEnabled(WeakCryptoKeyCheckFeature) {
+		return checkKeyEntropy(volume.Key)
+	}
+	return nil
+}
+
+// checkKeyEntropy rejects keys which are obviously not random. AES-XTS key
+// consists of two halves, which must differ as well
+func checkKeyEntropy(key []byte) error {
+	if len(bytes.Trim(key, "\x00")) == 0 {
+		return fmt.Errorf("all-zero key is not allowed")
+	}
+	distinct := make(map[byte]struct{}, len(key))
+	for _, b := range key {
+		distinct[b] = struct{}{}
+	}
+	if len(distinct) < len(key)/4 {
+		return fmt.Errorf("key has too low entropy, only %v distinct bytes", len(distinct))
+	}
+	keyHalf := len(key) / 2
+	if bytes.Equal(key[:keyHalf], key[keyHalf:]) {
+		return fmt.Errorf("key halves must differ for AES_XTS")
+	}
 	return nil
 }
+
+// decodeEncryptedVolumeKey returns volume with key decoded according to
+// encoding requested in metadata. Volume is returned as is without encoding
+func decodeEncryptedVolumeKey(ctx context.Context, volume *pb.EncryptedVolume) (*pb.EncryptedVolume, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(CryptoKeyEncodingMetadataKey)
+	if len(values) == 0 {
+		return volume, nil
+	}
+	encoding := strings.ToLower(values[0])
+	var key []byte
+	var err error
+	switch encoding {
+	case "hex":
+		key, err = hex.DecodeString(string(volume.Key))
+	case "base64":
+		key, err = base64.StdEncoding.DecodeString(string(volume.Key))
+	default:
+		msg := fmt.Sprintf("unsupported key encoding %q, supported are hex and base64", values[0])
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("key is not valid %v: %v", encoding, err)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	decoded := utils.ProtoClone(volume)
+	decoded.Key = key
+	return decoded, nil
+}
+
+func (s *Server) expectedKeyLengthInBits(cipher pb.EncryptionType) (int, error) {
+	switch {
+	case cipher == pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_256:
+		return 512, nil
+	case cipher == pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_128:
+		return 256, nil
+	default:
+		return 0, fmt.Errorf("only AES_XTS_256 and AES_XTS_128 are supported")
+	}
+}
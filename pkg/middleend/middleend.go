@@ -7,17 +7,23 @@ package middleend
 
 import (
 	"log"
+	"sync"
 
 	"github.com/philippgille/gokv"
 
 	"github.com/opiproject/gospdk/spdk"
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
 
 // VolumeParameters contains MiddleEnd volume related structures
 type VolumeParameters struct {
 	qosVolumes map[string]*pb.QosVolume
 	encVolumes map[string]*pb.EncryptedVolume
+	// sharedKeys maps encrypted volume names to externally managed crypto keys
+	sharedKeys map[string]string
+	// compositeVolumes are encrypted volumes with QoS created as a whole
+	compositeVolumes map[string]*CompositeVolume
 }
 
 // Server contains middleend related OPI services
@@ -29,7 +35,12 @@ type Server struct {
 	store      gokv.Store
 	volumes    VolumeParameters
 	tweakMode  string
-	Pagination map[string]int
+	Pagination *utils.Pagination
+	// resourceLocks serializes mutating operations on the same resource
+	resourceLocks *utils.KeyedMutex
+	// mapsMu guards volumes, since resourceLocks serialize operations on
+	// one resource only
+	mapsMu sync.RWMutex
 }
 
 // NewServer creates initialized instance of MiddleEnd server communicating
@@ -51,10 +62,35 @@ func NewCustomizedServer(jsonRPC spdk.JSONRPC, store gokv.Store, tweakMode strin
 		rpc:   jsonRPC,
 		store: store,
 		volumes: VolumeParameters{
-			qosVolumes: make(map[string]*pb.QosVolume),
-			encVolumes: make(map[string]*pb.EncryptedVolume),
+			qosVolumes:       make(map[string]*pb.QosVolume),
+			encVolumes:       make(map[string]*pb.EncryptedVolume),
+			sharedKeys:       make(map[string]string),
+			compositeVolumes: make(map[string]*CompositeVolume),
 		},
-		tweakMode:  tweakMode,
-		Pagination: make(map[string]int),
+		tweakMode:     tweakMode,
+		Pagination:    utils.NewPagination(),
+		resourceLocks: utils.NewKeyedMutex(),
+	}
+}
+
+// ResourceCounts returns number of MiddleEnd resources per resource type
+func (s *Server) ResourceCounts() map[string]int {
+	s.mapsMu.RLock()
+	defer s.mapsMu.RUnlock()
+	return map[string]int{
+		"composite_volumes": len(s.volumes.compositeVolumes),
+		"encrypted_volumes": len(s.volumes.encVolumes),
+		"qos_volumes":       len(s.volumes.qosVolumes),
+	}
+}
+
+// StateResources returns MiddleEnd resources per resource type for export
+// and import of bridge state. Encrypted volumes are exported with their keys,
+// since deleting them requires the keys
+func (s *Server) StateResources() map[string]utils.StateResource {
+	return map[string]utils.StateResource{
+		"composite_volumes": utils.JSONStateResource(&s.mapsMu, s.volumes.compositeVolumes),
+		"encrypted_volumes": utils.ProtoStateResource(&s.mapsMu, s.volumes.encVolumes),
+		"qos_volumes":       utils.ProtoStateResource(&s.mapsMu, s.volumes.qosVolumes),
 	}
 }
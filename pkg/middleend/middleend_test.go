@@ -7,9 +7,12 @@ package middleend
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"net"
 	"os"
+	"reflect"
+	"testing"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -53,6 +56,30 @@ func (e *testEnv) Close() {
 	utils.CloseGrpcConnection(e.conn)
 }
 
+// spdkParamsRecorder keeps called methods and JSON of params sent to SPDK to
+// verify them in tests
+type spdkParamsRecorder struct {
+	spdk.JSONRPC
+	methods []string
+	params  []string
+}
+
+func (r *spdkParamsRecorder) Call(ctx context.Context, method string, args, result interface{}) error {
+	data, err := json.Marshal(args)
+	if err != nil {
+		log.Panic(err)
+	}
+	r.methods = append(r.methods, method)
+	r.params = append(r.params, string(data))
+	return r.JSONRPC.Call(ctx, method, args, result)
+}
+
+func (e *testEnv) recordSpdkParams() *spdkParamsRecorder {
+	recorder := &spdkParamsRecorder{JSONRPC: e.opiSpdkServer.rpc}
+	e.opiSpdkServer.rpc = recorder
+	return recorder
+}
+
 func createTestEnvironment(spdkResponses []string) *testEnv {
 	env := &testEnv{}
 	env.testSocket = utils.GenerateSocketName("middleend")
@@ -60,7 +87,7 @@ func createTestEnvironment(spdkResponses []string) *testEnv {
 	options := gomap.DefaultOptions
 	options.Codec = utils.ProtoCodec{}
 	store := gomap.NewStore(options)
-	env.opiSpdkServer = NewServer(env.jsonRPC, store)
+	env.opiSpdkServer = NewServer(utils.NewSpdkCallRecordingJSONRPC(env.jsonRPC), store)
 
 	ctx := context.Background()
 	conn, err := grpc.DialContext(ctx,
@@ -83,7 +110,7 @@ func createTestEnvironment(spdkResponses []string) *testEnv {
 
 func dialer(opiSpdkServer *Server) func(context.Context, string) (net.Conn, error) {
 	listener := bufconn.Listen(1024 * 1024)
-	server := grpc.NewServer()
+	server := grpc.NewServer(grpc.UnaryInterceptor(utils.SpdkCallsUnaryServerInterceptor))
 	pb.RegisterMiddleendEncryptionServiceServer(server, opiSpdkServer)
 	pb.RegisterMiddleendQosVolumeServiceServer(server, opiSpdkServer)
 
@@ -113,3 +140,20 @@ var (
 		Cipher:        encryptedVolume.Cipher,
 	}
 )
+
+func TestMiddleEnd_ResourceCounts(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+	testEnv.opiSpdkServer.volumes.encVolumes[encryptedVolumeName] = utils.ProtoClone(&encryptedVolume)
+	testEnv.opiSpdkServer.volumes.qosVolumes[testQosVolumeName] = utils.ProtoClone(testQosVolume)
+	testEnv.opiSpdkServer.volumes.qosVolumes[testQosVolumeName+"1"] = utils.ProtoClone(testQosVolume)
+
+	expected := map[string]int{
+		"composite_volumes": 0,
+		"encrypted_volumes": 1,
+		"qos_volumes":       2,
+	}
+	if counts := testEnv.opiSpdkServer.ResourceCounts(); !reflect.DeepEqual(counts, expected) {
+		t.Error("counts: expected", expected, "received", counts)
+	}
+}
@@ -19,9 +19,25 @@ import (
 	"go.einride.tech/aip/resourceid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
+// bdevAssignedRateLimits are QoS limits SPDK applies to a bdev
+type bdevAssignedRateLimits struct {
+	RwIosPerSec    int64 `json:"rw_ios_per_sec"`
+	RwMbytesPerSec int64 `json:"rw_mbytes_per_sec"`
+	RMbytesPerSec  int64 `json:"r_mbytes_per_sec"`
+	WMbytesPerSec  int64 `json:"w_mbytes_per_sec"`
+}
+
+// bdevGetBdevsResult extends spdk.BdevGetBdevsResult with assigned rate limits
+// TODO: remove once gospdk supports assigned_rate_limits
+type bdevGetBdevsResult struct {
+	spdk.BdevGetBdevsResult
+	AssignedRateLimits bdevAssignedRateLimits `json:"assigned_rate_limits"`
+}
+
 func sortQosVolumes(volumes []*pb.QosVolume) {
 	sort.Slice(volumes, func(i int, j int) bool {
 		return volumes[i].Name < volumes[j].Name
@@ -42,20 +58,31 @@ func (s *Server) CreateQosVolume(ctx context.Context, in *pb.CreateQosVolumeRequ
 	}
 	in.QosVolume.Name = utils.ResourceIDToVolumeName(resourceID)
 
+	unlock := s.resourceLocks.Lock(in.QosVolume.Name)
+	defer unlock()
 	if err := s.verifyQosVolume(in.QosVolume); err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
-	if volume, ok := s.volumes.qosVolumes[in.QosVolume.Name]; ok {
+	s.mapsMu.RLock()
+	volume, ok := s.volumes.qosVolumes[in.QosVolume.Name]
+	s.mapsMu.RUnlock()
+	if ok {
 		log.Printf("Already existing QosVolume with name %v", in.QosVolume.Name)
 		return volume, nil
 	}
 
+	params := qosLimitParams(in.QosVolume.VolumeNameRef, in.QosVolume.Limits.Max)
+	if utils.SkipSpdkCallInDryRun(ctx, "bdev_set_qos_limit", &params) {
+		return utils.ProtoClone(in.QosVolume), nil
+	}
 	if err := s.setMaxLimit(ctx, in.QosVolume.VolumeNameRef, in.QosVolume.Limits.Max); err != nil {
 		return nil, err
 	}
 
 	response := utils.ProtoClone(in.QosVolume)
+	s.mapsMu.Lock()
 	s.volumes.qosVolumes[in.QosVolume.Name] = response
+	s.mapsMu.Unlock()
 	log.Printf("CreateQosVolume: Sending to client: %v", response)
 	return response, nil
 }
@@ -66,8 +93,12 @@ func (s *Server) DeleteQosVolume(ctx context.Context, in *pb.DeleteQosVolumeRequ
 	if err := s.validateDeleteQosVolumeRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.Name)
+	defer unlock()
 	// fetch object from the database
+	s.mapsMu.RLock()
 	qosVolume, ok := s.volumes.qosVolumes[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		if in.AllowMissing {
 			return &emptypb.Empty{}, nil
@@ -76,11 +107,17 @@ func (s *Server) DeleteQosVolume(ctx context.Context, in *pb.DeleteQosVolumeRequ
 		return nil, err
 	}
 
+	params := qosLimitParams(qosVolume.VolumeNameRef, &pb.QosLimit{})
+	if utils.SkipSpdkCallInDryRun(ctx, "bdev_set_qos_limit", &params) {
+		return &emptypb.Empty{}, nil
+	}
 	if err := s.cleanMaxLimit(ctx, qosVolume.VolumeNameRef); err != nil {
 		return nil, err
 	}
 
+	s.mapsMu.Lock()
 	delete(s.volumes.qosVolumes, in.Name)
+	s.mapsMu.Unlock()
 	return &emptypb.Empty{}, nil
 }
 
@@ -90,12 +127,16 @@ func (s *Server) UpdateQosVolume(ctx context.Context, in *pb.UpdateQosVolumeRequ
 	if err := s.validateUpdateQosVolumeRequest(in); err != nil {
 		return nil, err
 	}
+	unlock := s.resourceLocks.Lock(in.QosVolume.Name)
+	defer unlock()
 	// fetch object from the database
 	if err := s.verifyQosVolume(in.QosVolume); err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 	name := in.QosVolume.Name
+	s.mapsMu.RLock()
 	volume, ok := s.volumes.qosVolumes[name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		log.Printf("Non-existing QoS volume with name %v", name)
 		return nil, status.Errorf(codes.NotFound, "unable to find key %s", name)
@@ -111,7 +152,9 @@ func (s *Server) UpdateQosVolume(ctx context.Context, in *pb.UpdateQosVolumeRequ
 		return nil, err
 	}
 
+	s.mapsMu.Lock()
 	s.volumes.qosVolumes[name] = in.QosVolume
+	s.mapsMu.Unlock()
 	return in.QosVolume, nil
 }
 
@@ -128,9 +171,11 @@ func (s *Server) ListQosVolumes(_ context.Context, in *pb.ListQosVolumesRequest)
 	}
 
 	volumes := []*pb.QosVolume{}
+	s.mapsMu.RLock()
 	for _, qosVolume := range s.volumes.qosVolumes {
 		volumes = append(volumes, utils.ProtoClone(qosVolume))
 	}
+	s.mapsMu.RUnlock()
 	sortQosVolumes(volumes)
 
 	token := ""
@@ -138,25 +183,55 @@ func (s *Server) ListQosVolumes(_ context.Context, in *pb.ListQosVolumesRequest)
 	volumes, hasMoreElements := utils.LimitPagination(volumes, offset, size)
 	if hasMoreElements {
 		token = uuid.New().String()
-		s.Pagination[token] = offset + size
+		s.Pagination.Set(token, offset+size)
 	}
 
+	volumes, token = utils.LimitPaginationBySize(volumes, offset, token, s.Pagination)
 	return &pb.ListQosVolumesResponse{QosVolumes: volumes, NextPageToken: token}, nil
 }
 
-// GetQosVolume gets a QoS volume
-func (s *Server) GetQosVolume(_ context.Context, in *pb.GetQosVolumeRequest) (*pb.QosVolume, error) {
+// GetQosVolume gets a QoS volume with limits currently applied by SPDK
+func (s *Server) GetQosVolume(ctx context.Context, in *pb.GetQosVolumeRequest) (*pb.QosVolume, error) {
 	// check input correctness
 	if err := s.validateGetQosVolumeRequest(in); err != nil {
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.volumes.qosVolumes[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	return volume, nil
+	params := spdk.BdevGetBdevsParams{
+		Name: volume.VolumeNameRef,
+	}
+	var result []bdevGetBdevsResult
+	err := s.rpc.Call(ctx, "bdev_get_bdevs", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	if len(result) != 1 {
+		msg := fmt.Sprintf("expecting exactly 1 result, got %d", len(result))
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	limits := result[0].AssignedRateLimits
+	response := utils.ProtoClone(volume)
+	response.Limits = &pb.Limits{
+		Max: &pb.QosLimit{
+			RwIopsKiops:    limits.RwIosPerSec / 1000,
+			RwBandwidthMbs: limits.RwMbytesPerSec,
+			RdBandwidthMbs: limits.RMbytesPerSec,
+			WrBandwidthMbs: limits.WMbytesPerSec,
+		},
+	}
+	if !proto.Equal(response.Limits.Max, volume.Limits.GetMax()) {
+		log.Printf("QoS volume %v limits %v applied by SPDK differ from requested %v",
+			volume.Name, response.Limits.Max, volume.Limits.GetMax())
+	}
+	return response, nil
 }
 
 // StatsQosVolume gets a QoS volume stats
@@ -166,7 +241,9 @@ func (s *Server) StatsQosVolume(ctx context.Context, in *pb.StatsQosVolumeReques
 		return nil, err
 	}
 	// fetch object from the database
+	s.mapsMu.RLock()
 	volume, ok := s.volumes.qosVolumes[in.Name]
+	s.mapsMu.RUnlock()
 	if !ok {
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
@@ -199,14 +276,18 @@ func (s *Server) StatsQosVolume(ctx context.Context, in *pb.StatsQosVolumeReques
 		}}, nil
 }
 
-func (s *Server) setMaxLimit(ctx context.Context, underlyingVolume string, limit *pb.QosLimit) error {
-	params := spdk.BdevQoSParams{
+func qosLimitParams(underlyingVolume string, limit *pb.QosLimit) spdk.BdevQoSParams {
+	return spdk.BdevQoSParams{
 		Name:           underlyingVolume,
 		RwIosPerSec:    int(limit.RwIopsKiops * 1000),
 		RwMbytesPerSec: int(limit.RwBandwidthMbs),
 		RMbytesPerSec:  int(limit.RdBandwidthMbs),
 		WMbytesPerSec:  int(limit.WrBandwidthMbs),
 	}
+}
+
+func (s *Server) setMaxLimit(ctx context.Context, underlyingVolume string, limit *pb.QosLimit) error {
+	params := qosLimitParams(underlyingVolume, limit)
 	var result spdk.BdevQoSResult
 	err := s.rpc.Call(ctx, "bdev_set_qos_limit", &params, &result)
 	if err != nil {
@@ -185,6 +185,76 @@ func TestMiddleEnd_CreateQosVolume(t *testing.T) {
 			existBefore: false,
 			existAfter:  false,
 		},
+		"max_limit rd_bandwidth_mbs exceeds rw_bandwidth_mbs": {
+			id: testQosVolumeID,
+			in: &pb.QosVolume{
+				VolumeNameRef: "volume-42",
+				Limits: &pb.Limits{
+					Max: &pb.QosLimit{RwBandwidthMbs: 1, RdBandwidthMbs: 2},
+				},
+			},
+			out:         nil,
+			spdk:        []string{},
+			errCode:     codes.InvalidArgument,
+			errMsg:      "QoS volume max_limit rd_bandwidth_mbs cannot exceed rw_bandwidth_mbs",
+			existBefore: false,
+			existAfter:  false,
+		},
+		"max_limit wr_bandwidth_mbs exceeds rw_bandwidth_mbs": {
+			id: testQosVolumeID,
+			in: &pb.QosVolume{
+				VolumeNameRef: "volume-42",
+				Limits: &pb.Limits{
+					Max: &pb.QosLimit{RwBandwidthMbs: 1, WrBandwidthMbs: 2},
+				},
+			},
+			out:         nil,
+			spdk:        []string{},
+			errCode:     codes.InvalidArgument,
+			errMsg:      "QoS volume max_limit wr_bandwidth_mbs cannot exceed rw_bandwidth_mbs",
+			existBefore: false,
+			existAfter:  false,
+		},
+		"max_limit rd and wr bandwidth within rw_bandwidth_mbs": {
+			id: testQosVolumeID,
+			in: &pb.QosVolume{
+				VolumeNameRef: "volume-42",
+				Limits: &pb.Limits{
+					Max: &pb.QosLimit{RwIopsKiops: 1, RwBandwidthMbs: 2, RdBandwidthMbs: 2, WrBandwidthMbs: 1},
+				},
+			},
+			out: &pb.QosVolume{
+				VolumeNameRef: "volume-42",
+				Limits: &pb.Limits{
+					Max: &pb.QosLimit{RwIopsKiops: 1, RwBandwidthMbs: 2, RdBandwidthMbs: 2, WrBandwidthMbs: 1},
+				},
+			},
+			spdk:        []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			errCode:     codes.OK,
+			errMsg:      "",
+			existBefore: false,
+			existAfter:  true,
+		},
+		"max_limit rd and wr bandwidth without rw_bandwidth_mbs": {
+			id: testQosVolumeID,
+			in: &pb.QosVolume{
+				VolumeNameRef: "volume-42",
+				Limits: &pb.Limits{
+					Max: &pb.QosLimit{RdBandwidthMbs: 3, WrBandwidthMbs: 2},
+				},
+			},
+			out: &pb.QosVolume{
+				VolumeNameRef: "volume-42",
+				Limits: &pb.Limits{
+					Max: &pb.QosLimit{RdBandwidthMbs: 3, WrBandwidthMbs: 2},
+				},
+			},
+			spdk:        []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			errCode:     codes.OK,
+			errMsg:      "",
+			existBefore: false,
+			existAfter:  true,
+		},
 		"max_limit with all zero limits": {
 			id: testQosVolumeID,
 			in: &pb.QosVolume{
@@ -939,7 +1009,7 @@ func TestMiddleEnd_ListQosVolume(t *testing.T) {
 			request.Parent = tt.in
 			request.PageSize = tt.size
 			request.PageToken = tt.token
-			testEnv.opiSpdkServer.Pagination[existingToken] = 1
+			testEnv.opiSpdkServer.Pagination.Set(existingToken, 1)
 
 			response, err := testEnv.client.ListQosVolumes(testEnv.ctx, request)
 
@@ -971,31 +1041,73 @@ func TestMiddleEnd_GetQosVolume(t *testing.T) {
 	tests := map[string]struct {
 		in      string
 		out     *pb.QosVolume
+		spdk    []string
 		errCode codes.Code
 		errMsg  string
 	}{
 		"unknown QoS volume name": {
 			in:      utils.ResourceIDToVolumeName("unknown-qos-volume-id"),
 			out:     nil,
+			spdk:    []string{},
 			errCode: codes.NotFound,
 			errMsg:  fmt.Sprintf("unable to find key %s", utils.ResourceIDToVolumeName("unknown-qos-volume-id")),
 		},
 		"existing QoS volume": {
 			in:      testQosVolumeName,
 			out:     testQosVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"volume-42","assigned_rate_limits":{"rw_ios_per_sec":0,"rw_mbytes_per_sec":1,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0}}]}`},
 			errCode: codes.OK,
 			errMsg:  "",
 		},
+		"SPDK applies different limits than stored": {
+			in: testQosVolumeName,
+			out: &pb.QosVolume{
+				Name:          testQosVolume.Name,
+				VolumeNameRef: testQosVolume.VolumeNameRef,
+				Limits: &pb.Limits{
+					Max: &pb.QosLimit{RwIopsKiops: 2, RwBandwidthMbs: 5, RdBandwidthMbs: 3},
+				},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"volume-42","assigned_rate_limits":{"rw_ios_per_sec":2000,"rw_mbytes_per_sec":5,"r_mbytes_per_sec":3,"w_mbytes_per_sec":0}}]}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"SPDK reports no limits": {
+			in: testQosVolumeName,
+			out: &pb.QosVolume{
+				Name:          testQosVolume.Name,
+				VolumeNameRef: testQosVolume.VolumeNameRef,
+				Limits:        &pb.Limits{Max: &pb.QosLimit{}},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"volume-42"}]}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"underlying volume not found": {
+			in:      testQosVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":[]}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("expecting exactly 1 result, got %d", 0),
+		},
+		"SPDK call failed": {
+			in:      testQosVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":-19,"message":"No such device"},"result":[]}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("bdev_get_bdevs: %v", "json response error: No such device"),
+		},
 		"no required field": {
 			"",
 			nil,
+			[]string{},
 			codes.Unknown,
 			"missing required field: name",
 		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			testEnv := createTestEnvironment([]string{})
+			testEnv := createTestEnvironment(tt.spdk)
 			defer testEnv.Close()
 
 			testEnv.opiSpdkServer.volumes.qosVolumes[testQosVolumeName] = utils.ProtoClone(testQosVolume)
@@ -12,21 +12,19 @@ import (
 	"go.einride.tech/aip/resourcename"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
 
 func (s *Server) validateCreateQosVolumeRequest(in *pb.CreateQosVolumeRequest) error {
+	v := &utils.Validator{}
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
-	}
+	v.CheckRequiredFields(in)
 	// see https://google.aip.dev/133#user-specified-ids
 	if in.QosVolumeId != "" {
-		if err := resourceid.ValidateUserSettable(in.QosVolumeId); err != nil {
-			return err
-		}
+		v.Check("qos_volume_id", resourceid.ValidateUserSettable(in.QosVolumeId))
 	}
 	// TODO: validate also: block_size, blocks_count, uuid, filename
-	return nil
+	return v.Err()
 }
 
 func (s *Server) validateDeleteQosVolumeRequest(in *pb.DeleteQosVolumeRequest) error {
@@ -107,5 +105,16 @@ func (s *Server) verifyQosVolume(volume *pb.QosVolume) error {
 		return fmt.Errorf("QoS volume max_limit rw_bandwidth_mbs cannot be negative")
 	}
 
+	// SPDK enforces all set limits, so a read or write limit above the
+	// combined one would never be reached
+	if volume.Limits.Max.RwBandwidthMbs != 0 &&
+		volume.Limits.Max.RdBandwidthMbs > volume.Limits.Max.RwBandwidthMbs {
+		return fmt.Errorf("QoS volume max_limit rd_bandwidth_mbs cannot exceed rw_bandwidth_mbs")
+	}
+	if volume.Limits.Max.RwBandwidthMbs != 0 &&
+		volume.Limits.Max.WrBandwidthMbs > volume.Limits.Max.RwBandwidthMbs {
+		return fmt.Errorf("QoS volume max_limit wr_bandwidth_mbs cannot exceed rw_bandwidth_mbs")
+	}
+
 	return nil
 }
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package middleend implements the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestMiddleEnd_StateRoundTrip(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	spdkQos := `{"id":%d,"error":{"code":0,"message":""},"result":[{"name":"volume-42","assigned_rate_limits":{"rw_ios_per_sec":0,"rw_mbytes_per_sec":1,"r_mbytes_per_sec":0,"w_mbytes_per_sec":0}}]}`
+	testEnv := createTestEnvironment([]string{spdkQos})
+	defer testEnv.Close()
+	qosVolume := utils.ProtoClone(testQosVolume)
+	qosVolume.Name = testQosVolumeName
+	testEnv.opiSpdkServer.volumes.qosVolumes[testQosVolumeName] = qosVolume
+	encVolume := utils.ProtoClone(&encryptedVolume)
+	encVolume.Name = encryptedVolumeName
+	testEnv.opiSpdkServer.volumes.encVolumes[encryptedVolumeName] = encVolume
+	compositeVolume := testCompositeVolume
+	testEnv.opiSpdkServer.volumes.compositeVolumes[testCompositeVolumeName] = &compositeVolume
+
+	state, err := utils.NewStateServer(testEnv.opiSpdkServer).ExportState(testEnv.ctx, &emptypb.Empty{})
+	if err != nil {
+		t.Fatal("expected no error on export, received", err)
+	}
+
+	importEnv := createTestEnvironment([]string{spdkQos})
+	defer importEnv.Close()
+	if _, err := utils.NewStateServer(importEnv.opiSpdkServer).ImportState(importEnv.ctx, state); err != nil {
+		t.Fatal("expected no error on import, received", err)
+	}
+
+	// keys are kept, since deleting encrypted volumes requires them
+	if imported := importEnv.opiSpdkServer.volumes.encVolumes[encryptedVolumeName]; !proto.Equal(imported, encVolume) {
+		t.Error("encrypted volume: expected", encVolume, "received", imported)
+	}
+	if imported := importEnv.opiSpdkServer.volumes.compositeVolumes[testCompositeVolumeName]; !reflect.DeepEqual(imported, &compositeVolume) {
+		t.Error("composite volume: expected", compositeVolume, "received", imported)
+	}
+
+	request := &pb.GetQosVolumeRequest{Name: testQosVolumeName}
+	exported, err := testEnv.client.GetQosVolume(testEnv.ctx, request)
+	if err != nil {
+		t.Fatal("expected no error on get, received", err)
+	}
+	imported, err := importEnv.client.GetQosVolume(importEnv.ctx, request)
+	if err != nil {
+		t.Fatal("expected no error on get after import, received", err)
+	}
+	if !proto.Equal(imported, exported) {
+		t.Error("qos volume: expected", exported, "received", imported)
+	}
+}
@@ -0,0 +1,391 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package transaction executes multiple create requests atomically
+package transaction
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ServiceName is full name of the transaction service. It is not part of
+// OPI API, so it is registered with a hand written service descriptor
+const ServiceName = "opi_spdk_bridge.v1.TransactionService"
+
+// BackendServer serves BackEnd volumes which can be created in a transaction
+type BackendServer interface {
+	pb.NullVolumeServiceServer
+	pb.AioVolumeServiceServer
+	pb.MallocVolumeServiceServer
+}
+
+// Operation is a single create request executed in a transaction. Method
+// is name of OPI create RPC, e.g. CreateNullVolume
+type Operation struct {
+	Method  string
+	Request proto.Message
+}
+
+// operation binds create RPC to delete RPC used to roll it back
+type operation struct {
+	newRequest func() proto.Message
+	// name returns name of resource to be created if it is known upfront
+	name   func(proto.Message) string
+	create func(context.Context, proto.Message) (proto.Message, error)
+	get    func(context.Context, string) error
+	delete func(context.Context, string) error
+}
+
+// createdResource is resource created in a transaction deleted on rollback
+type createdResource struct {
+	op   operation
+	name string
+}
+
+// Server contains transaction related services
+type Server struct {
+	operations map[string]operation
+	// releaser is notified of resources deleted on rollback
+	releaser utils.ResourceReleaser
+	// quotas account resources created by operations to tenants
+	quotas *utils.TenantQuotas
+}
+
+// NewServer creates initialized instance of transaction server creating
+// resources by provided BackEnd and FrontEnd servers
+func NewServer(backend BackendServer, frontend pb.FrontendNvmeServiceServer) *Server {
+	if backend == nil {
+		log.Panic("nil for BackendServer is not allowed")
+	}
+	if frontend == nil {
+		log.Panic("nil for FrontendNvmeServiceServer is not allowed")
+	}
+	volumeName := func(id string) string {
+		if id == "" {
+			return ""
+		}
+		return utils.ResourceIDToVolumeName(id)
+	}
+	return &Server{operations: map[string]operation{
+		"CreateNullVolume": {
+			newRequest: func() proto.Message { return &pb.CreateNullVolumeRequest{} },
+			name: func(in proto.Message) string {
+				return volumeName(in.(*pb.CreateNullVolumeRequest).NullVolumeId)
+			},
+			create: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return backend.CreateNullVolume(ctx, in.(*pb.CreateNullVolumeRequest))
+			},
+			get: func(ctx context.Context, name string) error {
+				_, err := backend.GetNullVolume(ctx, &pb.GetNullVolumeRequest{Name: name})
+				return err
+			},
+			delete: func(ctx context.Context, name string) error {
+				_, err := backend.DeleteNullVolume(ctx, &pb.DeleteNullVolumeRequest{Name: name, AllowMissing: true})
+				return err
+			},
+		},
+		"CreateAioVolume": {
+			newRequest: func() proto.Message { return &pb.CreateAioVolumeRequest{} },
+			name: func(in proto.Message) string {
+				return volumeName(in.(*pb.CreateAioVolumeRequest).AioVolumeId)
+			},
+			create: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return backend.CreateAioVolume(ctx, in.(*pb.CreateAioVolumeRequest))
+			},
+			get: func(ctx context.Context, name string) error {
+				_, err := backend.GetAioVolume(ctx, &pb.GetAioVolumeRequest{Name: name})
+				return err
+			},
+			delete: func(ctx context.Context, name string) error {
+				_, err := backend.DeleteAioVolume(ctx, &pb.DeleteAioVolumeRequest{Name: name, AllowMissing: true})
+				return err
+			},
+		},
+		"CreateMallocVolume": {
+			newRequest: func() proto.Message { return &pb.CreateMallocVolumeRequest{} },
+			name: func(in proto.Message) string {
+				return volumeName(in.(*pb.CreateMallocVolumeRequest).MallocVolumeId)
+			},
+			create: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return backend.CreateMallocVolume(ctx, in.(*pb.CreateMallocVolumeRequest))
+			},
+			get: func(ctx context.Context, name string) error {
+				_, err := backend.GetMallocVolume(ctx, &pb.GetMallocVolumeRequest{Name: name})
+				return err
+			},
+			delete: func(ctx context.Context, name string) error {
+				_, err := backend.DeleteMallocVolume(ctx, &pb.DeleteMallocVolumeRequest{Name: name, AllowMissing: true})
+				return err
+			},
+		},
+		"CreateNvmeSubsystem": {
+			newRequest: func() proto.Message { return &pb.CreateNvmeSubsystemRequest{} },
+			name: func(in proto.Message) string {
+				if id := in.(*pb.CreateNvmeSubsystemRequest).NvmeSubsystemId; id != "" {
+					return utils.ResourceIDToSubsystemName(id)
+				}
+				return ""
+			},
+			create: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return frontend.CreateNvmeSubsystem(ctx, in.(*pb.CreateNvmeSubsystemRequest))
+			},
+			get: func(ctx context.Context, name string) error {
+				_, err := frontend.GetNvmeSubsystem(ctx, &pb.GetNvmeSubsystemRequest{Name: name})
+				return err
+			},
+			delete: func(ctx context.Context, name string) error {
+				_, err := frontend.DeleteNvmeSubsystem(ctx, &pb.DeleteNvmeSubsystemRequest{Name: name, AllowMissing: true})
+				return err
+			},
+		},
+		"CreateNvmeController": {
+			newRequest: func() proto.Message { return &pb.CreateNvmeControllerRequest{} },
+			name: func(in proto.Message) string {
+				request := in.(*pb.CreateNvmeControllerRequest)
+				if request.NvmeControllerId != "" {
+					return utils.ResourceIDToControllerName(utils.GetSubsystemIDFromNvmeName(request.Parent), request.NvmeControllerId)
+				}
+				return ""
+			},
+			create: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return frontend.CreateNvmeController(ctx, in.(*pb.CreateNvmeControllerRequest))
+			},
+			get: func(ctx context.Context, name string) error {
+				_, err := frontend.GetNvmeController(ctx, &pb.GetNvmeControllerRequest{Name: name})
+				return err
+			},
+			delete: func(ctx context.Context, name string) error {
+				_, err := frontend.DeleteNvmeController(ctx, &pb.DeleteNvmeControllerRequest{Name: name, AllowMissing: true})
+				return err
+			},
+		},
+		"CreateNvmeNamespace": {
+			newRequest: func() proto.Message { return &pb.CreateNvmeNamespaceRequest{} },
+			name: func(in proto.Message) string {
+				request := in.(*pb.CreateNvmeNamespaceRequest)
+				if request.NvmeNamespaceId != "" {
+					return utils.ResourceIDToNamespaceName(utils.GetSubsystemIDFromNvmeName(request.Parent), request.NvmeNamespaceId)
+				}
+				return ""
+			},
+			create: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return frontend.CreateNvmeNamespace(ctx, in.(*pb.CreateNvmeNamespaceRequest))
+			},
+			get: func(ctx context.Context, name string) error {
+				_, err := frontend.GetNvmeNamespace(ctx, &pb.GetNvmeNamespaceRequest{Name: name})
+				return err
+			},
+			delete: func(ctx context.Context, name string) error {
+				_, err := frontend.DeleteNvmeNamespace(ctx, &pb.DeleteNvmeNamespaceRequest{Name: name, AllowMissing: true})
+				return err
+			},
+		},
+	}}
+}
+
+func (s *Server) supportedMethods() []string {
+	methods := make([]string, 0, len(s.operations))
+	for method := range s.operations {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func (s *Server) lookup(method string) (operation, error) {
+	op, ok := s.operations[method]
+	if !ok {
+		msg := fmt.Sprintf("unsupported operation %v, supported are %v", method, s.supportedMethods())
+		return operation{}, status.Errorf(codes.InvalidArgument, msg)
+	}
+	return op, nil
+}
+
+// Execute runs operations in order and returns created resources. If any
+// of them fails, resources created so far are deleted in reverse order.
+// Resources which already existed before the transaction are kept
+func (s *Server) Execute(ctx context.Context, operations []Operation) ([]proto.Message, error) {
+	if len(operations) == 0 {
+		msg := "transaction has no operations"
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	ops := make([]operation, len(operations))
+	for i, operation := range operations {
+		op, err := s.lookup(operation.Method)
+		if err != nil {
+			return nil, err
+		}
+		ops[i] = op
+	}
+
+	var created []createdResource
+	resources := make([]proto.Message, 0, len(operations))
+	for i, operation := range operations {
+		op := ops[i]
+		name := op.name(operation.Request)
+		// anything but NotFound may be an existing resource, keep it on rollback
+		existed := name != "" && status.Code(op.get(ctx, name)) != codes.NotFound
+		resource, err := s.create(ctx, operation, op)
+		if err != nil {
+			log.Printf("Transaction operation %d %v failed, rolling back: %v", i, operation.Method, err)
+			s.rollback(ctx, created)
+			msg := fmt.Sprintf("operation %d %v failed: %v", i, operation.Method, status.Convert(err).Message())
+			return nil, status.Errorf(status.Code(err), msg)
+		}
+		if !existed {
+			created = append(created, createdResource{op: op, name: resource.(interface{ GetName() string }).GetName()})
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+// SetResourceReleaser sets releaser notified of resources deleted on
+// rollback, since its deletes bypass interceptors
+func (s *Server) SetResourceReleaser(releaser utils.ResourceReleaser) {
+	s.releaser = releaser
+}
+
+// SetTenantQuotas sets quotas operations are accounted against, since
+// creates of a transaction bypass interceptors
+func (s *Server) SetTenantQuotas(quotas *utils.TenantQuotas) {
+	s.quotas = quotas
+}
+
+// create runs create of operation accounted to tenant of ctx if quotas are
+// set. Kind of resource is name of create method without Create prefix
+func (s *Server) create(ctx context.Context, operation Operation, op operation) (proto.Message, error) {
+	if s.quotas == nil {
+		return op.create(ctx, operation.Request)
+	}
+	kind := strings.TrimPrefix(operation.Method, "Create")
+	resource, err := s.quotas.Create(ctx, kind, operation.Request, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return op.create(ctx, req.(proto.Message))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resource.(proto.Message), nil
+}
+
+func (s *Server) rollback(ctx context.Context, created []createdResource) {
+	// roll back even if ctx is done, not to leave a partial transaction
+	ctx, cancel := utils.CleanupContext(ctx)
+	defer cancel()
+	for i := len(created) - 1; i >= 0; i-- {
+		if err := created[i].op.delete(ctx, created[i].name); err != nil {
+			log.Printf("error: failed to roll back %v: %v", created[i].name, err)
+			continue
+		}
+		if s.releaser != nil {
+			s.releaser.Release(created[i].name)
+		}
+	}
+}
+
+// transactionRequest is JSON form of Transaction request
+type transactionRequest struct {
+	Operations []struct {
+		Method  string          `json:"method"`
+		Request json.RawMessage `json:"request"`
+	} `json:"operations"`
+}
+
+// Transaction executes operations listed in in as
+// {"operations":[{"method":"CreateNullVolume","request":{...}}]} and
+// returns created resources as {"resources":[{...}]}
+func (s *Server) Transaction(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	data, err := in.MarshalJSON()
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	var request transactionRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		msg := fmt.Sprintf("invalid transaction request: %v", err)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	operations := make([]Operation, len(request.Operations))
+	for i, operation := range request.Operations {
+		op, err := s.lookup(operation.Method)
+		if err != nil {
+			return nil, err
+		}
+		operations[i] = Operation{Method: operation.Method, Request: op.newRequest()}
+		if err := protojson.Unmarshal(operation.Request, operations[i].Request); err != nil {
+			msg := fmt.Sprintf("invalid request of operation %d %v: %v", i, operation.Method, err)
+			return nil, status.Errorf(codes.InvalidArgument, msg)
+		}
+	}
+
+	resources, err := s.Execute(ctx, operations)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]*structpb.Value, len(resources))
+	for i, resource := range resources {
+		data, err := protojson.Marshal(resource)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, err.Error())
+		}
+		values[i] = &structpb.Value{}
+		if err := values[i].UnmarshalJSON(data); err != nil {
+			return nil, status.Errorf(codes.Internal, err.Error())
+		}
+	}
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"resources": structpb.NewListValue(&structpb.ListValue{Values: values}),
+	}}, nil
+}
+
+// transactionServiceServer is implemented by Server
+type transactionServiceServer interface {
+	Transaction(context.Context, *structpb.Struct) (*structpb.Struct, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*transactionServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Transaction",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(structpb.Struct)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(transactionServiceServer).Transaction(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: "/" + ServiceName + "/Transaction",
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(transactionServiceServer).Transaction(ctx, req.(*structpb.Struct))
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterServer registers transaction service on s
+func RegisterServer(s *grpc.Server, srv *Server) {
+	s.RegisterService(&serviceDesc, srv)
+}
@@ -0,0 +1,397 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package transaction executes multiple create requests atomically
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fakeServer records create and delete calls of Null volumes and Nvme
+// resources and their releases, failing creates of failMethod. Deletes fail once their context
+// is done, as calls to SPDK do
+type fakeServer struct {
+	pb.UnimplementedNullVolumeServiceServer
+	pb.UnimplementedAioVolumeServiceServer
+	pb.UnimplementedMallocVolumeServiceServer
+	pb.UnimplementedFrontendNvmeServiceServer
+
+	failMethod string
+	existing   map[string]bool
+	calls      []string
+	// cancel is called by failing create, as if client canceled request
+	cancel context.CancelFunc
+}
+
+func newFakeServer(failMethod string, existing ...string) *fakeServer {
+	server := &fakeServer{failMethod: failMethod, existing: make(map[string]bool)}
+	for _, name := range existing {
+		server.existing[name] = true
+	}
+	return server
+}
+
+func (f *fakeServer) create(method, name string) error {
+	if method == f.failMethod {
+		if f.cancel != nil {
+			f.cancel()
+		}
+		return status.Errorf(codes.InvalidArgument, "could not create %v", name)
+	}
+	f.calls = append(f.calls, method+" "+name)
+	f.existing[name] = true
+	return nil
+}
+
+func (f *fakeServer) get(name string) error {
+	if !f.existing[name] {
+		return status.Errorf(codes.NotFound, "unable to find key %s", name)
+	}
+	return nil
+}
+
+func (f *fakeServer) delete(ctx context.Context, method, name string) (*emptypb.Empty, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	f.calls = append(f.calls, method+" "+name)
+	delete(f.existing, name)
+	return &emptypb.Empty{}, nil
+}
+
+func (f *fakeServer) Release(name string) {
+	f.calls = append(f.calls, "Release "+name)
+}
+
+func (f *fakeServer) CreateNullVolume(_ context.Context, in *pb.CreateNullVolumeRequest) (*pb.NullVolume, error) {
+	in.NullVolume.Name = utils.ResourceIDToVolumeName(in.NullVolumeId)
+	return in.NullVolume, f.create("CreateNullVolume", in.NullVolume.Name)
+}
+
+func (f *fakeServer) GetNullVolume(_ context.Context, in *pb.GetNullVolumeRequest) (*pb.NullVolume, error) {
+	return &pb.NullVolume{Name: in.Name}, f.get(in.Name)
+}
+
+func (f *fakeServer) DeleteNullVolume(ctx context.Context, in *pb.DeleteNullVolumeRequest) (*emptypb.Empty, error) {
+	return f.delete(ctx, "DeleteNullVolume", in.Name)
+}
+
+func (f *fakeServer) CreateNvmeSubsystem(_ context.Context, in *pb.CreateNvmeSubsystemRequest) (*pb.NvmeSubsystem, error) {
+	in.NvmeSubsystem.Name = utils.ResourceIDToSubsystemName(in.NvmeSubsystemId)
+	return in.NvmeSubsystem, f.create("CreateNvmeSubsystem", in.NvmeSubsystem.Name)
+}
+
+func (f *fakeServer) GetNvmeSubsystem(_ context.Context, in *pb.GetNvmeSubsystemRequest) (*pb.NvmeSubsystem, error) {
+	return &pb.NvmeSubsystem{Name: in.Name}, f.get(in.Name)
+}
+
+func (f *fakeServer) DeleteNvmeSubsystem(ctx context.Context, in *pb.DeleteNvmeSubsystemRequest) (*emptypb.Empty, error) {
+	return f.delete(ctx, "DeleteNvmeSubsystem", in.Name)
+}
+
+func (f *fakeServer) CreateNvmeController(_ context.Context, in *pb.CreateNvmeControllerRequest) (*pb.NvmeController, error) {
+	in.NvmeController.Name = utils.ResourceIDToControllerName(utils.GetSubsystemIDFromNvmeName(in.Parent), in.NvmeControllerId)
+	return in.NvmeController, f.create("CreateNvmeController", in.NvmeController.Name)
+}
+
+func (f *fakeServer) GetNvmeController(_ context.Context, in *pb.GetNvmeControllerRequest) (*pb.NvmeController, error) {
+	return &pb.NvmeController{Name: in.Name}, f.get(in.Name)
+}
+
+func (f *fakeServer) DeleteNvmeController(ctx context.Context, in *pb.DeleteNvmeControllerRequest) (*emptypb.Empty, error) {
+	return f.delete(ctx, "DeleteNvmeController", in.Name)
+}
+
+func (f *fakeServer) CreateNvmeNamespace(_ context.Context, in *pb.CreateNvmeNamespaceRequest) (*pb.NvmeNamespace, error) {
+	in.NvmeNamespace.Name = utils.ResourceIDToNamespaceName(utils.GetSubsystemIDFromNvmeName(in.Parent), in.NvmeNamespaceId)
+	return in.NvmeNamespace, f.create("CreateNvmeNamespace", in.NvmeNamespace.Name)
+}
+
+func (f *fakeServer) GetNvmeNamespace(_ context.Context, in *pb.GetNvmeNamespaceRequest) (*pb.NvmeNamespace, error) {
+	return &pb.NvmeNamespace{Name: in.Name}, f.get(in.Name)
+}
+
+func (f *fakeServer) DeleteNvmeNamespace(ctx context.Context, in *pb.DeleteNvmeNamespaceRequest) (*emptypb.Empty, error) {
+	return f.delete(ctx, "DeleteNvmeNamespace", in.Name)
+}
+
+func hostPathOperations() []Operation {
+	return []Operation{
+		{
+			Method: "CreateNullVolume",
+			Request: &pb.CreateNullVolumeRequest{
+				NullVolumeId: "vol0",
+				NullVolume:   &pb.NullVolume{BlockSize: 512, BlocksCount: 64},
+			},
+		},
+		{
+			Method: "CreateNvmeSubsystem",
+			Request: &pb.CreateNvmeSubsystemRequest{
+				NvmeSubsystemId: "subsys0",
+				NvmeSubsystem:   &pb.NvmeSubsystem{Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn.2022-09.io.spdk:opi1"}},
+			},
+		},
+		{
+			Method: "CreateNvmeController",
+			Request: &pb.CreateNvmeControllerRequest{
+				Parent:           "nvmeSubsystems/subsys0",
+				NvmeControllerId: "ctrl0",
+				NvmeController:   &pb.NvmeController{Spec: &pb.NvmeControllerSpec{}},
+			},
+		},
+		{
+			Method: "CreateNvmeNamespace",
+			Request: &pb.CreateNvmeNamespaceRequest{
+				Parent:          "nvmeSubsystems/subsys0",
+				NvmeNamespaceId: "ns0",
+				NvmeNamespace:   &pb.NvmeNamespace{Spec: &pb.NvmeNamespaceSpec{VolumeNameRef: "vol0"}},
+			},
+		},
+	}
+}
+
+func TestTransaction_Execute(t *testing.T) {
+	tests := map[string]struct {
+		operations []Operation
+		failMethod string
+		existing   []string
+		resources  []string
+		calls      []string
+		errCode    codes.Code
+		errMsg     string
+	}{
+		"all operations succeed": {
+			operations: hostPathOperations(),
+			failMethod: "",
+			existing:   nil,
+			resources: []string{
+				"volumes/vol0",
+				"nvmeSubsystems/subsys0",
+				"nvmeSubsystems/subsys0/nvmeControllers/ctrl0",
+				"nvmeSubsystems/subsys0/nvmeNamespaces/ns0",
+			},
+			calls: []string{
+				"CreateNullVolume volumes/vol0",
+				"CreateNvmeSubsystem nvmeSubsystems/subsys0",
+				"CreateNvmeController nvmeSubsystems/subsys0/nvmeControllers/ctrl0",
+				"CreateNvmeNamespace nvmeSubsystems/subsys0/nvmeNamespaces/ns0",
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"failure rolls back in reverse order": {
+			operations: hostPathOperations(),
+			failMethod: "CreateNvmeNamespace",
+			existing:   nil,
+			resources:  nil,
+			calls: []string{
+				"CreateNullVolume volumes/vol0",
+				"CreateNvmeSubsystem nvmeSubsystems/subsys0",
+				"CreateNvmeController nvmeSubsystems/subsys0/nvmeControllers/ctrl0",
+				"DeleteNvmeController nvmeSubsystems/subsys0/nvmeControllers/ctrl0",
+				"Release nvmeSubsystems/subsys0/nvmeControllers/ctrl0",
+				"DeleteNvmeSubsystem nvmeSubsystems/subsys0",
+				"Release nvmeSubsystems/subsys0",
+				"DeleteNullVolume volumes/vol0",
+				"Release volumes/vol0",
+			},
+			errCode: codes.InvalidArgument,
+			errMsg:  "operation 3 CreateNvmeNamespace failed: could not create nvmeSubsystems/subsys0/nvmeNamespaces/ns0",
+		},
+		"already existing resources are kept on rollback": {
+			operations: hostPathOperations(),
+			failMethod: "CreateNvmeController",
+			existing:   []string{"volumes/vol0"},
+			resources:  nil,
+			calls: []string{
+				"CreateNullVolume volumes/vol0",
+				"CreateNvmeSubsystem nvmeSubsystems/subsys0",
+				"DeleteNvmeSubsystem nvmeSubsystems/subsys0",
+				"Release nvmeSubsystems/subsys0",
+			},
+			errCode: codes.InvalidArgument,
+			errMsg:  "operation 2 CreateNvmeController failed: could not create nvmeSubsystems/subsys0/nvmeControllers/ctrl0",
+		},
+		"first operation fails": {
+			operations: hostPathOperations(),
+			failMethod: "CreateNullVolume",
+			existing:   nil,
+			resources:  nil,
+			calls:      nil,
+			errCode:    codes.InvalidArgument,
+			errMsg:     "operation 0 CreateNullVolume failed: could not create volumes/vol0",
+		},
+		"unsupported operation": {
+			operations: append(hostPathOperations(), Operation{Method: "DeleteNullVolume", Request: &pb.DeleteNullVolumeRequest{}}),
+			failMethod: "",
+			existing:   nil,
+			resources:  nil,
+			calls:      nil,
+			errCode:    codes.InvalidArgument,
+			errMsg: fmt.Sprintf("unsupported operation %v, supported are %v", "DeleteNullVolume",
+				[]string{"CreateAioVolume", "CreateMallocVolume", "CreateNullVolume", "CreateNvmeController", "CreateNvmeNamespace", "CreateNvmeSubsystem"}),
+		},
+		"no operations": {
+			operations: []Operation{},
+			failMethod: "",
+			existing:   nil,
+			resources:  nil,
+			calls:      nil,
+			errCode:    codes.InvalidArgument,
+			errMsg:     "transaction has no operations",
+		},
+	}
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			fake := newFakeServer(tt.failMethod, tt.existing...)
+			server := NewServer(fake, fake)
+			server.SetResourceReleaser(fake)
+
+			resources, err := server.Execute(context.Background(), tt.operations)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			var names []string
+			for _, resource := range resources {
+				names = append(names, resource.(interface{ GetName() string }).GetName())
+			}
+			if !reflect.DeepEqual(names, tt.resources) {
+				t.Error("resources: expected", tt.resources, "received", names)
+			}
+			if !reflect.DeepEqual(fake.calls, tt.calls) {
+				t.Error("calls: expected", tt.calls, "received", fake.calls)
+			}
+		})
+	}
+}
+
+func TestTransaction_ExecuteRollbackAfterCancel(t *testing.T) {
+	fake := newFakeServer("CreateNvmeController")
+	server := NewServer(fake, fake)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fake.cancel = cancel
+
+	_, err := server.Execute(ctx, hostPathOperations())
+
+	if status.Code(err) != codes.InvalidArgument {
+		t.Error("error code: expected", codes.InvalidArgument, "received", err)
+	}
+	calls := []string{
+		"CreateNullVolume volumes/vol0",
+		"CreateNvmeSubsystem nvmeSubsystems/subsys0",
+		"DeleteNvmeSubsystem nvmeSubsystems/subsys0",
+		"DeleteNullVolume volumes/vol0",
+	}
+	if !reflect.DeepEqual(fake.calls, calls) {
+		t.Error("calls: expected", calls, "received", fake.calls)
+	}
+}
+
+func TestTransaction_ExecuteTenantQuotas(t *testing.T) {
+	fake := newFakeServer("")
+	server := NewServer(fake, fake)
+	quotas, err := utils.NewTenantQuotas(map[string]int{"NullVolume": 1, "NvmeController": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetResourceReleaser(quotas)
+	server.SetTenantQuotas(quotas)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(utils.TenantMetadataKey, "tenant0"))
+
+	_, err = server.Execute(ctx, hostPathOperations())
+	if er, ok := status.FromError(err); !ok || er.Code() != codes.ResourceExhausted ||
+		er.Message() != "operation 2 CreateNvmeController failed: tenant tenant0 reached quota of 0 NvmeController" {
+		t.Error("expected quota of operation to be exceeded, received", err)
+	}
+	// quota of volume rolled back is released
+	if _, err := server.Execute(ctx, hostPathOperations()[:1]); err != nil {
+		t.Error("expected no error, received", err)
+	}
+	operations := []Operation{{
+		Method: "CreateNullVolume",
+		Request: &pb.CreateNullVolumeRequest{
+			NullVolumeId: "vol1",
+			NullVolume:   &pb.NullVolume{BlockSize: 512, BlocksCount: 64},
+		},
+	}}
+	if _, err := server.Execute(ctx, operations); status.Code(err) != codes.ResourceExhausted {
+		t.Error("error code: expected", codes.ResourceExhausted, "received", err)
+	}
+	// requests without tenant are not limited
+	if _, err := server.Execute(context.Background(), operations); err != nil {
+		t.Error("expected no error, received", err)
+	}
+}
+
+func TestTransaction_Transaction(t *testing.T) {
+	tests := map[string]struct {
+		request   string
+		resources []string
+		errCode   codes.Code
+	}{
+		"valid request": {
+			request: `{"operations":[
+				{"method":"CreateNullVolume","request":{"nullVolumeId":"vol0","nullVolume":{"blockSize":512,"blocksCount":64}}},
+				{"method":"CreateNvmeSubsystem","request":{"nvmeSubsystemId":"subsys0","nvmeSubsystem":{"spec":{"nqn":"nqn.2022-09.io.spdk:opi1"}}}}
+			]}`,
+			resources: []string{"volumes/vol0", "nvmeSubsystems/subsys0"},
+			errCode:   codes.OK,
+		},
+		"malformed operation request": {
+			request:   `{"operations":[{"method":"CreateNullVolume","request":{"nullVolumeId":5}}]}`,
+			resources: nil,
+			errCode:   codes.InvalidArgument,
+		},
+		"malformed operations": {
+			request:   `{"operations":"CreateNullVolume"}`,
+			resources: nil,
+			errCode:   codes.InvalidArgument,
+		},
+	}
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			fake := newFakeServer("")
+			server := NewServer(fake, fake)
+			request := &structpb.Struct{}
+			if err := request.UnmarshalJSON([]byte(tt.request)); err != nil {
+				t.Fatal(err)
+			}
+
+			response, err := server.Transaction(context.Background(), request)
+
+			if code := status.Code(err); code != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", code, err)
+			}
+			var names []string
+			for _, resource := range response.GetFields()["resources"].GetListValue().GetValues() {
+				names = append(names, resource.GetStructValue().GetFields()["name"].GetStringValue())
+			}
+			if !reflect.DeepEqual(names, tt.resources) {
+				t.Error("resources: expected", tt.resources, "received", names)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/opiproject/gospdk/spdk"
+)
+
+// SpdkBatch issues independent SPDK GET calls needed by a single request
+// concurrently and collects their results at a single point. Identical calls,
+// same method with same params, are sent to SPDK once and their result is
+// shared. A batch is request scoped and must not be reused after Wait
+type SpdkBatch struct {
+	ctx   context.Context
+	rpc   spdk.JSONRPC
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	calls map[string]*batchedCall
+	gets  []batchedGet
+}
+
+type batchedCall struct {
+	method string
+	result json.RawMessage
+	err    error
+}
+
+type batchedGet struct {
+	call   *batchedCall
+	result interface{}
+}
+
+// NewSpdkBatch creates a batch calling SPDK via jsonRPC within ctx
+func NewSpdkBatch(ctx context.Context, jsonRPC spdk.JSONRPC) *SpdkBatch {
+	if jsonRPC == nil {
+		log.Panic("nil for JSONRPC is not allowed")
+	}
+	return &SpdkBatch{
+		ctx:   ctx,
+		rpc:   jsonRPC,
+		calls: make(map[string]*batchedCall),
+	}
+}
+
+// Get issues SPDK method with params in background. result is filled in
+// once Wait returns without error
+func (b *SpdkBatch) Get(method string, params, result interface{}) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		log.Panicf("failed to marshal params of %s: %v", method, err)
+	}
+	key := method + string(data)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	call, ok := b.calls[key]
+	if !ok {
+		call = &batchedCall{method: method}
+		b.calls[key] = call
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			call.err = b.rpc.Call(b.ctx, method, params, &call.result)
+		}()
+	}
+	b.gets = append(b.gets, batchedGet{call: call, result: result})
+}
+
+// Wait waits for all issued calls and fills in their results. It returns
+// error of the first failed call in the order calls were issued
+func (b *SpdkBatch) Wait() error {
+	b.wg.Wait()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, get := range b.gets {
+		if get.call.err != nil {
+			return get.call.err
+		}
+		if err := json.Unmarshal(get.call.result, get.result); err != nil {
+			return fmt.Errorf("%s: %s", get.call.method, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/opiproject/gospdk/spdk"
+)
+
+// stubBatchJSONRPC answers SPDK methods with fixed JSON results or errors
+// and counts calls per method
+type stubBatchJSONRPC struct {
+	spdk.JSONRPC
+	mu      sync.Mutex
+	results map[string]string
+	errs    map[string]error
+	calls   map[string]int
+}
+
+func (r *stubBatchJSONRPC) Call(_ context.Context, method string, _, result interface{}) error {
+	r.mu.Lock()
+	r.calls[method]++
+	r.mu.Unlock()
+	if err, ok := r.errs[method]; ok {
+		return err
+	}
+	return json.Unmarshal([]byte(r.results[method]), result)
+}
+
+func TestSpdkBatch(t *testing.T) {
+	tests := map[string]struct {
+		errs   map[string]error
+		calls  map[string]int
+		errMsg string
+	}{
+		"results are filled in and identical calls shared": {
+			errs:   map[string]error{},
+			calls:  map[string]int{"bdev_get_bdevs": 2, "bdev_get_iostat": 1},
+			errMsg: "",
+		},
+		"first failed call in issue order is returned": {
+			errs: map[string]error{
+				"bdev_get_iostat": errors.New("bdev_get_iostat: json response error: iostat"),
+				"bdev_get_bdevs":  errors.New("bdev_get_bdevs: json response error: bdevs"),
+			},
+			calls:  map[string]int{"bdev_get_bdevs": 2, "bdev_get_iostat": 1},
+			errMsg: "bdev_get_bdevs: json response error: bdevs",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rpc := &stubBatchJSONRPC{
+				results: map[string]string{
+					"bdev_get_bdevs":  `[{"name":"Malloc1","block_size":512,"num_blocks":64}]`,
+					"bdev_get_iostat": `{"tick_rate":1000,"bdevs":[{"name":"Malloc1","num_read_ops":7}]}`,
+				},
+				errs:  tt.errs,
+				calls: make(map[string]int),
+			}
+			batch := NewSpdkBatch(context.Background(), rpc)
+			var first, same, other []spdk.BdevGetBdevsResult
+			var iostat spdk.BdevGetIostatResult
+			batch.Get("bdev_get_bdevs", &spdk.BdevGetBdevsParams{Name: "Malloc1"}, &first)
+			batch.Get("bdev_get_iostat", &spdk.BdevGetIostatParams{Name: "Malloc1"}, &iostat)
+			batch.Get("bdev_get_bdevs", &spdk.BdevGetBdevsParams{Name: "Malloc1"}, &same)
+			batch.Get("bdev_get_bdevs", &spdk.BdevGetBdevsParams{Name: "Malloc2"}, &other)
+			err := batch.Wait()
+
+			if err == nil && tt.errMsg != "" || err != nil && err.Error() != tt.errMsg {
+				t.Error("error: expected", tt.errMsg, "received", err)
+			}
+			if !reflect.DeepEqual(rpc.calls, tt.calls) {
+				t.Error("calls: expected", tt.calls, "received", rpc.calls)
+			}
+			if err != nil {
+				return
+			}
+			if len(first) != 1 || first[0].NumBlocks != 64 || !reflect.DeepEqual(first, same) {
+				t.Error("bdevs: expected shared result of 64 blocks, received", first, same)
+			}
+			if len(iostat.Bdevs) != 1 || iostat.Bdevs[0].NumReadOps != 7 {
+				t.Error("iostat: expected 7 reads, received", iostat)
+			}
+		})
+	}
+}
+
+func TestSpdkBatchUnmarshalError(t *testing.T) {
+	rpc := &stubBatchJSONRPC{
+		results: map[string]string{"bdev_get_bdevs": `true`},
+		errs:    map[string]error{},
+		calls:   make(map[string]int),
+	}
+	batch := NewSpdkBatch(context.Background(), rpc)
+	var result []spdk.BdevGetBdevsResult
+	batch.Get("bdev_get_bdevs", nil, &result)
+	errMsg := "bdev_get_bdevs: json: cannot unmarshal bool into Go value of type []spdk.BdevGetBdevsResult"
+	if err := batch.Wait(); err == nil || err.Error() != errMsg {
+		t.Error("error: expected", errMsg, "received", err)
+	}
+}
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	channelzservice "google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// ChannelzServicePrefix is prefix of full method names of channelz service
+const ChannelzServicePrefix = "/grpc.channelz.v1.Channelz/"
+
+// RegisterChannelz registers channelz service exposing internal state of
+// gRPC connections on s if enabled
+func RegisterChannelz(s *grpc.Server, enabled bool) {
+	if !enabled {
+		return
+	}
+	channelzservice.RegisterChannelzServiceToServer(s)
+}
+
+// NewAdminUnaryServerInterceptor creates interceptor allowing calls of
+// methods starting with one of prefixes only to clients presenting a
+// verified TLS certificate with common name or DNS name listed in admins
+func NewAdminUnaryServerInterceptor(admins []string, prefixes ...string) grpc.UnaryServerInterceptor {
+	allowed := allowedAdmins(admins)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(info.FullMethod, prefix) {
+				if !isAdmin(ctx, allowed) {
+					msg := fmt.Sprintf("admin identity is required to call %s", info.FullMethod)
+					return nil, status.Errorf(codes.PermissionDenied, msg)
+				}
+				break
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// CheckAdminRestricted returns error unless calls of admin services can be
+// restricted to admins: TLS is configured, at least one admin identity is
+// given and admin interceptor is enabled in interceptors
+func CheckAdminRestricted(tlsFiles string, admins, interceptors []string) error {
+	if tlsFiles == "" {
+		return fmt.Errorf("tls is not configured")
+	}
+	if len(allowedAdmins(admins)) == 0 {
+		return fmt.Errorf("no admin identities configured")
+	}
+	for _, name := range interceptors {
+		if name == AdminInterceptor {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s interceptor is not enabled", AdminInterceptor)
+}
+
+func allowedAdmins(admins []string) map[string]struct{} {
+	allowed := make(map[string]struct{}, len(admins))
+	for _, admin := range admins {
+		if admin != "" {
+			allowed[admin] = struct{}{}
+		}
+	}
+	return allowed
+}
+
+func isAdmin(ctx context.Context, allowed map[string]struct{}) bool {
+	return matchAdmin(ctx, allowed) != ""
+}
+
+// matchAdmin returns common name or DNS name of verified client certificate
+// listed in allowed, or empty string if there is none
+func matchAdmin(ctx context.Context, allowed map[string]struct{}) string {
+	for _, cert := range verifiedClientCertificates(ctx) {
+		if _, ok := allowed[cert.Subject.CommonName]; ok {
+			return cert.Subject.CommonName
+		}
+		for _, name := range cert.DNSNames {
+			if _, ok := allowed[name]; ok {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// verifiedClientCertificates returns leaf certificates of verified chains
+// presented by TLS client
+func verifiedClientCertificates(ctx context.Context) []*x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+	certs := make([]*x509.Certificate, 0, len(tlsInfo.State.VerifiedChains))
+	for _, chain := range tlsInfo.State.VerifiedChains {
+		if len(chain) > 0 {
+			certs = append(certs, chain[0])
+		}
+	}
+	return certs
+}
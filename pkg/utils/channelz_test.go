@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestRegisterChannelz(t *testing.T) {
+	tests := map[string]struct {
+		enabled        bool
+		wantRegistered bool
+	}{
+		"enabled": {
+			enabled:        true,
+			wantRegistered: true,
+		},
+		"disabled": {
+			enabled:        false,
+			wantRegistered: false,
+		},
+	}
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			s := grpc.NewServer()
+			RegisterChannelz(s, tt.enabled)
+
+			_, registered := s.GetServiceInfo()["grpc.channelz.v1.Channelz"]
+			if registered != tt.wantRegistered {
+				t.Error("channelz registered: expected", tt.wantRegistered, "received", registered)
+			}
+		})
+	}
+}
+
+func tlsPeerContext(commonName string, dnsNames ...string) context.Context {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}, DNSNames: dnsNames}
+	return peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+		},
+	})
+}
+
+func TestNewAdminUnaryServerInterceptor(t *testing.T) {
+	channelzMethod := ChannelzServicePrefix + "GetTopChannels"
+	tests := map[string]struct {
+		ctx     context.Context
+		method  string
+		errCode codes.Code
+	}{
+		"admin common name": {
+			ctx:     tlsPeerContext("admin"),
+			method:  channelzMethod,
+			errCode: codes.OK,
+		},
+		"admin dns name": {
+			ctx:     tlsPeerContext("client", "ops.example.com"),
+			method:  channelzMethod,
+			errCode: codes.OK,
+		},
+		"not an admin": {
+			ctx:     tlsPeerContext("client"),
+			method:  channelzMethod,
+			errCode: codes.PermissionDenied,
+		},
+		"certificate without names": {
+			ctx:     tlsPeerContext(""),
+			method:  channelzMethod,
+			errCode: codes.PermissionDenied,
+		},
+		"no tls peer": {
+			ctx:     context.Background(),
+			method:  channelzMethod,
+			errCode: codes.PermissionDenied,
+		},
+		"not restricted method": {
+			ctx:     tlsPeerContext("client"),
+			method:  "/opi_api.storage.v1.NullVolumeService/GetNullVolume",
+			errCode: codes.OK,
+		},
+	}
+	interceptor := NewAdminUnaryServerInterceptor([]string{"admin", "", "ops.example.com"}, ChannelzServicePrefix)
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			handlerCalled := false
+			handler := func(context.Context, interface{}) (interface{}, error) {
+				handlerCalled = true
+				return nil, nil
+			}
+
+			_, err := interceptor(tt.ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+
+			if code := status.Code(err); code != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", code)
+			}
+			if handlerCalled != (tt.errCode == codes.OK) {
+				t.Error("handler called: expected", tt.errCode == codes.OK, "received", handlerCalled)
+			}
+		})
+	}
+}
+
+func TestCheckAdminRestricted(t *testing.T) {
+	tests := map[string]struct {
+		tlsFiles     string
+		admins       []string
+		interceptors []string
+		wantErr      bool
+	}{
+		"restricted": {
+			tlsFiles:     "server.crt:server.key:ca.crt",
+			admins:       []string{"admin"},
+			interceptors: DefaultInterceptors,
+			wantErr:      false,
+		},
+		"no tls": {
+			tlsFiles:     "",
+			admins:       []string{"admin"},
+			interceptors: DefaultInterceptors,
+			wantErr:      true,
+		},
+		"no admins": {
+			tlsFiles:     "server.crt:server.key:ca.crt",
+			admins:       []string{""},
+			interceptors: DefaultInterceptors,
+			wantErr:      true,
+		},
+		"admin interceptor disabled": {
+			tlsFiles:     "server.crt:server.key:ca.crt",
+			admins:       []string{"admin"},
+			interceptors: []string{LoggingInterceptor},
+			wantErr:      true,
+		},
+	}
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			err := CheckAdminRestricted(tt.tlsFiles, tt.admins, tt.interceptors)
+
+			if (err != nil) != tt.wantErr {
+				t.Error("expected error", tt.wantErr, "received", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config contains bridge settings which can be provided in a JSON or YAML
+// config file. Keys are named after the flags setting the same values
+type Config struct {
+	GrpcPort     int             `json:"grpc_port,omitempty"`
+	HTTPPort     int             `json:"http_port,omitempty"`
+	SpdkAddress  string          `json:"spdk_addr,omitempty"`
+	RedisAddress string          `json:"redis_addr,omitempty"`
+	TLSFiles     string          `json:"tls,omitempty"`
+	LogLevel     string          `json:"log_level,omitempty"`
+	LogFormat    string          `json:"log_format,omitempty"`
+	FeatureFlags map[string]bool `json:"feature_flags,omitempty"`
+	Kvm          bool            `json:"kvm,omitempty"`
+	QmpAddress   string          `json:"qmp_addr,omitempty"`
+	CtrlrDir     string          `json:"ctrlr_dir,omitempty"`
+	// Buses lists QEMU PCI buses IDs to attach devices on
+	Buses []string `json:"buses,omitempty"`
+	// Interceptors lists enabled unary server interceptors in invocation order
+	Interceptors []string `json:"interceptors,omitempty"`
+	// TenantQuotas maps resource kinds to number of them a tenant can create
+	TenantQuotas map[string]int `json:"tenant_quotas,omitempty"`
+}
+
+// configKeys returns keys of config file, i.e. json names of Config fields
+func configKeys() map[string]int {
+	keys := make(map[string]int)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		keys[name] = i
+	}
+	return keys
+}
+
+// LoadConfig reads config file located at path. Files with .yaml or .yml
+// extension are parsed as YAML, others as JSON. Unknown keys are reported
+// as error, so that misspelled settings are not silently ignored
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		var content map[string]interface{}
+		if err := yaml.Unmarshal(data, &content); err != nil {
+			return Config{}, fmt.Errorf("failed to parse config file %v: %v", path, err)
+		}
+		if data, err = json.Marshal(content); err != nil {
+			return Config{}, fmt.Errorf("failed to parse config file %v: %v", path, err)
+		}
+	}
+	var content map[string]json.RawMessage
+	if err := json.Unmarshal(data, &content); err != nil {
+		return Config{}, fmt.Errorf("failed to parse config file %v: %v", path, err)
+	}
+	keys := configKeys()
+	var unknown []string
+	for key := range content {
+		if _, ok := keys[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) != 0 {
+		sort.Strings(unknown)
+		return Config{}, fmt.Errorf("unknown keys in config file %v: %v", path, strings.Join(unknown, ", "))
+	}
+	config := Config{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("failed to parse config file %v: %v", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config file %v: %v", path, err)
+	}
+	return config, nil
+}
+
+// Validate checks values of settings which are not validated when applied
+func (c Config) Validate() error {
+	if c.GrpcPort < 0 || c.GrpcPort > 65535 {
+		return fmt.Errorf("grpc_port %d is not a valid port", c.GrpcPort)
+	}
+	if c.HTTPPort < 0 || c.HTTPPort > 65535 {
+		return fmt.Errorf("http_port %d is not a valid port", c.HTTPPort)
+	}
+	seen := make(map[string]bool, len(c.Buses))
+	for _, bus := range c.Buses {
+		if bus == "" || strings.Contains(bus, ":") {
+			return fmt.Errorf("buses contain invalid bus ID %q", bus)
+		}
+		if seen[bus] {
+			return fmt.Errorf("buses contain bus ID %q twice", bus)
+		}
+		seen[bus] = true
+	}
+	return nil
+}
+
+// MergeConfig returns settings of file config overridden by flags config.
+// Flags config holds values of all flags, so only flags named in setFlags,
+// i.e. explicitly given on command line, and settings missing in the file
+// take flag values. Flag defaults do not override the file this way
+func MergeConfig(file Config, flags Config, setFlags map[string]bool) Config {
+	merged := reflect.ValueOf(&file).Elem()
+	flagValues := reflect.ValueOf(flags)
+	for name, i := range configKeys() {
+		if setFlags[name] || merged.Field(i).IsZero() {
+			merged.Field(i).Set(flagValues.Field(i))
+		}
+	}
+	return file
+}
+
+// ReloadConfig applies settings from next which are safe to change without
+// dropping connections and updates current accordingly. Names of changed
+// settings which require restart are returned and ignored until restart.
+func ReloadConfig(current *Config, next Config) ([]string, error) {
+	if next.LogLevel != "" && next.LogLevel != current.LogLevel {
+		if err := SetLogLevel(next.LogLevel); err != nil {
+			return nil, err
+		}
+		log.Printf("Log level changed from %q to %q", current.LogLevel, next.LogLevel)
+		current.LogLevel = next.LogLevel
+	}
+	if next.LogFormat != "" && next.LogFormat != current.LogFormat {
+		if err := SetLogFormat(next.LogFormat); err != nil {
+			return nil, err
+		}
+		log.Printf("Log format changed from %q to %q", current.LogFormat, next.LogFormat)
+		current.LogFormat = next.LogFormat
+	}
+
+	var restartRequired []string
+	switch {
+	case (current.TLSFiles == "") != (next.TLSFiles == ""):
+		// enabling or disabling TLS changes server credentials
+		restartRequired = append(restartRequired, "tls")
+	case next.TLSFiles != "":
+		// re-read even unchanged paths to pick up rotated certificates
+		tlsConfig, err := ParseTLSFiles(next.TLSFiles)
+		if err != nil {
+			return nil, err
+		}
+		if err := ReloadTLSCredentials(tlsConfig); err != nil {
+			return nil, err
+		}
+		current.TLSFiles = next.TLSFiles
+	}
+
+	if !reflect.DeepEqual(next.FeatureFlags, current.FeatureFlags) {
+		SetFeatureFlags(next.FeatureFlags)
+		current.FeatureFlags = next.FeatureFlags
+	}
+
+	if next.GrpcPort != 0 && next.GrpcPort != current.GrpcPort {
+		restartRequired = append(restartRequired, "grpc_port")
+	}
+	if next.HTTPPort != 0 && next.HTTPPort != current.HTTPPort {
+		restartRequired = append(restartRequired, "http_port")
+	}
+	if next.SpdkAddress != "" && next.SpdkAddress != current.SpdkAddress {
+		restartRequired = append(restartRequired, "spdk_addr")
+	}
+	if next.RedisAddress != "" && next.RedisAddress != current.RedisAddress {
+		restartRequired = append(restartRequired, "redis_addr")
+	}
+	if next.Interceptors != nil && !reflect.DeepEqual(next.Interceptors, current.Interceptors) {
+		restartRequired = append(restartRequired, "interceptors")
+	}
+	if next.TenantQuotas != nil && !reflect.DeepEqual(next.TenantQuotas, current.TenantQuotas) {
+		restartRequired = append(restartRequired, "tenant_quotas")
+	}
+	if next.Kvm != current.Kvm {
+		restartRequired = append(restartRequired, "kvm")
+	}
+	if next.QmpAddress != "" && next.QmpAddress != current.QmpAddress {
+		restartRequired = append(restartRequired, "qmp_addr")
+	}
+	if next.CtrlrDir != "" && next.CtrlrDir != current.CtrlrDir {
+		restartRequired = append(restartRequired, "ctrlr_dir")
+	}
+	if next.Buses != nil && !reflect.DeepEqual(next.Buses, current.Buses) {
+		restartRequired = append(restartRequired, "buses")
+	}
+	for _, name := range restartRequired {
+		log.Printf("Config change of %v is ignored until restart", name)
+	}
+	return restartRequired, nil
+}
+
+var featureFlags = struct {
+	sync.RWMutex
+	flags map[string]bool
+}{}
+
+// SetFeatureFlags replaces currently configured feature flags
+func SetFeatureFlags(flags map[string]bool) {
+	featureFlags.Lock()
+	defer featureFlags.Unlock()
+	featureFlags.flags = make(map[string]bool, len(flags))
+	for name, enabled := range flags {
+		featureFlags.flags[name] = enabled
+	}
+}
+
+// FeatureEnabled reports whether feature flag is turned on
+func FeatureEnabled(name string) bool {
+	featureFlags.RLock()
+	defer featureFlags.RUnlock()
+	return featureFlags.flags[name]
+}
@@ -0,0 +1,301 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+)
+
+func TestConfig_LoadConfig(t *testing.T) {
+	tests := map[string]struct {
+		file      string
+		content   string
+		config    Config
+		expectErr bool
+	}{
+		"valid config": {
+			content: `{"grpc_port":50051,"log_level":"info","feature_flags":{"feature":true},"interceptors":["spdk_calls","logging"],"tenant_quotas":{"NvmeSubsystem":10}}`,
+			config: Config{
+				GrpcPort:     50051,
+				LogLevel:     "info",
+				FeatureFlags: map[string]bool{"feature": true},
+				Interceptors: []string{"spdk_calls", "logging"},
+				TenantQuotas: map[string]int{"NvmeSubsystem": 10},
+			},
+			expectErr: false,
+		},
+		"kvm options": {
+			content: `{"spdk_addr":"/var/tmp/spdk2.sock","kvm":true,"qmp_addr":"/tmp/qmp.sock","ctrlr_dir":"/tmp/ctrlrs","buses":["pci.opi.0","pci.opi.1"]}`,
+			config: Config{
+				SpdkAddress: "/var/tmp/spdk2.sock",
+				Kvm:         true,
+				QmpAddress:  "/tmp/qmp.sock",
+				CtrlrDir:    "/tmp/ctrlrs",
+				Buses:       []string{"pci.opi.0", "pci.opi.1"},
+			},
+			expectErr: false,
+		},
+		"yaml config": {
+			file:    "config.yaml",
+			content: "grpc_port: 50052\nredis_addr: 10.0.0.1:6379\nkvm: true\nbuses:\n  - pci.opi.0\nfeature_flags:\n  feature: true\n",
+			config: Config{
+				GrpcPort:     50052,
+				RedisAddress: "10.0.0.1:6379",
+				Kvm:          true,
+				Buses:        []string{"pci.opi.0"},
+				FeatureFlags: map[string]bool{"feature": true},
+			},
+			expectErr: false,
+		},
+		"malformed config": {
+			content:   `{"grpc_port":`,
+			config:    Config{},
+			expectErr: true,
+		},
+		"malformed yaml config": {
+			file:      "config.yml",
+			content:   "grpc_port: [",
+			config:    Config{},
+			expectErr: true,
+		},
+		"unknown keys": {
+			content:   `{"grpc_port":50051,"spdk_address":"/var/tmp/spdk.sock","bus":["pci.opi.0"]}`,
+			config:    Config{},
+			expectErr: true,
+		},
+		"wrong value type": {
+			content:   `{"buses":"pci.opi.0"}`,
+			config:    Config{},
+			expectErr: true,
+		},
+		"invalid port": {
+			content:   `{"http_port":70000}`,
+			config:    Config{},
+			expectErr: true,
+		},
+		"duplicate bus": {
+			content:   `{"buses":["pci.opi.0","pci.opi.0"]}`,
+			config:    Config{},
+			expectErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			file := tt.file
+			if file == "" {
+				file = "config.json"
+			}
+			path := filepath.Join(t.TempDir(), file)
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			config, err := LoadConfig(path)
+			if (err != nil) != tt.expectErr {
+				t.Error("Expect error", tt.expectErr, "received", err)
+			}
+			if !reflect.DeepEqual(config, tt.config) {
+				t.Error("config: expected", tt.config, "received", config)
+			}
+		})
+	}
+}
+
+func TestConfig_LoadConfigUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"grpc_port":50051,"spdk_address":"x","bus":[]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadConfig(path)
+
+	expected := "unknown keys in config file " + path + ": bus, spdk_address"
+	if err == nil || err.Error() != expected {
+		t.Error("error: expected", expected, "received", err)
+	}
+}
+
+func TestConfig_MergeConfig(t *testing.T) {
+	flags := Config{
+		GrpcPort:     50051,
+		HTTPPort:     8082,
+		SpdkAddress:  "/var/tmp/spdk.sock",
+		RedisAddress: "127.0.0.1:6379",
+		LogLevel:     "info",
+		QmpAddress:   "127.0.0.1:5555",
+		Buses:        []string{},
+	}
+	tests := map[string]struct {
+		file     Config
+		setFlags map[string]bool
+		config   Config
+	}{
+		"file sets values": {
+			file: Config{
+				GrpcPort:    50052,
+				SpdkAddress: "/var/tmp/spdk2.sock",
+				Kvm:         true,
+				Buses:       []string{"pci.opi.0"},
+			},
+			setFlags: map[string]bool{},
+			config: Config{
+				GrpcPort:     50052,
+				HTTPPort:     8082,
+				SpdkAddress:  "/var/tmp/spdk2.sock",
+				RedisAddress: "127.0.0.1:6379",
+				LogLevel:     "info",
+				Kvm:          true,
+				QmpAddress:   "127.0.0.1:5555",
+				Buses:        []string{"pci.opi.0"},
+			},
+		},
+		"flag overrides file value": {
+			file: Config{
+				GrpcPort:    50052,
+				SpdkAddress: "/var/tmp/spdk2.sock",
+				Buses:       []string{"pci.opi.0"},
+			},
+			setFlags: map[string]bool{"grpc_port": true, "buses": true},
+			config: Config{
+				GrpcPort:     50051,
+				HTTPPort:     8082,
+				SpdkAddress:  "/var/tmp/spdk2.sock",
+				RedisAddress: "127.0.0.1:6379",
+				LogLevel:     "info",
+				QmpAddress:   "127.0.0.1:5555",
+				Buses:        []string{},
+			},
+		},
+		"empty file": {
+			file:     Config{},
+			setFlags: map[string]bool{},
+			config:   flags,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := MergeConfig(tt.file, flags, tt.setFlags)
+			if !reflect.DeepEqual(config, tt.config) {
+				t.Error("config: expected", tt.config, "received", config)
+			}
+		})
+	}
+}
+
+func TestConfig_ReloadConfig(t *testing.T) {
+	tests := map[string]struct {
+		next            Config
+		logLevel        logging.Level
+		jsonFormat      bool
+		feature         bool
+		restartRequired []string
+		expectErr       bool
+	}{
+		"log level change applied": {
+			next:            Config{GrpcPort: 50051, LogLevel: "error"},
+			logLevel:        logging.LevelError,
+			feature:         false,
+			restartRequired: nil,
+			expectErr:       false,
+		},
+		"port change requires restart": {
+			next:            Config{GrpcPort: 50052, HTTPPort: 8083},
+			logLevel:        logging.LevelDebug,
+			feature:         false,
+			restartRequired: []string{"grpc_port", "http_port"},
+			expectErr:       false,
+		},
+		"log level and port change": {
+			next:            Config{GrpcPort: 50052, LogLevel: "warn"},
+			logLevel:        logging.LevelWarn,
+			feature:         false,
+			restartRequired: []string{"grpc_port"},
+			expectErr:       false,
+		},
+		"enabling tls requires restart": {
+			next:            Config{TLSFiles: "a:b:c"},
+			logLevel:        logging.LevelDebug,
+			feature:         false,
+			restartRequired: []string{"tls"},
+			expectErr:       false,
+		},
+		"feature flag applied": {
+			next:            Config{FeatureFlags: map[string]bool{"feature": true}},
+			logLevel:        logging.LevelDebug,
+			feature:         true,
+			restartRequired: nil,
+			expectErr:       false,
+		},
+		"interceptors change requires restart": {
+			next:            Config{Interceptors: []string{"logging"}},
+			logLevel:        logging.LevelDebug,
+			feature:         false,
+			restartRequired: []string{"interceptors"},
+			expectErr:       false,
+		},
+		"log format change applied": {
+			next:            Config{LogFormat: "json"},
+			logLevel:        logging.LevelDebug,
+			jsonFormat:      true,
+			feature:         false,
+			restartRequired: nil,
+			expectErr:       false,
+		},
+		"unknown log format": {
+			next:            Config{LogFormat: "xml"},
+			logLevel:        logging.LevelDebug,
+			feature:         false,
+			restartRequired: nil,
+			expectErr:       true,
+		},
+		"unknown log level": {
+			next:            Config{LogLevel: "verbose"},
+			logLevel:        logging.LevelDebug,
+			feature:         false,
+			restartRequired: nil,
+			expectErr:       true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_ = SetLogLevel("debug")
+			_ = SetLogFormat(LogFormatText)
+			SetFeatureFlags(nil)
+			t.Cleanup(func() {
+				_ = SetLogLevel("debug")
+				_ = SetLogFormat(LogFormatText)
+				SetFeatureFlags(nil)
+			})
+			current := &Config{GrpcPort: 50051, HTTPPort: 8082, LogLevel: "debug"}
+
+			restartRequired, err := ReloadConfig(current, tt.next)
+			if (err != nil) != tt.expectErr {
+				t.Error("Expect error", tt.expectErr, "received", err)
+			}
+			if !reflect.DeepEqual(restartRequired, tt.restartRequired) {
+				t.Error("restart required: expected", tt.restartRequired, "received", restartRequired)
+			}
+			for _, lvl := range []logging.Level{logging.LevelDebug, logging.LevelInfo, logging.LevelWarn, logging.LevelError} {
+				if logLevelEnabled(lvl) != (lvl >= tt.logLevel) {
+					t.Error("log level", lvl, "enabled", logLevelEnabled(lvl), "with configured level", tt.logLevel)
+				}
+			}
+			if logFormatJSON() != tt.jsonFormat {
+				t.Error("json log format: expected", tt.jsonFormat, "received", logFormatJSON())
+			}
+			if FeatureEnabled("feature") != tt.feature {
+				t.Error("feature: expected", tt.feature, "received", FeatureEnabled("feature"))
+			}
+			if current.GrpcPort != 50051 {
+				t.Error("grpc port must not change before restart, received", current.GrpcPort)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// DryRunMetadataKey is request metadata key which, when set to "true",
+	// makes Create and Delete calls validate the request and compute SPDK
+	// parameters without invoking SPDK methods changing its configuration
+	// or changing stored resources
+	DryRunMetadataKey = "opi-dry-run"
+	// DryRunHeaderKey is response header key carrying SPDK calls which would
+	// be issued by a dry run request, one JSON encoded DryRunSpdkCall per
+	// value
+	DryRunHeaderKey = "opi-dry-run-spdk-calls"
+)
+
+// DryRunSpdkCall is SPDK call skipped by a dry run request
+type DryRunSpdkCall struct {
+	Method string      `json:"method"`
+	Params interface{} `json:"params,omitempty"`
+}
+
+// DryRunRequested tells whether client requested dry run in
+// DryRunMetadataKey metadata
+func DryRunRequested(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(DryRunMetadataKey)
+	return len(values) > 0 && values[0] == "true"
+}
+
+// SkipSpdkCallInDryRun reports SPDK method with params in DryRunHeaderKey
+// header when dry run is requested. It returns true in that case, so that
+// caller skips the call and any change of stored resources
+func SkipSpdkCallInDryRun(ctx context.Context, method string, params interface{}) bool {
+	if !DryRunRequested(ctx) {
+		return false
+	}
+	log.Printf("Dry run, skipping SPDK call %v with %+v", method, params)
+	data, err := json.Marshal(DryRunSpdkCall{Method: method, Params: params})
+	if err != nil {
+		log.Printf("error: failed to marshal dry run SPDK call: %v", err)
+		return true
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(DryRunHeaderKey, string(data))); err != nil {
+		log.Printf("error: failed to send dry run SPDK call: %v", err)
+	}
+	return true
+}
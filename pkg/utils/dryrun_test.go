@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestSkipSpdkCallInDryRun(t *testing.T) {
+	tests := map[string]struct {
+		md   metadata.MD
+		skip bool
+	}{
+		"no metadata": {
+			md:   nil,
+			skip: false,
+		},
+		"dry run requested": {
+			md:   metadata.Pairs(DryRunMetadataKey, "true"),
+			skip: true,
+		},
+		"dry run disabled": {
+			md:   metadata.Pairs(DryRunMetadataKey, "false"),
+			skip: false,
+		},
+		"other metadata": {
+			md:   metadata.Pairs(SpdkCallsMetadataKey, "true"),
+			skip: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+
+			if DryRunRequested(ctx) != tt.skip {
+				t.Error("dry run requested: expected", tt.skip, "received", DryRunRequested(ctx))
+			}
+			if skip := SkipSpdkCallInDryRun(ctx, "bdev_malloc_create", map[string]string{"name": "mytest"}); skip != tt.skip {
+				t.Error("skip: expected", tt.skip, "received", skip)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var fileRoot = struct {
+	sync.RWMutex
+	dir string
+}{}
+
+// SetFileRoot restricts all file paths handled by the bridge to dir.
+// Empty dir removes the restriction
+func SetFileRoot(dir string) error {
+	resolved := ""
+	if dir != "" {
+		if !filepath.IsAbs(dir) {
+			return fmt.Errorf("file root %s is not an absolute path", dir)
+		}
+		var err error
+		resolved, err = filepath.EvalSymlinks(dir)
+		if err != nil {
+			return fmt.Errorf("file root %s cannot be evaluated: %v", dir, err)
+		}
+		info, err := os.Stat(resolved)
+		if err != nil {
+			return fmt.Errorf("file root %s cannot be evaluated: %v", dir, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("file root %s is not a directory", dir)
+		}
+	}
+	fileRoot.Lock()
+	defer fileRoot.Unlock()
+	fileRoot.dir = resolved
+	return nil
+}
+
+// FileRoot returns configured file root with symlinks resolved or empty
+// string if file paths are not restricted
+func FileRoot() string {
+	fileRoot.RLock()
+	defer fileRoot.RUnlock()
+	return fileRoot.dir
+}
+
+// ResolveFilePath resolves symlinks in filePath and verifies the result is
+// located within the file root. Relative paths are relative to the file
+// root. The file itself does not need to exist yet. Paths are returned
+// unchanged if no file root is configured
+func ResolveFilePath(filePath string) (string, error) {
+	root := FileRoot()
+	if root == "" {
+		return filePath, nil
+	}
+	absPath := filePath
+	if !filepath.IsAbs(absPath) {
+		absPath = filepath.Join(root, absPath)
+	}
+	resolved, err := evalSymlinksAllowMissing(absPath)
+	if err != nil {
+		msg := fmt.Sprintf("path %s cannot be evaluated: %v", filePath, err)
+		return "", status.Errorf(codes.InvalidArgument, msg)
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		msg := fmt.Sprintf("path %s is outside of file root %s", filePath, root)
+		return "", status.Errorf(codes.InvalidArgument, msg)
+	}
+	return resolved, nil
+}
+
+// evalSymlinksAllowMissing resolves symlinks in the longest existing prefix
+// of filePath and appends the missing elements unchanged
+func evalSymlinksAllowMissing(filePath string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(filePath)
+	if err == nil {
+		return resolved, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	parent := filepath.Dir(filePath)
+	if parent == filePath {
+		return "", err
+	}
+	resolvedParent, err := evalSymlinksAllowMissing(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(resolvedParent, filepath.Base(filePath)), nil
+}
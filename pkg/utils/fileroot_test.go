@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestResolveFilePath(t *testing.T) {
+	tests := map[string]struct {
+		path    string
+		want    string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"existing file in root": {
+			path:    "$ROOT/disk.img",
+			want:    "$ROOT/disk.img",
+			errCode: codes.OK,
+		},
+		"not yet created file in root": {
+			path:    "$ROOT/new/disk.img",
+			want:    "$ROOT/new/disk.img",
+			errCode: codes.OK,
+		},
+		"relative path": {
+			path:    "disk.img",
+			want:    "$ROOT/disk.img",
+			errCode: codes.OK,
+		},
+		"symlink within root": {
+			path:    "$ROOT/inner-link",
+			want:    "$ROOT/disk.img",
+			errCode: codes.OK,
+		},
+		"traversal to parent": {
+			path:    "$ROOT/../outside.img",
+			errCode: codes.InvalidArgument,
+			errMsg:  "path $ROOT/../outside.img is outside of file root $ROOT",
+		},
+		"relative traversal": {
+			path:    "../outside.img",
+			errCode: codes.InvalidArgument,
+			errMsg:  "path ../outside.img is outside of file root $ROOT",
+		},
+		"absolute path outside root": {
+			path:    "/etc/passwd",
+			errCode: codes.InvalidArgument,
+			errMsg:  "path /etc/passwd is outside of file root $ROOT",
+		},
+		"symlink escaping root": {
+			path:    "$ROOT/outer-link/disk.img",
+			errCode: codes.InvalidArgument,
+			errMsg:  "path $ROOT/outer-link/disk.img is outside of file root $ROOT",
+		},
+	}
+
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(base, "root")
+	if err := os.Mkdir(root, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "disk.img"), []byte{}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "disk.img"), filepath.Join(root, "inner-link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(base, filepath.Join(root, "outer-link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFileRoot(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = SetFileRoot("") })
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			expand := func(s string) string { return strings.ReplaceAll(s, "$ROOT", root) }
+			resolved, err := ResolveFilePath(expand(tt.path))
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != expand(tt.errMsg) {
+					t.Error("error message: expected", expand(tt.errMsg), "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if resolved != expand(tt.want) {
+				t.Error("path: expected", expand(tt.want), "received", resolved)
+			}
+		})
+	}
+}
+
+func TestResolveFilePath_NoFileRoot(t *testing.T) {
+	if err := SetFileRoot(""); err != nil {
+		t.Fatal(err)
+	}
+	path := "/some/../path"
+	resolved, err := ResolveFilePath(path)
+	if err != nil {
+		t.Error("expected no error, received", err)
+	}
+	if resolved != path {
+		t.Error("path: expected", path, "received", resolved)
+	}
+}
+
+func TestSetFileRoot(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte{}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = SetFileRoot("") })
+
+	tests := map[string]struct {
+		dir    string
+		errMsg string
+	}{
+		"empty dir": {
+			dir:    "",
+			errMsg: "",
+		},
+		"existing dir": {
+			dir:    dir,
+			errMsg: "",
+		},
+		"relative dir": {
+			dir:    "relative",
+			errMsg: "file root relative is not an absolute path",
+		},
+		"regular file": {
+			dir:    file,
+			errMsg: fmt.Sprintf("file root %s is not a directory", file),
+		},
+	}
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			errMsg := ""
+			if err := SetFileRoot(tt.dir); err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tt.errMsg {
+				t.Error("error: expected", tt.errMsg, "received", errMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/philippgille/gokv"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// DefaultHealthCheckInterval is how often SPDK is probed by default
+	DefaultHealthCheckInterval = 5 * time.Second
+	// DefaultHealthFailureThreshold is number of consecutive failed probes
+	// marking SPDK unhealthy by default
+	DefaultHealthFailureThreshold = 3
+	// DefaultHealthSuccessThreshold is number of consecutive successful
+	// probes marking SPDK healthy again by default
+	DefaultHealthSuccessThreshold = 2
+)
+
+// Names of gRPC health services reporting status of individual components.
+// The overall "" service is SERVING only when all of them are
+const (
+	// SpdkHealthService reports whether SPDK is reachable and answers
+	SpdkHealthService = "spdk"
+	// StoreHealthService reports whether the store is reachable
+	StoreHealthService = "store"
+)
+
+// healthStoreKey is read by store probes, it does not need to exist
+const healthStoreKey = "health/probe"
+
+// healthProbe tracks consecutive results of probing one component
+type healthProbe struct {
+	healthy   bool
+	failures  int
+	successes int
+}
+
+// record accounts probe result and returns whether component health changed
+func (p *healthProbe) record(err error, failureThreshold, successThreshold int) bool {
+	if err != nil {
+		p.successes = 0
+		p.failures++
+		if p.healthy && p.failures >= failureThreshold {
+			p.healthy = false
+			return true
+		}
+		return false
+	}
+	p.failures = 0
+	p.successes++
+	if !p.healthy && p.successes >= successThreshold {
+		p.healthy = true
+		return true
+	}
+	return false
+}
+
+// SpdkHealthChecker probes SPDK and the store and reports the results as
+// serving status of gRPC health service. Status changes only after
+// failureThreshold failed or successThreshold successful probes in a row,
+// so a transient error does not toggle readiness. Health service answers
+// from the last probe results, so probes do not reach SPDK
+type SpdkHealthChecker struct {
+	rpc              spdk.JSONRPC
+	server           *health.Server
+	failureThreshold int
+	successThreshold int
+	spdkAddress      string
+	store            gokv.Store
+
+	mu         sync.Mutex
+	spdkProbe  healthProbe
+	storeProbe healthProbe
+}
+
+// NewSpdkHealthChecker creates SpdkHealthChecker reporting to server. SPDK
+// and the store are considered healthy until the first failureThreshold
+// failed probes. jsonRPC must report connection failures instead of exiting,
+// as NewSpdkClient does, so that SPDK going away is reported as NOT_SERVING
+func NewSpdkHealthChecker(jsonRPC spdk.JSONRPC, server *health.Server, failureThreshold, successThreshold int) (*SpdkHealthChecker, error) {
+	if failureThreshold < 1 {
+		return nil, fmt.Errorf("health failure threshold must be at least 1, got %d", failureThreshold)
+	}
+	if successThreshold < 1 {
+		return nil, fmt.Errorf("health success threshold must be at least 1, got %d", successThreshold)
+	}
+	c := &SpdkHealthChecker{
+		rpc:              jsonRPC,
+		server:           server,
+		failureThreshold: failureThreshold,
+		successThreshold: successThreshold,
+		spdkProbe:        healthProbe{healthy: true},
+		storeProbe:       healthProbe{healthy: true},
+	}
+	for _, service := range []string{"", SpdkHealthService, StoreHealthService} {
+		c.server.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+	}
+	return c, nil
+}
+
+// SetSpdkAddress makes probes check that SPDK socket at address accepts
+// connections before calling SPDK, reporting a missing socket file more
+// clearly than a failed call. Must be called before probing starts
+func (c *SpdkHealthChecker) SetSpdkAddress(address string) {
+	c.spdkAddress = address
+}
+
+// SetStore makes probes check that store is reachable. Must be called
+// before probing starts
+func (c *SpdkHealthChecker) SetStore(store gokv.Store) {
+	c.store = store
+}
+
+// Healthy reports whether SPDK and the store are currently considered healthy
+func (c *SpdkHealthChecker) Healthy() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.spdkProbe.healthy && c.storeProbe.healthy
+}
+
+// Check probes SPDK and the store once and updates serving status if a
+// threshold is reached. It returns whether both are considered healthy
+// afterwards
+func (c *SpdkHealthChecker) Check(ctx context.Context) bool {
+	spdkErr := c.checkSpdk(ctx)
+	storeErr := c.checkStore()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	spdkChanged := c.spdkProbe.record(spdkErr, c.failureThreshold, c.successThreshold)
+	if spdkErr != nil {
+		log.Printf("SPDK health probe failed (%d/%d): %v", c.spdkProbe.failures, c.failureThreshold, spdkErr)
+	}
+	if spdkChanged {
+		c.setServingStatus(SpdkHealthService, c.spdkProbe.healthy)
+	}
+	storeChanged := c.storeProbe.record(storeErr, c.failureThreshold, c.successThreshold)
+	if storeErr != nil {
+		log.Printf("Store health probe failed (%d/%d): %v", c.storeProbe.failures, c.failureThreshold, storeErr)
+	}
+	if storeChanged {
+		c.setServingStatus(StoreHealthService, c.storeProbe.healthy)
+	}
+	healthy := c.spdkProbe.healthy && c.storeProbe.healthy
+	if spdkChanged || storeChanged {
+		c.setServingStatus("", healthy)
+	}
+	return healthy
+}
+
+func (c *SpdkHealthChecker) checkSpdk(ctx context.Context) error {
+	if c.spdkAddress != "" {
+		if err := checkSpdkReachable(c.spdkAddress); err != nil {
+			return err
+		}
+	}
+	var result spdk.GetVersionResult
+	return c.rpc.Call(ctx, "spdk_get_version", nil, &result)
+}
+
+func (c *SpdkHealthChecker) checkStore() error {
+	if c.store == nil {
+		return nil
+	}
+	_, err := c.store.Get(healthStoreKey, &structpb.Struct{})
+	return err
+}
+
+func (c *SpdkHealthChecker) setServingStatus(service string, healthy bool) {
+	servingStatus := healthpb.HealthCheckResponse_SERVING
+	if !healthy {
+		servingStatus = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	log.Printf("Health of %q changed to %v", service, servingStatus)
+	c.server.SetServingStatus(service, servingStatus)
+}
+
+// Run probes SPDK and the store every interval until ctx is done. A probe
+// not answered within interval counts as failed
+func (c *SpdkHealthChecker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			probeCtx, cancel := context.WithTimeout(ctx, interval)
+			c.Check(probeCtx)
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/philippgille/gokv"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestNewSpdkHealthChecker(t *testing.T) {
+	tests := map[string]struct {
+		failureThreshold int
+		successThreshold int
+		errMsg           string
+	}{
+		"valid thresholds": {
+			failureThreshold: 3,
+			successThreshold: 2,
+			errMsg:           "",
+		},
+		"zero failure threshold": {
+			failureThreshold: 0,
+			successThreshold: 2,
+			errMsg:           fmt.Sprintf("health failure threshold must be at least 1, got %d", 0),
+		},
+		"negative success threshold": {
+			failureThreshold: 3,
+			successThreshold: -1,
+			errMsg:           fmt.Sprintf("health success threshold must be at least 1, got %d", -1),
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			_, err := NewSpdkHealthChecker(nil, health.NewServer(), tt.failureThreshold, tt.successThreshold)
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tt.errMsg {
+				t.Error("expected error", tt.errMsg, "received", errMsg)
+			}
+		})
+	}
+}
+
+func TestSpdkHealthChecker_Check(t *testing.T) {
+	const (
+		ok   = `{"id":%d,"error":{"code":0,"message":""},"result":{"version":"SPDK v23.01","fields":{"major":23,"minor":1,"patch":0,"suffix":""}}}`
+		fail = `{"id":%d,"error":{"code":1,"message":"myopierr"},"result":null}`
+	)
+	tests := map[string]struct {
+		spdk    []string
+		healthy []bool
+	}{
+		"single failure does not transition": {
+			spdk:    []string{ok, fail, ok, fail, fail, ok},
+			healthy: []bool{true, true, true, true, true, true},
+		},
+		"sustained failures transition": {
+			spdk:    []string{fail, fail, fail, fail},
+			healthy: []bool{true, true, false, false},
+		},
+		"recovery needs consecutive successes": {
+			spdk:    []string{fail, fail, fail, ok, fail, ok, ok, ok},
+			healthy: []bool{true, true, false, false, false, false, true, true},
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testSocket := GenerateSocketName("utils")
+			ln, testJSONRPC := CreateTestSpdkServer(testSocket, tt.spdk)
+			defer func() {
+				CloseListener(ln)
+				if err := os.RemoveAll(testSocket); err != nil {
+					t.Error(err)
+				}
+			}()
+			healthServer := health.NewServer()
+			checker, err := NewSpdkHealthChecker(testJSONRPC, healthServer, 3, 2)
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+
+			for i, want := range tt.healthy {
+				if healthy := checker.Check(context.Background()); healthy != want {
+					t.Error("probe", i, "healthy: expected", want, "received", healthy)
+				}
+				response, err := healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{})
+				if err != nil {
+					t.Fatal("expected no error, received", err)
+				}
+				wantStatus := healthpb.HealthCheckResponse_SERVING
+				if !want {
+					wantStatus = healthpb.HealthCheckResponse_NOT_SERVING
+				}
+				if response.Status != wantStatus {
+					t.Error("probe", i, "status: expected", wantStatus, "received", response.Status)
+				}
+			}
+			if checker.Healthy() != tt.healthy[len(tt.healthy)-1] {
+				t.Error("expected Healthy to match last probe result")
+			}
+		})
+	}
+}
+
+// downStore emulates store which cannot be reached while down is set
+type downStore struct {
+	gokv.Store
+	down bool
+}
+
+func (s *downStore) Get(_ string, _ interface{}) (bool, error) {
+	if s.down {
+		return false, errors.New("dial tcp 127.0.0.1:6379: connect: connection refused")
+	}
+	return false, nil
+}
+
+func checkServingStatus(t *testing.T, healthServer *health.Server, want map[string]healthpb.HealthCheckResponse_ServingStatus) {
+	for service, wantStatus := range want {
+		response, err := healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatal("expected no error, received", err)
+		}
+		if response.Status != wantStatus {
+			t.Errorf("service %q status: expected %v received %v", service, wantStatus, response.Status)
+		}
+	}
+}
+
+func TestSpdkHealthChecker_SpdkDown(t *testing.T) {
+	testSocket := GenerateSocketName("utils")
+	ln, testJSONRPC := startSleepingSpdkServer(t, testSocket, 0)
+	healthServer := health.NewServer()
+	checker, err := NewSpdkHealthChecker(testJSONRPC, healthServer, 2, 1)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	checker.SetSpdkAddress(testSocket)
+	checker.SetStore(&downStore{})
+
+	if !checker.Check(context.Background()) {
+		t.Error("expected healthy while SPDK is up")
+	}
+
+	// SPDK going away must be reported instead of terminating the process
+	if err := ln.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, false} {
+		if healthy := checker.Check(context.Background()); healthy != want {
+			t.Error("probe", i, "healthy: expected", want, "received", healthy)
+		}
+	}
+	checkServingStatus(t, healthServer, map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"":                 healthpb.HealthCheckResponse_NOT_SERVING,
+		SpdkHealthService:  healthpb.HealthCheckResponse_NOT_SERVING,
+		StoreHealthService: healthpb.HealthCheckResponse_SERVING,
+	})
+
+	// SPDK restarted on the same socket
+	startSleepingSpdkServer(t, testSocket, 0)
+	if !checker.Check(context.Background()) {
+		t.Error("expected healthy once SPDK is back")
+	}
+	checkServingStatus(t, healthServer, map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"":                healthpb.HealthCheckResponse_SERVING,
+		SpdkHealthService: healthpb.HealthCheckResponse_SERVING,
+	})
+}
+
+func TestSpdkHealthChecker_StoreDown(t *testing.T) {
+	testSocket := GenerateSocketName("utils")
+	_, testJSONRPC := startSleepingSpdkServer(t, testSocket, 0)
+	healthServer := health.NewServer()
+	checker, err := NewSpdkHealthChecker(testJSONRPC, healthServer, 1, 1)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	checker.SetSpdkAddress(testSocket)
+	store := &downStore{down: true}
+	checker.SetStore(store)
+
+	if checker.Check(context.Background()) {
+		t.Error("expected unhealthy while store is down")
+	}
+	checkServingStatus(t, healthServer, map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"":                 healthpb.HealthCheckResponse_NOT_SERVING,
+		SpdkHealthService:  healthpb.HealthCheckResponse_SERVING,
+		StoreHealthService: healthpb.HealthCheckResponse_NOT_SERVING,
+	})
+
+	store.down = false
+	if !checker.Check(context.Background()) {
+		t.Error("expected healthy once store is back")
+	}
+	checkServingStatus(t, healthServer, map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"":                 healthpb.HealthCheckResponse_SERVING,
+		StoreHealthService: healthpb.HealthCheckResponse_SERVING,
+	})
+}
+
+func TestSpdkHealthChecker_SpdkNeverStarted(t *testing.T) {
+	// without socket pre-check probes reach SPDK client dialing a missing socket
+	healthServer := health.NewServer()
+	checker, err := NewSpdkHealthChecker(NewSpdkClient(GenerateSocketName("utils")), healthServer, 1, 1)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	if checker.Check(context.Background()) {
+		t.Error("expected unhealthy while SPDK is not running")
+	}
+	checkServingStatus(t, healthServer, map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"":                healthpb.HealthCheckResponse_NOT_SERVING,
+		SpdkHealthService: healthpb.HealthCheckResponse_NOT_SERVING,
+	})
+}
+
+func TestSpdkHealthChecker_RunProbeTimeout(t *testing.T) {
+	testSocket := GenerateSocketName("utils")
+	_, testJSONRPC := startSleepingSpdkServer(t, testSocket, time.Hour)
+	healthServer := health.NewServer()
+	checker, err := NewSpdkHealthChecker(NewTimeoutJSONRPC(testJSONRPC, 0), healthServer, 1, 1)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	go checker.Run(ctx, 50*time.Millisecond)
+	for checker.Healthy() && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if checker.Healthy() {
+		t.Error("expected unanswered probe to fail")
+	}
+}
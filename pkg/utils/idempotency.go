@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/philippgille/gokv"
+	"go.einride.tech/aip/resourceid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// IdempotencyKeyMetadataKey is metadata key carrying client chosen key of a
+// create request. Retried creates with the same key create the resource
+// under the same ID, so they return the resource created by the first one
+// instead of a duplicate, even when created without user specified ID
+const IdempotencyKeyMetadataKey = "opi-idempotency-key"
+
+// IdempotencyKeys records resource IDs created with idempotency keys in the
+// store. Keys are kept after the resource is deleted, so a retry after
+// delete creates the resource again under the same ID
+type IdempotencyKeys struct {
+	store gokv.Store
+	locks *KeyedMutex
+}
+
+// NewIdempotencyKeys creates idempotency keys recorded in store
+func NewIdempotencyKeys(store gokv.Store) *IdempotencyKeys {
+	if store == nil {
+		log.Panic("nil for Store is not allowed")
+	}
+	return &IdempotencyKeys{store: store, locks: NewKeyedMutex()}
+}
+
+func idempotencyKeyFromContext(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(IdempotencyKeyMetadataKey)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func idempotencyStoreKey(kind string, key string) string {
+	return "idempotency/" + kind + "/" + key
+}
+
+// ResourceID returns ID to create resource of kind, e.g. NullVolume, with.
+// Without idempotency key it is requestedID or a generated one. With the
+// key it is ID recorded for the key by a previous create, which conflicts
+// with a different requestedID. Creates racing on the same key get the same
+// ID and are serialized by the resource lock then
+func (k *IdempotencyKeys) ResourceID(ctx context.Context, kind string, requestedID string) (string, error) {
+	key := idempotencyKeyFromContext(ctx)
+	if key == "" {
+		if requestedID == "" {
+			return resourceid.NewSystemGenerated(), nil
+		}
+		return requestedID, nil
+	}
+	storeKey := idempotencyStoreKey(kind, key)
+	unlock := k.locks.Lock(storeKey)
+	defer unlock()
+	value := &structpb.Struct{}
+	found, err := k.store.Get(storeKey, value)
+	if err != nil {
+		msg := fmt.Sprintf("failed to load idempotency key %s: %v", key, err)
+		return "", status.Errorf(codes.Internal, msg)
+	}
+	if found {
+		recordedID := value.Fields["resource_id"].GetStringValue()
+		if requestedID != "" && requestedID != recordedID {
+			msg := fmt.Sprintf("idempotency key %s was used to create %s %s", key, kind, recordedID)
+			return "", status.Errorf(codes.InvalidArgument, msg)
+		}
+		log.Printf("Idempotency key %s of %s resolved to %s", key, kind, recordedID)
+		return recordedID, nil
+	}
+	resourceID := requestedID
+	if resourceID == "" {
+		resourceID = resourceid.NewSystemGenerated()
+	}
+	// dry run must leave no trace, the key is recorded by the real create
+	if DryRunRequested(ctx) {
+		return resourceID, nil
+	}
+	value = &structpb.Struct{Fields: map[string]*structpb.Value{
+		"resource_id": structpb.NewStringValue(resourceID),
+	}}
+	if err := k.store.Set(storeKey, value); err != nil {
+		msg := fmt.Sprintf("failed to store idempotency key %s: %v", key, err)
+		return "", status.Errorf(codes.Internal, msg)
+	}
+	return resourceID, nil
+}
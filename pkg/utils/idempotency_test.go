@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestIdempotencyKeys_ResourceID(t *testing.T) {
+	tests := map[string]struct {
+		key         string
+		dryRun      bool
+		requestedID string
+		recordedID  string
+		expectedID  string
+		errCode     codes.Code
+		errMsg      string
+	}{
+		"no key with requested ID": {
+			requestedID: "myvolume",
+			expectedID:  "myvolume",
+			errCode:     codes.OK,
+		},
+		"first create with key and requested ID": {
+			key:         "retry-1",
+			requestedID: "myvolume",
+			expectedID:  "myvolume",
+			errCode:     codes.OK,
+		},
+		"retried create with key": {
+			key:         "retry-1",
+			recordedID:  "generated",
+			requestedID: "",
+			expectedID:  "generated",
+			errCode:     codes.OK,
+		},
+		"retried create with key and same requested ID": {
+			key:         "retry-1",
+			recordedID:  "myvolume",
+			requestedID: "myvolume",
+			expectedID:  "myvolume",
+			errCode:     codes.OK,
+		},
+		"key used for another ID": {
+			key:         "retry-1",
+			recordedID:  "myvolume",
+			requestedID: "othervolume",
+			expectedID:  "",
+			errCode:     codes.InvalidArgument,
+			errMsg:      "idempotency key retry-1 was used to create NullVolume myvolume",
+		},
+		"dry run with key": {
+			key:         "retry-1",
+			dryRun:      true,
+			requestedID: "myvolume",
+			expectedID:  "myvolume",
+			errCode:     codes.OK,
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			store, err := NewStore(KvBackendMemory, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys := NewIdempotencyKeys(store)
+			if tt.recordedID != "" {
+				recordCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyMetadataKey, tt.key))
+				if _, err := keys.ResourceID(recordCtx, "NullVolume", tt.recordedID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			md := metadata.MD{}
+			if tt.key != "" {
+				md.Set(IdempotencyKeyMetadataKey, tt.key)
+			}
+			if tt.dryRun {
+				md.Set(DryRunMetadataKey, "true")
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+
+			resourceID, err := keys.ResourceID(ctx, "NullVolume", tt.requestedID)
+
+			if resourceID != tt.expectedID {
+				t.Error("resource ID: expected", tt.expectedID, "received", resourceID)
+			}
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			storeKey := idempotencyStoreKey("NullVolume", tt.key)
+			if found, _ := store.Get(storeKey, &structpb.Struct{}); tt.key != "" && found == tt.dryRun {
+				t.Error("key recorded: expected", !tt.dryRun, "received", found)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeys_ResourceIDGenerated(t *testing.T) {
+	store, err := NewStore(KvBackendMemory, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := NewIdempotencyKeys(store)
+
+	first, err := keys.ResourceID(context.Background(), "NullVolume", "")
+	if err != nil || first == "" {
+		t.Fatal("expected generated ID, received", first, err)
+	}
+	second, _ := keys.ResourceID(context.Background(), "NullVolume", "")
+	if second == first {
+		t.Error("expected different IDs without key, received", first, "twice")
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyMetadataKey, "retry-1"))
+	first, _ = keys.ResourceID(ctx, "NullVolume", "")
+	second, _ = keys.ResourceID(ctx, "NullVolume", "")
+	if first == "" || second != first {
+		t.Error("expected the same ID with key, received", first, "and", second)
+	}
+	other, _ := keys.ResourceID(ctx, "MallocVolume", "")
+	if other == first {
+		t.Error("expected keys of different kinds not to collide, received", other)
+	}
+}
+
+func TestNewIdempotencyKeys_NilStore(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic for nil store")
+		}
+	}()
+	NewIdempotencyKeys(nil)
+}
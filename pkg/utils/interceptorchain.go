@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"fmt"
+	"sort"
+
+	"google.golang.org/grpc"
+)
+
+// Names of unary server interceptors which can be enabled in configuration
+const (
+	MetricsInterceptor     = "metrics"
+	LoggingInterceptor     = "logging"
+	SpdkCallsInterceptor   = "spdk_calls"
+	AdminInterceptor       = "admin"
+	TenantQuotaInterceptor = "tenant_quota"
+	ReadMaskInterceptor    = "read_mask"
+	VerbosityInterceptor   = "response_verbosity"
+)
+
+// DefaultInterceptors lists interceptors enabled when configuration does
+// not provide them, in the order they are invoked
+var DefaultInterceptors = []string{MetricsInterceptor, LoggingInterceptor, SpdkCallsInterceptor, AdminInterceptor, TenantQuotaInterceptor, ReadMaskInterceptor, VerbosityInterceptor}
+
+// BuildUnaryInterceptorChain returns interceptors named in order, the first
+// one being the outermost. available maps supported names to interceptors,
+// nil interceptor is supported but not applicable to the current setup
+// (e.g. admin without TLS) and is left out of the chain
+func BuildUnaryInterceptorChain(order []string, available map[string]grpc.UnaryServerInterceptor) ([]grpc.UnaryServerInterceptor, error) {
+	seen := make(map[string]bool, len(order))
+	chain := make([]grpc.UnaryServerInterceptor, 0, len(order))
+	for _, name := range order {
+		interceptor, ok := available[name]
+		if !ok {
+			names := make([]string, 0, len(available))
+			for name := range available {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown interceptor %q, supported are %v", name, names)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("interceptor %q is listed more than once", name)
+		}
+		seen[name] = true
+		if interceptor != nil {
+			chain = append(chain, interceptor)
+		}
+	}
+	return chain, nil
+}
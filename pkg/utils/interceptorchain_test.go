@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+func TestBuildUnaryInterceptorChain(t *testing.T) {
+	tests := map[string]struct {
+		order   []string
+		invoked []string
+		errMsg  string
+	}{
+		"default order": {
+			order:   []string{"first", "second", "third"},
+			invoked: []string{"first", "second", "third"},
+			errMsg:  "",
+		},
+		"custom order": {
+			order:   []string{"third", "first", "second"},
+			invoked: []string{"third", "first", "second"},
+			errMsg:  "",
+		},
+		"disabled interceptor": {
+			order:   []string{"first", "third"},
+			invoked: []string{"first", "third"},
+			errMsg:  "",
+		},
+		"not applicable interceptor": {
+			order:   []string{"first", "inapplicable", "second"},
+			invoked: []string{"first", "second"},
+			errMsg:  "",
+		},
+		"no interceptors": {
+			order:   []string{},
+			invoked: nil,
+			errMsg:  "",
+		},
+		"unknown interceptor": {
+			order:   []string{"first", "fourth"},
+			invoked: nil,
+			errMsg:  `unknown interceptor "fourth", supported are [first inapplicable second third]`,
+		},
+		"duplicated interceptor": {
+			order:   []string{"first", "second", "first"},
+			invoked: nil,
+			errMsg:  `interceptor "first" is listed more than once`,
+		},
+	}
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var invoked []string
+			recording := func(name string) grpc.UnaryServerInterceptor {
+				return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+					invoked = append(invoked, name)
+					return handler(ctx, req)
+				}
+			}
+			available := map[string]grpc.UnaryServerInterceptor{
+				"first":        recording("first"),
+				"second":       recording("second"),
+				"third":        recording("third"),
+				"inapplicable": nil,
+			}
+
+			chain, err := BuildUnaryInterceptorChain(tt.order, available)
+
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tt.errMsg {
+				t.Error("error: expected", tt.errMsg, "received", errMsg)
+			}
+			handler := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+			for _, interceptor := range chain {
+				_, _ = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+			}
+			if !reflect.DeepEqual(invoked, tt.invoked) {
+				t.Error("invoked interceptors: expected", tt.invoked, "received", invoked)
+			}
+		})
+	}
+}
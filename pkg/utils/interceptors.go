@@ -6,27 +6,334 @@ package utils
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
-// InterceptorLogger creates logger for interceptors based on default Go logger
+var logLevel = struct {
+	sync.RWMutex
+	level logging.Level
+}{level: logging.LevelDebug}
+
+// SetLogLevel sets minimal level (debug, info, warn or error) of messages
+// logged by InterceptorLogger
+func SetLogLevel(level string) error {
+	var lvl logging.Level
+	switch strings.ToLower(level) {
+	case "debug":
+		lvl = logging.LevelDebug
+	case "info":
+		lvl = logging.LevelInfo
+	case "warn":
+		lvl = logging.LevelWarn
+	case "error":
+		lvl = logging.LevelError
+	default:
+		return fmt.Errorf("unknown log level %v", level)
+	}
+	logLevel.Lock()
+	defer logLevel.Unlock()
+	logLevel.level = lvl
+	return nil
+}
+
+func logLevelEnabled(lvl logging.Level) bool {
+	logLevel.RLock()
+	defer logLevel.RUnlock()
+	return lvl >= logLevel.level
+}
+
+// Formats of messages logged by InterceptorLogger
+const (
+	// LogFormatText logs messages as plain text
+	LogFormatText = "text"
+	// LogFormatJSON logs each message as a single line JSON object
+	LogFormatJSON = "json"
+)
+
+var logFormat = struct {
+	sync.RWMutex
+	format string
+}{format: LogFormatText}
+
+// SetLogFormat sets format (text or json) of messages logged by
+// InterceptorLogger
+func SetLogFormat(format string) error {
+	switch strings.ToLower(format) {
+	case LogFormatText, LogFormatJSON:
+	default:
+		return fmt.Errorf("unknown log format %v, supported are %v", format, []string{LogFormatText, LogFormatJSON})
+	}
+	logFormat.Lock()
+	defer logFormat.Unlock()
+	logFormat.format = strings.ToLower(format)
+	return nil
+}
+
+func logFormatJSON() bool {
+	logFormat.RLock()
+	defer logFormat.RUnlock()
+	return logFormat.format == LogFormatJSON
+}
+
+// payloadLogged tells whether fields carry request or response content,
+// which can contain keys
+func payloadLogged(fields []any) bool {
+	for i := 0; i < len(fields); i += 2 {
+		if fields[i] == "grpc.request.content" || fields[i] == "grpc.response.content" {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactedPlaceholder replaces values of redacted fields in logged payloads
+const RedactedPlaceholder = "REDACTED"
+
+// RedactedLogFields lists full names of proto fields holding keys, values of
+// which are replaced with RedactedPlaceholder in logged request and response
+// payloads
+var RedactedLogFields = []string{
+	"opi_api.storage.v1.EncryptedVolume.key",
+	"opi_api.storage.v1.NvmeSubsystemSpec.psk",
+	"opi_api.storage.v1.TcpController.psk",
+}
+
+// redactPayload returns copy of message with values of RedactedLogFields
+// replaced, message itself is returned if there is nothing to redact
+func redactPayload(message proto.Message) proto.Message {
+	redacted := make(map[protoreflect.FullName]bool, len(RedactedLogFields))
+	for _, name := range RedactedLogFields {
+		redacted[protoreflect.FullName(name)] = true
+	}
+	if !containsRedacted(message.ProtoReflect(), redacted) {
+		return message
+	}
+	clone := proto.Clone(message)
+	redactMessage(clone.ProtoReflect(), redacted)
+	return clone
+}
+
+func containsRedacted(m protoreflect.Message, redacted map[protoreflect.FullName]bool) bool {
+	found := false
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case redacted[fd.FullName()]:
+			found = true
+		case fd.Message() == nil || fd.IsMap():
+		case fd.IsList():
+			for i := 0; i < v.List().Len() && !found; i++ {
+				found = containsRedacted(v.List().Get(i).Message(), redacted)
+			}
+		default:
+			found = containsRedacted(v.Message(), redacted)
+		}
+		return !found
+	})
+	return found
+}
+
+func redactMessage(m protoreflect.Message, redacted map[protoreflect.FullName]bool) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case redacted[fd.FullName()] && fd.Kind() == protoreflect.BytesKind && !fd.IsList():
+			m.Set(fd, protoreflect.ValueOfBytes([]byte(RedactedPlaceholder)))
+		case redacted[fd.FullName()] && fd.Kind() == protoreflect.StringKind && !fd.IsList():
+			m.Set(fd, protoreflect.ValueOfString(RedactedPlaceholder))
+		case redacted[fd.FullName()]:
+			m.Clear(fd)
+		case fd.Message() == nil || fd.IsMap():
+		case fd.IsList():
+			for i := 0; i < v.List().Len(); i++ {
+				redactMessage(v.List().Get(i).Message(), redacted)
+			}
+		default:
+			redactMessage(v.Message(), redacted)
+		}
+		return true
+	})
+}
+
+var servicePayloads = struct {
+	sync.RWMutex
+	omitted map[string]bool
+	// structPaths maps services to dotted paths of fields redacted in their
+	// struct payloads
+	structPaths map[string][][]string
+}{omitted: map[string]bool{}, structPaths: map[string][][]string{}}
+
+// OmitLoggedPayloads makes InterceptorLogger replace whole request and
+// response content of calls to service with RedactedPlaceholder. It is meant
+// for services carrying keys in payloads RedactedLogFields cannot describe
+func OmitLoggedPayloads(service string) {
+	servicePayloads.Lock()
+	defer servicePayloads.Unlock()
+	servicePayloads.omitted[service] = true
+}
+
+// RedactLoggedStructFields makes InterceptorLogger replace values at dotted
+// paths, e.g. "encrypted_volume.key", in struct payloads of calls to service
+// with RedactedPlaceholder. Lists on the path are redacted item by item. It
+// is meant for hand written services, struct payloads of which
+// RedactedLogFields cannot describe
+func RedactLoggedStructFields(service string, paths ...string) {
+	servicePayloads.Lock()
+	defer servicePayloads.Unlock()
+	for _, path := range paths {
+		servicePayloads.structPaths[service] = append(servicePayloads.structPaths[service], strings.Split(path, "."))
+	}
+}
+
+// servicePayloadRedaction tells whether payloads of the service called in
+// fields are omitted and which struct paths are redacted otherwise
+func servicePayloadRedaction(fields []any) (bool, [][]string) {
+	servicePayloads.RLock()
+	defer servicePayloads.RUnlock()
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == logging.ServiceFieldKey {
+			service, _ := fields[i+1].(string)
+			return servicePayloads.omitted[service], servicePayloads.structPaths[service]
+		}
+	}
+	return false, nil
+}
+
+// redactStruct returns copy of payload with values at paths replaced,
+// payload itself is returned if there are no paths
+func redactStruct(payload *structpb.Struct, paths [][]string) *structpb.Struct {
+	if len(paths) == 0 {
+		return payload
+	}
+	clone := proto.Clone(payload).(*structpb.Struct)
+	for _, path := range paths {
+		redactStructPath(clone, path)
+	}
+	return clone
+}
+
+func redactStructPath(payload *structpb.Struct, path []string) {
+	value, ok := payload.GetFields()[path[0]]
+	switch {
+	case !ok:
+	case len(path) == 1:
+		payload.Fields[path[0]] = structpb.NewStringValue(RedactedPlaceholder)
+	default:
+		redactValuePath(value, path[1:])
+	}
+}
+
+func redactValuePath(value *structpb.Value, path []string) {
+	switch kind := value.GetKind().(type) {
+	case *structpb.Value_StructValue:
+		redactStructPath(kind.StructValue, path)
+	case *structpb.Value_ListValue:
+		for _, item := range kind.ListValue.GetValues() {
+			redactValuePath(item, path)
+		}
+	}
+}
+
+// redactPayloadFields replaces request and response content in fields with
+// their redacted copies
+func redactPayloadFields(fields []any) []any {
+	omitted, structPaths := servicePayloadRedaction(fields)
+	result := make([]any, len(fields))
+	copy(result, fields)
+	for i := 0; i+1 < len(result); i += 2 {
+		if result[i] != "grpc.request.content" && result[i] != "grpc.response.content" {
+			continue
+		}
+		if omitted {
+			result[i+1] = RedactedPlaceholder
+			continue
+		}
+		switch payload := result[i+1].(type) {
+		case *structpb.Struct:
+			result[i+1] = redactStruct(payload, structPaths)
+		case proto.Message:
+			result[i+1] = redactPayload(payload)
+		}
+	}
+	return result
+}
+
+func logLevelName(lvl logging.Level) string {
+	switch lvl {
+	case logging.LevelDebug:
+		return "debug"
+	case logging.LevelInfo:
+		return "info"
+	case logging.LevelWarn:
+		return "warn"
+	case logging.LevelError:
+		return "error"
+	default:
+		panic(fmt.Sprintf("unknown level %v", lvl))
+	}
+}
+
+// jsonLogValue converts logged field value to a value encoded to JSON as is
+func jsonLogValue(value any) any {
+	switch v := value.(type) {
+	case proto.Message:
+		data, err := protojson.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return json.RawMessage(data)
+	case string, bool, int, int32, int64, uint, uint32, uint64, float32, float64:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func logJSON(l *log.Logger, lvl logging.Level, msg string, fields []any) {
+	entry := map[string]any{
+		"time":  time.Now().Format(time.RFC3339Nano),
+		"level": logLevelName(lvl),
+		"msg":   msg,
+	}
+	for i := 0; i+1 < len(fields); i += 2 {
+		entry[fmt.Sprint(fields[i])] = jsonLogValue(fields[i+1])
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		l.Println("error: failed to encode log message:", err, msg)
+		return
+	}
+	// bypass logger prefix and flags to keep the line valid JSON
+	_, _ = fmt.Fprintln(l.Writer(), string(data))
+}
+
+// InterceptorLogger creates logger for interceptors based on default Go
+// logger. Request and response payloads are logged at debug level only,
+// with RedactedLogFields and paths registered by RedactLoggedStructFields
+// replaced, or omitted entirely for services registered by OmitLoggedPayloads
 func InterceptorLogger(l *log.Logger) logging.Logger {
 	return logging.LoggerFunc(func(_ context.Context, lvl logging.Level, msg string, fields ...any) {
-		switch lvl {
-		case logging.LevelDebug:
-			msg = fmt.Sprintf("DEBUG :%v", msg)
-		case logging.LevelInfo:
-			msg = fmt.Sprintf("INFO :%v", msg)
-		case logging.LevelWarn:
-			msg = fmt.Sprintf("WARN :%v", msg)
-		case logging.LevelError:
-			msg = fmt.Sprintf("ERROR :%v", msg)
-		default:
-			panic(fmt.Sprintf("unknown level %v", lvl))
+		if payloadLogged(fields) {
+			lvl = logging.LevelDebug
+			fields = redactPayloadFields(fields)
+		}
+		if !logLevelEnabled(lvl) {
+			return
+		}
+		if logFormatJSON() {
+			logJSON(l, lvl, msg, fields)
+			return
 		}
+		msg = fmt.Sprintf("%v :%v", strings.ToUpper(logLevelName(lvl)), msg)
 		l.Println(append([]any{"msg", msg}, fields...))
 	})
 }
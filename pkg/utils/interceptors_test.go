@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestInterceptorLogger(t *testing.T) {
+	callFields := []any{"grpc.method", "GetNvmeSubsystem", "grpc.code", "OK", "grpc.time_ms", "1.5"}
+	payloadFields := append([]any{"grpc.request.content", wrapperspb.String("secret-key")}, callFields...)
+
+	tests := map[string]struct {
+		level    string
+		format   string
+		lvl      logging.Level
+		fields   []any
+		expected []string
+		logged   bool
+	}{
+		"info logged at info level": {
+			level:    "info",
+			format:   LogFormatText,
+			lvl:      logging.LevelInfo,
+			fields:   callFields,
+			expected: []string{"INFO :finished call", "grpc.method GetNvmeSubsystem"},
+			logged:   true,
+		},
+		"debug filtered at info level": {
+			level:  "info",
+			format: LogFormatText,
+			lvl:    logging.LevelDebug,
+			fields: callFields,
+			logged: false,
+		},
+		"info filtered at error level": {
+			level:  "error",
+			format: LogFormatText,
+			lvl:    logging.LevelInfo,
+			fields: callFields,
+			logged: false,
+		},
+		"payload filtered at info level": {
+			level:  "info",
+			format: LogFormatText,
+			lvl:    logging.LevelInfo,
+			fields: payloadFields,
+			logged: false,
+		},
+		"payload logged at debug level": {
+			level:    "debug",
+			format:   LogFormatText,
+			lvl:      logging.LevelInfo,
+			fields:   payloadFields,
+			expected: []string{"DEBUG :finished call", "secret-key"},
+			logged:   true,
+		},
+		"json": {
+			level:    "info",
+			format:   LogFormatJSON,
+			lvl:      logging.LevelWarn,
+			fields:   callFields,
+			expected: []string{`"level":"warn"`, `"msg":"finished call"`, `"grpc.method":"GetNvmeSubsystem"`, `"grpc.code":"OK"`, `"grpc.time_ms":"1.5"`},
+			logged:   true,
+		},
+		"json payload at debug level": {
+			level:    "debug",
+			format:   LogFormatJSON,
+			lvl:      logging.LevelInfo,
+			fields:   payloadFields,
+			expected: []string{`"level":"debug"`, `"grpc.request.content":"secret-key"`},
+			logged:   true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Cleanup(func() {
+				_ = SetLogLevel("debug")
+				_ = SetLogFormat(LogFormatText)
+			})
+			if err := SetLogLevel(tt.level); err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+			if err := SetLogFormat(tt.format); err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+			var buf bytes.Buffer
+			logger := InterceptorLogger(log.New(&buf, "prefix ", log.LstdFlags))
+
+			logger.Log(context.Background(), tt.lvl, "finished call", tt.fields...)
+
+			output := buf.String()
+			if (output != "") != tt.logged {
+				t.Fatal("logged: expected", tt.logged, "received", output)
+			}
+			for _, part := range tt.expected {
+				if !strings.Contains(output, part) {
+					t.Error("expected output to contain", part, "received", output)
+				}
+			}
+			if tt.logged && tt.format == LogFormatJSON {
+				lines := strings.Split(strings.TrimSpace(output), "\n")
+				if len(lines) != 1 || !json.Valid([]byte(lines[0])) {
+					t.Error("expected single valid JSON line, received", output)
+				}
+			}
+		})
+	}
+}
+
+func TestSetLogFormat(t *testing.T) {
+	t.Cleanup(func() { _ = SetLogFormat(LogFormatText) })
+	if err := SetLogFormat("JSON"); err != nil || !logFormatJSON() {
+		t.Error("expected json format to be set, received", err)
+	}
+	if err := SetLogFormat("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if !logFormatJSON() {
+		t.Error("expected unknown format to keep previous one")
+	}
+}
+
+func TestRedactPayload(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	tests := map[string]struct {
+		in       proto.Message
+		expected proto.Message
+	}{
+		"nested field": {
+			in:       &pb.CreateEncryptedVolumeRequest{EncryptedVolume: &pb.EncryptedVolume{VolumeNameRef: "v", Key: key}},
+			expected: &pb.CreateEncryptedVolumeRequest{EncryptedVolume: &pb.EncryptedVolume{VolumeNameRef: "v", Key: []byte(RedactedPlaceholder)}},
+		},
+		"list items": {
+			in: &pb.ListNvmeSubsystemsResponse{NvmeSubsystems: []*pb.NvmeSubsystem{
+				{Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn1", Psk: key}},
+				{Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn2"}},
+			}},
+			expected: &pb.ListNvmeSubsystemsResponse{NvmeSubsystems: []*pb.NvmeSubsystem{
+				{Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn1", Psk: []byte(RedactedPlaceholder)}},
+				{Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn2"}},
+			}},
+		},
+		"remote controller": {
+			in:       &pb.NvmeRemoteController{Tcp: &pb.TcpController{Psk: key}},
+			expected: &pb.NvmeRemoteController{Tcp: &pb.TcpController{Psk: []byte(RedactedPlaceholder)}},
+		},
+		"nothing to redact": {
+			in:       &pb.EncryptedVolume{VolumeNameRef: "v"},
+			expected: &pb.EncryptedVolume{VolumeNameRef: "v"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			original := proto.Clone(tt.in)
+
+			redacted := redactPayload(tt.in)
+
+			if !proto.Equal(redacted, tt.expected) {
+				t.Error("expected", tt.expected, "received", redacted)
+			}
+			if !proto.Equal(tt.in, original) {
+				t.Error("expected original message not to be changed, received", tt.in)
+			}
+		})
+	}
+}
+
+func TestRedactStruct(t *testing.T) {
+	paths := [][]string{{"encrypted_volume", "key"}, {"volumes", "psk"}}
+	tests := map[string]struct {
+		in       string
+		paths    [][]string
+		expected string
+	}{
+		"nested field": {
+			in:       `{"encrypted_volume":{"volume_name_ref":"v","key":"MDEyMzQ1Njc4OWFiY2RlZg=="}}`,
+			paths:    paths,
+			expected: `{"encrypted_volume":{"volume_name_ref":"v","key":"REDACTED"}}`,
+		},
+		"list items": {
+			in:       `{"volumes":[{"name":"a","psk":"c2VjcmV0"},{"name":"b"}]}`,
+			paths:    paths,
+			expected: `{"volumes":[{"name":"a","psk":"REDACTED"},{"name":"b"}]}`,
+		},
+		"missing path": {
+			in:       `{"encrypted_volume":"not a struct","limits":{}}`,
+			paths:    paths,
+			expected: `{"encrypted_volume":"not a struct","limits":{}}`,
+		},
+		"no paths": {
+			in:       `{"encrypted_volume":{"key":"MDEyMzQ1Njc4OWFiY2RlZg=="}}`,
+			paths:    nil,
+			expected: `{"encrypted_volume":{"key":"MDEyMzQ1Njc4OWFiY2RlZg=="}}`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			in := &structpb.Struct{}
+			if err := in.UnmarshalJSON([]byte(tt.in)); err != nil {
+				t.Fatal(err)
+			}
+			expected := &structpb.Struct{}
+			if err := expected.UnmarshalJSON([]byte(tt.expected)); err != nil {
+				t.Fatal(err)
+			}
+			original := proto.Clone(in)
+
+			redacted := redactStruct(in, tt.paths)
+
+			if !proto.Equal(redacted, expected) {
+				t.Error("expected", expected, "received", redacted)
+			}
+			if !proto.Equal(in, original) {
+				t.Error("expected original struct not to be changed, received", in)
+			}
+		})
+	}
+}
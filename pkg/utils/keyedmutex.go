@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"sync"
+)
+
+// KeyedMutex serializes operations on the same key, e.g. resource name,
+// while operations on different keys proceed in parallel. Locks are created
+// on demand and dropped once no operation holds or waits for them
+type KeyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	// refs counts operations holding or waiting for the lock
+	refs int
+}
+
+// NewKeyedMutex creates KeyedMutex with no locks held
+func NewKeyedMutex() *KeyedMutex {
+	return &KeyedMutex{locks: make(map[string]*keyedLock)}
+}
+
+// Lock blocks until key is not held by another operation and returns
+// function unlocking it
+func (m *KeyedMutex) Lock(key string) func() {
+	m.mu.Lock()
+	lock, ok := m.locks[key]
+	if !ok {
+		lock = &keyedLock{}
+		m.locks[key] = lock
+	}
+	lock.refs++
+	m.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(m.locks, key)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyedMutex_SameKeySerialized(t *testing.T) {
+	m := NewKeyedMutex()
+	unlock := m.Lock("volume-1")
+
+	locked := make(chan struct{})
+	go func() {
+		defer m.Lock("volume-1")()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("expected second lock of the same key to wait")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("expected second lock to be acquired after unlock")
+	}
+}
+
+func TestKeyedMutex_DifferentKeysParallel(t *testing.T) {
+	m := NewKeyedMutex()
+	unlock := m.Lock("volume-1")
+	defer unlock()
+
+	locked := make(chan struct{})
+	go func() {
+		defer m.Lock("volume-2")()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("expected lock of a different key not to wait")
+	}
+}
+
+func TestKeyedMutex_UnusedLocksDropped(t *testing.T) {
+	m := NewKeyedMutex()
+	m.Lock("volume-1")()
+	unlock := m.Lock("volume-2")
+
+	m.mu.Lock()
+	held := len(m.locks)
+	m.mu.Unlock()
+	if held != 1 {
+		t.Error("expected 1 held lock, received", held)
+	}
+	unlock()
+	if len(m.locks) != 0 {
+		t.Error("expected no locks kept after unlock, received", len(m.locks))
+	}
+}
@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/philippgille/gokv"
+	"github.com/philippgille/gokv/gomap"
+	"github.com/philippgille/gokv/redis"
+)
+
+// Backends of the store keeping annotations, expiry and other data SPDK
+// cannot hold
+const (
+	// KvBackendRedis keeps data in Redis, surviving bridge restarts
+	KvBackendRedis = "redis"
+	// KvBackendMemory keeps data in bridge memory, so no Redis is needed but
+	// data is lost on restart
+	KvBackendMemory = "memory"
+)
+
+// NewStore creates store of kvBackend kind. redisAddress is used only by
+// KvBackendRedis
+func NewStore(kvBackend, redisAddress string) (gokv.Store, error) {
+	switch kvBackend {
+	case KvBackendRedis:
+		options := redis.DefaultOptions
+		options.Address = redisAddress
+		options.Codec = ProtoCodec{}
+		return redis.NewClient(options)
+	case KvBackendMemory:
+		log.Println("Using in-memory store, its data is lost on restart")
+		options := gomap.DefaultOptions
+		options.Codec = ProtoCodec{}
+		return gomap.NewStore(options), nil
+	default:
+		return nil, fmt.Errorf("unknown kv backend %q, supported are %v",
+			kvBackend, []string{KvBackendMemory, KvBackendRedis})
+	}
+}
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestNewStore(t *testing.T) {
+	tests := map[string]struct {
+		kvBackend string
+		errMsg    string
+	}{
+		"memory": {
+			kvBackend: KvBackendMemory,
+			errMsg:    "",
+		},
+		"unknown": {
+			kvBackend: "etcd",
+			errMsg:    fmt.Sprintf("unknown kv backend %q, supported are %v", "etcd", []string{"memory", "redis"}),
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			store, err := NewStore(tt.kvBackend, "")
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tt.errMsg {
+				t.Fatal("expected error", tt.errMsg, "received", errMsg)
+			}
+			if err != nil {
+				return
+			}
+			defer func() {
+				if err := store.Close(); err != nil {
+					t.Error(err)
+				}
+			}()
+
+			if err := store.Set("annotations/volume-1", structpb.NewStringValue("team-a")); err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+			value := &structpb.Value{}
+			if found, err := store.Get("annotations/volume-1", value); !found || err != nil || value.GetStringValue() != "team-a" {
+				t.Error("expected stored value, received", found, err, value)
+			}
+		})
+	}
+}
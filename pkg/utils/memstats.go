@@ -0,0 +1,288 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// DefaultMemoryStatsCacheTTL is how long memory stats are served from cache
+// by default, since SPDK dumps them into a file on every call
+const DefaultMemoryStatsCacheTTL = 5 * time.Second
+
+// SpdkMemoryServiceName is full name of the service reporting SPDK memory
+// usage. It is not part of OPI API, so it is registered with a hand written
+// service descriptor
+const SpdkMemoryServiceName = "opi_spdk_bridge.v1.SpdkMemoryService"
+
+// SpdkHeapStats contains DPDK malloc heap usage, backed by hugepages
+type SpdkHeapStats struct {
+	ID               int    `json:"id"`
+	Name             string `json:"name"`
+	SizeBytes        uint64 `json:"size_bytes"`
+	FreeBytes        uint64 `json:"free_bytes"`
+	AllocBytes       uint64 `json:"alloc_bytes"`
+	GreatestFreeSize uint64 `json:"greatest_free_bytes"`
+	AllocCount       uint64 `json:"alloc_count"`
+	FreeCount        uint64 `json:"free_count"`
+}
+
+// SpdkMempoolStats contains DPDK memory pool usage
+type SpdkMempoolStats struct {
+	Name      string `json:"name"`
+	Size      uint64 `json:"size"`
+	Available uint64 `json:"available"`
+	InUse     uint64 `json:"in_use"`
+}
+
+// SpdkMemoryStats contains SPDK hugepage and memory pool utilization
+type SpdkMemoryStats struct {
+	TotalBytes  uint64             `json:"total_bytes"`
+	HeapBytes   uint64             `json:"heap_bytes"`
+	UsedBytes   uint64             `json:"used_bytes"`
+	UsedPercent float64            `json:"used_percent"`
+	Heaps       []SpdkHeapStats    `json:"heaps"`
+	Mempools    []SpdkMempoolStats `json:"mempools"`
+}
+
+// envDpdkGetMemStatsResult is result of env_dpdk_get_mem_stats
+// TODO: use spdk.EnvDpdkGetMemStatsResult when gospdk provides it
+type envDpdkGetMemStatsResult struct {
+	Filename string `json:"filename"`
+}
+
+// MemoryStatsServer reports SPDK memory usage, caching it for a short time
+type MemoryStatsServer struct {
+	rpc      spdk.JSONRPC
+	cacheTTL time.Duration
+
+	mu       sync.Mutex
+	cached   *SpdkMemoryStats
+	cachedAt time.Time
+}
+
+// NewMemoryStatsServer creates memory stats server communicating with
+// provided jsonRPC and caching stats for cacheTTL
+func NewMemoryStatsServer(jsonRPC spdk.JSONRPC, cacheTTL time.Duration) *MemoryStatsServer {
+	if jsonRPC == nil {
+		log.Panic("nil for JSONRPC is not allowed")
+	}
+	if cacheTTL < 0 {
+		log.Panicf("memory stats cache ttl cannot be negative, got %v", cacheTTL)
+	}
+	return &MemoryStatsServer{
+		rpc:      jsonRPC,
+		cacheTTL: cacheTTL,
+	}
+}
+
+// MemoryStats returns current SPDK memory usage. SPDK writes the stats into
+// a file, so the bridge has to share file system with SPDK
+func (s *MemoryStatsServer) MemoryStats(ctx context.Context) (*SpdkMemoryStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cached != nil && time.Since(s.cachedAt) < s.cacheTTL {
+		return s.cached, nil
+	}
+	var result envDpdkGetMemStatsResult
+	err := s.rpc.Call(ctx, "env_dpdk_get_mem_stats", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	if result.Filename == "" {
+		msg := "Could not get SPDK memory stats"
+		return nil, status.Errorf(codes.Internal, msg)
+	}
+	data, err := os.ReadFile(result.Filename)
+	if err != nil {
+		msg := fmt.Sprintf("Could not read SPDK memory stats from %s: %v", result.Filename, err)
+		return nil, status.Errorf(codes.Unavailable, msg)
+	}
+	stats, err := parseSpdkMemoryStats(data)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	s.cached = stats
+	s.cachedAt = time.Now()
+	return stats, nil
+}
+
+// GetSpdkMemoryStats returns SpdkMemoryStats as a struct
+func (s *MemoryStatsServer) GetSpdkMemoryStats(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	stats, err := s.MemoryStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	response := &structpb.Struct{}
+	if err := response.UnmarshalJSON(data); err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	return response, nil
+}
+
+// memoryStatsServiceServer is implemented by MemoryStatsServer
+type memoryStatsServiceServer interface {
+	GetSpdkMemoryStats(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+}
+
+var memoryStatsServiceDesc = grpc.ServiceDesc{
+	ServiceName: SpdkMemoryServiceName,
+	HandlerType: (*memoryStatsServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetSpdkMemoryStats",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(emptypb.Empty)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(memoryStatsServiceServer).GetSpdkMemoryStats(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: "/" + SpdkMemoryServiceName + "/GetSpdkMemoryStats",
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(memoryStatsServiceServer).GetSpdkMemoryStats(ctx, req.(*emptypb.Empty))
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterMemoryStatsServer registers memory stats service on s
+func RegisterMemoryStatsServer(s *grpc.Server, srv *MemoryStatsServer) {
+	s.RegisterService(&memoryStatsServiceDesc, srv)
+}
+
+// parseSpdkMemoryStats parses file written by env_dpdk_get_mem_stats,
+// containing DPDK physical memory size, malloc heaps and mempools dumps
+func parseSpdkMemoryStats(data []byte) (*SpdkMemoryStats, error) {
+	stats := &SpdkMemoryStats{Heaps: []SpdkHeapStats{}, Mempools: []SpdkMempoolStats{}}
+	var heap *SpdkHeapStats
+	var mempool *SpdkMempoolStats
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		var err error
+		switch {
+		case strings.HasPrefix(line, "DPDK memory size "):
+			stats.TotalBytes, err = parseMemStatsUint(strings.TrimPrefix(line, "DPDK memory size "))
+		case strings.HasPrefix(line, "Heap id:"):
+			var id uint64
+			id, err = parseMemStatsUint(strings.TrimPrefix(line, "Heap id:"))
+			stats.Heaps = append(stats.Heaps, SpdkHeapStats{ID: int(id)})
+			heap, mempool = &stats.Heaps[len(stats.Heaps)-1], nil
+		case strings.HasPrefix(line, "mempool <"):
+			name := strings.TrimPrefix(line, "mempool <")
+			if end := strings.Index(name, ">"); end >= 0 {
+				name = name[:end]
+			}
+			stats.Mempools = append(stats.Mempools, SpdkMempoolStats{Name: name})
+			heap, mempool = nil, &stats.Mempools[len(stats.Mempools)-1]
+		case heap != nil:
+			err = parseHeapStatsLine(heap, line)
+		case mempool != nil:
+			err = parseMempoolStatsLine(mempool, line)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SPDK memory stats line %q: %v", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for i := range stats.Heaps {
+		stats.HeapBytes += stats.Heaps[i].SizeBytes
+		stats.UsedBytes += stats.Heaps[i].AllocBytes
+	}
+	for i := range stats.Mempools {
+		if stats.Mempools[i].Size > stats.Mempools[i].Available {
+			stats.Mempools[i].InUse = stats.Mempools[i].Size - stats.Mempools[i].Available
+		}
+	}
+	if stats.TotalBytes == 0 {
+		stats.TotalBytes = stats.HeapBytes
+	}
+	if stats.TotalBytes != 0 {
+		stats.UsedPercent = float64(stats.UsedBytes) * 100 / float64(stats.TotalBytes)
+	}
+	return stats, nil
+}
+
+func parseHeapStatsLine(heap *SpdkHeapStats, line string) error {
+	key, value, found := strings.Cut(line, ":")
+	if !found {
+		return nil
+	}
+	var field *uint64
+	switch key {
+	case "Heap name":
+		heap.Name = value
+		return nil
+	case "Heap_size":
+		field = &heap.SizeBytes
+	case "Free_size":
+		field = &heap.FreeBytes
+	case "Alloc_size":
+		field = &heap.AllocBytes
+	case "Greatest_free_size":
+		field = &heap.GreatestFreeSize
+	case "Alloc_count":
+		field = &heap.AllocCount
+	case "Free_count":
+		field = &heap.FreeCount
+	default:
+		return nil
+	}
+	var err error
+	*field, err = parseMemStatsUint(value)
+	return err
+}
+
+func parseMempoolStatsLine(mempool *SpdkMempoolStats, line string) error {
+	key, value, found := strings.Cut(line, "=")
+	if !found {
+		return nil
+	}
+	var err error
+	switch key {
+	case "size":
+		mempool.Size, err = parseMemStatsUint(value)
+	case "common_pool_count":
+		mempool.Available, err = parseMemStatsUint(value)
+	}
+	return err
+}
+
+func parseMemStatsUint(value string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), ","), 10, 64)
+}
@@ -0,0 +1,240 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testSpdkMemDump = `DPDK memory size 2147483648
+DPDK memory layout
+Segment 0: IOVA:0x200000000, len:2147483648, virt:0x200000000, socket_id:0, hugepage_sz:2097152, nchannel:0, nrank:0
+Zone 0: name:<rte_eth_dev_data>, len:0x34700, virt:0x2000003d3200, socket_id:0, flags:0
+Heap id:0
+	Heap name:socket_0
+	Heap_size:2147483648,
+	Free_size:1610612736,
+	Alloc_size:536870912,
+	Greatest_free_size:1073741824,
+	Alloc_count:172,
+	Free_count:3,
+Heap id:1
+	Heap name:
+	Heap_size:0,
+	Free_size:0,
+	Alloc_size:0,
+	Greatest_free_size:0,
+	Alloc_count:0,
+	Free_count:0,
+mempool <bdev_io_12345>@0x200000d08e00
+  flags=10
+  socket_id=0
+  pool=0x200000b01280
+  iova=0x200000d08e00
+  nb_mem_chunks=1
+  size=65535
+  populated_size=65535
+  header_size=64
+  elt_size=248
+  trailer_size=0
+  total_obj_size=312
+  private_data_size=0
+  ops_index=0
+  ops_name: <ring_mp_mc>
+  avg bytes/object=312.004761
+  internal cache infos:
+    cache_size=256
+    cache_count[0]=0
+    total_cache_count=0
+  common_pool_count=60000
+  no statistics available
+mempool <msgpool_12345>@0x200000e9a100
+  flags=10
+  size=262143
+  common_pool_count=262143
+`
+
+// memStatsJSONRPC answers env_dpdk_get_mem_stats with filename and counts
+// the calls
+type memStatsJSONRPC struct {
+	spdk.JSONRPC
+	filename string
+	mu       sync.Mutex
+	calls    int
+}
+
+func (r *memStatsJSONRPC) Call(_ context.Context, method string, _, result interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if method != "env_dpdk_get_mem_stats" {
+		panic("unexpected method " + method)
+	}
+	r.calls++
+	result.(*envDpdkGetMemStatsResult).Filename = r.filename
+	return nil
+}
+
+func writeTestSpdkMemDump(t *testing.T) string {
+	filename := filepath.Join(t.TempDir(), "spdk_mem_dump.txt")
+	if err := os.WriteFile(filename, []byte(testSpdkMemDump), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestMemoryStatsServer_MemoryStats(t *testing.T) {
+	rpc := &memStatsJSONRPC{filename: writeTestSpdkMemDump(t)}
+	server := NewMemoryStatsServer(rpc, time.Hour)
+
+	stats, err := server.MemoryStats(context.Background())
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	want := &SpdkMemoryStats{
+		TotalBytes:  2147483648,
+		HeapBytes:   2147483648,
+		UsedBytes:   536870912,
+		UsedPercent: 25,
+		Heaps: []SpdkHeapStats{
+			{
+				ID:               0,
+				Name:             "socket_0",
+				SizeBytes:        2147483648,
+				FreeBytes:        1610612736,
+				AllocBytes:       536870912,
+				GreatestFreeSize: 1073741824,
+				AllocCount:       172,
+				FreeCount:        3,
+			},
+			{ID: 1},
+		},
+		Mempools: []SpdkMempoolStats{
+			{Name: "bdev_io_12345", Size: 65535, Available: 60000, InUse: 5535},
+			{Name: "msgpool_12345", Size: 262143, Available: 262143, InUse: 0},
+		},
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("stats: expected %+v, received %+v", want, stats)
+	}
+}
+
+func TestMemoryStatsServer_Cache(t *testing.T) {
+	tests := map[string]struct {
+		cacheTTL  time.Duration
+		wantCalls int
+	}{
+		"served from cache": {
+			cacheTTL:  time.Hour,
+			wantCalls: 1,
+		},
+		"cache disabled": {
+			cacheTTL:  0,
+			wantCalls: 2,
+		},
+	}
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			rpc := &memStatsJSONRPC{filename: writeTestSpdkMemDump(t)}
+			server := NewMemoryStatsServer(rpc, tt.cacheTTL)
+
+			for i := 0; i < 2; i++ {
+				if _, err := server.MemoryStats(context.Background()); err != nil {
+					t.Fatal("expected no error, received", err)
+				}
+			}
+
+			if rpc.calls != tt.wantCalls {
+				t.Error("SPDK calls: expected", tt.wantCalls, "received", rpc.calls)
+			}
+		})
+	}
+}
+
+func TestMemoryStatsServer_Errors(t *testing.T) {
+	tests := map[string]struct {
+		filename string
+		content  string
+		errCode  codes.Code
+	}{
+		"no filename returned": {
+			filename: "",
+			errCode:  codes.Internal,
+		},
+		"missing file": {
+			filename: "/nonexistent/spdk_mem_dump.txt",
+			errCode:  codes.Unavailable,
+		},
+		"malformed dump": {
+			filename: "spdk_mem_dump.txt",
+			content:  "Heap id:0\n\tHeap_size:many,\n",
+			errCode:  codes.Internal,
+		},
+	}
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			filename := tt.filename
+			if tt.content != "" {
+				filename = filepath.Join(t.TempDir(), tt.filename)
+				if err := os.WriteFile(filename, []byte(tt.content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			server := NewMemoryStatsServer(&memStatsJSONRPC{filename: filename}, time.Hour)
+
+			_, err := server.MemoryStats(context.Background())
+
+			if code := status.Code(err); code != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", code, err)
+			}
+		})
+	}
+}
+
+func TestMemoryStatsServer_GetSpdkMemoryStats(t *testing.T) {
+	rpc := &memStatsJSONRPC{filename: writeTestSpdkMemDump(t)}
+	server := NewMemoryStatsServer(rpc, time.Hour)
+
+	response, err := server.GetSpdkMemoryStats(context.Background(), nil)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	fields := response.GetFields()
+	if used := fields["used_percent"].GetNumberValue(); used != 25 {
+		t.Error("used_percent: expected", 25, "received", used)
+	}
+	if heaps := fields["heaps"].GetListValue().GetValues(); len(heaps) != 2 {
+		t.Error("heaps: expected", 2, "received", len(heaps))
+	}
+	if mempools := fields["mempools"].GetListValue().GetValues(); len(mempools) != 2 {
+		t.Error("mempools: expected", 2, "received", len(mempools))
+	}
+}
+
+func TestRegisterMemoryStatsServer(t *testing.T) {
+	s := grpc.NewServer()
+	RegisterMemoryStatsServer(s, NewMemoryStatsServer(&memStatsJSONRPC{}, time.Hour))
+
+	info, ok := s.GetServiceInfo()[SpdkMemoryServiceName]
+	if !ok {
+		t.Fatal("expected", SpdkMemoryServiceName, "to be registered")
+	}
+	if len(info.Methods) != 1 || info.Methods[0].Name != "GetSpdkMemoryStats" {
+		t.Error("methods: expected [GetSpdkMemoryStats], received", info.Methods)
+	}
+}
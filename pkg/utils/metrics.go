@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultMetricsPath is HTTP path Prometheus metrics are served on by default
+const DefaultMetricsPath = "/metrics"
+
+const metricsNamespace = "opi_spdk_bridge"
+
+// Metrics collects Prometheus metrics of gRPC requests and SPDK calls
+type Metrics struct {
+	registry   *prometheus.Registry
+	requests   *prometheus.CounterVec
+	latency    *prometheus.HistogramVec
+	spdkErrors *prometheus.CounterVec
+}
+
+// NewMetrics creates metrics registered in their own registry, so that
+// multiple instances do not collide
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "grpc_requests_total",
+			Help:      "Number of gRPC requests by method and status code.",
+		}, []string{"method", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "grpc_request_duration_seconds",
+			Help:      "Latency of gRPC requests by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		spdkErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "spdk_errors_total",
+			Help:      "Number of failed SPDK JSON-RPC calls by SPDK method.",
+		}, []string{"method"}),
+	}
+	m.registry.MustRegister(m.requests, m.latency, m.spdkErrors)
+	return m
+}
+
+// RegisterReapedVolumes exports number of volumes deleted because their
+// ttl expired, read from reaped on every scrape
+func (m *Metrics) RegisterReapedVolumes(reaped func() uint64) {
+	m.registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reaped_volumes_total",
+		Help:      "Number of volumes deleted because their ttl expired.",
+	}, func() float64 {
+		return float64(reaped())
+	}))
+}
+
+// Handler returns HTTP handler serving metrics in Prometheus format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// UnaryServerInterceptor counts gRPC requests and observes their latency
+func (m *Metrics) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	m.latency.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+	m.requests.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+	return resp, err
+}
+
+type metricsJSONRPC struct {
+	spdk.JSONRPC
+	metrics *Metrics
+}
+
+// NewMetricsJSONRPC wraps jsonRPC so that every failed SPDK call is counted
+// in metrics
+func NewMetricsJSONRPC(jsonRPC spdk.JSONRPC, metrics *Metrics) spdk.JSONRPC {
+	if jsonRPC == nil {
+		log.Panic("nil for JSONRPC is not allowed")
+	}
+	if metrics == nil {
+		log.Panic("nil for Metrics is not allowed")
+	}
+	return &metricsJSONRPC{JSONRPC: jsonRPC, metrics: metrics}
+}
+
+func (c *metricsJSONRPC) GetVersion(ctx context.Context) string {
+	version := c.JSONRPC.GetVersion(ctx)
+	if version == "" {
+		c.metrics.spdkErrors.WithLabelValues("spdk_get_version").Inc()
+	}
+	return version
+}
+
+func (c *metricsJSONRPC) Call(ctx context.Context, method string, args, result interface{}) error {
+	err := c.JSONRPC.Call(ctx, method, args, result)
+	if err != nil {
+		c.metrics.spdkErrors.WithLabelValues(method).Inc()
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opiproject/gospdk/spdk"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	info := &grpc.UnaryServerInfo{FullMethod: "/opi_api.storage.v1.NullVolumeService/GetNullVolume"}
+	handlers := []grpc.UnaryHandler{
+		func(ctx context.Context, req interface{}) (interface{}, error) { return req, nil },
+		func(ctx context.Context, req interface{}) (interface{}, error) { return req, nil },
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, status.Error(codes.NotFound, "unable to find key")
+		},
+	}
+	for _, handler := range handlers {
+		_, _ = metrics.UnaryServerInterceptor(context.Background(), "req", info, handler)
+	}
+	jsonRPC := NewMetricsJSONRPC(&stubBatchJSONRPC{
+		results: map[string]string{"bdev_get_bdevs": "[]"},
+		errs:    map[string]error{"bdev_null_delete": errors.New("bdev_null_delete: json response error: No such device")},
+		calls:   map[string]int{},
+	}, metrics)
+	var result interface{}
+	_ = jsonRPC.Call(context.Background(), "bdev_get_bdevs", nil, &result)
+	_ = jsonRPC.Call(context.Background(), "bdev_null_delete", nil, &result)
+	metrics.RegisterReapedVolumes(func() uint64 { return 3 })
+
+	server := httptest.NewServer(metrics.Handler())
+	defer server.Close()
+	resp, err := http.Get(server.URL + DefaultMetricsPath)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	expected := []string{
+		"# TYPE opi_spdk_bridge_grpc_requests_total counter",
+		`opi_spdk_bridge_grpc_requests_total{code="OK",method="/opi_api.storage.v1.NullVolumeService/GetNullVolume"} 2`,
+		`opi_spdk_bridge_grpc_requests_total{code="NotFound",method="/opi_api.storage.v1.NullVolumeService/GetNullVolume"} 1`,
+		"# TYPE opi_spdk_bridge_grpc_request_duration_seconds histogram",
+		`opi_spdk_bridge_grpc_request_duration_seconds_count{method="/opi_api.storage.v1.NullVolumeService/GetNullVolume"} 3`,
+		"# TYPE opi_spdk_bridge_spdk_errors_total counter",
+		`opi_spdk_bridge_spdk_errors_total{method="bdev_null_delete"} 1`,
+		"# TYPE opi_spdk_bridge_reaped_volumes_total counter",
+		"opi_spdk_bridge_reaped_volumes_total 3",
+	}
+	for _, line := range expected {
+		if !strings.Contains(string(body), line) {
+			t.Error("expected metrics to contain", line, "received", string(body))
+		}
+	}
+	if strings.Contains(string(body), `method="bdev_get_bdevs"`) {
+		t.Error("expected successful SPDK calls not to be counted as errors")
+	}
+}
+
+func TestNewMetricsJSONRPC_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		jsonRPC spdk.JSONRPC
+		metrics *Metrics
+	}{
+		"nil JSONRPC": {jsonRPC: nil, metrics: NewMetrics()},
+		"nil Metrics": {jsonRPC: &stubBatchJSONRPC{}, metrics: nil},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("expected panic")
+				}
+			}()
+			NewMetricsJSONRPC(tt.jsonRPC, tt.metrics)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc"
+)
+
+// DefaultGrpcMaxMsgSize is default gRPC limit of received message size
+const DefaultGrpcMaxMsgSize = 4 << 20
+
+func validateGrpcMaxMsgSizes(recvSize, sendSize int) error {
+	if recvSize <= 0 {
+		return fmt.Errorf("gRPC max receive message size must be positive, got %d", recvSize)
+	}
+	if sendSize <= 0 {
+		return fmt.Errorf("gRPC max send message size must be positive, got %d", sendSize)
+	}
+	return nil
+}
+
+// GrpcMaxMsgSizeServerOptions returns gRPC server options limiting size of
+// messages the server receives and sends
+func GrpcMaxMsgSizeServerOptions(recvSize, sendSize int) ([]grpc.ServerOption, error) {
+	if err := validateGrpcMaxMsgSizes(recvSize, sendSize); err != nil {
+		return nil, err
+	}
+	return []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(recvSize),
+		grpc.MaxSendMsgSize(sendSize),
+	}, nil
+}
+
+// GrpcMaxMsgSizeDialOptions returns options for clients, such as HTTP
+// gateway, of server created with GrpcMaxMsgSizeServerOptions, so that
+// clients accept as large responses as the server sends and send as large
+// requests as the server receives
+func GrpcMaxMsgSizeDialOptions(recvSize, sendSize int) ([]grpc.DialOption, error) {
+	if err := validateGrpcMaxMsgSizes(recvSize, sendSize); err != nil {
+		return nil, err
+	}
+	return []grpc.DialOption{
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(sendSize),
+			grpc.MaxCallSendMsgSize(recvSize),
+		),
+	}, nil
+}
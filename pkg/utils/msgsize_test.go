@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"testing"
+)
+
+func TestGrpcMaxMsgSizeOptions(t *testing.T) {
+	tests := map[string]struct {
+		recvSize int
+		sendSize int
+		wantErr  string
+	}{
+		"default sizes": {
+			recvSize: DefaultGrpcMaxMsgSize,
+			sendSize: DefaultGrpcMaxMsgSize,
+			wantErr:  "",
+		},
+		"zero receive size": {
+			recvSize: 0,
+			sendSize: DefaultGrpcMaxMsgSize,
+			wantErr:  "gRPC max receive message size must be positive, got 0",
+		},
+		"negative send size": {
+			recvSize: DefaultGrpcMaxMsgSize,
+			sendSize: -1,
+			wantErr:  "gRPC max send message size must be positive, got -1",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			serverOptions, serverErr := GrpcMaxMsgSizeServerOptions(tt.recvSize, tt.sendSize)
+			dialOptions, dialErr := GrpcMaxMsgSizeDialOptions(tt.recvSize, tt.sendSize)
+
+			for _, err := range []error{serverErr, dialErr} {
+				gotErr := ""
+				if err != nil {
+					gotErr = err.Error()
+				}
+				if gotErr != tt.wantErr {
+					t.Errorf("expected error %q, received %q", tt.wantErr, gotErr)
+				}
+			}
+			if tt.wantErr == "" && (len(serverOptions) != 2 || len(dialOptions) != 1) {
+				t.Error("expected options, received", serverOptions, dialOptions)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync/atomic"
+
+	"go.einride.tech/aip/resourcename"
+)
+
+// NameStrategy builds resource names out of resource IDs and parses the IDs
+// back. It is used for all resource names, so it has to round-trip
+type NameStrategy interface {
+	// Join builds name of a resource out of alternating collection and
+	// resource ID segments, from the outermost parent to the resource
+	Join(elems ...string) string
+	// ResourceID returns ID of the resource name refers to
+	ResourceID(name string) string
+	// ParentID returns ID of the resource of collection in name or empty
+	// string if name has no such parent
+	ParentID(name string, collection string) string
+}
+
+// DefaultNameStrategy joins collections and IDs by slash, e.g.
+// nvmeSubsystems/{subsystem}/nvmeNamespaces/{namespace}
+type DefaultNameStrategy struct{}
+
+// Join builds name of a resource
+func (DefaultNameStrategy) Join(elems ...string) string {
+	return resourcename.Join(elems...)
+}
+
+// ResourceID returns ID of the resource name refers to
+func (DefaultNameStrategy) ResourceID(name string) string {
+	return path.Base(name)
+}
+
+// ParentID returns ID of the resource of collection in name
+func (DefaultNameStrategy) ParentID(name string, collection string) string {
+	segments := strings.Split(name, "/")
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i] == collection {
+			return segments[i+1]
+		}
+	}
+	return ""
+}
+
+// PrefixNameStrategy prepends a fixed prefix, e.g. clusters/{cluster}, to
+// names built by DefaultNameStrategy
+type PrefixNameStrategy struct {
+	prefix string
+}
+
+// NewPrefixNameStrategy creates strategy prepending prefix to names
+func NewPrefixNameStrategy(prefix string) *PrefixNameStrategy {
+	return &PrefixNameStrategy{prefix: strings.Trim(prefix, "/")}
+}
+
+// Join builds name of a resource
+func (s *PrefixNameStrategy) Join(elems ...string) string {
+	return s.prefix + "/" + DefaultNameStrategy{}.Join(elems...)
+}
+
+// ResourceID returns ID of the resource name refers to
+func (s *PrefixNameStrategy) ResourceID(name string) string {
+	return DefaultNameStrategy{}.ResourceID(name)
+}
+
+// ParentID returns ID of the resource of collection in name
+func (s *PrefixNameStrategy) ParentID(name string, collection string) string {
+	return DefaultNameStrategy{}.ParentID(strings.TrimPrefix(name, s.prefix+"/"), collection)
+}
+
+// nameStrategyBox keeps concrete type stored in atomic.Value constant
+type nameStrategyBox struct {
+	NameStrategy
+}
+
+var nameStrategy = func() *atomic.Value {
+	strategy := &atomic.Value{}
+	strategy.Store(nameStrategyBox{DefaultNameStrategy{}})
+	return strategy
+}()
+
+func currentNameStrategy() NameStrategy {
+	return nameStrategy.Load().(nameStrategyBox).NameStrategy
+}
+
+// nameRoundTrips lists resource names, as collection and ID segments, a
+// strategy is checked with
+var nameRoundTrips = [][]string{
+	{"volumes", "volume-id"},
+	{"nvmeSubsystems", "subsystem-id"},
+	{"nvmeSubsystems", "subsystem-id", "nvmeNamespaces", "namespace-id"},
+	{"nvmeSubsystems", "subsystem-id", "nvmeControllers", "controller-id"},
+	{"nvmeRemoteControllers", "controller-id"},
+	{"nvmeRemoteControllers", "controller-id", "nvmePaths", "path-id"},
+}
+
+// ValidateNameStrategy checks that names built by strategy are valid
+// resource names and that resource and parent IDs parsed out of them build
+// the same names again
+func ValidateNameStrategy(strategy NameStrategy) error {
+	for _, elems := range nameRoundTrips {
+		name := strategy.Join(elems...)
+		if err := resourcename.Validate(name); err != nil {
+			return fmt.Errorf("invalid name %q: %v", name, err)
+		}
+		parsed := make([]string, len(elems))
+		for i := 0; i < len(elems); i += 2 {
+			parsed[i] = elems[i]
+			if i+2 == len(elems) {
+				parsed[i+1] = strategy.ResourceID(name)
+			} else {
+				parsed[i+1] = strategy.ParentID(name, elems[i])
+			}
+		}
+		if again := strategy.Join(parsed...); again != name {
+			return fmt.Errorf("name %q does not round-trip, IDs %v build %q", name, parsed, again)
+		}
+	}
+	return nil
+}
+
+// SetNameStrategy sets strategy used to build and parse all resource names
+// after validating it round-trips
+func SetNameStrategy(strategy NameStrategy) error {
+	if strategy == nil {
+		return fmt.Errorf("nil name strategy is not allowed")
+	}
+	if err := ValidateNameStrategy(strategy); err != nil {
+		return err
+	}
+	nameStrategy.Store(nameStrategyBox{strategy})
+	return nil
+}
+
+// ResourceNameToID returns ID of the resource name refers to
+func ResourceNameToID(name string) string {
+	return currentNameStrategy().ResourceID(name)
+}
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// hashNameStrategy replaces resource IDs by their hash, so IDs can not be
+// parsed back out of names
+type hashNameStrategy struct {
+	DefaultNameStrategy
+}
+
+func (hashNameStrategy) Join(elems ...string) string {
+	hashed := make([]string, len(elems))
+	for i, elem := range elems {
+		hashed[i] = elem
+		if i%2 == 1 {
+			hashed[i] = fmt.Sprintf("h%x", sha256.Sum256([]byte(elem)))[:17]
+		}
+	}
+	return DefaultNameStrategy{}.Join(hashed...)
+}
+
+func TestNameStrategy(t *testing.T) {
+	tests := map[string]struct {
+		strategy   NameStrategy
+		volume     string
+		namespace  string
+		subsysID   string
+		remoteID   string
+		pathName   string
+		strategyOk bool
+	}{
+		"default strategy": {
+			strategy:   DefaultNameStrategy{},
+			volume:     "volumes/volume-id",
+			namespace:  "nvmeSubsystems/subsystem-id/nvmeNamespaces/namespace-id",
+			subsysID:   "subsystem-id",
+			remoteID:   "controller-id",
+			pathName:   "nvmeRemoteControllers/controller-id/nvmePaths/path-id",
+			strategyOk: true,
+		},
+		"prefix strategy": {
+			strategy:   NewPrefixNameStrategy("clusters/cluster-a/"),
+			volume:     "clusters/cluster-a/volumes/volume-id",
+			namespace:  "clusters/cluster-a/nvmeSubsystems/subsystem-id/nvmeNamespaces/namespace-id",
+			subsysID:   "subsystem-id",
+			remoteID:   "controller-id",
+			pathName:   "clusters/cluster-a/nvmeRemoteControllers/controller-id/nvmePaths/path-id",
+			strategyOk: true,
+		},
+		"prefix strategy with invalid segment": {
+			strategy:   NewPrefixNameStrategy("clusters/-cluster-"),
+			strategyOk: false,
+		},
+		"hashing strategy": {
+			strategy:   hashNameStrategy{},
+			strategyOk: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Cleanup(func() {
+				if err := SetNameStrategy(DefaultNameStrategy{}); err != nil {
+					t.Fatal("expected no error restoring default strategy, received", err)
+				}
+			})
+
+			err := SetNameStrategy(tt.strategy)
+			if (err == nil) != tt.strategyOk {
+				t.Fatal("expected strategy accepted", tt.strategyOk, "received", err)
+			}
+			if !tt.strategyOk {
+				if ResourceIDToVolumeName("volume-id") != "volumes/volume-id" {
+					t.Error("expected rejected strategy not to be used")
+				}
+				return
+			}
+
+			if name := ResourceIDToVolumeName("volume-id"); name != tt.volume {
+				t.Error("volume: expected", tt.volume, "received", name)
+			}
+			namespace := ResourceIDToNamespaceName("subsystem-id", "namespace-id")
+			if namespace != tt.namespace {
+				t.Error("namespace: expected", tt.namespace, "received", namespace)
+			}
+			if id := GetSubsystemIDFromNvmeName(namespace); id != tt.subsysID {
+				t.Error("subsystem id: expected", tt.subsysID, "received", id)
+			}
+			if id := ResourceNameToID(namespace); id != "namespace-id" {
+				t.Error("namespace id: expected", "namespace-id", "received", id)
+			}
+			pathName := ResourceIDToNvmePathName("controller-id", "path-id")
+			if pathName != tt.pathName {
+				t.Error("path: expected", tt.pathName, "received", pathName)
+			}
+			if id := GetRemoteControllerIDFromNvmeRemoteName(pathName); id != tt.remoteID {
+				t.Error("remote controller id: expected", tt.remoteID, "received", id)
+			}
+			if id := GetSubsystemIDFromNvmeName(ResourceIDToVolumeName("volume-id")); id != "" {
+				t.Error("expected no subsystem id in volume name, received", id)
+			}
+		})
+	}
+}
+
+func TestValidateNameStrategyError(t *testing.T) {
+	err := ValidateNameStrategy(hashNameStrategy{})
+	if err == nil || !strings.Contains(err.Error(), "does not round-trip") {
+		t.Error("expected round-trip error, received", err)
+	}
+	if err := SetNameStrategy(nil); err == nil {
+		t.Error("expected error for nil strategy")
+	}
+}
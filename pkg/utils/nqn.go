@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"fmt"
+	"regexp"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MaxNqnLength is the longest NQN allowed by Nvme base specification
+const MaxNqnLength = 223
+
+var nqnPattern = regexp.MustCompile(`^nqn\.[0-9]{4}-[0-9]{2}(\.[a-zA-Z0-9]+)+(:[a-zA-Z0-9-.]+)+$`)
+
+// ValidateNqn checks nqn is not too long and has nqn.yyyy-mm.reverse.domain:name
+// format
+func ValidateNqn(nqn string) error {
+	if len(nqn) > MaxNqnLength {
+		msg := fmt.Sprintf("Nqn value (%s) is too long, have to be between 1 and %d", nqn, MaxNqnLength)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if !nqnPattern.MatchString(nqn) {
+		msg := fmt.Sprintf("NQN value (%s) does not match pattern", nqn)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
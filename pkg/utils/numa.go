@@ -0,0 +1,21 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"log"
+	"path/filepath"
+)
+
+// NumaNodeCount returns number of NUMA nodes present on the host,
+// hosts without NUMA information are treated as single node
+func NumaNodeCount() int {
+	nodes, err := filepath.Glob("/sys/devices/system/node/node[0-9]*")
+	if err != nil || len(nodes) == 0 {
+		log.Printf("No NUMA nodes found (%v), assuming single node", err)
+		return 1
+	}
+	return len(nodes)
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultPaginationTokenTTL is default time a pagination token stays valid
+// after it was issued
+const DefaultPaginationTokenTTL = 10 * time.Minute
+
+var paginationTokenTTL = func() *atomic.Int64 {
+	ttl := &atomic.Int64{}
+	ttl.Store(int64(DefaultPaginationTokenTTL))
+	return ttl
+}()
+
+// SetPaginationTokenTTL sets time pagination tokens stay valid after they
+// were issued
+func SetPaginationTokenTTL(ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("pagination token ttl must be positive, got %v", ttl)
+	}
+	paginationTokenTTL.Store(int64(ttl))
+	return nil
+}
+
+type paginationEntry struct {
+	offset  int
+	expires time.Time
+}
+
+// Pagination stores offsets of the next List pages by opaque page tokens.
+// Tokens expire after pagination token ttl, so that iterations abandoned by
+// clients are not kept forever
+type Pagination struct {
+	mu      sync.Mutex
+	entries map[string]paginationEntry
+	now     func() time.Time
+}
+
+// NewPagination creates an empty Pagination
+func NewPagination() *Pagination {
+	return &Pagination{
+		entries: make(map[string]paginationEntry),
+		now:     time.Now,
+	}
+}
+
+// Set stores offset of the next page under token and evicts expired tokens
+func (p *Pagination) Set(token string, offset int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	for t, entry := range p.entries {
+		if !now.Before(entry.expires) {
+			delete(p.entries, t)
+		}
+	}
+	p.entries[token] = paginationEntry{
+		offset:  offset,
+		expires: now.Add(time.Duration(paginationTokenTTL.Load())),
+	}
+}
+
+// Get returns offset stored under token, expired token is evicted and
+// reported as not found
+func (p *Pagination) Get(token string) (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.entries[token]
+	if !ok {
+		return 0, false
+	}
+	if !p.now().Before(entry.expires) {
+		delete(p.entries, token)
+		return 0, false
+	}
+	return entry.offset, true
+}
+
+// Len returns number of stored tokens including expired ones not evicted yet
+func (p *Pagination) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
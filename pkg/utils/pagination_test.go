@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSetPaginationTokenTTL(t *testing.T) {
+	t.Cleanup(func() { _ = SetPaginationTokenTTL(DefaultPaginationTokenTTL) })
+	tests := map[string]struct {
+		ttl    time.Duration
+		errMsg string
+	}{
+		"positive ttl": {
+			ttl:    time.Minute,
+			errMsg: "",
+		},
+		"zero ttl": {
+			ttl:    0,
+			errMsg: "pagination token ttl must be positive, got 0s",
+		},
+		"negative ttl": {
+			ttl:    -time.Second,
+			errMsg: "pagination token ttl must be positive, got -1s",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := SetPaginationTokenTTL(tt.ttl)
+
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tt.errMsg {
+				t.Error("expected error", tt.errMsg, "received", errMsg)
+			}
+		})
+	}
+}
+
+func TestPaginationTokenExpiry(t *testing.T) {
+	t.Cleanup(func() { _ = SetPaginationTokenTTL(DefaultPaginationTokenTTL) })
+	if err := SetPaginationTokenTTL(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	pagination := NewPagination()
+	pagination.now = func() time.Time { return now }
+
+	pagination.Set("token", 5)
+	now = now.Add(time.Minute - time.Second)
+	if offset, ok := pagination.Get("token"); !ok || offset != 5 {
+		t.Error("expected offset 5 before ttl, received", offset, ok)
+	}
+	size, offset, err := ExtractPagination(10, "token", pagination)
+	if err != nil || size != 10 || offset != 5 {
+		t.Error("expected size 10 and offset 5 before ttl, received", size, offset, err)
+	}
+
+	now = now.Add(time.Second)
+	_, _, err = ExtractPagination(10, "token", pagination)
+	if status.Code(err) != codes.NotFound {
+		t.Error("expected", codes.NotFound, "for expired token, received", err)
+	}
+	if pagination.Len() != 0 {
+		t.Error("expected expired token to be evicted, received", pagination.Len(), "tokens")
+	}
+}
+
+func TestPaginationSweepsExpiredTokens(t *testing.T) {
+	t.Cleanup(func() { _ = SetPaginationTokenTTL(DefaultPaginationTokenTTL) })
+	if err := SetPaginationTokenTTL(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	pagination := NewPagination()
+	pagination.now = func() time.Time { return now }
+
+	pagination.Set("abandoned-1", 1)
+	pagination.Set("abandoned-2", 2)
+	now = now.Add(time.Minute)
+	pagination.Set("fresh", 3)
+
+	if pagination.Len() != 1 {
+		t.Error("expected abandoned tokens to be evicted, received", pagination.Len(), "tokens")
+	}
+	if offset, ok := pagination.Get("fresh"); !ok || offset != 3 {
+		t.Error("expected offset 3 of fresh token, received", offset, ok)
+	}
+}
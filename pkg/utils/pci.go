@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	pc "github.com/opiproject/opi-api/inventory/v1/gen/go"
+)
+
+// SysfsPCIDevicesDir is directory Linux exposes PCI devices in
+const SysfsPCIDevicesDir = "/sys/bus/pci/devices"
+
+// massStorageClassPrefix is PCI base class of mass storage controllers
+const massStorageClassPrefix = "0x01"
+
+// StoragePCIeDevices lists mass storage controllers (NVMe, SATA, SAS, ...)
+// found in sysfs PCI devices dir
+func StoragePCIeDevices(dir string) ([]*pc.PCIeDeviceInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	devices := []*pc.PCIeDeviceInfo{}
+	for _, entry := range entries {
+		deviceDir := filepath.Join(dir, entry.Name())
+		if !strings.HasPrefix(readSysfsAttribute(deviceDir, "class"), massStorageClassPrefix) {
+			continue
+		}
+		device := &pc.PCIeDeviceInfo{
+			Address:  entry.Name(),
+			Vendor:   readSysfsAttribute(deviceDir, "vendor"),
+			Product:  readSysfsAttribute(deviceDir, "device"),
+			Revision: readSysfsAttribute(deviceDir, "revision"),
+		}
+		// driver is a symlink to the bound driver, missing if none is bound
+		if driver, err := os.Readlink(filepath.Join(deviceDir, "driver")); err == nil {
+			device.Driver = filepath.Base(driver)
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+func readSysfsAttribute(deviceDir, attribute string) string {
+	data, err := os.ReadFile(filepath.Join(deviceDir, attribute))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
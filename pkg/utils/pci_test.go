@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	pc "github.com/opiproject/opi-api/inventory/v1/gen/go"
+)
+
+func TestStoragePCIeDevices(t *testing.T) {
+	dir := t.TempDir()
+	driversDir := filepath.Join(dir, "drivers")
+	devicesDir := filepath.Join(dir, "devices")
+	devices := map[string]map[string]string{
+		"0000:5e:00.0": {"class": "0x010802\n", "vendor": "0x8086\n", "device": "0x0a54\n", "revision": "0x00\n", "driver": "vfio-pci"},
+		"0000:00:17.0": {"class": "0x010601\n", "vendor": "0x8086\n", "device": "0xa352\n", "revision": "0x10\n", "driver": "ahci"},
+		"0000:af:00.0": {"class": "0x010802\n", "vendor": "0x144d\n", "device": "0xa808\n", "revision": "0x00\n"},
+		"0000:18:00.0": {"class": "0x020000\n", "vendor": "0x8086\n", "device": "0x1572\n", "revision": "0x02\n", "driver": "i40e"},
+	}
+	for address, attributes := range devices {
+		deviceDir := filepath.Join(devicesDir, address)
+		if err := os.MkdirAll(deviceDir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		for attribute, value := range attributes {
+			if attribute == "driver" {
+				if err := os.MkdirAll(filepath.Join(driversDir, value), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Symlink(filepath.Join(driversDir, value), filepath.Join(deviceDir, "driver")); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			if err := os.WriteFile(filepath.Join(deviceDir, attribute), []byte(value), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	result, err := StoragePCIeDevices(devicesDir)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	expected := []*pc.PCIeDeviceInfo{
+		{Driver: "ahci", Address: "0000:00:17.0", Vendor: "0x8086", Product: "0xa352", Revision: "0x10"},
+		{Driver: "vfio-pci", Address: "0000:5e:00.0", Vendor: "0x8086", Product: "0x0a54", Revision: "0x00"},
+		{Driver: "", Address: "0000:af:00.0", Vendor: "0x144d", Product: "0xa808", Revision: "0x00"},
+	}
+	if len(result) != len(expected) {
+		t.Fatal("devices: expected", expected, "received", result)
+	}
+	for i := range expected {
+		if !proto.Equal(result[i], expected[i]) {
+			t.Error("device: expected", expected[i], "received", result[i])
+		}
+	}
+}
+
+func TestStoragePCIeDevices_MissingDir(t *testing.T) {
+	if _, err := StoragePCIeDevices(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for missing dir")
+	}
+}
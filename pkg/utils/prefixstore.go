@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"log"
+
+	"github.com/philippgille/gokv"
+)
+
+// StorePrefixSeparator separates store prefix from keys
+const StorePrefixSeparator = ":"
+
+type prefixedStore struct {
+	gokv.Store
+	prefix string
+}
+
+// NewPrefixedStore wraps store so that all keys are namespaced by prefix,
+// letting multiple bridges share one store without key collisions. Empty
+// prefix leaves keys as they are
+func NewPrefixedStore(store gokv.Store, prefix string) gokv.Store {
+	if store == nil {
+		log.Panic("nil for store is not allowed")
+	}
+	if prefix == "" {
+		return store
+	}
+	return &prefixedStore{Store: store, prefix: prefix + StorePrefixSeparator}
+}
+
+func (s *prefixedStore) Set(k string, v interface{}) error {
+	return s.Store.Set(s.prefix+k, v)
+}
+
+func (s *prefixedStore) Get(k string, v interface{}) (bool, error) {
+	return s.Store.Get(s.prefix+k, v)
+}
+
+func (s *prefixedStore) Delete(k string) error {
+	return s.Store.Delete(s.prefix + k)
+}
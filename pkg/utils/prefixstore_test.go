@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"testing"
+
+	"github.com/philippgille/gokv/gomap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestNewPrefixedStore(t *testing.T) {
+	options := gomap.DefaultOptions
+	options.Codec = ProtoCodec{}
+	shared := gomap.NewStore(options)
+	first := NewPrefixedStore(shared, "bridge-1")
+	second := NewPrefixedStore(shared, "bridge-2")
+
+	if err := first.Set("annotations/volume-1", structpb.NewStringValue("first")); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if found, err := second.Get("annotations/volume-1", &structpb.Value{}); found || err != nil {
+		t.Error("expected key of another prefix not to be found, received", found, err)
+	}
+	value := &structpb.Value{}
+	if found, err := shared.Get("bridge-1:annotations/volume-1", value); !found || err != nil || value.GetStringValue() != "first" {
+		t.Error("expected key stored under prefix, received", found, err, value)
+	}
+
+	if err := second.Delete("annotations/volume-1"); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if found, err := first.Get("annotations/volume-1", &structpb.Value{}); !found || err != nil {
+		t.Error("expected delete under another prefix to keep key, received", found, err)
+	}
+	if err := first.Delete("annotations/volume-1"); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if found, err := shared.Get("bridge-1:annotations/volume-1", &structpb.Value{}); found || err != nil {
+		t.Error("expected key deleted, received", found, err)
+	}
+
+	if err := NewPrefixedStore(shared, "").Set("annotations/volume-2", structpb.NewStringValue("none")); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if found, err := shared.Get("annotations/volume-2", &structpb.Value{}); !found || err != nil {
+		t.Error("expected key without prefix stored as is, received", found, err)
+	}
+}
@@ -5,8 +5,11 @@
 package utils
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -14,14 +17,27 @@ import (
 
 const keyPermissions = 0600
 
-// KeyToTemporaryFile writes pskKey into a tmp file located in tmpDir with
-// required file permissions to be consumed by SPDK
+// keyFilePrefix is prefix of names of tmp files psk keys are written to
+const keyFilePrefix = "opikey"
+
+// KeyFileDir returns directory psk key files are written to: file root, or
+// /var/tmp if not configured
+func KeyFileDir() string {
+	dir := FileRoot()
+	if dir == "" {
+		dir = "/var/tmp"
+	}
+	return dir
+}
+
+// KeyToTemporaryFile writes pskKey into a tmp file located in KeyFileDir
+// with required file permissions to be consumed by SPDK
 func KeyToTemporaryFile(pskKey []byte) (string, error) {
 	if len(pskKey) == 0 {
 		return "", status.Error(codes.FailedPrecondition, "empty psk key")
 	}
 
-	keyFile, err := os.CreateTemp("/var/tmp", "opikey")
+	keyFile, err := os.CreateTemp(KeyFileDir(), keyFilePrefix)
 	if err != nil {
 		return "", status.Error(codes.Internal, "failed to create tmp file for key")
 	}
@@ -34,3 +50,38 @@ func KeyToTemporaryFile(pskKey []byte) (string, error) {
 
 	return keyFile.Name(), nil
 }
+
+// RemoveStaleKeyFiles removes psk key files left in dir by previous runs,
+// e.g. after a crash in the middle of an operation. dir has to be
+// KeyFileDir, so unrelated files are never deleted. Key files of other
+// bridges sharing dir are removed too. Returns number of removed files
+func RemoveStaleKeyFiles(dir string) (int, error) {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return 0, err
+	}
+	keyFileDir, err := filepath.EvalSymlinks(KeyFileDir())
+	if err != nil {
+		return 0, err
+	}
+	if resolved != keyFileDir {
+		return 0, fmt.Errorf("refusing to remove key files from %v, key files are written to %v", dir, KeyFileDir())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range entries {
+		// os.CreateTemp appends random digits to the prefix
+		suffix := strings.TrimPrefix(entry.Name(), keyFilePrefix)
+		if suffix == entry.Name() || suffix == "" || strings.Trim(suffix, "0123456789") != "" || !entry.Type().IsRegular() {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -47,3 +47,59 @@ func TestKeyToTemporaryFile(t *testing.T) {
 		})
 	}
 }
+
+func TestRemoveStaleKeyFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := SetFileRoot(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := SetFileRoot(""); err != nil {
+			t.Error(err)
+		}
+	})
+	stale := []string{"opikey123456", "opikey42"}
+	kept := []string{"opikey", "opikey-backup", "other123", "volume.img"}
+	for _, name := range append(stale, kept...) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("key"), keyPermissions); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "opikey7"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	kept = append(kept, "opikey7")
+
+	removed, err := RemoveStaleKeyFiles(dir)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if removed != len(stale) {
+		t.Error("removed: expected", len(stale), "received", removed)
+	}
+	for _, name := range stale {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Error("expected", name, "to be removed, received", err)
+		}
+	}
+	for _, name := range kept {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error("expected", name, "to be kept, received", err)
+		}
+	}
+}
+
+func TestRemoveStaleKeyFiles_OtherDir(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "opikey123")
+	if err := os.WriteFile(keyFile, []byte("key"), keyPermissions); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := RemoveStaleKeyFiles(dir); err == nil {
+		t.Error("expected error for dir other than key files dir")
+	}
+	if _, err := os.Stat(keyFile); err != nil {
+		t.Error("expected", keyFile, "to be kept, received", err)
+	}
+}
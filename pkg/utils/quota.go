@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TenantMetadataKey is request metadata key identifying tenant the created
+// resources are accounted to. Requests without it are not limited
+const TenantMetadataKey = "opi-tenant"
+
+// ParseTenantQuotas parses quotas in kind=limit format separated by `,`,
+// e.g. "NvmeSubsystem=10,NvmeController=20". Kind is resource name of
+// Create and Delete methods, e.g. NvmeSubsystem for CreateNvmeSubsystem
+func ParseTenantQuotas(str string) (map[string]int, error) {
+	quotas := make(map[string]int)
+	if str == "" {
+		return quotas, nil
+	}
+	for _, entry := range strings.Split(str, ",") {
+		kind, value, found := strings.Cut(entry, "=")
+		if !found || kind == "" {
+			return nil, fmt.Errorf("invalid tenant quota %q, expected kind=limit", entry)
+		}
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tenant quota %q limit: %v", entry, err)
+		}
+		quotas[kind] = limit
+	}
+	return quotas, nil
+}
+
+// ResourceReleaser releases accounting of resources servers delete on their
+// own rather than on Delete requests, e.g. children of cascade deleted
+// subsystem, volumes deleted by TTL reaper or transaction rollback, which
+// bypass interceptors
+type ResourceReleaser interface {
+	Release(name string)
+}
+
+type tenantKind struct {
+	tenant string
+	kind   string
+}
+
+// TenantQuotas limits number of resources each tenant can create
+type TenantQuotas struct {
+	limits map[string]int
+
+	mu     sync.Mutex
+	counts map[tenantKind]int
+	// owners maps created resource names to tenant and kind they are
+	// accounted to
+	owners map[string]tenantKind
+}
+
+// NewTenantQuotas creates quotas limiting number of resources of each kind
+// in limits a tenant can create
+func NewTenantQuotas(limits map[string]int) (*TenantQuotas, error) {
+	q := &TenantQuotas{
+		limits: make(map[string]int, len(limits)),
+		counts: make(map[tenantKind]int),
+		owners: make(map[string]tenantKind),
+	}
+	for kind, limit := range limits {
+		if limit < 0 {
+			return nil, fmt.Errorf("tenant quota of %v cannot be negative, got %d", kind, limit)
+		}
+		q.limits[kind] = limit
+	}
+	return q, nil
+}
+
+// UnaryServerInterceptor rejects Create calls of a tenant which already
+// created resources up to its quota with ResourceExhausted, including
+// repeated creates of an existing resource, as its name is known only after
+// the call. Successful Delete calls release quota of the tenant which
+// created the resource
+func (q *TenantQuotas) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	method := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]
+	switch {
+	case strings.HasPrefix(method, "Create"):
+		return q.Create(ctx, strings.TrimPrefix(method, "Create"), req, handler)
+	case strings.HasPrefix(method, "Delete"):
+		if _, limited := q.limits[strings.TrimPrefix(method, "Delete")]; !limited {
+			return handler(ctx, req)
+		}
+		resp, err := handler(ctx, req)
+		if named, ok := req.(interface{ GetName() string }); ok && err == nil {
+			q.Release(named.GetName())
+		}
+		return resp, err
+	default:
+		return handler(ctx, req)
+	}
+}
+
+// Create accounts resource of kind created by handler to tenant of ctx the
+// same way as Create calls, so that servers creating resources on their
+// own, e.g. operations of a transaction, do not bypass quotas
+func (q *TenantQuotas) Create(ctx context.Context, kind string, req interface{}, handler grpc.UnaryHandler) (interface{}, error) {
+	if _, limited := q.limits[kind]; !limited {
+		return handler(ctx, req)
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(TenantMetadataKey)
+	if len(values) == 0 || values[0] == "" {
+		return handler(ctx, req)
+	}
+	return q.create(ctx, req, tenantKind{tenant: values[0], kind: kind}, handler)
+}
+
+func (q *TenantQuotas) create(ctx context.Context, req interface{}, key tenantKind, handler grpc.UnaryHandler) (interface{}, error) {
+	// reserve quota before the call, so that concurrent creates cannot
+	// exceed it
+	q.mu.Lock()
+	if count, limit := q.counts[key], q.limits[key.kind]; count >= limit {
+		q.mu.Unlock()
+		msg := fmt.Sprintf("tenant %v reached quota of %d %v", key.tenant, limit, key.kind)
+		return nil, status.Errorf(codes.ResourceExhausted, msg)
+	}
+	if DryRunRequested(ctx) {
+		// dry run creates nothing, so it neither reserves quota nor records
+		// the owner, but still reports exhausted quota
+		q.mu.Unlock()
+		return handler(ctx, req)
+	}
+	q.counts[key]++
+	q.mu.Unlock()
+
+	resp, err := handler(ctx, req)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	named, ok := resp.(interface{ GetName() string })
+	if err != nil || !ok {
+		q.counts[key]--
+		return resp, err
+	}
+	if _, exists := q.owners[named.GetName()]; exists {
+		// idempotent create returned already accounted resource
+		q.counts[key]--
+		return resp, err
+	}
+	q.owners[named.GetName()] = key
+	log.Printf("Tenant %v uses %d of %d %v", key.tenant, q.counts[key], q.limits[key.kind], key.kind)
+	return resp, err
+}
+
+// Release releases quota of tenant which created resource name, names not
+// accounted to any tenant are ignored
+func (q *TenantQuotas) Release(name string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	key, ok := q.owners[name]
+	if !ok {
+		return
+	}
+	delete(q.owners, name)
+	q.counts[key]--
+}
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseTenantQuotas(t *testing.T) {
+	tests := map[string]struct {
+		str       string
+		quotas    map[string]int
+		expectErr bool
+	}{
+		"empty": {
+			str:       "",
+			quotas:    map[string]int{},
+			expectErr: false,
+		},
+		"multiple kinds": {
+			str:       "NvmeSubsystem=10,NvmeController=20",
+			quotas:    map[string]int{"NvmeSubsystem": 10, "NvmeController": 20},
+			expectErr: false,
+		},
+		"missing limit": {
+			str:       "NvmeSubsystem",
+			quotas:    nil,
+			expectErr: true,
+		},
+		"missing kind": {
+			str:       "=10",
+			quotas:    nil,
+			expectErr: true,
+		},
+		"not a number limit": {
+			str:       "NvmeSubsystem=ten",
+			quotas:    nil,
+			expectErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			quotas, err := ParseTenantQuotas(tt.str)
+
+			if (err != nil) != tt.expectErr {
+				t.Error("Expect error", tt.expectErr, "received", err)
+			}
+			if !reflect.DeepEqual(quotas, tt.quotas) {
+				t.Error("quotas: expected", tt.quotas, "received", quotas)
+			}
+		})
+	}
+}
+
+func TestNewTenantQuotas_NegativeLimit(t *testing.T) {
+	if _, err := NewTenantQuotas(map[string]int{"NvmeSubsystem": -1}); err == nil {
+		t.Error("expected error for negative limit")
+	}
+}
+
+func TestTenantQuotas_UnaryServerInterceptor(t *testing.T) {
+	quotas, err := NewTenantQuotas(map[string]int{"NvmeSubsystem": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const service = "/opi_api.storage.v1.FrontendNvmeService/"
+	subsystems := map[string]bool{}
+	call := func(tenant, method string, req interface{}) error {
+		ctx := context.Background()
+		if tenant != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(TenantMetadataKey, tenant))
+		}
+		handler := func(_ context.Context, req interface{}) (interface{}, error) {
+			switch r := req.(type) {
+			case *pb.CreateNvmeSubsystemRequest:
+				if r.NvmeSubsystemId == "failing" {
+					return nil, status.Error(codes.InvalidArgument, "invalid subsystem")
+				}
+				subsystems[r.NvmeSubsystemId] = true
+				return &pb.NvmeSubsystem{Name: r.NvmeSubsystemId}, nil
+			case *pb.DeleteNvmeSubsystemRequest:
+				delete(subsystems, r.Name)
+			}
+			return req, nil
+		}
+		_, err := quotas.UnaryServerInterceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: service + method}, handler)
+		return err
+	}
+	create := func(tenant, id string) error {
+		return call(tenant, "CreateNvmeSubsystem", &pb.CreateNvmeSubsystemRequest{NvmeSubsystemId: id})
+	}
+	del := func(tenant, name string) error {
+		return call(tenant, "DeleteNvmeSubsystem", &pb.DeleteNvmeSubsystemRequest{Name: name})
+	}
+	expectCode := func(err error, code codes.Code) {
+		t.Helper()
+		if er, _ := status.FromError(err); er.Code() != code {
+			t.Error("error code: expected", code, "received", er.Code(), err)
+		}
+	}
+
+	expectCode(create("tenant-a", "subsys-1"), codes.OK)
+	// idempotent create of the same resource does not consume quota twice
+	expectCode(create("tenant-a", "subsys-1"), codes.OK)
+	expectCode(create("tenant-a", "failing"), codes.InvalidArgument)
+	expectCode(create("tenant-a", "subsys-2"), codes.OK)
+	expectCode(create("tenant-a", "subsys-3"), codes.ResourceExhausted)
+	if subsystems["subsys-3"] {
+		t.Error("expected create beyond quota not to reach handler")
+	}
+
+	// other tenants and requests without tenant are not affected
+	expectCode(create("tenant-b", "subsys-4"), codes.OK)
+	expectCode(create("", "subsys-5"), codes.OK)
+	// other methods are not limited
+	expectCode(call("tenant-a", "CreateNvmeController", &pb.CreateNvmeControllerRequest{}), codes.OK)
+
+	// deleting resource frees quota of tenant which created it
+	expectCode(del("", "subsys-1"), codes.OK)
+	expectCode(create("tenant-a", "subsys-3"), codes.OK)
+	expectCode(create("tenant-a", "subsys-6"), codes.ResourceExhausted)
+	// deleting resource not accounted to any tenant frees nothing
+	expectCode(del("tenant-a", "subsys-5"), codes.OK)
+	expectCode(create("tenant-a", "subsys-6"), codes.ResourceExhausted)
+}
+
+func TestTenantQuotas_DryRun(t *testing.T) {
+	quotas, err := NewTenantQuotas(map[string]int{"NvmeSubsystem": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/opi_api.storage.v1.FrontendNvmeService/CreateNvmeSubsystem"}
+	handler := func(_ context.Context, req interface{}) (interface{}, error) {
+		return &pb.NvmeSubsystem{Name: req.(*pb.CreateNvmeSubsystemRequest).NvmeSubsystemId}, nil
+	}
+	create := func(id string, dryRun bool) error {
+		md := metadata.Pairs(TenantMetadataKey, "tenant-a")
+		if dryRun {
+			md.Append(DryRunMetadataKey, "true")
+		}
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+		_, err := quotas.UnaryServerInterceptor(ctx, &pb.CreateNvmeSubsystemRequest{NvmeSubsystemId: id}, info, handler)
+		return err
+	}
+	expectCode := func(err error, code codes.Code) {
+		t.Helper()
+		if er, _ := status.FromError(err); er.Code() != code {
+			t.Error("error code: expected", code, "received", er.Code(), err)
+		}
+	}
+
+	// dry runs neither reserve quota nor record owners
+	expectCode(create("subsys-0", true), codes.OK)
+	expectCode(create("subsys-1", true), codes.OK)
+	if len(quotas.owners) != 0 {
+		t.Error("expected no owners recorded by dry run, received", quotas.owners)
+	}
+	expectCode(create("subsys-2", false), codes.OK)
+	// dry run still reports exhausted quota
+	expectCode(create("subsys-3", true), codes.ResourceExhausted)
+	expectCode(create("subsys-3", false), codes.ResourceExhausted)
+}
@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/opiproject/gospdk/spdk"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ReactorStatsServiceName is full name of the service reporting utilization
+// of SPDK reactors and threads. It is not part of OPI API, so it is
+// registered with a hand written service descriptor
+const ReactorStatsServiceName = "opi_spdk_bridge.v1.ReactorStatsService"
+
+// spdkMethodNotFoundError is error SPDK returns for RPCs it was built
+// without
+const spdkMethodNotFoundError = "Method not found"
+
+// frameworkGetReactorsResult is result of framework_get_reactors
+// TODO: use spdk.FrameworkGetReactorsResult when gospdk provides it
+type frameworkGetReactorsResult struct {
+	TickRate uint64 `json:"tick_rate"`
+	Reactors []struct {
+		Lcore     int    `json:"lcore"`
+		Busy      uint64 `json:"busy"`
+		Idle      uint64 `json:"idle"`
+		LwThreads []struct {
+			Name string `json:"name"`
+		} `json:"lw_threads"`
+	} `json:"reactors"`
+}
+
+// threadGetStatsResult is result of thread_get_stats
+// TODO: use spdk.ThreadGetStatsResult when gospdk provides it
+type threadGetStatsResult struct {
+	TickRate uint64 `json:"tick_rate"`
+	Threads  []struct {
+		Name string `json:"name"`
+		ID   uint64 `json:"id"`
+		Busy uint64 `json:"busy"`
+		Idle uint64 `json:"idle"`
+	} `json:"threads"`
+}
+
+// ReactorStat is utilization of SPDK reactor running on a CPU core
+type ReactorStat struct {
+	Core        int      `json:"core"`
+	BusyTicks   uint64   `json:"busy_ticks"`
+	IdleTicks   uint64   `json:"idle_ticks"`
+	BusyPercent float64  `json:"busy_percent"`
+	Threads     []string `json:"threads"`
+}
+
+// ThreadStat is utilization of SPDK thread
+type ThreadStat struct {
+	Name        string  `json:"name"`
+	ID          uint64  `json:"id"`
+	BusyTicks   uint64  `json:"busy_ticks"`
+	IdleTicks   uint64  `json:"idle_ticks"`
+	BusyPercent float64 `json:"busy_percent"`
+}
+
+// ReactorStats contains utilization of SPDK reactors and threads since SPDK
+// start. Ticks are counted at TickRate per second
+type ReactorStats struct {
+	TickRate uint64        `json:"tick_rate"`
+	Reactors []ReactorStat `json:"reactors"`
+	Threads  []ThreadStat  `json:"threads"`
+}
+
+// ReactorStatsServer reports SPDK reactor utilization for capacity planning
+type ReactorStatsServer struct {
+	rpc spdk.JSONRPC
+}
+
+// NewReactorStatsServer creates reactor stats server communicating with
+// provided jsonRPC
+func NewReactorStatsServer(jsonRPC spdk.JSONRPC) *ReactorStatsServer {
+	if jsonRPC == nil {
+		log.Panic("nil for JSONRPC is not allowed")
+	}
+	return &ReactorStatsServer{rpc: jsonRPC}
+}
+
+// busyPercent returns share of busy ticks in all ticks
+func busyPercent(busy uint64, idle uint64) float64 {
+	if busy+idle == 0 {
+		return 0
+	}
+	return float64(busy) * 100 / float64(busy+idle)
+}
+
+// callStatsRPC calls SPDK stats method and returns Unimplemented if SPDK was
+// built without it
+func (s *ReactorStatsServer) callStatsRPC(ctx context.Context, method string, result interface{}) error {
+	err := s.rpc.Call(ctx, method, nil, result)
+	if err != nil && strings.Contains(err.Error(), spdkMethodNotFoundError) {
+		msg := fmt.Sprintf("SPDK does not provide %s", method)
+		return status.Errorf(codes.Unimplemented, msg)
+	}
+	return err
+}
+
+// Stats returns busy and idle ticks per reactor and per thread
+func (s *ReactorStatsServer) Stats(ctx context.Context) (*ReactorStats, error) {
+	var reactors frameworkGetReactorsResult
+	if err := s.callStatsRPC(ctx, "framework_get_reactors", &reactors); err != nil {
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v", reactors)
+	var threads threadGetStatsResult
+	if err := s.callStatsRPC(ctx, "thread_get_stats", &threads); err != nil {
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v", threads)
+	stats := &ReactorStats{
+		TickRate: reactors.TickRate,
+		Reactors: make([]ReactorStat, 0, len(reactors.Reactors)),
+		Threads:  make([]ThreadStat, 0, len(threads.Threads)),
+	}
+	for _, reactor := range reactors.Reactors {
+		names := make([]string, 0, len(reactor.LwThreads))
+		for _, thread := range reactor.LwThreads {
+			names = append(names, thread.Name)
+		}
+		stats.Reactors = append(stats.Reactors, ReactorStat{
+			Core:        reactor.Lcore,
+			BusyTicks:   reactor.Busy,
+			IdleTicks:   reactor.Idle,
+			BusyPercent: busyPercent(reactor.Busy, reactor.Idle),
+			Threads:     names,
+		})
+	}
+	for _, thread := range threads.Threads {
+		stats.Threads = append(stats.Threads, ThreadStat{
+			Name:        thread.Name,
+			ID:          thread.ID,
+			BusyTicks:   thread.Busy,
+			IdleTicks:   thread.Idle,
+			BusyPercent: busyPercent(thread.Busy, thread.Idle),
+		})
+	}
+	return stats, nil
+}
+
+// GetReactorStats returns ReactorStats as a struct. Struct numbers are
+// doubles, so tick counts above 2^53 lose precision, percentages do not
+func (s *ReactorStatsServer) GetReactorStats(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	stats, err := s.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	response := &structpb.Struct{}
+	if err := response.UnmarshalJSON(data); err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	return response, nil
+}
+
+// reactorStatsServiceServer is implemented by ReactorStatsServer
+type reactorStatsServiceServer interface {
+	GetReactorStats(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+}
+
+var reactorStatsServiceDesc = grpc.ServiceDesc{
+	ServiceName: ReactorStatsServiceName,
+	HandlerType: (*reactorStatsServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetReactorStats",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(emptypb.Empty)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(reactorStatsServiceServer).GetReactorStats(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: "/" + ReactorStatsServiceName + "/GetReactorStats",
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(reactorStatsServiceServer).GetReactorStats(ctx, req.(*emptypb.Empty))
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterReactorStatsServer registers reactor stats service on s
+func RegisterReactorStatsServer(s *grpc.Server, srv *ReactorStatsServer) {
+	s.RegisterService(&reactorStatsServiceDesc, srv)
+}
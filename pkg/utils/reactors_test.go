@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// testSpdkReactors is framework_get_reactors result of SPDK running on two
+// cores
+const testSpdkReactors = `{
+	"tick_rate":2400000000,
+	"pid":5,
+	"reactors":[
+		{"lcore":0,"busy":3000,"idle":1000,"in_interrupt":false,"irq_count":0,"sys_stat":12,"usr_stat":34,
+		 "lw_threads":[{"name":"app_thread","id":1,"cpumask":"1","elapsed":4000},{"name":"nvmf_tgt_poll_group_000","id":2,"cpumask":"1","elapsed":4000}]},
+		{"lcore":1,"busy":0,"idle":4000,"in_interrupt":false,"irq_count":0,"sys_stat":1,"usr_stat":2,"lw_threads":[]}
+	]
+}`
+
+// testSpdkThreads is thread_get_stats result matching testSpdkReactors
+const testSpdkThreads = `{
+	"tick_rate":2400000000,
+	"threads":[
+		{"name":"app_thread","id":1,"cpumask":"1","busy":1000,"idle":3000,"active_pollers_count":1,"timed_pollers_count":3,"paused_pollers_count":0},
+		{"name":"nvmf_tgt_poll_group_000","id":2,"cpumask":"1","busy":2000,"idle":2000,"active_pollers_count":1,"timed_pollers_count":1,"paused_pollers_count":0}
+	]
+}`
+
+func TestReactorStatsServer_Stats(t *testing.T) {
+	tests := map[string]struct {
+		spdk    []string
+		out     *ReactorStats
+		errCode codes.Code
+		errMsg  string
+	}{
+		"reactors on two cores": {
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":` + testSpdkReactors + `}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":` + testSpdkThreads + `}`,
+			},
+			out: &ReactorStats{
+				TickRate: 2400000000,
+				Reactors: []ReactorStat{
+					{Core: 0, BusyTicks: 3000, IdleTicks: 1000, BusyPercent: 75, Threads: []string{"app_thread", "nvmf_tgt_poll_group_000"}},
+					{Core: 1, BusyTicks: 0, IdleTicks: 4000, BusyPercent: 0, Threads: []string{}},
+				},
+				Threads: []ThreadStat{
+					{Name: "app_thread", ID: 1, BusyTicks: 1000, IdleTicks: 3000, BusyPercent: 25},
+					{Name: "nvmf_tgt_poll_group_000", ID: 2, BusyTicks: 2000, IdleTicks: 2000, BusyPercent: 50},
+				},
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"reactors not provided by SPDK": {
+			spdk:    []string{`{"id":%d,"error":{"code":-32601,"message":"Method not found"},"result":null}`},
+			out:     nil,
+			errCode: codes.Unimplemented,
+			errMsg:  "SPDK does not provide framework_get_reactors",
+		},
+		"thread stats not provided by SPDK": {
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":` + testSpdkReactors + `}`,
+				`{"id":%d,"error":{"code":-32601,"message":"Method not found"},"result":null}`,
+			},
+			out:     nil,
+			errCode: codes.Unimplemented,
+			errMsg:  "SPDK does not provide thread_get_stats",
+		},
+		"error from SPDK": {
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":null}`},
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("framework_get_reactors: %v", "json response error: myopierr"),
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testSocket := GenerateSocketName("utils")
+			ln, jsonRPC := CreateTestSpdkServer(testSocket, tt.spdk)
+			defer func() {
+				CloseListener(ln)
+				if err := os.RemoveAll(testSocket); err != nil {
+					t.Error(err)
+				}
+			}()
+
+			stats, err := NewReactorStatsServer(jsonRPC).Stats(context.Background())
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if !reflect.DeepEqual(stats, tt.out) {
+				t.Error("stats: expected", tt.out, "received", stats)
+			}
+		})
+	}
+}
+
+func TestReactorStatsServer_GetReactorStats(t *testing.T) {
+	testSocket := GenerateSocketName("utils")
+	ln, jsonRPC := CreateTestSpdkServer(testSocket, []string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":` + testSpdkReactors + `}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":` + testSpdkThreads + `}`,
+	})
+	defer func() {
+		CloseListener(ln)
+		if err := os.RemoveAll(testSocket); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	response, err := NewReactorStatsServer(jsonRPC).GetReactorStats(context.Background(), &emptypb.Empty{})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if tickRate := response.GetFields()["tick_rate"].GetNumberValue(); tickRate != 2400000000 {
+		t.Error("tick rate: expected 2400000000, received", tickRate)
+	}
+	reactors := response.GetFields()["reactors"].GetListValue().GetValues()
+	if len(reactors) != 2 {
+		t.Fatal("reactors: expected 2, received", reactors)
+	}
+	if busy := reactors[0].GetStructValue().GetFields()["busy_percent"].GetNumberValue(); busy != 75 {
+		t.Error("busy percent: expected 75, received", busy)
+	}
+	if threads := response.GetFields()["threads"].GetListValue().GetValues(); len(threads) != 2 {
+		t.Error("threads: expected 2, received", threads)
+	}
+}
+
+func TestRegisterReactorStatsServer(t *testing.T) {
+	s := grpc.NewServer()
+	RegisterReactorStatsServer(s, NewReactorStatsServer(&memStatsJSONRPC{}))
+
+	info, ok := s.GetServiceInfo()[ReactorStatsServiceName]
+	if !ok {
+		t.Fatal("expected", ReactorStatsServiceName, "to be registered")
+	}
+	if len(info.Methods) != 1 || info.Methods[0].Name != "GetReactorStats" {
+		t.Error("methods: expected [GetReactorStats], received", info.Methods)
+	}
+}
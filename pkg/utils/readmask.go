@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"go.einride.tech/aip/fieldmask"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// ReadMaskMetadataKey is request metadata key carrying comma separated
+// field paths of resources to return from Get and List calls, since the
+// requests have no read_mask field
+const ReadMaskMetadataKey = "opi-read-mask"
+
+// ApplyReadMask returns copy of msg with only fields listed in mask set
+func ApplyReadMask(msg proto.Message, mask *fieldmaskpb.FieldMask) (proto.Message, error) {
+	if err := validateReadMask(mask, msg); err != nil {
+		return nil, err
+	}
+	return projectMessage(msg, mask), nil
+}
+
+// ApplyListReadMask returns copy of List response with mask applied to each
+// resource in its repeated fields. Other fields, e.g. next_page_token, are
+// kept
+func ApplyListReadMask(response proto.Message, mask *fieldmaskpb.FieldMask) (proto.Message, error) {
+	projected := proto.Clone(response).ProtoReflect()
+	fields := projected.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !fd.IsList() || fd.Message() == nil {
+			continue
+		}
+		if err := validateReadMask(mask, projected.NewField(fd).List().NewElement().Message().Interface()); err != nil {
+			return nil, err
+		}
+		if !projected.Has(fd) {
+			continue
+		}
+		list := projected.Mutable(fd).List()
+		for j := 0; j < list.Len(); j++ {
+			resource := projectMessage(list.Get(j).Message().Interface(), mask)
+			list.Set(j, protoreflect.ValueOfMessage(resource.ProtoReflect()))
+		}
+	}
+	return projected.Interface(), nil
+}
+
+func validateReadMask(mask *fieldmaskpb.FieldMask, resource proto.Message) error {
+	if err := fieldmask.Validate(mask, resource); err != nil {
+		msg := fmt.Sprintf("invalid read mask for %v: %v", resource.ProtoReflect().Descriptor().Name(), err)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func projectMessage(msg proto.Message, mask *fieldmaskpb.FieldMask) proto.Message {
+	projected := msg.ProtoReflect().New().Interface()
+	fieldmask.Update(mask, projected, msg)
+	return projected
+}
+
+func readMaskFromContext(ctx context.Context) *fieldmaskpb.FieldMask {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(ReadMaskMetadataKey)
+	if len(values) == 0 {
+		return nil
+	}
+	mask := &fieldmaskpb.FieldMask{}
+	for _, value := range values {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				mask.Paths = append(mask.Paths, field)
+			}
+		}
+	}
+	if len(mask.Paths) == 0 {
+		return nil
+	}
+	return mask
+}
+
+// ReadMaskUnaryServerInterceptor projects responses of Get and List calls
+// to fields requested in ReadMaskMetadataKey metadata
+func ReadMaskUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	mask := readMaskFromContext(ctx)
+	if mask == nil {
+		return handler(ctx, req)
+	}
+	method := path.Base(info.FullMethod)
+	isGet := strings.HasPrefix(method, "Get")
+	isList := strings.HasPrefix(method, "List")
+	if !isGet && !isList {
+		return handler(ctx, req)
+	}
+	response, err := handler(ctx, req)
+	if err != nil {
+		return response, err
+	}
+	msg, ok := response.(proto.Message)
+	if !ok {
+		return response, nil
+	}
+	if isGet {
+		return ApplyReadMask(msg, mask)
+	}
+	return ApplyListReadMask(msg, mask)
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+func TestReadMaskUnaryServerInterceptor(t *testing.T) {
+	volume := &pb.NullVolume{Name: "volumes/mytest", BlockSize: 512, BlocksCount: 64, Uuid: "9d5c3d1c-c5e3-4b2b-8b5e-1a5a1f7d8a8b"}
+	list := &pb.ListNullVolumesResponse{
+		NullVolumes:   []*pb.NullVolume{volume, {Name: "volumes/other", BlockSize: 4096, BlocksCount: 32}},
+		NextPageToken: "token",
+	}
+
+	tests := map[string]struct {
+		mask     string
+		method   string
+		response proto.Message
+		out      proto.Message
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"masked get": {
+			mask:     "name,block_size",
+			method:   "/opi_api.storage.v1.NullVolumeService/GetNullVolume",
+			response: volume,
+			out:      &pb.NullVolume{Name: "volumes/mytest", BlockSize: 512},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"masked list": {
+			mask:     "name",
+			method:   "/opi_api.storage.v1.NullVolumeService/ListNullVolumes",
+			response: list,
+			out: &pb.ListNullVolumesResponse{
+				NullVolumes:   []*pb.NullVolume{{Name: "volumes/mytest"}, {Name: "volumes/other"}},
+				NextPageToken: "token",
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"wildcard mask": {
+			mask:     "*",
+			method:   "/opi_api.storage.v1.NullVolumeService/GetNullVolume",
+			response: volume,
+			out:      volume,
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"no mask": {
+			mask:     "",
+			method:   "/opi_api.storage.v1.NullVolumeService/GetNullVolume",
+			response: volume,
+			out:      volume,
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"mask ignored for other methods": {
+			mask:     "name",
+			method:   "/opi_api.storage.v1.NullVolumeService/CreateNullVolume",
+			response: volume,
+			out:      volume,
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"unknown field on get": {
+			mask:     "name,size",
+			method:   "/opi_api.storage.v1.NullVolumeService/GetNullVolume",
+			response: volume,
+			out:      nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   "invalid read mask for NullVolume: invalid field path: size",
+		},
+		"unknown field on list": {
+			mask:     "next_page_token",
+			method:   "/opi_api.storage.v1.NullVolumeService/ListNullVolumes",
+			response: list,
+			out:      nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   "invalid read mask for NullVolume: invalid field path: next_page_token",
+		},
+	}
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			original := proto.Clone(tt.response)
+			ctx := context.Background()
+			if tt.mask != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(ReadMaskMetadataKey, tt.mask))
+			}
+			handler := func(context.Context, interface{}) (interface{}, error) {
+				return tt.response, nil
+			}
+
+			response, err := ReadMaskUnaryServerInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+			if tt.out == nil {
+				if response != nil {
+					t.Error("response: expected nil, received", response)
+				}
+			} else if msg, ok := response.(proto.Message); !ok || !proto.Equal(msg, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+			if !proto.Equal(tt.response, original) {
+				t.Error("handler response must not be modified, received", tt.response)
+			}
+		})
+	}
+}
@@ -5,22 +5,16 @@
 // Package utils contails useful helper functions
 package utils
 
-import (
-	"strings"
-
-	"go.einride.tech/aip/resourcename"
-)
-
 // ResourceIDToVolumeName creates name of volume resource based on ID
 func ResourceIDToVolumeName(resourceID string) string {
-	return resourcename.Join(
+	return currentNameStrategy().Join(
 		"volumes", resourceID,
 	)
 }
 
 // ResourceIDToSubsystemName transforms subsystem resource ID to subsystem name
 func ResourceIDToSubsystemName(resourceID string) string {
-	return resourcename.Join(
+	return currentNameStrategy().Join(
 		"nvmeSubsystems", resourceID,
 	)
 }
@@ -28,7 +22,7 @@ func ResourceIDToSubsystemName(resourceID string) string {
 // ResourceIDToNamespaceName transforms subsystem resource ID and namespace
 // resource ID to namespace name
 func ResourceIDToNamespaceName(subsysResourceID, ctrlrResourceID string) string {
-	return resourcename.Join(
+	return currentNameStrategy().Join(
 		"nvmeSubsystems", subsysResourceID,
 		"nvmeNamespaces", ctrlrResourceID,
 	)
@@ -37,7 +31,7 @@ func ResourceIDToNamespaceName(subsysResourceID, ctrlrResourceID string) string
 // ResourceIDToControllerName transforms subsystem resource ID and controller
 // resource ID to controller name
 func ResourceIDToControllerName(subsysResourceID, ctrlrResourceID string) string {
-	return resourcename.Join(
+	return currentNameStrategy().Join(
 		"nvmeSubsystems", subsysResourceID,
 		"nvmeControllers", ctrlrResourceID,
 	)
@@ -45,31 +39,20 @@ func ResourceIDToControllerName(subsysResourceID, ctrlrResourceID string) string
 
 // GetSubsystemIDFromNvmeName get parent ID (subsystem ID) from nvme related names
 func GetSubsystemIDFromNvmeName(name string) string {
-	segments := strings.Split(name, "/")
-	for i := range segments {
-		if (i + 1) == len(segments) {
-			return ""
-		}
-
-		if segments[i] == "nvmeSubsystems" {
-			return segments[i+1]
-		}
-	}
-
-	return ""
+	return currentNameStrategy().ParentID(name, "nvmeSubsystems")
 }
 
 // ResourceIDToRemoteControllerName transforms remote controller resource ID to
 // remote controller name
 func ResourceIDToRemoteControllerName(resourceID string) string {
-	return resourcename.Join(
+	return currentNameStrategy().Join(
 		"nvmeRemoteControllers", resourceID,
 	)
 }
 
 // ResourceIDToNvmePathName transforms path resource ID to path name
 func ResourceIDToNvmePathName(ctrlrResourceID, pathResourceID string) string {
-	return resourcename.Join(
+	return currentNameStrategy().Join(
 		"nvmeRemoteControllers", ctrlrResourceID,
 		"nvmePaths", pathResourceID,
 	)
@@ -78,11 +61,5 @@ func ResourceIDToNvmePathName(ctrlrResourceID, pathResourceID string) string {
 // GetRemoteControllerIDFromNvmeRemoteName get parent ID (RemoteController ID)
 // from nvme related names
 func GetRemoteControllerIDFromNvmeRemoteName(name string) string {
-	controller := ""
-	_ = resourcename.Sscan(name,
-		"nvmeRemoteControllers/{controller}",
-		&controller,
-	)
-
-	return controller
+	return currentNameStrategy().ParentID(name, "nvmeRemoteControllers")
 }
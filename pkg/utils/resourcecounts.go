@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ResourceCountsServiceName is full name of the service reporting number of
+// resources of each type. It is not part of OPI API, so it is registered
+// with a hand written service descriptor
+const ResourceCountsServiceName = "opi_spdk_bridge.v1.ResourceCountsService"
+
+// ResourceCounter reports number of resources it holds per resource type
+type ResourceCounter interface {
+	ResourceCounts() map[string]int
+}
+
+// ResourceCountsServer sums up resource counts of all servers, read from
+// in-memory maps without calling SPDK
+type ResourceCountsServer struct {
+	counters []ResourceCounter
+}
+
+// NewResourceCountsServer creates resource counts server summing up counts
+// of provided counters
+func NewResourceCountsServer(counters ...ResourceCounter) *ResourceCountsServer {
+	for _, counter := range counters {
+		if counter == nil {
+			log.Panic("nil for ResourceCounter is not allowed")
+		}
+	}
+	return &ResourceCountsServer{counters: counters}
+}
+
+// GetResourceCounts returns number of resources per resource type, e.g.
+// {"nvme_subsystems": 2, "null_volumes": 5}
+func (s *ResourceCountsServer) GetResourceCounts(_ context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	counts := make(map[string]*structpb.Value)
+	for _, counter := range s.counters {
+		for resource, count := range counter.ResourceCounts() {
+			if value, ok := counts[resource]; ok {
+				count += int(value.GetNumberValue())
+			}
+			counts[resource] = structpb.NewNumberValue(float64(count))
+		}
+	}
+	return &structpb.Struct{Fields: counts}, nil
+}
+
+type resourceCountsServiceServer interface {
+	GetResourceCounts(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+}
+
+var resourceCountsServiceDesc = grpc.ServiceDesc{
+	ServiceName: ResourceCountsServiceName,
+	HandlerType: (*resourceCountsServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetResourceCounts",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(emptypb.Empty)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(resourceCountsServiceServer).GetResourceCounts(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: "/" + ResourceCountsServiceName + "/GetResourceCounts",
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(resourceCountsServiceServer).GetResourceCounts(ctx, req.(*emptypb.Empty))
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterResourceCountsServer registers resource counts service on s
+func RegisterResourceCountsServer(s *grpc.Server, srv *ResourceCountsServer) {
+	s.RegisterService(&resourceCountsServiceDesc, srv)
+}
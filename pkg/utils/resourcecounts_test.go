@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type fakeResourceCounter map[string]int
+
+func (c fakeResourceCounter) ResourceCounts() map[string]int {
+	return c
+}
+
+func TestResourceCountsServer_GetResourceCounts(t *testing.T) {
+	server := NewResourceCountsServer(
+		fakeResourceCounter{"null_volumes": 2, "aio_volumes": 0},
+		fakeResourceCounter{"nvme_subsystems": 1, "nvme_namespaces": 3},
+		fakeResourceCounter{"qos_volumes": 1, "null_volumes": 1},
+	)
+
+	counts, err := server.GetResourceCounts(context.Background(), &emptypb.Empty{})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	expected := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"null_volumes":    structpb.NewNumberValue(3),
+		"aio_volumes":     structpb.NewNumberValue(0),
+		"nvme_subsystems": structpb.NewNumberValue(1),
+		"nvme_namespaces": structpb.NewNumberValue(3),
+		"qos_volumes":     structpb.NewNumberValue(1),
+	}}
+	if !proto.Equal(counts, expected) {
+		t.Error("counts: expected", expected, "received", counts)
+	}
+}
+
+func TestNewResourceCountsServer_NilCounter(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic for nil counter")
+		}
+	}()
+	NewResourceCountsServer(fakeResourceCounter{}, nil)
+}
+
+func TestRegisterResourceCountsServer(t *testing.T) {
+	s := grpc.NewServer()
+	RegisterResourceCountsServer(s, NewResourceCountsServer())
+
+	info, ok := s.GetServiceInfo()[ResourceCountsServiceName]
+	if !ok {
+		t.Fatal("expected", ResourceCountsServiceName, "to be registered")
+	}
+	if len(info.Methods) != 1 || info.Methods[0].Name != "GetResourceCounts" {
+		t.Error("methods: expected [GetResourceCounts], received", info.Methods)
+	}
+}
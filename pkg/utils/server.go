@@ -14,17 +14,22 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/opiproject/gospdk/spdk"
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
 )
 
 // ExtractPagination is a helper function for List pagination to fetch PageSize and PageToken
-func ExtractPagination(pageSize int32, pageToken string, pagination map[string]int) (size int, offset int, err error) {
+func ExtractPagination(pageSize int32, pageToken string, pagination *Pagination) (size int, offset int, err error) {
 	const (
 		maxPageSize     = 250
 		defaultPageSize = 50
@@ -43,7 +48,7 @@ func ExtractPagination(pageSize int32, pageToken string, pagination map[string]i
 	offset = 0
 	if pageToken != "" {
 		var ok bool
-		offset, ok = pagination[pageToken]
+		offset, ok = pagination.Get(pageToken)
 		if !ok {
 			return -1, -1, status.Errorf(codes.NotFound, "unable to find pagination token %s", pageToken)
 		}
@@ -52,6 +57,48 @@ func ExtractPagination(pageSize int32, pageToken string, pagination map[string]i
 	return size, offset, nil
 }
 
+// DefaultListByteBudget is default limit of encoded size of resources in a
+// List response, kept below default 4MB gRPC message limit
+const DefaultListByteBudget = 3 << 20
+
+var listByteBudget = func() *atomic.Int64 {
+	budget := &atomic.Int64{}
+	budget.Store(DefaultListByteBudget)
+	return budget
+}()
+
+// SetListByteBudget sets limit of encoded size of resources in a List
+// response
+func SetListByteBudget(budget int) error {
+	if budget <= 0 {
+		return fmt.Errorf("list byte budget must be positive, got %d", budget)
+	}
+	listByteBudget.Store(int64(budget))
+	return nil
+}
+
+// LimitPaginationBySize truncates page of resources starting at offset, so
+// its encoded size fits into the list byte budget, instead of failing the
+// List with ResourceExhausted. If truncated, token is created if needed
+// and set to the first resource left out. At least one resource is kept
+func LimitPaginationBySize[T proto.Message](page []T, offset int, token string, pagination *Pagination) ([]T, string) {
+	budget := listByteBudget.Load()
+	total := int64(0)
+	for i, resource := range page {
+		// tag and length prefix of repeated field element
+		total += int64(1 + protowire.SizeBytes(proto.Size(resource)))
+		if total > budget && i > 0 {
+			log.Printf("Limiting result len(%d) to %d to fit into %d bytes", len(page), i, budget)
+			if token == "" {
+				token = uuid.New().String()
+			}
+			pagination.Set(token, offset+i)
+			return page[:i], token
+		}
+	}
+	return page, token
+}
+
 // LimitPagination is a helper function for slice the result by offset and size
 func LimitPagination[T any](result []T, offset int, size int) ([]T, bool) {
 	end := offset + size
@@ -66,7 +113,7 @@ func LimitPagination[T any](result []T, offset int, size int) ([]T, bool) {
 
 // CreateTestSpdkServer creates a mock spdk server for testing
 func CreateTestSpdkServer(socket string, spdkResponses []string) (net.Listener, spdk.JSONRPC) {
-	jsonRPC := spdk.NewClient(socket)
+	jsonRPC := NewSpdkClient(socket)
 	ln := jsonRPC.StartUnixListener()
 	if len(spdkResponses) > 0 {
 		go spdkMockServerCommunicate(jsonRPC, ln, spdkResponses)
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2023 Intel Corporation
+
+// Package utils contails useful helper functions
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSetListByteBudget(t *testing.T) {
+	t.Cleanup(func() { _ = SetListByteBudget(DefaultListByteBudget) })
+	tests := map[string]struct {
+		in     int
+		errMsg string
+	}{
+		"positive budget": {
+			in:     1024,
+			errMsg: "",
+		},
+		"zero budget": {
+			in:     0,
+			errMsg: "list byte budget must be positive, got 0",
+		},
+		"negative budget": {
+			in:     -1,
+			errMsg: "list byte budget must be positive, got -1",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := SetListByteBudget(tt.in)
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tt.errMsg {
+				t.Error("expected error", tt.errMsg, "received", errMsg)
+			}
+		})
+	}
+}
+
+func TestLimitPaginationBySize(t *testing.T) {
+	t.Cleanup(func() { _ = SetListByteBudget(DefaultListByteBudget) })
+	page := []*pb.NullVolume{}
+	for i := 0; i < 10; i++ {
+		page = append(page, &pb.NullVolume{Name: fmt.Sprintf("volume-%d", i)})
+	}
+	// every element takes tag, length and 10 bytes of message
+	elementSize := 1 + 1 + proto.Size(page[0])
+
+	tests := map[string]struct {
+		budget   int
+		offset   int
+		token    string
+		outLen   int
+		outToken bool
+		next     int
+	}{
+		"fits into budget": {
+			budget:   10 * elementSize,
+			offset:   0,
+			token:    "",
+			outLen:   10,
+			outToken: false,
+		},
+		"truncated": {
+			budget:   3*elementSize + 1,
+			offset:   5,
+			token:    "",
+			outLen:   3,
+			outToken: true,
+			next:     8,
+		},
+		"truncated with existing token": {
+			budget:   4 * elementSize,
+			offset:   0,
+			token:    "existing-token",
+			outLen:   4,
+			outToken: true,
+			next:     4,
+		},
+		"at least one element": {
+			budget:   1,
+			offset:   0,
+			token:    "",
+			outLen:   1,
+			outToken: true,
+			next:     1,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := SetListByteBudget(tt.budget); err != nil {
+				t.Fatal(err)
+			}
+			pagination := NewPagination()
+			if tt.token != "" {
+				pagination.Set(tt.token, 100)
+			}
+
+			out, token := LimitPaginationBySize(page, tt.offset, tt.token, pagination)
+
+			if len(out) != tt.outLen {
+				t.Error("expected", tt.outLen, "elements, received", len(out))
+			}
+			if (token != "") != tt.outToken {
+				t.Error("expected token presence", tt.outToken, "received", token)
+			}
+			if tt.token != "" && token != tt.token {
+				t.Error("expected existing token", tt.token, "to be reused, received", token)
+			}
+			if next, _ := pagination.Get(token); tt.outToken && next != tt.next {
+				t.Error("expected next offset", tt.next, "received", next)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/opiproject/gospdk/spdk"
+)
+
+const (
+	// SpdkCallsMetadataKey is request metadata key which enables recording of
+	// SPDK calls when set to "true"
+	SpdkCallsMetadataKey = "opi-record-spdk-calls"
+	// SpdkCallsTrailerKey is response trailer key carrying comma separated
+	// list of SPDK methods invoked while serving the request
+	SpdkCallsTrailerKey = "opi-spdk-calls"
+)
+
+type spdkCallRecorderKey struct{}
+
+type spdkCallRecorder struct {
+	mu      sync.Mutex
+	methods []string
+}
+
+func (r *spdkCallRecorder) record(method string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.methods = append(r.methods, method)
+}
+
+func (r *spdkCallRecorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.methods, ",")
+}
+
+func recordSpdkCall(ctx context.Context, method string) {
+	if recorder, ok := ctx.Value(spdkCallRecorderKey{}).(*spdkCallRecorder); ok {
+		recorder.record(method)
+	}
+}
+
+type spdkCallRecordingJSONRPC struct {
+	spdk.JSONRPC
+}
+
+// NewSpdkCallRecordingJSONRPC wraps jsonRPC so that every SPDK method invoked
+// is appended to the recorder attached to the call context, if any
+func NewSpdkCallRecordingJSONRPC(jsonRPC spdk.JSONRPC) spdk.JSONRPC {
+	if jsonRPC == nil {
+		log.Panic("nil for JSONRPC is not allowed")
+	}
+	return &spdkCallRecordingJSONRPC{JSONRPC: jsonRPC}
+}
+
+func (c *spdkCallRecordingJSONRPC) GetVersion(ctx context.Context) string {
+	recordSpdkCall(ctx, "spdk_get_version")
+	return c.JSONRPC.GetVersion(ctx)
+}
+
+func (c *spdkCallRecordingJSONRPC) Call(ctx context.Context, method string, args, result interface{}) error {
+	recordSpdkCall(ctx, method)
+	return c.JSONRPC.Call(ctx, method, args, result)
+}
+
+// SpdkCallsUnaryServerInterceptor records SPDK methods invoked during a request
+// which opted in via SpdkCallsMetadataKey and returns them in SpdkCallsTrailerKey
+func SpdkCallsUnaryServerInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(SpdkCallsMetadataKey); len(values) == 0 || values[0] != "true" {
+		return handler(ctx, req)
+	}
+	recorder := &spdkCallRecorder{}
+	ctx = context.WithValue(ctx, spdkCallRecorderKey{}, recorder)
+	resp, err := handler(ctx, req)
+	if trailerErr := grpc.SetTrailer(ctx, metadata.Pairs(SpdkCallsTrailerKey, recorder.String())); trailerErr != nil {
+		log.Printf("error: failed to set SPDK calls trailer: %v", trailerErr)
+	}
+	return resp, err
+}
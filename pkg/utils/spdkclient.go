@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spdkClient calls SPDK like spdk.Client, but returns connection failures,
+// wrapping the underlying net errors, instead of exiting, so that they can
+// be retried, and reports errors SPDK answered with as SpdkError instead of
+// flattening them into text
+// TODO: drop it when gospdk reports SPDK error codes
+type spdkClient struct {
+	*spdk.Client
+	transport string
+	socket    string
+	id        uint64
+	tracer    trace.Tracer
+}
+
+// NewSpdkClient creates JSONRPC interacting with SPDK via unix domain
+// socket, e.g. /var/tmp/spdk.sock, or via tcp connection ip and port tuple,
+// e.g. 10.1.1.2:1234
+func NewSpdkClient(socketPath string) spdk.JSONRPC {
+	if socketPath == "" {
+		log.Panic("empty socketPath is not allowed")
+	}
+	protocol := "tcp"
+	if _, _, err := net.SplitHostPort(socketPath); err != nil {
+		protocol = "unix"
+	}
+	log.Printf("Connection to SPDK will be via: %s detected from %s", protocol, socketPath)
+	return &spdkClient{
+		Client:    spdk.NewClient(socketPath),
+		transport: protocol,
+		socket:    socketPath,
+		tracer:    otel.Tracer(""),
+	}
+}
+
+func (c *spdkClient) GetID() uint64 {
+	return atomic.LoadUint64(&c.id)
+}
+
+func (c *spdkClient) GetVersion(ctx context.Context) string {
+	var ver spdk.GetVersionResult
+	if err := c.Call(ctx, "spdk_get_version", nil, &ver); err != nil {
+		log.Printf("Could not get spdk version: %v", err)
+		return ""
+	}
+	log.Printf("Received from SPDK: %v", ver)
+	return ver.Version
+}
+
+func (c *spdkClient) Call(ctx context.Context, method string, args, result interface{}) error {
+	id := atomic.AddUint64(&c.id, 1)
+
+	_, childSpan := c.tracer.Start(ctx, "spdk."+method)
+	defer childSpan.End()
+
+	if childSpan.IsRecording() {
+		childSpan.SetAttributes(
+			attribute.Int64("request.id", int64(id)),
+			attribute.String("spdk.socket", c.socket),
+			attribute.String("spdk.transport", c.transport),
+		)
+	}
+
+	data, err := json.Marshal(spdk.RPCRequest{
+		RPCVersion: spdk.JSONRPCVersion,
+		ID:         id,
+		Method:     method,
+		Params:     args,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	log.Printf("Sending to SPDK: %s", data)
+
+	response, err := c.communicate(ctx, data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if response.ID != id {
+		return fmt.Errorf("%s: %s", method, spdkIDMismatchError)
+	}
+	if response.Error.Code != 0 {
+		return &SpdkError{Method: method, Code: response.Error.Code, Message: response.Error.Message}
+	}
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	return nil
+}
+
+// communicate sends request in a connection of its own and reads response
+// SPDK sends before closing it. Connection is bounded by ctx, so that calls
+// SPDK does not answer return on ctx deadline or cancellation with ctx error
+func (c *spdkClient) communicate(ctx context.Context, data []byte) (*spdk.RPCResponse, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.transport, c.socket)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Printf("error: failed to close SPDK connection: %v", err)
+		}
+	}()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+	if ctx.Done() != nil {
+		// unblock pending reads and writes when client cancels
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-ctx.Done():
+				_ = conn.SetDeadline(time.Now())
+			case <-stop:
+			}
+		}()
+	}
+	response, err := c.exchange(conn, data)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	return response, nil
+}
+
+// contextError returns ctx error instead of err when the connection failed
+// because ctx is done
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		// connection deadline passed just before ctx deadline fired
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// exchange writes request to conn and reads response
+func (c *spdkClient) exchange(conn net.Conn, data []byte) (*spdk.RPCResponse, error) {
+	if _, err := conn.Write(data); err != nil {
+		return nil, err
+	}
+	if closeWriter, ok := conn.(interface{ CloseWrite() error }); ok {
+		if err := closeWriter.CloseWrite(); err != nil {
+			return nil, err
+		}
+	}
+	var response spdk.RPCResponse
+	err := json.NewDecoder(bufio.NewReader(conn)).Decode(&response)
+	jsonresponse, _ := json.Marshal(response)
+	log.Printf("Received from SPDK: %s", jsonresponse)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
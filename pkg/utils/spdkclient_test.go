@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSpdkClient_Call(t *testing.T) {
+	tests := map[string]struct {
+		spdk     []string
+		result   bool
+		errCode  codes.Code
+		errMsg   string
+		spdkErr  *SpdkError
+		expectOk bool
+	}{
+		"valid response": {
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			result:   true,
+			errCode:  codes.OK,
+			errMsg:   "",
+			spdkErr:  nil,
+			expectOk: false,
+		},
+		"error from SPDK": {
+			spdk:     []string{`{"id":%d,"error":{"code":-32602,"message":"Invalid parameters"},"result":null}`},
+			result:   false,
+			errCode:  codes.Unknown,
+			errMsg:   "bdev_null_delete: json response error: Invalid parameters",
+			spdkErr:  &SpdkError{Method: "bdev_null_delete", Code: -32602, Message: "Invalid parameters"},
+			expectOk: true,
+		},
+		"ID mismatch": {
+			spdk:     []string{`{"id":0,"error":{"code":0,"message":""},"result":true}`},
+			result:   false,
+			errCode:  codes.Unknown,
+			errMsg:   "bdev_null_delete: json response ID mismatch",
+			spdkErr:  nil,
+			expectOk: false,
+		},
+		"empty response": {
+			spdk:     []string{""},
+			result:   false,
+			errCode:  codes.Unknown,
+			errMsg:   "bdev_null_delete: EOF",
+			spdkErr:  nil,
+			expectOk: false,
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testSocket := GenerateSocketName("utils")
+			ln, jsonRPC := CreateTestSpdkServer(testSocket, tt.spdk)
+			defer func() {
+				CloseListener(ln)
+				if err := os.RemoveAll(testSocket); err != nil {
+					t.Error(err)
+				}
+			}()
+
+			var result bool
+			err := jsonRPC.Call(context.Background(), "bdev_null_delete", nil, &result)
+
+			if result != tt.result {
+				t.Error("result: expected", tt.result, "received", result)
+			}
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			// status is sent to clients as proto, details must survive it
+			spdkErr, ok := SpdkErrorFromStatus(status.FromProto(er.Proto()))
+			if ok != tt.expectOk {
+				t.Error("SPDK error in details: expected", tt.expectOk, "received", ok)
+			}
+			if !reflect.DeepEqual(spdkErr, tt.spdkErr) {
+				t.Error("SPDK error: expected", tt.spdkErr, "received", spdkErr)
+			}
+		})
+	}
+}
+
+func TestSpdkClient_CallConnectionError(t *testing.T) {
+	jsonRPC := NewSpdkClient(GenerateSocketName("utils"))
+
+	err := jsonRPC.Call(context.Background(), "bdev_null_delete", nil, nil)
+
+	if !isSpdkConnectionError(err) {
+		t.Error("expected connection error, received", err)
+	}
+}
+
+func TestSpdkClient_CallContextDone(t *testing.T) {
+	tests := map[string]struct {
+		ctx func() (context.Context, context.CancelFunc)
+		err error
+	}{
+		"deadline exceeded": {
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+			err: context.DeadlineExceeded,
+		},
+		"canceled": {
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(20*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			err: context.Canceled,
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			const delay = time.Second
+			_, jsonRPC := startSleepingSpdkServer(t, GenerateSocketName("utils"), delay)
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			start := time.Now()
+			err := jsonRPC.Call(ctx, "spdk_get_version", nil, nil)
+
+			if elapsed := time.Since(start); elapsed >= delay {
+				t.Error("expected call to return before SPDK responds, took", elapsed)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Error("error: expected", tt.err, "received", err)
+			}
+		})
+	}
+}
+
+func TestNewSpdkClient_EmptySocket(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic for empty socket path")
+		}
+	}()
+	NewSpdkClient("")
+}
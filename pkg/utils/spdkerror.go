@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SpdkErrorDomain is domain of ErrorInfo attached to gRPC status of errors
+// reported by SPDK
+const SpdkErrorDomain = "spdk.io"
+
+// SpdkErrorReason is reason of ErrorInfo attached to gRPC status of errors
+// reported by SPDK. Its metadata holds SPDK method, code and message
+const SpdkErrorReason = "SPDK_ERROR"
+
+// SpdkError is error SPDK answered a JSON-RPC call with
+type SpdkError struct {
+	Method  string
+	Code    int
+	Message string
+}
+
+// Error keeps format of errors reported by spdk.Client
+func (e *SpdkError) Error() string {
+	return fmt.Sprintf("%s: json response error: %s", e.Method, e.Message)
+}
+
+// GRPCStatus returns Unknown status with SPDK error code and message
+// attached as ErrorInfo details, so that clients can handle SPDK errors
+// without parsing the message
+func (e *SpdkError) GRPCStatus() *status.Status {
+	st := status.New(codes.Unknown, e.Error())
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: SpdkErrorReason,
+		Domain: SpdkErrorDomain,
+		Metadata: map[string]string{
+			"method":  e.Method,
+			"code":    strconv.Itoa(e.Code),
+			"message": e.Message,
+		},
+	})
+	if err != nil {
+		log.Printf("error: failed to attach SPDK error details: %v", err)
+		return st
+	}
+	return detailed
+}
+
+// SpdkErrorFromStatus returns SPDK error carried in details of st, if any
+func SpdkErrorFromStatus(st *status.Status) (*SpdkError, bool) {
+	for _, detail := range st.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.Domain != SpdkErrorDomain || info.Reason != SpdkErrorReason {
+			continue
+		}
+		code, err := strconv.Atoi(info.Metadata["code"])
+		if err != nil {
+			continue
+		}
+		return &SpdkError{Method: info.Metadata["method"], Code: code, Message: info.Metadata["message"]}, true
+	}
+	return nil, false
+}
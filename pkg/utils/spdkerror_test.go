@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSpdkError_GRPCStatus(t *testing.T) {
+	spdkErr := &SpdkError{Method: "bdev_malloc_create", Code: -17, Message: "File exists"}
+	var err error = fmt.Errorf("wrapped: %w", spdkErr)
+
+	var target *SpdkError
+	if !errors.As(err, &target) {
+		t.Fatal("expected SpdkError to be found in", err)
+	}
+	st, ok := status.FromError(spdkErr)
+	if !ok {
+		t.Fatal("expected SpdkError to convert to status")
+	}
+	if st.Code() != codes.Unknown {
+		t.Error("error code: expected", codes.Unknown, "received", st.Code())
+	}
+	if st.Message() != "bdev_malloc_create: json response error: File exists" {
+		t.Error("error message: expected format of spdk.Client, received", st.Message())
+	}
+
+	// status is sent to clients as proto, details must survive it
+	received, ok := SpdkErrorFromStatus(status.FromProto(st.Proto()))
+	if !ok {
+		t.Fatal("expected SPDK error in status details")
+	}
+	if !reflect.DeepEqual(received, spdkErr) {
+		t.Error("SPDK error: expected", spdkErr, "received", received)
+	}
+}
+
+func TestSpdkErrorFromStatus(t *testing.T) {
+	foreign, err := status.New(codes.Unknown, "failed").WithDetails(&errdetails.ErrorInfo{
+		Reason:   SpdkErrorReason,
+		Domain:   "example.com",
+		Metadata: map[string]string{"code": "-17"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	malformed, err := status.New(codes.Unknown, "failed").WithDetails(&errdetails.ErrorInfo{
+		Reason:   SpdkErrorReason,
+		Domain:   SpdkErrorDomain,
+		Metadata: map[string]string{"code": "not-a-number"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		in *status.Status
+	}{
+		"no details":        {in: status.New(codes.Unknown, "failed")},
+		"other domain":      {in: foreign},
+		"malformed code":    {in: malformed},
+		"not an SPDK error": {in: status.New(codes.NotFound, "unable to find key")},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			if spdkErr, ok := SpdkErrorFromStatus(tt.in); ok {
+				t.Error("expected no SPDK error, received", spdkErr)
+			}
+		})
+	}
+}
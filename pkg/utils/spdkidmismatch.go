@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/opiproject/gospdk/spdk"
+)
+
+// Policies applied when SPDK answers with response ID not matching request
+const (
+	// SpdkIDMismatchReconnect drops the client and creates a new one, since
+	// the stream is desynchronized, and fails the call with codes.Unavailable
+	SpdkIDMismatchReconnect = "reconnect"
+	// SpdkIDMismatchFail fails the call keeping the client
+	SpdkIDMismatchFail = "fail"
+)
+
+// spdkIDMismatchError is error reported by spdk.Client on response ID mismatch
+const spdkIDMismatchError = "json response ID mismatch"
+
+type idMismatchHandlingJSONRPC struct {
+	mu         sync.RWMutex
+	jsonRPC    spdk.JSONRPC
+	newJSONRPC func() spdk.JSONRPC
+	recycled   uint64
+}
+
+// NewIDMismatchHandlingJSONRPC creates JSONRPC calling SPDK via client
+// created by newJSONRPC. With SpdkIDMismatchReconnect policy the client is
+// replaced by a new one once a response ID mismatch is detected
+func NewIDMismatchHandlingJSONRPC(newJSONRPC func() spdk.JSONRPC, policy string) (spdk.JSONRPC, error) {
+	if newJSONRPC == nil {
+		log.Panic("nil for JSONRPC constructor is not allowed")
+	}
+	switch policy {
+	case SpdkIDMismatchReconnect:
+		return &idMismatchHandlingJSONRPC{jsonRPC: newJSONRPC(), newJSONRPC: newJSONRPC}, nil
+	case SpdkIDMismatchFail:
+		return newJSONRPC(), nil
+	default:
+		return nil, fmt.Errorf("unknown SPDK ID mismatch policy %q, supported are %v",
+			policy, []string{SpdkIDMismatchFail, SpdkIDMismatchReconnect})
+	}
+}
+
+func (c *idMismatchHandlingJSONRPC) client() spdk.JSONRPC {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.jsonRPC
+}
+
+// recycle replaces client which got mismatched response, unless it was
+// already replaced by a concurrent call
+func (c *idMismatchHandlingJSONRPC) recycle(desynced spdk.JSONRPC) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.jsonRPC != desynced {
+		return
+	}
+	c.jsonRPC = c.newJSONRPC()
+	c.recycled++
+	log.Printf("error: SPDK response ID mismatch, recycled SPDK client %d time(s)", c.recycled)
+}
+
+func (c *idMismatchHandlingJSONRPC) GetID() uint64 {
+	return c.client().GetID()
+}
+
+func (c *idMismatchHandlingJSONRPC) GetVersion(ctx context.Context) string {
+	return c.client().GetVersion(ctx)
+}
+
+func (c *idMismatchHandlingJSONRPC) StartUnixListener() net.Listener {
+	return c.client().StartUnixListener()
+}
+
+func (c *idMismatchHandlingJSONRPC) Call(ctx context.Context, method string, args, result interface{}) error {
+	jsonRPC := c.client()
+	err := jsonRPC.Call(ctx, method, args, result)
+	if err != nil && strings.HasSuffix(err.Error(), spdkIDMismatchError) {
+		c.recycle(jsonRPC)
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/opiproject/gospdk/spdk"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIDMismatchHandlingJSONRPC(t *testing.T) {
+	tests := map[string]struct {
+		policy   string
+		spdk     []string
+		clients  int
+		errCodes []codes.Code
+		errMsgs  []string
+	}{
+		"reconnect recycles client": {
+			policy: SpdkIDMismatchReconnect,
+			spdk: []string{
+				`{"id":0,"error":{"code":0,"message":""},"result":true}`,
+				// recycled client numbers requests from 1 again
+				`{"id":1,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":2,"error":{"code":0,"message":""},"result":true}`,
+			},
+			clients:  2,
+			errCodes: []codes.Code{codes.Unavailable, codes.OK, codes.OK},
+			errMsgs:  []string{fmt.Sprintf("spdk_get_version: %v", "json response ID mismatch"), "", ""},
+		},
+		"reconnect keeps client on other errors": {
+			policy: SpdkIDMismatchReconnect,
+			spdk: []string{
+				`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			clients:  1,
+			errCodes: []codes.Code{codes.Unknown, codes.OK},
+			errMsgs:  []string{fmt.Sprintf("spdk_get_version: %v", "json response error: myopierr"), ""},
+		},
+		"fail keeps client": {
+			policy: SpdkIDMismatchFail,
+			spdk: []string{
+				`{"id":0,"error":{"code":0,"message":""},"result":true}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":true}`,
+			},
+			clients:  1,
+			errCodes: []codes.Code{codes.Unknown, codes.OK},
+			errMsgs:  []string{fmt.Sprintf("spdk_get_version: %v", "json response ID mismatch"), ""},
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testSocket := GenerateSocketName("utils")
+			ln, testJSONRPC := CreateTestSpdkServer(testSocket, tt.spdk)
+			defer func() {
+				CloseListener(ln)
+				if err := os.RemoveAll(testSocket); err != nil {
+					t.Error(err)
+				}
+			}()
+			clients := 0
+			jsonRPC, err := NewIDMismatchHandlingJSONRPC(func() spdk.JSONRPC {
+				clients++
+				if clients == 1 {
+					return testJSONRPC
+				}
+				return spdk.NewClient(testSocket)
+			}, tt.policy)
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+
+			for i := range tt.spdk {
+				var result bool
+				err := jsonRPC.Call(context.Background(), "spdk_get_version", nil, &result)
+				er, _ := status.FromError(err)
+				if er.Code() != tt.errCodes[i] {
+					t.Error("call", i, "error code: expected", tt.errCodes[i], "received", er.Code())
+				}
+				if er.Message() != tt.errMsgs[i] {
+					t.Error("call", i, "error message: expected", tt.errMsgs[i], "received", er.Message())
+				}
+			}
+			if clients != tt.clients {
+				t.Error("clients: expected", tt.clients, "received", clients)
+			}
+		})
+	}
+}
+
+func TestNewIDMismatchHandlingJSONRPC_UnknownPolicy(t *testing.T) {
+	_, err := NewIDMismatchHandlingJSONRPC(func() spdk.JSONRPC {
+		return spdk.NewClient("/some/path")
+	}, "retry")
+	expected := `unknown SPDK ID mismatch policy "retry", supported are [fail reconnect]`
+	if err == nil || err.Error() != expected {
+		t.Error("error: expected", expected, "received", err)
+	}
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+)
+
+// DefaultSpdkRetryBackoff is default delay before the first retry of SPDK
+// call failed on connection level, doubled with every next retry
+const DefaultSpdkRetryBackoff = 100 * time.Millisecond
+
+// spdkResponseError is part of error reported by spdk.Client when SPDK
+// answered with an error, such calls are never retried
+const spdkResponseError = "json response error"
+
+// spdkConnectionErrors are suffixes of errors reported by spdk.Client,
+// which formats underlying errors as text, when connection to SPDK failed
+var spdkConnectionErrors = []string{
+	io.EOF.Error(),
+	io.ErrUnexpectedEOF.Error(),
+	syscall.ECONNREFUSED.Error(),
+	syscall.ECONNRESET.Error(),
+	syscall.EPIPE.Error(),
+}
+
+// spdkReadOnlyMethods are SPDK methods not changing SPDK state, which can be
+// sent again even if SPDK may have executed them already
+var spdkReadOnlyMethods = map[string]bool{
+	"accel_crypto_keys_get":     true,
+	"bdev_get_bdevs":            true,
+	"bdev_get_histogram":        true,
+	"bdev_get_iostat":           true,
+	"bdev_nvme_get_controllers": true,
+	"framework_get_reactors":    true,
+	"framework_get_subsystems":  true,
+	"nvmf_get_stats":            true,
+	"nvmf_get_subsystems":       true,
+	"spdk_get_version":          true,
+	"thread_get_stats":          true,
+	"vhost_get_controllers":     true,
+}
+
+// isSpdkDialError reports whether err is a failure to connect to SPDK, so
+// that the request was never sent
+func isSpdkDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isSpdkRetriable reports whether call of method failed with err may be
+// retried. Calls failed after the request was sent, e.g. on EOF, are retried
+// only for read-only methods, since SPDK may have executed the request, and
+// sending e.g. a create again would fail with already exists
+func isSpdkRetriable(method string, err error) bool {
+	return isSpdkDialError(err) || (spdkReadOnlyMethods[method] && isSpdkConnectionError(err))
+}
+
+// isSpdkConnectionError reports whether err is a connection level failure,
+// after which an immediate retry may succeed
+func isSpdkConnectionError(err error) bool {
+	var spdkErr *SpdkError
+	if err == nil || errors.As(err, &spdkErr) || strings.Contains(err.Error(), spdkResponseError) {
+		return false
+	}
+	var opErr *net.OpError
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &opErr) {
+		return true
+	}
+	for _, suffix := range spdkConnectionErrors {
+		if strings.HasSuffix(err.Error(), suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+type retryingJSONRPC struct {
+	spdk.JSONRPC
+	retries int
+	backoff time.Duration
+}
+
+// NewRetryingJSONRPC wraps jsonRPC so that SPDK calls failed on connection
+// level are retried up to retries times with exponential backoff. Failed
+// connects are retried for all methods, failures after the request was sent,
+// e.g. EOF, for read-only methods only. Errors reported by SPDK, e.g. already
+// exists, are never retried. 0 retries returns jsonRPC as is. jsonRPC must
+// report connection failures instead of exiting, as NewSpdkClient does,
+// since spdk.Client terminates the process when dialing SPDK fails
+func NewRetryingJSONRPC(jsonRPC spdk.JSONRPC, retries int, backoff time.Duration) (spdk.JSONRPC, error) {
+	if jsonRPC == nil {
+		log.Panic("nil for JSONRPC is not allowed")
+	}
+	if retries < 0 {
+		return nil, fmt.Errorf("SPDK retries %d cannot be negative", retries)
+	}
+	if backoff < 0 {
+		return nil, fmt.Errorf("SPDK retry backoff %v cannot be negative", backoff)
+	}
+	if retries == 0 {
+		return jsonRPC, nil
+	}
+	return &retryingJSONRPC{JSONRPC: jsonRPC, retries: retries, backoff: backoff}, nil
+}
+
+func (c *retryingJSONRPC) Call(ctx context.Context, method string, args, result interface{}) error {
+	delay := c.backoff
+	err := c.JSONRPC.Call(ctx, method, args, result)
+	for attempt := 1; attempt <= c.retries && isSpdkRetriable(method, err); attempt++ {
+		log.Printf("error: SPDK call %s failed, retry %d/%d in %v: %v", method, attempt, c.retries, delay, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+		err = c.JSONRPC.Call(ctx, method, args, result)
+	}
+	return err
+}
@@ -0,0 +1,351 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+)
+
+// flakyJSONRPC fails the first failures calls with err, as if connecting to
+// SPDK failed, and passes the following ones to SPDK
+type flakyJSONRPC struct {
+	spdk.JSONRPC
+	failures int
+	err      error
+	calls    int
+}
+
+func (r *flakyJSONRPC) Call(ctx context.Context, method string, args, result interface{}) error {
+	r.calls++
+	if r.calls <= r.failures {
+		return r.err
+	}
+	return r.JSONRPC.Call(ctx, method, args, result)
+}
+
+func TestRetryingJSONRPC_Call(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "unix", Err: syscall.ECONNREFUSED}
+	tests := map[string]struct {
+		method   string
+		retries  int
+		failures int
+		err      error
+		spdk     []string
+		calls    int
+		errMsg   string
+	}{
+		"dial failures retried": {
+			method:   "bdev_get_bdevs",
+			retries:  3,
+			failures: 2,
+			err:      dialErr,
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			calls:    3,
+			errMsg:   "",
+		},
+		"EOF retried": {
+			method:   "bdev_get_bdevs",
+			retries:  1,
+			failures: 1,
+			err:      fmt.Errorf("bdev_get_bdevs: %s", "EOF"),
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			calls:    2,
+			errMsg:   "",
+		},
+		"connection reset retried": {
+			method:   "bdev_get_bdevs",
+			retries:  1,
+			failures: 1,
+			err:      fmt.Errorf("bdev_get_bdevs: read unix @->/var/tmp/spdk.sock: %s", syscall.ECONNRESET),
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			calls:    2,
+			errMsg:   "",
+		},
+		"dial failures of create retried": {
+			method:   "bdev_malloc_create",
+			retries:  1,
+			failures: 1,
+			err:      dialErr,
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":true}`},
+			calls:    2,
+			errMsg:   "",
+		},
+		"EOF of create not retried": {
+			method:   "bdev_malloc_create",
+			retries:  3,
+			failures: 1,
+			err:      fmt.Errorf("bdev_malloc_create: %s", "EOF"),
+			spdk:     []string{},
+			calls:    1,
+			errMsg:   "bdev_malloc_create: EOF",
+		},
+		"connection reset of create not retried": {
+			method:   "bdev_malloc_create",
+			retries:  3,
+			failures: 1,
+			err:      fmt.Errorf("bdev_malloc_create: read unix @->/var/tmp/spdk.sock: %s", syscall.ECONNRESET),
+			spdk:     []string{},
+			calls:    1,
+			errMsg:   "bdev_malloc_create: read unix @->/var/tmp/spdk.sock: connection reset by peer",
+		},
+		"retries exhausted": {
+			method:   "bdev_get_bdevs",
+			retries:  2,
+			failures: 3,
+			err:      dialErr,
+			spdk:     []string{},
+			calls:    3,
+			errMsg:   dialErr.Error(),
+		},
+		"SPDK error not retried": {
+			method:   "bdev_malloc_create",
+			retries:  3,
+			failures: 1,
+			err:      errors.New("bdev_malloc_create: json response error: File exists"),
+			spdk:     []string{},
+			calls:    1,
+			errMsg:   "bdev_malloc_create: json response error: File exists",
+		},
+		"typed SPDK error not retried": {
+			method:   "bdev_get_bdevs",
+			retries:  3,
+			failures: 1,
+			err:      &SpdkError{Method: "bdev_get_bdevs", Code: -19, Message: "No such device"},
+			spdk:     []string{},
+			calls:    1,
+			errMsg:   "bdev_get_bdevs: json response error: No such device",
+		},
+		"SPDK error ending like connection error not retried": {
+			method:   "bdev_get_bdevs",
+			retries:  3,
+			failures: 1,
+			err:      errors.New("bdev_get_bdevs: json response error: EOF"),
+			spdk:     []string{},
+			calls:    1,
+			errMsg:   "bdev_get_bdevs: json response error: EOF",
+		},
+		"retry disabled": {
+			method:   "bdev_get_bdevs",
+			retries:  0,
+			failures: 1,
+			err:      dialErr,
+			spdk:     []string{},
+			calls:    1,
+			errMsg:   dialErr.Error(),
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testSocket := GenerateSocketName("utils")
+			ln, testJSONRPC := CreateTestSpdkServer(testSocket, tt.spdk)
+			defer func() {
+				CloseListener(ln)
+				if err := os.RemoveAll(testSocket); err != nil {
+					t.Error(err)
+				}
+			}()
+			flaky := &flakyJSONRPC{JSONRPC: testJSONRPC, failures: tt.failures, err: tt.err}
+			jsonRPC, err := NewRetryingJSONRPC(flaky, tt.retries, time.Millisecond)
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+
+			var result bool
+			err = jsonRPC.Call(context.Background(), tt.method, nil, &result)
+
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tt.errMsg {
+				t.Error("error: expected", tt.errMsg, "received", errMsg)
+			}
+			if tt.errMsg == "" && !result {
+				t.Error("expected result of successful retry")
+			}
+			if flaky.calls != tt.calls {
+				t.Error("calls: expected", tt.calls, "received", flaky.calls)
+			}
+		})
+	}
+}
+
+func TestRetryingJSONRPC_ContextDone(t *testing.T) {
+	flaky := &flakyJSONRPC{failures: 10, err: fmt.Errorf("bdev_get_bdevs: %s", "EOF")}
+	jsonRPC, err := NewRetryingJSONRPC(flaky, 10, time.Hour)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = jsonRPC.Call(ctx, "bdev_get_bdevs", nil, nil)
+
+	if err != flaky.err {
+		t.Error("error: expected", flaky.err, "received", err)
+	}
+	if flaky.calls != 1 {
+		t.Error("calls: expected 1, received", flaky.calls)
+	}
+}
+
+func TestNewRetryingJSONRPC(t *testing.T) {
+	tests := map[string]struct {
+		retries int
+		backoff time.Duration
+		errMsg  string
+	}{
+		"valid policy": {
+			retries: 3,
+			backoff: DefaultSpdkRetryBackoff,
+			errMsg:  "",
+		},
+		"negative retries": {
+			retries: -1,
+			backoff: DefaultSpdkRetryBackoff,
+			errMsg:  "SPDK retries -1 cannot be negative",
+		},
+		"negative backoff": {
+			retries: 3,
+			backoff: -time.Second,
+			errMsg:  "SPDK retry backoff -1s cannot be negative",
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			_, err := NewRetryingJSONRPC(&flakyJSONRPC{}, tt.retries, tt.backoff)
+
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tt.errMsg {
+				t.Error("error: expected", tt.errMsg, "received", errMsg)
+			}
+		})
+	}
+}
+
+// startRefusingSpdkServer listens on unix socket and drops the first
+// refusals connections without answering, as SPDK does while restarting,
+// and answers the following ones. It returns number of accepted connections
+func startRefusingSpdkServer(t *testing.T, socket string, refusals int32) *atomic.Int32 {
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	connections := &atomic.Int32{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if connections.Add(1) <= refusals {
+				_ = conn.Close()
+				continue
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				var request spdk.RPCRequest
+				if err := json.NewDecoder(conn).Decode(&request); err != nil {
+					return
+				}
+				fmt.Fprintf(conn, `{"id":%d,"error":{"code":0,"message":""},"result":true}`, request.ID)
+			}(conn)
+		}
+	}()
+	return connections
+}
+
+func TestRetryingJSONRPC_RefusedConnects(t *testing.T) {
+	tests := map[string]struct {
+		retries     int
+		refusals    int32
+		connections int32
+		success     bool
+	}{
+		"refused connects retried": {
+			retries:     3,
+			refusals:    2,
+			connections: 3,
+			success:     true,
+		},
+		"refused connects exhaust retries": {
+			retries:     2,
+			refusals:    5,
+			connections: 3,
+			success:     false,
+		},
+		"retry disabled": {
+			retries:     0,
+			refusals:    1,
+			connections: 1,
+			success:     false,
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testSocket := GenerateSocketName("utils")
+			connections := startRefusingSpdkServer(t, testSocket, tt.refusals)
+			jsonRPC, err := NewRetryingJSONRPC(NewSpdkClient(testSocket), tt.retries, time.Millisecond)
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+
+			var result bool
+			err = jsonRPC.Call(context.Background(), "bdev_get_bdevs", nil, &result)
+
+			if tt.success && (err != nil || !result) {
+				t.Error("expected result of successful retry, received", result, err)
+			}
+			if !tt.success && !isSpdkConnectionError(err) {
+				t.Error("expected connection error, received", err)
+			}
+			if n := connections.Load(); n != tt.connections {
+				t.Error("connections: expected", tt.connections, "received", n)
+			}
+		})
+	}
+}
+
+func TestRetryingJSONRPC_NoListener(t *testing.T) {
+	testSocket := GenerateSocketName("utils")
+	// socket file left behind by exited SPDK refuses connections
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: testSocket, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.SetUnlinkOnClose(false)
+	if err := ln.Close(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Remove(testSocket) })
+	jsonRPC, err := NewRetryingJSONRPC(NewSpdkClient(testSocket), 2, time.Millisecond)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	err = jsonRPC.Call(context.Background(), "bdev_get_bdevs", nil, nil)
+
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Error("expected", syscall.ECONNREFUSED, "received", err)
+	}
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/opiproject/gospdk/spdk"
+)
+
+// DefaultSpdkCleanupTimeout bounds SPDK calls undoing part of a request,
+// e.g. resuming a paused subsystem, when no SPDK timeout is configured
+const DefaultSpdkCleanupTimeout = 30 * time.Second
+
+var spdkCleanupTimeout = func() *atomic.Int64 {
+	timeout := &atomic.Int64{}
+	timeout.Store(int64(DefaultSpdkCleanupTimeout))
+	return timeout
+}()
+
+// SetSpdkCleanupTimeout sets timeout of SPDK calls made with CleanupContext
+func SetSpdkCleanupTimeout(timeout time.Duration) error {
+	if timeout <= 0 {
+		return fmt.Errorf("SPDK cleanup timeout must be positive, got %v", timeout)
+	}
+	spdkCleanupTimeout.Store(int64(timeout))
+	return nil
+}
+
+// detachedContext carries values of parent, e.g. dry run request metadata,
+// but neither its deadline nor its cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// CleanupContext returns context of SPDK calls undoing part of request ctx,
+// e.g. resuming a paused subsystem or rolling back created resources. They
+// must run even when ctx deadline passed or client canceled, otherwise
+// timeouts would leave the partial state they exist to prevent, so they are
+// bounded by the cleanup timeout instead
+func CleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(detachedContext{parent: ctx}, time.Duration(spdkCleanupTimeout.Load()))
+}
+
+type timeoutJSONRPC struct {
+	spdk.JSONRPC
+	timeout time.Duration
+}
+
+// NewTimeoutJSONRPC wraps jsonRPC so that every SPDK call is bounded by
+// timeout, or by the call context deadline if that is sooner. 0 timeout
+// bounds calls by the context deadline only. jsonRPC must honor the call
+// context, as NewSpdkClient does, while spdk.Client does not. SPDK may still
+// complete a timed out call, e.g. a timed out create may leave a bdev the
+// bridge does not track. Calls undoing a request should use CleanupContext
+func NewTimeoutJSONRPC(jsonRPC spdk.JSONRPC, timeout time.Duration) spdk.JSONRPC {
+	if jsonRPC == nil {
+		log.Panic("nil for JSONRPC is not allowed")
+	}
+	if timeout < 0 {
+		log.Panicf("SPDK timeout %v cannot be negative", timeout)
+	}
+	return &timeoutJSONRPC{JSONRPC: jsonRPC, timeout: timeout}
+}
+
+func (c *timeoutJSONRPC) GetVersion(ctx context.Context) string {
+	var ver spdk.GetVersionResult
+	err := c.Call(ctx, "spdk_get_version", nil, &ver)
+	if err != nil {
+		log.Printf("Could not get spdk version: %v", err)
+		return ""
+	}
+	log.Printf("Received from SPDK: %v", ver)
+	return ver.Version
+}
+
+func (c *timeoutJSONRPC) Call(ctx context.Context, method string, args, result interface{}) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	// do not send calls nobody waits for anymore
+	if err := ctx.Err(); err != nil {
+		return contextDoneError(err, fmt.Sprintf("%s: not sent to SPDK", method))
+	}
+	err := c.JSONRPC.Call(ctx, method, args, result)
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return contextDoneError(err, fmt.Sprintf("%s: SPDK did not respond", method))
+	}
+	return err
+}
+
+func contextDoneError(err error, reason string) error {
+	code, cause := codes.DeadlineExceeded, context.DeadlineExceeded
+	if errors.Is(err, context.Canceled) {
+		code, cause = codes.Canceled, context.Canceled
+	}
+	msg := fmt.Sprintf("%s: %v", reason, cause)
+	log.Print(msg)
+	return status.Errorf(code, msg)
+}
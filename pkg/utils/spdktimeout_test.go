@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// startSleepingSpdkServer answers every SPDK request on socket with version
+// after delay until the returned listener is closed
+func startSleepingSpdkServer(t *testing.T, socket string, delay time.Duration) (net.Listener, spdk.JSONRPC) {
+	ln := spdk.NewClient(socket).StartUnixListener()
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				var request spdk.RPCRequest
+				if err := json.NewDecoder(conn).Decode(&request); err != nil {
+					return
+				}
+				time.Sleep(delay)
+				fmt.Fprintf(conn, `{"id":%d,"error":{"code":0,"message":""},"result":{"version":"SPDK v20.10"}}`, request.ID)
+			}(conn)
+		}
+	}()
+	return ln, NewSpdkClient(socket)
+}
+
+func TestTimeoutJSONRPC(t *testing.T) {
+	tests := map[string]struct {
+		delay       time.Duration
+		timeout     time.Duration
+		ctxDeadline time.Duration
+		errCode     codes.Code
+		version     string
+	}{
+		"response within timeout": {
+			delay:       0,
+			timeout:     time.Second,
+			ctxDeadline: 0,
+			errCode:     codes.OK,
+			version:     "SPDK v20.10",
+		},
+		"response after timeout": {
+			delay:       time.Second,
+			timeout:     20 * time.Millisecond,
+			ctxDeadline: 0,
+			errCode:     codes.DeadlineExceeded,
+			version:     "",
+		},
+		"context deadline sooner than timeout": {
+			delay:       time.Second,
+			timeout:     time.Minute,
+			ctxDeadline: 20 * time.Millisecond,
+			errCode:     codes.DeadlineExceeded,
+			version:     "",
+		},
+		"no timeout bounded by context deadline": {
+			delay:       time.Second,
+			timeout:     0,
+			ctxDeadline: 20 * time.Millisecond,
+			errCode:     codes.DeadlineExceeded,
+			version:     "",
+		},
+		"no timeout and no context deadline": {
+			delay:       20 * time.Millisecond,
+			timeout:     0,
+			ctxDeadline: 0,
+			errCode:     codes.OK,
+			version:     "SPDK v20.10",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, spdkClient := startSleepingSpdkServer(t, GenerateSocketName("timeout"), tt.delay)
+			jsonRPC := NewTimeoutJSONRPC(spdkClient, tt.timeout)
+			ctx := context.Background()
+			if tt.ctxDeadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctxDeadline)
+				defer cancel()
+			}
+
+			var result spdk.GetVersionResult
+			start := time.Now()
+			err := jsonRPC.Call(ctx, "spdk_get_version", nil, &result)
+			if elapsed := time.Since(start); tt.errCode != codes.OK && elapsed >= tt.delay {
+				t.Error("expected call to return before SPDK responds, took", elapsed)
+			}
+
+			if er, _ := status.FromError(err); er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code(), err)
+			}
+			if result.Version != tt.version {
+				t.Error("version: expected", tt.version, "received", result.Version)
+			}
+		})
+	}
+}
+
+func TestTimeoutJSONRPC_GetVersion(t *testing.T) {
+	_, spdkClient := startSleepingSpdkServer(t, GenerateSocketName("timeout"), time.Second)
+	jsonRPC := NewTimeoutJSONRPC(spdkClient, 20*time.Millisecond)
+
+	if version := jsonRPC.GetVersion(context.Background()); version != "" {
+		t.Error("expected no version on timeout, received", version)
+	}
+}
+
+func TestNewTimeoutJSONRPC_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		jsonRPC spdk.JSONRPC
+		timeout time.Duration
+	}{
+		"nil JSONRPC":      {jsonRPC: nil, timeout: time.Second},
+		"negative timeout": {jsonRPC: spdk.NewClient("/dev/null"), timeout: -time.Second},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("expected panic")
+				}
+			}()
+			NewTimeoutJSONRPC(tt.jsonRPC, tt.timeout)
+		})
+	}
+}
+
+func TestTimeoutJSONRPC_ContextAlreadyDone(t *testing.T) {
+	flaky := &flakyJSONRPC{}
+	jsonRPC := NewTimeoutJSONRPC(flaky, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := jsonRPC.Call(ctx, "bdev_get_bdevs", nil, nil)
+
+	if status.Code(err) != codes.Canceled {
+		t.Error("error code: expected", codes.Canceled, "received", err)
+	}
+	if flaky.calls != 0 {
+		t.Error("expected no call sent to SPDK, received", flaky.calls)
+	}
+}
+
+type cleanupTestKey struct{}
+
+func TestCleanupContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), cleanupTestKey{}, "dry-run"))
+	cancel()
+
+	cleanupCtx, cleanupCancel := CleanupContext(ctx)
+	defer cleanupCancel()
+
+	if err := cleanupCtx.Err(); err != nil {
+		t.Error("expected cleanup context not done with request, received", err)
+	}
+	if value := cleanupCtx.Value(cleanupTestKey{}); value != "dry-run" {
+		t.Error("expected values of request context, received", value)
+	}
+	deadline, ok := cleanupCtx.Deadline()
+	if !ok || time.Until(deadline) > DefaultSpdkCleanupTimeout {
+		t.Error("expected deadline within", DefaultSpdkCleanupTimeout, "received", deadline, ok)
+	}
+}
+
+func TestSetSpdkCleanupTimeout(t *testing.T) {
+	t.Cleanup(func() { spdkCleanupTimeout.Store(int64(DefaultSpdkCleanupTimeout)) })
+
+	if err := SetSpdkCleanupTimeout(0); err == nil {
+		t.Error("expected error for zero timeout")
+	}
+	if err := SetSpdkCleanupTimeout(time.Second); err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	cleanupCtx, cancel := CleanupContext(context.Background())
+	defer cancel()
+	if deadline, _ := cleanupCtx.Deadline(); time.Until(deadline) > time.Second {
+		t.Error("expected deadline within 1s, received", deadline)
+	}
+}
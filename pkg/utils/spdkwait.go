@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+)
+
+const (
+	spdkWaitInitialDelay = 100 * time.Millisecond
+	spdkWaitMaxDelay     = 2 * time.Second
+)
+
+// WaitForSpdk verifies SPDK listening on unix socket address is reachable
+// and answers spdk_get_version. It retries with exponential backoff until
+// timeout expires, so SPDK starting concurrently has time to come up.
+// Zero timeout checks only once. TCP addresses are not checked
+func WaitForSpdk(ctx context.Context, jsonRPC spdk.JSONRPC, address string, timeout time.Duration) error {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return nil
+	}
+	deadline := time.Now().Add(timeout)
+	delay := spdkWaitInitialDelay
+	for {
+		err := checkSpdkSocket(ctx, jsonRPC, address)
+		if err == nil {
+			return nil
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			if timeout > 0 {
+				return fmt.Errorf("SPDK is not available after waiting %v: %w", timeout, err)
+			}
+			return fmt.Errorf("SPDK is not available: %w", err)
+		}
+		if delay > remaining {
+			delay = remaining
+		}
+		log.Printf("SPDK is not available yet, retrying in %v: %v", delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > spdkWaitMaxDelay {
+			delay = spdkWaitMaxDelay
+		}
+	}
+}
+
+func checkSpdkSocket(ctx context.Context, jsonRPC spdk.JSONRPC, address string) error {
+	if err := checkSpdkReachable(address); err != nil {
+		return err
+	}
+	if version := jsonRPC.GetVersion(ctx); version == "" {
+		return fmt.Errorf("no response to spdk_get_version on socket %s", address)
+	}
+	return nil
+}
+
+// checkSpdkReachable verifies SPDK address accepts connections. gospdk
+// terminates the process on dial errors, so it has to be checked before
+// calling SPDK whenever SPDK may be gone
+func checkSpdkReachable(address string) error {
+	network := "tcp"
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		network = "unix"
+		info, err := os.Stat(address)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("socket %s does not exist", address)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to access socket %s: %v", address, err)
+		}
+		if info.Mode()&fs.ModeSocket == 0 {
+			return fmt.Errorf("%s is not a unix socket", address)
+		}
+	}
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return fmt.Errorf("unable to connect to socket %s: %v", address, err)
+	}
+	if err := conn.Close(); err != nil {
+		log.Printf("error: failed to close connection to %s: %v", address, err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+)
+
+type versionJSONRPC struct {
+	spdk.JSONRPC
+	version string
+}
+
+func (r *versionJSONRPC) GetVersion(_ context.Context) string {
+	return r.version
+}
+
+func listenUnixSocket(t *testing.T, socket string) {
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+}
+
+func TestWaitForSpdk(t *testing.T) {
+	tests := map[string]struct {
+		version     string
+		timeout     time.Duration
+		listenAfter time.Duration
+		regularFile bool
+		tcp         bool
+		errMsg      string
+		minDuration time.Duration
+	}{
+		"spdk available": {
+			version:     "SPDK v24.01",
+			timeout:     0,
+			listenAfter: 0,
+			errMsg:      "",
+		},
+		"spdk comes up while waiting": {
+			version:     "SPDK v24.01",
+			timeout:     5 * time.Second,
+			listenAfter: 200 * time.Millisecond,
+			errMsg:      "",
+			minDuration: 200 * time.Millisecond,
+		},
+		"spdk never appears": {
+			version:     "SPDK v24.01",
+			timeout:     300 * time.Millisecond,
+			listenAfter: -1,
+			errMsg:      "SPDK is not available after waiting 300ms: socket %s does not exist",
+			minDuration: 300 * time.Millisecond,
+		},
+		"socket missing without waiting": {
+			version:     "SPDK v24.01",
+			timeout:     0,
+			listenAfter: -1,
+			errMsg:      "SPDK is not available: socket %s does not exist",
+		},
+		"not a socket": {
+			version:     "SPDK v24.01",
+			timeout:     0,
+			regularFile: true,
+			errMsg:      "SPDK is not available: %s is not a unix socket",
+		},
+		"spdk does not answer": {
+			version:     "",
+			timeout:     0,
+			listenAfter: 0,
+			errMsg:      "SPDK is not available: no response to spdk_get_version on socket %s",
+		},
+		"tcp address is not checked": {
+			version: "",
+			timeout: 0,
+			tcp:     true,
+			errMsg:  "",
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			address := filepath.Join(t.TempDir(), "spdk.sock")
+			switch {
+			case tt.tcp:
+				address = "127.0.0.1:1"
+			case tt.regularFile:
+				if err := os.WriteFile(address, []byte{}, 0600); err != nil {
+					t.Fatal(err)
+				}
+			case tt.listenAfter == 0:
+				listenUnixSocket(t, address)
+			case tt.listenAfter > 0:
+				timer := time.AfterFunc(tt.listenAfter, func() { listenUnixSocket(t, address) })
+				t.Cleanup(func() { timer.Stop() })
+			}
+
+			start := time.Now()
+			err := WaitForSpdk(context.Background(), &versionJSONRPC{version: tt.version}, address, tt.timeout)
+			elapsed := time.Since(start)
+
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			wantMsg := tt.errMsg
+			if wantMsg != "" {
+				wantMsg = fmt.Sprintf(wantMsg, address)
+			}
+			if errMsg != wantMsg {
+				t.Error("error: expected", wantMsg, "received", errMsg)
+			}
+			if elapsed < tt.minDuration {
+				t.Error("expected to wait at least", tt.minDuration, "waited", elapsed)
+			}
+		})
+	}
+}
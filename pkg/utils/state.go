@@ -0,0 +1,293 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// StateServiceName is full name of the service exporting resources tracked
+// by the bridge. It is not part of OPI API, so it is registered with a hand
+// written service descriptor
+const StateServiceName = "opi_spdk_bridge.v1.StateService"
+
+// StateServicePrefix is prefix of full method names of state service
+const StateServicePrefix = "/" + StateServiceName + "/"
+
+// StateResource exports and imports resources of one type as JSON
+type StateResource struct {
+	Export func() ([]json.RawMessage, error)
+	// Import decodes resources and returns function storing them, so that
+	// nothing is stored unless resources of all types decode
+	Import func([]json.RawMessage) (func(), error)
+}
+
+// StateHolder provides resources it holds per resource type, keys are the
+// same as of ResourceCounts
+type StateHolder interface {
+	StateResources() map[string]StateResource
+}
+
+// namedProto is a resource keyed by its name
+type namedProto interface {
+	proto.Message
+	GetName() string
+}
+
+// ProtoStateResource exports and imports resources of map keyed by resource
+// names in protobuf JSON format. Map is read and written under mu
+func ProtoStateResource[T namedProto](mu *sync.RWMutex, resources map[string]T) StateResource {
+	return StateResource{
+		Export: func() ([]json.RawMessage, error) {
+			mu.RLock()
+			defer mu.RUnlock()
+			names := make([]string, 0, len(resources))
+			for name := range resources {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			exported := make([]json.RawMessage, 0, len(names))
+			for _, name := range names {
+				data, err := protojson.Marshal(resources[name])
+				if err != nil {
+					return nil, err
+				}
+				exported = append(exported, data)
+			}
+			return exported, nil
+		},
+		Import: func(exported []json.RawMessage) (func(), error) {
+			var zero T
+			decoded := make([]T, 0, len(exported))
+			for _, data := range exported {
+				resource := zero.ProtoReflect().New().Interface().(T)
+				if err := protojson.Unmarshal(data, resource); err != nil {
+					return nil, err
+				}
+				if resource.GetName() == "" {
+					return nil, fmt.Errorf("missing name of %s", data)
+				}
+				decoded = append(decoded, resource)
+			}
+			return func() {
+				mu.Lock()
+				defer mu.Unlock()
+				for _, resource := range decoded {
+					resources[resource.GetName()] = resource
+				}
+			}, nil
+		},
+	}
+}
+
+// namedJSON is a pointer to resource keyed by its name, which is not a
+// protobuf message, e.g. resources served by hand written services
+type namedJSON[T any] interface {
+	*T
+	GetName() string
+}
+
+// JSONStateResource exports and imports resources of map keyed by resource
+// names in JSON format. Map is read and written under mu
+func JSONStateResource[T any, P namedJSON[T]](mu *sync.RWMutex, resources map[string]P) StateResource {
+	return StateResource{
+		Export: func() ([]json.RawMessage, error) {
+			mu.RLock()
+			defer mu.RUnlock()
+			names := make([]string, 0, len(resources))
+			for name := range resources {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			exported := make([]json.RawMessage, 0, len(names))
+			for _, name := range names {
+				data, err := json.Marshal(resources[name])
+				if err != nil {
+					return nil, err
+				}
+				exported = append(exported, data)
+			}
+			return exported, nil
+		},
+		Import: func(exported []json.RawMessage) (func(), error) {
+			decoded := make([]P, 0, len(exported))
+			for _, data := range exported {
+				resource := P(new(T))
+				if err := json.Unmarshal(data, resource); err != nil {
+					return nil, err
+				}
+				if resource.GetName() == "" {
+					return nil, fmt.Errorf("missing name of %s", data)
+				}
+				decoded = append(decoded, resource)
+			}
+			return func() {
+				mu.Lock()
+				defer mu.Unlock()
+				for _, resource := range decoded {
+					resources[resource.GetName()] = resource
+				}
+			}, nil
+		},
+	}
+}
+
+// StateServer exports resources of all holders as a single JSON document
+// and imports it into a fresh instance for disaster recovery. Import does
+// not call SPDK, it expects SPDK to already hold the resources. Exported
+// state contains secrets, such as keys of encrypted volumes. Data kept only
+// in the gokv store, e.g. annotations and TTL expiry, is neither exported
+// nor imported, so the store has to be preserved alongside the document
+type StateServer struct {
+	holders []StateHolder
+}
+
+// NewStateServer creates state server over provided holders
+func NewStateServer(holders ...StateHolder) *StateServer {
+	for _, holder := range holders {
+		if holder == nil {
+			log.Panic("nil for StateHolder is not allowed")
+		}
+	}
+	return &StateServer{holders: holders}
+}
+
+func (s *StateServer) resources() map[string]StateResource {
+	resources := make(map[string]StateResource)
+	for _, holder := range s.holders {
+		for resourceType, resource := range holder.StateResources() {
+			resources[resourceType] = resource
+		}
+	}
+	return resources
+}
+
+// ExportState returns all resources per resource type, e.g.
+// {"nvme_subsystems": [...], "null_volumes": [...]}
+func (s *StateServer) ExportState(_ context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	state := make(map[string][]json.RawMessage)
+	for resourceType, resource := range s.resources() {
+		exported, err := resource.Export()
+		if err != nil {
+			msg := fmt.Sprintf("Could not export %s: %v", resourceType, err)
+			return nil, status.Errorf(codes.Internal, msg)
+		}
+		state[resourceType] = exported
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	response := &structpb.Struct{}
+	if err := response.UnmarshalJSON(data); err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	return response, nil
+}
+
+// ImportState stores resources of document returned by ExportState without
+// calling SPDK. Resources with names already present are replaced. Holders
+// do not lock their resources for import, so it must complete before the
+// server starts serving requests and background tasks; it is therefore not
+// exposed as a gRPC method
+func (s *StateServer) ImportState(_ context.Context, in *structpb.Struct) (*emptypb.Empty, error) {
+	data, err := in.MarshalJSON()
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	var state map[string][]json.RawMessage
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	resources := s.resources()
+	stores := make([]func(), 0, len(state))
+	for resourceType, exported := range state {
+		resource, ok := resources[resourceType]
+		if !ok {
+			msg := fmt.Sprintf("unknown resource type %s", resourceType)
+			return nil, status.Errorf(codes.InvalidArgument, msg)
+		}
+		store, err := resource.Import(exported)
+		if err != nil {
+			msg := fmt.Sprintf("invalid %s: %v", resourceType, err)
+			return nil, status.Errorf(codes.InvalidArgument, msg)
+		}
+		stores = append(stores, store)
+	}
+	for _, store := range stores {
+		store()
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ImportStateFile imports document returned by ExportState from file at
+// path, see ImportState
+func (s *StateServer) ImportStateFile(path string) error {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	in := &structpb.Struct{}
+	if err := in.UnmarshalJSON(data); err != nil {
+		return fmt.Errorf("invalid state document %s: %w", path, err)
+	}
+	_, err = s.ImportState(context.Background(), in)
+	return err
+}
+
+type stateServiceServer interface {
+	ExportState(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+}
+
+var stateServiceDesc = grpc.ServiceDesc{
+	ServiceName: StateServiceName,
+	HandlerType: (*stateServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ExportState",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(emptypb.Empty)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(stateServiceServer).ExportState(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: StateServicePrefix + "ExportState",
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(stateServiceServer).ExportState(ctx, req.(*emptypb.Empty))
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterStateServer registers state service on s. Exported state carries
+// keys, so payloads of the service are never logged. Callers must register
+// it only when CheckAdminRestricted succeeds
+func RegisterStateServer(s *grpc.Server, srv *StateServer) {
+	OmitLoggedPayloads(StateServiceName)
+	s.RegisterService(&stateServiceDesc, srv)
+}
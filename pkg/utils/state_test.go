@@ -0,0 +1,227 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type fakeStateHolder struct {
+	mu          sync.RWMutex
+	nullVolumes map[string]*pb.NullVolume
+	subsystems  map[string]*pb.NvmeSubsystem
+}
+
+func (h *fakeStateHolder) StateResources() map[string]StateResource {
+	return map[string]StateResource{
+		"null_volumes":    ProtoStateResource(&h.mu, h.nullVolumes),
+		"nvme_subsystems": ProtoStateResource(&h.mu, h.subsystems),
+	}
+}
+
+func newFakeStateHolder() *fakeStateHolder {
+	return &fakeStateHolder{
+		nullVolumes: make(map[string]*pb.NullVolume),
+		subsystems:  make(map[string]*pb.NvmeSubsystem),
+	}
+}
+
+func TestStateServer_ImportState(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		errCode codes.Code
+		errMsg  string
+		stored  int
+	}{
+		"unknown resource type": {
+			in:      `{"null_volumes":[{"name":"volumes/null0"}],"unknown_volumes":[]}`,
+			errCode: codes.InvalidArgument,
+			errMsg:  "unknown resource type unknown_volumes",
+			stored:  0,
+		},
+		"missing name": {
+			in:      `{"null_volumes":[{"name":"volumes/null0"}],"nvme_subsystems":[{"spec":{"nqn":"nqn.2022-09.io.spdk:opi3"}}]}`,
+			errCode: codes.InvalidArgument,
+			errMsg:  `invalid nvme_subsystems: missing name of {"spec":{"nqn":"nqn.2022-09.io.spdk:opi3"}}`,
+			stored:  0,
+		},
+		"valid state": {
+			in:      `{"null_volumes":[{"name":"volumes/null0"}],"nvme_subsystems":[{"name":"nvmeSubsystems/subsys0"}]}`,
+			errCode: codes.OK,
+			errMsg:  "",
+			stored:  2,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			holder := newFakeStateHolder()
+			in := &structpb.Struct{}
+			if err := in.UnmarshalJSON([]byte(tt.in)); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := NewStateServer(holder).ImportState(context.Background(), in)
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			if stored := len(holder.nullVolumes) + len(holder.subsystems); stored != tt.stored {
+				t.Error("stored: expected", tt.stored, "received", stored)
+			}
+		})
+	}
+}
+
+func TestStateServer_ImportStateFile(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "state.json")
+	if err := os.WriteFile(valid, []byte(`{"null_volumes":[{"name":"volumes/null0"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	malformed := filepath.Join(dir, "malformed.json")
+	if err := os.WriteFile(malformed, []byte(`{"null_volumes":`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		path    string
+		wantErr bool
+		stored  int
+	}{
+		"valid document":     {path: valid, wantErr: false, stored: 1},
+		"malformed document": {path: malformed, wantErr: true, stored: 0},
+		"missing file":       {path: filepath.Join(dir, "missing.json"), wantErr: true, stored: 0},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			holder := newFakeStateHolder()
+
+			err := NewStateServer(holder).ImportStateFile(tt.path)
+
+			if (err != nil) != tt.wantErr {
+				t.Error("expected error", tt.wantErr, "received", err)
+			}
+			if stored := len(holder.nullVolumes); stored != tt.stored {
+				t.Error("stored: expected", tt.stored, "received", stored)
+			}
+		})
+	}
+}
+
+func TestStateServer_ExportState(t *testing.T) {
+	backend := newFakeStateHolder()
+	backend.nullVolumes["volumes/null1"] = &pb.NullVolume{Name: "volumes/null1", BlockSize: 512}
+	backend.nullVolumes["volumes/null0"] = &pb.NullVolume{Name: "volumes/null0", BlockSize: 4096}
+
+	state, err := NewStateServer(backend).ExportState(context.Background(), &emptypb.Empty{})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	expected := &structpb.Struct{}
+	if err := expected.UnmarshalJSON([]byte(`{"null_volumes":[{"name":"volumes/null0","blockSize":"4096"},{"name":"volumes/null1","blockSize":"512"}],"nvme_subsystems":[]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(state, expected) {
+		t.Error("state: expected", expected, "received", state)
+	}
+}
+
+func TestStateServer_ExportStateConcurrentWrites(t *testing.T) {
+	backend := newFakeStateHolder()
+	server := NewStateServer(backend)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			name := fmt.Sprintf("volumes/null%d", i)
+			backend.mu.Lock()
+			backend.nullVolumes[name] = &pb.NullVolume{Name: name, BlockSize: 512}
+			backend.mu.Unlock()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if _, err := server.ExportState(context.Background(), &emptypb.Empty{}); err != nil {
+			t.Fatal("expected no error, received", err)
+		}
+	}
+	<-done
+}
+
+func TestNewStateServer_NilHolder(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic for nil holder")
+		}
+	}()
+	NewStateServer(newFakeStateHolder(), nil)
+}
+
+func TestRegisterStateServer(t *testing.T) {
+	s := grpc.NewServer()
+	RegisterStateServer(s, NewStateServer())
+
+	info, ok := s.GetServiceInfo()[StateServiceName]
+	if !ok {
+		t.Fatal("expected", StateServiceName, "to be registered")
+	}
+	methods := make([]string, 0, len(info.Methods))
+	for _, method := range info.Methods {
+		methods = append(methods, method.Name)
+	}
+	sort.Strings(methods)
+	if expected := []string{"ExportState"}; !reflect.DeepEqual(methods, expected) {
+		t.Error("methods: expected", expected, "received", methods)
+	}
+}
+
+func TestRegisterStateServer_PayloadsNotLogged(t *testing.T) {
+	RegisterStateServer(grpc.NewServer(), NewStateServer())
+	exported, err := structpb.NewStruct(map[string]interface{}{
+		"encrypted_volumes": []interface{}{map[string]interface{}{"key": "secret-key"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	logger := InterceptorLogger(log.New(&buf, "", 0))
+
+	logger.Log(context.Background(), logging.LevelInfo, "finished call",
+		logging.ServiceFieldKey, StateServiceName, "grpc.response.content", exported)
+
+	if output := buf.String(); strings.Contains(output, "secret-key") || !strings.Contains(output, RedactedPlaceholder) {
+		t.Error("expected state payload to be omitted, received", output)
+	}
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+)
+
+// StatsCollector coalesces all stats consumers into a single periodic
+// bdev_get_iostat call and fans the result out to every subscriber, so
+// the number of SPDK calls does not grow with the number of consumers
+type StatsCollector struct {
+	rpc      spdk.JSONRPC
+	interval time.Duration
+
+	// pollMu serializes polls, so that concurrent Iostat calls share one
+	pollMu sync.Mutex
+
+	mu          sync.Mutex
+	subscribers map[chan *spdk.BdevGetIostatResult]struct{}
+	stop        context.CancelFunc
+	latest      *spdk.BdevGetIostatResult
+	polled      time.Time
+}
+
+// NewStatsCollector creates a collector polling SPDK every interval
+// while there is at least one subscriber
+func NewStatsCollector(jsonRPC spdk.JSONRPC, interval time.Duration) *StatsCollector {
+	if jsonRPC == nil {
+		log.Panic("nil for JSONRPC is not allowed")
+	}
+	if interval <= 0 {
+		log.Panicf("stats interval has to be positive, got %v", interval)
+	}
+	return &StatsCollector{
+		rpc:         jsonRPC,
+		interval:    interval,
+		subscribers: make(map[chan *spdk.BdevGetIostatResult]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving stats of all bdevs on every poll and
+// a function to cancel the subscription. Results are shared between
+// subscribers and must not be modified. A subscriber which falls behind
+// receives only the latest result
+func (c *StatsCollector) Subscribe() (<-chan *spdk.BdevGetIostatResult, func()) {
+	ch := make(chan *spdk.BdevGetIostatResult, 1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribers[ch] = struct{}{}
+	if c.stop == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		c.stop = cancel
+		go c.run(ctx)
+	}
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() { c.unsubscribe(ch) })
+	}
+}
+
+// Iostat returns stats of all bdevs polled less than interval ago, polling
+// SPDK only if the latest result is older, so that stats requests cost at
+// most one SPDK call per interval. The result is shared and must not be
+// modified
+func (c *StatsCollector) Iostat(ctx context.Context) (*spdk.BdevGetIostatResult, error) {
+	c.pollMu.Lock()
+	defer c.pollMu.Unlock()
+	c.mu.Lock()
+	latest, polled := c.latest, c.polled
+	c.mu.Unlock()
+	if latest != nil && time.Since(polled) < c.interval {
+		return latest, nil
+	}
+	return c.poll(ctx)
+}
+
+func (c *StatsCollector) unsubscribe(ch chan *spdk.BdevGetIostatResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.subscribers, ch)
+	close(ch)
+	if len(c.subscribers) == 0 && c.stop != nil {
+		c.stop()
+		c.stop = nil
+	}
+}
+
+func (c *StatsCollector) run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.pollMu.Lock()
+			_, err := c.poll(ctx)
+			c.pollMu.Unlock()
+			if err != nil {
+				log.Printf("error: failed to collect stats: %v", err)
+			}
+		}
+	}
+}
+
+// poll calls SPDK and fans the result out to subscribers, pollMu must be
+// held
+func (c *StatsCollector) poll(ctx context.Context) (*spdk.BdevGetIostatResult, error) {
+	var result spdk.BdevGetIostatResult
+	if err := c.rpc.Call(ctx, "bdev_get_iostat", nil, &result); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latest, c.polled = &result, time.Now()
+	for ch := range c.subscribers {
+		// drop previous result not consumed yet by a slow subscriber
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- &result
+	}
+	return &result, nil
+}
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+)
+
+// countingIostatJSONRPC answers bdev_get_iostat with a tick rate equal to
+// the number of calls made so far
+type countingIostatJSONRPC struct {
+	spdk.JSONRPC
+	mu    sync.Mutex
+	calls int
+}
+
+func (r *countingIostatJSONRPC) Call(_ context.Context, method string, _, result interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if method != "bdev_get_iostat" {
+		panic("unexpected method " + method)
+	}
+	r.calls++
+	result.(*spdk.BdevGetIostatResult).TickRate = r.calls
+	return nil
+}
+
+func (r *countingIostatJSONRPC) numberOfCalls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+func TestStatsCollector_SinglePollFeedsAllSubscribers(t *testing.T) {
+	rpc := &countingIostatJSONRPC{}
+	collector := NewStatsCollector(rpc, time.Hour)
+
+	var subscriptions []<-chan *spdk.BdevGetIostatResult
+	for i := 0; i < 3; i++ {
+		ch, unsubscribe := collector.Subscribe()
+		defer unsubscribe()
+		subscriptions = append(subscriptions, ch)
+	}
+
+	for poll := 1; poll <= 2; poll++ {
+		collector.poll(context.Background())
+		if rpc.numberOfCalls() != poll {
+			t.Fatal("SPDK calls: expected", poll, "received", rpc.numberOfCalls())
+		}
+		for i, ch := range subscriptions {
+			result := <-ch
+			if result.TickRate != poll {
+				t.Error("subscriber", i, "expected result of poll", poll, "received", result.TickRate)
+			}
+		}
+	}
+}
+
+func TestStatsCollector_SlowSubscriberReceivesLatest(t *testing.T) {
+	rpc := &countingIostatJSONRPC{}
+	collector := NewStatsCollector(rpc, time.Hour)
+	ch, unsubscribe := collector.Subscribe()
+	defer unsubscribe()
+
+	collector.poll(context.Background())
+	collector.poll(context.Background())
+
+	if result := <-ch; result.TickRate != 2 {
+		t.Error("expected latest result of poll", 2, "received", result.TickRate)
+	}
+	select {
+	case result := <-ch:
+		t.Error("expected no stale result, received", result.TickRate)
+	default:
+	}
+}
+
+func TestStatsCollector_PollsOnlyWhileSubscribed(t *testing.T) {
+	rpc := &countingIostatJSONRPC{}
+	collector := NewStatsCollector(rpc, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if rpc.numberOfCalls() != 0 {
+		t.Fatal("expected no SPDK calls without subscribers, received", rpc.numberOfCalls())
+	}
+
+	first, unsubscribeFirst := collector.Subscribe()
+	second, unsubscribeSecond := collector.Subscribe()
+	for i := 0; i < 3; i++ {
+		<-first
+		<-second
+	}
+	unsubscribeFirst()
+	unsubscribeSecond()
+	unsubscribeSecond()
+
+	if _, ok := <-first; ok {
+		t.Error("expected channel to be closed after unsubscribe")
+	}
+	calls := rpc.numberOfCalls()
+	time.Sleep(20 * time.Millisecond)
+	if rpc.numberOfCalls() > calls+1 {
+		t.Error("expected polling to stop after last unsubscribe, calls grew from", calls, "to", rpc.numberOfCalls())
+	}
+}
+
+func TestStatsCollector_Iostat(t *testing.T) {
+	rpc := &countingIostatJSONRPC{}
+	collector := NewStatsCollector(rpc, 50*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if result, err := collector.Iostat(context.Background()); err != nil || result.TickRate != 1 {
+				t.Error("expected result of the first poll, received", result, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if rpc.numberOfCalls() != 1 {
+		t.Error("SPDK calls within interval: expected", 1, "received", rpc.numberOfCalls())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if result, err := collector.Iostat(context.Background()); err != nil || result.TickRate != 2 {
+		t.Error("expected result of a new poll after interval, received", result, err)
+	}
+}
+
+func TestStatsCollector_NewStatsCollector(t *testing.T) {
+	tests := map[string]struct {
+		jsonRPC   spdk.JSONRPC
+		interval  time.Duration
+		wantPanic bool
+	}{
+		"nil json rpc": {
+			jsonRPC:   nil,
+			interval:  time.Second,
+			wantPanic: true,
+		},
+		"zero interval": {
+			jsonRPC:   &countingIostatJSONRPC{},
+			interval:  0,
+			wantPanic: true,
+		},
+		"valid arguments": {
+			jsonRPC:   &countingIostatJSONRPC{},
+			interval:  time.Second,
+			wantPanic: false,
+		},
+	}
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if (r != nil) != tt.wantPanic {
+					t.Errorf("NewStatsCollector() recover = %v, wantPanic = %v", r, tt.wantPanic)
+				}
+			}()
+
+			if collector := NewStatsCollector(tt.jsonRPC, tt.interval); collector == nil {
+				t.Error("expected non nil collector")
+			}
+		})
+	}
+}
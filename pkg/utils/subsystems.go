@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/opiproject/gospdk/spdk"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// SpdkSubsystemServiceName is full name of the service reporting loaded
+// SPDK subsystems. It is not part of OPI API, so it is registered with a
+// hand written service descriptor
+const SpdkSubsystemServiceName = "opi_spdk_bridge.v1.SpdkSubsystemService"
+
+// RequiredSpdkSubsystems lists SPDK subsystems used by the bridge services
+var RequiredSpdkSubsystems = []string{"accel", "bdev", "nvmf", "vhost_blk", "vhost_scsi"}
+
+// spdkNotInitializedError is part of error SPDK returns for runtime RPCs
+// while it waits for framework_start_init
+const spdkNotInitializedError = "framework_start_init"
+
+// frameworkGetSubsystemsResult is result of framework_get_subsystems
+// TODO: use spdk.FrameworkGetSubsystemsResult when gospdk provides it
+type frameworkGetSubsystemsResult []struct {
+	Subsystem string   `json:"subsystem"`
+	DependsOn []string `json:"depends_on"`
+}
+
+// SpdkSubsystem is SPDK subsystem with subsystems it depends on
+type SpdkSubsystem struct {
+	Name      string   `json:"name"`
+	DependsOn []string `json:"depends_on"`
+}
+
+// SpdkSubsystems contains SPDK subsystems in their initialization order
+// and required subsystems SPDK was built or started without
+type SpdkSubsystems struct {
+	// Initialized is false while SPDK waits for framework_start_init,
+	// subsystems are not reported then
+	Initialized bool            `json:"initialized"`
+	Subsystems  []SpdkSubsystem `json:"subsystems"`
+	Missing     []string        `json:"missing"`
+}
+
+// SubsystemsServer reports SPDK subsystems, e.g. to diagnose vhost not
+// being loaded before creating virtio-blk controllers
+type SubsystemsServer struct {
+	rpc spdk.JSONRPC
+}
+
+// NewSubsystemsServer creates SPDK subsystems server communicating with
+// provided jsonRPC
+func NewSubsystemsServer(jsonRPC spdk.JSONRPC) *SubsystemsServer {
+	if jsonRPC == nil {
+		log.Panic("nil for JSONRPC is not allowed")
+	}
+	return &SubsystemsServer{rpc: jsonRPC}
+}
+
+// Subsystems returns SPDK subsystems and required subsystems missing
+func (s *SubsystemsServer) Subsystems(ctx context.Context) (*SpdkSubsystems, error) {
+	var result frameworkGetSubsystemsResult
+	err := s.rpc.Call(ctx, "framework_get_subsystems", nil, &result)
+	if err != nil {
+		if strings.Contains(err.Error(), spdkNotInitializedError) {
+			log.Printf("SPDK framework is not initialized: %v", err)
+			return &SpdkSubsystems{
+				Initialized: false,
+				Subsystems:  []SpdkSubsystem{},
+				Missing:     []string{},
+			}, nil
+		}
+		return nil, err
+	}
+	log.Printf("Received from SPDK: %v", result)
+	subsystems := &SpdkSubsystems{
+		Initialized: true,
+		Subsystems:  make([]SpdkSubsystem, 0, len(result)),
+		Missing:     []string{},
+	}
+	loaded := make(map[string]bool, len(result))
+	for _, subsystem := range result {
+		dependsOn := subsystem.DependsOn
+		if dependsOn == nil {
+			dependsOn = []string{}
+		}
+		subsystems.Subsystems = append(subsystems.Subsystems, SpdkSubsystem{
+			Name:      subsystem.Subsystem,
+			DependsOn: dependsOn,
+		})
+		loaded[subsystem.Subsystem] = true
+	}
+	for _, name := range RequiredSpdkSubsystems {
+		if !loaded[name] {
+			subsystems.Missing = append(subsystems.Missing, name)
+		}
+	}
+	return subsystems, nil
+}
+
+// GetSpdkSubsystems returns SpdkSubsystems as a struct
+func (s *SubsystemsServer) GetSpdkSubsystems(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	subsystems, err := s.Subsystems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(subsystems)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	response := &structpb.Struct{}
+	if err := response.UnmarshalJSON(data); err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	return response, nil
+}
+
+// subsystemsServiceServer is implemented by SubsystemsServer
+type subsystemsServiceServer interface {
+	GetSpdkSubsystems(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+}
+
+var subsystemsServiceDesc = grpc.ServiceDesc{
+	ServiceName: SpdkSubsystemServiceName,
+	HandlerType: (*subsystemsServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetSpdkSubsystems",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(emptypb.Empty)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(subsystemsServiceServer).GetSpdkSubsystems(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: "/" + SpdkSubsystemServiceName + "/GetSpdkSubsystems",
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(subsystemsServiceServer).GetSpdkSubsystems(ctx, req.(*emptypb.Empty))
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterSubsystemsServer registers SPDK subsystems service on s
+func RegisterSubsystemsServer(s *grpc.Server, srv *SubsystemsServer) {
+	s.RegisterService(&subsystemsServiceDesc, srv)
+}
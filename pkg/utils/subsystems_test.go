@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// testSpdkSubsystems is framework_get_subsystems result of SPDK nvmf_tgt
+// started without vhost
+const testSpdkSubsystems = `[
+	{"subsystem":"keyring","depends_on":[]},
+	{"subsystem":"iobuf","depends_on":[]},
+	{"subsystem":"accel","depends_on":["iobuf"]},
+	{"subsystem":"sock","depends_on":[]},
+	{"subsystem":"vmd","depends_on":[]},
+	{"subsystem":"bdev","depends_on":["accel","vmd","sock","iobuf","keyring"]},
+	{"subsystem":"nvmf","depends_on":["bdev","sock","keyring"]}
+]`
+
+func TestSubsystemsServer_Subsystems(t *testing.T) {
+	tests := map[string]struct {
+		spdk    []string
+		out     *SpdkSubsystems
+		errCode codes.Code
+		errMsg  string
+	}{
+		"nvmf target without vhost": {
+			spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":` + testSpdkSubsystems + `}`},
+			out: &SpdkSubsystems{
+				Initialized: true,
+				Subsystems: []SpdkSubsystem{
+					{Name: "keyring", DependsOn: []string{}},
+					{Name: "iobuf", DependsOn: []string{}},
+					{Name: "accel", DependsOn: []string{"iobuf"}},
+					{Name: "sock", DependsOn: []string{}},
+					{Name: "vmd", DependsOn: []string{}},
+					{Name: "bdev", DependsOn: []string{"accel", "vmd", "sock", "iobuf", "keyring"}},
+					{Name: "nvmf", DependsOn: []string{"bdev", "sock", "keyring"}},
+				},
+				Missing: []string{"vhost_blk", "vhost_scsi"},
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"all required subsystems": {
+			spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":[{"subsystem":"accel"},{"subsystem":"bdev"},{"subsystem":"nvmf"},{"subsystem":"vhost_blk"},{"subsystem":"vhost_scsi"}]}`},
+			out: &SpdkSubsystems{
+				Initialized: true,
+				Subsystems: []SpdkSubsystem{
+					{Name: "accel", DependsOn: []string{}},
+					{Name: "bdev", DependsOn: []string{}},
+					{Name: "nvmf", DependsOn: []string{}},
+					{Name: "vhost_blk", DependsOn: []string{}},
+					{Name: "vhost_scsi", DependsOn: []string{}},
+				},
+				Missing: []string{},
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"framework not initialized": {
+			spdk: []string{`{"id":%d,"error":{"code":-32601,"message":"Method may only be called after framework is initialized using framework_start_init RPC."},"result":null}`},
+			out: &SpdkSubsystems{
+				Initialized: false,
+				Subsystems:  []SpdkSubsystem{},
+				Missing:     []string{},
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"error from SPDK": {
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":null}`},
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("framework_get_subsystems: %v", "json response error: myopierr"),
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testSocket := GenerateSocketName("utils")
+			ln, jsonRPC := CreateTestSpdkServer(testSocket, tt.spdk)
+			defer func() {
+				CloseListener(ln)
+				if err := os.RemoveAll(testSocket); err != nil {
+					t.Error(err)
+				}
+			}()
+
+			subsystems, err := NewSubsystemsServer(jsonRPC).Subsystems(context.Background())
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if !reflect.DeepEqual(subsystems, tt.out) {
+				t.Error("subsystems: expected", tt.out, "received", subsystems)
+			}
+		})
+	}
+}
+
+func TestSubsystemsServer_GetSpdkSubsystems(t *testing.T) {
+	testSocket := GenerateSocketName("utils")
+	ln, jsonRPC := CreateTestSpdkServer(testSocket, []string{`{"id":%d,"error":{"code":0,"message":""},"result":` + testSpdkSubsystems + `}`})
+	defer func() {
+		CloseListener(ln)
+		if err := os.RemoveAll(testSocket); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	response, err := NewSubsystemsServer(jsonRPC).GetSpdkSubsystems(context.Background(), &emptypb.Empty{})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if !response.GetFields()["initialized"].GetBoolValue() {
+		t.Error("initialized: expected true, received", response.GetFields()["initialized"])
+	}
+	if subsystems := response.GetFields()["subsystems"].GetListValue().GetValues(); len(subsystems) != 7 {
+		t.Error("subsystems: expected 7, received", subsystems)
+	}
+	if missing := response.GetFields()["missing"].GetListValue().AsSlice(); !reflect.DeepEqual(missing, []interface{}{"vhost_blk", "vhost_scsi"}) {
+		t.Error("missing: expected [vhost_blk vhost_scsi], received", missing)
+	}
+}
+
+func TestRegisterSubsystemsServer(t *testing.T) {
+	s := grpc.NewServer()
+	RegisterSubsystemsServer(s, NewSubsystemsServer(&memStatsJSONRPC{}))
+
+	info, ok := s.GetServiceInfo()[SpdkSubsystemServiceName]
+	if !ok {
+		t.Fatal("expected", SpdkSubsystemServiceName, "to be registered")
+	}
+	if len(info.Methods) != 1 || info.Methods[0].Name != "GetSpdkSubsystems" {
+		t.Error("methods: expected [GetSpdkSubsystems], received", info.Methods)
+	}
+}
@@ -12,11 +12,37 @@ import (
 	"log"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
 
+// serverTLSConfig is TLS configuration served to new gRPC connections
+var serverTLSConfig atomic.Pointer[tls.Config]
+
+var mutualTLS = struct {
+	sync.RWMutex
+	enabled bool
+}{enabled: true}
+
+// SetMutualTLS sets whether gRPC server set up by SetupTLSCredentials
+// requires clients to present a certificate signed by the CA, which is the
+// default. Otherwise client certificates are optional, verified against the
+// CA when presented, and the CA certificate may be omitted
+func SetMutualTLS(enabled bool) {
+	mutualTLS.Lock()
+	defer mutualTLS.Unlock()
+	mutualTLS.enabled = enabled
+}
+
+func mutualTLSEnabled() bool {
+	mutualTLS.RLock()
+	defer mutualTLS.RUnlock()
+	return mutualTLS.enabled
+}
+
 // TLSConfig contains information required to enable TLS for gRPC server.
 type TLSConfig struct {
 	ServerCertPath string
@@ -25,14 +51,14 @@ type TLSConfig struct {
 }
 
 // ParseTLSFiles parses a string containing server certificate,
-// server key and CA certificate separated by `:`
+// server key and optionally CA certificate separated by `:`
 func ParseTLSFiles(tlsFiles string) (TLSConfig, error) {
 	files := strings.Split(tlsFiles, ":")
 
 	numOfFiles := len(files)
-	if numOfFiles != 3 {
+	if numOfFiles != 2 && numOfFiles != 3 {
 		return TLSConfig{}, errors.New("wrong number of path entries provided." +
-			"Expect <server cert>:<server key>:<ca cert> are provided separated by `:`")
+			"Expect <server cert>:<server key>[:<ca cert>] are provided separated by `:`")
 	}
 
 	tls := TLSConfig{}
@@ -48,6 +74,9 @@ func ParseTLSFiles(tlsFiles string) (TLSConfig, error) {
 		return TLSConfig{}, fmt.Errorf(emptyPathErr, "server key")
 	}
 
+	if numOfFiles == 2 {
+		return tls, nil
+	}
 	tls.CaCertPath = files[2]
 	if tls.CaCertPath == "" {
 		return TLSConfig{}, fmt.Errorf(emptyPathErr, "CA cert")
@@ -65,15 +94,58 @@ func setupTLSCredentials(config TLSConfig,
 	loadX509KeyPair func(string, string) (tls.Certificate, error),
 	readFile func(string) ([]byte, error),
 ) (grpc.ServerOption, error) {
+	c, err := newServerTLSConfig(config, loadX509KeyPair, readFile)
+	if err != nil {
+		return nil, err
+	}
+	serverTLSConfig.Store(c)
+
+	// every new connection picks up the latest config, see ReloadTLSCredentials
+	return grpc.Creds(credentials.NewTLS(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return serverTLSConfig.Load(), nil
+		},
+	})), nil
+}
+
+// ReloadTLSCredentials re-reads TLS files and applies them to new connections
+// of gRPC server set up by SetupTLSCredentials, existing ones are kept
+func ReloadTLSCredentials(config TLSConfig) error {
+	if serverTLSConfig.Load() == nil {
+		return errors.New("TLS credentials were not set up")
+	}
+	c, err := newServerTLSConfig(config, tls.LoadX509KeyPair, os.ReadFile)
+	if err != nil {
+		return err
+	}
+	serverTLSConfig.Store(c)
+	return nil
+}
+
+func newServerTLSConfig(config TLSConfig,
+	loadX509KeyPair func(string, string) (tls.Certificate, error),
+	readFile func(string) ([]byte, error),
+) (*tls.Config, error) {
+	mutual := mutualTLSEnabled()
+	if mutual && config.CaCertPath == "" {
+		return nil, errors.New("mutual TLS requires CA certificate to verify client certificates. " +
+			"Provide <server cert>:<server key>:<ca cert> or disable mutual TLS")
+	}
 	serverCert, err := loadX509KeyPair(config.ServerCertPath, config.ServerKeyPath)
 	if err != nil {
 		return nil, err
 	}
 
+	clientAuth := tls.RequireAndVerifyClientCert
+	if !mutual {
+		clientAuth = tls.VerifyClientCertIfGiven
+	}
 	c := &tls.Config{
 		Certificates: []tls.Certificate{serverCert},
-		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientAuth:   clientAuth,
 		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2"},
 		CipherSuites: []uint16{
 			tls.TLS_AES_256_GCM_SHA384,
 			tls.TLS_AES_128_GCM_SHA256,
@@ -85,6 +157,11 @@ func setupTLSCredentials(config TLSConfig,
 		},
 	}
 
+	if config.CaCertPath == "" {
+		log.Println("No client CA certificate, client certificates are not requested")
+		c.ClientAuth = tls.NoClientCert
+		return c, nil
+	}
 	c.ClientCAs = x509.NewCertPool()
 	log.Println("Loading client ca certificate:", config.CaCertPath)
 
@@ -97,5 +174,5 @@ func setupTLSCredentials(config TLSConfig,
 		return nil, fmt.Errorf("failed to add client CA's certificate: %v", config.CaCertPath)
 	}
 
-	return grpc.Creds(credentials.NewTLS(c)), nil
+	return c, nil
 }
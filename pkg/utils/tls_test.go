@@ -5,9 +5,27 @@
 package utils
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func TestServer_ParseTLSFiles(t *testing.T) {
@@ -34,9 +52,9 @@ func TestServer_ParseTLSFiles(t *testing.T) {
 		},
 		"2 files are provided": {
 			tlsStr:     "a:b",
-			expectErr:  true,
-			serverCert: "",
-			serverKey:  "",
+			expectErr:  false,
+			serverCert: "a",
+			serverKey:  "b",
 			caCert:     "",
 		},
 		"3 files are provided": {
@@ -175,3 +193,181 @@ func TestServer_SetupTLSCredentials(t *testing.T) {
 		})
 	}
 }
+
+// testPKI is CA with server and client certificates it signed
+type testPKI struct {
+	files      TLSConfig
+	caPool     *x509.CertPool
+	clientCert tls.Certificate
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	dir := t.TempDir()
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	caKey := newKey()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue := func(serial int64, name string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+		key := newKey()
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			DNSNames:     []string{name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDer, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	}
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	pki := &testPKI{caPool: x509.NewCertPool()}
+	pki.caPool.AddCert(caCert)
+	serverCert, serverKey := issue(2, "localhost", x509.ExtKeyUsageServerAuth)
+	pki.files = TLSConfig{
+		ServerCertPath: write("server.crt", serverCert),
+		ServerKeyPath:  write("server.key", serverKey),
+		CaCertPath:     write("ca.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDer})),
+	}
+	clientCert, clientKey := issue(3, "client", x509.ExtKeyUsageClientAuth)
+	if pki.clientCert, err = tls.X509KeyPair(clientCert, clientKey); err != nil {
+		t.Fatal(err)
+	}
+	return pki
+}
+
+// checkTLSServerHealth serves health service with TLS config and calls it
+// from a client presenting clientCerts
+func checkTLSServerHealth(t *testing.T, config TLSConfig, caPool *x509.CertPool, clientCerts []tls.Certificate) error {
+	option, err := SetupTLSCredentials(config)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	server := grpc.NewServer(option)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	creds := credentials.NewTLS(&tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		ServerName:   "localhost",
+		RootCAs:      caPool,
+		Certificates: clientCerts,
+	})
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(creds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestSetupTLSCredentials_ClientAuth(t *testing.T) {
+	pki := newTestPKI(t)
+	tests := map[string]struct {
+		mutual      bool
+		noCaCert    bool
+		clientCerts []tls.Certificate
+		connects    bool
+	}{
+		"mutual TLS rejects client without certificate": {
+			mutual:      true,
+			noCaCert:    false,
+			clientCerts: nil,
+			connects:    false,
+		},
+		"mutual TLS accepts client with valid certificate": {
+			mutual:      true,
+			noCaCert:    false,
+			clientCerts: []tls.Certificate{pki.clientCert},
+			connects:    true,
+		},
+		"one-way TLS accepts client without certificate": {
+			mutual:      false,
+			noCaCert:    false,
+			clientCerts: nil,
+			connects:    true,
+		},
+		"one-way TLS accepts client with valid certificate": {
+			mutual:      false,
+			noCaCert:    false,
+			clientCerts: []tls.Certificate{pki.clientCert},
+			connects:    true,
+		},
+		"one-way TLS without CA certificate": {
+			mutual:      false,
+			noCaCert:    true,
+			clientCerts: nil,
+			connects:    true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			SetMutualTLS(tt.mutual)
+			defer SetMutualTLS(true)
+			config := pki.files
+			if tt.noCaCert {
+				config.CaCertPath = ""
+			}
+
+			err := checkTLSServerHealth(t, config, pki.caPool, tt.clientCerts)
+
+			if (err == nil) != tt.connects {
+				t.Error("expected to connect", tt.connects, "received", err)
+			}
+		})
+	}
+}
+
+func TestSetupTLSCredentials_MutualTLSWithoutCaCert(t *testing.T) {
+	SetMutualTLS(true)
+	_, err := SetupTLSCredentials(TLSConfig{ServerCertPath: "a", ServerKeyPath: "b"})
+
+	if err == nil || !strings.Contains(err.Error(), "mutual TLS requires CA certificate") {
+		t.Error("expected missing CA certificate error, received", err)
+	}
+}
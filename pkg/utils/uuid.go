@@ -0,0 +1,39 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ValidateUUID checks UUID provided by client is well formed, omitted UUID
+// is valid and is generated by EnsureUUID
+func ValidateUUID(value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, err := uuid.Parse(value); err != nil {
+		msg := fmt.Sprintf("invalid uuid %q: %v", value, err)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+// EnsureUUID returns UUID to be passed to SPDK and stored: value itself if
+// provided, otherwise a generated v4 UUID, so that the bridge knows UUID
+// of created object instead of SPDK assigning a random one
+func EnsureUUID(value string) (string, error) {
+	if value == "" {
+		return uuid.NewString(), nil
+	}
+	if err := ValidateUUID(value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestEnsureUUID(t *testing.T) {
+	tests := map[string]struct {
+		in        string
+		out       string
+		generated bool
+		errCode   codes.Code
+	}{
+		"provided uuid is kept": {
+			in:      "11d3902e-d9bb-49a7-bb27-cd7261ef3217",
+			out:     "11d3902e-d9bb-49a7-bb27-cd7261ef3217",
+			errCode: codes.OK,
+		},
+		"omitted uuid is generated": {
+			in:        "",
+			generated: true,
+			errCode:   codes.OK,
+		},
+		"invalid uuid": {
+			in:      "not-a-uuid",
+			errCode: codes.InvalidArgument,
+		},
+	}
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			out, err := EnsureUUID(tt.in)
+
+			if status.Code(err) != tt.errCode {
+				t.Error("expected err code", tt.errCode, "received", status.Code(err))
+			}
+			if tt.generated {
+				parsed, err := uuid.Parse(out)
+				if err != nil || parsed.Version() != 4 {
+					t.Error("expected generated v4 uuid, received", out)
+				}
+			} else if out != tt.out {
+				t.Error("expected", tt.out, "received", out)
+			}
+		})
+	}
+}
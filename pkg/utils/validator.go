@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"go.einride.tech/aip/fieldbehavior"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// Validator accumulates all validation failures of a request instead of
+// stopping at the first one, so they can be reported to client together
+type Validator struct {
+	violations []*errdetails.BadRequest_FieldViolation
+	errs       []error
+}
+
+// Check records err as a violation of field, nil err is ignored
+func (v *Validator) Check(field string, err error) {
+	if err == nil {
+		return
+	}
+	v.errs = append(v.errs, err)
+	v.violations = append(v.violations, &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: status.Convert(err).Message(),
+	})
+}
+
+// CheckRequiredFields records every field annotated as required which
+// does not have a value, see https://aip.dev/203
+func (v *Validator) CheckRequiredFields(m proto.Message) {
+	v.CheckRequiredFieldsWithMask(m, &fieldmaskpb.FieldMask{Paths: []string{"*"}})
+}
+
+// CheckRequiredFieldsWithMask is like CheckRequiredFields, but limited to
+// required fields in mask
+func (v *Validator) CheckRequiredFieldsWithMask(m proto.Message, mask *fieldmaskpb.FieldMask) {
+	v.checkRequiredFields(m.ProtoReflect(), func(path string) bool {
+		return hasMaskPath(mask, path)
+	}, "")
+}
+
+// CheckRequiredFieldsExcept is like CheckRequiredFields, but skips required
+// fields at paths, e.g. ones server fills in when omitted
+func (v *Validator) CheckRequiredFieldsExcept(m proto.Message, paths ...string) {
+	v.checkRequiredFields(m.ProtoReflect(), func(path string) bool {
+		for _, p := range paths {
+			if p == path {
+				return false
+			}
+		}
+		return true
+	}, "")
+}
+
+func (v *Validator) checkRequiredFields(m protoreflect.Message, checked func(path string) bool, path string) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		fieldPath := string(field.Name())
+		if path != "" {
+			fieldPath = path + "." + fieldPath
+		}
+		switch {
+		case !m.Has(field):
+			if fieldbehavior.Has(field, annotations.FieldBehavior_REQUIRED) && checked(fieldPath) {
+				v.Check(fieldPath, fmt.Errorf("missing required field: %s", fieldPath))
+			}
+		case field.Kind() != protoreflect.MessageKind || field.IsMap():
+			continue
+		case field.IsList():
+			list := m.Get(field).List()
+			for j := 0; j < list.Len(); j++ {
+				v.checkRequiredFields(list.Get(j).Message(), checked, fieldPath)
+			}
+		default:
+			v.checkRequiredFields(m.Get(field).Message(), checked, fieldPath)
+		}
+	}
+}
+
+func hasMaskPath(mask *fieldmaskpb.FieldMask, path string) bool {
+	for _, p := range mask.GetPaths() {
+		if p == "*" || p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns nil if no violation was recorded. A single violation keeps
+// code and message of the original error, several violations are reported
+// as InvalidArgument. In both cases all violations are attached as
+// google.rpc.BadRequest details
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	st := status.Convert(v.errs[0])
+	if len(v.errs) > 1 {
+		descriptions := make([]string, len(v.violations))
+		for i, violation := range v.violations {
+			descriptions[i] = violation.Description
+		}
+		st = status.New(codes.InvalidArgument, strings.Join(descriptions, "; "))
+	}
+	detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: v.violations})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"errors"
+	"testing"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestValidator_Err(t *testing.T) {
+	tests := map[string]struct {
+		validate   func(v *Validator)
+		errCode    codes.Code
+		errMsg     string
+		violations []*errdetails.BadRequest_FieldViolation
+	}{
+		"no violations": {
+			validate: func(v *Validator) {
+				v.CheckRequiredFields(&pb.CreateNullVolumeRequest{
+					NullVolume: &pb.NullVolume{BlockSize: 512, BlocksCount: 64},
+				})
+				v.Check("null_volume_id", nil)
+			},
+			errCode:    codes.OK,
+			errMsg:     "",
+			violations: nil,
+		},
+		"single violation keeps original error": {
+			validate: func(v *Validator) {
+				v.Check("null_volume_id", errors.New("invalid id"))
+			},
+			errCode: codes.Unknown,
+			errMsg:  "invalid id",
+			violations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "null_volume_id", Description: "invalid id"},
+			},
+		},
+		"single status violation keeps original code": {
+			validate: func(v *Validator) {
+				v.Check("null_volume.block_size", status.Error(codes.FailedPrecondition, "bad block size"))
+			},
+			errCode: codes.FailedPrecondition,
+			errMsg:  "bad block size",
+			violations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "null_volume.block_size", Description: "bad block size"},
+			},
+		},
+		"all missing required fields reported": {
+			validate: func(v *Validator) {
+				v.CheckRequiredFields(&pb.CreateNullVolumeRequest{NullVolume: &pb.NullVolume{}})
+			},
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: null_volume.block_size; missing required field: null_volume.blocks_count",
+			violations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "null_volume.block_size", Description: "missing required field: null_volume.block_size"},
+				{Field: "null_volume.blocks_count", Description: "missing required field: null_volume.blocks_count"},
+			},
+		},
+		"required fields limited by mask": {
+			validate: func(v *Validator) {
+				v.CheckRequiredFieldsWithMask(
+					&pb.CreateNullVolumeRequest{NullVolume: &pb.NullVolume{}},
+					&fieldmaskpb.FieldMask{Paths: []string{"null_volume", "null_volume.blocks_count"}},
+				)
+			},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: null_volume.blocks_count",
+			violations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "null_volume.blocks_count", Description: "missing required field: null_volume.blocks_count"},
+			},
+		},
+		"required fields except skipped paths": {
+			validate: func(v *Validator) {
+				v.CheckRequiredFieldsExcept(
+					&pb.CreateNullVolumeRequest{NullVolume: &pb.NullVolume{}},
+					"null_volume.block_size",
+				)
+			},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: null_volume.blocks_count",
+			violations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "null_volume.blocks_count", Description: "missing required field: null_volume.blocks_count"},
+			},
+		},
+		"required and custom violations reported together": {
+			validate: func(v *Validator) {
+				v.CheckRequiredFields(&pb.CreateNullVolumeRequest{})
+				v.Check("null_volume_id", errors.New("invalid id"))
+			},
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: null_volume; invalid id",
+			violations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "null_volume", Description: "missing required field: null_volume"},
+				{Field: "null_volume_id", Description: "invalid id"},
+			},
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			v := &Validator{}
+			tt.validate(v)
+			err := v.Err()
+
+			st := status.Convert(err)
+			if st.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", st.Code())
+			}
+			if st.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", st.Message())
+			}
+
+			var violations []*errdetails.BadRequest_FieldViolation
+			for _, detail := range st.Details() {
+				if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+					violations = append(violations, badRequest.FieldViolations...)
+				}
+			}
+			if len(violations) != len(tt.violations) {
+				t.Fatal("violations: expected", tt.violations, "received", violations)
+			}
+			for i := range violations {
+				if !proto.Equal(violations[i], tt.violations[i]) {
+					t.Error("violation: expected", tt.violations[i], "received", violations[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// ResponseVerbosityMetadataKey is request metadata key selecting how much
+// of the created resource Create calls return
+const ResponseVerbosityMetadataKey = "opi-response-verbosity"
+
+// MinimalResponseVerbosity makes Create calls return only name and status
+// of the created resource
+const MinimalResponseVerbosity = "minimal"
+
+// minimalResponseFields are fields kept in minimal responses, if resource
+// has them
+var minimalResponseFields = []protoreflect.Name{"name", "status"}
+
+// MinimalResponse returns copy of msg with only name and status set
+func MinimalResponse(msg proto.Message) proto.Message {
+	fields := msg.ProtoReflect().Descriptor().Fields()
+	mask := &fieldmaskpb.FieldMask{}
+	for _, name := range minimalResponseFields {
+		if fields.ByName(name) != nil {
+			mask.Paths = append(mask.Paths, string(name))
+		}
+	}
+	return projectMessage(msg, mask)
+}
+
+func minimalResponseRequested(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(ResponseVerbosityMetadataKey)
+	return len(values) > 0 && values[0] == MinimalResponseVerbosity
+}
+
+// ResponseVerbosityUnaryServerInterceptor projects responses of Create
+// calls to name and status when requested in ResponseVerbosityMetadataKey
+// metadata, to reduce payload of bulk provisioning
+func ResponseVerbosityUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !minimalResponseRequested(ctx) || !strings.HasPrefix(path.Base(info.FullMethod), "Create") {
+		return handler(ctx, req)
+	}
+	response, err := handler(ctx, req)
+	if err != nil {
+		return response, err
+	}
+	msg, ok := response.(proto.Message)
+	if !ok {
+		return response, nil
+	}
+	return MinimalResponse(msg), nil
+}
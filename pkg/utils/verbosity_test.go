@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+func TestResponseVerbosityUnaryServerInterceptor(t *testing.T) {
+	namespace := &pb.NvmeNamespace{
+		Name: "nvmeSubsystems/subsys0/nvmeNamespaces/ns0",
+		Spec: &pb.NvmeNamespaceSpec{HostNsid: 22, VolumeNameRef: "Malloc1"},
+		Status: &pb.NvmeNamespaceStatus{
+			State:     pb.NvmeNamespaceStatus_STATE_ENABLED,
+			OperState: pb.NvmeNamespaceStatus_OPER_STATE_ONLINE,
+		},
+	}
+	volume := &pb.NullVolume{Name: "volumes/mytest", BlockSize: 512, BlocksCount: 64}
+
+	tests := map[string]struct {
+		verbosity string
+		method    string
+		response  proto.Message
+		out       proto.Message
+	}{
+		"minimal create": {
+			verbosity: MinimalResponseVerbosity,
+			method:    "/opi_api.storage.v1.FrontendNvmeService/CreateNvmeNamespace",
+			response:  namespace,
+			out:       &pb.NvmeNamespace{Name: namespace.Name, Status: namespace.Status},
+		},
+		"minimal create of resource without status": {
+			verbosity: MinimalResponseVerbosity,
+			method:    "/opi_api.storage.v1.NullVolumeService/CreateNullVolume",
+			response:  volume,
+			out:       &pb.NullVolume{Name: volume.Name},
+		},
+		"default create": {
+			verbosity: "",
+			method:    "/opi_api.storage.v1.FrontendNvmeService/CreateNvmeNamespace",
+			response:  namespace,
+			out:       namespace,
+		},
+		"unknown verbosity": {
+			verbosity: "full",
+			method:    "/opi_api.storage.v1.FrontendNvmeService/CreateNvmeNamespace",
+			response:  namespace,
+			out:       namespace,
+		},
+		"minimal ignored for other methods": {
+			verbosity: MinimalResponseVerbosity,
+			method:    "/opi_api.storage.v1.FrontendNvmeService/UpdateNvmeNamespace",
+			response:  namespace,
+			out:       namespace,
+		},
+	}
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			original := proto.Clone(tt.response)
+			ctx := context.Background()
+			if tt.verbosity != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(ResponseVerbosityMetadataKey, tt.verbosity))
+			}
+			handler := func(context.Context, interface{}) (interface{}, error) {
+				return tt.response, nil
+			}
+
+			response, err := ResponseVerbosityUnaryServerInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+
+			if msg, ok := response.(proto.Message); !ok || !proto.Equal(msg, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+			if !proto.Equal(tt.response, original) {
+				t.Error("handler response must not be modified, received", tt.response)
+			}
+		})
+	}
+}
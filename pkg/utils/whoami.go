@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// IdentityServiceName is full name of the service reporting identity of
+// the caller as resolved by the server. It is not part of OPI API, so it is
+// registered with a hand written service descriptor
+const IdentityServiceName = "opi_spdk_bridge.v1.IdentityService"
+
+// CallerCertificate is client certificate presented over TLS
+type CallerCertificate struct {
+	Subject    string   `json:"subject"`
+	CommonName string   `json:"common_name"`
+	DNSNames   []string `json:"dns_names"`
+	// Verified is false if certificate did not chain to a trusted CA, such
+	// certificate is not considered for admin identities
+	Verified bool `json:"verified"`
+}
+
+// CallerIdentity is identity of the caller and privileges it resolves to
+type CallerIdentity struct {
+	PeerAddress string `json:"peer_address"`
+	// AuthType is security protocol of the connection, e.g. "tls", empty
+	// for insecure connections
+	AuthType    string             `json:"auth_type"`
+	Certificate *CallerCertificate `json:"certificate"`
+	// AdminIdentity is certificate name matching one of admin identities
+	AdminIdentity string `json:"admin_identity"`
+	Admin         bool   `json:"admin"`
+}
+
+// IdentityServer reports identity of the caller to diagnose TLS and admin
+// identities configuration
+type IdentityServer struct {
+	admins map[string]struct{}
+}
+
+// NewIdentityServer creates identity server resolving admin privileges
+// against admins, same as admin interceptor does
+func NewIdentityServer(admins []string) *IdentityServer {
+	return &IdentityServer{admins: allowedAdmins(admins)}
+}
+
+// Identity returns identity of the caller of ctx
+func (s *IdentityServer) Identity(ctx context.Context) *CallerIdentity {
+	identity := &CallerIdentity{}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return identity
+	}
+	if p.Addr != nil {
+		identity.PeerAddress = p.Addr.String()
+	}
+	if p.AuthInfo != nil {
+		identity.AuthType = p.AuthInfo.AuthType()
+	}
+	if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.PeerCertificates) > 0 {
+		cert := tlsInfo.State.PeerCertificates[0]
+		dnsNames := cert.DNSNames
+		if dnsNames == nil {
+			dnsNames = []string{}
+		}
+		identity.Certificate = &CallerCertificate{
+			Subject:    cert.Subject.String(),
+			CommonName: cert.Subject.CommonName,
+			DNSNames:   dnsNames,
+			Verified:   len(tlsInfo.State.VerifiedChains) > 0,
+		}
+	}
+	identity.AdminIdentity = matchAdmin(ctx, s.admins)
+	identity.Admin = identity.AdminIdentity != ""
+	return identity
+}
+
+// WhoAmI returns CallerIdentity as a struct
+func (s *IdentityServer) WhoAmI(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	data, err := json.Marshal(s.Identity(ctx))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	response := &structpb.Struct{}
+	if err := response.UnmarshalJSON(data); err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	return response, nil
+}
+
+// identityServiceServer is implemented by IdentityServer
+type identityServiceServer interface {
+	WhoAmI(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+}
+
+var identityServiceDesc = grpc.ServiceDesc{
+	ServiceName: IdentityServiceName,
+	HandlerType: (*identityServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "WhoAmI",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(emptypb.Empty)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(identityServiceServer).WhoAmI(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: "/" + IdentityServiceName + "/WhoAmI",
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(identityServiceServer).WhoAmI(ctx, req.(*emptypb.Empty))
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterIdentityServer registers identity service on s
+func RegisterIdentityServer(s *grpc.Server, srv *IdentityServer) {
+	s.RegisterService(&identityServiceDesc, srv)
+}
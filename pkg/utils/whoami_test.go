@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2022-2024 Dell Inc, or its subsidiaries.
+
+// Package utils contains useful helper functions
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var testPeerAddr = &net.TCPAddr{IP: net.IPv4(10, 0, 0, 7), Port: 51234}
+
+// tlsClientContext returns context of TLS client presenting certificate,
+// verified if chained to a trusted CA
+func tlsClientContext(cert *x509.Certificate, verified bool) context.Context {
+	state := tls.ConnectionState{}
+	if cert != nil {
+		state.PeerCertificates = []*x509.Certificate{cert}
+		if verified {
+			state.VerifiedChains = [][]*x509.Certificate{{cert}}
+		}
+	}
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr:     testPeerAddr,
+		AuthInfo: credentials.TLSInfo{State: state},
+	})
+}
+
+func TestIdentityServer_Identity(t *testing.T) {
+	adminCert := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "ops", Organization: []string{"OPI"}},
+		DNSNames: []string{"ops.example.com"},
+	}
+	clientCert := &x509.Certificate{Subject: pkix.Name{CommonName: "client"}}
+	tests := map[string]struct {
+		ctx  context.Context
+		want *CallerIdentity
+	}{
+		"admin client certificate": {
+			ctx: tlsClientContext(adminCert, true),
+			want: &CallerIdentity{
+				PeerAddress: "10.0.0.7:51234",
+				AuthType:    "tls",
+				Certificate: &CallerCertificate{
+					Subject:    "CN=ops,O=OPI",
+					CommonName: "ops",
+					DNSNames:   []string{"ops.example.com"},
+					Verified:   true,
+				},
+				AdminIdentity: "ops.example.com",
+				Admin:         true,
+			},
+		},
+		"not admin client certificate": {
+			ctx: tlsClientContext(clientCert, true),
+			want: &CallerIdentity{
+				PeerAddress: "10.0.0.7:51234",
+				AuthType:    "tls",
+				Certificate: &CallerCertificate{
+					Subject:    "CN=client",
+					CommonName: "client",
+					DNSNames:   []string{},
+					Verified:   true,
+				},
+				AdminIdentity: "",
+				Admin:         false,
+			},
+		},
+		"unverified admin client certificate": {
+			ctx: tlsClientContext(adminCert, false),
+			want: &CallerIdentity{
+				PeerAddress: "10.0.0.7:51234",
+				AuthType:    "tls",
+				Certificate: &CallerCertificate{
+					Subject:    "CN=ops,O=OPI",
+					CommonName: "ops",
+					DNSNames:   []string{"ops.example.com"},
+					Verified:   false,
+				},
+				AdminIdentity: "",
+				Admin:         false,
+			},
+		},
+		"tls without client certificate": {
+			ctx: tlsClientContext(nil, false),
+			want: &CallerIdentity{
+				PeerAddress: "10.0.0.7:51234",
+				AuthType:    "tls",
+				Certificate: nil,
+			},
+		},
+		"insecure connection": {
+			ctx: peer.NewContext(context.Background(), &peer.Peer{Addr: testPeerAddr}),
+			want: &CallerIdentity{
+				PeerAddress: "10.0.0.7:51234",
+				AuthType:    "",
+			},
+		},
+		"no peer": {
+			ctx:  context.Background(),
+			want: &CallerIdentity{},
+		},
+	}
+	server := NewIdentityServer([]string{"admin", "ops.example.com"})
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			identity := server.Identity(tt.ctx)
+
+			if !reflect.DeepEqual(identity, tt.want) {
+				t.Errorf("identity: expected %+v received %+v", tt.want, identity)
+			}
+		})
+	}
+}
+
+func TestIdentityServer_WhoAmI(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "admin"}}
+
+	response, err := NewIdentityServer([]string{"admin"}).WhoAmI(tlsClientContext(cert, true), &emptypb.Empty{})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if !response.GetFields()["admin"].GetBoolValue() {
+		t.Error("admin: expected true, received", response.GetFields()["admin"])
+	}
+	certificate := response.GetFields()["certificate"].GetStructValue().GetFields()
+	if name := certificate["common_name"].GetStringValue(); name != "admin" {
+		t.Error("common name: expected admin, received", name)
+	}
+}
+
+func TestRegisterIdentityServer(t *testing.T) {
+	s := grpc.NewServer()
+	RegisterIdentityServer(s, NewIdentityServer(nil))
+
+	info, ok := s.GetServiceInfo()[IdentityServiceName]
+	if !ok {
+		t.Fatal("expected", IdentityServiceName, "to be registered")
+	}
+	if len(info.Methods) != 1 || info.Methods[0].Name != "WhoAmI" {
+		t.Error("methods: expected [WhoAmI], received", info.Methods)
+	}
+}